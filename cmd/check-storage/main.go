@@ -0,0 +1,92 @@
+// Command check-storage compares the configured storage with the files table. It reports
+// stored files without a row, rows whose file or thumbnails are missing, and rows whose
+// past exam, class note, community, profile or chat message no longer exists.
+//
+// It reads the same configuration as the API (configs/config.yaml plus environment
+// variables). Without flags it only reports; it exits with status 2 if it found anything.
+//
+//	go run ./cmd/check-storage
+//	go run ./cmd/check-storage -delete-orphans -repair
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/bootstrap"
+	"github.com/yigit/unisphere/internal/db"
+)
+
+func main() {
+	deleteOrphans := flag.Bool("delete-orphans", false, "Delete stored files that no row or upload refers to")
+	repair := flag.Bool("repair", false, "Delete rows whose file or resource is gone and drop missing thumbnails from rows")
+	flag.Parse()
+
+	cfg, lgr, err := bootstrap.LoadConfigAndSetupLogger()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	database, err := db.NewPostgresDB(cfg)
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("Failed to connect to database")
+	}
+	defer database.Close()
+
+	blobs := repositories.NewBlobRepository(database.Pool)
+	storage, err := bootstrap.NewFileStorage(cfg, blobs)
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("Failed to open file storage")
+	}
+
+	checker := services.NewStorageCheckService(
+		repositories.NewFileRepository(database.Pool),
+		repositories.NewUploadSessionRepository(database.Pool),
+		blobs,
+		storage,
+		lgr,
+	)
+	report, err := checker.Check(context.Background(), services.StorageCheckOptions{
+		DeleteOrphans: *deleteOrphans,
+		Repair:        *repair,
+	})
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("Storage check aborted")
+	}
+
+	for _, object := range report.OrphanedFiles {
+		fmt.Printf("orphaned file      %s (%d bytes, modified %s)\n", object.Path, object.Size, object.ModTime.Format("2006-01-02 15:04"))
+	}
+	for _, file := range report.MissingFiles {
+		fmt.Printf("missing file       file %d %s (%s %d)\n", file.ID, file.FilePath, file.ResourceType, file.ResourceID)
+	}
+	for _, fileID := range slices.Sorted(maps.Keys(report.MissingThumbnails)) {
+		fmt.Printf("missing thumbnails file %d sizes %v\n", fileID, report.MissingThumbnails[fileID])
+	}
+	for _, file := range report.DetachedFiles {
+		fmt.Printf("detached file      file %d %s (%s %d no longer exists)\n", file.ID, file.FilePath, file.ResourceType, file.ResourceID)
+	}
+
+	lgr.Info().
+		Int("orphanedFiles", len(report.OrphanedFiles)).
+		Int("missingFiles", len(report.MissingFiles)).
+		Int("missingThumbnails", len(report.MissingThumbnails)).
+		Int("detachedFiles", len(report.DetachedFiles)).
+		Int("deleted", report.Deleted).
+		Int("repaired", report.Repaired).
+		Int("failed", report.Failed).
+		Msg("Storage check finished")
+	switch {
+	case report.Failed > 0:
+		os.Exit(1)
+	case report.Problems() > 0 && !*deleteOrphans && !*repair:
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,220 @@
+// Command migrate-storage copies files from the local upload directory into the
+// configured S3-compatible bucket and rewrites files.file_path and files.file_url.
+//
+// It reads the same configuration as the API (configs/config.yaml plus environment
+// variables) and can be re-run safely: rows already pointing at the bucket are skipped.
+//
+//	go run ./cmd/migrate-storage -dry-run
+//	go run ./cmd/migrate-storage -delete-local
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/bootstrap"
+	"github.com/yigit/unisphere/internal/db"
+	"github.com/yigit/unisphere/internal/pkg/filestorage"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Report what would be migrated without uploading or updating rows")
+	deleteLocal := flag.Bool("delete-local", false, "Delete local files after they were uploaded and their rows updated")
+	batchSize := flag.Int("batch", 200, "Number of file rows read per query")
+	flag.Parse()
+
+	cfg, lgr, err := bootstrap.LoadConfigAndSetupLogger()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	local, err := bootstrap.NewLocalStorage(cfg)
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("Failed to open local storage")
+	}
+	bucket, err := bootstrap.NewS3Storage(cfg)
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("Failed to connect to S3 storage; check the storage.s3 settings")
+	}
+
+	database, err := db.NewPostgresDB(cfg)
+	if err != nil {
+		lgr.Fatal().Err(err).Msg("Failed to connect to database")
+	}
+	defer database.Close()
+
+	m := &migrator{
+		files:       repositories.NewFileRepository(database.Pool),
+		local:       local,
+		bucket:      bucket,
+		dryRun:      *dryRun,
+		deleteLocal: *deleteLocal,
+		logger:      lgr,
+		uploaded:    make(map[string]string),
+	}
+	if err := m.run(context.Background(), *batchSize); err != nil {
+		lgr.Fatal().Err(err).Msg("Storage migration aborted")
+	}
+
+	lgr.Info().
+		Int("migrated", m.migrated).
+		Int("skipped", m.skipped).
+		Int("failed", m.failed).
+		Bool("dryRun", m.dryRun).
+		Msg("Storage migration finished")
+	if m.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// fileRows is the part of the file repository the migration reads and updates
+type fileRows interface {
+	ListAfter(ctx context.Context, afterID int64, limit int) ([]*models.File, error)
+	UpdateLocation(ctx context.Context, id int64, filePath, fileURL string) error
+}
+
+// migrator moves file rows from local storage to the bucket one at a time
+type migrator struct {
+	files       fileRows
+	local       *filestorage.LocalStorage
+	bucket      *filestorage.S3Storage
+	dryRun      bool
+	deleteLocal bool
+	logger      zerolog.Logger
+
+	// uploaded maps the stored paths uploaded so far to their URL; rows with the same
+	// content share a blob, which is uploaded once
+	uploaded map[string]string
+
+	migrated, skipped, failed int
+}
+
+func (m *migrator) run(ctx context.Context, batchSize int) error {
+	var lastID int64
+	for {
+		files, err := m.files.ListAfter(ctx, lastID, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return nil
+		}
+
+		for _, file := range files {
+			lastID = file.ID
+			if strings.HasPrefix(file.FileURL, m.bucket.GetBaseURL()+"/") {
+				m.skipped++
+				continue
+			}
+			if err := m.migrate(ctx, file); err != nil {
+				m.failed++
+				m.logger.Error().Err(err).Int64("fileID", file.ID).Str("filePath", file.FilePath).Msg("Failed to migrate file")
+				continue
+			}
+			m.migrated++
+		}
+	}
+}
+
+// migrate uploads one file and points its row at the uploaded object
+func (m *migrator) migrate(ctx context.Context, file *models.File) error {
+	storedPath := m.storedPath(file)
+	key := strings.TrimPrefix(storedPath, "/")
+	if key == "" {
+		return fmt.Errorf("file has no usable path")
+	}
+
+	if fileURL, ok := m.uploaded[storedPath]; ok {
+		if err := m.files.UpdateLocation(ctx, file.ID, storedPath, fileURL); err != nil {
+			return err
+		}
+		m.logger.Info().Int64("fileID", file.ID).Str("key", key).Msg("File migrated; its blob was already uploaded")
+		return nil
+	}
+
+	src, err := m.local.OpenFile(storedPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	size := int64(-1)
+	if stat, ok := src.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if info, err := stat.Stat(); err == nil {
+			size = info.Size()
+		}
+	}
+
+	if m.dryRun {
+		m.logger.Info().Int64("fileID", file.ID).Str("key", key).Int64("size", size).Msg("Would migrate file")
+		return nil
+	}
+
+	contentType := file.FileType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(key))
+	}
+	fileURL, err := m.bucket.PutObject(ctx, key, src, size, contentType)
+	if err != nil {
+		return err
+	}
+
+	// Thumbnails are named after the file and move with it
+	moved := &models.File{FilePath: storedPath, ThumbnailSizes: file.ThumbnailSizes}
+	for _, thumbnailSize := range moved.ThumbnailSizes {
+		if err := m.copyToBucket(ctx, moved.ThumbnailPath(thumbnailSize), contentType); err != nil {
+			_ = m.bucket.DeleteFile(storedPath)
+			return fmt.Errorf("thumbnail %d: %w", thumbnailSize, err)
+		}
+	}
+
+	if err := m.files.UpdateLocation(ctx, file.ID, storedPath, fileURL); err != nil {
+		// Leave the local copy in place; the row still points at it
+		_ = m.bucket.DeleteFile(storedPath)
+		return err
+	}
+
+	m.uploaded[storedPath] = fileURL
+	m.logger.Info().Int64("fileID", file.ID).Str("key", key).Msg("File migrated")
+
+	if m.deleteLocal {
+		_ = src.Close()
+		if err := m.local.DeleteFile(storedPath); err != nil {
+			m.logger.Warn().Err(err).Int64("fileID", file.ID).Msg("Failed to delete local copy")
+		}
+		for _, thumbnailSize := range moved.ThumbnailSizes {
+			_ = m.local.DeleteFile(moved.ThumbnailPath(thumbnailSize))
+		}
+	}
+	return nil
+}
+
+// copyToBucket uploads a local file that has no row of its own, such as a thumbnail
+func (m *migrator) copyToBucket(ctx context.Context, storedPath, contentType string) error {
+	src, err := m.local.OpenFile(storedPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = m.bucket.PutObject(ctx, strings.TrimPrefix(storedPath, "/"), src, -1, contentType)
+	return err
+}
+
+// storedPath normalizes the file_path of a row to the "/sub_path/name.ext" form both backends use
+func (m *migrator) storedPath(file *models.File) string {
+	p := file.FilePath
+	if p == "" {
+		p = file.FileURL
+	}
+	return filestorage.StoredPath(m.local.GetBaseURL(), p)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/pkg/filestorage"
+	"github.com/yigit/unisphere/internal/pkg/filestorage/s3test"
+)
+
+const (
+	testBucket   = "unisphere-test"
+	localBaseURL = "http://localhost:8080/uploads"
+)
+
+// memoryRows is a file table held in memory
+type memoryRows struct {
+	files     []*models.File // Ordered by ID
+	updateErr error
+}
+
+func (r *memoryRows) ListAfter(ctx context.Context, afterID int64, limit int) ([]*models.File, error) {
+	var page []*models.File
+	for _, file := range r.files {
+		if file.ID > afterID && len(page) < limit {
+			copied := *file
+			page = append(page, &copied)
+		}
+	}
+	return page, nil
+}
+
+func (r *memoryRows) UpdateLocation(ctx context.Context, id int64, filePath, fileURL string) error {
+	if r.updateErr != nil {
+		return r.updateErr
+	}
+	for _, file := range r.files {
+		if file.ID == id {
+			file.FilePath = filePath
+			file.FileURL = fileURL
+			return nil
+		}
+	}
+	return errors.New("file not found")
+}
+
+// migrationFixture is a local upload directory, a bucket and rows pointing into the directory
+type migrationFixture struct {
+	dir    string
+	server *s3test.Server
+	rows   *memoryRows
+	m      *migrator
+}
+
+func newMigrationFixture(t *testing.T, rows []*models.File, localFiles map[string]string) *migrationFixture {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range localFiles {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	local, err := filestorage.NewLocalStorage(dir, localBaseURL)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	server := s3test.NewServer()
+	t.Cleanup(server.Close)
+	bucket, err := filestorage.NewS3Storage(context.Background(), filestorage.S3Config{
+		Endpoint: server.Endpoint(), Region: "us-east-1", Bucket: testBucket,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+
+	f := &migrationFixture{dir: dir, server: server, rows: &memoryRows{files: rows}}
+	f.m = &migrator{
+		files:    f.rows,
+		local:    local,
+		bucket:   bucket,
+		logger:   zerolog.Nop(),
+		uploaded: make(map[string]string),
+	}
+	return f
+}
+
+func (f *migrationFixture) localExists(name string) bool {
+	_, err := os.Stat(filepath.Join(f.dir, filepath.FromSlash(name)))
+	return err == nil
+}
+
+func TestMigrate(t *testing.T) {
+	rows := []*models.File{
+		{ID: 1, FilePath: "/past_exams/a.pdf", FileURL: localBaseURL + "/past_exams/a.pdf", FileType: "application/pdf"},
+		// Older rows hold the full URL or an "uploads/..." path
+		{ID: 2, FilePath: localBaseURL + "/class_notes/b.txt", FileURL: localBaseURL + "/class_notes/b.txt", FileType: "text/plain"},
+		{ID: 3, FilePath: "uploads/class_notes/c.txt", FileURL: localBaseURL + "/class_notes/c.txt"},
+		// A second row sharing the blob of the first
+		{ID: 4, FilePath: "/past_exams/a.pdf", FileURL: localBaseURL + "/past_exams/a.pdf", FileType: "application/pdf"},
+		// Thumbnails move with their image
+		{ID: 5, FilePath: "/class_notes/d.jpg", FileURL: localBaseURL + "/class_notes/d.jpg", FileType: "image/jpeg", ThumbnailSizes: []int{256}},
+		// The local file is gone
+		{ID: 6, FilePath: "/past_exams/missing.pdf", FileURL: localBaseURL + "/past_exams/missing.pdf"},
+	}
+	localFiles := map[string]string{
+		"past_exams/a.pdf":      "pdf a",
+		"class_notes/b.txt":     "text b",
+		"class_notes/c.txt":     "text c",
+		"class_notes/d.jpg":     "jpeg d",
+		"class_notes/d_256.jpg": "thumbnail d",
+	}
+
+	f := newMigrationFixture(t, rows, localFiles)
+	if err := f.m.run(context.Background(), 2); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if f.m.migrated != 5 || f.m.skipped != 0 || f.m.failed != 1 {
+		t.Errorf("migrated %d, skipped %d, failed %d; want 5, 0, 1", f.m.migrated, f.m.skipped, f.m.failed)
+	}
+
+	wantKeys := []string{"class_notes/b.txt", "class_notes/c.txt", "class_notes/d.jpg", "class_notes/d_256.jpg", "past_exams/a.pdf"}
+	if keys := f.server.Keys(testBucket); strings.Join(keys, ",") != strings.Join(wantKeys, ",") {
+		t.Errorf("bucket holds %v, want %v", keys, wantKeys)
+	}
+	for key, content := range map[string]string{"past_exams/a.pdf": "pdf a", "class_notes/d_256.jpg": "thumbnail d"} {
+		if object := f.server.Object(testBucket, key); object == nil || string(object.Data) != content {
+			t.Errorf("object %s = %+v, want %q", key, object, content)
+		}
+	}
+	if object := f.server.Object(testBucket, "past_exams/a.pdf"); object.ContentType != "application/pdf" {
+		t.Errorf("content type = %q, want application/pdf", object.ContentType)
+	}
+
+	bucketURL := f.m.bucket.GetBaseURL()
+	tests := []struct {
+		id       int64
+		wantPath string
+		wantURL  string
+	}{
+		{1, "/past_exams/a.pdf", bucketURL + "/past_exams/a.pdf"},
+		{2, "/class_notes/b.txt", bucketURL + "/class_notes/b.txt"},
+		{3, "/class_notes/c.txt", bucketURL + "/class_notes/c.txt"},
+		{4, "/past_exams/a.pdf", bucketURL + "/past_exams/a.pdf"},
+		{5, "/class_notes/d.jpg", bucketURL + "/class_notes/d.jpg"},
+		{6, "/past_exams/missing.pdf", localBaseURL + "/past_exams/missing.pdf"},
+	}
+	for _, tt := range tests {
+		row := f.rows.files[tt.id-1]
+		if row.FilePath != tt.wantPath || row.FileURL != tt.wantURL {
+			t.Errorf("row %d = (%q, %q), want (%q, %q)", tt.id, row.FilePath, row.FileURL, tt.wantPath, tt.wantURL)
+		}
+	}
+
+	// Local copies are kept without -delete-local
+	for name := range localFiles {
+		if !f.localExists(name) {
+			t.Errorf("local file %s was deleted", name)
+		}
+	}
+
+	// A second run skips the rows already in the bucket
+	f.m.migrated, f.m.failed = 0, 0
+	if err := f.m.run(context.Background(), 10); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if f.m.migrated != 0 || f.m.skipped != 5 || f.m.failed != 1 {
+		t.Errorf("second run migrated %d, skipped %d, failed %d; want 0, 5, 1", f.m.migrated, f.m.skipped, f.m.failed)
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	rows := []*models.File{{ID: 1, FilePath: "/past_exams/a.pdf", FileURL: localBaseURL + "/past_exams/a.pdf"}}
+	f := newMigrationFixture(t, rows, map[string]string{"past_exams/a.pdf": "pdf a"})
+	f.m.dryRun = true
+
+	if err := f.m.run(context.Background(), 10); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if f.m.migrated != 1 {
+		t.Errorf("migrated %d, want 1", f.m.migrated)
+	}
+	if keys := f.server.Keys(testBucket); len(keys) != 0 {
+		t.Errorf("dry run uploaded %v", keys)
+	}
+	if row := f.rows.files[0]; row.FileURL != localBaseURL+"/past_exams/a.pdf" {
+		t.Errorf("dry run rewrote the row to %q", row.FileURL)
+	}
+}
+
+func TestMigrateDeleteLocal(t *testing.T) {
+	rows := []*models.File{
+		{ID: 1, FilePath: "/class_notes/d.jpg", FileURL: localBaseURL + "/class_notes/d.jpg", ThumbnailSizes: []int{256, 1024}},
+	}
+	localFiles := map[string]string{
+		"class_notes/d.jpg":      "jpeg",
+		"class_notes/d_256.jpg":  "small",
+		"class_notes/d_1024.jpg": "large",
+	}
+	f := newMigrationFixture(t, rows, localFiles)
+	f.m.deleteLocal = true
+
+	if err := f.m.run(context.Background(), 10); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for name := range localFiles {
+		if f.localExists(name) {
+			t.Errorf("local file %s was kept", name)
+		}
+		if f.server.Object(testBucket, name) == nil {
+			t.Errorf("object %s is missing", name)
+		}
+	}
+}
+
+func TestMigrateFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		localFiles map[string]string
+		updateErr  error
+	}{
+		// The upload is removed again so the bucket holds nothing no row points at
+		{"missing thumbnail", map[string]string{"class_notes/d.jpg": "jpeg"}, nil},
+		{"row update fails", map[string]string{"class_notes/d.jpg": "jpeg", "class_notes/d_256.jpg": "small"}, errors.New("database down")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := []*models.File{
+				{ID: 1, FilePath: "/class_notes/d.jpg", FileURL: localBaseURL + "/class_notes/d.jpg", ThumbnailSizes: []int{256}},
+			}
+			f := newMigrationFixture(t, rows, tt.localFiles)
+			f.rows.updateErr = tt.updateErr
+			f.m.deleteLocal = true
+
+			if err := f.m.run(context.Background(), 10); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if f.m.failed != 1 || f.m.migrated != 0 {
+				t.Errorf("migrated %d, failed %d; want 0, 1", f.m.migrated, f.m.failed)
+			}
+			if f.server.Object(testBucket, "class_notes/d.jpg") != nil {
+				t.Error("the uploaded file was left in the bucket")
+			}
+			if row := f.rows.files[0]; row.FileURL != localBaseURL+"/class_notes/d.jpg" {
+				t.Errorf("row points at %q after a failed migration", row.FileURL)
+			}
+			for name := range tt.localFiles {
+				if !f.localExists(name) {
+					t.Errorf("local file %s was deleted after a failed migration", name)
+				}
+			}
+		})
+	}
+}
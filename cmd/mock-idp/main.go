@@ -0,0 +1,397 @@
+// Command mock-idp is an OpenID Connect provider for trying single sign-on locally. It
+// serves discovery, an authorization endpoint with a form to choose the user's claims, a
+// token endpoint that checks PKCE, userinfo and its JWKS. Keys, codes and tokens live in
+// memory. Do not expose it: it logs in whoever fills in the form.
+//
+// Start it and point the API at it:
+//
+//	go run ./cmd/mock-idp -addr :9400 -student-number 20231234 -department CENG
+//	OIDC_ISSUER_URL=http://localhost:9400 OIDC_CLIENT_ID=unisphere \
+//	OIDC_REDIRECT_URL=http://localhost:3000/auth/sso/callback \
+//	OIDC_CLAIM_DEPARTMENT=department OIDC_CLAIM_STUDENT_NUMBER=student_number go run ./cmd/api
+//
+// With -auto the authorization endpoint skips the form and redirects with the user from the
+// flags, so the flow can be followed with curl.
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yigit/unisphere/internal/pkg/auth"
+	"github.com/yigit/unisphere/internal/pkg/oidc"
+)
+
+// codeTTL is how long an authorization code can be exchanged
+const codeTTL = time.Minute
+
+// tokenTTL is the lifetime of ID and access tokens
+const tokenTTL = time.Hour
+
+// user holds the claims of the user logging in
+type user struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Department    string
+	StudentNumber string
+}
+
+// claims returns the profile claims of the user
+func (u user) claims() map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub":            u.Subject,
+		"email":          u.Email,
+		"email_verified": u.EmailVerified,
+		"given_name":     u.GivenName,
+		"family_name":    u.FamilyName,
+		"name":           strings.TrimSpace(u.GivenName + " " + u.FamilyName),
+	}
+	if u.Department != "" {
+		claims["department"] = u.Department
+	}
+	if u.StudentNumber != "" {
+		claims["student_number"] = u.StudentNumber
+	}
+	return claims
+}
+
+// authorization is an issued code waiting to be exchanged
+type authorization struct {
+	user          user
+	nonce         string
+	codeChallenge string
+	redirectURI   string
+	expiresAt     time.Time
+}
+
+// provider is the mock OpenID Connect provider
+type provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	defaults     user
+	auto         bool
+	key          *auth.SigningKey
+
+	mu     sync.Mutex
+	codes  map[string]*authorization
+	tokens map[string]user // access token to user, for userinfo
+}
+
+func main() {
+	addr := flag.String("addr", ":9400", "Address to listen on")
+	issuer := flag.String("issuer", "http://localhost:9400", "Issuer URL; must be the URL the API reaches the provider at")
+	clientID := flag.String("client-id", "unisphere", "Client ID the API is registered with")
+	clientSecret := flag.String("client-secret", "", "Client secret; empty accepts a public client")
+	auto := flag.Bool("auto", false, "Redirect with the default user instead of showing the login form")
+	var defaults user
+	flag.StringVar(&defaults.Email, "email", "s20231234@student.example.edu", "Default email")
+	flag.BoolVar(&defaults.EmailVerified, "email-verified", true, "Default email_verified")
+	flag.StringVar(&defaults.GivenName, "given-name", "Ada", "Default given_name")
+	flag.StringVar(&defaults.FamilyName, "family-name", "Lovelace", "Default family_name")
+	flag.StringVar(&defaults.Department, "department", "", "Default department claim (a department code)")
+	flag.StringVar(&defaults.StudentNumber, "student-number", "", "Default student_number claim")
+	flag.StringVar(&defaults.Subject, "subject", "", "Default sub; derived from the email when empty")
+	flag.Parse()
+
+	key, err := auth.GenerateSigningKey(auth.AlgorithmRS256)
+	if err != nil {
+		log.Fatalf("failed to generate signing key: %v", err)
+	}
+
+	p := &provider{
+		issuer:       strings.TrimSuffix(*issuer, "/"),
+		clientID:     *clientID,
+		clientSecret: *clientSecret,
+		defaults:     defaults,
+		auto:         *auto,
+		key:          key,
+		codes:        make(map[string]*authorization),
+		tokens:       make(map[string]user),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/userinfo", p.userInfo)
+	mux.HandleFunc("/jwks", p.jwks)
+
+	log.Printf("mock OpenID Connect provider %s listening on %s", p.issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// discovery serves the provider metadata
+func (p *provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"userinfo_endpoint":                     p.issuer + "/userinfo",
+		"jwks_uri":                              p.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{auth.AlgorithmRS256},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
+
+// loginForm lets the tester choose who logs in
+var loginForm = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Mock OpenID Connect provider</title></head>
+<body>
+<h1>Log in to the mock provider</h1>
+<form method="post" action="/authorize">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}
+<p><label>Email <input name="email" value="{{.User.Email}}"></label>
+<label><input type="checkbox" name="email_verified" value="true"{{if .User.EmailVerified}} checked{{end}}> verified</label></p>
+<p><label>Given name <input name="given_name" value="{{.User.GivenName}}"></label></p>
+<p><label>Family name <input name="family_name" value="{{.User.FamilyName}}"></label></p>
+<p><label>Department code <input name="department" value="{{.User.Department}}"></label></p>
+<p><label>Student number <input name="student_number" value="{{.User.StudentNumber}}"></label></p>
+<p><label>Subject <input name="sub" value="{{.User.Subject}}" placeholder="derived from the email"></label></p>
+<p><button type="submit">Log in</button></p>
+</form>
+</body>
+</html>
+`))
+
+// authorizeParams are the authorization request parameters carried through the login form
+var authorizeParams = []string{"response_type", "client_id", "redirect_uri", "scope", "state", "nonce", "code_challenge", "code_challenge_method"}
+
+// authorize checks an authorization request, shows the login form and, once it is submitted,
+// redirects back with a code
+func (p *provider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	// Errors before the redirect URI is trusted are shown, not redirected
+	if r.Form.Get("client_id") != p.clientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(r.Form.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case r.Form.Get("response_type") != "code":
+		redirect(w, r, redirectURI, "unsupported_response_type", r.Form.Get("state"))
+		return
+	case !containsScope(r.Form.Get("scope"), "openid"):
+		redirect(w, r, redirectURI, "invalid_scope", r.Form.Get("state"))
+		return
+	case r.Form.Get("code_challenge") == "" || r.Form.Get("code_challenge_method") != "S256":
+		redirect(w, r, redirectURI, "invalid_request", r.Form.Get("state"))
+		return
+	}
+
+	u := p.defaults
+	if r.Method == http.MethodPost {
+		u = user{
+			Subject:       strings.TrimSpace(r.PostForm.Get("sub")),
+			Email:         strings.TrimSpace(r.PostForm.Get("email")),
+			EmailVerified: r.PostForm.Get("email_verified") == "true",
+			GivenName:     strings.TrimSpace(r.PostForm.Get("given_name")),
+			FamilyName:    strings.TrimSpace(r.PostForm.Get("family_name")),
+			Department:    strings.TrimSpace(r.PostForm.Get("department")),
+			StudentNumber: strings.TrimSpace(r.PostForm.Get("student_number")),
+		}
+	} else if !p.auto {
+		params := make(map[string]string, len(authorizeParams))
+		for _, name := range authorizeParams {
+			params[name] = r.Form.Get(name)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := loginForm.Execute(w, map[string]interface{}{"Params": params, "User": p.defaults}); err != nil {
+			log.Printf("failed to render login form: %v", err)
+		}
+		return
+	}
+	if u.Subject == "" {
+		sum := sha256.Sum256([]byte(strings.ToLower(u.Email)))
+		u.Subject = base64.RawURLEncoding.EncodeToString(sum[:12])
+	}
+
+	code, err := oidc.RandomToken()
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+	p.mu.Lock()
+	p.codes[code] = &authorization{
+		user:          u,
+		nonce:         r.Form.Get("nonce"),
+		codeChallenge: r.Form.Get("code_challenge"),
+		redirectURI:   redirectURI.String(),
+		expiresAt:     time.Now().Add(codeTTL),
+	}
+	p.mu.Unlock()
+	log.Printf("issued code for %s (sub %s)", u.Email, u.Subject)
+
+	query := redirectURI.Query()
+	query.Set("code", code)
+	if state := r.Form.Get("state"); state != "" {
+		query.Set("state", state)
+	}
+	redirectURI.RawQuery = query.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token exchanges a code for an ID token and an access token
+func (p *provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	// Basic credentials are form-encoded before being put in the header (RFC 6749 2.3.1)
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.clientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.clientSecret)) != 1 {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	// Codes are single use, whether the exchange succeeds or not
+	p.mu.Lock()
+	code := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	if code == nil || time.Now().After(code.expiresAt) || code.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	if oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != code.codeChallenge {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims(code.user.claims())
+	claims["iss"] = p.issuer
+	claims["aud"] = p.clientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(tokenTTL).Unix()
+	if code.nonce != "" {
+		claims["nonce"] = code.nonce
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = p.key.ID
+	signed, err := idToken.SignedString(p.key.PrivateKey)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	accessToken, err := oidc.RandomToken()
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+	p.mu.Lock()
+	p.tokens[accessToken] = code.user
+	p.mu.Unlock()
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(tokenTTL.Seconds()),
+		"id_token":     signed,
+	})
+}
+
+// userInfo returns the claims of the user an access token was issued to
+func (p *provider) userInfo(w http.ResponseWriter, r *http.Request) {
+	accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	p.mu.Lock()
+	u, ok := p.tokens[accessToken]
+	p.mu.Unlock()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, u.claims())
+}
+
+// jwks serves the public key ID tokens are signed with
+func (p *provider) jwks(w http.ResponseWriter, r *http.Request) {
+	jwk, err := p.key.PublicJWK()
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, auth.JWKS{Keys: []auth.JWK{jwk}})
+}
+
+// redirect sends an authorization error back to the client
+func redirect(w http.ResponseWriter, r *http.Request, redirectURI *url.URL, errorCode, state string) {
+	query := redirectURI.Query()
+	query.Set("error", errorCode)
+	if state != "" {
+		query.Set("state", state)
+	}
+	redirectURI.RawQuery = query.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// tokenError writes a token endpoint error response
+func tokenError(w http.ResponseWriter, status int, errorCode string) {
+	writeJSON(w, status, map[string]string{"error": errorCode})
+}
+
+// containsScope reports whether a space-separated scope list contains scope
+func containsScope(scopes, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+// Command mock-ldap is an in-memory LDAP directory for trying directory logins locally. It
+// serves the entries of an LDIF file, or a sample directory, over plain LDAP. Binds are
+// checked against the userPassword of the entries. Do not expose it: the passwords are
+// stored in the clear.
+//
+// Start it and point the API at it:
+//
+//	go run ./cmd/mock-ldap -addr :3389
+//	LDAP_URL=ldap://localhost:3389 LDAP_BASE_DN=ou=people,dc=example,dc=edu \
+//	LDAP_BIND_DN=cn=unisphere,ou=services,dc=example,dc=edu LDAP_BIND_PASSWORD=unisphere \
+//	LDAP_ATTRIBUTE_DEPARTMENT=departmentNumber go run ./cmd/api
+//
+// The sample users log in with the password "password". With -ldif, sending SIGHUP reloads
+// the file, so removing an entry and running the sync deactivates its user.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/yigit/unisphere/internal/pkg/ldap/ldaptest"
+)
+
+// sampleLDIF is the directory served without -ldif
+const sampleLDIF = `dn: dc=example,dc=edu
+objectClass: domain
+dc: example
+
+dn: ou=services,dc=example,dc=edu
+objectClass: organizationalUnit
+ou: services
+
+dn: cn=unisphere,ou=services,dc=example,dc=edu
+objectClass: organizationalRole
+cn: unisphere
+userPassword: unisphere
+
+dn: ou=people,dc=example,dc=edu
+objectClass: organizationalUnit
+ou: people
+
+dn: uid=ada,ou=people,dc=example,dc=edu
+objectClass: inetOrgPerson
+objectClass: eduPerson
+uid: ada
+entryUUID: 5b0e7a5c-2c1e-4b8e-9a7e-1f3c9d6a0001
+mail: ada@example.edu
+givenName: Ada
+sn: Lovelace
+departmentNumber: CENG
+eduPersonAffiliation: student
+userPassword: password
+
+dn: uid=alan,ou=people,dc=example,dc=edu
+objectClass: inetOrgPerson
+objectClass: eduPerson
+uid: alan
+entryUUID: 5b0e7a5c-2c1e-4b8e-9a7e-1f3c9d6a0002
+mail: alan@example.edu
+givenName: Alan
+sn: Turing
+departmentNumber: CENG
+eduPersonAffiliation: faculty
+eduPersonAffiliation: employee
+userPassword: password
+`
+
+func main() {
+	addr := flag.String("addr", ":3389", "Address to listen on")
+	ldifPath := flag.String("ldif", "", "LDIF file with the entries to serve; empty serves a sample directory")
+	flag.Parse()
+
+	entries, err := loadEntries(*ldifPath)
+	if err != nil {
+		log.Fatalf("failed to load entries: %v", err)
+	}
+	server := ldaptest.NewServer(entries)
+
+	if *ldifPath != "" {
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		go func() {
+			for range hangup {
+				entries, err := loadEntries(*ldifPath)
+				if err != nil {
+					log.Printf("failed to reload %s: %v", *ldifPath, err)
+					continue
+				}
+				server.SetEntries(entries)
+				log.Printf("reloaded %d entries from %s", len(entries), *ldifPath)
+			}
+		}()
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %v", *addr, err)
+	}
+	log.Printf("mock LDAP directory with %d entries listening on %s", len(entries), *addr)
+	log.Fatal(server.Serve(listener))
+}
+
+// loadEntries reads the entries of an LDIF file, or the sample directory
+func loadEntries(path string) ([]*ldaptest.Entry, error) {
+	if path == "" {
+		return ldaptest.ParseLDIF(strings.NewReader(sampleLDIF))
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ldaptest.ParseLDIF(file)
+}
@@ -2,17 +2,21 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/yigit/unisphere/internal/app/models"
 	"github.com/yigit/unisphere/internal/app/repositories"
 	"github.com/yigit/unisphere/internal/pkg/apperrors"
 )
 
 // AuthorizationService handles authorization checks
 type AuthorizationService struct {
-	userRepo      *repositories.UserRepository
-	classNoteRepo *repositories.ClassNoteRepository
-	pastExamRepo  *repositories.PastExamRepository
+	userRepo           *repositories.UserRepository
+	classNoteRepo      *repositories.ClassNoteRepository
+	pastExamRepo       *repositories.PastExamRepository
+	departmentRepo     *repositories.DepartmentRepository
+	roleAssignmentRepo *repositories.RoleAssignmentRepository
 }
 
 // NewAuthorizationService creates a new AuthorizationService
@@ -20,14 +24,96 @@ func NewAuthorizationService(
 	userRepo *repositories.UserRepository,
 	classNoteRepo *repositories.ClassNoteRepository,
 	pastExamRepo *repositories.PastExamRepository,
+	departmentRepo *repositories.DepartmentRepository,
+	roleAssignmentRepo *repositories.RoleAssignmentRepository,
 ) *AuthorizationService {
 	return &AuthorizationService{
-		userRepo:      userRepo,
-		classNoteRepo: classNoteRepo,
-		pastExamRepo:  pastExamRepo,
+		userRepo:           userRepo,
+		classNoteRepo:      classNoteRepo,
+		pastExamRepo:       pastExamRepo,
+		departmentRepo:     departmentRepo,
+		roleAssignmentRepo: roleAssignmentRepo,
 	}
 }
 
+// HasPermission checks if a user has a permission in a scope, through the role of their
+// account or a role assignment. A nil scope is satisfied by the permission in any scope.
+// Roles are read from the database, so changes apply without new tokens.
+func (s *AuthorizationService) HasPermission(ctx context.Context, userID int64, permission Permission, scope *Scope) (bool, error) {
+	grants, err := s.roleAssignmentRepo.ListGrants(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, grant := range grants {
+		definition, ok := LookupRole(grant.Role)
+		if !ok || !definition.Allows(permission) {
+			continue
+		}
+
+		covers, err := s.covers(ctx, grant, scope)
+		if err != nil {
+			return false, err
+		}
+		if covers {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RequirePermission is HasPermission returning a forbidden error with message when the user
+// lacks the permission
+func (s *AuthorizationService) RequirePermission(ctx context.Context, userID int64, permission Permission, scope *Scope, message string) error {
+	allowed, err := s.HasPermission(ctx, userID, permission, scope)
+	if err != nil {
+		return fmt.Errorf("error checking permission: %w", err)
+	}
+	if !allowed {
+		return apperrors.NewForbiddenError(message)
+	}
+	return nil
+}
+
+// covers checks if a grant applies in a scope. Global grants apply everywhere and faculty
+// grants also apply to the departments of the faculty.
+func (s *AuthorizationService) covers(ctx context.Context, grant *models.RoleAssignment, scope *Scope) (bool, error) {
+	if scope == nil || grant.ScopeType == models.ScopeGlobal {
+		return true, nil
+	}
+	if grant.ScopeID == nil {
+		return false, nil
+	}
+	if grant.ScopeType == scope.Type && *grant.ScopeID == scope.ID {
+		return true, nil
+	}
+
+	if grant.ScopeType == models.ScopeFaculty && scope.Type == models.ScopeDepartment {
+		department, err := s.departmentRepo.GetByID(ctx, scope.ID)
+		if err != nil {
+			if errors.Is(err, apperrors.ErrDepartmentNotFound) {
+				return false, nil
+			}
+			return false, fmt.Errorf("error getting department: %w", err)
+		}
+		return department.FacultyID == *grant.ScopeID, nil
+	}
+	return false, nil
+}
+
+// PastExamScope returns the department scope of a past exam, which permissions on the exam
+// are checked in
+func (s *AuthorizationService) PastExamScope(ctx context.Context, examID int64) (*Scope, error) {
+	exam, err := s.pastExamRepo.GetByID(ctx, examID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting past exam: %w", err)
+	}
+	if exam == nil {
+		return nil, apperrors.ErrPastExamNotFound
+	}
+	return &Scope{Type: models.ScopeDepartment, ID: exam.DepartmentID}, nil
+}
+
 // ValidateClassNoteOwnership checks if a user has ownership of a class note
 func (s *AuthorizationService) ValidateClassNoteOwnership(ctx context.Context, noteID, userID int64) error {
 	// Get the class note
@@ -0,0 +1,96 @@
+package auth
+
+import "github.com/yigit/unisphere/internal/app/models"
+
+// Permission is an action a role allows, checked by routes and services instead of roles
+type Permission string
+
+// Permissions
+const (
+	PermissionFacultyWrite      Permission = "faculty:write"      // Create, update and delete faculties
+	PermissionDepartmentWrite   Permission = "department:write"   // Create, update and delete departments
+	PermissionPastExamWrite     Permission = "pastexam:write"     // Publish past exams and edit one's own
+	PermissionPastExamManage    Permission = "pastexam:manage"    // Edit and delete past exams of others
+	PermissionCommunityModerate Permission = "community:moderate" // Edit and delete communities one does not lead
+	PermissionUserManage        Permission = "user:manage"        // List, delete, unlock and verify users
+	PermissionRoleManage        Permission = "role:manage"        // Assign and remove roles
+	PermissionFileModerate      Permission = "file:moderate"      // Review duplicate and quarantined files
+)
+
+// Scope limits a permission to a faculty, department or community. A nil *Scope in a check
+// means any scope will do.
+type Scope struct {
+	Type string
+	ID   int64
+}
+
+// GlobalScope is the scope of permissions that are not limited to anything
+var GlobalScope = Scope{Type: models.ScopeGlobal}
+
+// RoleDefinition describes what a role allows and the scopes it can be assigned in
+type RoleDefinition struct {
+	Role        models.RoleType
+	Permissions []Permission
+	Scopes      []string // Scopes the role can be assigned in; none if it cannot be assigned
+}
+
+// Allows reports whether the role has a permission
+func (d RoleDefinition) Allows(permission Permission) bool {
+	for _, p := range d.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// AssignableIn reports whether the role can be assigned in a scope type
+func (d RoleDefinition) AssignableIn(scopeType string) bool {
+	for _, s := range d.Scopes {
+		if s == scopeType {
+			return true
+		}
+	}
+	return false
+}
+
+// roleDefinitions are the roles known to the permission system. The role of an account
+// applies globally; roles can also be assigned to users within a scope.
+var roleDefinitions = []RoleDefinition{
+	{
+		Role: models.RoleAdmin,
+		Permissions: []Permission{
+			PermissionFacultyWrite, PermissionDepartmentWrite, PermissionPastExamWrite,
+			PermissionPastExamManage, PermissionCommunityModerate, PermissionUserManage,
+			PermissionRoleManage, PermissionFileModerate,
+		},
+		Scopes: []string{models.ScopeGlobal},
+	},
+	{
+		Role:        models.RoleInstructor,
+		Permissions: []Permission{PermissionFacultyWrite, PermissionDepartmentWrite, PermissionPastExamWrite},
+		Scopes:      []string{models.ScopeGlobal, models.ScopeFaculty, models.ScopeDepartment},
+	},
+	{
+		Role:        models.RoleModerator,
+		Permissions: []Permission{PermissionPastExamWrite, PermissionPastExamManage, PermissionCommunityModerate},
+		Scopes:      []string{models.ScopeGlobal, models.ScopeFaculty, models.ScopeDepartment, models.ScopeCommunity},
+	},
+	{Role: models.RoleStudent},
+	{Role: models.RolePendingInstructor},
+}
+
+// RoleDefinitions returns every role known to the permission system
+func RoleDefinitions() []RoleDefinition {
+	return roleDefinitions
+}
+
+// LookupRole returns the definition of a role
+func LookupRole(role models.RoleType) (RoleDefinition, bool) {
+	for _, d := range roleDefinitions {
+		if d.Role == role {
+			return d, true
+		}
+	}
+	return RoleDefinition{}, false
+}
@@ -0,0 +1,34 @@
+package auth
+
+// TokenScope limits what a personal access token can do. Tokens act as their owner, so the
+// owner's permissions apply on top of the scopes.
+type TokenScope string
+
+// Token scopes
+const (
+	TokenScopeContentRead  TokenScope = "content:read"  // Read faculties, departments, past exams, class notes, communities and files
+	TokenScopeContentWrite TokenScope = "content:write" // Create, change and delete that content, upload files and join communities
+	TokenScopeChatRead     TokenScope = "chat:read"     // Read community chat messages
+	TokenScopeChatSend     TokenScope = "chat:send"     // Send and delete community chat messages, and use the chat WebSocket
+	TokenScopeProfileRead  TokenScope = "profile:read"  // Read the owner's profile and other users
+)
+
+// tokenScopes are the scopes personal access tokens can be created with
+var tokenScopes = []TokenScope{
+	TokenScopeContentRead, TokenScopeContentWrite, TokenScopeChatRead, TokenScopeChatSend, TokenScopeProfileRead,
+}
+
+// TokenScopes returns every token scope
+func TokenScopes() []TokenScope {
+	return tokenScopes
+}
+
+// IsTokenScope reports whether a string is a known token scope
+func IsTokenScope(scope string) bool {
+	for _, s := range tokenScopes {
+		if string(s) == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
+)
+
+// AccountController handles changes to the authenticated user's password and email address
+type AccountController struct {
+	authService services.AuthService
+}
+
+// NewAccountController creates a new AccountController
+func NewAccountController(authService services.AuthService) *AccountController {
+	return &AccountController{
+		authService: authService,
+	}
+}
+
+// ChangePassword changes the user's password
+// @Summary Change password
+// @Description Changes the current user's password, given the current one. Every other session is signed out, personal access tokens are revoked and all access tokens issued so far stop working, including the one the request was made with: the session continues with the token pair in the response, which is missing for access tokens issued before sessions existed. Wrong current passwords count as failed logins. Accounts linked to the LDAP directory change their password there.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} dto.APIResponse{data=dto.ChangePasswordResponse} "Password changed"
+// @Failure 400 {object} dto.ErrorResponse "Wrong current password, weak new password or directory account"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 429 {object} dto.ErrorResponse "Too many failed attempts or account locked; see Retry-After"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/password [put]
+func (c *AccountController) ChangePassword(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+	sessionID, _ := ctx.Get("sessionID")
+	currentSessionID, _ := sessionID.(int64)
+
+	var req dto.ChangePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(dto.HandleValidationError(err)))
+		return
+	}
+
+	response, err := c.authService.ChangePassword(ctx, userID.(int64), currentSessionID, &req, clientInfo(ctx))
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(response))
+}
+
+// RequestEmailChange starts changing the user's email address
+// @Summary Change email address
+// @Description Sends a confirmation link to a new email address for the current user, given their password. The account keeps its current address until the link is followed; the old address is then sent a link undoing the change. Students have to use an address recognized as a student address. Accounts linked to the LDAP directory change their address there.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.ChangeEmailRequest true "New email address and current password"
+// @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse} "Confirmation email sent"
+// @Failure 400 {object} dto.ErrorResponse "Invalid address, wrong current password or directory account"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 409 {object} dto.ErrorResponse "Email already in use"
+// @Failure 429 {object} dto.ErrorResponse "Too many failed attempts, confirmation emails or account locked; see Retry-After"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/email [post]
+func (c *AccountController) RequestEmailChange(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	var req dto.ChangeEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(dto.HandleValidationError(err)))
+		return
+	}
+
+	if err := c.authService.RequestEmailChange(ctx, userID.(int64), &req, clientInfo(ctx)); err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(
+		dto.SuccessResponse{Message: "A confirmation link has been sent to the new email address"}))
+}
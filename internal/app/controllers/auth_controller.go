@@ -4,6 +4,7 @@ package controllers
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
@@ -34,7 +35,7 @@ func NewAuthController(authService services.AuthService, userRepo repositories.I
 
 // Register handles user registration
 // @Summary Register a new user
-// @Description Creates a new user account with the provided information. User role is determined automatically based on email pattern (emails starting with 's' followed by digits are assigned STUDENT role, others are assigned INSTRUCTOR role). Registration requires email verification.
+// @Description Creates a new user account with the provided information. User role is determined by the configured registration strategy: students get the STUDENT role, everyone else PENDING_INSTRUCTOR (with pendingApproval set in the response) and the rights of a student until an admin approves them as an instructor. Registration requires email verification.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -103,15 +104,16 @@ func (c *AuthController) Register(ctx *gin.Context) {
 
 // Login handles user login
 // @Summary User login
-// @Description Authenticates a user and returns an access token
+// @Description Authenticates a user and returns an access token. When the user has two-factor authentication enabled, mfaRequired is true and, instead of tokens, an mfaToken is returned to exchange together with a code at /auth/login/mfa. mfaEnrollmentRequired is true for instructors and admins who have not set up two-factor authentication yet.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body dto.LoginRequest true "Login credentials"
-// @Success 200 {object} dto.APIResponse{data=dto.TokenResponse} "Login successful"
+// @Success 200 {object} dto.APIResponse{data=dto.LoginResponse} "Login successful or second factor required"
 // @Failure 400 {object} dto.ErrorResponse "Invalid request format or validation error"
 // @Failure 401 {object} dto.ErrorResponse "Invalid credentials"
 // @Failure 403 {object} dto.ErrorResponse "Account disabled"
+// @Failure 429 {object} dto.ErrorResponse "Too many failed logins or account locked; see Retry-After"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /auth/login [post]
 func (c *AuthController) Login(ctx *gin.Context) {
@@ -124,7 +126,7 @@ func (c *AuthController) Login(ctx *gin.Context) {
 	}
 
 	// Process login
-	tokenResponse, err := c.authService.Login(ctx.Request.Context(), &req)
+	loginResponse, err := c.authService.Login(ctx.Request.Context(), &req, clientInfo(ctx))
 	if err != nil {
 		c.logger.Warn().Err(err).Str("email", req.Email).Msg("Login failed")
 		middleware.HandleAPIError(ctx, err)
@@ -132,9 +134,55 @@ func (c *AuthController) Login(ctx *gin.Context) {
 	}
 
 	// Log successful login
-	c.logger.Info().
-		Str("email", req.Email).
-		Msg("User logged in successfully")
+	if loginResponse.MFARequired {
+		c.logger.Info().
+			Str("email", req.Email).
+			Msg("Password accepted, awaiting second factor")
+	} else {
+		c.logger.Info().
+			Str("email", req.Email).
+			Msg("User logged in successfully")
+	}
+
+	// Return login response
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Data: loginResponse,
+	})
+}
+
+// VerifyMFALogin completes a login that requires a second factor
+// @Summary Complete login with a second factor
+// @Description Exchanges the mfaToken returned by /auth/login and a code from the authenticator app, or an unused recovery code, for an access token. After 5 wrong codes the login has to start over.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.MFALoginRequest true "MFA challenge token and code"
+// @Success 200 {object} dto.APIResponse{data=dto.TokenResponse} "Login successful"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request format or validation error"
+// @Failure 401 {object} dto.ErrorResponse "Invalid, expired or revoked MFA token, or invalid code"
+// @Failure 403 {object} dto.ErrorResponse "Account disabled"
+// @Failure 429 {object} dto.ErrorResponse "Too many wrong codes or account locked; see Retry-After"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /auth/login/mfa [post]
+func (c *AuthController) VerifyMFALogin(ctx *gin.Context) {
+	var req dto.MFALoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("Invalid MFA login request payload")
+		errorDetail := dto.HandleValidationError(err)
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(errorDetail))
+		return
+	}
+
+	// Process second factor
+	tokenResponse, err := c.authService.VerifyMFALogin(ctx.Request.Context(), &req, clientInfo(ctx))
+	if err != nil {
+		c.logger.Warn().Err(err).Msg("MFA login failed")
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	// Log successful login
+	c.logger.Info().Msg("User logged in successfully with second factor")
 
 	// Return token response
 	ctx.JSON(http.StatusOK, dto.APIResponse{
@@ -144,14 +192,14 @@ func (c *AuthController) Login(ctx *gin.Context) {
 
 // RefreshToken handles refresh token request
 // @Summary Refresh access token
-// @Description Creates a new access token using a valid refresh token
+// @Description Creates a new access token using a valid refresh token. The refresh token is rotated: the response contains a new one, and presenting a used refresh token again signs out the whole session.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body dto.RefreshTokenRequest true "Refresh token"
 // @Success 200 {object} dto.APIResponse{data=dto.TokenResponse} "Token refreshed successfully"
 // @Failure 400 {object} dto.ErrorResponse "Invalid request format or validation error"
-// @Failure 401 {object} dto.ErrorResponse "Invalid refresh token"
+// @Failure 401 {object} dto.ErrorResponse "Invalid, expired or revoked refresh token"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /auth/refresh-token [post]
 func (c *AuthController) RefreshToken(ctx *gin.Context) {
@@ -164,7 +212,7 @@ func (c *AuthController) RefreshToken(ctx *gin.Context) {
 	}
 
 	// Process refresh token
-	tokenResponse, err := c.authService.RefreshToken(ctx.Request.Context(), req.RefreshToken)
+	tokenResponse, err := c.authService.RefreshToken(ctx.Request.Context(), req.RefreshToken, clientInfo(ctx))
 	if err != nil {
 		c.logger.Warn().Err(err).Msg("Refresh token failed")
 		middleware.HandleAPIError(ctx, err)
@@ -180,6 +228,54 @@ func (c *AuthController) RefreshToken(ctx *gin.Context) {
 	})
 }
 
+// Logout handles user logout
+// @Summary Log out
+// @Description Signs out the session the access token belongs to and revokes the access token right away, instead of when it expires.
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse} "Logged out"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /auth/logout [post]
+func (c *AuthController) Logout(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+	sessionID, _ := ctx.Get("sessionID")
+	tokenID, _ := ctx.Get("tokenID")
+	tokenExpiresAt, _ := ctx.Get("tokenExpiresAt")
+
+	sid, _ := sessionID.(int64)
+	jti, _ := tokenID.(string)
+	expiresAt, _ := tokenExpiresAt.(time.Time)
+
+	if err := c.authService.Logout(ctx.Request.Context(), userID.(int64), sid, jti, expiresAt); err != nil {
+		c.logger.Error().Err(err).Msg("Logout failed")
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Data: dto.SuccessResponse{Message: "Logged out"},
+	})
+}
+
+// GetJWKS returns the public keys access tokens are signed with
+// @Summary JSON Web Key Set
+// @Description Returns the public keys access tokens can be verified with, identified by the kid header of a token. Keys show up here before tokens are signed with them and stay for a grace period after they are replaced. The set is empty when tokens are signed with HS256.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} auth.JWKS "JSON Web Key Set"
+// @Router /.well-known/jwks.json [get]
+func (c *AuthController) GetJWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, c.jwtService.JWKS())
+}
+
 // VerifyEmail handles email verification
 // @Summary Verify email address
 // @Description Verifies a user's email address using the verification token
@@ -192,6 +288,7 @@ func (c *AuthController) RefreshToken(ctx *gin.Context) {
 // @Failure 404 {object} dto.ErrorResponse "Token not found"
 // @Failure 410 {object} dto.ErrorResponse "Token expired"
 // @Failure 409 {object} dto.ErrorResponse "Email already verified"
+// @Failure 429 {object} dto.ErrorResponse "Too many verification emails requested; see Retry-After"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /auth/verify-email [get]
 func (c *AuthController) VerifyEmail(ctx *gin.Context) {
@@ -218,6 +315,70 @@ func (c *AuthController) VerifyEmail(ctx *gin.Context) {
 	})
 }
 
+// ConfirmEmailChange handles the link confirming a new email address
+// @Summary Confirm email change
+// @Description Changes a user's email address to the one the confirmation link was sent to. Every session is signed out, so the user logs in again with the new address. The old address is sent a link undoing the change.
+// @Tags auth
+// @Produce json
+// @Param token query string true "Token from the confirmation email"
+// @Success 200 {object} dto.APIResponse{data=dto.MessageResponse} "Email address changed"
+// @Failure 400 {object} dto.ErrorResponse "Invalid, used or expired token"
+// @Failure 409 {object} dto.ErrorResponse "Email already in use"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /auth/confirm-email-change [get]
+func (c *AuthController) ConfirmEmailChange(ctx *gin.Context) {
+	token := ctx.Query("token")
+	if token == "" {
+		errorDetail := dto.NewErrorDetail(dto.ErrorCodeValidationFailed, "Missing email change token")
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(errorDetail))
+		return
+	}
+
+	if err := c.authService.ConfirmEmailChange(ctx.Request.Context(), token); err != nil {
+		c.logger.Warn().Err(err).Msg("Email change confirmation failed")
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Data: dto.MessageResponse{
+			Message: "Your email address has been changed. Please log in again with your new address.",
+		},
+	})
+}
+
+// UndoEmailChange handles the link undoing an email change, sent to the old address
+// @Summary Undo email change
+// @Description Restores the email address an email change replaced and signs out every session, as whoever made the change may hold one. Pending email changes are cancelled.
+// @Tags auth
+// @Produce json
+// @Param token query string true "Token from the email changed notification"
+// @Success 200 {object} dto.APIResponse{data=dto.MessageResponse} "Email change undone"
+// @Failure 400 {object} dto.ErrorResponse "Invalid, used or expired token"
+// @Failure 409 {object} dto.ErrorResponse "The old address has been taken by another account"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /auth/undo-email-change [get]
+func (c *AuthController) UndoEmailChange(ctx *gin.Context) {
+	token := ctx.Query("token")
+	if token == "" {
+		errorDetail := dto.NewErrorDetail(dto.ErrorCodeValidationFailed, "Missing email change token")
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(errorDetail))
+		return
+	}
+
+	if err := c.authService.UndoEmailChange(ctx.Request.Context(), token); err != nil {
+		c.logger.Warn().Err(err).Msg("Undoing email change failed")
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Data: dto.MessageResponse{
+			Message: "The email change has been undone and every session signed out. If you did not make the change, reset your password now.",
+		},
+	})
+}
+
 // ResendVerificationEmail handles resending verification email
 // @Summary Resend verification email
 // @Description Resends the verification email to a previously registered email address
@@ -240,7 +401,7 @@ func (c *AuthController) ResendVerificationEmail(ctx *gin.Context) {
 		return
 	}
 
-	err := c.authService.ResendVerificationEmail(ctx.Request.Context(), email)
+	err := c.authService.ResendVerificationEmail(ctx.Request.Context(), email, clientInfo(ctx))
 	if err != nil {
 		c.logger.Warn().Err(err).Str("email", email).Msg("Failed to resend verification email")
 		middleware.HandleAPIError(ctx, err)
@@ -265,6 +426,7 @@ func (c *AuthController) ResendVerificationEmail(ctx *gin.Context) {
 // @Success 200 {object} dto.APIResponse{data=dto.MessageResponse} "Password reset code sent"
 // @Failure 400 {object} dto.ErrorResponse "Invalid email format"
 // @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 429 {object} dto.ErrorResponse "Too many password resets requested; see Retry-After"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /auth/forgot-password [post]
 func (c *AuthController) ForgotPassword(ctx *gin.Context) {
@@ -277,7 +439,7 @@ func (c *AuthController) ForgotPassword(ctx *gin.Context) {
 	}
 
 	// Process forgot password request
-	err := c.authService.ForgotPassword(ctx.Request.Context(), req.Email)
+	err := c.authService.ForgotPassword(ctx.Request.Context(), req.Email, clientInfo(ctx))
 	if err != nil {
 		c.logger.Warn().Err(err).Str("email", req.Email).Msg("Forgot password request failed")
 		middleware.HandleAPIError(ctx, err)
@@ -299,7 +461,7 @@ func (c *AuthController) ForgotPassword(ctx *gin.Context) {
 
 // ResetPassword handles password reset
 // @Summary Reset password
-// @Description Resets a user's password using the reset token
+// @Description Resets a user's password using the reset token. Every session is signed out and personal access tokens are revoked.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -342,3 +504,11 @@ func (c *AuthController) ResetPassword(ctx *gin.Context) {
 
 // For backwards compatibility, these methods can be added back if needed
 // but currently they are not exposed via any routes
+
+// clientInfo describes the client making the request, for recording device sessions
+func clientInfo(ctx *gin.Context) *dto.ClientInfo {
+	return &dto.ClientInfo{
+		UserAgent: ctx.Request.UserAgent(),
+		IPAddress: ctx.ClientIP(),
+	}
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/pkg/filestorage"
+	"github.com/yigit/unisphere/internal/pkg/logger"
+)
+
+// streamFileBundle writes the bundle to the response as a ZIP archive.
+// The archive is produced while it is sent, so once streaming has started
+// errors can only be logged; the client sees a truncated download.
+func streamFileBundle(ctx *gin.Context, storage filestorage.FileStorage, bundle *dto.FileBundle) {
+	entries := make([]filestorage.ZipEntry, len(bundle.Entries))
+	for i, entry := range bundle.Entries {
+		entries[i] = filestorage.ZipEntry{
+			Name:     entry.ArchivePath,
+			FilePath: entry.FilePath,
+			Modified: entry.ModifiedAt,
+		}
+	}
+
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": bundle.FileName}))
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Status(http.StatusOK)
+
+	if err := filestorage.WriteZip(ctx.Writer, storage, entries); err != nil {
+		logger.Error().Err(err).Str("bundle", bundle.FileName).Msg("Failed to stream ZIP bundle")
+		_ = ctx.Error(err)
+	}
+}
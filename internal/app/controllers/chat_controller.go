@@ -3,6 +3,7 @@ package controllers
 import (
 	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 	"strings"
@@ -18,13 +19,15 @@ import (
 
 // ChatController handles chat message operations
 type ChatController struct {
-	chatService services.ChatService
+	chatService   services.ChatService
+	uploadService services.UploadService
 }
 
 // NewChatController creates a new ChatController
-func NewChatController(chatService services.ChatService) *ChatController {
+func NewChatController(chatService services.ChatService, uploadService services.UploadService) *ChatController {
 	return &ChatController{
-		chatService: chatService,
+		chatService:   chatService,
+		uploadService: uploadService,
 	}
 }
 
@@ -36,11 +39,13 @@ func NewChatController(chatService services.ChatService) *ChatController {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Community ID"
-// @Param before query string false "Get messages before this timestamp (RFC3339 format)"
-// @Param after query string false "Get messages after this timestamp (RFC3339 format)"
+// @Param before query string false "Get messages before this timestamp (RFC3339 format), or a cursor token from X-Prev-Cursor to get newer messages"
+// @Param after query string false "Get messages after this timestamp (RFC3339 format), or a cursor token from X-Next-Cursor to get older messages"
 // @Param limit query int false "Maximum number of messages to retrieve (default: 50)" default(50)
 // @Param senderId query int false "Filter messages by sender ID"
 // @Success 200 {object} dto.APIResponse{data=[]dto.ChatMessageResponse}
+// @Header 200 {string} X-Next-Cursor "Cursor token for older messages, pass as after"
+// @Header 200 {string} X-Prev-Cursor "Cursor token for newer messages, pass as before"
 // @Failure 400 {object} dto.APIResponse{error=dto.ErrorDetail}
 // @Failure 401 {object} dto.APIResponse{error=dto.ErrorDetail} "Unauthorized: JWT token missing or invalid"
 // @Failure 403 {object} dto.APIResponse{error=dto.ErrorDetail} "Forbidden: User is not a participant in the community"
@@ -72,19 +77,23 @@ func (c *ChatController) GetChatMessages(ctx *gin.Context) {
 		}
 	}
 
-	// Parse before time
+	// Parse before time; anything that is not a timestamp is treated as a cursor token
 	if beforeStr := ctx.Query("before"); beforeStr != "" {
 		beforeTime, err := time.Parse(time.RFC3339, beforeStr)
 		if err == nil {
 			filter.Before = &beforeTime
+		} else {
+			filter.BeforeCursor = beforeStr
 		}
 	}
 
-	// Parse after time
+	// Parse after time; anything that is not a timestamp is treated as a cursor token
 	if afterStr := ctx.Query("after"); afterStr != "" {
 		afterTime, err := time.Parse(time.RFC3339, afterStr)
 		if err == nil {
 			filter.After = &afterTime
+		} else {
+			filter.AfterCursor = afterStr
 		}
 	}
 
@@ -97,12 +106,14 @@ func (c *ChatController) GetChatMessages(ctx *gin.Context) {
 	}
 
 	// Get messages from service
-	messages, err := c.chatService.GetChatMessages(ctx, communityID, filter)
+	messages, cursorInfo, err := c.chatService.GetChatMessages(ctx, communityID, filter)
 	if err != nil {
 		fmt.Printf("Error getting chat messages: %v\n", err)
 
 		// Handle specific error cases
 		switch {
+		case errors.Is(err, apperrors.ErrBadRequest):
+			middleware.HandleAPIError(ctx, err)
 		case errors.Is(err, apperrors.ErrResourceNotFound) || strings.Contains(err.Error(), "not found"):
 			ctx.JSON(http.StatusNotFound, dto.NewErrorResponse(
 				dto.NewErrorDetail(dto.ErrorCodeResourceNotFound, "Community not found")))
@@ -116,6 +127,14 @@ func (c *ChatController) GetChatMessages(ctx *gin.Context) {
 		return
 	}
 
+	// The body stays a plain message array; cursors travel in headers
+	if cursorInfo.NextCursor != "" {
+		ctx.Header("X-Next-Cursor", cursorInfo.NextCursor)
+	}
+	if cursorInfo.PrevCursor != "" {
+		ctx.Header("X-Prev-Cursor", cursorInfo.PrevCursor)
+	}
+
 	fmt.Println("********* GetChatMessages SUCCESSFUL *********")
 	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(messages))
 }
@@ -232,7 +251,8 @@ func (c *ChatController) SendTextMessage(ctx *gin.Context) {
 // @Security BearerAuth
 // @Param id path int true "Community ID"
 // @Param content formData string false "Optional message content to accompany the file"
-// @Param file formData file true "File to upload (PDF or image)"
+// @Param file formData file false "File to upload (PDF or image); required unless uploadId is given"
+// @Param uploadId formData string false "ID of a finished resumable upload to send instead of file"
 // @Success 201 {object} dto.APIResponse{data=dto.ChatMessageResponse}
 // @Failure 400 {object} dto.APIResponse{error=dto.ErrorDetail}
 // @Failure 401 {object} dto.APIResponse{error=dto.ErrorDetail} "Unauthorized: JWT token missing or invalid"
@@ -252,9 +272,18 @@ func (c *ChatController) SendFileMessage(ctx *gin.Context) {
 		return
 	}
 
-	// Get file from form
-	file, err := ctx.FormFile("file")
+	// Get file from form, or from a finished resumable upload
+	uploads, err := openFormUploads(ctx, c.uploadService, "uploadId")
 	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+	defer uploads.Close()
+
+	var file *multipart.FileHeader
+	if len(uploads.Files()) > 0 {
+		file = uploads.Files()[0]
+	} else if file, err = ctx.FormFile("file"); err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
 			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "No file provided").WithDetails(err.Error())))
 		return
@@ -276,6 +305,7 @@ func (c *ChatController) SendFileMessage(ctx *gin.Context) {
 		middleware.HandleAPIError(ctx, err)
 		return
 	}
+	uploads.Attached(ctx, file)
 
 	fmt.Println("********* SendFileMessage SUCCESSFUL *********")
 	ctx.JSON(http.StatusCreated, dto.NewSuccessResponse(message))
@@ -11,6 +11,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/yigit/unisphere/internal/app/models/dto"
 	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
 	"github.com/yigit/unisphere/internal/pkg/apperrors"
 	"github.com/yigit/unisphere/internal/pkg/filestorage"
 )
@@ -28,13 +29,15 @@ func parseIDParam(ctx *gin.Context, paramName string) (int64, error) {
 // ClassNoteController handles class note operations
 type ClassNoteController struct {
 	classNoteService services.ClassNoteService
-	fileStorage      *filestorage.LocalStorage
+	uploadService    services.UploadService
+	fileStorage      filestorage.FileStorage
 }
 
 // NewClassNoteController creates a new ClassNoteController
-func NewClassNoteController(classNoteService services.ClassNoteService, fileStorage *filestorage.LocalStorage) *ClassNoteController {
+func NewClassNoteController(classNoteService services.ClassNoteService, uploadService services.UploadService, fileStorage filestorage.FileStorage) *ClassNoteController {
 	return &ClassNoteController{
 		classNoteService: classNoteService,
+		uploadService:    uploadService,
 		fileStorage:      fileStorage,
 	}
 }
@@ -53,6 +56,11 @@ func NewClassNoteController(classNoteService services.ClassNoteService, fileStor
 // @Param pageSize query int false "Page size (default: 10, max: 100)" default(10) minimum(1) maximum(100)
 // @Param sortBy query string false "Sort by field (created_at, updated_at, title, course_code)" Enums(created_at, updated_at, title, course_code) default(created_at)
 // @Param sortOrder query string false "Sort direction (asc, desc)" Enums(asc, desc) default(desc)
+// @Param after query string false "Cursor token (cursor.nextCursor) to get the page after it; page is ignored"
+// @Param before query string false "Cursor token (cursor.prevCursor) to get the page before it; page is ignored"
+// @Param fields query string false "Comma separated class note fields to return (e.g. id,title,courseCode); id is always included"
+// @Param expand query string false "Comma separated relations to include (files, department); defaults to files"
+// @Param includeHtml query bool false "Return contentHtml, the content rendered to sanitized HTML"
 // @Success 200 {object} dto.APIResponse{data=dto.ClassNoteListResponse}
 // @Failure 400 {object} dto.APIResponse{error=dto.ErrorDetail}
 // @Failure 401 {object} dto.APIResponse{error=dto.ErrorDetail} "Unauthorized: JWT token missing or invalid"
@@ -68,15 +76,20 @@ func (c *ClassNoteController) GetAllNotes(ctx *gin.Context) {
 		return
 	}
 
+	projection, ok := bindProjection(ctx, dto.ClassNoteResponse{}, dto.ClassNoteRelations)
+	if !ok {
+		return
+	}
+	filter.Projection = projection
+
 	// Get notes with pagination
 	notes, err := c.classNoteService.GetAllNotes(ctx, &filter)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, dto.NewErrorResponse(
-			dto.NewErrorDetail(dto.ErrorCodeInternalServer, "Failed to get class notes")))
+		middleware.HandleAPIError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(notes))
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(projection.ApplyList(notes, "classNotes")))
 }
 
 // GetNoteByID godoc
@@ -87,6 +100,7 @@ func (c *ClassNoteController) GetAllNotes(ctx *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Class note ID"
+// @Param includeHtml query bool false "Return contentHtml, the content rendered to sanitized HTML"
 // @Success 200 {object} dto.APIResponse{data=dto.ClassNoteResponse}
 // @Failure 400 {object} dto.APIResponse{error=dto.ErrorDetail}
 // @Failure 401 {object} dto.APIResponse{error=dto.ErrorDetail} "Unauthorized: JWT token missing or invalid"
@@ -103,7 +117,8 @@ func (c *ClassNoteController) GetNoteByID(ctx *gin.Context) {
 	}
 
 	// Get note
-	note, err := c.classNoteService.GetNoteByID(ctx, id)
+	includeHTML, _ := strconv.ParseBool(ctx.Query("includeHtml"))
+	note, err := c.classNoteService.GetNoteByID(ctx, id, includeHTML)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, dto.NewErrorResponse(
 			dto.NewErrorDetail(dto.ErrorCodeInternalServer, "Failed to get class note")))
@@ -187,6 +202,10 @@ func (c *ClassNoteController) CreateNote(ctx *gin.Context) {
 	note, err := c.classNoteService.CreateNote(ctx, &req, files)
 	if err != nil {
 		fmt.Printf("Service hatası: %v\n", err)
+		if errors.Is(err, apperrors.ErrValidationFailed) {
+			middleware.HandleAPIError(ctx, err)
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, dto.NewErrorResponse(
 			dto.NewErrorDetail(dto.ErrorCodeInternalServer, "Failed to create class note").WithDetails(err.Error())))
 		return
@@ -320,7 +339,8 @@ func (c *ClassNoteController) DeleteNote(ctx *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Class note ID"
-// @Param files formData file true "Files to upload" collectionFormat multi
+// @Param files formData file false "Files to upload" collectionFormat multi
+// @Param uploadIds formData []string false "IDs of finished resumable uploads to add" collectionFormat(multi)
 // @Success 200 {object} dto.APIResponse{data=dto.ClassNoteResponse}
 // @Failure 400 {object} dto.APIResponse{error=dto.ErrorDetail}
 // @Failure 401 {object} dto.APIResponse{error=dto.ErrorDetail} "Unauthorized: JWT token missing or invalid"
@@ -336,15 +356,20 @@ func (c *ClassNoteController) AddFilesToNote(ctx *gin.Context) {
 		return
 	}
 
-	// Get files
-	form, err := ctx.MultipartForm()
+	// Get files; finished resumable uploads may be sent instead of or next to them
+	var files []*multipart.FileHeader
+	if form, err := ctx.MultipartForm(); err == nil {
+		files = form.File["files"]
+	}
+
+	uploads, err := openFormUploads(ctx, c.uploadService, "uploadIds")
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
-			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Invalid form data")))
+		middleware.HandleAPIError(ctx, err)
 		return
 	}
+	defer uploads.Close()
+	files = append(files, uploads.Files()...)
 
-	files := form.File["files"]
 	if len(files) == 0 {
 		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
 			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "No files uploaded")))
@@ -354,11 +379,19 @@ func (c *ClassNoteController) AddFilesToNote(ctx *gin.Context) {
 	// Add files to note
 	updatedNote, err := c.classNoteService.AddFilesToNote(ctx, id, files)
 	if err != nil {
+		if errors.Is(err, apperrors.ErrValidationFailed) {
+			middleware.HandleAPIError(ctx, err)
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, dto.NewErrorResponse(
 			dto.NewErrorDetail(dto.ErrorCodeInternalServer, "Failed to add files to class note")))
 		return
 	}
 
+	for _, file := range files {
+		uploads.Attached(ctx, file)
+	}
+
 	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(updatedNote))
 }
 
@@ -446,4 +479,34 @@ func (c *ClassNoteController) GetFileDetails(ctx *gin.Context) {
 	}
 
 	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(fileDetails))
-}
\ No newline at end of file
+}
+
+// DownloadNoteBundle godoc
+// @Summary Download all files of a class note as a ZIP
+// @Description Streams a ZIP archive of every file attached to the class note, organised as course code/title.
+// @Tags class-notes
+// @Produce application/zip
+// @Security BearerAuth
+// @Param noteId path int true "Class note ID"
+// @Success 200 {file} binary "ZIP archive"
+// @Failure 400 {object} dto.APIResponse{error=dto.ErrorDetail}
+// @Failure 401 {object} dto.APIResponse{error=dto.ErrorDetail} "Unauthorized: JWT token missing or invalid"
+// @Failure 404 {object} dto.APIResponse{error=dto.ErrorDetail}
+// @Failure 500 {object} dto.APIResponse{error=dto.ErrorDetail}
+// @Router /class-notes/{noteId}/bundle [get]
+func (c *ClassNoteController) DownloadNoteBundle(ctx *gin.Context) {
+	id, err := parseIDParam(ctx, "noteId")
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Invalid class note ID")))
+		return
+	}
+
+	bundle, err := c.classNoteService.GetNoteBundle(ctx, id)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	streamFileBundle(ctx, c.fileStorage, bundle)
+}
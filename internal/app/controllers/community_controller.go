@@ -21,11 +21,11 @@ import (
 // CommunityController handles community related operations
 type CommunityController struct {
 	communityService services.CommunityService
-	fileStorage      *filestorage.LocalStorage
+	fileStorage      filestorage.FileStorage
 }
 
 // NewCommunityController creates a new CommunityController
-func NewCommunityController(communityService services.CommunityService, fileStorage *filestorage.LocalStorage) *CommunityController {
+func NewCommunityController(communityService services.CommunityService, fileStorage filestorage.FileStorage) *CommunityController {
 	return &CommunityController{
 		communityService: communityService,
 		fileStorage:      fileStorage,
@@ -43,6 +43,9 @@ func NewCommunityController(communityService services.CommunityService, fileStor
 // @Param search query string false "Search by name or abbreviation"
 // @Param page query int false "Page number (1-based)" default(1) minimum(1)
 // @Param pageSize query int false "Page size (default: 10, max: 100)" default(10) minimum(1) maximum(100)
+// @Param after query string false "Cursor token (cursor.nextCursor) to get the page after it; page is ignored"
+// @Param before query string false "Cursor token (cursor.prevCursor) to get the page before it; page is ignored"
+// @Param fields query string false "Comma separated community fields to return (e.g. id,name,abbreviation); id is always included"
 // @Success 200 {object} dto.APIResponse{data=dto.CommunityListResponse} "Communities retrieved successfully"
 // @Failure 400 {object} dto.ErrorResponse "Invalid request parameters"
 // @Failure 401 {object} dto.ErrorResponse "Unauthorized: JWT token missing or invalid"
@@ -80,10 +83,24 @@ func (c *CommunityController) GetAllCommunities(ctx *gin.Context) {
 	if search, ok := filters["search"].(string); ok {
 		filter.Search = &search
 	}
+	filter.After = ctx.Query("after")
+	filter.Before = ctx.Query("before")
+
+	projection, ok := bindProjection(ctx, dto.CommunityResponse{}, nil)
+	if !ok {
+		return
+	}
+	filter.Projection = projection
 
 	// Try to get the communities, but have a fallback
 	response, err := c.communityService.GetAllCommunities(ctx, filter)
 
+	// A malformed cursor is the client's mistake, not a reason to fall back
+	if errors.Is(err, apperrors.ErrBadRequest) {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
 	// If there's an error, return an empty list instead of an error
 	if err != nil {
 		fmt.Printf("Error in GetAllCommunities: %v, returning empty list\n", err)
@@ -98,7 +115,7 @@ func (c *CommunityController) GetAllCommunities(ctx *gin.Context) {
 		}
 	}
 
-	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(response))
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(projection.ApplyList(response, "communities")))
 }
 
 // GetCommunityByID handles retrieving a specific community by ID
@@ -190,7 +207,7 @@ func (c *CommunityController) CreateCommunity(ctx *gin.Context) {
 
 // UpdateCommunity handles updating an existing community
 // @Summary Update a community
-// @Description Updates an existing community with the provided information
+// @Description Updates an existing community with the provided information. Only the community lead or a user with community:moderate on it can update it.
 // @Tags communities
 // @Accept json
 // @Produce json
@@ -200,6 +217,7 @@ func (c *CommunityController) CreateCommunity(ctx *gin.Context) {
 // @Success 200 {object} dto.APIResponse{data=dto.CommunityResponse} "Community updated successfully"
 // @Failure 400 {object} dto.ErrorResponse "Invalid request parameters"
 // @Failure 401 {object} dto.ErrorResponse "Unauthorized: JWT token missing or invalid"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden: User is neither the lead nor a moderator of the community"
 // @Failure 404 {object} dto.ErrorResponse "Community not found"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /communities/{id} [put]
@@ -243,7 +261,7 @@ func (c *CommunityController) UpdateCommunity(ctx *gin.Context) {
 
 // DeleteCommunity handles deleting a community
 // @Summary Delete a community
-// @Description Deletes an existing community by its ID
+// @Description Deletes an existing community by its ID. Only the community lead or a user with community:moderate on it can delete it.
 // @Tags communities
 // @Accept json
 // @Produce json
@@ -252,6 +270,7 @@ func (c *CommunityController) UpdateCommunity(ctx *gin.Context) {
 // @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse} "Community deleted successfully"
 // @Failure 400 {object} dto.ErrorResponse "Invalid community ID"
 // @Failure 401 {object} dto.ErrorResponse "Unauthorized: JWT token missing or invalid"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden: User is neither the lead nor a moderator of the community"
 // @Failure 404 {object} dto.ErrorResponse "Community not found"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /communities/{id} [delete]
@@ -340,6 +359,8 @@ func (c *CommunityController) UpdateProfilePhoto(ctx *gin.Context) {
 		case errors.Is(err, apperrors.ErrResourceNotFound) || strings.Contains(err.Error(), "not found"):
 			ctx.JSON(http.StatusNotFound, dto.NewErrorResponse(
 				dto.NewErrorDetail(dto.ErrorCodeResourceNotFound, "Community not found")))
+		case errors.Is(err, apperrors.ErrValidationFailed):
+			middleware.HandleAPIError(ctx, err)
 		default:
 			ctx.JSON(http.StatusInternalServerError, dto.NewErrorResponse(
 				dto.NewErrorDetail(dto.ErrorCodeInternalServer, "Failed to update profile photo").WithDetails(err.Error())))
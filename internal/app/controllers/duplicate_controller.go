@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
+)
+
+// DuplicateController handles duplicate content reports
+type DuplicateController struct {
+	duplicateService services.DuplicateService
+}
+
+// NewDuplicateController creates a new DuplicateController
+func NewDuplicateController(duplicateService services.DuplicateService) *DuplicateController {
+	return &DuplicateController{
+		duplicateService: duplicateService,
+	}
+}
+
+// GetDuplicateReport returns clusters of duplicate note and exam files (file:moderate only)
+// @Summary Get duplicate file report
+// @Description Lists clusters of exact (same SHA-256) and near-duplicate (similar text) past exam and class note files so admins can merge them. Requires the file:moderate permission.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (1-based), for both exact and near-duplicate clusters" default(1) minimum(1)
+// @Param pageSize query int false "Clusters per page and match type (default: 20, max: 100)" default(20) minimum(1) maximum(100)
+// @Success 200 {object} dto.APIResponse{data=dto.DuplicateReportResponse} "Duplicate report generated successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid page parameters"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the file:moderate permission"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /admin/duplicates [get]
+func (c *DuplicateController) GetDuplicateReport(ctx *gin.Context) {
+	var req dto.DuplicateReportRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(dto.HandleValidationError(err)))
+		return
+	}
+
+	report, err := c.duplicateService.GetDuplicateReport(ctx, &req)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(report))
+}
@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
+	"github.com/yigit/unisphere/internal/pkg/filestorage"
+	"github.com/yigit/unisphere/internal/pkg/logger"
+)
+
+// inlineContentTypes are the types browsers may show in place. They cannot run scripts in the
+// origin of the API; every other type, such as HTML or SVG, is served as an attachment.
+var inlineContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+	"video/mp4":       true,
+	"audio/mpeg":      true,
+}
+
+// FileController serves stored files after checking access to their owning resource
+type FileController struct {
+	fileService services.FileService
+	fileStorage filestorage.FileStorage
+}
+
+// NewFileController creates a new FileController
+func NewFileController(fileService services.FileService, fileStorage filestorage.FileStorage) *FileController {
+	return &FileController{
+		fileService: fileService,
+		fileStorage: fileStorage,
+	}
+}
+
+// DownloadFile godoc
+// @Summary Download a file
+// @Description Streams a file after checking the permissions of the past exam, class note or community it belongs to. Supports HTTP Range requests.
+// @Tags files
+// @Produce octet-stream
+// @Security BearerAuth
+// @Param fileId path int true "File ID"
+// @Param download query bool false "Send as attachment instead of inline; types that are unsafe to show are always attachments"
+// @Param size query int false "Thumbnail size in pixels (64, 256 or 1024) of an image file"
+// @Param Range header string false "Byte range, e.g. bytes=0-1048575"
+// @Success 200 {file} file "File content"
+// @Success 206 {file} file "Partial file content"
+// @Failure 400 {object} dto.ErrorResponse "Invalid file ID"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized: JWT token missing or invalid"
+// @Failure 403 {object} dto.ErrorResponse "No access to the owning resource"
+// @Failure 404 {object} dto.ErrorResponse "File not found"
+// @Failure 416 {object} dto.ErrorResponse "Range not satisfiable"
+// @Router /files/{fileId}/download [get]
+func (c *FileController) DownloadFile(ctx *gin.Context) {
+	fileID, err := parseIDParam(ctx, "fileId")
+	if err != nil || fileID <= 0 {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Invalid file ID")))
+		return
+	}
+
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	file, err := c.fileService.GetFileForUser(ctx, fileID, userID.(int64))
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	c.serveFile(ctx, file, "private, no-cache")
+}
+
+// CreateSignedURL godoc
+// @Summary Create a signed download URL
+// @Description Issues a short-lived HMAC-signed URL for a file the user may read. The URL works without an Authorization header, e.g. in <img> tags or browser downloads.
+// @Tags files
+// @Produce json
+// @Security BearerAuth
+// @Param fileId path int true "File ID"
+// @Param expiresIn query int false "Lifetime in seconds; capped by the server"
+// @Success 200 {object} dto.APIResponse{data=dto.SignedFileURLResponse} "Signed URL created"
+// @Failure 400 {object} dto.ErrorResponse "Invalid file ID or lifetime"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized: JWT token missing or invalid"
+// @Failure 403 {object} dto.ErrorResponse "No access to the owning resource"
+// @Failure 404 {object} dto.ErrorResponse "File not found"
+// @Router /files/{fileId}/signed-url [post]
+func (c *FileController) CreateSignedURL(ctx *gin.Context) {
+	fileID, err := parseIDParam(ctx, "fileId")
+	if err != nil || fileID <= 0 {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Invalid file ID")))
+		return
+	}
+
+	var req dto.SignedFileURLRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		errorDetail := dto.NewErrorDetail(dto.ErrorCodeValidationFailed, "Invalid expiresIn")
+		errorDetail = errorDetail.WithDetails(err.Error())
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(errorDetail))
+		return
+	}
+
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	signed, err := c.fileService.CreateSignedURL(ctx, fileID, userID.(int64), time.Duration(req.ExpiresIn)*time.Second)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(signed))
+}
+
+// DownloadSignedFile godoc
+// @Summary Download a file through a signed URL
+// @Description Streams a file named by a URL from POST /files/{fileId}/signed-url. No Authorization header is needed. Supports HTTP Range requests.
+// @Tags files
+// @Produce octet-stream
+// @Param fileId path int true "File ID"
+// @Param expires query int true "Expiry as Unix time"
+// @Param signature query string true "URL signature"
+// @Param download query bool false "Send as attachment instead of inline; types that are unsafe to show are always attachments"
+// @Param size query int false "Thumbnail size in pixels (64, 256 or 1024) of an image file"
+// @Success 200 {file} file "File content"
+// @Success 206 {file} file "Partial file content"
+// @Failure 400 {object} dto.ErrorResponse "Invalid file ID"
+// @Failure 401 {object} dto.ErrorResponse "Invalid or expired signature"
+// @Failure 404 {object} dto.ErrorResponse "File not found"
+// @Router /files/{fileId}/content [get]
+func (c *FileController) DownloadSignedFile(ctx *gin.Context) {
+	fileID, err := parseIDParam(ctx, "fileId")
+	if err != nil || fileID <= 0 {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Invalid file ID")))
+		return
+	}
+
+	// A malformed expiry fails signature verification like a tampered one
+	expires, _ := strconv.ParseInt(ctx.Query("expires"), 10, 64)
+
+	file, err := c.fileService.GetSignedFile(ctx, fileID, expires, ctx.Query("signature"))
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	// The URL stops working at expiry, so caches must not outlive it
+	maxAge := expires - time.Now().Unix()
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	c.serveFile(ctx, file, "private, max-age="+strconv.FormatInt(maxAge, 10))
+}
+
+// ServeUpload serves legacy /uploads/* URLs. Only public files (profile photos) are
+// served; everything else must be fetched through the authorized download endpoint.
+func (c *FileController) ServeUpload(ctx *gin.Context) {
+	file, err := c.fileService.GetPublicFileByPath(ctx, ctx.Param("filepath"))
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	c.serveFile(ctx, file, "public, max-age=3600")
+}
+
+// serveFile streams a stored file, or the thumbnail named by the size query parameter.
+// Files are shown in place only when their type is in inlineContentTypes. Seekable storage
+// readers (local files, S3 objects) go through http.ServeContent, which answers Range and
+// conditional requests.
+func (c *FileController) serveFile(ctx *gin.Context, file *models.File, cacheControl string) {
+	if sizeParam := ctx.Query("size"); sizeParam != "" {
+		size, _ := strconv.Atoi(sizeParam)
+		thumbnail := file.Thumbnail(size)
+		if thumbnail == nil {
+			ctx.JSON(http.StatusNotFound, dto.NewErrorResponse(
+				dto.NewErrorDetail(dto.ErrorCodeResourceNotFound, "Thumbnail not found")))
+			return
+		}
+		file = thumbnail
+	}
+
+	reader, err := c.fileStorage.OpenFile(file.FilePath)
+	if err != nil {
+		logger.Error().Err(err).Int64("fileID", file.ID).Str("path", file.FilePath).Msg("Failed to open stored file")
+		ctx.JSON(http.StatusNotFound, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeResourceNotFound, "File content not found")))
+		return
+	}
+	defer reader.Close()
+
+	contentType := file.FileType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := "inline"
+	if download, _ := strconv.ParseBool(ctx.Query("download")); download || !isInlineContentType(contentType) {
+		disposition = "attachment"
+	}
+
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": file.FileName}))
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Header("Cache-Control", cacheControl)
+
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(ctx.Writer, ctx.Request, file.FileName, file.UpdatedAt, seeker)
+		return
+	}
+
+	// Without seeking only whole-file responses are possible
+	ctx.Header("Accept-Ranges", "none")
+	if file.FileSize > 0 {
+		ctx.Header("Content-Length", strconv.FormatInt(file.FileSize, 10))
+	}
+	ctx.Status(http.StatusOK)
+	if strings.EqualFold(ctx.Request.Method, http.MethodHead) {
+		return
+	}
+	if _, err := io.Copy(ctx.Writer, reader); err != nil {
+		logger.Error().Err(err).Int64("fileID", file.ID).Msg("Failed to stream file")
+		_ = ctx.Error(err)
+	}
+}
+
+// isInlineContentType reports whether a stored content type may be shown in place
+func isInlineContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return inlineContentTypes[mediaType]
+}
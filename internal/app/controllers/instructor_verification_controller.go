@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
+)
+
+// InstructorVerificationController handles the admin review queue of accounts waiting to be
+// confirmed as instructors
+type InstructorVerificationController struct {
+	verificationService services.InstructorVerificationService
+}
+
+// NewInstructorVerificationController creates a new InstructorVerificationController
+func NewInstructorVerificationController(verificationService services.InstructorVerificationService) *InstructorVerificationController {
+	return &InstructorVerificationController{
+		verificationService: verificationService,
+	}
+}
+
+// GetVerifications lists instructor verifications (user:manage only)
+// @Summary List instructor verifications
+// @Description Lists accounts that registered as instructors, oldest request first. Pending accounts have the role PENDING_INSTRUCTOR and the rights of a student until they are approved. Requires the user:manage permission.
+// @Tags admin
+// @Produce json
+// @Param status query string false "PENDING (default), APPROVED or REJECTED"
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=[]dto.InstructorVerificationResponse} "Instructor verifications retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid status"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the user:manage permission"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /admin/instructor-verifications [get]
+func (c *InstructorVerificationController) GetVerifications(ctx *gin.Context) {
+	verifications, err := c.verificationService.ListVerifications(ctx, ctx.Query("status"))
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(verifications))
+}
+
+// Approve confirms an account as an instructor (user:manage only)
+// @Summary Approve an instructor
+// @Description Makes a pending account an instructor and emails the user. Their access tokens are revoked, so the new role applies when they refresh. Requires the user:manage permission.
+// @Tags admin
+// @Produce json
+// @Param userId path int true "User ID"
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse} "Instructor approved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the user:manage permission"
+// @Failure 404 {object} dto.ErrorResponse "User not found or not pending verification"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /admin/instructor-verifications/{userId}/approve [post]
+func (c *InstructorVerificationController) Approve(ctx *gin.Context) {
+	adminID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	userID, err := parseIDParam(ctx, "userId")
+	if err != nil || userID == 0 {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Invalid user ID")))
+		return
+	}
+
+	if err := c.verificationService.Approve(ctx, adminID.(int64), userID); err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(
+		dto.SuccessResponse{Message: "Instructor approved successfully"}))
+}
+
+// Reject declines to confirm an account as an instructor (user:manage only)
+// @Summary Reject an instructor
+// @Description Makes a pending account a student account and emails the user the reason. Requires the user:manage permission.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param userId path int true "User ID"
+// @Param request body dto.RejectInstructorRequest true "Reason, sent to the user"
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse} "Instructor rejected successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid user ID or missing reason"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the user:manage permission"
+// @Failure 404 {object} dto.ErrorResponse "User not found or not pending verification"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /admin/instructor-verifications/{userId}/reject [post]
+func (c *InstructorVerificationController) Reject(ctx *gin.Context) {
+	adminID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	userID, err := parseIDParam(ctx, "userId")
+	if err != nil || userID == 0 {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Invalid user ID")))
+		return
+	}
+
+	var req dto.RejectInstructorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(dto.HandleValidationError(err)))
+		return
+	}
+
+	if err := c.verificationService.Reject(ctx, adminID.(int64), userID, req.Reason); err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(
+		dto.SuccessResponse{Message: "Instructor rejected successfully"}))
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
+)
+
+// LDAPController handles the LDAP directory sync
+type LDAPController struct {
+	ldapService services.LDAPService
+	logger      zerolog.Logger
+}
+
+// NewLDAPController creates a new LDAPController
+func NewLDAPController(ldapService services.LDAPService, logger zerolog.Logger) *LDAPController {
+	return &LDAPController{
+		ldapService: ldapService,
+		logger:      logger,
+	}
+}
+
+// Sync syncs users with the LDAP directory now (user:manage only)
+// @Summary Sync users with the LDAP directory
+// @Description Runs the periodic directory sync now: users linked to a directory entry get their names, department and role from it, and users whose entry is gone are deactivated and signed out. Users deactivated by an earlier sync are reactivated when their entry is back. Requires the user:manage permission.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=dto.LDAPSyncResponse} "Sync completed"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the user:manage permission"
+// @Failure 404 {object} dto.ErrorResponse "LDAP directory authentication is not enabled"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error or directory unreachable"
+// @Router /admin/ldap/sync [post]
+func (c *LDAPController) Sync(ctx *gin.Context) {
+	report, err := c.ldapService.Sync(ctx.Request.Context())
+	if err != nil {
+		c.logger.Error().Err(err).Msg("LDAP directory sync failed")
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(report))
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
+)
+
+// LockoutController handles accounts locked after too many failed logins
+type LockoutController struct {
+	throttleService services.ThrottleService
+}
+
+// NewLockoutController creates a new LockoutController
+func NewLockoutController(throttleService services.ThrottleService) *LockoutController {
+	return &LockoutController{
+		throttleService: throttleService,
+	}
+}
+
+// GetLockedAccounts lists the accounts that are locked (user:manage only)
+// @Summary List locked accounts
+// @Description Lists accounts locked after too many failed logins, with when each lockout ends. Requires the user:manage permission.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=[]dto.LockedAccountResponse} "Locked accounts retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the user:manage permission"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /admin/users/locked [get]
+func (c *LockoutController) GetLockedAccounts(ctx *gin.Context) {
+	accounts, err := c.throttleService.ListLockedAccounts(ctx)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(accounts))
+}
+
+// UnlockAccount lifts the lockout of an account (user:manage only)
+// @Summary Unlock an account
+// @Description Lifts the lockout and login backoff of an account, so its owner can log in right away. Requires the user:manage permission.
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse} "Account unlocked successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the user:manage permission"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /admin/users/{id}/unlock [post]
+func (c *LockoutController) UnlockAccount(ctx *gin.Context) {
+	userID, err := parseIDParam(ctx, "id")
+	if err != nil || userID == 0 {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Invalid user ID")))
+		return
+	}
+
+	if err := c.throttleService.UnlockAccount(ctx, userID); err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(
+		dto.SuccessResponse{Message: "Account unlocked successfully"}))
+}
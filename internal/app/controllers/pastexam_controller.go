@@ -10,7 +10,6 @@ import (
 	"mime/multipart"
 
 	"github.com/gin-gonic/gin"
-	"github.com/yigit/unisphere/internal/app/models"
 	"github.com/yigit/unisphere/internal/app/models/dto"
 	"github.com/yigit/unisphere/internal/app/services"
 	"github.com/yigit/unisphere/internal/middleware"
@@ -22,13 +21,15 @@ import (
 // PastExamController handles past exam related operations
 type PastExamController struct {
 	pastExamService services.PastExamService
-	fileStorage     *filestorage.LocalStorage
+	uploadService   services.UploadService
+	fileStorage     filestorage.FileStorage
 }
 
 // NewPastExamController creates a new PastExamController
-func NewPastExamController(pastExamService services.PastExamService, fileStorage *filestorage.LocalStorage) *PastExamController {
+func NewPastExamController(pastExamService services.PastExamService, uploadService services.UploadService, fileStorage filestorage.FileStorage) *PastExamController {
 	return &PastExamController{
 		pastExamService: pastExamService,
+		uploadService:   uploadService,
 		fileStorage:     fileStorage,
 	}
 }
@@ -51,6 +52,11 @@ func NewPastExamController(pastExamService services.PastExamService, fileStorage
 // @Param sortOrder query string false "Sort order (ASC, DESC)"
 // @Param page query int false "Page number (1-based)" default(1) minimum(1)
 // @Param pageSize query int false "Page size (default: 10, max: 100)" default(10) minimum(1) maximum(100)
+// @Param after query string false "Cursor token (cursor.nextCursor) to get the page after it; page is ignored"
+// @Param before query string false "Cursor token (cursor.prevCursor) to get the page before it; page is ignored"
+// @Param fields query string false "Comma separated past exam fields to return (e.g. id,title,year); id is always included"
+// @Param expand query string false "Comma separated relations to include (files, department); defaults to files"
+// @Param includeHtml query bool false "Return contentHtml, the content rendered to sanitized HTML"
 // @Success 200 {object} dto.APIResponse{data=dto.PastExamListResponse} "Past exams retrieved successfully"
 // @Failure 400 {object} dto.ErrorResponse "Invalid request parameters"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
@@ -124,6 +130,15 @@ func (c *PastExamController) GetAllPastExams(ctx *gin.Context) {
 	if term, ok := filters["term"].(string); ok {
 		filter.Term = &term
 	}
+	filter.After = ctx.Query("after")
+	filter.Before = ctx.Query("before")
+	filter.IncludeHTML, _ = strconv.ParseBool(ctx.Query("includeHtml"))
+
+	projection, ok := bindProjection(ctx, dto.PastExamResponse{}, dto.PastExamRelations)
+	if !ok {
+		return
+	}
+	filter.Projection = projection
 
 	response, err := c.pastExamService.GetAllExams(ctx, filter)
 	if err != nil {
@@ -131,7 +146,7 @@ func (c *PastExamController) GetAllPastExams(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(response))
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(projection.ApplyList(response, "pastExams")))
 }
 
 // GetPastExamByID handles retrieving a specific past exam by ID
@@ -141,6 +156,7 @@ func (c *PastExamController) GetAllPastExams(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Past exam ID"
+// @Param includeHtml query bool false "Return contentHtml, the content rendered to sanitized HTML"
 // @Success 200 {object} dto.APIResponse{data=dto.PastExamResponse} "Past exam retrieved successfully"
 // @Failure 400 {object} dto.ErrorResponse "Invalid past exam ID"
 // @Failure 404 {object} dto.ErrorResponse "Past exam not found"
@@ -157,7 +173,8 @@ func (c *PastExamController) GetPastExamByID(ctx *gin.Context) {
 	}
 
 	// Get exam by ID
-	exam, err := c.pastExamService.GetExamByID(ctx, id)
+	includeHTML, _ := strconv.ParseBool(ctx.Query("includeHtml"))
+	exam, err := c.pastExamService.GetExamByID(ctx, id, includeHTML)
 	if err != nil {
 		middleware.HandleAPIError(ctx, err)
 		return
@@ -168,7 +185,7 @@ func (c *PastExamController) GetPastExamByID(ctx *gin.Context) {
 
 // CreatePastExam godoc
 // @Summary Create a new past exam
-// @Description Create a new past exam with file upload. Only instructors can create past exams. The current authenticated instructor will be set as the instructor of the exam.
+// @Description Create a new past exam with file upload. Requires the pastexam:write permission on the department of the exam. The current authenticated user will be set as the instructor of the exam.
 // @Tags past-exams
 // @Accept multipart/form-data
 // @Produce json
@@ -182,7 +199,7 @@ func (c *PastExamController) GetPastExamByID(ctx *gin.Context) {
 // @Success 201 {object} dto.APIResponse{data=dto.PastExamResponse}
 // @Failure 400 {object} dto.APIResponse{error=dto.ErrorDetail}
 // @Failure 401 {object} dto.APIResponse{error=dto.ErrorDetail} "Unauthorized: JWT token missing or invalid"
-// @Failure 403 {object} dto.APIResponse{error=dto.ErrorDetail} "Forbidden: User does not have instructor role"
+// @Failure 403 {object} dto.APIResponse{error=dto.ErrorDetail} "Forbidden: User does not have the pastexam:write permission on the department"
 // @Failure 500 {object} dto.APIResponse{error=dto.ErrorDetail}
 // @Router /past-exams [post]
 func (c *PastExamController) CreatePastExam(ctx *gin.Context) {
@@ -230,24 +247,6 @@ func (c *PastExamController) CreatePastExam(ctx *gin.Context) {
 		return
 	}
 	
-	// Get user role
-	roleType, exists := ctx.Get("roleType") 
-	if !exists {
-		fmt.Println("User role not found in context")
-		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
-			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "User role not found")))
-		return
-	}
-	
-	// Convert to string and check role
-	roleStr, ok := roleType.(string)
-	if !ok || roleStr != string(models.RoleInstructor) {
-		fmt.Printf("User has invalid role for creating past exam: %v\n", roleStr)
-		ctx.JSON(http.StatusForbidden, dto.NewErrorResponse(
-			dto.NewErrorDetail(dto.ErrorCodeForbidden, "Only instructors can create past exams")))
-		return
-	}
-	
 	fmt.Printf("Creating past exam with instructor ID: %v\n", userID)
 	fmt.Printf("Request data: %+v\n", req)
 
@@ -255,6 +254,10 @@ func (c *PastExamController) CreatePastExam(ctx *gin.Context) {
 	exam, err := c.pastExamService.CreateExam(ctx, &req, files)
 	if err != nil {
 		fmt.Printf("Error creating past exam: %v\n", err)
+		if errors.Is(err, apperrors.ErrValidationFailed) || errors.Is(err, apperrors.ErrPermissionDenied) {
+			middleware.HandleAPIError(ctx, err)
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, dto.NewErrorResponse(
 			dto.NewErrorDetail(dto.ErrorCodeInternalServer, "Failed to create past exam").WithDetails(err.Error())))
 		return
@@ -266,7 +269,7 @@ func (c *PastExamController) CreatePastExam(ctx *gin.Context) {
 
 // UpdatePastExam handles updating an existing past exam
 // @Summary Update a past exam
-// @Description Updates an existing past exam with the provided information. Only instructors can update past exams. The instructor must be the owner of the exam.
+// @Description Updates an existing past exam with the provided information. Requires the pastexam:write permission on the department of the exam, and the user must be the creator of the exam or have pastexam:manage there.
 // @Tags past-exams
 // @Accept multipart/form-data
 // @Produce json
@@ -282,7 +285,7 @@ func (c *PastExamController) CreatePastExam(ctx *gin.Context) {
 // @Success 200 {object} dto.APIResponse{data=dto.PastExamResponse} "Past exam updated successfully"
 // @Failure 400 {object} dto.ErrorResponse "Invalid request format"
 // @Failure 401 {object} dto.ErrorResponse "Unauthorized: JWT token missing or invalid"
-// @Failure 403 {object} dto.ErrorResponse "Forbidden: User does not have instructor role or is not the creator"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden: User lacks pastexam:write on the department, or is neither the creator nor a past exam manager"
 // @Failure 404 {object} dto.ErrorResponse "Past exam not found"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /past-exams/{id} [put]
@@ -335,7 +338,7 @@ func (c *PastExamController) UpdatePastExam(ctx *gin.Context) {
 // @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse} "Past exam deleted successfully" 
 // @Failure 400 {object} dto.ErrorResponse "Invalid past exam ID"
 // @Failure 401 {object} dto.ErrorResponse "Unauthorized: JWT token missing or invalid"
-// @Failure 403 {object} dto.ErrorResponse "Forbidden: User does not have instructor role or is not the creator"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden: User lacks pastexam:write on the department, or is neither the creator nor a past exam manager"
 // @Failure 404 {object} dto.ErrorResponse "Past exam not found"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /past-exams/{id} [delete]
@@ -397,11 +400,12 @@ func (c *PastExamController) DeletePastExam(ctx *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Past exam ID"
-// @Param files formData file true "Files to upload (can be multiple)"
-// @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse}
+// @Param files formData file false "Files to upload (can be multiple)"
+// @Param uploadIds formData []string false "IDs of finished resumable uploads to add (can be multiple)" collectionFormat(multi)
+// @Success 200 {object} dto.APIResponse{data=dto.FilesAddedResponse} "Files added; duplicateWarnings lists uploads that already exist elsewhere"
 // @Failure 400 {object} dto.APIResponse{error=dto.ErrorDetail}
 // @Failure 401 {object} dto.APIResponse{error=dto.ErrorDetail} "Unauthorized: JWT token missing or invalid"
-// @Failure 403 {object} dto.APIResponse{error=dto.ErrorDetail} "Forbidden: User does not have instructor role or is not the creator"
+// @Failure 403 {object} dto.APIResponse{error=dto.ErrorDetail} "Forbidden: User lacks pastexam:write on the department, or is neither the creator nor a past exam manager"
 // @Failure 404 {object} dto.APIResponse{error=dto.ErrorDetail}
 // @Failure 500 {object} dto.APIResponse{error=dto.ErrorDetail}
 // @Router /past-exams/{id}/files [post]
@@ -434,6 +438,15 @@ func (c *PastExamController) AddFileToPastExam(ctx *gin.Context) {
 	} else {
 		fmt.Println("No files provided or error getting files")
 	}
+
+	// Finished resumable uploads are added like uploaded files
+	uploads, err := openFormUploads(ctx, c.uploadService, "uploadIds")
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+	defer uploads.Close()
+	files = append(files, uploads.Files()...)
 	
 	if len(files) == 0 {
 		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
@@ -444,15 +457,18 @@ func (c *PastExamController) AddFileToPastExam(ctx *gin.Context) {
 	// Process each file
 	successCount := 0
 	var lastError error
+	var duplicateWarnings []dto.DuplicateWarning
 	
 	for _, fileHeader := range files {
 		// Add file to past exam
-		err = c.pastExamService.AddFileToPastExam(ctx, id, fileHeader)
+		warnings, err := c.pastExamService.AddFileToPastExam(ctx, id, fileHeader)
 		if err != nil {
 			fmt.Printf("Error adding file '%s' to past exam: %v\n", fileHeader.Filename, err)
 			lastError = err
 		} else {
 			successCount++
+			duplicateWarnings = append(duplicateWarnings, warnings...)
+			uploads.Attached(ctx, fileHeader)
 		}
 	}
 	
@@ -465,6 +481,8 @@ func (c *PastExamController) AddFileToPastExam(ctx *gin.Context) {
 		case errors.Is(lastError, apperrors.ErrPermissionDenied) || strings.Contains(lastError.Error(), "unauthorized"):
 			ctx.JSON(http.StatusForbidden, dto.NewErrorResponse(
 				dto.NewErrorDetail(dto.ErrorCodeForbidden, "You don't have permission to update this past exam")))
+		case errors.Is(lastError, apperrors.ErrValidationFailed):
+			middleware.HandleAPIError(ctx, lastError)
 		default:
 			ctx.JSON(http.StatusInternalServerError, dto.NewErrorResponse(
 				dto.NewErrorDetail(dto.ErrorCodeInternalServer, "Failed to add files to past exam").WithDetails(lastError.Error())))
@@ -473,8 +491,10 @@ func (c *PastExamController) AddFileToPastExam(ctx *gin.Context) {
 	}
 
 	fmt.Printf("********* AddFileToPastExam BAŞARILI: %d/%d files added *********\n", successCount, len(files))
-	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(
-		dto.SuccessResponse{Message: fmt.Sprintf("%d files added to past exam successfully", successCount)}))
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(dto.FilesAddedResponse{
+		Message:           fmt.Sprintf("%d files added to past exam successfully", successCount),
+		DuplicateWarnings: duplicateWarnings,
+	}))
 }
 
 // DeleteFileFromPastExam godoc
@@ -489,7 +509,7 @@ func (c *PastExamController) AddFileToPastExam(ctx *gin.Context) {
 // @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse}
 // @Failure 400 {object} dto.APIResponse{error=dto.ErrorDetail}
 // @Failure 401 {object} dto.APIResponse{error=dto.ErrorDetail} "Unauthorized: JWT token missing or invalid"
-// @Failure 403 {object} dto.APIResponse{error=dto.ErrorDetail} "Forbidden: User does not have instructor role or is not the creator"
+// @Failure 403 {object} dto.APIResponse{error=dto.ErrorDetail} "Forbidden: User lacks pastexam:write on the department, or is neither the creator nor a past exam manager"
 // @Failure 404 {object} dto.APIResponse{error=dto.ErrorDetail}
 // @Failure 500 {object} dto.APIResponse{error=dto.ErrorDetail}
 // @Router /past-exams/{id}/files/{fileId} [delete]
@@ -540,3 +560,64 @@ func (c *PastExamController) DeleteFileFromPastExam(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(
 		dto.SuccessResponse{Message: "File deleted from past exam successfully"}))
 }
+
+// DownloadPastExamBundle godoc
+// @Summary Download all files of a past exam as a ZIP
+// @Description Streams a ZIP archive of every file attached to the past exam, organised as year/term/title.
+// @Tags past-exams
+// @Produce application/zip
+// @Security BearerAuth
+// @Param id path int true "Past exam ID"
+// @Success 200 {file} binary "ZIP archive"
+// @Failure 400 {object} dto.ErrorResponse "Invalid past exam ID"
+// @Failure 404 {object} dto.ErrorResponse "Past exam not found or has no files"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /past-exams/{id}/bundle [get]
+func (c *PastExamController) DownloadPastExamBundle(ctx *gin.Context) {
+	id, err := parseIDParam(ctx, "id")
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeValidationFailed, "Invalid past exam ID").
+				WithDetails("Past exam ID must be a valid number")))
+		return
+	}
+
+	bundle, err := c.pastExamService.GetExamBundle(ctx, id)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	streamFileBundle(ctx, c.fileStorage, bundle)
+}
+
+// DownloadCourseBundle godoc
+// @Summary Download all past exams of a course as a ZIP
+// @Description Streams a ZIP archive of every file attached to the course's past exams, optionally limited to a year range. Files are organised as year/term/title.
+// @Tags past-exams
+// @Produce application/zip
+// @Security BearerAuth
+// @Param courseCode query string true "Course code"
+// @Param fromYear query int false "First year to include"
+// @Param toYear query int false "Last year to include"
+// @Success 200 {file} binary "ZIP archive"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request parameters or too many files"
+// @Failure 404 {object} dto.ErrorResponse "No files found for the course"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /past-exams/bundle [get]
+func (c *PastExamController) DownloadCourseBundle(ctx *gin.Context) {
+	var req dto.CourseBundleRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeValidationFailed, "Invalid request parameters").WithDetails(err.Error())))
+		return
+	}
+
+	bundle, err := c.pastExamService.GetCourseBundle(ctx, &req)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	streamFileBundle(ctx, c.fileStorage, bundle)
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
+)
+
+// PersonalAccessTokenController handles the authenticated user's personal access tokens
+type PersonalAccessTokenController struct {
+	tokenService services.PersonalAccessTokenService
+}
+
+// NewPersonalAccessTokenController creates a new PersonalAccessTokenController
+func NewPersonalAccessTokenController(tokenService services.PersonalAccessTokenService) *PersonalAccessTokenController {
+	return &PersonalAccessTokenController{
+		tokenService: tokenService,
+	}
+}
+
+// GetTokens lists the user's personal access tokens
+// @Summary List personal access tokens
+// @Description Lists the personal access tokens of the current user, newest first, including revoked and expired ones. The tokens themselves are not shown, only their first characters.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=[]dto.PersonalAccessTokenResponse} "Personal access tokens"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/tokens [get]
+func (c *PersonalAccessTokenController) GetTokens(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	tokens, err := c.tokenService.ListTokens(ctx, userID.(int64))
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(tokens))
+}
+
+// CreateToken creates a personal access token
+// @Summary Create a personal access token
+// @Description Creates a named token for scripts and bots to call the API as the current user, sent as "Authorization: Bearer upt_...". Its scopes limit it to content:read, content:write, chat:read, chat:send or profile:read routes; account, session, token and admin routes refuse it. Without expiresInDays it does not expire. The token is returned once and cannot be shown again.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body dto.CreatePersonalAccessTokenRequest true "Name, scopes and expiry"
+// @Security BearerAuth
+// @Success 201 {object} dto.APIResponse{data=dto.CreatedPersonalAccessTokenResponse} "Token created"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request or unknown scope"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 409 {object} dto.ErrorResponse "Too many active tokens"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/tokens [post]
+func (c *PersonalAccessTokenController) CreateToken(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	var req dto.CreatePersonalAccessTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(dto.HandleValidationError(err)))
+		return
+	}
+
+	token, err := c.tokenService.CreateToken(ctx, userID.(int64), &req)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, dto.NewSuccessResponse(token))
+}
+
+// RevokeToken revokes a personal access token
+// @Summary Revoke a personal access token
+// @Description Revokes one of the current user's personal access tokens. It stops working at once.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param tokenId path int true "Personal access token ID"
+// @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse} "Token revoked"
+// @Failure 400 {object} dto.ErrorResponse "Invalid token ID"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 404 {object} dto.ErrorResponse "Token not found or already revoked"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/tokens/{tokenId} [delete]
+func (c *PersonalAccessTokenController) RevokeToken(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	tokenID, err := parseIDParam(ctx, "tokenId")
+	if err != nil || tokenID == 0 {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Invalid token ID")))
+		return
+	}
+
+	if err := c.tokenService.RevokeToken(ctx, userID.(int64), tokenID); err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(
+		dto.SuccessResponse{Message: "Personal access token revoked"}))
+}
@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+)
+
+// bindProjection parses the fields= and expand= query parameters of a list endpoint.
+// On invalid input it writes a 400 response and returns false.
+func bindProjection(ctx *gin.Context, item interface{}, relations dto.Relations) (*dto.Projection, bool) {
+	projection, err := dto.NewProjection(ctx.Query("fields"), ctx.Query("expand"), item, relations)
+	if err != nil {
+		errorDetail := dto.NewErrorDetail(dto.ErrorCodeValidationFailed, "Invalid fields or expand parameter")
+		errorDetail = errorDetail.WithDetails(err.Error())
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(errorDetail))
+		return nil, false
+	}
+	return projection, true
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
+)
+
+// QuarantineController handles files quarantined by the malware scanner
+type QuarantineController struct {
+	scanService services.ScanService
+}
+
+// NewQuarantineController creates a new QuarantineController
+func NewQuarantineController(scanService services.ScanService) *QuarantineController {
+	return &QuarantineController{
+		scanService: scanService,
+	}
+}
+
+// GetQuarantinedFiles lists files in which the malware scanner found malware (file:moderate only)
+// @Summary List quarantined files
+// @Description Lists uploaded files that were found infected, with the signature the scanner reported. Quarantined files cannot be downloaded and are hidden from listings. Requires the file:moderate permission.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=[]dto.QuarantinedFileResponse} "Quarantined files retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the file:moderate permission"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /admin/files/quarantine [get]
+func (c *QuarantineController) GetQuarantinedFiles(ctx *gin.Context) {
+	files, err := c.scanService.GetQuarantinedFiles(ctx)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(files))
+}
+
+// DeleteQuarantinedFile permanently deletes a quarantined file (file:moderate only)
+// @Summary Delete a quarantined file
+// @Description Deletes a quarantined file from storage and the database. Requires the file:moderate permission.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param fileId path int true "File ID"
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse} "Quarantined file deleted successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid file ID"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the file:moderate permission"
+// @Failure 404 {object} dto.ErrorResponse "File not found or not quarantined"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /admin/files/quarantine/{fileId} [delete]
+func (c *QuarantineController) DeleteQuarantinedFile(ctx *gin.Context) {
+	fileID, err := parseIDParam(ctx, "fileId")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Invalid file ID")))
+		return
+	}
+
+	if err := c.scanService.DeleteQuarantinedFile(ctx, fileID); err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(
+		dto.SuccessResponse{Message: "Quarantined file deleted successfully"}))
+}
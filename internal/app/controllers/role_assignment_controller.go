@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
+)
+
+// RoleAssignmentController handles the roles and the role assignments that grant users
+// permissions within a scope
+type RoleAssignmentController struct {
+	roleAssignmentService services.RoleAssignmentService
+}
+
+// NewRoleAssignmentController creates a new RoleAssignmentController
+func NewRoleAssignmentController(roleAssignmentService services.RoleAssignmentService) *RoleAssignmentController {
+	return &RoleAssignmentController{
+		roleAssignmentService: roleAssignmentService,
+	}
+}
+
+// GetRoles lists the roles and their permissions (role:manage only)
+// @Summary List roles
+// @Description Lists every role with the permissions it grants and the scopes it can be assigned in. Requires the role:manage permission.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=[]dto.RoleResponse} "Roles retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the role:manage permission"
+// @Router /admin/roles [get]
+func (c *RoleAssignmentController) GetRoles(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(c.roleAssignmentService.ListRoles()))
+}
+
+// GetAssignments lists role assignments (role:manage only)
+// @Summary List role assignments
+// @Description Lists the roles granted to users within a scope, optionally for one user. The role of an account is not listed; it always applies globally. Requires the role:manage permission.
+// @Tags admin
+// @Produce json
+// @Param userId query int false "Only list the assignments of this user"
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=[]dto.RoleAssignmentResponse} "Role assignments retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the role:manage permission"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /admin/role-assignments [get]
+func (c *RoleAssignmentController) GetAssignments(ctx *gin.Context) {
+	var userID *int64
+	if userIDStr := ctx.Query("userId"); userIDStr != "" {
+		id, err := strconv.ParseInt(userIDStr, 10, 64)
+		if err != nil || id <= 0 {
+			ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+				dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Invalid user ID")))
+			return
+		}
+		userID = &id
+	}
+
+	assignments, err := c.roleAssignmentService.ListAssignments(ctx, userID)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(assignments))
+}
+
+// CreateAssignment grants a user a role within a scope (role:manage only)
+// @Summary Assign a role
+// @Description Grants a user a role within a scope: global, or a faculty, department or community given by scopeId. A faculty assignment also covers the departments of the faculty. Takes effect on the user's next request. Requires the role:manage permission.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body dto.CreateRoleAssignmentRequest true "User, role and scope"
+// @Security BearerAuth
+// @Success 201 {object} dto.APIResponse{data=dto.RoleAssignmentResponse} "Role assigned successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request, unknown role or scope not allowed for the role"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the role:manage permission"
+// @Failure 404 {object} dto.ErrorResponse "User, faculty, department or community not found"
+// @Failure 409 {object} dto.ErrorResponse "The user already has this role in this scope"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /admin/role-assignments [post]
+func (c *RoleAssignmentController) CreateAssignment(ctx *gin.Context) {
+	adminID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	var req dto.CreateRoleAssignmentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(dto.HandleValidationError(err)))
+		return
+	}
+
+	assignment, err := c.roleAssignmentService.CreateAssignment(ctx, adminID.(int64), &req)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, dto.NewSuccessResponse(assignment))
+}
+
+// DeleteAssignment removes a role assignment (role:manage only)
+// @Summary Remove a role assignment
+// @Description Removes a role granted to a user within a scope. Takes effect on the user's next request. Requires the role:manage permission.
+// @Tags admin
+// @Produce json
+// @Param id path int true "Role assignment ID"
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse} "Role assignment removed successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid role assignment ID"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the role:manage permission"
+// @Failure 404 {object} dto.ErrorResponse "Role assignment not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /admin/role-assignments/{id} [delete]
+func (c *RoleAssignmentController) DeleteAssignment(ctx *gin.Context) {
+	adminID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	id, err := parseIDParam(ctx, "id")
+	if err != nil || id == 0 {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Invalid role assignment ID")))
+		return
+	}
+
+	if err := c.roleAssignmentService.DeleteAssignment(ctx, adminID.(int64), id); err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(
+		dto.SuccessResponse{Message: "Role assignment removed successfully"}))
+}
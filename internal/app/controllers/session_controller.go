@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
+)
+
+// SessionController handles the authenticated user's device sessions
+type SessionController struct {
+	authService services.AuthService
+}
+
+// NewSessionController creates a new SessionController
+func NewSessionController(authService services.AuthService) *SessionController {
+	return &SessionController{
+		authService: authService,
+	}
+}
+
+// GetSessions lists the user's active sessions
+// @Summary List active sessions
+// @Description Lists the devices the current user is signed in on, one per login. The session the request was made from has current set to true.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=[]dto.SessionResponse} "Active sessions"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/sessions [get]
+func (c *SessionController) GetSessions(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+	sessionID, _ := ctx.Get("sessionID")
+	currentSessionID, _ := sessionID.(int64)
+
+	sessions, err := c.authService.GetSessions(ctx, userID.(int64), currentSessionID)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(sessions))
+}
+
+// RevokeSession signs the user out of one session
+// @Summary Sign out a session
+// @Description Signs the current user out of one session. Its refresh token and the access tokens issued to it stop working.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param sessionId path int true "Session ID"
+// @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse} "Session signed out"
+// @Failure 400 {object} dto.ErrorResponse "Invalid session ID"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 404 {object} dto.ErrorResponse "Session not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/sessions/{sessionId} [delete]
+func (c *SessionController) RevokeSession(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	sessionID, err := parseIDParam(ctx, "sessionId")
+	if err != nil || sessionID == 0 {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Invalid session ID")))
+		return
+	}
+
+	if err := c.authService.RevokeSession(ctx, userID.(int64), sessionID); err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(
+		dto.SuccessResponse{Message: "Session signed out"}))
+}
+
+// RevokeAllSessions signs the user out of every session
+// @Summary Sign out all sessions
+// @Description Signs the current user out of every session, including the one the request was made from. All refresh tokens stop working.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse} "All sessions signed out"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/sessions [delete]
+func (c *SessionController) RevokeAllSessions(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	if err := c.authService.RevokeAllSessions(ctx, userID.(int64)); err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(
+		dto.SuccessResponse{Message: "All sessions signed out"}))
+}
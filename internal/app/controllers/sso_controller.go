@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
+)
+
+// SSOController handles logins through the single sign-on provider
+type SSOController struct {
+	ssoService services.SSOService
+	logger     zerolog.Logger
+}
+
+// NewSSOController creates a new SSOController
+func NewSSOController(ssoService services.SSOService, logger zerolog.Logger) *SSOController {
+	return &SSOController{
+		ssoService: ssoService,
+		logger:     logger,
+	}
+}
+
+// Authorize starts a single sign-on login
+// @Summary Start single sign-on
+// @Description Returns the URL of the OpenID Connect provider to send the user to. After logging in there, the provider redirects to the configured redirect URL with code and state, which the client posts to /auth/sso/callback. The state must come back before expiresIn seconds have passed.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} dto.APIResponse{data=dto.SSOAuthorizationResponse} "Authorization URL created"
+// @Failure 404 {object} dto.ErrorResponse "Single sign-on is not enabled"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error or provider unreachable"
+// @Router /auth/sso/authorize [get]
+func (c *SSOController) Authorize(ctx *gin.Context) {
+	response, err := c.ssoService.AuthorizationURL(ctx.Request.Context())
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to start single sign-on")
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(response))
+}
+
+// Callback completes a single sign-on login
+// @Summary Complete single sign-on
+// @Description Exchanges the code and state the provider redirected back with for an access token, like /auth/login. On the first login the provider account is linked to the user with the same verified email, or a new account is created from its claims. Users with two-factor authentication get an mfaToken to complete at /auth/login/mfa.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.SSOCallbackRequest true "Code and state from the provider redirect"
+// @Success 200 {object} dto.APIResponse{data=dto.LoginResponse} "Login successful or second factor required"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request, or state invalid or expired"
+// @Failure 401 {object} dto.ErrorResponse "Code or ID token rejected"
+// @Failure 403 {object} dto.ErrorResponse "Account disabled or email not verified by the provider"
+// @Failure 404 {object} dto.ErrorResponse "Single sign-on is not enabled"
+// @Failure 409 {object} dto.ErrorResponse "Account already linked to another provider account, or student number taken"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /auth/sso/callback [post]
+func (c *SSOController) Callback(ctx *gin.Context) {
+	var req dto.SSOCallbackRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		c.logger.Warn().Err(err).Msg("Invalid single sign-on callback payload")
+		errorDetail := dto.HandleValidationError(err)
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(errorDetail))
+		return
+	}
+
+	loginResponse, err := c.ssoService.Login(ctx.Request.Context(), &req, clientInfo(ctx))
+	if err != nil {
+		c.logger.Warn().Err(err).Msg("Single sign-on login failed")
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(loginResponse))
+}
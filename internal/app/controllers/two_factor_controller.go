@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
+)
+
+// TwoFactorController handles the authenticated user's two-factor authentication settings
+type TwoFactorController struct {
+	twoFactorService services.TwoFactorService
+}
+
+// NewTwoFactorController creates a new TwoFactorController
+func NewTwoFactorController(twoFactorService services.TwoFactorService) *TwoFactorController {
+	return &TwoFactorController{
+		twoFactorService: twoFactorService,
+	}
+}
+
+// GetStatus returns the user's two-factor authentication status
+// @Summary Get two-factor authentication status
+// @Description Returns whether two-factor authentication is enabled for the current user, whether their role requires it, and how many recovery codes are left.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=dto.TwoFactorStatusResponse} "Two-factor authentication status"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/2fa [get]
+func (c *TwoFactorController) GetStatus(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	status, err := c.twoFactorService.GetStatus(ctx, userID.(int64))
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(status))
+}
+
+// Setup starts a TOTP enrollment
+// @Summary Start two-factor authentication setup
+// @Description Generates a TOTP secret and its otpauth:// provisioning URI, to show as a QR code for authenticator apps. Two-factor authentication is turned on once a code is confirmed with /users/profile/2fa/enable; calling setup again replaces the secret.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=dto.TOTPSetupResponse} "TOTP secret generated"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 409 {object} dto.ErrorResponse "Two-factor authentication is already enabled"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/2fa/setup [post]
+func (c *TwoFactorController) Setup(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	setup, err := c.twoFactorService.Setup(ctx, userID.(int64))
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(setup))
+}
+
+// Enable confirms the TOTP enrollment and turns two-factor authentication on
+// @Summary Enable two-factor authentication
+// @Description Confirms the secret from /users/profile/2fa/setup with a code from the authenticator app and turns two-factor authentication on. Returns recovery codes, which are shown only this once.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.TOTPCodeRequest true "Code from the authenticator app"
+// @Success 200 {object} dto.APIResponse{data=dto.RecoveryCodesResponse} "Two-factor authentication enabled"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request format or setup not started"
+// @Failure 401 {object} dto.ErrorResponse "Invalid token or code"
+// @Failure 409 {object} dto.ErrorResponse "Two-factor authentication is already enabled"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/2fa/enable [post]
+func (c *TwoFactorController) Enable(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	var req dto.TOTPCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(dto.HandleValidationError(err)))
+		return
+	}
+
+	codes, err := c.twoFactorService.Enable(ctx, userID.(int64), req.Code)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(codes))
+}
+
+// Disable turns two-factor authentication off
+// @Summary Disable two-factor authentication
+// @Description Turns two-factor authentication off and deletes the recovery codes. Requires the password and a code from the authenticator app or a recovery code.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.DisableTOTPRequest true "Password and code"
+// @Success 200 {object} dto.APIResponse{data=dto.SuccessResponse} "Two-factor authentication disabled"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request format or two-factor authentication not enabled"
+// @Failure 401 {object} dto.ErrorResponse "Invalid token, password or code"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/2fa/disable [post]
+func (c *TwoFactorController) Disable(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	var req dto.DisableTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(dto.HandleValidationError(err)))
+		return
+	}
+
+	if err := c.twoFactorService.Disable(ctx, userID.(int64), &req); err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(
+		dto.SuccessResponse{Message: "Two-factor authentication disabled"}))
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes
+// @Summary Regenerate recovery codes
+// @Description Replaces all recovery codes with new ones, which are shown only this once. Requires a code from the authenticator app; recovery codes are not accepted.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.TOTPCodeRequest true "Code from the authenticator app"
+// @Success 200 {object} dto.APIResponse{data=dto.RecoveryCodesResponse} "Recovery codes regenerated"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request format or two-factor authentication not enabled"
+// @Failure 401 {object} dto.ErrorResponse "Invalid token or code"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/2fa/recovery-codes [post]
+func (c *TwoFactorController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return
+	}
+
+	var req dto.TOTPCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(dto.HandleValidationError(err)))
+		return
+	}
+
+	codes, err := c.twoFactorService.RegenerateRecoveryCodes(ctx, userID.(int64), req.Code)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(codes))
+}
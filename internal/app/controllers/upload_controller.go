@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/services"
+	"github.com/yigit/unisphere/internal/middleware"
+)
+
+// Headers of the tus 1.0 resumable upload protocol, see https://tus.io/protocols/resumable-upload
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,expiration,termination"
+	tusContentType = "application/offset+octet-stream"
+)
+
+// UploadController implements the tus resumable upload protocol on top of UploadService
+type UploadController struct {
+	uploadService services.UploadService
+}
+
+// NewUploadController creates a new UploadController
+func NewUploadController(uploadService services.UploadService) *UploadController {
+	return &UploadController{
+		uploadService: uploadService,
+	}
+}
+
+// Options godoc
+// @Summary Describe the resumable upload server
+// @Description tus discovery: reports the protocol version, supported extensions and maximum upload size
+// @Tags uploads
+// @Success 204 "Tus-Version, Tus-Extension and Tus-Max-Size headers"
+// @Router /uploads [options]
+func (c *UploadController) Options(ctx *gin.Context) {
+	ctx.Header("Tus-Resumable", tusVersion)
+	ctx.Header("Tus-Version", tusVersion)
+	ctx.Header("Tus-Extension", tusExtensions)
+	ctx.Header("Tus-Max-Size", strconv.FormatInt(c.uploadService.MaxSize(), 10))
+	ctx.Status(http.StatusNoContent)
+}
+
+// CreateUpload godoc
+// @Summary Start a resumable upload
+// @Description tus creation: reserves an upload of Upload-Length bytes, which count toward the storage quota together with the user's other unfinished uploads. A user can have at most 10 unfinished uploads. The Location header is the URL to PATCH the content to. Finished uploads are attached with the uploadIds form field of the past exam and class note file endpoints, or uploadId of chat file messages.
+// @Tags uploads
+// @Security BearerAuth
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param Upload-Length header int true "Total size in bytes"
+// @Param Upload-Metadata header string false "Comma separated key and base64 value pairs, e.g. filename ZXhhbS5wZGY="
+// @Success 201 "Location and Upload-Expires headers"
+// @Failure 400 {object} dto.ErrorResponse "Missing or invalid headers"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized: JWT token missing or invalid"
+// @Failure 409 {object} dto.ErrorResponse "Too many unfinished uploads"
+// @Failure 412 "Unsupported Tus-Resumable version"
+// @Failure 413 {object} dto.ErrorResponse "Upload-Length exceeds Tus-Max-Size"
+// @Router /uploads [post]
+func (c *UploadController) CreateUpload(ctx *gin.Context) {
+	userID, ok := c.begin(ctx)
+	if !ok {
+		return
+	}
+
+	length, err := strconv.ParseInt(ctx.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Upload-Length header must be a non-negative integer")))
+		return
+	}
+	if length > c.uploadService.MaxSize() {
+		ctx.Header("Tus-Max-Size", strconv.FormatInt(c.uploadService.MaxSize(), 10))
+		ctx.JSON(http.StatusRequestEntityTooLarge, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeFileTooLarge, "Upload-Length exceeds the maximum upload size")))
+		return
+	}
+
+	session, err := c.uploadService.CreateUpload(ctx, userID, length, ctx.GetHeader("Upload-Metadata"))
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	setUploadExpires(ctx, session)
+	ctx.Header("Location", strings.TrimRight(ctx.Request.URL.Path, "/")+"/"+session.ID)
+	ctx.Status(http.StatusCreated)
+}
+
+// GetUploadOffset godoc
+// @Summary Get the offset of a resumable upload
+// @Description tus HEAD request: reports how many bytes were received, so an interrupted upload can resume there
+// @Tags uploads
+// @Security BearerAuth
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param uploadId path string true "Upload ID"
+// @Success 200 "Upload-Offset, Upload-Length and Upload-Expires headers"
+// @Failure 404 "Upload not found or expired"
+// @Router /uploads/{uploadId} [head]
+func (c *UploadController) GetUploadOffset(ctx *gin.Context) {
+	userID, ok := c.begin(ctx)
+	if !ok {
+		return
+	}
+
+	session, err := c.uploadService.GetUpload(ctx, userID, ctx.Param("uploadId"))
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	ctx.Header("Upload-Length", strconv.FormatInt(session.Length, 10))
+	if session.Metadata != "" {
+		ctx.Header("Upload-Metadata", session.Metadata)
+	}
+	setUploadExpires(ctx, session)
+	ctx.Status(http.StatusOK)
+}
+
+// PatchUpload godoc
+// @Summary Send content of a resumable upload
+// @Description tus PATCH request: appends the body at Upload-Offset. If the connection drops, the bytes that arrived are kept; ask for the offset with HEAD and continue from there.
+// @Tags uploads
+// @Accept application/offset+octet-stream
+// @Security BearerAuth
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param Upload-Offset header int true "Offset the body starts at; must equal the current offset"
+// @Param uploadId path string true "Upload ID"
+// @Success 204 "Upload-Offset header with the new offset"
+// @Failure 400 {object} dto.ErrorResponse "Missing Upload-Offset or body exceeds Upload-Length"
+// @Failure 404 {object} dto.ErrorResponse "Upload not found or expired"
+// @Failure 409 {object} dto.ErrorResponse "Upload-Offset does not match"
+// @Failure 415 {object} dto.ErrorResponse "Content-Type is not application/offset+octet-stream"
+// @Router /uploads/{uploadId} [patch]
+func (c *UploadController) PatchUpload(ctx *gin.Context) {
+	userID, ok := c.begin(ctx)
+	if !ok {
+		return
+	}
+
+	if ctx.ContentType() != tusContentType {
+		ctx.JSON(http.StatusUnsupportedMediaType, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Content-Type must be "+tusContentType)))
+		return
+	}
+
+	offset, err := strconv.ParseInt(ctx.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		ctx.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Upload-Offset header must be a non-negative integer")))
+		return
+	}
+
+	session, err := c.uploadService.WriteChunk(ctx, userID, ctx.Param("uploadId"), offset, ctx.Request.Body)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	setUploadExpires(ctx, session)
+	ctx.Status(http.StatusNoContent)
+}
+
+// DeleteUpload godoc
+// @Summary Cancel a resumable upload
+// @Description tus termination: deletes the upload and the content received so far
+// @Tags uploads
+// @Security BearerAuth
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param uploadId path string true "Upload ID"
+// @Success 204 "Upload deleted"
+// @Failure 404 {object} dto.ErrorResponse "Upload not found or expired"
+// @Router /uploads/{uploadId} [delete]
+func (c *UploadController) DeleteUpload(ctx *gin.Context) {
+	userID, ok := c.begin(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.uploadService.TerminateUpload(ctx, userID, ctx.Param("uploadId")); err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// begin checks the protocol version and the authenticated user that every tus request
+// except OPTIONS needs. It writes the error response and returns false if either is missing.
+func (c *UploadController) begin(ctx *gin.Context) (int64, bool) {
+	ctx.Header("Tus-Resumable", tusVersion)
+
+	if ctx.GetHeader("Tus-Resumable") != tusVersion {
+		ctx.Header("Tus-Version", tusVersion)
+		ctx.JSON(http.StatusPreconditionFailed, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, "Unsupported Tus-Resumable version; expected "+tusVersion)))
+		return 0, false
+	}
+
+	userID, ok := ctx.Get("userID")
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")))
+		return 0, false
+	}
+	return userID.(int64), true
+}
+
+// setUploadExpires sets the tus expiration header of an upload
+func setUploadExpires(ctx *gin.Context, session *models.UploadSession) {
+	ctx.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+}
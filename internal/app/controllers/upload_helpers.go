@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"mime/multipart"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yigit/unisphere/internal/app/services"
+)
+
+// formUploads holds the finished resumable uploads a request refers to by ID. Their files
+// go through the same service calls as multipart files.
+type formUploads struct {
+	uploadService services.UploadService
+	uploads       map[*multipart.FileHeader]*services.CompletedUpload
+	files         []*multipart.FileHeader
+}
+
+// openFormUploads opens the uploads named by the given form field, which may repeat.
+// Call Close when the request is done.
+func openFormUploads(ctx *gin.Context, uploadService services.UploadService, field string) (*formUploads, error) {
+	u := &formUploads{
+		uploadService: uploadService,
+		uploads:       make(map[*multipart.FileHeader]*services.CompletedUpload),
+	}
+
+	ids := ctx.PostFormArray(field)
+	if len(ids) == 0 {
+		return u, nil
+	}
+
+	userID, _ := ctx.Get("userID")
+	for _, id := range ids {
+		upload, err := uploadService.OpenCompletedUpload(ctx, userID.(int64), id)
+		if err != nil {
+			u.Close()
+			return nil, err
+		}
+		u.uploads[upload.File] = upload
+		u.files = append(u.files, upload.File)
+	}
+	return u, nil
+}
+
+// Files returns the files of the uploads in request order
+func (u *formUploads) Files() []*multipart.FileHeader {
+	return u.files
+}
+
+// Attached deletes the upload of a file once the file is stored with its resource.
+// Files that did not come from an upload are ignored.
+func (u *formUploads) Attached(ctx context.Context, file *multipart.FileHeader) {
+	if upload, ok := u.uploads[file]; ok {
+		u.uploadService.FinishUpload(ctx, upload)
+	}
+}
+
+// Close releases the temporary files of the uploads. Uploads that were not attached
+// stay available until they expire, so the client can retry.
+func (u *formUploads) Close() {
+	for _, upload := range u.uploads {
+		_ = upload.Close()
+	}
+}
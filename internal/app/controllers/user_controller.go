@@ -16,11 +16,11 @@ import (
 // UserController handles user-related operations
 type UserController struct {
 	userService services.UserService
-	fileStorage *filestorage.LocalStorage
+	fileStorage filestorage.FileStorage
 }
 
 // NewUserController creates a new user controller
-func NewUserController(userService services.UserService, fileStorage *filestorage.LocalStorage) *UserController {
+func NewUserController(userService services.UserService, fileStorage filestorage.FileStorage) *UserController {
 	return &UserController{
 		userService: userService,
 		fileStorage: fileStorage,
@@ -45,6 +45,7 @@ func (c *UserController) mapUserToResponse(user *models.User) dto.ExtendedUserRe
 		fileInfo, err := c.userService.GetFileByID(context.Background(), *user.ProfilePhotoFileID)
 		if err == nil && fileInfo != nil {
 			response.ProfilePhotoURL = fileInfo.FileURL
+			response.ProfilePhotoThumbnails = dto.FileThumbnails(fileInfo)
 		}
 	}
 
@@ -95,7 +96,7 @@ func (c *UserController) GetUserByID(ctx *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param departmentId path int true "Department ID" Format(int64) minimum(1)
-// @Param role query string false "Filter by role (STUDENT, INSTRUCTOR)"
+// @Param role query string false "Filter by role (STUDENT, INSTRUCTOR, PENDING_INSTRUCTOR)"
 // @Success 200 {object} dto.APIResponse{data=[]dto.ExtendedUserResponse} "Users retrieved successfully"
 // @Failure 400 {object} dto.ErrorResponse "Invalid department ID format"
 // @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
@@ -177,6 +178,44 @@ func (c *UserController) GetUserProfile(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(c.mapUserToResponse(user)))
 }
 
+// GetStorageUsage retrieves the storage used by the authenticated user
+// @Summary Get storage usage
+// @Description Get how much storage the files uploaded by the currently authenticated user take up, by resource type, and the quota of their role. quotaBytes and remainingBytes are omitted when the quota is unlimited.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.APIResponse{data=dto.StorageUsageResponse} "Storage usage retrieved successfully"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Router /users/profile/storage [get]
+func (c *UserController) GetStorageUsage(ctx *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userIDInterface, exists := ctx.Get("userID")
+	if !exists {
+		errorDetail := dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")
+		errorDetail = errorDetail.WithDetails("User ID not found in request context")
+		ctx.JSON(http.StatusUnauthorized, dto.NewErrorResponse(errorDetail))
+		return
+	}
+
+	// Convert user ID to int64
+	userID, ok := userIDInterface.(int64)
+	if !ok {
+		errorDetail := dto.NewErrorDetail(dto.ErrorCodeInternalServer, "Invalid user ID format")
+		ctx.JSON(http.StatusInternalServerError, dto.NewErrorResponse(errorDetail))
+		return
+	}
+
+	usage, err := c.userService.GetStorageUsage(ctx, userID)
+	if err != nil {
+		middleware.HandleAPIError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(usage))
+}
+
 // UpdateUserProfile updates the user's profile information
 // @Summary Update user profile
 // @Description Update profile information for the currently authenticated user
@@ -279,7 +318,9 @@ func (c *UserController) UpdateProfilePhoto(ctx *gin.Context) {
 
 	// Return success response
 	response := dto.UpdateProfilePhotoResponse{
-		ProfilePhotoFileID: updatedFile.ID,
+		ProfilePhotoFileID:     updatedFile.ID,
+		ProfilePhotoURL:        updatedFile.FileURL,
+		ProfilePhotoThumbnails: dto.FileThumbnails(updatedFile),
 	}
 	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(response))
 }
@@ -325,17 +366,22 @@ func (c *UserController) DeleteProfilePhoto(ctx *gin.Context) {
 
 // GetAllUsers is an alias for GetUsersByFilter, but intended for admin use only
 // @Summary Get all users (Admin only)
-// @Description Retrieves a list of all users in the system. Only accessible by Admin users.
+// @Description Retrieves a list of all users in the system. Requires the user:manage permission.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param page query int false "Page number for pagination" Default(1) minimum(1)
 // @Param limit query int false "Number of items per page" Default(20) maximum(100)
-// @Param role query string false "Filter by role (STUDENT, INSTRUCTOR, ADMIN)"
+// @Param role query string false "Filter by role (STUDENT, INSTRUCTOR, ADMIN, PENDING_INSTRUCTOR)"
+// @Param after query string false "Cursor token (cursor.nextCursor) to get the page after it; page is ignored"
+// @Param before query string false "Cursor token (cursor.prevCursor) to get the page before it; page is ignored"
+// @Param fields query string false "Comma separated user fields to return (e.g. id,email,role); id is always included"
+// @Param expand query string false "Comma separated relations to include (department)"
 // @Success 200 {object} dto.APIResponse{data=dto.UserListResponse} "Users retrieved successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid fields or expand parameter"
 // @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
-// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have admin privileges"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the user:manage permission"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /admin/users [get]
 func (c *UserController) GetAllUsers(ctx *gin.Context) {
@@ -363,8 +409,19 @@ func (c *UserController) GetAllUsers(ctx *gin.Context) {
 		filter.Role = &role
 	}
 
+	// Cursor tokens take precedence over the page number
+	filter.After = ctx.Query("after")
+	filter.Before = ctx.Query("before")
+
+	// Parse sparse fieldset and relation expansion
+	projection, ok := bindProjection(ctx, dto.ExtendedUserResponse{}, dto.UserRelations)
+	if !ok {
+		return
+	}
+	filter.Projection = projection
+
 	// Get users by filter
-	users, total, err := c.userService.GetUsersByFilter(ctx, &filter)
+	users, total, cursorInfo, err := c.userService.GetUsersByFilter(ctx, &filter)
 	if err != nil {
 		middleware.HandleAPIError(ctx, err)
 		return
@@ -373,7 +430,16 @@ func (c *UserController) GetAllUsers(ctx *gin.Context) {
 	// Convert models to DTOs
 	userResponses := make([]dto.ExtendedUserResponse, 0, len(users))
 	for _, user := range users {
-		userResponses = append(userResponses, c.mapUserToResponse(user))
+		response := c.mapUserToResponse(user)
+		if user.Department != nil {
+			response.Department = &dto.DepartmentResponse{
+				ID:        user.Department.ID,
+				Name:      user.Department.Name,
+				Code:      user.Department.Code,
+				FacultyID: user.Department.FacultyID,
+			}
+		}
+		userResponses = append(userResponses, response)
 	}
 
 	// Create response with pagination
@@ -385,15 +451,16 @@ func (c *UserController) GetAllUsers(ctx *gin.Context) {
 			PageSize:    filter.PageSize,
 			TotalItems:  total,
 		},
+		Cursor: cursorInfo,
 	}
 
-	// Return users
-	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(response))
+	// Return users, reduced to the requested fields
+	ctx.JSON(http.StatusOK, dto.NewSuccessResponse(projection.ApplyList(response, "users")))
 }
 
-// DeleteUser deletes a user by ID (Admin only)
+// DeleteUser deletes a user by ID (user:manage only)
 // @Summary Delete user
-// @Description Deletes a user by ID. Only accessible by Admin users.
+// @Description Deletes a user by ID. Requires the user:manage permission.
 // @Tags users
 // @Accept json
 // @Produce json
@@ -402,7 +469,7 @@ func (c *UserController) GetAllUsers(ctx *gin.Context) {
 // @Success 200 {object} dto.APIResponse{data=dto.MessageResponse} "User deleted successfully"
 // @Failure 400 {object} dto.ErrorResponse "Invalid user ID format"
 // @Failure 401 {object} dto.ErrorResponse "Unauthorized - Invalid or missing token"
-// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have admin privileges"
+// @Failure 403 {object} dto.ErrorResponse "Forbidden - User does not have the user:manage permission"
 // @Failure 404 {object} dto.ErrorResponse "User not found"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Router /admin/users/{id} [delete]
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Actions throttled against brute force and inbox spam
+const (
+	ThrottleActionLogin              = "login"
+	ThrottleActionForgotPassword     = "forgot_password"
+	ThrottleActionResendVerification = "resend_verification"
+	ThrottleActionChangeEmail        = "change_email"
+)
+
+// AuthThrottle counts attempts at an action by one account or IP address
+type AuthThrottle struct {
+	Action          string     `json:"action" db:"action"`
+	Subject         string     `json:"subject" db:"subject"` // account:<email> or ip:<address>
+	Attempts        int        `json:"attempts" db:"attempts"`
+	WindowStartedAt time.Time  `json:"windowStartedAt" db:"window_started_at"`
+	BlockedUntil    *time.Time `json:"blockedUntil,omitempty" db:"blocked_until"`
+	LockedUntil     *time.Time `json:"lockedUntil,omitempty" db:"locked_until"`
+	UpdatedAt       time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// ThrottleAccountSubject is the subject of an account, by its email address
+func ThrottleAccountSubject(email string) string {
+	return "account:" + email
+}
+
+// ThrottleIPSubject is the subject of an IP address
+func ThrottleIPSubject(ip string) string {
+	return "ip:" + ip
+}
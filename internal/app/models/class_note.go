@@ -6,15 +6,17 @@ import "time"
 
 // ClassNote represents a class note in the database
 type ClassNote struct {
-	ID           int64     `db:"id"`
-	CourseCode   string    `db:"course_code"`
-	Title        string    `db:"title"`
-	Description  string    `db:"description"`
-	Content      string    `db:"content"`
-	DepartmentID int64     `db:"department_id"`
-	UserID       int64     `db:"user_id"`
-	CreatedAt    time.Time `db:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at"`
+	ID                 int64     `db:"id"`
+	CourseCode         string    `db:"course_code"`
+	Title              string    `db:"title"`
+	Description        string    `db:"description"`
+	Content            string    `db:"content"`              // Markdown source
+	ContentHTML        *string   `db:"content_html"`         // Sanitized HTML rendered from Content
+	ContentHTMLVersion *int      `db:"content_html_version"` // markdown.PolicyVersion ContentHTML was rendered with
+	DepartmentID       int64     `db:"department_id"`
+	UserID             int64     `db:"user_id"`
+	CreatedAt          time.Time `db:"created_at"`
+	UpdatedAt          time.Time `db:"updated_at"`
 	// İlişkisel alanlar
 	Files []*File `json:"files,omitempty"` // İlişkili dosyalar
 }
@@ -1,9 +1,12 @@
 package dto
 
+import "time"
+
 // LoginRequest represents login credentials
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email       string `json:"email" binding:"required,email"`
+	Password    string `json:"password" binding:"required"`
+	DeviceLabel string `json:"deviceLabel,omitempty" binding:"max=100" example:"Office laptop"` // Names the session; derived from the user agent when empty
 }
 
 // TokenResponse represents JWT token information
@@ -15,6 +18,102 @@ type TokenResponse struct {
 	RefreshTokenExpiresIn int64  `json:"refreshTokenExpiresIn,omitempty"`
 }
 
+// LoginResponse is the result of a password login: the token pair, or, when the user has
+// two-factor authentication enabled, a challenge token to exchange for it at /auth/login/mfa
+type LoginResponse struct {
+	*TokenResponse
+	MFARequired           bool   `json:"mfaRequired"`
+	MFAToken              string `json:"mfaToken,omitempty"`
+	MFATokenExpiresIn     int64  `json:"mfaTokenExpiresIn,omitempty"`
+	MFAEnrollmentRequired bool   `json:"mfaEnrollmentRequired,omitempty"` // The user's role requires 2FA, which is not set up yet
+}
+
+// MFALoginRequest completes a login that requires a second factor
+type MFALoginRequest struct {
+	MFAToken    string `json:"mfaToken" binding:"required"`
+	Code        string `json:"code" binding:"required" example:"123456"` // TOTP code or recovery code
+	DeviceLabel string `json:"deviceLabel,omitempty" binding:"max=100" example:"Office laptop"`
+}
+
+// SSOAuthorizationResponse starts a single sign-on login: the client sends the user to
+// AuthorizationURL, and the provider redirects back with a code and State
+type SSOAuthorizationResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+	State            string `json:"state"`
+	ExpiresIn        int64  `json:"expiresIn"` // Seconds left to come back with the code
+}
+
+// SSOCallbackRequest completes a single sign-on login with what the provider redirected back with
+type SSOCallbackRequest struct {
+	Code        string `json:"code" binding:"required"`
+	State       string `json:"state" binding:"required"`
+	DeviceLabel string `json:"deviceLabel,omitempty" binding:"max=100" example:"Office laptop"`
+}
+
+// ClientInfo describes the client a login or refresh comes from; it is recorded on the session
+type ClientInfo struct {
+	DeviceLabel string
+	UserAgent   string
+	IPAddress   string
+}
+
+// SessionResponse describes one of the user's sessions: a login and the refresh tokens issued from it
+type SessionResponse struct {
+	ID          int64     `json:"id"`
+	DeviceLabel string    `json:"deviceLabel" example:"Firefox on Windows"`
+	UserAgent   string    `json:"userAgent"`
+	IPAddress   string    `json:"ipAddress" example:"203.0.113.7"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LastUsedAt  time.Time `json:"lastUsedAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	Current     bool      `json:"current"` // The session of the access token used for the request
+}
+
+// CreatePersonalAccessTokenRequest creates a personal access token. Without expiresInDays the
+// token does not expire.
+type CreatePersonalAccessTokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100" example:"Grading script"`
+	Scopes        []string `json:"scopes" binding:"required,min=1,dive,required" example:"content:read,chat:send"`
+	ExpiresInDays int      `json:"expiresInDays" binding:"omitempty,min=1,max=365" example:"90"`
+}
+
+// PersonalAccessTokenResponse describes a personal access token, without the token itself
+type PersonalAccessTokenResponse struct {
+	ID         int64      `json:"id" example:"1"`
+	Name       string     `json:"name" example:"Grading script"`
+	TokenHint  string     `json:"tokenHint" example:"upt_Zk3q9w"` // Start of the token, to recognize it
+	Scopes     []string   `json:"scopes" example:"content:read,chat:send"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	Active     bool       `json:"active"` // Neither revoked nor expired
+}
+
+// CreatedPersonalAccessTokenResponse is a new personal access token with the token itself,
+// which is not shown again
+type CreatedPersonalAccessTokenResponse struct {
+	PersonalAccessTokenResponse
+	Token string `json:"token" example:"upt_Zk3q9wR2..."`
+}
+
+// LockedAccountResponse describes an account locked after too many failed logins
+type LockedAccountResponse struct {
+	UserID      int64     `json:"userId"`
+	Email       string    `json:"email"`
+	FirstName   string    `json:"firstName"`
+	LastName    string    `json:"lastName"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
+
+// LDAPSyncResponse reports what a directory sync changed
+type LDAPSyncResponse struct {
+	Checked     int `json:"checked"`     // Users linked to the directory
+	Updated     int `json:"updated"`     // Users whose names, department or role changed
+	Deactivated int `json:"deactivated"` // Users deactivated because their entry is gone
+	Reactivated int `json:"reactivated"` // Users deactivated earlier whose entry is back
+}
+
 // RefreshTokenRequest represents refresh token request
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refreshToken" binding:"required"`
@@ -37,14 +136,15 @@ type UpdateProfileRequest struct {
 
 // UserResponse represents basic user information
 type UserResponse struct {
-	ID                 int64  `json:"id"`
-	Email              string `json:"email"`
-	FirstName          string `json:"firstName"`
-	LastName           string `json:"lastName"`
-	Role               string `json:"role"`
-	DepartmentID       *int64 `json:"departmentId,omitempty"`
-	ProfilePhotoFileID *int64 `json:"profilePhotoFileId,omitempty"`
-	ProfilePhotoURL    string `json:"profilePhotoUrl,omitempty"`
+	ID                     int64             `json:"id"`
+	Email                  string            `json:"email"`
+	FirstName              string            `json:"firstName"`
+	LastName               string            `json:"lastName"`
+	Role                   string            `json:"role"`
+	DepartmentID           *int64            `json:"departmentId,omitempty"`
+	ProfilePhotoFileID     *int64            `json:"profilePhotoFileId,omitempty"`
+	ProfilePhotoURL        string            `json:"profilePhotoUrl,omitempty"`
+	ProfilePhotoThumbnails map[string]string `json:"profilePhotoThumbnails,omitempty"`
 }
 
 // AuthResponse represents successful authentication response
@@ -57,6 +157,9 @@ type AuthResponse struct {
 type RegisterResponse struct {
 	Message string `json:"message" example:"Verification email sent. Please check your inbox to complete registration."`
 	UserID  int64  `json:"userId" example:"123"`
+	// PendingApproval is true for accounts registered as instructors; they have the rights of
+	// a student until an admin approves them
+	PendingApproval bool `json:"pendingApproval" example:"false"`
 }
 
 // VerifyEmailRequest defines the request model for email verification
@@ -80,7 +183,50 @@ type ResetPasswordRequest struct {
 	NewPassword string `json:"newPassword" binding:"required,min=8"`
 }
 
+// ChangePasswordResponse carries a new token pair for the session the password was changed
+// from; the tokens it held before stop working like those of every other session
+type ChangePasswordResponse struct {
+	Message string         `json:"message" example:"Password changed. Your other sessions have been signed out and your personal access tokens revoked."`
+	Token   *TokenResponse `json:"token,omitempty"` // Missing when the request carried no session
+}
+
+// ChangeEmailRequest asks to change the email address of a logged-in user
+type ChangeEmailRequest struct {
+	NewEmail        string `json:"newEmail" binding:"required,email" example:"new.address@school.edu.tr"`
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+}
+
 // MessageResponse represents a simple message response
 type MessageResponse struct {
 	Message string `json:"message"`
 }
+
+// TOTPSetupResponse carries the secret of a TOTP enrollment in progress
+type TOTPSetupResponse struct {
+	Secret          string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	ProvisioningURI string `json:"provisioningUri" example:"otpauth://totp/UniSphere:user@school.edu.tr?secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP&issuer=UniSphere"` // Encode as a QR code for authenticator apps
+}
+
+// TOTPCodeRequest carries a code from the user's authenticator
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+// DisableTOTPRequest confirms turning two-factor authentication off
+type DisableTOTPRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required" example:"123456"` // TOTP code or recovery code
+}
+
+// RecoveryCodesResponse carries newly generated recovery codes; they are shown only once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes" example:"k7qm-3xta,p2dv-9rwe"`
+}
+
+// TwoFactorStatusResponse describes a user's two-factor authentication
+type TwoFactorStatusResponse struct {
+	Enabled                bool       `json:"enabled"`
+	Required               bool       `json:"required"` // The user's role requires 2FA
+	EnabledAt              *time.Time `json:"enabledAt,omitempty"`
+	RecoveryCodesRemaining int        `json:"recoveryCodesRemaining"`
+}
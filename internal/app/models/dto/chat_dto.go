@@ -20,6 +20,10 @@ type GetChatMessagesRequest struct {
 	After   *time.Time `form:"after,omitempty"`
 	Limit   int        `form:"limit,default=50" binding:"min=1,max=100"`
 	SenderID *int64    `form:"senderId,omitempty"`
+
+	// Cursor tokens; the before/after query parameters carry these instead of a timestamp
+	AfterCursor  string `form:"-"`
+	BeforeCursor string `form:"-"`
 }
 
 // --- Response DTOs ---
@@ -39,9 +43,10 @@ type ChatMessageResponse struct {
 	SenderName  string  `json:"senderName,omitempty"`
 	
 	// File information if available
-	FileName     *string `json:"fileName,omitempty"`
-	FileURL      *string `json:"fileUrl,omitempty"`
-	FileType     *string `json:"fileType,omitempty"`
+	FileName       *string           `json:"fileName,omitempty"`
+	FileURL        *string           `json:"fileUrl,omitempty"`
+	FileType       *string           `json:"fileType,omitempty"`
+	FileThumbnails map[string]string `json:"fileThumbnails,omitempty"`
 }
 
 // ChatMessageDetailResponse extends ChatMessageResponse with full sender and file details
@@ -53,11 +58,12 @@ type ChatMessageDetailResponse struct {
 
 // ChatFileResponse represents file details for chat messages
 type ChatFileResponse struct {
-	ID       int64  `json:"id"`
-	FileName string `json:"fileName"`
-	FileURL  string `json:"fileUrl"`
-	FileType string `json:"fileType"`
-	FileSize int64  `json:"fileSize"`
+	ID         int64             `json:"id"`
+	FileName   string            `json:"fileName"`
+	FileURL    string            `json:"fileUrl"`
+	FileType   string            `json:"fileType"`
+	FileSize   int64             `json:"fileSize"`
+	Thumbnails map[string]string `json:"thumbnails,omitempty"`
 }
 
 // ChatMessageListResponse represents a list of chat messages
@@ -86,12 +92,13 @@ func ToChatMessageResponse(message *models.ChatMessage) ChatMessageResponse {
 	// Add file information if a file is attached
 	if message.File != nil {
 		fileName := message.File.FileName
-		fileURL := message.File.FileURL
+		fileURL := FileAccessURL(message.File)
 		fileType := message.File.FileType
 		
 		response.FileName = &fileName
 		response.FileURL = &fileURL
 		response.FileType = &fileType
+		response.FileThumbnails = FileThumbnails(message.File)
 	}
 
 	return response
@@ -116,11 +123,12 @@ func ToChatMessageDetailResponse(message *models.ChatMessage) ChatMessageDetailR
 	// Add full file details if available
 	if message.File != nil {
 		response.File = &ChatFileResponse{
-			ID:       message.File.ID,
-			FileName: message.File.FileName,
-			FileURL:  message.File.FileURL,
-			FileType: message.File.FileType,
-			FileSize: message.File.FileSize,
+			ID:         message.File.ID,
+			FileName:   message.File.FileName,
+			FileURL:    FileAccessURL(message.File),
+			FileType:   message.File.FileType,
+			FileSize:   message.File.FileSize,
+			Thumbnails: FileThumbnails(message.File),
 		}
 	}
 
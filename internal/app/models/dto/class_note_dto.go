@@ -11,7 +11,7 @@ type CreateClassNoteRequest struct {
 	CourseCode   string `json:"courseCode" form:"courseCode" binding:"required"`
 	Title        string `json:"title" form:"title" binding:"required"`
 	Description  string `json:"description" form:"description" binding:"required"`
-	Content      string `json:"content" form:"content" binding:"required"`
+	Content      string `json:"content" form:"content" binding:"required"` // Markdown; raw HTML is removed
 	DepartmentID int64  `json:"departmentId" form:"departmentId" binding:"required,gt=0"`
 }
 
@@ -20,7 +20,7 @@ type UpdateClassNoteRequest struct {
 	CourseCode  string `json:"courseCode" binding:"required"`
 	Title       string `json:"title" binding:"required"`
 	Description string `json:"description" binding:"required"`
-	Content     string `json:"content" binding:"required"`
+	Content     string `json:"content" binding:"required"` // Markdown; raw HTML is removed
 }
 
 // --- Response DTOs ---
@@ -47,12 +47,19 @@ type ClassNoteResponse struct {
 	CourseCode   string                        `json:"courseCode"`
 	Title        string                        `json:"title"`
 	Description  string                        `json:"description"`
-	Content      string                        `json:"content"`
+	Content      string                        `json:"content"`               // Markdown source
+	ContentHTML  string                        `json:"contentHtml,omitempty"` // Sanitized HTML rendered from content; only with includeHtml=true
 	DepartmentID int64                         `json:"departmentId"`
 	UserID       int64                         `json:"userId"`
 	CreatedAt    time.Time                     `json:"createdAt"`
 	UpdatedAt    time.Time                     `json:"updatedAt"`
 	Files        []SimpleClassNoteFileResponse `json:"files,omitempty"`
+
+	// Only set on list responses when requested with expand=department
+	Department *DepartmentResponse `json:"department,omitempty"`
+
+	// Only set right after an upload when the uploaded files already exist elsewhere
+	DuplicateWarnings []DuplicateWarning `json:"duplicateWarnings,omitempty"`
 }
 
 // PaginationInfo is defined in response.go to avoid duplication
@@ -61,6 +68,7 @@ type ClassNoteResponse struct {
 type ClassNoteListResponse struct {
 	ClassNotes []ClassNoteResponse `json:"classNotes"`
 	PaginationInfo
+	Cursor *CursorInfo `json:"cursor,omitempty"`
 }
 
 // ClassNoteFilterRequest represents class note filter parameters
@@ -72,6 +80,17 @@ type ClassNoteFilterRequest struct {
 	PageSize     int     `form:"pageSize,default=10" binding:"min=1,max=100"`
 	SortBy       string  `form:"sortBy,default=created_at" binding:"omitempty,oneof=created_at updated_at title course_code"`
 	SortOrder    string  `form:"sortOrder,default=desc" binding:"omitempty,oneof=asc desc"`
+	After        string  `form:"after,omitempty"`       // Cursor token; returns the page after it
+	Before       string  `form:"before,omitempty"`      // Cursor token; returns the page before it
+	IncludeHTML  bool    `form:"includeHtml,omitempty"` // Whether to return contentHtml
+
+	Projection *Projection `form:"-"` // Parsed fields= and expand= parameters
+}
+
+// ClassNoteRelations are the relations of a class note that can be requested with expand=
+var ClassNoteRelations = Relations{
+	"files":      {Keys: []string{"files"}, Default: true},
+	"department": {Keys: []string{"department"}},
 }
 
 // --- Helper Functions ---
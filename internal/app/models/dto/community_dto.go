@@ -44,15 +44,16 @@ type CommunityParticipantResponse struct {
 
 // CommunityResponse represents basic community information
 type CommunityResponse struct {
-	ID                 int64   `json:"id"`
-	Name               string  `json:"name"`
-	Abbreviation       string  `json:"abbreviation"`
-	LeadID             int64   `json:"leadId"`
-	ProfilePhotoFileID *int64  `json:"profilePhotoFileId,omitempty"`
-	ProfilePhotoURL    *string `json:"profilePhotoUrl,omitempty"`
-	ParticipantCount   int     `json:"participantCount,omitempty"`
-	CreatedAt          time.Time `json:"createdAt"`
-	UpdatedAt          time.Time `json:"updatedAt"`
+	ID                     int64             `json:"id"`
+	Name                   string            `json:"name"`
+	Abbreviation           string            `json:"abbreviation"`
+	LeadID                 int64             `json:"leadId"`
+	ProfilePhotoFileID     *int64            `json:"profilePhotoFileId,omitempty"`
+	ProfilePhotoURL        *string           `json:"profilePhotoUrl,omitempty"`
+	ProfilePhotoThumbnails map[string]string `json:"profilePhotoThumbnails,omitempty"`
+	ParticipantCount       int               `json:"participantCount,omitempty"`
+	CreatedAt              time.Time         `json:"createdAt"`
+	UpdatedAt              time.Time         `json:"updatedAt"`
 }
 
 // CommunityDetailResponse extends CommunityResponse with participant details
@@ -67,6 +68,7 @@ type CommunityDetailResponse struct {
 type CommunityListResponse struct {
 	Communities []CommunityResponse `json:"communities"`
 	PaginationInfo
+	Cursor *CursorInfo `json:"cursor,omitempty"`
 }
 
 // CommunityFilterRequest represents community filter parameters
@@ -75,6 +77,10 @@ type CommunityFilterRequest struct {
 	Search   *string `form:"search,omitempty"` // For searching by name or abbreviation
 	Page     int     `form:"page,default=1" binding:"min=1"`
 	PageSize int     `form:"pageSize,default=10" binding:"min=1,max=100"`
+	After    string  `form:"after,omitempty"`  // Cursor token; returns the page after it
+	Before   string  `form:"before,omitempty"` // Cursor token; returns the page before it
+
+	Projection *Projection `form:"-"` // Parsed fields= parameter; communities have no expandable relations
 }
 
 // UserBasicResponse represents minimal user information for including in community responses
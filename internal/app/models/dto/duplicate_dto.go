@@ -0,0 +1,64 @@
+package dto
+
+import "time"
+
+// Duplicate match types
+const (
+	DuplicateMatchExact = "EXACT" // Byte-identical content (same SHA-256)
+	DuplicateMatchNear  = "NEAR"  // Similar extracted text (MinHash)
+)
+
+// DuplicateWarning tells the uploader that an uploaded file already exists elsewhere
+type DuplicateWarning struct {
+	FileID           int64   `json:"fileId" example:"42"`
+	FileName         string  `json:"fileName" example:"midterm.pdf"`
+	MatchType        string  `json:"matchType" example:"EXACT"`
+	Similarity       float64 `json:"similarity" example:"1"`
+	ExistingFileID   int64   `json:"existingFileId" example:"17"`
+	ExistingFileName string  `json:"existingFileName" example:"midterm-2023.pdf"`
+	ResourceType     string  `json:"resourceType" example:"PAST_EXAM"`
+	ResourceID       int64   `json:"resourceId" example:"5"`
+	ResourceURL      string  `json:"resourceUrl" example:"/api/v1/past-exams/5"`
+}
+
+// FilesAddedResponse is returned after attaching files to an existing resource
+type FilesAddedResponse struct {
+	Message           string             `json:"message" example:"2 files added to past exam successfully"`
+	DuplicateWarnings []DuplicateWarning `json:"duplicateWarnings,omitempty"`
+}
+
+// DuplicateClusterFile is a single file within a duplicate cluster
+type DuplicateClusterFile struct {
+	FileID       int64     `json:"fileId"`
+	FileName     string    `json:"fileName"`
+	FileSize     int64     `json:"fileSize"`
+	ResourceType string    `json:"resourceType"`
+	ResourceID   int64     `json:"resourceId"`
+	ResourceURL  string    `json:"resourceUrl"`
+	UploadedBy   int64     `json:"uploadedBy"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// DuplicateCluster is a group of files that are duplicates of each other
+type DuplicateCluster struct {
+	MatchType     string                 `json:"matchType"`
+	ContentHash   string                 `json:"contentHash,omitempty"`
+	MinSimilarity float64                `json:"minSimilarity"`
+	Files         []DuplicateClusterFile `json:"files"`
+}
+
+// DuplicateReportRequest selects a page of the duplicate report. The same page of both the
+// exact and the near-duplicate clusters is returned.
+type DuplicateReportRequest struct {
+	Page     int `form:"page,default=1" binding:"min=1"`
+	PageSize int `form:"pageSize,default=20" binding:"min=1,max=100"`
+}
+
+// DuplicateReportResponse lists duplicate clusters for admins to review and merge
+type DuplicateReportResponse struct {
+	ExactClusters   []DuplicateCluster `json:"exactClusters"`
+	ExactPagination PaginationInfo     `json:"exactPagination"`
+	NearClusters    []DuplicateCluster `json:"nearClusters"`
+	NearPagination  PaginationInfo     `json:"nearPagination"`
+	GeneratedAt     time.Time          `json:"generatedAt"`
+}
@@ -13,6 +13,10 @@ const (
 	ErrorCodeExpiredToken          ErrorCode = "AUTH_006"
 	ErrorCodeTokenNotFound         ErrorCode = "AUTH_007"
 	ErrorCodeUnauthorized          ErrorCode = "AUTH_008"
+	ErrorCodeInvalidMFACode        ErrorCode = "AUTH_009"
+	ErrorCodeMFARequired           ErrorCode = "AUTH_010"
+	ErrorCodeTooManyAttempts       ErrorCode = "AUTH_011"
+	ErrorCodeAccountLocked         ErrorCode = "AUTH_012"
 	ErrorCodeResourceNotFound      ErrorCode = "RES_001"
 	ErrorCodeResourceAlreadyExists ErrorCode = "RES_002"
 	ErrorCodeResourceInvalid       ErrorCode = "RES_003"
@@ -24,6 +28,10 @@ const (
 	ErrorCodeBadRequest            ErrorCode = "BAD_REQUEST"
 	ErrorCodeForbidden             ErrorCode = "FORBIDDEN"
 	ErrorCodeInvalidRequest        ErrorCode = "INVALID_REQUEST"
+	ErrorCodeFileTypeNotAllowed    ErrorCode = "FILE_001"
+	ErrorCodeFileTooLarge          ErrorCode = "FILE_002"
+	ErrorCodeTooManyFiles          ErrorCode = "FILE_003"
+	ErrorCodeStorageQuotaExceeded  ErrorCode = "FILE_004"
 )
 
 // ErrorSeverity represents the severity level of an error
@@ -7,6 +7,14 @@ const (
 	RoleStudent    RoleType = "STUDENT"
 	RoleInstructor RoleType = "INSTRUCTOR"
 	RoleAdmin      RoleType = "ADMIN"
+
+	// RolePendingInstructor is held by accounts waiting for an admin to confirm them as
+	// instructors; until then they have the rights of a student
+	RolePendingInstructor RoleType = "PENDING_INSTRUCTOR"
+
+	// RoleModerator is never the role of an account; it is only granted through role
+	// assignments, usually limited to a department or community
+	RoleModerator RoleType = "MODERATOR"
 )
 
 // Term represents a semester term
@@ -28,6 +28,10 @@ var (
 	ErrorCodeExpiredToken          = enums.ErrorCodeExpiredToken
 	ErrorCodeTokenNotFound         = enums.ErrorCodeTokenNotFound
 	ErrorCodeUnauthorized          = enums.ErrorCodeUnauthorized
+	ErrorCodeInvalidMFACode        = enums.ErrorCodeInvalidMFACode
+	ErrorCodeMFARequired           = enums.ErrorCodeMFARequired
+	ErrorCodeTooManyAttempts       = enums.ErrorCodeTooManyAttempts
+	ErrorCodeAccountLocked         = enums.ErrorCodeAccountLocked
 	ErrorCodeResourceNotFound      = enums.ErrorCodeResourceNotFound
 	ErrorCodeResourceAlreadyExists = enums.ErrorCodeResourceAlreadyExists
 	ErrorCodeResourceInvalid       = enums.ErrorCodeResourceInvalid
@@ -39,6 +43,10 @@ var (
 	ErrorCodeBadRequest            = enums.ErrorCodeBadRequest
 	ErrorCodeForbidden             = enums.ErrorCodeForbidden
 	ErrorCodeInvalidRequest        = enums.ErrorCodeInvalidRequest
+	ErrorCodeFileTypeNotAllowed    = enums.ErrorCodeFileTypeNotAllowed
+	ErrorCodeFileTooLarge          = enums.ErrorCodeFileTooLarge
+	ErrorCodeTooManyFiles          = enums.ErrorCodeTooManyFiles
+	ErrorCodeStorageQuotaExceeded  = enums.ErrorCodeStorageQuotaExceeded
 	
 	ErrorSeverityInfo     = enums.ErrorSeverityInfo
 	ErrorSeverityWarning  = enums.ErrorSeverityWarning
@@ -1,5 +1,13 @@
 package dto
 
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/yigit/unisphere/internal/app/models"
+)
+
 // FileType represents the type of file
 type FileType string
 
@@ -23,3 +31,92 @@ type FileResponse struct {
 type FileUploadResponse struct {
 	FileID string `json:"fileId" example:"123456"`
 }
+
+// FileBundleEntry is a single file inside a downloadable ZIP bundle
+type FileBundleEntry struct {
+	ArchivePath string    // Path inside the archive, e.g. 2023/FALL/Midterm/questions.pdf
+	FilePath    string    // Path of the file in storage
+	ModifiedAt  time.Time // Timestamp recorded in the archive
+}
+
+// FileBundle describes a ZIP archive of attached files that is streamed to the client
+type FileBundle struct {
+	FileName string // Name offered in Content-Disposition
+	Entries  []FileBundleEntry
+}
+
+// SignedFileURLResponse is a short-lived URL that downloads a file without an Authorization header
+// @Description Expiring signed download URL, usable in browsers and <img> tags
+type SignedFileURLResponse struct {
+	URL       string    `json:"url" example:"/api/v1/files/42/content?expires=1767225600&signature=..."`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SignedFileURLRequest controls the lifetime of a signed download URL
+type SignedFileURLRequest struct {
+	ExpiresIn int `form:"expiresIn" binding:"omitempty,min=1"` // Seconds; capped by the server
+}
+
+// QuarantinedFileResponse is an upload the malware scanner found infected
+// @Description Infected upload moved to quarantine
+type QuarantinedFileResponse struct {
+	ID           int64     `json:"id" example:"42"`
+	FileName     string    `json:"fileName" example:"notes.pdf"`
+	FileSize     int64     `json:"fileSize" example:"68"`
+	FileType     string    `json:"fileType" example:"application/pdf"`
+	ResourceType string    `json:"resourceType" example:"CLASS_NOTE"`
+	ResourceID   int64     `json:"resourceId" example:"7"`
+	UploadedBy   int64     `json:"uploadedBy" example:"15"`
+	Signature    string    `json:"signature" example:"Eicar-Test-Signature"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// NewQuarantinedFileResponse maps an infected file to its response
+func NewQuarantinedFileResponse(file *models.File) QuarantinedFileResponse {
+	response := QuarantinedFileResponse{
+		ID:           file.ID,
+		FileName:     file.FileName,
+		FileSize:     file.FileSize,
+		FileType:     file.FileType,
+		ResourceType: string(file.ResourceType),
+		ResourceID:   file.ResourceID,
+		UploadedBy:   file.UploadedBy,
+		CreatedAt:    file.CreatedAt,
+	}
+	if file.ScanSignature != nil {
+		response.Signature = *file.ScanSignature
+	}
+	return response
+}
+
+// FileDownloadPath is the authorized download endpoint of a file
+func FileDownloadPath(fileID int64) string {
+	return fmt.Sprintf("/api/v1/files/%d/download", fileID)
+}
+
+// FileAccessURL returns the URL clients should use for a stored file.
+// Profile photos stay public; every other file goes through the authorized download endpoint.
+func FileAccessURL(file *models.File) string {
+	if file.ResourceType.IsPublic() {
+		return file.FileURL
+	}
+	return FileDownloadPath(file.ID)
+}
+
+// FileThumbnails returns the thumbnail URLs of a file keyed by size in pixels, or nil when
+// it has none. Like FileAccessURL, thumbnails of private files go through the download endpoint.
+func FileThumbnails(file *models.File) map[string]string {
+	if file == nil || len(file.ThumbnailSizes) == 0 {
+		return nil
+	}
+
+	thumbnails := make(map[string]string, len(file.ThumbnailSizes))
+	for _, size := range file.ThumbnailSizes {
+		if file.ResourceType.IsPublic() {
+			thumbnails[strconv.Itoa(size)] = file.ThumbnailURL(size)
+		} else {
+			thumbnails[strconv.Itoa(size)] = fmt.Sprintf("%s?size=%d", FileDownloadPath(file.ID), size)
+		}
+	}
+	return thumbnails
+}
@@ -28,12 +28,19 @@ type PastExamResponse struct {
 	Year         int       `json:"year"`
 	Term         string    `json:"term"`
 	Title        string    `json:"title"`
-	Content      string    `json:"content"`
+	Content      string    `json:"content"`               // Markdown source
+	ContentHTML  string    `json:"contentHtml,omitempty"` // Sanitized HTML rendered from content; only with includeHtml=true
 	DepartmentID int64     `json:"departmentId"`
 	InstructorID int64     `json:"instructorId"`
 	FileIDs      []int64   `json:"fileIds,omitempty"`
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
+
+	// Only set on list responses when requested with expand=department
+	Department *DepartmentResponse `json:"department,omitempty"`
+
+	// Only set right after an upload when the uploaded files already exist elsewhere
+	DuplicateWarnings []DuplicateWarning `json:"duplicateWarnings,omitempty"`
 }
 
 // CreatePastExamRequest represents past exam creation data
@@ -42,7 +49,7 @@ type CreatePastExamRequest struct {
 	Year         int    `json:"year" form:"year" binding:"required,gt=1900"`
 	Term         string `json:"term" form:"term" binding:"required,oneof=FALL SPRING"`
 	Title        string `json:"title" form:"title" binding:"required"`
-	Content      string `json:"content" form:"content" binding:"omitempty"` // Make Content optional
+	Content      string `json:"content" form:"content" binding:"omitempty"` // Markdown, raw HTML removed; optional
 	DepartmentID int64  `json:"departmentId" form:"departmentId" binding:"required,gt=0"`
 }
 
@@ -52,13 +59,14 @@ type UpdatePastExamRequest struct {
 	Year       int    `json:"year" form:"year" binding:"required,gt=1900"`
 	Term       string `json:"term" form:"term" binding:"required,oneof=FALL SPRING"`
 	Title      string `json:"title" form:"title" binding:"required"`
-	Content    string `json:"content" form:"content" binding:"omitempty"` // Make Content optional
+	Content    string `json:"content" form:"content" binding:"omitempty"` // Markdown, raw HTML removed; optional
 }
 
 // PastExamListResponse represents a list of past exams
 type PastExamListResponse struct {
 	PastExams []PastExamResponse `json:"pastExams"`
 	PaginationInfo
+	Cursor *CursorInfo `json:"cursor,omitempty"`
 }
 
 // PastExamFilterRequest represents past exam filter parameters
@@ -71,4 +79,22 @@ type PastExamFilterRequest struct {
 	InstructorID *int64  `form:"instructorId,omitempty"`
 	Page         int     `form:"page,default=1" binding:"min=1"`
 	PageSize     int     `form:"pageSize,default=10" binding:"min=1,max=100"`
+	After        string  `form:"after,omitempty"`       // Cursor token; returns the page after it
+	Before       string  `form:"before,omitempty"`      // Cursor token; returns the page before it
+	IncludeHTML  bool    `form:"includeHtml,omitempty"` // Whether to return contentHtml
+
+	Projection *Projection `form:"-"` // Parsed fields= and expand= parameters
+}
+
+// PastExamRelations are the relations of a past exam that can be requested with expand=
+var PastExamRelations = Relations{
+	"files":      {Keys: []string{"fileIds"}, Default: true},
+	"department": {Keys: []string{"department"}},
+}
+
+// CourseBundleRequest selects every past exam of a course, optionally within a year range
+type CourseBundleRequest struct {
+	CourseCode string `form:"courseCode" binding:"required"`
+	FromYear   *int   `form:"fromYear" binding:"omitempty,gt=1900"`
+	ToYear     *int   `form:"toYear" binding:"omitempty,gt=1900"`
 }
@@ -0,0 +1,178 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Relation describes an expandable relation of a list item
+type Relation struct {
+	Keys    []string // JSON keys of the item that the relation populates
+	Default bool     // Whether the relation is included when expand= is not given
+}
+
+// Relations maps the names accepted by expand= to the relations of a list item
+type Relations map[string]Relation
+
+// Projection is a parsed sparse fieldset (fields=) and relation expansion (expand=) request.
+// A nil *Projection selects every field and the default relations.
+type Projection struct {
+	fields    map[string]struct{}
+	expand    map[string]struct{}
+	relations Relations
+}
+
+// NewProjection parses comma separated fields and expand values and validates them
+// against the JSON field names of item and the given relations
+func NewProjection(fields, expand string, item interface{}, relations Relations) (*Projection, error) {
+	p := &Projection{relations: relations}
+
+	if expand != "" {
+		p.expand = make(map[string]struct{})
+		for _, name := range splitList(expand) {
+			if len(relations) == 0 {
+				return nil, fmt.Errorf("expand is not supported on this list")
+			}
+			if _, ok := relations[name]; !ok {
+				return nil, fmt.Errorf("unknown expand value %q (allowed: %s)", name, strings.Join(relations.names(), ", "))
+			}
+			p.expand[name] = struct{}{}
+		}
+	}
+
+	if fields != "" {
+		known := jsonFieldNames(reflect.TypeOf(item))
+		p.fields = make(map[string]struct{})
+		for _, name := range splitList(fields) {
+			if _, ok := known[name]; !ok {
+				return nil, fmt.Errorf("unknown field %q", name)
+			}
+			p.fields[name] = struct{}{}
+		}
+	}
+
+	return p, nil
+}
+
+// Expands reports whether the relation should be loaded and returned
+func (p *Projection) Expands(relation string) bool {
+	if p == nil {
+		return false
+	}
+	if p.expand == nil {
+		return p.relations[relation].Default
+	}
+	_, ok := p.expand[relation]
+	return ok
+}
+
+// ExpandsOr is Expands for callers without a projection, falling back to byDefault
+func (p *Projection) ExpandsOr(relation string, byDefault bool) bool {
+	if p == nil {
+		return byDefault
+	}
+	return p.Expands(relation)
+}
+
+// ApplyList reduces every item of the list stored under listKey in response to the
+// requested fields. Other keys such as pagination info are left untouched. The id and
+// the keys of expanded relations are always kept.
+func (p *Projection) ApplyList(response interface{}, listKey string) interface{} {
+	if p == nil || p.fields == nil {
+		return response
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return response
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(data, &body); err != nil {
+		return response
+	}
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(body[listKey], &items); err != nil {
+		return response
+	}
+
+	keep := map[string]struct{}{"id": {}}
+	for name := range p.fields {
+		keep[name] = struct{}{}
+	}
+	for name, relation := range p.relations {
+		if p.Expands(name) {
+			for _, key := range relation.Keys {
+				keep[key] = struct{}{}
+			}
+		}
+	}
+
+	for _, item := range items {
+		for key := range item {
+			if _, ok := keep[key]; !ok {
+				delete(item, key)
+			}
+		}
+	}
+
+	projected, err := json.Marshal(items)
+	if err != nil {
+		return response
+	}
+	body[listKey] = projected
+	return body
+}
+
+func (r Relations) names() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitList splits a comma separated query value, dropping blanks
+func splitList(value string) []string {
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// jsonFieldNames returns the JSON names of a struct's fields, including embedded ones
+func jsonFieldNames(t reflect.Type) map[string]struct{} {
+	names := make(map[string]struct{})
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return names
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			for embedded := range jsonFieldNames(field.Type) {
+				names[embedded] = struct{}{}
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = struct{}{}
+	}
+	return names
+}
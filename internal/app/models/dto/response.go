@@ -36,3 +36,13 @@ type PaginationInfo struct {
 	PageSize    int   `json:"pageSize" example:"10"`   // Number of items per page
 	TotalItems  int64 `json:"totalItems" example:"48"` // Total number of items matching the query
 }
+
+// CursorInfo carries opaque keyset pagination tokens for list responses.
+// Pass NextCursor as `after` or PrevCursor as `before` to move between pages;
+// unlike page numbers, cursors stay stable while items are being added.
+type CursorInfo struct {
+	NextCursor string `json:"nextCursor,omitempty"` // Token for the following page
+	PrevCursor string `json:"prevCursor,omitempty"` // Token for the preceding page
+	HasNext    bool   `json:"hasNext"`              // Whether a following page exists
+	HasPrev    bool   `json:"hasPrev"`              // Whether a preceding page exists
+}
@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+// RoleResponse describes a role, the permissions it grants and the scopes it can be assigned in
+type RoleResponse struct {
+	Role             string   `json:"role" example:"MODERATOR"`
+	Permissions      []string `json:"permissions" example:"pastexam:manage,community:moderate"`
+	AssignableScopes []string `json:"assignableScopes" example:"global,faculty,department,community"` // Empty if the role cannot be assigned
+}
+
+// RoleAssignmentResponse is a role granted to a user within a scope
+type RoleAssignmentResponse struct {
+	ID        int64     `json:"id" example:"1"`
+	UserID    int64     `json:"userId" example:"42"`
+	Role      string    `json:"role" example:"INSTRUCTOR"`
+	ScopeType string    `json:"scopeType" example:"department"`
+	ScopeID   *int64    `json:"scopeId,omitempty" example:"3"` // Absent for global assignments
+	GrantedBy *int64    `json:"grantedBy,omitempty" example:"1"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CreateRoleAssignmentRequest grants a user a role within a scope. ScopeID is required unless
+// the scope is global.
+type CreateRoleAssignmentRequest struct {
+	UserID    int64  `json:"userId" binding:"required,gt=0" example:"42"`
+	Role      string `json:"role" binding:"required" example:"INSTRUCTOR"`
+	ScopeType string `json:"scopeType" binding:"required,oneof=global faculty department community" example:"department"`
+	ScopeID   *int64 `json:"scopeId" binding:"omitempty,gt=0" example:"3"`
+}
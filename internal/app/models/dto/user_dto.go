@@ -1,20 +1,26 @@
 package dto
 
+import "time"
+
 // NOTE: UserResponse is already defined in auth_dto.go
 // We'll create an extended version with additional fields
 
 // ExtendedUserResponse represents detailed user information
 // Extends the basic UserResponse with additional fields
 type ExtendedUserResponse struct {
-	ID                 int64  `json:"id"`
-	Email              string `json:"email"`
-	FirstName          string `json:"firstName"`
-	LastName           string `json:"lastName"`
-	Role               string `json:"role"`
-	DepartmentID       *int64 `json:"departmentId,omitempty"`
-	ProfilePhotoFileID *int64 `json:"profilePhotoFileId,omitempty"`
-	ProfilePhotoURL    string `json:"profilePhotoUrl,omitempty"`
-	IsActive           bool   `json:"isActive"`
+	ID                     int64             `json:"id"`
+	Email                  string            `json:"email"`
+	FirstName              string            `json:"firstName"`
+	LastName               string            `json:"lastName"`
+	Role                   string            `json:"role"`
+	DepartmentID           *int64            `json:"departmentId,omitempty"`
+	ProfilePhotoFileID     *int64            `json:"profilePhotoFileId,omitempty"`
+	ProfilePhotoURL        string            `json:"profilePhotoUrl,omitempty"`
+	ProfilePhotoThumbnails map[string]string `json:"profilePhotoThumbnails,omitempty"`
+	IsActive               bool              `json:"isActive"`
+
+	// Only set on list responses when requested with expand=department
+	Department *DepartmentResponse `json:"department,omitempty"`
 }
 
 // UserFilterRequest represents user filtering parameters
@@ -25,19 +31,30 @@ type UserFilterRequest struct {
 	Name         *string `form:"name,omitempty"` // For searching by first or last name
 	Page         int     `form:"page,default=1" binding:"min=1"`
 	PageSize     int     `form:"pageSize,default=10" binding:"min=1,max=100"`
+	After        string  `form:"after,omitempty"`  // Cursor token; returns the page after it
+	Before       string  `form:"before,omitempty"` // Cursor token; returns the page before it
+
+	Projection *Projection `form:"-"` // Parsed fields= and expand= parameters
+}
+
+// UserRelations are the relations of a user that can be requested with expand=
+var UserRelations = Relations{
+	"department": {Keys: []string{"department"}},
 }
 
 // UserListResponse represents a list of users with pagination
 type UserListResponse struct {
 	Users []ExtendedUserResponse `json:"users"`
 	PaginationInfo
+	Cursor *CursorInfo `json:"cursor,omitempty"`
 }
 
 // UpdateUserRequest represents user update data
 type UpdateUserRequest struct {
 	FirstName string `json:"firstName" binding:"required"`
 	LastName  string `json:"lastName" binding:"required"`
-	// Email değişimi engellendi - kullanıcılar email'lerini değiştiremezler
+	// Email and password changes need the current password and have endpoints of their own;
+	// a password here is refused rather than ignored
 	Password *string `json:"password,omitempty"`
 }
 
@@ -49,5 +66,44 @@ type ChangePasswordRequest struct {
 
 // UpdateProfilePhotoResponse represents a successful profile photo update
 type UpdateProfilePhotoResponse struct {
-	ProfilePhotoFileID int64 `json:"profilePhotoFileId"`
+	ProfilePhotoFileID     int64             `json:"profilePhotoFileId"`
+	ProfilePhotoURL        string            `json:"profilePhotoUrl"`
+	ProfilePhotoThumbnails map[string]string `json:"profilePhotoThumbnails,omitempty"`
+}
+
+// StorageUsageResponse shows how much storage a user uses against their quota
+type StorageUsageResponse struct {
+	UsedBytes      int64                  `json:"usedBytes" example:"52428800"`
+	QuotaBytes     *int64                 `json:"quotaBytes" example:"1073741824"`     // null when unlimited
+	RemainingBytes *int64                 `json:"remainingBytes" example:"1021313024"` // null when unlimited
+	ByResourceType []ResourceStorageUsage `json:"byResourceType"`
+}
+
+// ResourceStorageUsage is the storage used by the files of one resource type
+type ResourceStorageUsage struct {
+	ResourceType string `json:"resourceType" example:"PAST_EXAM"`
+	FileCount    int    `json:"fileCount" example:"4"`
+	UsedBytes    int64  `json:"usedBytes" example:"41943040"`
+}
+
+// InstructorVerificationResponse is an account in the instructor review queue
+type InstructorVerificationResponse struct {
+	UserID          int64      `json:"userId" example:"42"`
+	Email           string     `json:"email" example:"jane.doe@school.edu.tr"`
+	FirstName       string     `json:"firstName" example:"Jane"`
+	LastName        string     `json:"lastName" example:"Doe"`
+	Role            string     `json:"role" example:"PENDING_INSTRUCTOR"`
+	EmailVerified   bool       `json:"emailVerified"`
+	DepartmentID    *int64     `json:"departmentId,omitempty"`
+	Status          string     `json:"status" example:"PENDING"` // PENDING, APPROVED or REJECTED
+	RequestedAt     time.Time  `json:"requestedAt"`
+	ReviewedBy      *int64     `json:"reviewedBy,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewedAt,omitempty"`
+	RejectionReason *string    `json:"rejectionReason,omitempty"`
+}
+
+// RejectInstructorRequest gives the reason an account is not confirmed as an instructor; it
+// is sent to the user
+type RejectInstructorRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000" example:"We could not find you in the staff directory of your department."`
 }
@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // FileType represents the type of file
 type FileType string
@@ -14,19 +19,96 @@ const (
 	FileTypeChatMessage           FileType = "CHAT_MESSAGE"
 )
 
+// ScanStatus is the state of a file's malware scan
+type ScanStatus string
+
+const (
+	ScanStatusPending  ScanStatus = "PENDING"  // Stored, not scanned yet; cannot be downloaded
+	ScanStatusClean    ScanStatus = "CLEAN"    // No malware found
+	ScanStatusInfected ScanStatus = "INFECTED" // Malware found; the file was moved to quarantine
+)
+
+// IsPublic reports whether files of this type may be served without authorization.
+// Only profile photos are public; they are shown to anyone who can see the profile.
+func (t FileType) IsPublic() bool {
+	return t == FileTypeProfilePhoto || t == FileTypeCommunityProfilePhoto
+}
+
 // File represents a file in the system
 type File struct {
-	ID           int64     `json:"id" db:"id"`
-	FileName     string    `json:"fileName" db:"file_name"`
-	FilePath     string    `json:"filePath" db:"file_path"`
-	FileURL      string    `json:"fileUrl" db:"file_url"`
-	FileSize     int64     `json:"fileSize" db:"file_size"`
-	FileType     string    `json:"fileType" db:"file_type"` // MIME type
-	ResourceType FileType  `json:"resourceType" db:"resource_type"`
-	ResourceID   int64     `json:"resourceId" db:"resource_id"`
-	UploadedBy   int64     `json:"uploadedBy" db:"uploaded_by"`
-	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
+	ID             int64      `json:"id" db:"id"`
+	FileName       string     `json:"fileName" db:"file_name"`
+	FilePath       string     `json:"filePath" db:"file_path"`
+	FileURL        string     `json:"fileUrl" db:"file_url"`
+	FileSize       int64      `json:"fileSize" db:"file_size"`
+	FileType       string     `json:"fileType" db:"file_type"` // MIME type
+	ResourceType   FileType   `json:"resourceType" db:"resource_type"`
+	ResourceID     int64      `json:"resourceId" db:"resource_id"`
+	UploadedBy     int64      `json:"uploadedBy" db:"uploaded_by"`
+	ContentHash    *string    `json:"contentHash,omitempty" db:"content_hash"`       // SHA-256 of the content
+	ThumbnailSizes []int      `json:"thumbnailSizes,omitempty" db:"thumbnail_sizes"` // Sizes of the thumbnails stored next to the file
+	ScanStatus     ScanStatus `json:"scanStatus" db:"scan_status"`
+	ScanSignature  *string    `json:"scanSignature,omitempty" db:"scan_signature"` // Malware found in an infected file
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// HasThumbnail reports whether a thumbnail of the given size was stored for the file
+func (f *File) HasThumbnail(size int) bool {
+	for _, s := range f.ThumbnailSizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// ThumbnailPath returns the storage path of the thumbnail of the given size.
+// Thumbnails sit next to the file: "dir/name.jpg" has "dir/name_256.jpg".
+func (f *File) ThumbnailPath(size int) string {
+	return thumbnailName(f.FilePath, size)
+}
+
+// ThumbnailURL returns the URL of the thumbnail of the given size
+func (f *File) ThumbnailURL(size int) string {
+	return thumbnailName(f.FileURL, size)
+}
+
+// Thumbnail returns a copy of the file that points at its thumbnail of the given size,
+// or nil when no such thumbnail was stored
+func (f *File) Thumbnail(size int) *File {
+	if !f.HasThumbnail(size) {
+		return nil
+	}
+
+	thumbnail := *f
+	thumbnail.FileName = thumbnailName(f.FileName, size)
+	thumbnail.FilePath = f.ThumbnailPath(size)
+	thumbnail.FileURL = f.ThumbnailURL(size)
+	thumbnail.FileSize = 0 // Not recorded for thumbnails
+	thumbnail.ThumbnailSizes = nil
+	return &thumbnail
+}
+
+func thumbnailName(p string, size int) string {
+	ext := path.Ext(p)
+	return strings.TrimSuffix(p, ext) + "_" + strconv.Itoa(size) + ext
+}
+
+// ParseThumbnailPath splits a thumbnail path into the path of its file and the thumbnail size.
+// ok is false when p does not name a thumbnail.
+func ParseThumbnailPath(p string) (filePath string, size int, ok bool) {
+	ext := path.Ext(p)
+	stem := strings.TrimSuffix(p, ext)
+	i := strings.LastIndex(stem, "_")
+	if i < 0 || strings.Contains(stem[i:], "/") {
+		return "", 0, false
+	}
+	size, err := strconv.Atoi(stem[i+1:])
+	if err != nil || size <= 0 {
+		return "", 0, false
+	}
+	return stem[:i] + ext, size, true
 }
 
 // PastExamFile represents the association between past exams and files
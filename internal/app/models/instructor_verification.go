@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Statuses of an instructor verification
+const (
+	InstructorVerificationPending  = "PENDING"
+	InstructorVerificationApproved = "APPROVED"
+	InstructorVerificationRejected = "REJECTED"
+)
+
+// InstructorVerification is an admin's review of an account that registered as
+// PENDING_INSTRUCTOR. Approving it makes the account an instructor, rejecting it a student.
+type InstructorVerification struct {
+	UserID          int64      `json:"userId" db:"user_id"`
+	Status          string     `json:"status" db:"status"`
+	RequestedAt     time.Time  `json:"requestedAt" db:"requested_at"`
+	ReviewedBy      *int64     `json:"reviewedBy,omitempty" db:"reviewed_by"`
+	ReviewedAt      *time.Time `json:"reviewedAt,omitempty" db:"reviewed_at"`
+	RejectionReason *string    `json:"rejectionReason,omitempty" db:"rejection_reason"`
+
+	// Relations (populated when needed)
+	User *User `json:"user,omitempty"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// JWTSigningKey is a stored key pair access tokens are signed with
+type JWTSigningKey struct {
+	KID        string    `json:"kid" db:"kid"`
+	Algorithm  string    `json:"algorithm" db:"algorithm"`
+	PrivateKey string    `json:"-" db:"private_key"` // Encrypted
+	PublicKey  string    `json:"publicKey" db:"public_key"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+}
@@ -16,9 +16,11 @@ type Term = enums.Term
 
 // Constants for backward compatibility
 var (
-	RoleStudent    = enums.RoleStudent
-	RoleInstructor = enums.RoleInstructor
-	RoleAdmin      = enums.RoleAdmin
-	TermFall       = enums.TermFall
-	TermSpring     = enums.TermSpring
+	RoleStudent           = enums.RoleStudent
+	RoleInstructor        = enums.RoleInstructor
+	RoleAdmin             = enums.RoleAdmin
+	RolePendingInstructor = enums.RolePendingInstructor
+	RoleModerator         = enums.RoleModerator
+	TermFall              = enums.TermFall
+	TermSpring            = enums.TermSpring
 )
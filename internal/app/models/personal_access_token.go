@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// PersonalAccessToken is a named, scoped token a user created for an integration to call the
+// API as them. Only its hash is stored.
+type PersonalAccessToken struct {
+	ID         int64      `json:"id" db:"id"`
+	UserID     int64      `json:"userId" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	TokenHint  string     `json:"tokenHint" db:"token_hint"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
+}
+
+// Active reports whether the token can still be used at a time
+func (t *PersonalAccessToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RevokedAccessToken is an access token denylisted by its jti until it expires
+type RevokedAccessToken struct {
+	JTI       string    `json:"jti" db:"jti"`
+	UserID    int64     `json:"userId" db:"user_id"`
+	ExpiresAt time.Time `json:"expiresAt" db:"expires_at"`
+	RevokedAt time.Time `json:"revokedAt" db:"revoked_at"`
+}
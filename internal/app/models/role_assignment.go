@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Scope types of a role assignment
+const (
+	ScopeGlobal     = "global"
+	ScopeFaculty    = "faculty"
+	ScopeDepartment = "department"
+	ScopeCommunity  = "community"
+)
+
+// RoleAssignment grants a user a role within a scope, on top of the role of their account.
+// ScopeID is nil for global assignments.
+type RoleAssignment struct {
+	ID        int64     `json:"id" db:"id"`
+	UserID    int64     `json:"userId" db:"user_id"`
+	Role      RoleType  `json:"role" db:"role"`
+	ScopeType string    `json:"scopeType" db:"scope_type"`
+	ScopeID   *int64    `json:"scopeId,omitempty" db:"scope_id"`
+	GrantedBy *int64    `json:"grantedBy,omitempty" db:"granted_by"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
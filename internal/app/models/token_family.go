@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Reasons a token family was revoked
+const (
+	TokenFamilySignedOut          = "SIGNED_OUT"
+	TokenFamilyReuseDetected      = "REUSE_DETECTED"
+	TokenFamilyCredentialsChanged = "CREDENTIALS_CHANGED" // The password or email address changed
+)
+
+// TokenFamily groups the refresh tokens issued from one login: each refresh rotates the
+// family's token. Users see their families as device sessions.
+type TokenFamily struct {
+	ID            int64      `json:"id" db:"id"`
+	UserID        int64      `json:"userId" db:"user_id"`
+	DeviceLabel   string     `json:"deviceLabel" db:"device_label"`
+	UserAgent     string     `json:"userAgent" db:"user_agent"`
+	IPAddress     string     `json:"ipAddress" db:"ip_address"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+	LastUsedAt    time.Time  `json:"lastUsedAt" db:"last_used_at"`
+	ExpiresAt     time.Time  `json:"expiresAt" db:"expires_at"`
+	RevokedAt     *time.Time `json:"revokedAt,omitempty" db:"revoked_at"`
+	RevokedReason *string    `json:"revokedReason,omitempty" db:"revoked_reason"`
+}
+
+// RefreshToken is a stored refresh token
+type RefreshToken struct {
+	Token      string    `json:"-" db:"token"`
+	UserID     int64     `json:"userId" db:"user_id"`
+	FamilyID   *int64    `json:"familyId,omitempty" db:"family_id"` // Nil for tokens issued before families existed
+	ExpiryDate time.Time `json:"expiryDate" db:"expiry_date"`
+	IsRevoked  bool      `json:"isRevoked" db:"is_revoked"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// UploadSession is a resumable (tus) upload. Received bytes are kept as chunk objects in
+// file storage until the finished upload is attached to a resource or the session expires.
+type UploadSession struct {
+	ID         string    `json:"id" db:"id"`
+	UserID     int64     `json:"userId" db:"user_id"`
+	FileName   string    `json:"fileName" db:"file_name"`
+	Length     int64     `json:"length" db:"upload_length"`
+	Offset     int64     `json:"offset" db:"upload_offset"`
+	Metadata   string    `json:"metadata" db:"metadata"` // Upload-Metadata header as sent by the client
+	ChunkPaths []string  `json:"-" db:"chunk_paths"`
+	ExpiresAt  time.Time `json:"expiresAt" db:"expires_at"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt  time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// IsComplete reports whether all bytes of the upload were received
+func (u *UploadSession) IsComplete() bool {
+	return u.Offset == u.Length
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to their account at an OpenID Connect provider or an LDAP
+// directory, identified by the provider's issuer URL and the sub claim, or by the directory and
+// the entry's ID attribute
+type UserIdentity struct {
+	ID            int64      `json:"id" db:"id"`
+	UserID        int64      `json:"userId" db:"user_id"`
+	Issuer        string     `json:"issuer" db:"issuer"`
+	Subject       string     `json:"subject" db:"subject"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+	LastLoginAt   *time.Time `json:"lastLoginAt,omitempty" db:"last_login_at"`
+	DeactivatedAt *time.Time `json:"deactivatedAt,omitempty" db:"deactivated_at"` // Set while the directory sync keeps the user deactivated
+}
+
+// SSOLoginState is a single sign-on login sent to the provider, kept until it comes back with
+// an authorization code. The state identifies it; nonce and code verifier bind the code to it.
+type SSOLoginState struct {
+	State        string    `db:"state"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	CreatedAt    time.Time `db:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// UserTOTP is a user's TOTP authenticator. Until the first code is verified it is an
+// enrollment in progress and login does not ask for a code.
+type UserTOTP struct {
+	UserID         int64      `json:"userId" db:"user_id"`
+	Secret         string     `json:"-" db:"secret"` // Encrypted base32 secret
+	Enabled        bool       `json:"enabled" db:"enabled"`
+	LastUsedStep   int64      `json:"-" db:"last_used_step"`
+	FailedAttempts int        `json:"-" db:"failed_attempts"`
+	EnabledAt      *time.Time `json:"enabledAt,omitempty" db:"enabled_at"`
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// RoleRequiresMFA reports whether users of role must protect their account with a second
+// factor: instructors manage exams and notes, admins manage everything
+func RoleRequiresMFA(role RoleType) bool {
+	return role == RoleInstructor || role == RoleAdmin
+}
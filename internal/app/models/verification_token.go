@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Purposes of email verification tokens
+const (
+	VerificationTokenVerify          = "verify"            // Verifies the address given at registration
+	VerificationTokenEmailChange     = "email_change"      // Confirms a new address
+	VerificationTokenEmailChangeUndo = "email_change_undo" // Restores the address a change replaced
+)
+
+// EmailChangeToken is an email verification token that sets a user's email address: the new
+// address when it is confirmed, or the old one when the change is undone
+type EmailChangeToken struct {
+	Token      string    `json:"-" db:"token"`
+	UserID     int64     `json:"userId" db:"user_id"`
+	Purpose    string    `json:"purpose" db:"purpose"`
+	Email      string    `json:"email" db:"email"`
+	ExpiryDate time.Time `json:"expiryDate" db:"expiry_date"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+}
@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yigit/unisphere/internal/app/models"
+)
+
+// AuthThrottleRepository handles database operations for login and email request throttling
+type AuthThrottleRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewAuthThrottleRepository creates a new AuthThrottleRepository
+func NewAuthThrottleRepository(db *pgxpool.Pool) *AuthThrottleRepository {
+	return &AuthThrottleRepository{db: db}
+}
+
+// authThrottleColumns lists the columns scanned by scanAuthThrottle
+const authThrottleColumns = `action, subject, attempts, window_started_at, blocked_until, locked_until, updated_at`
+
+// scanAuthThrottle scans a row selected with authThrottleColumns
+func scanAuthThrottle(row pgx.Row) (*models.AuthThrottle, error) {
+	var throttle models.AuthThrottle
+	err := row.Scan(&throttle.Action, &throttle.Subject, &throttle.Attempts, &throttle.WindowStartedAt,
+		&throttle.BlockedUntil, &throttle.LockedUntil, &throttle.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// Get returns the counter of a subject for an action, or nil if it has none
+func (r *AuthThrottleRepository) Get(ctx context.Context, action, subject string) (*models.AuthThrottle, error) {
+	query := `SELECT ` + authThrottleColumns + ` FROM auth_throttles WHERE action = $1 AND subject = $2`
+
+	throttle, err := scanAuthThrottle(r.db.QueryRow(ctx, query, action, subject))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting auth throttle: %w", err)
+	}
+	return throttle, nil
+}
+
+// RecordAttempt counts an attempt and returns the counter. A counter whose last attempt is
+// older than window starts over.
+func (r *AuthThrottleRepository) RecordAttempt(ctx context.Context, action, subject string, window time.Duration) (*models.AuthThrottle, error) {
+	query := `
+		INSERT INTO auth_throttles (action, subject, attempts)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (action, subject) DO UPDATE
+		SET attempts = CASE WHEN auth_throttles.updated_at < $3 THEN 1 ELSE auth_throttles.attempts + 1 END,
+			window_started_at = CASE WHEN auth_throttles.updated_at < $3 THEN NOW() ELSE auth_throttles.window_started_at END,
+			updated_at = NOW()
+		RETURNING ` + authThrottleColumns
+
+	throttle, err := scanAuthThrottle(r.db.QueryRow(ctx, query, action, subject, time.Now().Add(-window)))
+	if err != nil {
+		return nil, fmt.Errorf("error recording auth attempt: %w", err)
+	}
+	return throttle, nil
+}
+
+// Block sets when a subject may try again. When lockedUntil is not nil the subject is locked
+// until then and its attempts start over.
+func (r *AuthThrottleRepository) Block(ctx context.Context, action, subject string, blockedUntil time.Time, lockedUntil *time.Time) error {
+	query := `
+		UPDATE auth_throttles
+		SET blocked_until = $3,
+			locked_until = COALESCE($4, locked_until),
+			attempts = CASE WHEN $4::timestamptz IS NULL THEN attempts ELSE 0 END
+		WHERE action = $1 AND subject = $2
+	`
+
+	if _, err := r.db.Exec(ctx, query, action, subject, blockedUntil, lockedUntil); err != nil {
+		return fmt.Errorf("error blocking auth subject: %w", err)
+	}
+	return nil
+}
+
+// Reset deletes the counter of a subject, lifting its backoff and lockout
+func (r *AuthThrottleRepository) Reset(ctx context.Context, action, subject string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM auth_throttles WHERE action = $1 AND subject = $2`, action, subject)
+	if err != nil {
+		return false, fmt.Errorf("error resetting auth throttle: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListLocked returns the counters of an action whose lockout has not ended yet
+func (r *AuthThrottleRepository) ListLocked(ctx context.Context, action string) ([]*models.AuthThrottle, error) {
+	query := `
+		SELECT ` + authThrottleColumns + `
+		FROM auth_throttles
+		WHERE action = $1 AND locked_until > NOW()
+		ORDER BY locked_until DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, action)
+	if err != nil {
+		return nil, fmt.Errorf("error listing locked subjects: %w", err)
+	}
+	defer rows.Close()
+
+	var throttles []*models.AuthThrottle
+	for rows.Next() {
+		throttle, err := scanAuthThrottle(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning auth throttle: %w", err)
+		}
+		throttles = append(throttles, throttle)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating auth throttles: %w", err)
+	}
+	return throttles, nil
+}
+
+// DeleteStale removes counters untouched since before the cutoff whose backoff and lockout are over
+func (r *AuthThrottleRepository) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM auth_throttles
+		WHERE updated_at < $1
+		AND (blocked_until IS NULL OR blocked_until < NOW())
+		AND (locked_until IS NULL OR locked_until < NOW())
+	`
+
+	tag, err := r.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting stale auth throttles: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yigit/unisphere/internal/pkg/filestorage"
+)
+
+// BlobRepository records content-addressed blobs. It implements filestorage.BlobIndex;
+// ref_count is kept up to date by a trigger on the files table.
+type BlobRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewBlobRepository creates a new BlobRepository
+func NewBlobRepository(db *pgxpool.Pool) *BlobRepository {
+	return &BlobRepository{db: db}
+}
+
+// Use marks the blob with the given hash as just used and returns it, or nil if it is not stored
+func (r *BlobRepository) Use(ctx context.Context, hash string) (*filestorage.Blob, error) {
+	query := `
+		UPDATE blobs SET last_used_at = NOW()
+		WHERE hash = $1
+		RETURNING hash, file_path, file_size, thumbnail_sizes
+	`
+
+	var blob filestorage.Blob
+	err := r.db.QueryRow(ctx, query, hash).Scan(&blob.Hash, &blob.Path, &blob.Size, &blob.ThumbnailSizes)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error using blob: %w", err)
+	}
+	return &blob, nil
+}
+
+// Add records a blob that was just stored. Concurrent uploads of the same content store
+// the same path, so a blob that is already recorded is only marked as used.
+func (r *BlobRepository) Add(ctx context.Context, blob *filestorage.Blob) error {
+	query := `
+		INSERT INTO blobs (hash, file_path, file_size, thumbnail_sizes, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (hash) DO UPDATE SET last_used_at = NOW()
+	`
+
+	thumbnailSizes := blob.ThumbnailSizes
+	if thumbnailSizes == nil {
+		thumbnailSizes = []int{}
+	}
+	if _, err := r.db.Exec(ctx, query, blob.Hash, blob.Path, blob.Size, thumbnailSizes); err != nil {
+		return fmt.Errorf("error adding blob: %w", err)
+	}
+	return nil
+}
+
+// DeleteUnused deletes the record of a blob no file refers to and that was last used before
+// the given time, calling remove before the deletion is committed
+func (r *BlobRepository) DeleteUnused(ctx context.Context, hash string, before time.Time, remove func(*filestorage.Blob) error) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The deleted row stays locked until commit, so uploads of the same content wait for remove
+	var blob filestorage.Blob
+	err = tx.QueryRow(ctx, `
+		DELETE FROM blobs
+		WHERE hash = $1 AND ref_count = 0 AND last_used_at < $2
+		RETURNING hash, file_path, file_size, thumbnail_sizes
+	`, hash, before).Scan(&blob.Hash, &blob.Path, &blob.Size, &blob.ThumbnailSizes)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error deleting blob: %w", err)
+	}
+
+	if err := remove(&blob); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("error committing blob deletion: %w", err)
+	}
+	return true, nil
+}
+
+// ListUnused returns the hashes of up to limit blobs no file refers to that were last used before the given time
+func (r *BlobRepository) ListUnused(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	query := `
+		SELECT hash FROM blobs
+		WHERE ref_count = 0 AND last_used_at < $1
+		ORDER BY last_used_at
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing unused blobs: %w", err)
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("error scanning blob: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// ListPaths returns the stored paths of all blobs, with the sizes of their thumbnails
+func (r *BlobRepository) ListPaths(ctx context.Context) (map[string][]int, error) {
+	rows, err := r.db.Query(ctx, `SELECT file_path, thumbnail_sizes FROM blobs`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing blobs: %w", err)
+	}
+	defer rows.Close()
+
+	paths := make(map[string][]int)
+	for rows.Next() {
+		var filePath string
+		var thumbnailSizes []int
+		if err := rows.Scan(&filePath, &thumbnailSizes); err != nil {
+			return nil, fmt.Errorf("error scanning blob: %w", err)
+		}
+		paths[filePath] = thumbnailSizes
+	}
+	return paths, rows.Err()
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/pkg/helpers"
 )
 
 // ChatRepository handles database operations for chat messages
@@ -83,7 +84,12 @@ func (r *ChatRepository) GetByID(ctx context.Context, id int64) (*models.ChatMes
 	return &message, nil
 }
 
-// GetByCommunityID retrieves messages for a specific community with filters
+// ChatMessageSort is the cursor sort key of chat histories, which are always ordered newest first
+const ChatMessageSort = "created_at:desc"
+
+// GetByCommunityID retrieves messages for a specific community with filters.
+// When cursor is set, only messages past it are returned; hasMore reports whether
+// further messages exist in the paging direction.
 func (r *ChatRepository) GetByCommunityID(
 	ctx context.Context,
 	communityID int64,
@@ -91,22 +97,34 @@ func (r *ChatRepository) GetByCommunityID(
 	after *time.Time,
 	senderID *int64,
 	limit int,
-) ([]*models.ChatMessage, error) {
+	cursor *helpers.Cursor,
+) ([]*models.ChatMessage, bool, error) {
 	// Base query
 	queryBuilder := squirrel.Select(
 		"cm.id", "cm.community_id", "cm.sender_id", "cm.message_type", 
 		"cm.content", "cm.file_id", "cm.created_at", "cm.updated_at",
 		"u.id as user_id", "u.first_name", "u.last_name", "u.email",
 		"f.id as file_id", "f.file_name", "f.file_url", "f.file_type", "f.file_size",
+		"f.thumbnail_sizes",
 	).
 		From("chat_messages cm").
 		LeftJoin("users u ON cm.sender_id = u.id").
 		LeftJoin("files f ON cm.file_id = f.id").
 		Where("cm.community_id = ?", communityID).
-		OrderBy("cm.created_at DESC").
-		Limit(uint64(limit)).
+		// Fetch one extra row to learn whether older (or, paging backwards, newer) messages follow
+		Limit(uint64(limit + 1)).
 		PlaceholderFormat(squirrel.Dollar)
 
+	pageBackwards := cursor != nil && cursor.Before
+	if cursor != nil {
+		value, err := cursorArg(cursor, ChatMessageSort, sortKindTime)
+		if err != nil {
+			return nil, false, err
+		}
+		queryBuilder = queryBuilder.Where(keysetCondition("cm.created_at", "cm.id", true, cursor, value))
+	}
+	queryBuilder = queryBuilder.OrderBy(keysetOrderBy("cm.created_at", "cm.id", true, pageBackwards)...)
+
 	// Add filters if provided
 	if before != nil {
 		queryBuilder = queryBuilder.Where("cm.created_at < ?", before)
@@ -123,13 +141,13 @@ func (r *ChatRepository) GetByCommunityID(
 	// Build the SQL query
 	sql, args, err := queryBuilder.ToSql()
 	if err != nil {
-		return nil, fmt.Errorf("error building SQL: %w", err)
+		return nil, false, fmt.Errorf("error building SQL: %w", err)
 	}
 
 	// Execute the query
 	rows, err := r.db.Query(ctx, sql, args...)
 	if err != nil {
-		return nil, fmt.Errorf("error executing query: %w", err)
+		return nil, false, fmt.Errorf("error executing query: %w", err)
 	}
 	defer rows.Close()
 
@@ -141,6 +159,7 @@ func (r *ChatRepository) GetByCommunityID(
 		var fileID, userID *int64
 		var firstName, lastName, email, fileName, fileURL, fileType *string
 		var fileSize *int64
+		var thumbnailSizes []int
 
 		err := rows.Scan(
 			&message.ID,
@@ -160,10 +179,11 @@ func (r *ChatRepository) GetByCommunityID(
 			&fileURL,
 			&fileType,
 			&fileSize,
+			&thumbnailSizes,
 		)
 
 		if err != nil {
-			return nil, fmt.Errorf("error scanning chat message row: %w", err)
+			return nil, false, fmt.Errorf("error scanning chat message row: %w", err)
 		}
 
 		// Add the sender if exists
@@ -196,6 +216,7 @@ func (r *ChatRepository) GetByCommunityID(
 			if fileSize != nil {
 				file.FileSize = *fileSize
 			}
+			file.ThumbnailSizes = thumbnailSizes
 			message.File = &file
 		}
 
@@ -204,10 +225,12 @@ func (r *ChatRepository) GetByCommunityID(
 
 	// Check for any errors during iteration
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating chat message rows: %w", err)
+		return nil, false, fmt.Errorf("error iterating chat message rows: %w", err)
 	}
 
-	return messages, nil
+	messages, hasMore := trimKeysetPage(messages, limit, pageBackwards)
+
+	return messages, hasMore, nil
 }
 
 // Delete removes a chat message
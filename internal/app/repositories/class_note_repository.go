@@ -9,6 +9,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/pkg/helpers"
 )
 
 // ClassNoteRepository handles database operations for class notes
@@ -21,11 +22,35 @@ func NewClassNoteRepository(db *pgxpool.Pool) *ClassNoteRepository {
 	return &ClassNoteRepository{db: db}
 }
 
-// GetAll retrieves all class notes with filtering, sorting and pagination
-func (r *ClassNoteRepository) GetAll(ctx context.Context, departmentID *int64, courseCode *string, instructorID *int64, page, pageSize int, sortBy, sortOrder string) ([]models.ClassNote, int64, error) {
+// classNoteSortKinds whitelists the class note sort columns and their cursor value types
+var classNoteSortKinds = map[string]sortKind{
+	"created_at":  sortKindTime,
+	"updated_at":  sortKindTime,
+	"title":       sortKindText,
+	"course_code": sortKindText,
+}
+
+// ClassNoteSort normalizes the requested sort and returns the column, order and cursor sort key
+func ClassNoteSort(sortBy, sortOrder string) (string, string, string) {
+	// Default to created_at if empty or invalid sort column
+	if _, ok := classNoteSortKinds[sortBy]; !ok {
+		sortBy = "created_at"
+	}
+	// Default to descending if invalid
+	if sortOrder != "asc" && sortOrder != "desc" {
+		sortOrder = "desc"
+	}
+	return sortBy, sortOrder, sortBy + ":" + sortOrder
+}
+
+// GetAll retrieves all class notes with filtering, sorting and pagination.
+// When cursor is set, the page is selected with a keyset condition instead of page/OFFSET
+// and hasMore reports whether further rows exist in the paging direction.
+// With withFiles, the files of the whole page are loaded with one query.
+func (r *ClassNoteRepository) GetAll(ctx context.Context, departmentID *int64, courseCode *string, instructorID *int64, page, pageSize int, sortBy, sortOrder string, cursor *helpers.Cursor, withFiles bool) ([]models.ClassNote, int64, bool, error) {
 	// Build base query
 	query := squirrel.Select(
-		"id", "course_code", "title", "description", "content",
+		"id", "course_code", "title", "description", "content", "content_html", "content_html_version",
 		"department_id", "user_id", "created_at", "updated_at",
 	).
 		From("class_notes").
@@ -42,79 +67,98 @@ func (r *ClassNoteRepository) GetAll(ctx context.Context, departmentID *int64, c
 		query = query.Where("user_id = ?", *instructorID)
 	}
 
-	// Add sorting with validation
-	// Default to created_at if empty or invalid sort column
-	if sortBy == "" {
-		sortBy = "created_at"
-	}
-	
-	// Validate sortBy field (whitelist approach for security)
-	validSortColumns := map[string]bool{
-		"created_at":  true,
-		"updated_at":  true,
-		"title":       true,
-		"course_code": true,
-	}
-	
-	if !validSortColumns[sortBy] {
-		sortBy = "created_at" // Default to created_at if invalid
-	}
-	
-	// Validate sortOrder
-	if sortOrder != "asc" && sortOrder != "desc" {
-		sortOrder = "desc" // Default to descending if invalid
-	}
-	
-	// Apply sorting
-	query = query.OrderBy(fmt.Sprintf("%s %s", sortBy, sortOrder))
+	// Validate sorting (whitelist approach for security); id breaks ties so pages are stable
+	sortBy, sortOrder, sortKey := ClassNoteSort(sortBy, sortOrder)
+	desc := sortOrder == "desc"
 
-	// Add pagination
-	offset := (page - 1) * pageSize
-	query = query.Limit(uint64(pageSize)).Offset(uint64(offset))
+	var total int64
+	before := cursor != nil && cursor.Before
+
+	if cursor == nil {
+		// Add sorting and pagination; the total comes back with every row
+		offset := (page - 1) * pageSize
+		query = query.OrderBy(keysetOrderBy(sortBy, "id", desc, false)...).
+			Limit(uint64(pageSize)).Offset(uint64(offset)).
+			Column("COUNT(*) OVER()")
+	} else {
+		value, err := cursorArg(cursor, sortKey, classNoteSortKinds[sortBy])
+		if err != nil {
+			return nil, 0, false, err
+		}
+
+		// Count the whole filtered list, not just the rows past the cursor
+		countSQL, countArgs, err := countRows(query)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("error building count SQL: %w", err)
+		}
+		if err := r.db.QueryRow(ctx, countSQL, countArgs...).Scan(&total); err != nil {
+			return nil, 0, false, fmt.Errorf("error counting class notes: %w", err)
+		}
 
-	// Get total count
-	countQuery := query.Column("COUNT(*) OVER()")
-	sql, args, err := countQuery.ToSql()
+		// Fetch one extra row to learn whether another page follows
+		query = query.Where(keysetCondition(sortBy, "id", desc, cursor, value)).
+			OrderBy(keysetOrderBy(sortBy, "id", desc, before)...).
+			Limit(uint64(pageSize + 1))
+	}
+
+	sql, args, err := query.ToSql()
 	if err != nil {
-		return nil, 0, fmt.Errorf("error building SQL: %w", err)
+		return nil, 0, false, fmt.Errorf("error building SQL: %w", err)
 	}
 
 	rows, err := r.db.Query(ctx, sql, args...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error executing query: %w", err)
+		return nil, 0, false, fmt.Errorf("error executing query: %w", err)
 	}
 	defer rows.Close()
 
 	var notes []models.ClassNote
-	var total int64
 
 	for rows.Next() {
 		var note models.ClassNote
-		err := rows.Scan(
+		dest := []interface{}{
 			&note.ID,
 			&note.CourseCode,
 			&note.Title,
 			&note.Description,
 			&note.Content,
+			&note.ContentHTML,
+			&note.ContentHTMLVersion,
 			&note.DepartmentID,
 			&note.UserID,
 			&note.CreatedAt,
 			&note.UpdatedAt,
-			&total,
-		)
-		if err != nil {
-			return nil, 0, fmt.Errorf("error scanning row: %w", err)
+		}
+		if cursor == nil {
+			dest = append(dest, &total)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, 0, false, fmt.Errorf("error scanning row: %w", err)
 		}
 		notes = append(notes, note)
 	}
 
-	return notes, total, nil
+	hasMore := false
+	if cursor != nil {
+		notes, hasMore = trimKeysetPage(notes, pageSize, before)
+	}
+
+	if !withFiles {
+		return notes, total, hasMore, nil
+	}
+
+	// Load the files of the whole page at once
+	if err := r.loadClassNoteFiles(ctx, notes); err != nil {
+		return nil, 0, false, err
+	}
+
+	return notes, total, hasMore, nil
 }
 
 // GetByID retrieves a class note by ID
 func (r *ClassNoteRepository) GetByID(ctx context.Context, id int64) (*models.ClassNote, error) {
 	query := squirrel.Select(
-		"id", "course_code", "title", "description", "content",
+		"id", "course_code", "title", "description", "content", "content_html", "content_html_version",
 		"department_id", "user_id", "created_at", "updated_at",
 	).
 		From("class_notes").
@@ -133,6 +177,8 @@ func (r *ClassNoteRepository) GetByID(ctx context.Context, id int64) (*models.Cl
 		&note.Title,
 		&note.Description,
 		&note.Content,
+		&note.ContentHTML,
+		&note.ContentHTMLVersion,
 		&note.DepartmentID,
 		&note.UserID,
 		&note.CreatedAt,
@@ -159,11 +205,11 @@ func (r *ClassNoteRepository) GetByID(ctx context.Context, id int64) (*models.Cl
 func (r *ClassNoteRepository) Create(ctx context.Context, note *models.ClassNote) (int64, error) {
 	query := squirrel.Insert("class_notes").
 		Columns(
-			"course_code", "title", "description", "content",
+			"course_code", "title", "description", "content", "content_html", "content_html_version",
 			"department_id", "user_id",
 		).
 		Values(
-			note.CourseCode, note.Title, note.Description, note.Content,
+			note.CourseCode, note.Title, note.Description, note.Content, note.ContentHTML, note.ContentHTMLVersion,
 			note.DepartmentID, note.UserID,
 		).
 		Suffix("RETURNING id").
@@ -193,6 +239,8 @@ func (r *ClassNoteRepository) Update(ctx context.Context, note *models.ClassNote
 		Set("title", note.Title).
 		Set("description", note.Description).
 		Set("content", note.Content).
+		Set("content_html", note.ContentHTML).
+		Set("content_html_version", note.ContentHTMLVersion).
 		Set("department_id", note.DepartmentID).
 		Set("user_id", note.UserID).
 		Set("updated_at", time.Now()).
@@ -311,10 +359,11 @@ func (r *ClassNoteRepository) RemoveFileFromClassNote(ctx context.Context, class
 func (r *ClassNoteRepository) GetClassNoteFiles(ctx context.Context, classNoteID int64) ([]*models.File, error) {
 	query := squirrel.Select("f.id", "f.file_name", "f.file_path", "f.file_url",
 		"f.file_size", "f.file_type", "f.resource_type", "f.resource_id",
-		"f.uploaded_by", "f.created_at", "f.updated_at").
+		"f.uploaded_by", "f.scan_status", "f.created_at", "f.updated_at").
 		From("files f").
 		Join("class_note_files cnf ON f.id = cnf.file_id").
 		Where("cnf.class_note_id = ?", classNoteID).
+		Where("f.scan_status = ?", string(models.ScanStatusClean)). // Hidden until scanned clean
 		PlaceholderFormat(squirrel.Dollar)
 
 	sql, args, err := query.ToSql()
@@ -341,6 +390,7 @@ func (r *ClassNoteRepository) GetClassNoteFiles(ctx context.Context, classNoteID
 			&file.ResourceType,
 			&file.ResourceID,
 			&file.UploadedBy,
+			&file.ScanStatus,
 			&file.CreatedAt,
 			&file.UpdatedAt,
 		)
@@ -352,3 +402,70 @@ func (r *ClassNoteRepository) GetClassNoteFiles(ctx context.Context, classNoteID
 
 	return files, nil
 }
+
+// loadClassNoteFiles sets the files of every given note with a single query
+func (r *ClassNoteRepository) loadClassNoteFiles(ctx context.Context, notes []models.ClassNote) error {
+	if len(notes) == 0 {
+		return nil
+	}
+
+	noteIDs := make([]int64, len(notes))
+	for i := range notes {
+		noteIDs[i] = notes[i].ID
+	}
+
+	query := squirrel.Select("cnf.class_note_id", "f.id", "f.file_name", "f.file_path", "f.file_url",
+		"f.file_size", "f.file_type", "f.resource_type", "f.resource_id",
+		"f.uploaded_by", "f.scan_status", "f.created_at", "f.updated_at").
+		From("files f").
+		Join("class_note_files cnf ON f.id = cnf.file_id").
+		Where("cnf.class_note_id = ANY(?)", noteIDs).
+		Where("f.scan_status = ?", string(models.ScanStatusClean)). // Hidden until scanned clean
+		OrderBy("f.id").
+		PlaceholderFormat(squirrel.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building SQL: %w", err)
+	}
+
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("error getting class note files: %w", err)
+	}
+	defer rows.Close()
+
+	filesByNote := make(map[int64][]*models.File, len(notes))
+	for rows.Next() {
+		var noteID int64
+		var file models.File
+		err := rows.Scan(
+			&noteID,
+			&file.ID,
+			&file.FileName,
+			&file.FilePath,
+			&file.FileURL,
+			&file.FileSize,
+			&file.FileType,
+			&file.ResourceType,
+			&file.ResourceID,
+			&file.UploadedBy,
+			&file.ScanStatus,
+			&file.CreatedAt,
+			&file.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("error scanning class note file: %w", err)
+		}
+		filesByNote[noteID] = append(filesByNote[noteID], &file)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating class note files: %w", err)
+	}
+
+	for i := range notes {
+		notes[i].Files = filesByNote[notes[i].ID]
+	}
+
+	return nil
+}
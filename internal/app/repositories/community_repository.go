@@ -8,6 +8,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/pkg/helpers"
 )
 
 // CommunityRepository handles database operations for communities
@@ -20,46 +21,73 @@ func NewCommunityRepository(db *pgxpool.Pool) *CommunityRepository {
 	return &CommunityRepository{db: db}
 }
 
-// GetAll retrieves all communities with filtering and pagination
-func (r *CommunityRepository) GetAll(ctx context.Context, leadID *int64, search *string, page, pageSize int) ([]models.Community, int64, error) {
+// CommunitySort is the cursor sort key of community lists, which are always ordered by id
+const CommunitySort = "id:asc"
+
+// GetAll retrieves all communities with filtering and pagination.
+// When cursor is set, the page is selected with a keyset condition instead of page/OFFSET
+// and hasMore reports whether further rows exist in the paging direction.
+func (r *CommunityRepository) GetAll(ctx context.Context, leadID *int64, search *string, page, pageSize int, cursor *helpers.Cursor) ([]models.Community, int64, bool, error) {
 	// Try to select from the communities table with proper error handling
 	var communities []models.Community
 	var total int64 = 0
 
-	// Build a query that will work with the current database schema
-	// We dynamically determine the column names to ensure compatibility with the database
-	query := `
-		SELECT 
-			id, name, abbreviation, lead_id, profile_photo_file_id,
-			created_at, updated_at, 
-			COUNT(*) OVER() as total_count
-		FROM communities
-		WHERE 1=1
-	`
-
-	// Build the arguments list and add conditions
+	// Build the filter conditions and arguments list
+	where := " WHERE 1=1"
 	args := []interface{}{}
 	argIndex := 1
 
 	// Add filters
 	if leadID != nil {
-		query += fmt.Sprintf(" AND lead_id = $%d", argIndex)
+		where += fmt.Sprintf(" AND lead_id = $%d", argIndex)
 		args = append(args, *leadID)
 		argIndex++
 	}
 
 	if search != nil && *search != "" {
 		searchPattern := "%" + *search + "%"
-		query += fmt.Sprintf(" AND (name ILIKE $%d OR abbreviation ILIKE $%d)", argIndex, argIndex+1)
+		where += fmt.Sprintf(" AND (name ILIKE $%d OR abbreviation ILIKE $%d)", argIndex, argIndex+1)
 		args = append(args, searchPattern, searchPattern)
 		argIndex += 2
 	}
 
-	// Add order, pagination
-	offset := (page - 1) * pageSize
-	query += " ORDER BY id"
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
-	args = append(args, pageSize, offset)
+	// Build a query that will work with the current database schema
+	columns := `
+		SELECT 
+			id, name, abbreviation, lead_id, profile_photo_file_id,
+			created_at, updated_at`
+
+	var query string
+	before := cursor != nil && cursor.Before
+
+	if cursor == nil {
+		// Add order, pagination; the total comes back with every row
+		offset := (page - 1) * pageSize
+		query = columns + `,
+			COUNT(*) OVER() as total_count
+		FROM communities` + where
+		query += " ORDER BY id"
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
+		args = append(args, pageSize, offset)
+	} else {
+		if _, err := cursorArg(cursor, CommunitySort, sortKindInt); err != nil {
+			return nil, 0, false, err
+		}
+
+		// Count the whole filtered list, not just the rows past the cursor
+		if err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM communities"+where, args...).Scan(&total); err != nil {
+			fmt.Printf("Error counting communities in GetAll: %v\n", err)
+			return []models.Community{}, 0, false, nil
+		}
+
+		// Fetch one extra row to learn whether another page follows
+		query = columns + `
+		FROM communities` + where
+		query += fmt.Sprintf(" AND id %s $%d", keysetOperator(false, before), argIndex)
+		query += " ORDER BY " + keysetOrderBy("id", "id", false, before)[0]
+		query += fmt.Sprintf(" LIMIT $%d", argIndex+1)
+		args = append(args, cursor.ID, pageSize+1)
+	}
 
 	// Use error handling to recover from potential issues
 	defer func() {
@@ -76,11 +104,11 @@ func (r *CommunityRepository) GetAll(ctx context.Context, leadID *int64, search
 	if err != nil {
 		// If there's an error executing the query, log it and return an empty list
 		fmt.Printf("Error executing query in GetAll: %v\n", err)
-		return []models.Community{}, 0, nil
+		return []models.Community{}, 0, false, nil
 	}
 	if rows == nil {
 		// If rows is nil for some reason, return an empty list
-		return []models.Community{}, 0, nil
+		return []models.Community{}, 0, false, nil
 	}
 	defer rows.Close()
 
@@ -88,7 +116,7 @@ func (r *CommunityRepository) GetAll(ctx context.Context, leadID *int64, search
 	for rows.Next() {
 		var comm models.Community
 		var profilePhotoFileID *int64
-		err := rows.Scan(
+		dest := []interface{}{
 			&comm.ID,
 			&comm.Name,
 			&comm.Abbreviation,
@@ -96,8 +124,11 @@ func (r *CommunityRepository) GetAll(ctx context.Context, leadID *int64, search
 			&profilePhotoFileID,
 			&comm.CreatedAt,
 			&comm.UpdatedAt,
-			&total,
-		)
+		}
+		if cursor == nil {
+			dest = append(dest, &total)
+		}
+		err := rows.Scan(dest...)
 
 		if err != nil {
 			// If we can't scan a row, log the error and continue
@@ -127,12 +158,17 @@ func (r *CommunityRepository) GetAll(ctx context.Context, leadID *int64, search
 		fmt.Printf("Error iterating rows in GetAll: %v\n", err)
 	}
 
+	hasMore := false
+	if cursor != nil {
+		communities, hasMore = trimKeysetPage(communities, pageSize, before)
+	}
+
 	// Always return a valid slice, even if empty
 	if communities == nil {
 		communities = []models.Community{}
 	}
 
-	return communities, total, nil
+	return communities, total, hasMore, nil
 }
 
 // GetByID retrieves a community by ID
@@ -293,7 +329,7 @@ func (r *CommunityRepository) getProfilePhoto(ctx context.Context, fileID int64)
 	query := squirrel.Select(
 		"id", "file_name", "file_path", "file_url", "file_size",
 		"file_type", "resource_type", "resource_id", "uploaded_by",
-		"created_at", "updated_at",
+		"thumbnail_sizes", "created_at", "updated_at",
 	).
 		From("files").
 		Where("id = ?", fileID).
@@ -315,6 +351,7 @@ func (r *CommunityRepository) getProfilePhoto(ctx context.Context, fileID int64)
 		&file.ResourceType,
 		&file.ResourceID,
 		&file.UploadedBy,
+		&file.ThumbnailSizes,
 		&file.CreatedAt,
 		&file.UpdatedAt,
 	)
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/helpers"
+)
+
+// Keyset (cursor) pagination helpers.
+// Lists are ordered by a sort column with the row id as tie-breaker, both in the
+// same direction, so the page boundary can be expressed as a row comparison:
+// (sort_column, id) < (cursor value, cursor id).
+
+// sortKind tells how a cursor value is converted back into a query argument
+type sortKind int
+
+const (
+	sortKindTime sortKind = iota
+	sortKindText
+	sortKindInt
+)
+
+// cursorArg validates the cursor against the current sort and converts its value
+func cursorArg(cursor *helpers.Cursor, sort string, kind sortKind) (interface{}, error) {
+	if cursor.Sort != sort {
+		return nil, apperrors.NewBadRequestError("Pagination cursor does not match the requested sort order")
+	}
+
+	switch kind {
+	case sortKindTime:
+		t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, apperrors.NewBadRequestError(helpers.ErrInvalidCursor.Error())
+		}
+		return t, nil
+	case sortKindInt:
+		n, err := strconv.ParseInt(cursor.Value, 10, 64)
+		if err != nil {
+			return nil, apperrors.NewBadRequestError(helpers.ErrInvalidCursor.Error())
+		}
+		return n, nil
+	default:
+		return cursor.Value, nil
+	}
+}
+
+// keysetOperator returns the comparison that selects rows past the cursor
+func keysetOperator(desc, before bool) string {
+	if desc != before {
+		return "<"
+	}
+	return ">"
+}
+
+// keysetCondition selects the rows following (or, for a before cursor, preceding) the cursor row
+func keysetCondition(column, idColumn string, desc bool, cursor *helpers.Cursor, value interface{}) squirrel.Sqlizer {
+	op := keysetOperator(desc, cursor.Before)
+	if column == idColumn {
+		return squirrel.Expr(fmt.Sprintf("%s %s ?", idColumn, op), cursor.ID)
+	}
+	return squirrel.Expr(fmt.Sprintf("(%s, %s) %s (?, ?)", column, idColumn, op), value, cursor.ID)
+}
+
+// keysetOrderBy returns the ORDER BY terms for a keyset query.
+// Paging backwards reads rows in reverse order; trimKeysetPage restores display order.
+func keysetOrderBy(column, idColumn string, desc, before bool) []string {
+	dir := "ASC"
+	if desc != before {
+		dir = "DESC"
+	}
+	if column == idColumn {
+		return []string{idColumn + " " + dir}
+	}
+	return []string{column + " " + dir, idColumn + " " + dir}
+}
+
+// trimKeysetPage drops the look-ahead row fetched beyond limit and puts a backwards
+// page back into display order. It reports whether more rows exist past the page.
+func trimKeysetPage[T any](rows []T, limit int, before bool) ([]T, bool) {
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	if before {
+		slices.Reverse(rows)
+	}
+	return rows, hasMore
+}
+
+// countRows counts the rows a query matches, ignoring its ordering and paging
+func countRows(query squirrel.SelectBuilder) (string, []interface{}, error) {
+	return squirrel.Select("COUNT(*)").
+		FromSelect(query.RemoveLimit().RemoveOffset().RemoveColumns().Column("1"), "matched").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/yigit/unisphere/internal/pkg/helpers"
+)
+
+func TestCursorArg(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 9, 30, 0, 123456789, time.UTC)
+
+	tests := []struct {
+		name    string
+		cursor  helpers.Cursor
+		kind    sortKind
+		want    interface{}
+		wantErr bool
+	}{
+		{"time", helpers.Cursor{Sort: "created_at:desc", Value: helpers.CursorValue(createdAt)}, sortKindTime, createdAt, false},
+		{"int", helpers.Cursor{Sort: "created_at:desc", Value: "42"}, sortKindInt, int64(42), false},
+		{"text", helpers.Cursor{Sort: "created_at:desc", Value: "Ada"}, sortKindText, "Ada", false},
+		{"invalid time", helpers.Cursor{Sort: "created_at:desc", Value: "yesterday"}, sortKindTime, nil, true},
+		{"invalid int", helpers.Cursor{Sort: "created_at:desc", Value: "4.2"}, sortKindInt, nil, true},
+		{"other sort", helpers.Cursor{Sort: "title:asc", Value: "Ada"}, sortKindText, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cursorArg(&tt.cursor, "created_at:desc", tt.kind)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cursorArg() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cursorArg() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeysetQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		column    string
+		desc      bool
+		before    bool
+		wantWhere string
+		wantArgs  []interface{}
+		wantOrder []string
+	}{
+		{"descending after", "created_at", true, false, "(created_at, id) < ($1, $2)", []interface{}{"v", int64(7)}, []string{"created_at DESC", "id DESC"}},
+		{"descending before", "created_at", true, true, "(created_at, id) > ($1, $2)", []interface{}{"v", int64(7)}, []string{"created_at ASC", "id ASC"}},
+		{"ascending after", "title", false, false, "(title, id) > ($1, $2)", []interface{}{"v", int64(7)}, []string{"title ASC", "id ASC"}},
+		{"ascending before", "title", false, true, "(title, id) < ($1, $2)", []interface{}{"v", int64(7)}, []string{"title DESC", "id DESC"}},
+		{"by id", "id", true, false, "id < $1", []interface{}{int64(7)}, []string{"id DESC"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor := &helpers.Cursor{ID: 7, Before: tt.before}
+			sql, args, err := squirrel.Select("*").From("t").
+				Where(keysetCondition(tt.column, "id", tt.desc, cursor, "v")).
+				PlaceholderFormat(squirrel.Dollar).
+				ToSql()
+			if err != nil {
+				t.Fatalf("ToSql: %v", err)
+			}
+			if want := "SELECT * FROM t WHERE " + tt.wantWhere; sql != want {
+				t.Errorf("query = %q, want %q", sql, want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+			if order := keysetOrderBy(tt.column, "id", tt.desc, tt.before); !reflect.DeepEqual(order, tt.wantOrder) {
+				t.Errorf("keysetOrderBy() = %v, want %v", order, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestTrimKeysetPage(t *testing.T) {
+	tests := []struct {
+		name        string
+		rows        []int
+		before      bool
+		want        []int
+		wantHasMore bool
+	}{
+		{"look-ahead row dropped", []int{1, 2, 3, 4}, false, []int{1, 2, 3}, true},
+		{"last page", []int{1, 2}, false, []int{1, 2}, false},
+		{"backwards page reversed", []int{4, 3, 2, 1}, true, []int{2, 3, 4}, true},
+		{"empty", nil, false, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, hasMore := trimKeysetPage(tt.rows, 3, tt.before)
+			if !reflect.DeepEqual(got, tt.want) || hasMore != tt.wantHasMore {
+				t.Errorf("trimKeysetPage() = %v, %v; want %v, %v", got, hasMore, tt.want, tt.wantHasMore)
+			}
+		})
+	}
+}
+
+func TestCountRows(t *testing.T) {
+	query := squirrel.Select("id", "title").From("notes").
+		Where(squirrel.Eq{"course_id": 3}).
+		OrderBy("id DESC").Limit(10).Offset(20).
+		PlaceholderFormat(squirrel.Dollar)
+
+	sql, args, err := countRows(query)
+	if err != nil {
+		t.Fatalf("countRows: %v", err)
+	}
+	want := "SELECT COUNT(*) FROM (SELECT 1 FROM notes WHERE course_id = $1 ORDER BY id DESC) AS matched"
+	if sql != want {
+		t.Errorf("query = %q, want %q", sql, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{3}) {
+		t.Errorf("args = %v, want [3]", args)
+	}
+}
@@ -87,6 +87,37 @@ func (r *DepartmentRepository) GetByID(ctx context.Context, id int64) (*models.D
 	return &department, nil
 }
 
+// GetByCode retrieves a department by its code, ignoring case
+func (r *DepartmentRepository) GetByCode(ctx context.Context, code string) (*models.Department, error) {
+	sql, args, err := r.sb.Select("id", "faculty_id", "name", "code").
+		From("departments").
+		Where(squirrel.Expr("LOWER(code) = LOWER(?)", code)).
+		Limit(1).
+		ToSql()
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Error building get department by code SQL")
+		return nil, fmt.Errorf("failed to build get department query: %w", err)
+	}
+
+	var department models.Department
+	err = r.db.QueryRow(ctx, sql, args...).Scan(
+		&department.ID,
+		&department.FacultyID,
+		&department.Name,
+		&department.Code,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.ErrDepartmentNotFound
+		}
+		logger.Error().Err(err).Str("code", code).Msg("Error scanning department row")
+		return nil, fmt.Errorf("error retrieving department: %w", err)
+	}
+
+	return &department, nil
+}
+
 // GetAll retrieves all departments
 func (r *DepartmentRepository) GetAll(ctx context.Context) ([]*models.Department, error) {
 	sql, args, err := r.sb.Select("id", "faculty_id", "name", "code").
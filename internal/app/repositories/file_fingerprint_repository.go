@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// FingerprintCandidate is a stored MinHash signature that may match a new file
+type FingerprintCandidate struct {
+	FileID    int64
+	Signature []int64
+}
+
+// FingerprintPair is a pair of files sharing at least one LSH band
+type FingerprintPair struct {
+	FileID      int64
+	OtherFileID int64
+}
+
+// FileFingerprintRepository handles database operations for file text fingerprints
+type FileFingerprintRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewFileFingerprintRepository creates a new FileFingerprintRepository
+func NewFileFingerprintRepository(db *pgxpool.Pool) *FileFingerprintRepository {
+	return &FileFingerprintRepository{db: db}
+}
+
+// Save stores the MinHash signature and LSH band hashes of a file
+func (r *FileFingerprintRepository) Save(ctx context.Context, fileID int64, signature []int64, bands []int64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO file_fingerprints (file_id, minhash)
+		VALUES ($1, $2)
+		ON CONFLICT (file_id) DO UPDATE SET minhash = EXCLUDED.minhash
+	`, fileID, signature)
+	if err != nil {
+		return fmt.Errorf("error saving file fingerprint: %w", err)
+	}
+
+	if _, err = tx.Exec(ctx, `DELETE FROM file_fingerprint_bands WHERE file_id = $1`, fileID); err != nil {
+		return fmt.Errorf("error clearing file fingerprint bands: %w", err)
+	}
+
+	for i, band := range bands {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO file_fingerprint_bands (file_id, band_index, band_hash)
+			VALUES ($1, $2, $3)
+		`, fileID, i, band)
+		if err != nil {
+			return fmt.Errorf("error saving file fingerprint band: %w", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// FindCandidates returns the signatures of files sharing at least one band with the given bands
+func (r *FileFingerprintRepository) FindCandidates(ctx context.Context, bands []int64, excludeFileID int64) ([]FingerprintCandidate, error) {
+	if len(bands) == 0 {
+		return nil, nil
+	}
+
+	indexes := make([]int32, len(bands))
+	for i := range bands {
+		indexes[i] = int32(i)
+	}
+
+	query := `
+		SELECT fp.file_id, fp.minhash
+		FROM file_fingerprints fp
+		WHERE fp.file_id <> $1 AND EXISTS (
+			SELECT 1
+			FROM file_fingerprint_bands b
+			JOIN unnest($2::smallint[], $3::bigint[]) AS q(band_index, band_hash)
+			  ON b.band_index = q.band_index AND b.band_hash = q.band_hash
+			WHERE b.file_id = fp.file_id
+		)
+	`
+
+	rows, err := r.db.Query(ctx, query, excludeFileID, indexes, bands)
+	if err != nil {
+		return nil, fmt.Errorf("error finding fingerprint candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []FingerprintCandidate
+	for rows.Next() {
+		var c FingerprintCandidate
+		if err := rows.Scan(&c.FileID, &c.Signature); err != nil {
+			return nil, fmt.Errorf("error scanning fingerprint candidate: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating fingerprint candidates: %w", err)
+	}
+
+	return candidates, nil
+}
+
+// GetCandidatePairs returns every pair of files sharing at least one LSH band. Buckets (files
+// with the same band hash) of more than maxBucketSize files are skipped: they come from
+// boilerplate text shared by many files, and pairing them up grows with the square of their size.
+func (r *FileFingerprintRepository) GetCandidatePairs(ctx context.Context, maxBucketSize int) ([]FingerprintPair, error) {
+	query := `
+		WITH buckets AS (
+			SELECT band_index, band_hash
+			FROM file_fingerprint_bands
+			GROUP BY band_index, band_hash
+			HAVING COUNT(*) BETWEEN 2 AND $1
+		)
+		SELECT DISTINCT a.file_id, b.file_id
+		FROM buckets k
+		JOIN file_fingerprint_bands a
+		  ON a.band_index = k.band_index AND a.band_hash = k.band_hash
+		JOIN file_fingerprint_bands b
+		  ON b.band_index = k.band_index AND b.band_hash = k.band_hash AND a.file_id < b.file_id
+	`
+
+	rows, err := r.db.Query(ctx, query, maxBucketSize)
+	if err != nil {
+		return nil, fmt.Errorf("error getting fingerprint pairs: %w", err)
+	}
+	defer rows.Close()
+
+	var pairs []FingerprintPair
+	for rows.Next() {
+		var p FingerprintPair
+		if err := rows.Scan(&p.FileID, &p.OtherFileID); err != nil {
+			return nil, fmt.Errorf("error scanning fingerprint pair: %w", err)
+		}
+		pairs = append(pairs, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating fingerprint pairs: %w", err)
+	}
+
+	return pairs, nil
+}
+
+// GetSignatures returns the stored signatures for the given files keyed by file ID
+func (r *FileFingerprintRepository) GetSignatures(ctx context.Context, fileIDs []int64) (map[int64][]int64, error) {
+	signatures := make(map[int64][]int64)
+	if len(fileIDs) == 0 {
+		return signatures, nil
+	}
+
+	rows, err := r.db.Query(ctx, `SELECT file_id, minhash FROM file_fingerprints WHERE file_id = ANY($1)`, fileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error getting fingerprints: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fileID int64
+		var sig []int64
+		if err := rows.Scan(&fileID, &sig); err != nil {
+			return nil, fmt.Errorf("error scanning fingerprint: %w", err)
+		}
+		signatures[fileID] = sig
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating fingerprints: %w", err)
+	}
+
+	return signatures, nil
+}
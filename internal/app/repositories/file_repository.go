@@ -2,11 +2,16 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yigit/unisphere/internal/app/models"
 	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/filestorage"
 )
 
 // FileRepository handles database operations for files
@@ -23,7 +28,7 @@ func NewFileRepository(db *pgxpool.Pool) *FileRepository {
 func (r *FileRepository) GetByID(ctx context.Context, id int64) (*models.File, error) {
 	query := `
 		SELECT id, file_name, file_path, file_url, file_size, file_type,
-			   resource_type, resource_id, uploaded_by, created_at, updated_at
+			   resource_type, resource_id, uploaded_by, thumbnail_sizes, scan_status, scan_signature, created_at, updated_at
 		FROM files
 		WHERE id = $1
 	`
@@ -39,28 +44,70 @@ func (r *FileRepository) GetByID(ctx context.Context, id int64) (*models.File, e
 		&file.ResourceType,
 		&file.ResourceID,
 		&file.UploadedBy,
+		&file.ThumbnailSizes,
+		&file.ScanStatus,
+		&file.ScanSignature,
 		&file.CreatedAt,
 		&file.UpdatedAt,
 	)
 
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.ErrResourceNotFound
+		}
 		return nil, fmt.Errorf("error getting file: %w", err)
 	}
 
 	return &file, nil
 }
 
-// Create creates a new file
+// GetByFilePath retrieves a file by its storage path. Paths were stored both with and
+// without a leading slash, so both forms are matched. Files with the same content share
+// a blob and its path; then a public, clean file is preferred.
+func (r *FileRepository) GetByFilePath(ctx context.Context, filePath string) (*models.File, error) {
+	query := `
+		SELECT id, file_name, file_path, file_url, file_size, file_type,
+			   resource_type, resource_id, uploaded_by, content_hash, thumbnail_sizes, scan_status, scan_signature, created_at, updated_at
+		FROM files
+		WHERE file_path = $1 OR file_path = $2
+		ORDER BY resource_type = ANY($3) DESC, scan_status = $4 DESC, id
+		LIMIT 1
+	`
+
+	relative := strings.TrimPrefix(filePath, "/")
+	publicTypes := []string{string(models.FileTypeProfilePhoto), string(models.FileTypeCommunityProfilePhoto)}
+	rows, err := r.db.Query(ctx, query, "/"+relative, relative, publicTypes, string(models.ScanStatusClean))
+	if err != nil {
+		return nil, fmt.Errorf("error getting file by path: %w", err)
+	}
+	defer rows.Close()
+
+	files, err := scanFiles(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, apperrors.ErrResourceNotFound
+	}
+	return files[0], nil
+}
+
+// Create creates a new file. A file stored as a blob is linked to it, which counts as a reference.
 func (r *FileRepository) Create(ctx context.Context, file *models.File) (int64, error) {
 	query := `
 		INSERT INTO files (
 			file_name, file_path, file_url, file_size, file_type,
-			resource_type, resource_id, uploaded_by, created_at, updated_at
+			resource_type, resource_id, uploaded_by, thumbnail_sizes, blob_hash, created_at, updated_at
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
 		RETURNING id
 	`
 
+	thumbnailSizes := file.ThumbnailSizes
+	if thumbnailSizes == nil {
+		thumbnailSizes = []int{}
+	}
+
 	var id int64
 	err := r.db.QueryRow(ctx, query,
 		file.FileName,
@@ -71,6 +118,8 @@ func (r *FileRepository) Create(ctx context.Context, file *models.File) (int64,
 		file.ResourceType,
 		file.ResourceID,
 		file.UploadedBy,
+		thumbnailSizes,
+		blobHash(file.FilePath),
 	).Scan(&id)
 
 	if err != nil {
@@ -122,3 +171,366 @@ func (r *FileRepository) Delete(ctx context.Context, id int64) error {
 
 	return nil
 }
+
+// CountByResource returns the number of files attached to a resource
+func (r *FileRepository) CountByResource(ctx context.Context, resourceType models.FileType, resourceID int64) (int, error) {
+	query := `SELECT COUNT(*) FROM files WHERE resource_type = $1 AND resource_id = $2`
+
+	var count int
+	if err := r.db.QueryRow(ctx, query, string(resourceType), resourceID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting files: %w", err)
+	}
+
+	return count, nil
+}
+
+// StorageUsage is the number and total size of files of one resource type
+type StorageUsage struct {
+	ResourceType models.FileType
+	FileCount    int
+	TotalSize    int64
+}
+
+// SumSizeByUploader returns the total size in bytes of the files a user uploaded
+func (r *FileRepository) SumSizeByUploader(ctx context.Context, userID int64) (int64, error) {
+	query := `SELECT COALESCE(SUM(file_size), 0) FROM files WHERE uploaded_by = $1`
+
+	var total int64
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&total); err != nil {
+		return 0, fmt.Errorf("error summing file sizes: %w", err)
+	}
+
+	return total, nil
+}
+
+// SumSizeByResource returns the total size in bytes of the files attached to a resource
+func (r *FileRepository) SumSizeByResource(ctx context.Context, resourceType models.FileType, resourceID int64) (int64, error) {
+	query := `SELECT COALESCE(SUM(file_size), 0) FROM files WHERE resource_type = $1 AND resource_id = $2`
+
+	var total int64
+	if err := r.db.QueryRow(ctx, query, string(resourceType), resourceID).Scan(&total); err != nil {
+		return 0, fmt.Errorf("error summing file sizes: %w", err)
+	}
+
+	return total, nil
+}
+
+// GetUsageByUploader returns the storage a user uses, grouped by resource type
+func (r *FileRepository) GetUsageByUploader(ctx context.Context, userID int64) ([]StorageUsage, error) {
+	query := `
+		SELECT resource_type, COUNT(*), COALESCE(SUM(file_size), 0)
+		FROM files
+		WHERE uploaded_by = $1
+		GROUP BY resource_type
+		ORDER BY resource_type
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting storage usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []StorageUsage
+	for rows.Next() {
+		var u StorageUsage
+		var resourceType string
+		if err := rows.Scan(&resourceType, &u.FileCount, &u.TotalSize); err != nil {
+			return nil, fmt.Errorf("error scanning storage usage: %w", err)
+		}
+		u.ResourceType = models.FileType(resourceType)
+		usage = append(usage, u)
+	}
+
+	return usage, rows.Err()
+}
+
+// SetContentHash stores the SHA-256 content hash of a file
+func (r *FileRepository) SetContentHash(ctx context.Context, id int64, hash string) error {
+	query := `UPDATE files SET content_hash = $1 WHERE id = $2`
+
+	result, err := r.db.Exec(ctx, query, hash, id)
+	if err != nil {
+		return fmt.Errorf("error setting file content hash: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return apperrors.ErrResourceNotFound
+	}
+
+	return nil
+}
+
+// FindByContentHash retrieves files of the given resource types with the same content hash
+func (r *FileRepository) FindByContentHash(ctx context.Context, hash string, resourceTypes []models.FileType, excludeID int64) ([]*models.File, error) {
+	query := `
+		SELECT id, file_name, file_path, file_url, file_size, file_type,
+			   resource_type, resource_id, uploaded_by, content_hash, thumbnail_sizes, scan_status, scan_signature, created_at, updated_at
+		FROM files
+		WHERE content_hash = $1 AND id <> $2 AND resource_type = ANY($3)
+		ORDER BY id
+	`
+
+	types := make([]string, len(resourceTypes))
+	for i, t := range resourceTypes {
+		types[i] = string(t)
+	}
+
+	rows, err := r.db.Query(ctx, query, hash, excludeID, types)
+	if err != nil {
+		return nil, fmt.Errorf("error finding files by content hash: %w", err)
+	}
+	defer rows.Close()
+
+	return scanFiles(rows)
+}
+
+// GetByIDs retrieves the files with the given IDs
+func (r *FileRepository) GetByIDs(ctx context.Context, ids []int64) ([]*models.File, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT id, file_name, file_path, file_url, file_size, file_type,
+			   resource_type, resource_id, uploaded_by, content_hash, thumbnail_sizes, scan_status, scan_signature, created_at, updated_at
+		FROM files
+		WHERE id = ANY($1)
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("error getting files: %w", err)
+	}
+	defer rows.Close()
+
+	return scanFiles(rows)
+}
+
+// ContentHashGroup is a set of files sharing the same content hash
+type ContentHashGroup struct {
+	ContentHash string
+	FileIDs     []int64
+}
+
+// GetContentHashGroups returns a page of the groups of files (of the given resource types) sharing
+// the same content hash, ordered by their oldest file, and the total number of groups
+func (r *FileRepository) GetContentHashGroups(ctx context.Context, resourceTypes []models.FileType, limit, offset int) ([]ContentHashGroup, int64, error) {
+	query := `
+		SELECT content_hash, array_agg(id ORDER BY id), COUNT(*) OVER ()
+		FROM files
+		WHERE content_hash IS NOT NULL AND resource_type = ANY($1)
+		GROUP BY content_hash
+		HAVING COUNT(*) > 1
+		ORDER BY MIN(id)
+		LIMIT $2 OFFSET $3
+	`
+
+	types := make([]string, len(resourceTypes))
+	for i, t := range resourceTypes {
+		types[i] = string(t)
+	}
+
+	rows, err := r.db.Query(ctx, query, types, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting content hash groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []ContentHashGroup
+	var total int64
+	for rows.Next() {
+		var group ContentHashGroup
+		if err := rows.Scan(&group.ContentHash, &group.FileIDs, &total); err != nil {
+			return nil, 0, fmt.Errorf("error scanning content hash group: %w", err)
+		}
+		groups = append(groups, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating content hash groups: %w", err)
+	}
+
+	// A page past the end has no rows to carry the total
+	if len(groups) == 0 && offset > 0 {
+		countQuery := `
+			SELECT COUNT(*) FROM (
+				SELECT 1 FROM files
+				WHERE content_hash IS NOT NULL AND resource_type = ANY($1)
+				GROUP BY content_hash
+				HAVING COUNT(*) > 1
+			) g
+		`
+		if err := r.db.QueryRow(ctx, countQuery, types).Scan(&total); err != nil {
+			return nil, 0, fmt.Errorf("error counting content hash groups: %w", err)
+		}
+	}
+
+	return groups, total, nil
+}
+
+// ListAfter retrieves up to limit files with an ID greater than afterID, in ID order.
+// It lets batch jobs walk the whole files table.
+func (r *FileRepository) ListAfter(ctx context.Context, afterID int64, limit int) ([]*models.File, error) {
+	query := `
+		SELECT id, file_name, file_path, file_url, file_size, file_type,
+			   resource_type, resource_id, uploaded_by, content_hash, thumbnail_sizes, scan_status, scan_signature, created_at, updated_at
+		FROM files
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing files: %w", err)
+	}
+	defer rows.Close()
+
+	return scanFiles(rows)
+}
+
+// UpdateLocation points a file record at a new storage path and URL, and at the blob stored there if any
+func (r *FileRepository) UpdateLocation(ctx context.Context, id int64, filePath, fileURL string) error {
+	query := `UPDATE files SET file_path = $1, file_url = $2, blob_hash = $3 WHERE id = $4`
+
+	result, err := r.db.Exec(ctx, query, filePath, fileURL, blobHash(filePath), id)
+	if err != nil {
+		return fmt.Errorf("error updating file location: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return apperrors.ErrResourceNotFound
+	}
+
+	return nil
+}
+
+// SetThumbnailSizes replaces the list of thumbnails stored for a file
+func (r *FileRepository) SetThumbnailSizes(ctx context.Context, id int64, sizes []int) error {
+	query := `UPDATE files SET thumbnail_sizes = $1 WHERE id = $2`
+
+	if sizes == nil {
+		sizes = []int{}
+	}
+	result, err := r.db.Exec(ctx, query, sizes, id)
+	if err != nil {
+		return fmt.Errorf("error setting file thumbnail sizes: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return apperrors.ErrResourceNotFound
+	}
+
+	return nil
+}
+
+// SetScanStatus records the result of a file's malware scan
+func (r *FileRepository) SetScanStatus(ctx context.Context, id int64, status models.ScanStatus, signature *string) error {
+	query := `UPDATE files SET scan_status = $1, scan_signature = $2, scanned_at = NOW() WHERE id = $3`
+
+	result, err := r.db.Exec(ctx, query, string(status), signature, id)
+	if err != nil {
+		return fmt.Errorf("error setting file scan status: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return apperrors.ErrResourceNotFound
+	}
+
+	return nil
+}
+
+// ListByScanStatus retrieves up to limit files with the given scan status and an ID greater than afterID, in ID order
+func (r *FileRepository) ListByScanStatus(ctx context.Context, status models.ScanStatus, afterID int64, limit int) ([]*models.File, error) {
+	query := `
+		SELECT id, file_name, file_path, file_url, file_size, file_type,
+			   resource_type, resource_id, uploaded_by, content_hash, thumbnail_sizes, scan_status, scan_signature, created_at, updated_at
+		FROM files
+		WHERE scan_status = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(ctx, query, string(status), afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing files by scan status: %w", err)
+	}
+	defer rows.Close()
+
+	return scanFiles(rows)
+}
+
+// ListDetached retrieves files created before createdBefore whose resource no longer exists.
+// Exam and note files belong to their exam or note, COMMUNITY files to their community.
+// Profile photos and chat attachments are detached once no user, community or message refers to them.
+func (r *FileRepository) ListDetached(ctx context.Context, createdBefore time.Time) ([]*models.File, error) {
+	query := `
+		SELECT f.id, f.file_name, f.file_path, f.file_url, f.file_size, f.file_type,
+			   f.resource_type, f.resource_id, f.uploaded_by, f.content_hash, f.thumbnail_sizes, f.scan_status, f.scan_signature, f.created_at, f.updated_at
+		FROM files f
+		WHERE f.created_at < $1 AND CASE f.resource_type
+			WHEN 'PAST_EXAM' THEN NOT EXISTS (SELECT 1 FROM past_exams WHERE id = f.resource_id)
+			WHEN 'CLASS_NOTE' THEN NOT EXISTS (SELECT 1 FROM class_notes WHERE id = f.resource_id)
+			WHEN 'COMMUNITY' THEN NOT EXISTS (SELECT 1 FROM communities WHERE id = f.resource_id)
+			WHEN 'PROFILE_PHOTO' THEN NOT EXISTS (SELECT 1 FROM users WHERE profile_photo_file_id = f.id)
+			WHEN 'COMMUNITY_PROFILE_PHOTO' THEN NOT EXISTS (SELECT 1 FROM communities WHERE profile_photo_file_id = f.id)
+			WHEN 'CHAT_MESSAGE' THEN NOT EXISTS (SELECT 1 FROM chat_messages WHERE file_id = f.id)
+			ELSE FALSE
+		END
+		ORDER BY f.id
+	`
+
+	rows, err := r.db.Query(ctx, query, createdBefore)
+	if err != nil {
+		return nil, fmt.Errorf("error listing detached files: %w", err)
+	}
+	defer rows.Close()
+
+	return scanFiles(rows)
+}
+
+// scanFiles scans rows selected with the content_hash column into file models
+func scanFiles(rows pgx.Rows) ([]*models.File, error) {
+	var files []*models.File
+	for rows.Next() {
+		var file models.File
+		err := rows.Scan(
+			&file.ID,
+			&file.FileName,
+			&file.FilePath,
+			&file.FileURL,
+			&file.FileSize,
+			&file.FileType,
+			&file.ResourceType,
+			&file.ResourceID,
+			&file.UploadedBy,
+			&file.ContentHash,
+			&file.ThumbnailSizes,
+			&file.ScanStatus,
+			&file.ScanSignature,
+			&file.CreatedAt,
+			&file.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning file row: %w", err)
+		}
+		files = append(files, &file)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating file rows: %w", err)
+	}
+
+	return files, nil
+}
+
+// blobHash returns the hash of the blob stored at filePath, or nil for files stored on their own
+func blobHash(filePath string) *string {
+	hash, ok := filestorage.BlobHash("", filePath)
+	if !ok {
+		return nil
+	}
+	return &hash
+}
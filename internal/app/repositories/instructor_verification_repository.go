@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+)
+
+// InstructorVerificationRepository handles database operations for the admin review of
+// accounts that registered as PENDING_INSTRUCTOR
+type InstructorVerificationRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewInstructorVerificationRepository creates a new InstructorVerificationRepository
+func NewInstructorVerificationRepository(db *pgxpool.Pool) *InstructorVerificationRepository {
+	return &InstructorVerificationRepository{db: db}
+}
+
+// Request puts a user in the review queue, reopening an earlier decision
+func (r *InstructorVerificationRepository) Request(ctx context.Context, userID int64) error {
+	query := `
+		INSERT INTO instructor_verifications (user_id, status)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET status = EXCLUDED.status, requested_at = NOW(),
+			reviewed_by = NULL, reviewed_at = NULL, rejection_reason = NULL
+	`
+
+	if _, err := r.db.Exec(ctx, query, userID, models.InstructorVerificationPending); err != nil {
+		return fmt.Errorf("error requesting instructor verification: %w", err)
+	}
+	return nil
+}
+
+// List returns the verifications with the given status and their users, oldest request first
+func (r *InstructorVerificationRepository) List(ctx context.Context, status string) ([]*models.InstructorVerification, error) {
+	query := `
+		SELECT v.user_id, v.status, v.requested_at, v.reviewed_by, v.reviewed_at, v.rejection_reason,
+			u.email, u.first_name, u.last_name, u.role_type, u.email_verified, u.department_id, u.created_at
+		FROM instructor_verifications v
+		JOIN users u ON u.id = v.user_id
+		WHERE v.status = $1
+		ORDER BY v.requested_at, v.user_id
+	`
+
+	rows, err := r.db.Query(ctx, query, status)
+	if err != nil {
+		return nil, fmt.Errorf("error listing instructor verifications: %w", err)
+	}
+	defer rows.Close()
+
+	var verifications []*models.InstructorVerification
+	for rows.Next() {
+		var verification models.InstructorVerification
+		var user models.User
+		if err := rows.Scan(
+			&verification.UserID, &verification.Status, &verification.RequestedAt,
+			&verification.ReviewedBy, &verification.ReviewedAt, &verification.RejectionReason,
+			&user.Email, &user.FirstName, &user.LastName, &user.RoleType, &user.EmailVerified,
+			&user.DepartmentID, &user.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning instructor verification: %w", err)
+		}
+		user.ID = verification.UserID
+		verification.User = &user
+		verifications = append(verifications, &verification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating instructor verifications: %w", err)
+	}
+	return verifications, nil
+}
+
+// Review records an admin's decision on a pending verification and gives the user the role
+// that follows from it, in one transaction. It returns apperrors.ErrResourceNotFound if the
+// user has no pending verification.
+func (r *InstructorVerificationRepository) Review(ctx context.Context, userID int64, status string, role models.RoleType, reviewerID int64, rejectionReason *string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE instructor_verifications
+		SET status = $2, reviewed_by = $3, reviewed_at = NOW(), rejection_reason = $4
+		WHERE user_id = $1 AND status = $5
+	`, userID, status, reviewerID, rejectionReason, models.InstructorVerificationPending)
+	if err != nil {
+		return fmt.Errorf("error reviewing instructor verification: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return apperrors.ErrResourceNotFound
+	}
+
+	// Only a pending role changes, so an account promoted or demoted meanwhile keeps its role
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET role_type = $2, updated_at = NOW()
+		WHERE id = $1 AND role_type = $3
+	`, userID, role, models.RolePendingInstructor); err != nil {
+		return fmt.Errorf("error updating user role: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
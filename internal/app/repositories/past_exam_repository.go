@@ -9,6 +9,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/pkg/helpers"
 )
 
 // PastExamRepository handles database operations for past exams
@@ -21,11 +22,17 @@ func NewPastExamRepository(db *pgxpool.Pool) *PastExamRepository {
 	return &PastExamRepository{db: db}
 }
 
-// GetAll retrieves all past exams with filtering and pagination
-func (r *PastExamRepository) GetAll(ctx context.Context, facultyID *int64, departmentID *int64, courseCode *string, year *int, term *string, page, pageSize int) ([]models.PastExam, int64, error) {
+// PastExamSort is the cursor sort key of past exam lists, which are always ordered newest first
+const PastExamSort = "created_at:desc"
+
+// GetAll retrieves all past exams with filtering and pagination.
+// When cursor is set, the page is selected with a keyset condition instead of page/OFFSET
+// and hasMore reports whether further rows exist in the paging direction.
+// Files are only loaded when withFiles is set.
+func (r *PastExamRepository) GetAll(ctx context.Context, facultyID *int64, departmentID *int64, courseCode *string, year *int, term *string, page, pageSize int, cursor *helpers.Cursor, withFiles bool) ([]models.PastExam, int64, bool, error) {
 	// Build base query with table aliases
 	query := squirrel.Select(
-		"pe.id", "pe.year", "pe.term", "pe.course_code", "pe.title", "pe.content",
+		"pe.id", "pe.year", "pe.term", "pe.course_code", "pe.title", "pe.content", "pe.content_html", "pe.content_html_version",
 		"pe.department_id", "pe.instructor_id", "pe.created_at", "pe.updated_at",
 	).
 		From("past_exams pe").
@@ -51,26 +58,124 @@ func (r *PastExamRepository) GetAll(ctx context.Context, facultyID *int64, depar
 		query = query.Where("pe.term = ?", *term)
 	}
 
-	// Add pagination
-	offset := (page - 1) * pageSize
-	query = query.Limit(uint64(pageSize)).Offset(uint64(offset))
+	var total int64
+	before := cursor != nil && cursor.Before
+
+	if cursor == nil {
+		// Add pagination; the total comes back with every row
+		offset := (page - 1) * pageSize
+		query = query.OrderBy("pe.created_at DESC", "pe.id DESC").
+			Limit(uint64(pageSize)).Offset(uint64(offset)).
+			Column("COUNT(*) OVER()")
+	} else {
+		value, err := cursorArg(cursor, PastExamSort, sortKindTime)
+		if err != nil {
+			return nil, 0, false, err
+		}
+
+		// Count the whole filtered list, not just the rows past the cursor
+		countSQL, countArgs, err := countRows(query)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("error building count SQL: %w", err)
+		}
+		if err := r.db.QueryRow(ctx, countSQL, countArgs...).Scan(&total); err != nil {
+			return nil, 0, false, fmt.Errorf("error counting past exams: %w", err)
+		}
+
+		// Fetch one extra row to learn whether another page follows
+		query = query.Where(keysetCondition("pe.created_at", "pe.id", true, cursor, value)).
+			OrderBy(keysetOrderBy("pe.created_at", "pe.id", true, before)...).
+			Limit(uint64(pageSize + 1))
+	}
 
-	// Get total count
-	countQuery := query.Column("COUNT(*) OVER()")
-	sql, args, err := countQuery.ToSql()
+	sql, args, err := query.ToSql()
 	if err != nil {
-		return nil, 0, fmt.Errorf("error building SQL: %w", err)
+		return nil, 0, false, fmt.Errorf("error building SQL: %w", err)
 	}
 
 	rows, err := r.db.Query(ctx, sql, args...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error executing query: %w", err)
+		return nil, 0, false, fmt.Errorf("error executing query: %w", err)
 	}
 	defer rows.Close()
 
 	var exams []models.PastExam
-	var total int64
 
+	for rows.Next() {
+		var exam models.PastExam
+		var termStr string
+		dest := []interface{}{
+			&exam.ID,
+			&exam.Year,
+			&termStr,
+			&exam.CourseCode,
+			&exam.Title,
+			&exam.Content,
+			&exam.ContentHTML,
+			&exam.ContentHTMLVersion,
+			&exam.DepartmentID,
+			&exam.InstructorID,
+			&exam.CreatedAt,
+			&exam.UpdatedAt,
+		}
+		if cursor == nil {
+			dest = append(dest, &total)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, 0, false, fmt.Errorf("error scanning row: %w", err)
+		}
+		exam.Term = models.Term(termStr)
+		exams = append(exams, exam)
+	}
+
+	hasMore := false
+	if cursor != nil {
+		exams, hasMore = trimKeysetPage(exams, pageSize, before)
+	}
+
+	if !withFiles {
+		return exams, total, hasMore, nil
+	}
+
+	// Load the files of the whole page at once
+	if err := r.loadPastExamFiles(ctx, exams); err != nil {
+		return nil, 0, false, err
+	}
+
+	return exams, total, hasMore, nil
+}
+
+// GetByCourseCode retrieves all past exams of a course, optionally limited to a year range,
+// ordered by year, term and title. The files of all exams are loaded with one query.
+func (r *PastExamRepository) GetByCourseCode(ctx context.Context, courseCode string, fromYear, toYear *int) ([]models.PastExam, error) {
+	query := squirrel.Select(
+		"id", "year", "term", "course_code", "title", "content", "content_html", "content_html_version",
+		"department_id", "instructor_id", "created_at", "updated_at",
+	).
+		From("past_exams").
+		Where("course_code = ?", courseCode).
+		OrderBy("year DESC", "term", "title", "id").
+		PlaceholderFormat(squirrel.Dollar)
+
+	if fromYear != nil {
+		query = query.Where("year >= ?", *fromYear)
+	}
+	if toYear != nil {
+		query = query.Where("year <= ?", *toYear)
+	}
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building SQL: %w", err)
+	}
+
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var exams []models.PastExam
 	for rows.Next() {
 		var exam models.PastExam
 		var termStr string
@@ -81,35 +186,34 @@ func (r *PastExamRepository) GetAll(ctx context.Context, facultyID *int64, depar
 			&exam.CourseCode,
 			&exam.Title,
 			&exam.Content,
+			&exam.ContentHTML,
+			&exam.ContentHTMLVersion,
 			&exam.DepartmentID,
 			&exam.InstructorID,
 			&exam.CreatedAt,
 			&exam.UpdatedAt,
-			&total,
 		)
 		if err != nil {
-			return nil, 0, fmt.Errorf("error scanning row: %w", err)
+			return nil, fmt.Errorf("error scanning row: %w", err)
 		}
 		exam.Term = models.Term(termStr)
 		exams = append(exams, exam)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
 
-	// Load files for each exam
-	for i := range exams {
-		files, err := r.GetPastExamFiles(ctx, exams[i].ID)
-		if err != nil {
-			return nil, 0, fmt.Errorf("error getting files for exam %d: %w", exams[i].ID, err)
-		}
-		exams[i].Files = files
+	if err := r.loadPastExamFiles(ctx, exams); err != nil {
+		return nil, err
 	}
 
-	return exams, total, nil
+	return exams, nil
 }
 
 // GetByID retrieves a past exam by ID
 func (r *PastExamRepository) GetByID(ctx context.Context, id int64) (*models.PastExam, error) {
 	query := squirrel.Select(
-		"id", "year", "term", "course_code", "title", "content",
+		"id", "year", "term", "course_code", "title", "content", "content_html", "content_html_version",
 		"department_id", "instructor_id", "created_at", "updated_at",
 	).
 		From("past_exams").
@@ -130,6 +234,8 @@ func (r *PastExamRepository) GetByID(ctx context.Context, id int64) (*models.Pas
 		&exam.CourseCode,
 		&exam.Title,
 		&exam.Content,
+		&exam.ContentHTML,
+		&exam.ContentHTMLVersion,
 		&exam.DepartmentID,
 		&exam.InstructorID,
 		&exam.CreatedAt,
@@ -157,11 +263,11 @@ func (r *PastExamRepository) GetByID(ctx context.Context, id int64) (*models.Pas
 func (r *PastExamRepository) Create(ctx context.Context, exam *models.PastExam) (int64, error) {
 	query := squirrel.Insert("past_exams").
 		Columns(
-			"year", "term", "course_code", "title", "content",
+			"year", "term", "course_code", "title", "content", "content_html", "content_html_version",
 			"department_id", "instructor_id",
 		).
 		Values(
-			exam.Year, string(exam.Term), exam.CourseCode, exam.Title, exam.Content,
+			exam.Year, string(exam.Term), exam.CourseCode, exam.Title, exam.Content, exam.ContentHTML, exam.ContentHTMLVersion,
 			exam.DepartmentID, exam.InstructorID,
 		).
 		Suffix("RETURNING id").
@@ -189,6 +295,8 @@ func (r *PastExamRepository) Update(ctx context.Context, exam *models.PastExam)
 		Set("course_code", exam.CourseCode).
 		Set("title", exam.Title).
 		Set("content", exam.Content).
+		Set("content_html", exam.ContentHTML).
+		Set("content_html_version", exam.ContentHTMLVersion).
 		Set("department_id", exam.DepartmentID).
 		Set("instructor_id", exam.InstructorID).
 		Set("updated_at", time.Now()).
@@ -283,10 +391,11 @@ func (r *PastExamRepository) RemoveFileFromPastExam(ctx context.Context, pastExa
 func (r *PastExamRepository) GetPastExamFiles(ctx context.Context, pastExamID int64) ([]*models.File, error) {
 	query := squirrel.Select("f.id", "f.file_name", "f.file_path", "f.file_url",
 		"f.file_size", "f.file_type", "f.resource_type", "f.resource_id",
-		"f.uploaded_by", "f.created_at", "f.updated_at").
+		"f.uploaded_by", "f.scan_status", "f.created_at", "f.updated_at").
 		From("files f").
 		Join("past_exam_files pef ON f.id = pef.file_id").
 		Where("pef.past_exam_id = ?", pastExamID).
+		Where("f.scan_status = ?", string(models.ScanStatusClean)). // Hidden until scanned clean
 		PlaceholderFormat(squirrel.Dollar)
 
 	sql, args, err := query.ToSql()
@@ -313,6 +422,7 @@ func (r *PastExamRepository) GetPastExamFiles(ctx context.Context, pastExamID in
 			&file.ResourceType,
 			&file.ResourceID,
 			&file.UploadedBy,
+			&file.ScanStatus,
 			&file.CreatedAt,
 			&file.UpdatedAt,
 		)
@@ -324,3 +434,69 @@ func (r *PastExamRepository) GetPastExamFiles(ctx context.Context, pastExamID in
 
 	return files, nil
 }
+
+// loadPastExamFiles sets the files of every given exam with a single query
+func (r *PastExamRepository) loadPastExamFiles(ctx context.Context, exams []models.PastExam) error {
+	if len(exams) == 0 {
+		return nil
+	}
+
+	examIDs := make([]int64, len(exams))
+	for i := range exams {
+		examIDs[i] = exams[i].ID
+	}
+
+	query := squirrel.Select("pef.past_exam_id", "f.id", "f.file_name", "f.file_path", "f.file_url",
+		"f.file_size", "f.file_type", "f.resource_type", "f.resource_id",
+		"f.uploaded_by", "f.scan_status", "f.created_at", "f.updated_at").
+		From("files f").
+		Join("past_exam_files pef ON f.id = pef.file_id").
+		Where("pef.past_exam_id = ANY(?)", examIDs).
+		Where("f.scan_status = ?", string(models.ScanStatusClean)). // Hidden until scanned clean
+		OrderBy("f.id").
+		PlaceholderFormat(squirrel.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building SQL: %w", err)
+	}
+
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("error getting past exam files: %w", err)
+	}
+	defer rows.Close()
+
+	filesByExam := make(map[int64][]*models.File, len(exams))
+	for rows.Next() {
+		var examID int64
+		var file models.File
+		err := rows.Scan(
+			&examID,
+			&file.ID,
+			&file.FileName,
+			&file.FilePath,
+			&file.FileURL,
+			&file.FileSize,
+			&file.FileType,
+			&file.ResourceType,
+			&file.ResourceID,
+			&file.UploadedBy,
+			&file.ScanStatus,
+			&file.CreatedAt,
+			&file.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("error scanning past exam file: %w", err)
+		}
+		filesByExam[examID] = append(filesByExam[examID], &file)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating past exam files: %w", err)
+	}
+
+	for i := range exams {
+		exams[i].Files = filesByExam[exams[i].ID]
+	}
+	return nil
+}
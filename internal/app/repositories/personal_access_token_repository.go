@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+)
+
+// PersonalAccessTokenRepository handles database operations for personal access tokens
+type PersonalAccessTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewPersonalAccessTokenRepository creates a new PersonalAccessTokenRepository
+func NewPersonalAccessTokenRepository(db *pgxpool.Pool) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{db: db}
+}
+
+// personalAccessTokenColumns lists the columns scanned by scanPersonalAccessToken
+const personalAccessTokenColumns = `id, user_id, name, token_hash, token_hint, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+// scanPersonalAccessToken scans a row selected with personalAccessTokenColumns
+func scanPersonalAccessToken(row pgx.Row) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.TokenHash, &token.TokenHint, &token.Scopes,
+		&token.ExpiresAt, &token.LastUsedAt, &token.RevokedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Create stores a token and sets its ID and creation time
+func (r *PersonalAccessTokenRepository) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token_hash, token_hint, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRow(ctx, query,
+		token.UserID, token.Name, token.TokenHash, token.TokenHint, token.Scopes, token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating personal access token: %w", err)
+	}
+	return nil
+}
+
+// GetByHash returns the token with a hash, or apperrors.ErrTokenNotFound
+func (r *PersonalAccessTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	query := `SELECT ` + personalAccessTokenColumns + ` FROM personal_access_tokens WHERE token_hash = $1`
+
+	token, err := scanPersonalAccessToken(r.db.QueryRow(ctx, query, tokenHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("error getting personal access token: %w", err)
+	}
+	return token, nil
+}
+
+// ListByUser returns the tokens of a user, newest first
+func (r *PersonalAccessTokenRepository) ListByUser(ctx context.Context, userID int64) ([]*models.PersonalAccessToken, error) {
+	query := `SELECT ` + personalAccessTokenColumns + ` FROM personal_access_tokens WHERE user_id = $1 ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing personal access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*models.PersonalAccessToken
+	for rows.Next() {
+		token, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning personal access token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating personal access tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// CountActive counts the tokens of a user that are neither revoked nor expired
+func (r *PersonalAccessTokenRepository) CountActive(ctx context.Context, userID int64) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+	`
+
+	var count int
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting personal access tokens: %w", err)
+	}
+	return count, nil
+}
+
+// TouchLastUsed records that a token was used. To spare a write on every request it only
+// moves last_used_at forward once a minute.
+func (r *PersonalAccessTokenRepository) TouchLastUsed(ctx context.Context, id int64) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`
+
+	if _, err := r.db.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("error updating personal access token: %w", err)
+	}
+	return nil
+}
+
+// Revoke revokes a token of a user. It returns apperrors.ErrResourceNotFound if the user has
+// no such token that is not revoked yet.
+func (r *PersonalAccessTokenRepository) Revoke(ctx context.Context, userID, id int64) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return fmt.Errorf("error revoking personal access token: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return apperrors.ErrResourceNotFound
+	}
+	return nil
+}
@@ -20,34 +20,58 @@ import (
 
 // Repositories holds all the repository instances
 type Repositories struct {
-	UserRepository                 *UserRepository
-	FacultyRepository              *FacultyRepository
-	DepartmentRepository           *DepartmentRepository
-	TokenRepository                *TokenRepository
-	VerificationTokenRepository    *VerificationTokenRepository
-	PasswordResetTokenRepository   *PasswordResetTokenRepository
-	PastExamRepository             *PastExamRepository
-	ClassNoteRepository            *ClassNoteRepository
-	FileRepository                 *FileRepository
-	CommunityRepository            *CommunityRepository
-	CommunityParticipantRepository *CommunityParticipantRepository
-	ChatRepository                 *ChatRepository
+	UserRepository                   *UserRepository
+	FacultyRepository                *FacultyRepository
+	DepartmentRepository             *DepartmentRepository
+	TokenRepository                  *TokenRepository
+	VerificationTokenRepository      *VerificationTokenRepository
+	PasswordResetTokenRepository     *PasswordResetTokenRepository
+	TOTPRepository                   *TOTPRepository
+	RevokedTokenRepository           *RevokedTokenRepository
+	SigningKeyRepository             *SigningKeyRepository
+	AuthThrottleRepository           *AuthThrottleRepository
+	InstructorVerificationRepository *InstructorVerificationRepository
+	RoleAssignmentRepository         *RoleAssignmentRepository
+	UserIdentityRepository           *UserIdentityRepository
+	SSOLoginStateRepository          *SSOLoginStateRepository
+	PersonalAccessTokenRepository    *PersonalAccessTokenRepository
+	PastExamRepository               *PastExamRepository
+	ClassNoteRepository              *ClassNoteRepository
+	FileRepository                   *FileRepository
+	FileFingerprintRepository        *FileFingerprintRepository
+	BlobRepository                   *BlobRepository
+	CommunityRepository              *CommunityRepository
+	CommunityParticipantRepository   *CommunityParticipantRepository
+	ChatRepository                   *ChatRepository
+	UploadSessionRepository          *UploadSessionRepository
 }
 
 // NewRepositories initializes all repositories
 func NewRepositories(db *pgxpool.Pool) *Repositories {
 	return &Repositories{
-		UserRepository:                 NewUserRepository(db),
-		FacultyRepository:              NewFacultyRepository(db),
-		DepartmentRepository:           NewDepartmentRepository(db),
-		TokenRepository:                NewTokenRepository(db),
-		VerificationTokenRepository:    NewVerificationTokenRepository(db),
-		PasswordResetTokenRepository:   NewPasswordResetTokenRepository(db),
-		PastExamRepository:             NewPastExamRepository(db),
-		ClassNoteRepository:            NewClassNoteRepository(db),
-		FileRepository:                 NewFileRepository(db),
-		CommunityRepository:            NewCommunityRepository(db),
-		CommunityParticipantRepository: NewCommunityParticipantRepository(db),
-		ChatRepository:                 NewChatRepository(db),
+		UserRepository:                   NewUserRepository(db),
+		FacultyRepository:                NewFacultyRepository(db),
+		DepartmentRepository:             NewDepartmentRepository(db),
+		TokenRepository:                  NewTokenRepository(db),
+		VerificationTokenRepository:      NewVerificationTokenRepository(db),
+		PasswordResetTokenRepository:     NewPasswordResetTokenRepository(db),
+		TOTPRepository:                   NewTOTPRepository(db),
+		RevokedTokenRepository:           NewRevokedTokenRepository(db),
+		SigningKeyRepository:             NewSigningKeyRepository(db),
+		AuthThrottleRepository:           NewAuthThrottleRepository(db),
+		InstructorVerificationRepository: NewInstructorVerificationRepository(db),
+		RoleAssignmentRepository:         NewRoleAssignmentRepository(db),
+		UserIdentityRepository:           NewUserIdentityRepository(db),
+		SSOLoginStateRepository:          NewSSOLoginStateRepository(db),
+		PersonalAccessTokenRepository:    NewPersonalAccessTokenRepository(db),
+		PastExamRepository:               NewPastExamRepository(db),
+		ClassNoteRepository:              NewClassNoteRepository(db),
+		FileRepository:                   NewFileRepository(db),
+		FileFingerprintRepository:        NewFileFingerprintRepository(db),
+		BlobRepository:                   NewBlobRepository(db),
+		CommunityRepository:              NewCommunityRepository(db),
+		CommunityParticipantRepository:   NewCommunityParticipantRepository(db),
+		ChatRepository:                   NewChatRepository(db),
+		UploadSessionRepository:          NewUploadSessionRepository(db),
 	}
 }
@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yigit/unisphere/internal/app/models"
+)
+
+// RevokedTokenRepository handles database operations for access token revocation: the jti
+// denylist, the per-user cutoff and the revoked sessions. The denylist and the cutoffs are kept
+// when the user is deleted.
+type RevokedTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewRevokedTokenRepository creates a new RevokedTokenRepository
+func NewRevokedTokenRepository(db *pgxpool.Pool) *RevokedTokenRepository {
+	return &RevokedTokenRepository{db: db}
+}
+
+// Revoke denylists an access token until it expires
+func (r *RevokedTokenRepository) Revoke(ctx context.Context, jti string, userID int64, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_access_tokens (jti, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING
+	`
+
+	if _, err := r.db.Exec(ctx, query, jti, userID, expiresAt); err != nil {
+		return fmt.Errorf("error revoking access token: %w", err)
+	}
+	return nil
+}
+
+// ListActive returns the denylisted access tokens that have not expired yet
+func (r *RevokedTokenRepository) ListActive(ctx context.Context) ([]models.RevokedAccessToken, error) {
+	query := `
+		SELECT jti, user_id, expires_at, revoked_at
+		FROM revoked_access_tokens
+		WHERE expires_at > NOW()
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing revoked access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []models.RevokedAccessToken
+	for rows.Next() {
+		var token models.RevokedAccessToken
+		if err := rows.Scan(&token.JTI, &token.UserID, &token.ExpiresAt, &token.RevokedAt); err != nil {
+			return nil, fmt.Errorf("error scanning revoked access token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating revoked access tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// DeleteExpired removes denylisted access tokens that have expired on their own
+func (r *RevokedTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM revoked_access_tokens WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired revoked access tokens: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// SetTokensInvalidBefore invalidates the access tokens of a user issued before the given time.
+// An earlier cutoff never replaces a later one.
+func (r *RevokedTokenRepository) SetTokensInvalidBefore(ctx context.Context, userID int64, cutoff time.Time) error {
+	query := `
+		INSERT INTO user_token_cutoffs (user_id, invalid_before)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET invalid_before = GREATEST(user_token_cutoffs.invalid_before, EXCLUDED.invalid_before)
+	`
+
+	if _, err := r.db.Exec(ctx, query, userID, cutoff); err != nil {
+		return fmt.Errorf("error setting tokens invalid before: %w", err)
+	}
+	return nil
+}
+
+// GetTokensInvalidBefore returns the cutoff of a user, or nil if their tokens were never revoked
+func (r *RevokedTokenRepository) GetTokensInvalidBefore(ctx context.Context, userID int64) (*time.Time, error) {
+	var cutoff time.Time
+	err := r.db.QueryRow(ctx, `SELECT invalid_before FROM user_token_cutoffs WHERE user_id = $1`, userID).Scan(&cutoff)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting token cutoff: %w", err)
+	}
+	return &cutoff, nil
+}
+
+// ListTokensInvalidBefore returns the cutoffs set after since, by user ID. Older cutoffs
+// only concern tokens that have expired anyway.
+func (r *RevokedTokenRepository) ListTokensInvalidBefore(ctx context.Context, since time.Time) (map[int64]time.Time, error) {
+	query := `
+		SELECT user_id, invalid_before
+		FROM user_token_cutoffs
+		WHERE invalid_before > $1
+	`
+
+	rows, err := r.db.Query(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("error listing token cutoffs: %w", err)
+	}
+	defer rows.Close()
+
+	cutoffs := make(map[int64]time.Time)
+	for rows.Next() {
+		var userID int64
+		var cutoff time.Time
+		if err := rows.Scan(&userID, &cutoff); err != nil {
+			return nil, fmt.Errorf("error scanning token cutoff: %w", err)
+		}
+		cutoffs[userID] = cutoff
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating token cutoffs: %w", err)
+	}
+	return cutoffs, nil
+}
+
+// ListRevokedSessions returns the IDs of the token families revoked after since, with the time
+// they were revoked. Access tokens name their family as their session.
+func (r *RevokedTokenRepository) ListRevokedSessions(ctx context.Context, since time.Time) (map[int64]time.Time, error) {
+	query := `
+		SELECT id, revoked_at
+		FROM token_families
+		WHERE revoked_at > $1
+	`
+
+	rows, err := r.db.Query(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("error listing revoked sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make(map[int64]time.Time)
+	for rows.Next() {
+		var sessionID int64
+		var revokedAt time.Time
+		if err := rows.Scan(&sessionID, &revokedAt); err != nil {
+			return nil, fmt.Errorf("error scanning revoked session: %w", err)
+		}
+		sessions[sessionID] = revokedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating revoked sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// DeleteExpiredCutoffs removes the cutoffs set before the given time of users that have been
+// deleted. Cutoffs of existing users are kept, as they also revoke personal access tokens,
+// which may not expire.
+func (r *RevokedTokenRepository) DeleteExpiredCutoffs(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM user_token_cutoffs c
+		WHERE c.invalid_before <= $1
+		AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = c.user_id)
+	`
+
+	tag, err := r.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired token cutoffs: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+)
+
+// RoleAssignmentRepository handles database operations for roles granted to users within a
+// scope
+type RoleAssignmentRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewRoleAssignmentRepository creates a new RoleAssignmentRepository
+func NewRoleAssignmentRepository(db *pgxpool.Pool) *RoleAssignmentRepository {
+	return &RoleAssignmentRepository{db: db}
+}
+
+// Create stores a role assignment and sets its ID and creation time. It returns
+// apperrors.ErrResourceAlreadyExists if the user already holds the role in that scope.
+func (r *RoleAssignmentRepository) Create(ctx context.Context, assignment *models.RoleAssignment) error {
+	query := `
+		INSERT INTO role_assignments (user_id, role, scope_type, scope_id, granted_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRow(ctx, query,
+		assignment.UserID, assignment.Role, assignment.ScopeType, assignment.ScopeID, assignment.GrantedBy,
+	).Scan(&assignment.ID, &assignment.CreatedAt)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return apperrors.ErrResourceAlreadyExists
+		}
+		return fmt.Errorf("error creating role assignment: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns a role assignment, or apperrors.ErrResourceNotFound
+func (r *RoleAssignmentRepository) GetByID(ctx context.Context, id int64) (*models.RoleAssignment, error) {
+	query := `
+		SELECT id, user_id, role, scope_type, scope_id, granted_by, created_at
+		FROM role_assignments
+		WHERE id = $1
+	`
+
+	var assignment models.RoleAssignment
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&assignment.ID, &assignment.UserID, &assignment.Role, &assignment.ScopeType,
+		&assignment.ScopeID, &assignment.GrantedBy, &assignment.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("error getting role assignment: %w", err)
+	}
+	return &assignment, nil
+}
+
+// List returns the role assignments of a user, or of every user if userID is nil, oldest first
+func (r *RoleAssignmentRepository) List(ctx context.Context, userID *int64) ([]*models.RoleAssignment, error) {
+	query := `
+		SELECT id, user_id, role, scope_type, scope_id, granted_by, created_at
+		FROM role_assignments
+		WHERE $1::bigint IS NULL OR user_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing role assignments: %w", err)
+	}
+	defer rows.Close()
+
+	var assignments []*models.RoleAssignment
+	for rows.Next() {
+		var assignment models.RoleAssignment
+		if err := rows.Scan(
+			&assignment.ID, &assignment.UserID, &assignment.Role, &assignment.ScopeType,
+			&assignment.ScopeID, &assignment.GrantedBy, &assignment.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning role assignment: %w", err)
+		}
+		assignments = append(assignments, &assignment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating role assignments: %w", err)
+	}
+	return assignments, nil
+}
+
+// ListGrants returns every role a user holds: the role of their account, as a global grant,
+// followed by their role assignments. Only ID-less RoleAssignments are returned.
+func (r *RoleAssignmentRepository) ListGrants(ctx context.Context, userID int64) ([]*models.RoleAssignment, error) {
+	query := `
+		SELECT role_type::text, 'global', NULL::bigint FROM users WHERE id = $1
+		UNION ALL
+		SELECT role, scope_type, scope_id FROM role_assignments WHERE user_id = $1
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing role grants: %w", err)
+	}
+	defer rows.Close()
+
+	var grants []*models.RoleAssignment
+	for rows.Next() {
+		grant := models.RoleAssignment{UserID: userID}
+		if err := rows.Scan(&grant.Role, &grant.ScopeType, &grant.ScopeID); err != nil {
+			return nil, fmt.Errorf("error scanning role grant: %w", err)
+		}
+		grants = append(grants, &grant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating role grants: %w", err)
+	}
+	return grants, nil
+}
+
+// Delete removes a role assignment. It returns apperrors.ErrResourceNotFound if it does not
+// exist.
+func (r *RoleAssignmentRepository) Delete(ctx context.Context, id int64) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM role_assignments WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting role assignment: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return apperrors.ErrResourceNotFound
+	}
+	return nil
+}
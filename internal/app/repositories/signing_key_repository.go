@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yigit/unisphere/internal/app/models"
+)
+
+// SigningKeyRepository handles database operations for JWT signing keys
+type SigningKeyRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewSigningKeyRepository creates a new SigningKeyRepository
+func NewSigningKeyRepository(db *pgxpool.Pool) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// List returns all signing keys, oldest first
+func (r *SigningKeyRepository) List(ctx context.Context) ([]models.JWTSigningKey, error) {
+	query := `
+		SELECT kid, algorithm, private_key, public_key, created_at
+		FROM jwt_signing_keys
+		ORDER BY created_at, kid
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.JWTSigningKey
+	for rows.Next() {
+		var key models.JWTSigningKey
+		if err := rows.Scan(&key.KID, &key.Algorithm, &key.PrivateKey, &key.PublicKey, &key.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning signing key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+// CreateIfDue stores a new signing key unless a key of the same algorithm was created after
+// dueBefore, so instances rotating at the same time add only one key. It reports whether
+// the key was stored.
+func (r *SigningKeyRepository) CreateIfDue(ctx context.Context, key *models.JWTSigningKey, dueBefore time.Time) (bool, error) {
+	query := `
+		INSERT INTO jwt_signing_keys (kid, algorithm, private_key, public_key, created_at)
+		SELECT $1, $2, $3, $4, $5
+		WHERE NOT EXISTS (
+			SELECT 1 FROM jwt_signing_keys WHERE algorithm = $2 AND created_at > $6
+		)
+	`
+
+	tag, err := r.db.Exec(ctx, query, key.KID, key.Algorithm, key.PrivateKey, key.PublicKey, key.CreatedAt, dueBefore)
+	if err != nil {
+		return false, fmt.Errorf("error creating signing key: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Delete removes signing keys by kid
+func (r *SigningKeyRepository) Delete(ctx context.Context, kids []string) error {
+	if len(kids) == 0 {
+		return nil
+	}
+	if _, err := r.db.Exec(ctx, `DELETE FROM jwt_signing_keys WHERE kid = ANY($1)`, kids); err != nil {
+		return fmt.Errorf("error deleting signing keys: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yigit/unisphere/internal/app/models"
+)
+
+// SSOLoginStateRepository handles database operations for pending single sign-on logins
+type SSOLoginStateRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewSSOLoginStateRepository creates a new SSOLoginStateRepository
+func NewSSOLoginStateRepository(db *pgxpool.Pool) *SSOLoginStateRepository {
+	return &SSOLoginStateRepository{db: db}
+}
+
+// Create stores a pending login and drops the ones that expired
+func (r *SSOLoginStateRepository) Create(ctx context.Context, state *models.SSOLoginState) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM sso_login_states WHERE expires_at <= NOW()`); err != nil {
+		return fmt.Errorf("error deleting expired sso login states: %w", err)
+	}
+
+	query := `
+		INSERT INTO sso_login_states (state, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`
+
+	err := r.db.QueryRow(ctx, query, state.State, state.Nonce, state.CodeVerifier, state.ExpiresAt).
+		Scan(&state.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating sso login state: %w", err)
+	}
+	return nil
+}
+
+// Consume deletes a pending login and returns it, or nil if it does not exist or expired.
+// A state can be consumed only once.
+func (r *SSOLoginStateRepository) Consume(ctx context.Context, state string) (*models.SSOLoginState, error) {
+	query := `
+		DELETE FROM sso_login_states
+		WHERE state = $1 AND expires_at > NOW()
+		RETURNING state, nonce, code_verifier, expires_at, created_at
+	`
+
+	var loginState models.SSOLoginState
+	err := r.db.QueryRow(ctx, query, state).Scan(&loginState.State, &loginState.Nonce,
+		&loginState.CodeVerifier, &loginState.ExpiresAt, &loginState.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error consuming sso login state: %w", err)
+	}
+	return &loginState, nil
+}
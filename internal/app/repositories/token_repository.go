@@ -9,6 +9,7 @@ import (
 	"github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v5" // Import pgx for ErrNoRows
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yigit/unisphere/internal/app/models"
 	"github.com/yigit/unisphere/internal/pkg/apperrors"
 	"github.com/yigit/unisphere/internal/pkg/dberrors" // Import the dberrors package
 	"github.com/yigit/unisphere/internal/pkg/logger"   // Import logger
@@ -28,11 +29,11 @@ func NewTokenRepository(db *pgxpool.Pool) *TokenRepository {
 	}
 }
 
-// CreateToken creates a new refresh token
-func (r *TokenRepository) CreateToken(ctx context.Context, token string, userID int64, expiryDate time.Time) error {
+// CreateToken creates a new refresh token in a token family
+func (r *TokenRepository) CreateToken(ctx context.Context, token string, userID int64, familyID int64, expiryDate time.Time) error {
 	sql, args, err := r.sb.Insert("refresh_tokens").
-		Columns("token", "user_id", "expiry_date", "is_revoked", "created_at").
-		Values(token, userID, expiryDate, false, time.Now()).
+		Columns("token", "user_id", "family_id", "expiry_date", "is_revoked", "created_at").
+		Values(token, userID, familyID, expiryDate, false, time.Now()).
 		ToSql()
 
 	if err != nil {
@@ -55,43 +56,55 @@ func (r *TokenRepository) CreateToken(ctx context.Context, token string, userID
 	return nil
 }
 
-// GetTokenByValue retrieves token information by value
-func (r *TokenRepository) GetTokenByValue(ctx context.Context, token string) (int64, time.Time, bool, error) {
-	var userID int64
-	var expiryDate time.Time
-	var isRevoked bool
-
-	sql, args, err := r.sb.Select("user_id", "expiry_date", "is_revoked").
+// GetToken retrieves a refresh token by value, whether or not it is revoked or expired
+func (r *TokenRepository) GetToken(ctx context.Context, token string) (*models.RefreshToken, error) {
+	sql, args, err := r.sb.Select("token", "user_id", "family_id", "expiry_date", "is_revoked", "created_at").
 		From("refresh_tokens").
 		Where(squirrel.Eq{"token": token}).
 		Limit(1).
 		ToSql()
 
 	if err != nil {
-		logger.Error().Err(err).Msg("Error building get token by value SQL")
-		return 0, time.Time{}, false, fmt.Errorf("failed to build get token query: %w", err)
+		logger.Error().Err(err).Msg("Error building get token SQL")
+		return nil, fmt.Errorf("failed to build get token query: %w", err)
 	}
 
-	err = r.db.QueryRow(ctx, sql, args...).Scan(&userID, &expiryDate, &isRevoked)
+	var refreshToken models.RefreshToken
+	err = r.db.QueryRow(ctx, sql, args...).Scan(
+		&refreshToken.Token, &refreshToken.UserID, &refreshToken.FamilyID,
+		&refreshToken.ExpiryDate, &refreshToken.IsRevoked, &refreshToken.CreatedAt,
+	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return 0, time.Time{}, false, apperrors.ErrTokenNotFound
+			return nil, apperrors.ErrTokenNotFound
 		}
-		logger.Error().Err(err).Str("token", token).Msg("Error scanning token row")
-		return 0, time.Time{}, false, fmt.Errorf("error retrieving token: %w", err)
+		logger.Error().Err(err).Msg("Error scanning token row")
+		return nil, fmt.Errorf("error retrieving token: %w", err)
 	}
 
-	// Check if token is revoked
-	if isRevoked {
-		return 0, time.Time{}, false, apperrors.ErrTokenRevoked
+	return &refreshToken, nil
+}
+
+// RotateToken revokes a refresh token that is being exchanged for a new one. It reports
+// false when the token was revoked already, e.g. by a concurrent refresh with the same token.
+func (r *TokenRepository) RotateToken(ctx context.Context, token string) (bool, error) {
+	sql, args, err := r.sb.Update("refresh_tokens").
+		Set("is_revoked", true).
+		Where(squirrel.Eq{"token": token, "is_revoked": false}).
+		ToSql()
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Error building rotate token SQL")
+		return false, fmt.Errorf("failed to build rotate token query: %w", err)
 	}
 
-	// Check token expiration
-	if expiryDate.Before(time.Now()) {
-		return 0, time.Time{}, false, apperrors.ErrTokenExpired
+	cmdTag, err := r.db.Exec(ctx, sql, args...)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error executing rotate token query")
+		return false, fmt.Errorf("error rotating token: %w", err)
 	}
 
-	return userID, expiryDate, isRevoked, nil
+	return cmdTag.RowsAffected() == 1, nil
 }
 
 // RevokeToken revokes a token
@@ -144,6 +157,207 @@ func (r *TokenRepository) RevokeAllUserTokens(ctx context.Context, userID int64)
 	return nil
 }
 
+// CreateFamily starts a token family for a login and returns its ID
+func (r *TokenRepository) CreateFamily(ctx context.Context, family *models.TokenFamily) (int64, error) {
+	sql, args, err := r.sb.Insert("token_families").
+		Columns("user_id", "device_label", "user_agent", "ip_address", "expires_at").
+		Values(family.UserID, family.DeviceLabel, family.UserAgent, family.IPAddress, family.ExpiresAt).
+		Suffix("RETURNING id").
+		ToSql()
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Error building create token family SQL")
+		return 0, fmt.Errorf("failed to build create token family query: %w", err)
+	}
+
+	var id int64
+	if err := r.db.QueryRow(ctx, sql, args...).Scan(&id); err != nil {
+		logger.Error().Err(err).Int64("userID", family.UserID).Msg("Error executing create token family query")
+		return 0, fmt.Errorf("error creating token family: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetFamily retrieves a token family by ID
+func (r *TokenRepository) GetFamily(ctx context.Context, familyID int64) (*models.TokenFamily, error) {
+	sql, args, err := r.sb.Select(tokenFamilyColumns...).
+		From("token_families").
+		Where(squirrel.Eq{"id": familyID}).
+		ToSql()
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Error building get token family SQL")
+		return nil, fmt.Errorf("failed to build get token family query: %w", err)
+	}
+
+	family, err := scanTokenFamily(r.db.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("error retrieving token family: %w", err)
+	}
+
+	return family, nil
+}
+
+// TouchFamily records that a family's token was just issued or refreshed, from where, and
+// until when the new token is valid
+func (r *TokenRepository) TouchFamily(ctx context.Context, familyID int64, userAgent, ipAddress string, expiresAt time.Time) error {
+	sql, args, err := r.sb.Update("token_families").
+		Set("user_agent", userAgent).
+		Set("ip_address", ipAddress).
+		Set("expires_at", expiresAt).
+		Set("last_used_at", time.Now()).
+		Where(squirrel.Eq{"id": familyID}).
+		ToSql()
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Error building touch token family SQL")
+		return fmt.Errorf("failed to build touch token family query: %w", err)
+	}
+
+	if _, err := r.db.Exec(ctx, sql, args...); err != nil {
+		logger.Error().Err(err).Int64("familyID", familyID).Msg("Error executing touch token family query")
+		return fmt.Errorf("error updating token family: %w", err)
+	}
+
+	return nil
+}
+
+// ListActiveFamilies returns the families of a user that are neither revoked nor expired,
+// most recently used first
+func (r *TokenRepository) ListActiveFamilies(ctx context.Context, userID int64) ([]*models.TokenFamily, error) {
+	sql, args, err := r.sb.Select(tokenFamilyColumns...).
+		From("token_families").
+		Where(squirrel.Eq{"user_id": userID, "revoked_at": nil}).
+		Where(squirrel.Gt{"expires_at": time.Now()}).
+		OrderBy("last_used_at DESC", "id DESC").
+		ToSql()
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Error building list token families SQL")
+		return nil, fmt.Errorf("failed to build list token families query: %w", err)
+	}
+
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		logger.Error().Err(err).Int64("userID", userID).Msg("Error executing list token families query")
+		return nil, fmt.Errorf("error listing token families: %w", err)
+	}
+	defer rows.Close()
+
+	var families []*models.TokenFamily
+	for rows.Next() {
+		family, err := scanTokenFamily(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning token family: %w", err)
+		}
+		families = append(families, family)
+	}
+
+	return families, rows.Err()
+}
+
+// RevokeFamily revokes a token family of a user and all its tokens. It reports false when the
+// user has no such family that is still active.
+func (r *TokenRepository) RevokeFamily(ctx context.Context, userID, familyID int64, reason string) (bool, error) {
+	revoked, err := r.revokeFamilies(ctx, squirrel.Eq{"id": familyID, "user_id": userID}, reason)
+	return revoked > 0, err
+}
+
+// RevokeAllFamilies revokes every active token family of a user and all their tokens.
+// It returns how many families were revoked.
+func (r *TokenRepository) RevokeAllFamilies(ctx context.Context, userID int64, reason string) (int64, error) {
+	return r.revokeFamilies(ctx, squirrel.Eq{"user_id": userID}, reason)
+}
+
+// RevokeOtherFamilies revokes every active token family of a user but one, and all their
+// tokens. It returns how many families were revoked.
+func (r *TokenRepository) RevokeOtherFamilies(ctx context.Context, userID, keepFamilyID int64, reason string) (int64, error) {
+	return r.revokeFamilies(ctx, squirrel.And{
+		squirrel.Eq{"user_id": userID},
+		squirrel.NotEq{"id": keepFamilyID},
+	}, reason)
+}
+
+// revokeFamilies revokes the active token families matching where, with their tokens
+func (r *TokenRepository) revokeFamilies(ctx context.Context, where squirrel.Sqlizer, reason string) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sql, args, err := r.sb.Update("token_families").
+		Set("revoked_at", time.Now()).
+		Set("revoked_reason", reason).
+		Where(where).
+		Where(squirrel.Eq{"revoked_at": nil}).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build revoke token families query: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error revoking token families: %w", err)
+	}
+	var familyIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning token family: %w", err)
+		}
+		familyIDs = append(familyIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error revoking token families: %w", err)
+	}
+	if len(familyIDs) == 0 {
+		return 0, nil
+	}
+
+	sql, args, err = r.sb.Update("refresh_tokens").
+		Set("is_revoked", true).
+		Where(squirrel.Eq{"family_id": familyIDs, "is_revoked": false}).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build revoke family tokens query: %w", err)
+	}
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return 0, fmt.Errorf("error revoking family tokens: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return int64(len(familyIDs)), nil
+}
+
+// tokenFamilyColumns are the columns scanTokenFamily reads
+var tokenFamilyColumns = []string{
+	"id", "user_id", "device_label", "user_agent", "ip_address",
+	"created_at", "last_used_at", "expires_at", "revoked_at", "revoked_reason",
+}
+
+// scanTokenFamily scans a row of tokenFamilyColumns
+func scanTokenFamily(row pgx.Row) (*models.TokenFamily, error) {
+	var family models.TokenFamily
+	err := row.Scan(
+		&family.ID, &family.UserID, &family.DeviceLabel, &family.UserAgent, &family.IPAddress,
+		&family.CreatedAt, &family.LastUsedAt, &family.ExpiresAt, &family.RevokedAt, &family.RevokedReason,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &family, nil
+}
+
 // CleanupExpiredTokens removes expired tokens from the database
 func (r *TokenRepository) CleanupExpiredTokens(ctx context.Context) (int64, error) {
 	thirtyDaysAgo := time.Now().Add(-30 * 24 * time.Hour)
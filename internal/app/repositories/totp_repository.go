@@ -0,0 +1,222 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+)
+
+// TOTPRepository handles database operations for TOTP authenticators and recovery codes
+type TOTPRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewTOTPRepository creates a new TOTPRepository
+func NewTOTPRepository(db *pgxpool.Pool) *TOTPRepository {
+	return &TOTPRepository{db: db}
+}
+
+// GetByUserID returns the authenticator of a user, or nil if the user has none
+func (r *TOTPRepository) GetByUserID(ctx context.Context, userID int64) (*models.UserTOTP, error) {
+	query := `
+		SELECT user_id, secret, enabled, last_used_step, failed_attempts, enabled_at, created_at, updated_at
+		FROM user_totp
+		WHERE user_id = $1
+	`
+
+	var totp models.UserTOTP
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&totp.UserID, &totp.Secret, &totp.Enabled, &totp.LastUsedStep, &totp.FailedAttempts,
+		&totp.EnabledAt, &totp.CreatedAt, &totp.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting TOTP authenticator: %w", err)
+	}
+	return &totp, nil
+}
+
+// IsEnabled reports whether a user has an enabled authenticator
+func (r *TOTPRepository) IsEnabled(ctx context.Context, userID int64) (bool, error) {
+	var enabled bool
+	err := r.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM user_totp WHERE user_id = $1 AND enabled)`, userID).Scan(&enabled)
+	if err != nil {
+		return false, fmt.Errorf("error checking TOTP authenticator: %w", err)
+	}
+	return enabled, nil
+}
+
+// SavePending stores the secret of an enrollment in progress, replacing an earlier one.
+// It reports false when the user already has an enabled authenticator.
+func (r *TOTPRepository) SavePending(ctx context.Context, userID int64, secret string) (bool, error) {
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, failed_attempts = 0, created_at = NOW()
+		WHERE NOT user_totp.enabled
+	`
+
+	tag, err := r.db.Exec(ctx, query, userID, secret)
+	if err != nil {
+		return false, fmt.Errorf("error saving TOTP secret: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Enable turns a pending enrollment into the user's authenticator, recording the step of the
+// code that confirmed it, and replaces the user's recovery codes with the given hashes
+func (r *TOTPRepository) Enable(ctx context.Context, userID int64, step int64, codeHashes []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE user_totp
+		SET enabled = TRUE, enabled_at = NOW(), last_used_step = $2, failed_attempts = 0
+		WHERE user_id = $1 AND NOT enabled
+	`, userID, step)
+	if err != nil {
+		return fmt.Errorf("error enabling TOTP authenticator: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return apperrors.ErrResourceNotFound
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a user's authenticator and recovery codes
+func (r *TOTPRepository) Delete(ctx context.Context, userID int64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("error deleting recovery codes: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("error deleting TOTP authenticator: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// UseStep records that the code of a time step was accepted. It reports false when a code of
+// that or a later step was already accepted, so each code works once.
+func (r *TOTPRepository) UseStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND last_used_step < $2
+	`, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("error recording TOTP step: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// AddFailedAttempt counts a wrong code and returns the number of wrong codes since the last
+// reset. The count starts over when the previous wrong code is older than window.
+func (r *TOTPRepository) AddFailedAttempt(ctx context.Context, userID int64, window time.Duration) (int, error) {
+	var attempts int
+	err := r.db.QueryRow(ctx, `
+		UPDATE user_totp
+		SET failed_attempts = CASE
+				WHEN last_failed_at IS NULL OR last_failed_at < NOW() - make_interval(secs => $2) THEN 1
+				ELSE failed_attempts + 1
+			END,
+			last_failed_at = NOW()
+		WHERE user_id = $1
+		RETURNING failed_attempts
+	`, userID, window.Seconds()).Scan(&attempts)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, apperrors.ErrResourceNotFound
+		}
+		return 0, fmt.Errorf("error counting failed TOTP attempt: %w", err)
+	}
+	return attempts, nil
+}
+
+// ResetFailedAttempts clears the count of wrong codes
+func (r *TOTPRepository) ResetFailedAttempts(ctx context.Context, userID int64) error {
+	if _, err := r.db.Exec(ctx, `UPDATE user_totp SET failed_attempts = 0 WHERE user_id = $1 AND failed_attempts > 0`, userID); err != nil {
+		return fmt.Errorf("error resetting failed TOTP attempts: %w", err)
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes replaces all recovery codes of a user with the given hashes
+func (r *TOTPRepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the unused recovery code with the given hash as used.
+// It reports false when the user has no such unused code.
+func (r *TOTPRepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE user_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("error using recovery code: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// CountUnusedRecoveryCodes returns how many recovery codes a user has left
+func (r *TOTPRepository) CountUnusedRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+// replaceRecoveryCodes deletes a user's recovery codes and inserts the given hashes within tx
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("error deleting recovery codes: %w", err)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(ctx, `INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return fmt.Errorf("error storing recovery code: %w", err)
+		}
+	}
+	return nil
+}
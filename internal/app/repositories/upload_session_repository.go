@@ -0,0 +1,159 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+)
+
+const uploadSessionColumns = `id, user_id, file_name, upload_length, upload_offset, metadata, chunk_paths, expires_at, created_at, updated_at`
+
+// UploadSessionRepository handles database operations for resumable uploads
+type UploadSessionRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewUploadSessionRepository creates a new UploadSessionRepository
+func NewUploadSessionRepository(db *pgxpool.Pool) *UploadSessionRepository {
+	return &UploadSessionRepository{db: db}
+}
+
+// Create stores a new upload session
+func (r *UploadSessionRepository) Create(ctx context.Context, session *models.UploadSession) error {
+	query := `
+		INSERT INTO upload_sessions (id, user_id, file_name, upload_length, metadata, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at
+	`
+
+	err := r.db.QueryRow(ctx, query,
+		session.ID, session.UserID, session.FileName, session.Length, session.Metadata, session.ExpiresAt,
+	).Scan(&session.CreatedAt, &session.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating upload session: %w", err)
+	}
+	return nil
+}
+
+// GetOpenByUser returns how many sessions of a user have not expired at the given time, and
+// the bytes they were created for
+func (r *UploadSessionRepository) GetOpenByUser(ctx context.Context, userID int64, now time.Time) (int, int64, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(upload_length), 0)
+		FROM upload_sessions
+		WHERE user_id = $1 AND expires_at > $2
+	`
+
+	var count int
+	var length int64
+	if err := r.db.QueryRow(ctx, query, userID, now).Scan(&count, &length); err != nil {
+		return 0, 0, fmt.Errorf("error counting open upload sessions: %w", err)
+	}
+	return count, length, nil
+}
+
+// GetByID retrieves an upload session, returning ErrResourceNotFound if it does not exist
+func (r *UploadSessionRepository) GetByID(ctx context.Context, id string) (*models.UploadSession, error) {
+	query := `SELECT ` + uploadSessionColumns + ` FROM upload_sessions WHERE id = $1`
+
+	session, err := scanUploadSession(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("error getting upload session: %w", err)
+	}
+	return session, nil
+}
+
+// AppendChunk records a stored chunk of size bytes that starts at offset and extends the
+// session's expiry. It returns ErrConflict if the session is no longer at offset, e.g.
+// because another request appended a chunk first, or if the chunk exceeds the upload length.
+func (r *UploadSessionRepository) AppendChunk(ctx context.Context, id string, offset int64, chunkPath string, size int64, expiresAt time.Time) (*models.UploadSession, error) {
+	query := `
+		UPDATE upload_sessions
+		SET upload_offset = upload_offset + $4,
+			chunk_paths = array_append(chunk_paths, $3),
+			expires_at = $5,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND upload_offset = $2 AND upload_offset + $4 <= upload_length
+		RETURNING ` + uploadSessionColumns
+
+	session, err := scanUploadSession(r.db.QueryRow(ctx, query, id, offset, chunkPath, size, expiresAt))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.ErrConflict
+		}
+		return nil, fmt.Errorf("error appending upload chunk: %w", err)
+	}
+	return session, nil
+}
+
+// Delete removes an upload session
+func (r *UploadSessionRepository) Delete(ctx context.Context, id string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM upload_sessions WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("error deleting upload session: %w", err)
+	}
+	return nil
+}
+
+// ListChunkPaths returns the stored chunk paths of all uploads
+func (r *UploadSessionRepository) ListChunkPaths(ctx context.Context) ([]string, error) {
+	query := `SELECT unnest(chunk_paths) FROM upload_sessions`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing upload chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, fmt.Errorf("error scanning upload chunk: %w", err)
+		}
+		paths = append(paths, p)
+	}
+
+	return paths, rows.Err()
+}
+
+// GetExpired returns up to limit sessions that expired before the given time
+func (r *UploadSessionRepository) GetExpired(ctx context.Context, before time.Time, limit int) ([]*models.UploadSession, error) {
+	query := `SELECT ` + uploadSessionColumns + ` FROM upload_sessions WHERE expires_at < $1 ORDER BY expires_at LIMIT $2`
+
+	rows, err := r.db.Query(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting expired upload sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*models.UploadSession
+	for rows.Next() {
+		session, err := scanUploadSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning upload session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func scanUploadSession(row pgx.Row) (*models.UploadSession, error) {
+	var session models.UploadSession
+	err := row.Scan(
+		&session.ID, &session.UserID, &session.FileName, &session.Length, &session.Offset,
+		&session.Metadata, &session.ChunkPaths, &session.ExpiresAt, &session.CreatedAt, &session.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+)
+
+// UserIdentityRepository handles database operations for users' identities at OpenID Connect
+// providers and LDAP directories
+type UserIdentityRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewUserIdentityRepository creates a new UserIdentityRepository
+func NewUserIdentityRepository(db *pgxpool.Pool) *UserIdentityRepository {
+	return &UserIdentityRepository{db: db}
+}
+
+// GetUserID returns the user an identity is linked to, or 0 if it is not linked
+func (r *UserIdentityRepository) GetUserID(ctx context.Context, issuer, subject string) (int64, error) {
+	var userID int64
+	err := r.db.QueryRow(ctx, `SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2`,
+		issuer, subject).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("error getting user identity: %w", err)
+	}
+	return userID, nil
+}
+
+// Create links an identity to a user. It returns ErrResourceAlreadyExists when the identity is
+// already linked.
+func (r *UserIdentityRepository) Create(ctx context.Context, identity *models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, issuer, subject, last_login_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING id, created_at, last_login_at
+	`
+
+	err := r.db.QueryRow(ctx, query, identity.UserID, identity.Issuer, identity.Subject).
+		Scan(&identity.ID, &identity.CreatedAt, &identity.LastLoginAt)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return apperrors.ErrResourceAlreadyExists
+		}
+		return fmt.Errorf("error creating user identity: %w", err)
+	}
+	return nil
+}
+
+// TouchLogin records a login through an identity
+func (r *UserIdentityRepository) TouchLogin(ctx context.Context, issuer, subject string) error {
+	_, err := r.db.Exec(ctx, `UPDATE user_identities SET last_login_at = NOW() WHERE issuer = $1 AND subject = $2`,
+		issuer, subject)
+	if err != nil {
+		return fmt.Errorf("error updating user identity: %w", err)
+	}
+	return nil
+}
+
+// GetSubject returns the subject a user is linked to at an issuer, or "" if there is none
+func (r *UserIdentityRepository) GetSubject(ctx context.Context, userID int64, issuer string) (string, error) {
+	var subject string
+	err := r.db.QueryRow(ctx, `SELECT subject FROM user_identities WHERE user_id = $1 AND issuer = $2 LIMIT 1`,
+		userID, issuer).Scan(&subject)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error getting user identity: %w", err)
+	}
+	return subject, nil
+}
+
+// userIdentityColumns lists the columns scanned by scanUserIdentity
+const userIdentityColumns = `id, user_id, issuer, subject, created_at, last_login_at, deactivated_at`
+
+// scanUserIdentity scans a row selected with userIdentityColumns
+func scanUserIdentity(row pgx.Row) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := row.Scan(&identity.ID, &identity.UserID, &identity.Issuer, &identity.Subject,
+		&identity.CreatedAt, &identity.LastLoginAt, &identity.DeactivatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// Get returns an identity, or nil if it is not linked
+func (r *UserIdentityRepository) Get(ctx context.Context, issuer, subject string) (*models.UserIdentity, error) {
+	query := `SELECT ` + userIdentityColumns + ` FROM user_identities WHERE issuer = $1 AND subject = $2`
+
+	identity, err := scanUserIdentity(r.db.QueryRow(ctx, query, issuer, subject))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting user identity: %w", err)
+	}
+	return identity, nil
+}
+
+// ListByIssuer returns the identities linked at an issuer
+func (r *UserIdentityRepository) ListByIssuer(ctx context.Context, issuer string) ([]*models.UserIdentity, error) {
+	query := `SELECT ` + userIdentityColumns + ` FROM user_identities WHERE issuer = $1 ORDER BY id`
+
+	rows, err := r.db.Query(ctx, query, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("error listing user identities: %w", err)
+	}
+	defer rows.Close()
+
+	var identities []*models.UserIdentity
+	for rows.Next() {
+		identity, err := scanUserIdentity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning user identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user identities: %w", err)
+	}
+	return identities, nil
+}
+
+// SetDeactivated records whether the directory sync keeps the user of an identity deactivated
+func (r *UserIdentityRepository) SetDeactivated(ctx context.Context, id int64, deactivated bool) error {
+	query := `
+		UPDATE user_identities
+		SET deactivated_at = CASE WHEN $2 THEN NOW() ELSE NULL END
+		WHERE id = $1
+	`
+
+	if _, err := r.db.Exec(ctx, query, id, deactivated); err != nil {
+		return fmt.Errorf("error updating user identity: %w", err)
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yigit/unisphere/internal/app/models"
 	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/helpers"
 )
 
 // These errors have been moved to apperrors package
@@ -47,7 +48,7 @@ type IUserRepository interface {
 	GetStudentByUserID(ctx context.Context, userID int64) (*models.Student, error)
 
 	// Advanced filtering
-	FindByFilter(ctx context.Context, departmentID *int64, roleType *models.RoleType, email *string, name *string, page, pageSize int) ([]*models.User, int64, error)
+	FindByFilter(ctx context.Context, departmentID *int64, roleType *models.RoleType, email *string, name *string, page, pageSize int, cursor *helpers.Cursor) ([]*models.User, int64, bool, error)
 }
 
 // UserRepository combines all user-related repositories
@@ -496,6 +497,50 @@ func (r *UserRepository) FindByDepartment(ctx context.Context, departmentID int6
 	return users, nil
 }
 
+// FindActiveByRole retrieves the active users with the given role
+func (r *UserRepository) FindActiveByRole(ctx context.Context, role models.RoleType) ([]*models.User, error) {
+	query := `
+		SELECT id, email, first_name, last_name, role_type, created_at, updated_at,
+		last_login_at, department_id, profile_photo_file_id, is_active
+		FROM users
+		WHERE role_type = $1 AND is_active = TRUE
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(ctx, query, role)
+	if err != nil {
+		return nil, fmt.Errorf("error querying users by role: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		var user models.User
+		var lastLoginAt sql.NullTime
+
+		err := rows.Scan(
+			&user.ID, &user.Email, &user.FirstName, &user.LastName, &user.RoleType,
+			&user.CreatedAt, &user.UpdatedAt, &lastLoginAt, &user.DepartmentID,
+			&user.ProfilePhotoFileID, &user.IsActive,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning user row: %w", err)
+		}
+
+		if lastLoginAt.Valid {
+			user.LastLoginAt = &lastLoginAt.Time
+		}
+
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user rows: %w", err)
+	}
+
+	return users, nil
+}
+
 // FindByDepartmentAndRole retrieves users by department and role
 func (r *UserRepository) FindByDepartmentAndRole(ctx context.Context, departmentID int64, role models.RoleType) ([]*models.User, error) {
 	query := `
@@ -540,8 +585,13 @@ func (r *UserRepository) FindByDepartmentAndRole(ctx context.Context, department
 	return users, nil
 }
 
-// FindByFilter retrieves users based on filter criteria with pagination
-func (r *UserRepository) FindByFilter(ctx context.Context, departmentID *int64, roleType *models.RoleType, email *string, name *string, page, pageSize int) ([]*models.User, int64, error) {
+// UserSort is the cursor sort key of user lists, which are always ordered by id
+const UserSort = "id:asc"
+
+// FindByFilter retrieves users based on filter criteria with pagination.
+// When cursor is set, the page is selected with a keyset condition instead of page/OFFSET
+// and hasMore reports whether further rows exist in the paging direction.
+func (r *UserRepository) FindByFilter(ctx context.Context, departmentID *int64, roleType *models.RoleType, email *string, name *string, page, pageSize int, cursor *helpers.Cursor) ([]*models.User, int64, bool, error) {
 	// Base SQL
 	baseSQL := `
 		FROM users
@@ -582,23 +632,37 @@ func (r *UserRepository) FindByFilter(ctx context.Context, departmentID *int64,
 	var total int64
 	err := r.db.QueryRow(ctx, countQuery, params...).Scan(&total)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error counting users: %w", err)
+		return nil, 0, false, fmt.Errorf("error counting users: %w", err)
 	}
 
 	// Now get paginated results
-	offset := (page - 1) * pageSize
 	query := `
 		SELECT id, email, first_name, last_name, role_type, created_at, updated_at, 
 		last_login_at, department_id, profile_photo_file_id, is_active
-	` + baseSQL + whereClause + `
+	` + baseSQL + whereClause
+
+	before := cursor != nil && cursor.Before
+	if cursor == nil {
+		offset := (page - 1) * pageSize
+		query += `
 		ORDER BY id
 		LIMIT $` + fmt.Sprintf("%d", paramIndex) + ` OFFSET $` + fmt.Sprintf("%d", paramIndex+1)
+		params = append(params, pageSize, offset)
+	} else {
+		if _, err := cursorArg(cursor, UserSort, sortKindInt); err != nil {
+			return nil, 0, false, err
+		}
 
-	params = append(params, pageSize, offset)
+		// Fetch one extra row to learn whether another page follows
+		query += fmt.Sprintf(" AND id %s $%d", keysetOperator(false, before), paramIndex) + `
+		ORDER BY ` + keysetOrderBy("id", "id", false, before)[0] + `
+		LIMIT $` + fmt.Sprintf("%d", paramIndex+1)
+		params = append(params, cursor.ID, pageSize+1)
+	}
 
 	rows, err := r.db.Query(ctx, query, params...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error querying users: %w", err)
+		return nil, 0, false, fmt.Errorf("error querying users: %w", err)
 	}
 	defer rows.Close()
 
@@ -614,7 +678,7 @@ func (r *UserRepository) FindByFilter(ctx context.Context, departmentID *int64,
 		)
 
 		if err != nil {
-			return nil, 0, fmt.Errorf("error scanning user row: %w", err)
+			return nil, 0, false, fmt.Errorf("error scanning user row: %w", err)
 		}
 
 		if lastLoginAt.Valid {
@@ -625,10 +689,15 @@ func (r *UserRepository) FindByFilter(ctx context.Context, departmentID *int64,
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("error iterating user rows: %w", err)
+		return nil, 0, false, fmt.Errorf("error iterating user rows: %w", err)
+	}
+
+	hasMore := false
+	if cursor != nil {
+		users, hasMore = trimKeysetPage(users, pageSize, before)
 	}
 
-	return users, total, nil
+	return users, total, hasMore, nil
 }
 
 // SetEmailVerified updates the email_verified field for a user
@@ -8,9 +8,12 @@ import (
 	"github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yigit/unisphere/internal/app/models"
 )
 
-// VerificationTokenRepository handles database operations for email verification tokens
+// VerificationTokenRepository handles database operations for email verification tokens.
+// Tokens confirming or undoing an email change share the table; the methods without "email
+// change" in their name only see the tokens that verify a registration.
 type VerificationTokenRepository struct {
 	db *pgxpool.Pool
 }
@@ -44,7 +47,7 @@ func (r *VerificationTokenRepository) CreateToken(ctx context.Context, userID in
 func (r *VerificationTokenRepository) GetTokenInfo(ctx context.Context, token string) (int64, time.Time, error) {
 	query := squirrel.Select("user_id", "expiry_date").
 		From("email_verification_tokens").
-		Where("token = ?", token).
+		Where("token = ? AND purpose = ?", token, models.VerificationTokenVerify).
 		PlaceholderFormat(squirrel.Dollar)
 
 	sql, args, err := query.ToSql()
@@ -104,10 +107,10 @@ func (r *VerificationTokenRepository) DeleteExpiredTokens(ctx context.Context) e
 	return nil
 }
 
-// DeleteTokensByUserID deletes all tokens for a specific user
+// DeleteTokensByUserID deletes all verification tokens for a specific user
 func (r *VerificationTokenRepository) DeleteTokensByUserID(ctx context.Context, userID int64) error {
 	query := squirrel.Delete("email_verification_tokens").
-		Where("user_id = ?", userID).
+		Where("user_id = ? AND purpose = ?", userID, models.VerificationTokenVerify).
 		PlaceholderFormat(squirrel.Dollar)
 
 	sql, args, err := query.ToSql()
@@ -121,4 +124,75 @@ func (r *VerificationTokenRepository) DeleteTokensByUserID(ctx context.Context,
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// CreateEmailChangeToken stores a token that confirms or undoes an email change
+func (r *VerificationTokenRepository) CreateEmailChangeToken(ctx context.Context, token *models.EmailChangeToken) error {
+	query := squirrel.Insert("email_verification_tokens").
+		Columns("user_id", "token", "purpose", "email", "expiry_date").
+		Values(token.UserID, token.Token, token.Purpose, token.Email, token.ExpiryDate).
+		PlaceholderFormat(squirrel.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building SQL: %w", err)
+	}
+
+	_, err = r.db.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("error creating email change token: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeEmailChangeToken deletes an email change token with a purpose and returns it, so
+// each token is used at most once, even by concurrent requests
+func (r *VerificationTokenRepository) ConsumeEmailChangeToken(ctx context.Context, token, purpose string) (*models.EmailChangeToken, error) {
+	query := squirrel.Delete("email_verification_tokens").
+		Where("token = ? AND purpose = ?", token, purpose).
+		Suffix("RETURNING token, user_id, purpose, email, expiry_date, created_at").
+		PlaceholderFormat(squirrel.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building SQL: %w", err)
+	}
+
+	var changeToken models.EmailChangeToken
+	err = r.db.QueryRow(ctx, sql, args...).Scan(
+		&changeToken.Token,
+		&changeToken.UserID,
+		&changeToken.Purpose,
+		&changeToken.Email,
+		&changeToken.ExpiryDate,
+		&changeToken.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("token not found")
+		}
+		return nil, fmt.Errorf("error consuming email change token: %w", err)
+	}
+
+	return &changeToken, nil
+}
+
+// DeleteEmailChangeTokens deletes a user's email change tokens with a purpose
+func (r *VerificationTokenRepository) DeleteEmailChangeTokens(ctx context.Context, userID int64, purpose string) error {
+	query := squirrel.Delete("email_verification_tokens").
+		Where("user_id = ? AND purpose = ?", userID, purpose).
+		PlaceholderFormat(squirrel.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building SQL: %w", err)
+	}
+
+	_, err = r.db.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("error deleting email change tokens: %w", err)
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	appAuth "github.com/yigit/unisphere/internal/app/auth"
 	"github.com/yigit/unisphere/internal/app/controllers"
 	"github.com/yigit/unisphere/internal/app/models"
 	"github.com/yigit/unisphere/internal/app/models/dto"
@@ -22,6 +23,19 @@ func SetupRouter(
 	communityController *controllers.CommunityController,
 	userController *controllers.UserController,
 	chatController *controllers.ChatController,
+	duplicateController *controllers.DuplicateController,
+	fileController *controllers.FileController,
+	uploadController *controllers.UploadController,
+	quarantineController *controllers.QuarantineController,
+	twoFactorController *controllers.TwoFactorController,
+	sessionController *controllers.SessionController,
+	lockoutController *controllers.LockoutController,
+	instructorVerificationController *controllers.InstructorVerificationController,
+	roleAssignmentController *controllers.RoleAssignmentController,
+	ssoController *controllers.SSOController,
+	ldapController *controllers.LDAPController,
+	personalAccessTokenController *controllers.PersonalAccessTokenController,
+	accountController *controllers.AccountController,
 	wsHandler *websocket.Handler,
 	authMiddleware *middleware.AuthMiddleware,
 ) {
@@ -30,9 +44,12 @@ func SetupRouter(
 
 	// Setup different route groups
 	setupPublicRoutes(v1, facultyController, departmentController)
-	setupAuthRoutes(v1, authController)
-	setupUserRoutes(v1, userController, authMiddleware)
+	setupAuthRoutes(v1, authController, ssoController, authMiddleware)
+	setupUserRoutes(v1, userController, twoFactorController, sessionController, personalAccessTokenController, accountController, lockoutController, instructorVerificationController, ldapController, authMiddleware)
 	setupContentRoutes(v1, pastExamController, classNoteController, communityController, chatController, wsHandler, authMiddleware, departmentController, facultyController)
+	setupAdminRoutes(v1, duplicateController, quarantineController, roleAssignmentController, authMiddleware)
+	setupFileRoutes(v1, fileController, authMiddleware)
+	setupUploadRoutes(v1, uploadController, authMiddleware)
 
 	// Health check endpoint (public)
 	v1.GET("/health", func(c *gin.Context) {
@@ -41,6 +58,9 @@ func SetupRouter(
 		})
 	})
 
+	// Public keys for verifying access tokens (outside /api/v1, where verifiers look for them)
+	router.GET("/.well-known/jwks.json", authController.GetJWKS)
+
 	// Test endpoint
 	router.GET("/ping", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "pong", "status": "success"})
@@ -72,17 +92,31 @@ func setupPublicRoutes(
 func setupAuthRoutes(
 	v1 *gin.RouterGroup,
 	authController *controllers.AuthController,
+	ssoController *controllers.SSOController,
+	authMiddleware *middleware.AuthMiddleware,
 ) {
 	// --- Public Auth routes ---
 	auth := v1.Group("/auth")
 	{
 		auth.POST("/register", authController.Register)
 		auth.POST("/login", authController.Login)
+		auth.POST("/login/mfa", authController.VerifyMFALogin)
 		auth.POST("/refresh", authController.RefreshToken)
 		auth.GET("/verify-email", authController.VerifyEmail)
 		auth.POST("/resend-verification", authController.ResendVerificationEmail)
 		auth.POST("/forgot-password", authController.ForgotPassword)
 		auth.POST("/reset-password", authController.ResetPassword)
+
+		// Links sent for an email change: to the new address, and to the old one to undo it
+		auth.GET("/confirm-email-change", authController.ConfirmEmailChange)
+		auth.GET("/undo-email-change", authController.UndoEmailChange)
+
+		// Single sign-on through the OpenID Connect provider
+		auth.GET("/sso/authorize", ssoController.Authorize)
+		auth.POST("/sso/callback", ssoController.Callback)
+
+		// Needs the access token being revoked
+		auth.POST("/logout", authMiddleware.JWTAuth(), authController.Logout)
 	}
 }
 
@@ -90,6 +124,13 @@ func setupAuthRoutes(
 func setupUserRoutes(
 	v1 *gin.RouterGroup,
 	userController *controllers.UserController,
+	twoFactorController *controllers.TwoFactorController,
+	sessionController *controllers.SessionController,
+	personalAccessTokenController *controllers.PersonalAccessTokenController,
+	accountController *controllers.AccountController,
+	lockoutController *controllers.LockoutController,
+	instructorVerificationController *controllers.InstructorVerificationController,
+	ldapController *controllers.LDAPController,
 	authMiddleware *middleware.AuthMiddleware,
 ) {
 	// Create authenticated group
@@ -104,6 +145,28 @@ func setupUserRoutes(
 		users.PUT("/profile", userController.UpdateUserProfile)
 		users.POST("/profile/photo", userController.UpdateProfilePhoto)
 		users.DELETE("/profile/photo", userController.DeleteProfilePhoto)
+		users.GET("/profile/storage", userController.GetStorageUsage)
+
+		// Password and email changes, confirmed with the current password
+		users.PUT("/profile/password", accountController.ChangePassword)
+		users.POST("/profile/email", accountController.RequestEmailChange)
+
+		// Two-factor authentication
+		users.GET("/profile/2fa", twoFactorController.GetStatus)
+		users.POST("/profile/2fa/setup", twoFactorController.Setup)
+		users.POST("/profile/2fa/enable", twoFactorController.Enable)
+		users.POST("/profile/2fa/disable", twoFactorController.Disable)
+		users.POST("/profile/2fa/recovery-codes", twoFactorController.RegenerateRecoveryCodes)
+
+		// Device sessions
+		users.GET("/profile/sessions", sessionController.GetSessions)
+		users.DELETE("/profile/sessions", sessionController.RevokeAllSessions)
+		users.DELETE("/profile/sessions/:sessionId", sessionController.RevokeSession)
+
+		// Personal access tokens for scripts and bots
+		users.GET("/profile/tokens", personalAccessTokenController.GetTokens)
+		users.POST("/profile/tokens", personalAccessTokenController.CreateToken)
+		users.DELETE("/profile/tokens/:tokenId", personalAccessTokenController.RevokeToken)
 	}
 
 	// Routes that require email verification
@@ -119,11 +182,23 @@ func setupUserRoutes(
 
 	// Admin protected routes
 	adminProtected := authenticatedWithEmailVerified.Group("/admin")
-	adminProtected.Use(authMiddleware.RoleRequired(string(models.RoleAdmin)))
+	adminProtected.Use(authMiddleware.PermissionRequired(appAuth.PermissionUserManage, middleware.GlobalScope()), authMiddleware.MFARequired())
 	{
-		// User management (Admin only)
+		// User management
 		adminProtected.GET("/users", userController.GetAllUsers)
 		adminProtected.DELETE("/users/:id", userController.DeleteUser)
+
+		// Accounts locked after too many failed logins
+		adminProtected.GET("/users/locked", lockoutController.GetLockedAccounts)
+		adminProtected.POST("/users/:id/unlock", lockoutController.UnlockAccount)
+
+		// Review of accounts that registered as instructors
+		adminProtected.GET("/instructor-verifications", instructorVerificationController.GetVerifications)
+		adminProtected.POST("/instructor-verifications/:userId/approve", instructorVerificationController.Approve)
+		adminProtected.POST("/instructor-verifications/:userId/reject", instructorVerificationController.Reject)
+
+		// Sync with the LDAP directory
+		adminProtected.POST("/ldap/sync", ldapController.Sync)
 	}
 
 	// Use a different URL pattern to avoid conflicts with /departments/:id endpoint
@@ -159,26 +234,28 @@ func setupContentRoutes(
 	// Faculty protected routes
 	facultiesProtected := authenticatedWithEmailVerified.Group("/faculties")
 	{
-		// Role-protected routes within faculties
-		facultiesInstructorProtected := facultiesProtected.Group("")
-		facultiesInstructorProtected.Use(authMiddleware.RoleRequired(string(models.RoleInstructor)))
+		// Routes that need faculty:write, globally to create and on the faculty to change it
+		facultyWrite := appAuth.PermissionFacultyWrite
+		facultiesWriteProtected := facultiesProtected.Group("")
+		facultiesWriteProtected.Use(authMiddleware.MFARequired())
 		{
-			facultiesInstructorProtected.POST("", facultyController.CreateFaculty)
-			facultiesInstructorProtected.PUT("/:id", facultyController.UpdateFaculty)
-			facultiesInstructorProtected.DELETE("/:id", facultyController.DeleteFaculty)
+			facultiesWriteProtected.POST("", authMiddleware.PermissionRequired(facultyWrite, middleware.GlobalScope()), facultyController.CreateFaculty)
+			facultiesWriteProtected.PUT("/:id", authMiddleware.PermissionRequired(facultyWrite, middleware.ParamScope(models.ScopeFaculty, "id")), facultyController.UpdateFaculty)
+			facultiesWriteProtected.DELETE("/:id", authMiddleware.PermissionRequired(facultyWrite, middleware.ParamScope(models.ScopeFaculty, "id")), facultyController.DeleteFaculty)
 		}
 	}
 
 	// Department protected routes
 	departmentsProtected := authenticatedWithEmailVerified.Group("/departments")
 	{
-		// Role-protected routes within departments
-		departmentsInstructorProtected := departmentsProtected.Group("")
-		departmentsInstructorProtected.Use(authMiddleware.RoleRequired(string(models.RoleInstructor)))
+		// Routes that need department:write, globally to create and on the department to change it
+		departmentWrite := appAuth.PermissionDepartmentWrite
+		departmentsWriteProtected := departmentsProtected.Group("")
+		departmentsWriteProtected.Use(authMiddleware.MFARequired())
 		{
-			departmentsInstructorProtected.POST("", departmentController.CreateDepartment)
-			departmentsInstructorProtected.PUT("/:id", departmentController.UpdateDepartment)
-			departmentsInstructorProtected.DELETE("/:id", departmentController.DeleteDepartment)
+			departmentsWriteProtected.POST("", authMiddleware.PermissionRequired(departmentWrite, middleware.GlobalScope()), departmentController.CreateDepartment)
+			departmentsWriteProtected.PUT("/:id", authMiddleware.PermissionRequired(departmentWrite, middleware.ParamScope(models.ScopeDepartment, "id")), departmentController.UpdateDepartment)
+			departmentsWriteProtected.DELETE("/:id", authMiddleware.PermissionRequired(departmentWrite, middleware.ParamScope(models.ScopeDepartment, "id")), departmentController.DeleteDepartment)
 		}
 	}
 
@@ -186,22 +263,27 @@ func setupContentRoutes(
 	pastExams := authenticatedWithEmailVerified.Group("/past-exams")
 	{
 		// Public routes accessible to all authenticated users (students and instructors)
-		pastExams.GET("", pastExamController.GetAllPastExams)     // List all past exams with optional filtering
-		pastExams.GET("/:id", pastExamController.GetPastExamByID) // Retrieve a specific past exam by ID
-
-		// Instructor-only routes - Protected by role-based middleware
-		// These routes are restricted to users with the Instructor role
-		pastExamsInstructorProtected := pastExams.Group("")
-		pastExamsInstructorProtected.Use(authMiddleware.RoleRequired(string(models.RoleInstructor)))
+		pastExams.GET("", pastExamController.GetAllPastExams)                   // List all past exams with optional filtering
+		pastExams.GET("/bundle", pastExamController.DownloadCourseBundle)       // ZIP of all exams of a course, optionally by year range
+		pastExams.GET("/:id", pastExamController.GetPastExamByID)               // Retrieve a specific past exam by ID
+		pastExams.GET("/:id/bundle", pastExamController.DownloadPastExamBundle) // ZIP of all files of a past exam
+
+		// Routes that need pastexam:write on the department of the exam
+		// Creating checks the department of the request body in the service; changing an exam
+		// also needs its ownership or pastexam:manage, checked in the service too
+		pastExamCreate := authMiddleware.PermissionRequired(appAuth.PermissionPastExamWrite, middleware.AnyScope())
+		pastExamWrite := authMiddleware.PermissionRequired(appAuth.PermissionPastExamWrite, authMiddleware.PastExamScope("id"))
+		pastExamsWriteProtected := pastExams.Group("")
+		pastExamsWriteProtected.Use(authMiddleware.MFARequired())
 		{
 			// CRUD operations for past exam resources
-			pastExamsInstructorProtected.POST("", pastExamController.CreatePastExam)       // Create a new past exam
-			pastExamsInstructorProtected.PUT("/:id", pastExamController.UpdatePastExam)    // Update an existing past exam
-			pastExamsInstructorProtected.DELETE("/:id", pastExamController.DeletePastExam) // Delete a past exam
+			pastExamsWriteProtected.POST("", pastExamCreate, pastExamController.CreatePastExam)      // Create a new past exam
+			pastExamsWriteProtected.PUT("/:id", pastExamWrite, pastExamController.UpdatePastExam)    // Update an existing past exam
+			pastExamsWriteProtected.DELETE("/:id", pastExamWrite, pastExamController.DeletePastExam) // Delete a past exam
 
 			// File management for past exams
-			pastExamsInstructorProtected.POST("/:id/files", pastExamController.AddFileToPastExam)                // Upload and attach files to a past exam
-			pastExamsInstructorProtected.DELETE("/:id/files/:fileId", pastExamController.DeleteFileFromPastExam) // Remove a file from a past exam
+			pastExamsWriteProtected.POST("/:id/files", pastExamWrite, pastExamController.AddFileToPastExam)                // Upload and attach files to a past exam
+			pastExamsWriteProtected.DELETE("/:id/files/:fileId", pastExamWrite, pastExamController.DeleteFileFromPastExam) // Remove a file from a past exam
 		}
 	}
 
@@ -210,6 +292,7 @@ func setupContentRoutes(
 	{
 		classNotes.GET("", classNoteController.GetAllNotes)
 		classNotes.GET("/:noteId", classNoteController.GetNoteByID)
+		classNotes.GET("/:noteId/bundle", classNoteController.DownloadNoteBundle)
 
 		// Both students and instructors can create class notes
 		classNotesAuthProtected := classNotes.Group("")
@@ -259,3 +342,81 @@ func setupContentRoutes(
 		}
 	}
 }
+
+// setupFileRoutes configures authorized and signed file downloads
+func setupFileRoutes(
+	v1 *gin.RouterGroup,
+	fileController *controllers.FileController,
+	authMiddleware *middleware.AuthMiddleware,
+) {
+	files := v1.Group("/files")
+	{
+		// Signed URLs carry their own authorization, so browsers and <img> tags can use them directly
+		files.GET("/:fileId/content", fileController.DownloadSignedFile)
+
+		filesAuthenticated := files.Group("")
+		filesAuthenticated.Use(authMiddleware.JWTAuth())
+		{
+			filesAuthenticated.GET("/:fileId/download", fileController.DownloadFile)       // Permission-checked download with Range support
+			filesAuthenticated.POST("/:fileId/signed-url", fileController.CreateSignedURL) // Issue a short-lived signed URL
+		}
+	}
+}
+
+// setupUploadRoutes configures the tus resumable upload endpoints
+func setupUploadRoutes(
+	v1 *gin.RouterGroup,
+	uploadController *controllers.UploadController,
+	authMiddleware *middleware.AuthMiddleware,
+) {
+	uploads := v1.Group("/uploads")
+	{
+		// Protocol discovery and CORS preflight carry no token
+		uploads.OPTIONS("", uploadController.Options)
+
+		uploadsAuthenticated := uploads.Group("")
+		uploadsAuthenticated.Use(authMiddleware.JWTAuth())
+		{
+			uploadsAuthenticated.POST("", uploadController.CreateUpload)              // Start an upload
+			uploadsAuthenticated.HEAD("/:uploadId", uploadController.GetUploadOffset) // Get the offset to resume from
+			uploadsAuthenticated.PATCH("/:uploadId", uploadController.PatchUpload)    // Append content
+			uploadsAuthenticated.DELETE("/:uploadId", uploadController.DeleteUpload)  // Cancel an upload
+		}
+	}
+}
+
+// setupAdminRoutes configures admin maintenance, reporting and role management routes
+func setupAdminRoutes(
+	v1 *gin.RouterGroup,
+	duplicateController *controllers.DuplicateController,
+	quarantineController *controllers.QuarantineController,
+	roleAssignmentController *controllers.RoleAssignmentController,
+	authMiddleware *middleware.AuthMiddleware,
+) {
+	admin := v1.Group("/admin")
+	admin.Use(authMiddleware.JWTAuth())
+	admin.Use(authMiddleware.EmailVerificationRequired())
+
+	fileModeration := admin.Group("")
+	fileModeration.Use(authMiddleware.PermissionRequired(appAuth.PermissionFileModerate, middleware.GlobalScope()), authMiddleware.MFARequired())
+	{
+		// Duplicate note and exam file clusters
+		fileModeration.GET("/duplicates", duplicateController.GetDuplicateReport)
+
+		// Files quarantined by the malware scanner
+		fileModeration.GET("/files/quarantine", quarantineController.GetQuarantinedFiles)
+		fileModeration.DELETE("/files/quarantine/:fileId", quarantineController.DeleteQuarantinedFile)
+	}
+
+	roleManagement := admin.Group("")
+	roleManagement.Use(authMiddleware.PermissionRequired(appAuth.PermissionRoleManage, middleware.GlobalScope()), authMiddleware.MFARequired())
+	{
+		// Roles and the permissions they grant
+		roleManagement.GET("/roles", roleAssignmentController.GetRoles)
+
+		// Roles granted to users within a scope
+		roleManagement.GET("/role-assignments", roleAssignmentController.GetAssignments)
+		roleManagement.POST("/role-assignments", roleAssignmentController.CreateAssignment)
+		roleManagement.DELETE("/role-assignments/:id", roleAssignmentController.DeleteAssignment)
+	}
+}
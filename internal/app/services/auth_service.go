@@ -17,6 +17,7 @@ import (
 	"github.com/yigit/unisphere/internal/pkg/auth"
 	"github.com/yigit/unisphere/internal/pkg/email"
 	"github.com/yigit/unisphere/internal/pkg/filestorage"
+	"github.com/yigit/unisphere/internal/pkg/helpers"
 	"github.com/yigit/unisphere/internal/pkg/validation"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -28,16 +29,30 @@ type AuthService interface {
 
 	// Email verification
 	VerifyEmail(ctx context.Context, token string) error
-	ResendVerificationEmail(ctx context.Context, email string) error
+	ResendVerificationEmail(ctx context.Context, email string, client *dto.ClientInfo) error
 
 	// Authentication
-	Login(ctx context.Context, req *dto.LoginRequest) (*dto.TokenResponse, error)
-	RefreshToken(ctx context.Context, token string) (*dto.TokenResponse, error)
+	Login(ctx context.Context, req *dto.LoginRequest, client *dto.ClientInfo) (*dto.LoginResponse, error)
+	VerifyMFALogin(ctx context.Context, req *dto.MFALoginRequest, client *dto.ClientInfo) (*dto.TokenResponse, error)
+	StartSession(ctx context.Context, user *models.User, client *dto.ClientInfo) (*dto.LoginResponse, error)
+	RefreshToken(ctx context.Context, token string, client *dto.ClientInfo) (*dto.TokenResponse, error)
+
+	// Sessions (refresh token families)
+	GetSessions(ctx context.Context, userID, currentSessionID int64) ([]dto.SessionResponse, error)
+	RevokeSession(ctx context.Context, userID, sessionID int64) error
+	RevokeAllSessions(ctx context.Context, userID int64) error
+	Logout(ctx context.Context, userID, sessionID int64, accessTokenID string, accessTokenExpiry time.Time) error
 
 	// Password reset
-	ForgotPassword(ctx context.Context, email string) error
+	ForgotPassword(ctx context.Context, email string, client *dto.ClientInfo) error
 	ResetPassword(ctx context.Context, token string, newPassword string) error
 
+	// Password and email changes by a logged-in user
+	ChangePassword(ctx context.Context, userID, sessionID int64, req *dto.ChangePasswordRequest, client *dto.ClientInfo) (*dto.ChangePasswordResponse, error)
+	RequestEmailChange(ctx context.Context, userID int64, req *dto.ChangeEmailRequest, client *dto.ClientInfo) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	UndoEmailChange(ctx context.Context, token string) error
+
 	// User profile
 	GetProfile(ctx context.Context, userID int64) (*dto.UserResponse, error)
 	GetUserByID(ctx context.Context, userID int64) (*models.User, error)
@@ -51,17 +66,25 @@ type AuthService interface {
 
 // authServiceImpl implements the AuthService interface
 type authServiceImpl struct {
-	userRepo               *repositories.UserRepository
-	tokenRepo              *repositories.TokenRepository
-	departmentRepo         *repositories.DepartmentRepository
-	facultyRepo            *repositories.FacultyRepository
-	fileRepo               *repositories.FileRepository
-	fileStorage            *filestorage.LocalStorage
-	verificationTokenRepo  *repositories.VerificationTokenRepository
-	passwordResetTokenRepo *repositories.PasswordResetTokenRepository
-	emailService           email.EmailService
-	jwtService             *auth.JWTService
-	logger                 zerolog.Logger
+	userRepo                      *repositories.UserRepository
+	tokenRepo                     *repositories.TokenRepository
+	departmentRepo                *repositories.DepartmentRepository
+	facultyRepo                   *repositories.FacultyRepository
+	fileRepo                      *repositories.FileRepository
+	fileStorage                   filestorage.FileStorage
+	quotaService                  QuotaService
+	scanService                   ScanService
+	twoFactorService              TwoFactorService
+	revocationService             TokenRevocationService
+	throttleService               ThrottleService
+	ldapService                   LDAPService
+	roleResolver                  RoleResolver
+	instructorVerificationService InstructorVerificationService
+	verificationTokenRepo         *repositories.VerificationTokenRepository
+	passwordResetTokenRepo        *repositories.PasswordResetTokenRepository
+	emailService                  email.EmailService
+	jwtService                    *auth.JWTService
+	logger                        zerolog.Logger
 }
 
 // NewAuthService creates a new AuthService
@@ -71,7 +94,15 @@ func NewAuthService(
 	departmentRepo *repositories.DepartmentRepository,
 	facultyRepo *repositories.FacultyRepository,
 	fileRepo *repositories.FileRepository,
-	fileStorage *filestorage.LocalStorage,
+	fileStorage filestorage.FileStorage,
+	quotaService QuotaService,
+	scanService ScanService,
+	twoFactorService TwoFactorService,
+	revocationService TokenRevocationService,
+	throttleService ThrottleService,
+	ldapService LDAPService,
+	roleResolver RoleResolver,
+	instructorVerificationService InstructorVerificationService,
 	verificationTokenRepo *repositories.VerificationTokenRepository,
 	passwordResetTokenRepo *repositories.PasswordResetTokenRepository,
 	emailService email.EmailService,
@@ -79,17 +110,25 @@ func NewAuthService(
 	logger zerolog.Logger,
 ) AuthService {
 	return &authServiceImpl{
-		userRepo:               userRepo,
-		tokenRepo:              tokenRepo,
-		departmentRepo:         departmentRepo,
-		facultyRepo:            facultyRepo,
-		fileRepo:               fileRepo,
-		fileStorage:            fileStorage,
-		verificationTokenRepo:  verificationTokenRepo,
-		passwordResetTokenRepo: passwordResetTokenRepo,
-		emailService:           emailService,
-		jwtService:             jwtService,
-		logger:                 logger,
+		userRepo:                      userRepo,
+		tokenRepo:                     tokenRepo,
+		departmentRepo:                departmentRepo,
+		facultyRepo:                   facultyRepo,
+		fileRepo:                      fileRepo,
+		fileStorage:                   fileStorage,
+		quotaService:                  quotaService,
+		scanService:                   scanService,
+		twoFactorService:              twoFactorService,
+		revocationService:             revocationService,
+		throttleService:               throttleService,
+		ldapService:                   ldapService,
+		roleResolver:                  roleResolver,
+		instructorVerificationService: instructorVerificationService,
+		verificationTokenRepo:         verificationTokenRepo,
+		passwordResetTokenRepo:        passwordResetTokenRepo,
+		emailService:                  emailService,
+		jwtService:                    jwtService,
+		logger:                        logger,
 	}
 }
 
@@ -207,32 +246,8 @@ func (s *authServiceImpl) Register(ctx context.Context, req *dto.RegisterRequest
 		return nil, fmt.Errorf("error hashing password: %w", err)
 	}
 
-	// Determine user role based on email pattern
-	roleType := models.RoleInstructor // Default role is instructor
-
-	// Extract username from email (part before @)
-	emailParts := strings.Split(req.Email, "@")
-	if len(emailParts) > 0 {
-		username := emailParts[0]
-
-		// Check if username starts with 's' followed by digits (e.g., s200201027)
-		if len(username) > 1 && username[0] == 's' {
-			// Check if remaining characters are digits
-			remainingChars := username[1:]
-			isStudent := true
-
-			for _, char := range remainingChars {
-				if !unicode.IsDigit(char) {
-					isStudent = false
-					break
-				}
-			}
-
-			if isStudent && len(remainingChars) > 0 {
-				roleType = models.RoleStudent
-			}
-		}
-	}
+	// Determine user role; accounts that are not students wait for an admin to confirm them
+	roleType := s.roleResolver.ResolveRole(req.Email)
 
 	// Create user with department_id and email_verified=false
 	user := &models.User{
@@ -253,6 +268,10 @@ func (s *authServiceImpl) Register(ctx context.Context, req *dto.RegisterRequest
 	}
 	user.ID = userID
 
+	if err := s.instructorVerificationService.QueueNewAccount(ctx, user); err != nil {
+		return nil, err
+	}
+
 	// Generate verification token
 	verificationToken, err := GenerateTokenForVerification()
 	if err != nil {
@@ -276,23 +295,52 @@ func (s *authServiceImpl) Register(ctx context.Context, req *dto.RegisterRequest
 	}
 
 	// Return successful response
-	return &dto.RegisterResponse{
+	response := &dto.RegisterResponse{
 		Message: "Registration successful. Please check your email to verify your account.",
 		UserID:  userID,
-	}, nil
+	}
+	if roleType == models.RolePendingInstructor {
+		response.Message = "Registration successful. Please check your email to verify your account. Your instructor account will be activated once an administrator approves it."
+		response.PendingApproval = true
+	}
+	return response, nil
 }
 
-// Login handles user login
-func (s *authServiceImpl) Login(ctx context.Context, req *dto.LoginRequest) (*dto.TokenResponse, error) {
+// Login handles user login. Users with two-factor authentication get an MFA challenge
+// token instead of a token pair, to exchange for one with VerifyMFALogin.
+func (s *authServiceImpl) Login(ctx context.Context, req *dto.LoginRequest, client *dto.ClientInfo) (*dto.LoginResponse, error) {
+	// Refuse attempts while the account or IP address is backing off or locked
+	throttleEmail := strings.ToLower(strings.TrimSpace(req.Email))
+	if err := s.throttleService.CheckLogin(ctx, throttleEmail, client.IPAddress); err != nil {
+		return nil, err
+	}
+
+	// Directory accounts log in with their directory password
+	directoryUser, err := s.ldapService.Authenticate(ctx, req.Email, req.Password)
+	if errors.Is(err, apperrors.ErrInvalidCredentials) {
+		user, _ := s.userRepo.GetByEmail(ctx, req.Email)
+		s.throttleService.RecordLoginFailure(ctx, throttleEmail, client.IPAddress, user)
+		return nil, apperrors.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if directoryUser != nil {
+		client.DeviceLabel = req.DeviceLabel
+		return s.startPasswordSession(ctx, directoryUser, throttleEmail, client)
+	}
+
 	// Get user by email
 	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
+		s.throttleService.RecordLoginFailure(ctx, throttleEmail, client.IPAddress, nil)
 		return nil, apperrors.ErrInvalidCredentials
 	}
 
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 	if err != nil {
+		s.throttleService.RecordLoginFailure(ctx, throttleEmail, client.IPAddress, user)
 		return nil, apperrors.ErrInvalidCredentials
 	}
 
@@ -311,70 +359,297 @@ func (s *authServiceImpl) Login(ctx context.Context, req *dto.LoginRequest) (*dt
 		}
 	}
 
+	client.DeviceLabel = req.DeviceLabel
+	return s.startPasswordSession(ctx, user, throttleEmail, client)
+}
+
+// startPasswordSession starts the session of a user who gave the right password. Their failed
+// logins are only cleared once the login is complete: with two-factor authentication, after
+// the code, so logging in again with the password does not earn more guesses at it.
+func (s *authServiceImpl) startPasswordSession(ctx context.Context, user *models.User, throttleEmail string, client *dto.ClientInfo) (*dto.LoginResponse, error) {
+	response, err := s.StartSession(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+	if !response.MFARequired {
+		s.throttleService.RecordLoginSuccess(ctx, throttleEmail)
+	}
+	return response, nil
+}
+
+// StartSession logs in a user whose identity has been established, by a password or by a
+// single sign-on provider. Users with two-factor authentication get an MFA challenge token.
+func (s *authServiceImpl) StartSession(ctx context.Context, user *models.User, client *dto.ClientInfo) (*dto.LoginResponse, error) {
 	// Check if user is active
 	if !user.IsActive {
 		return nil, apperrors.ErrAccountDisabled
 	}
 
+	// Ask for the second factor before issuing tokens
+	mfaRequired, err := s.twoFactorService.ChallengeRequired(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking two-factor authentication: %w", err)
+	}
+	if mfaRequired {
+		mfaToken, expiresIn, err := s.jwtService.GenerateMFAChallenge(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &dto.LoginResponse{
+			MFARequired:       true,
+			MFAToken:          mfaToken,
+			MFATokenExpiresIn: int64(expiresIn),
+		}, nil
+	}
+
+	tokenResponse, err := s.completeLogin(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.LoginResponse{
+		TokenResponse:         tokenResponse,
+		MFAEnrollmentRequired: models.RoleRequiresMFA(user.RoleType),
+	}, nil
+}
+
+// VerifyMFALogin completes a login that requires a second factor, exchanging the MFA
+// challenge token and a TOTP or recovery code for a token pair
+func (s *authServiceImpl) VerifyMFALogin(ctx context.Context, req *dto.MFALoginRequest, client *dto.ClientInfo) (*dto.TokenResponse, error) {
+	userID, err := s.jwtService.ValidateMFAChallenge(req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, apperrors.ErrInvalidCredentials
+	}
+	if !user.IsActive {
+		return nil, apperrors.ErrAccountDisabled
+	}
+
+	// Wrong codes count as failed logins, so they back off and lock the account like
+	// wrong passwords do
+	throttleEmail := strings.ToLower(user.Email)
+	if err := s.throttleService.CheckLogin(ctx, throttleEmail, client.IPAddress); err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorService.VerifyLoginCode(ctx, userID, req.Code); err != nil {
+		if errors.Is(err, apperrors.ErrInvalidMFACode) {
+			s.throttleService.RecordLoginFailure(ctx, throttleEmail, client.IPAddress, user)
+		}
+		return nil, err
+	}
+	s.throttleService.RecordLoginSuccess(ctx, throttleEmail)
+
+	client.DeviceLabel = req.DeviceLabel
+	return s.completeLogin(ctx, user, client)
+}
+
+// completeLogin records the login and starts a session with a new token pair
+func (s *authServiceImpl) completeLogin(ctx context.Context, user *models.User, client *dto.ClientInfo) (*dto.TokenResponse, error) {
 	// Update last login time
-	err = s.userRepo.UpdateLastLogin(ctx, user.ID)
+	err := s.userRepo.UpdateLastLogin(ctx, user.ID)
 	if err != nil {
 		s.logger.Warn().Err(err).Msg("Failed to update last login time")
 		// Don't return error, as login was successful
 	}
 
+	// Start a token family for the session
+	deviceLabel := strings.TrimSpace(client.DeviceLabel)
+	if deviceLabel == "" {
+		deviceLabel = helpers.DeviceLabelFromUserAgent(client.UserAgent)
+	}
+	familyID, err := s.tokenRepo.CreateFamily(ctx, &models.TokenFamily{
+		UserID:      user.ID,
+		DeviceLabel: deviceLabel,
+		UserAgent:   client.UserAgent,
+		IPAddress:   client.IPAddress,
+		ExpiresAt:   s.jwtService.GetRefreshTokenExpiry(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("session creation error: %w", err)
+	}
+
 	// Generate and return token
-	return s.generateTokenResponse(ctx, user)
+	return s.generateTokenResponse(ctx, user, familyID, client)
 }
 
-// RefreshToken creates a new access token using a refresh token
-func (s *authServiceImpl) RefreshToken(ctx context.Context, refreshToken string) (*dto.TokenResponse, error) {
+// RefreshToken creates a new access token using a refresh token. The refresh token is
+// rotated: presenting it again, as a thief replaying a stolen token would, revokes its whole family.
+func (s *authServiceImpl) RefreshToken(ctx context.Context, refreshToken string, client *dto.ClientInfo) (*dto.TokenResponse, error) {
 	// Validate refresh token
 	if err := s.validateToken(refreshToken); err != nil {
 		return nil, err
 	}
 
-	// Get token information with additional validation
-	userID, expiryDate, isRevoked, err := s.tokenRepo.GetTokenByValue(ctx, refreshToken)
+	// Get token information
+	token, err := s.tokenRepo.GetToken(ctx, refreshToken)
 	if err != nil {
-		if errors.Is(err, apperrors.ErrTokenNotFound) {
-			return nil, apperrors.ErrTokenNotFound
-		}
-		if errors.Is(err, apperrors.ErrTokenExpired) {
-			return nil, apperrors.ErrTokenExpired
-		}
-		if errors.Is(err, apperrors.ErrTokenRevoked) {
-			return nil, apperrors.ErrTokenRevoked
-		}
-		return nil, fmt.Errorf("token validation error: %w", err)
+		return nil, err
 	}
 
-	// Additional security checks
-	// 1. Check expiry date explicitly
-	if expiryDate.Before(time.Now()) {
+	// A revoked token was either rotated already or signed out; reuse of a rotated token means
+	// it leaked, so the session is ended for whoever holds its current token as well
+	if token.IsRevoked {
+		s.revokeReusedFamily(ctx, token)
+		return nil, apperrors.ErrTokenRevoked
+	}
+
+	// Check expiry date explicitly
+	if token.ExpiryDate.Before(time.Now()) {
 		// Also revoke expired token
 		_ = s.tokenRepo.RevokeToken(ctx, refreshToken)
 		return nil, apperrors.ErrTokenExpired
 	}
 
-	// 2. Check revocation status explicitly
-	if isRevoked {
-		return nil, apperrors.ErrTokenRevoked
+	if token.FamilyID != nil {
+		family, err := s.tokenRepo.GetFamily(ctx, *token.FamilyID)
+		if err != nil {
+			return nil, fmt.Errorf("session lookup error: %w", err)
+		}
+		if family.RevokedAt != nil {
+			return nil, apperrors.ErrTokenRevoked
+		}
 	}
 
 	// Get user information
-	user, err := s.userRepo.GetUserByID(ctx, userID)
+	user, err := s.userRepo.GetUserByID(ctx, token.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("user not found: %w", err)
 	}
+	// Deactivated users, such as those gone from the LDAP directory, get no new tokens
+	if !user.IsActive {
+		return nil, apperrors.ErrAccountDisabled
+	}
 
-	// Revoke old token (important for security - prevents token reuse)
-	if err := s.tokenRepo.RevokeToken(ctx, refreshToken); err != nil {
+	// Revoke old token (important for security - prevents token reuse). Losing the race to
+	// a concurrent refresh with the same token is reuse as well.
+	rotated, err := s.tokenRepo.RotateToken(ctx, refreshToken)
+	if err != nil {
 		return nil, fmt.Errorf("failed to revoke old token: %w", err)
 	}
+	if !rotated {
+		s.revokeReusedFamily(ctx, token)
+		return nil, apperrors.ErrTokenRevoked
+	}
+
+	// Tokens issued before families existed start one now. That happens only once the token
+	// is rotated, so a replay losing the race above leaves no orphaned session behind.
+	if token.FamilyID == nil {
+		familyID, err := s.tokenRepo.CreateFamily(ctx, &models.TokenFamily{
+			UserID:      token.UserID,
+			DeviceLabel: helpers.DeviceLabelFromUserAgent(client.UserAgent),
+			UserAgent:   client.UserAgent,
+			IPAddress:   client.IPAddress,
+			ExpiresAt:   token.ExpiryDate,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("session creation error: %w", err)
+		}
+		token.FamilyID = &familyID
+	}
 
 	// Generate new token
-	return s.generateTokenResponse(ctx, user)
+	return s.generateTokenResponse(ctx, user, *token.FamilyID, client)
+}
+
+// revokeReusedFamily revokes the family of a refresh token that was presented after it was revoked
+func (s *authServiceImpl) revokeReusedFamily(ctx context.Context, token *models.RefreshToken) {
+	if token.FamilyID == nil {
+		return
+	}
+
+	revoked, err := s.tokenRepo.RevokeFamily(ctx, token.UserID, *token.FamilyID, models.TokenFamilyReuseDetected)
+	if err != nil {
+		s.logger.Error().Err(err).Int64("userID", token.UserID).Int64("familyID", *token.FamilyID).Msg("Failed to revoke token family after refresh token reuse")
+		return
+	}
+	// Whoever holds the family's tokens may be the thief; their access tokens stop working too
+	s.revocationService.RevokeSession(*token.FamilyID)
+	if revoked {
+		s.logger.Warn().
+			Int64("userID", token.UserID).
+			Int64("familyID", *token.FamilyID).
+			Msg("Revoked refresh token reused; session revoked")
+	}
+}
+
+// GetSessions lists the active sessions of a user, marking the one currentSessionID belongs to
+func (s *authServiceImpl) GetSessions(ctx context.Context, userID, currentSessionID int64) ([]dto.SessionResponse, error) {
+	families, err := s.tokenRepo.ListActiveFamilies(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]dto.SessionResponse, len(families))
+	for i, family := range families {
+		sessions[i] = dto.SessionResponse{
+			ID:          family.ID,
+			DeviceLabel: family.DeviceLabel,
+			UserAgent:   family.UserAgent,
+			IPAddress:   family.IPAddress,
+			CreatedAt:   family.CreatedAt,
+			LastUsedAt:  family.LastUsedAt,
+			ExpiresAt:   family.ExpiresAt,
+			Current:     family.ID == currentSessionID,
+		}
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs a user out of one session; its refresh token and the access tokens
+// issued for it stop working
+func (s *authServiceImpl) RevokeSession(ctx context.Context, userID, sessionID int64) error {
+	revoked, err := s.tokenRepo.RevokeFamily(ctx, userID, sessionID, models.TokenFamilySignedOut)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return apperrors.NewResourceNotFoundError("Session not found")
+	}
+	s.revocationService.RevokeSession(sessionID)
+
+	s.logger.Info().Int64("userID", userID).Int64("sessionID", sessionID).Msg("Session signed out")
+	return nil
+}
+
+// RevokeAllSessions signs a user out of every session, including the current one. Access
+// tokens already issued stop working as well.
+func (s *authServiceImpl) RevokeAllSessions(ctx context.Context, userID int64) error {
+	revoked, err := s.tokenRepo.RevokeAllFamilies(ctx, userID, models.TokenFamilySignedOut)
+	if err != nil {
+		return err
+	}
+	// Tokens issued before families existed are not in any
+	if err := s.tokenRepo.RevokeAllUserTokens(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.revocationService.RevokeUserTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	s.logger.Info().Int64("userID", userID).Int64("sessions", revoked).Msg("All sessions signed out")
+	return nil
+}
+
+// Logout signs a user out of the session an access token belongs to and revokes the access token
+func (s *authServiceImpl) Logout(ctx context.Context, userID, sessionID int64, accessTokenID string, accessTokenExpiry time.Time) error {
+	if err := s.revocationService.RevokeAccessToken(ctx, accessTokenID, userID, accessTokenExpiry); err != nil {
+		return err
+	}
+
+	// Access tokens issued before sessions existed carry no session
+	if sessionID != 0 {
+		if _, err := s.tokenRepo.RevokeFamily(ctx, userID, sessionID, models.TokenFamilySignedOut); err != nil {
+			return err
+		}
+		// Access tokens issued for the session by earlier refreshes are not in the denylist
+		s.revocationService.RevokeSession(sessionID)
+	}
+
+	s.logger.Info().Int64("userID", userID).Int64("sessionID", sessionID).Msg("User logged out")
+	return nil
 }
 
 // GetProfile retrieves a user's profile
@@ -392,23 +667,26 @@ func (s *authServiceImpl) GetProfile(ctx context.Context, userID int64) (*dto.Us
 
 	// Get profile photo URL if exists
 	var profilePhotoURL string
+	var profilePhotoThumbnails map[string]string
 	if user.ProfilePhotoFileID != nil {
 		file, err := s.fileRepo.GetByID(ctx, *user.ProfilePhotoFileID)
 		if err == nil && file != nil { // Don't fail if photo not found
 			profilePhotoURL = file.FileURL
+			profilePhotoThumbnails = dto.FileThumbnails(file)
 		}
 	}
 
 	// Create base response
 	response := &dto.UserResponse{
-		ID:                 user.ID,
-		Email:              user.Email,
-		FirstName:          user.FirstName,
-		LastName:           user.LastName,
-		Role:               string(user.RoleType),
-		DepartmentID:       user.DepartmentID,
-		ProfilePhotoFileID: user.ProfilePhotoFileID,
-		ProfilePhotoURL:    profilePhotoURL,
+		ID:                     user.ID,
+		Email:                  user.Email,
+		FirstName:              user.FirstName,
+		LastName:               user.LastName,
+		Role:                   string(user.RoleType),
+		DepartmentID:           user.DepartmentID,
+		ProfilePhotoFileID:     user.ProfilePhotoFileID,
+		ProfilePhotoURL:        profilePhotoURL,
+		ProfilePhotoThumbnails: profilePhotoThumbnails,
 	}
 
 	return response, nil
@@ -439,7 +717,7 @@ func (s *authServiceImpl) UpdateProfile(ctx context.Context, userID int64, req *
 // UpdateProfilePhoto updates a user's profile photo
 func (s *authServiceImpl) UpdateProfilePhoto(ctx context.Context, userID int64, file *multipart.FileHeader) error {
 	// Delegate to the user service for a consistent implementation
-	userService := NewUserService(s.userRepo, s.departmentRepo, s.fileRepo, s.fileStorage, s, s.logger)
+	userService := NewUserService(s.userRepo, s.departmentRepo, s.fileRepo, s.fileStorage, s.quotaService, s.scanService, s, s.revocationService, s.logger)
 	_, err := userService.UpdateProfilePhoto(ctx, userID, file)
 	return err
 }
@@ -447,7 +725,7 @@ func (s *authServiceImpl) UpdateProfilePhoto(ctx context.Context, userID int64,
 // DeleteProfilePhoto deletes a user's profile photo
 func (s *authServiceImpl) DeleteProfilePhoto(ctx context.Context, userID int64) error {
 	// Delegate to the user service for a consistent implementation
-	userService := NewUserService(s.userRepo, s.departmentRepo, s.fileRepo, s.fileStorage, s, s.logger)
+	userService := NewUserService(s.userRepo, s.departmentRepo, s.fileRepo, s.fileStorage, s.quotaService, s.scanService, s, s.revocationService, s.logger)
 	return userService.DeleteProfilePhoto(ctx, userID)
 }
 
@@ -523,7 +801,7 @@ func (s *authServiceImpl) VerifyEmail(ctx context.Context, token string) error {
 }
 
 // ResendVerificationEmail sends a new verification email to the user
-func (s *authServiceImpl) ResendVerificationEmail(ctx context.Context, email string) error {
+func (s *authServiceImpl) ResendVerificationEmail(ctx context.Context, email string, client *dto.ClientInfo) error {
 	// Validate email - more lenient to help users
 	if strings.TrimSpace(email) == "" {
 		return fmt.Errorf("%w: email cannot be empty", apperrors.ErrValidationFailed)
@@ -532,6 +810,11 @@ func (s *authServiceImpl) ResendVerificationEmail(ctx context.Context, email str
 	// Convert email to lowercase to ensure consistency
 	email = strings.ToLower(email)
 
+	// Limit how many emails an address or IP address can trigger
+	if err := s.throttleService.AllowEmailRequest(ctx, models.ThrottleActionResendVerification, email, client.IPAddress); err != nil {
+		return err
+	}
+
 	// Get user by email
 	user, err := s.userRepo.GetByEmail(ctx, email)
 	if err != nil {
@@ -594,10 +877,10 @@ func GenerateTokenForVerification() (string, error) {
 	return email.GenerateVerificationToken()
 }
 
-// generateTokenResponse creates token response
-func (s *authServiceImpl) generateTokenResponse(ctx context.Context, user *models.User) (*dto.TokenResponse, error) {
+// generateTokenResponse creates token response, issuing the next refresh token of a token family
+func (s *authServiceImpl) generateTokenResponse(ctx context.Context, user *models.User, familyID int64, client *dto.ClientInfo) (*dto.TokenResponse, error) {
 	// Create access and refresh token pair
-	accessToken, refreshToken, expiresIn, refreshExpiresIn, err := s.jwtService.GenerateTokenPair(user)
+	accessToken, refreshToken, expiresIn, refreshExpiresIn, err := s.jwtService.GenerateTokenPair(user, familyID)
 	if err != nil {
 		return nil, fmt.Errorf("token generation error: %w", err)
 	}
@@ -606,10 +889,15 @@ func (s *authServiceImpl) generateTokenResponse(ctx context.Context, user *model
 	tokenExpiry := s.jwtService.GetRefreshTokenExpiry()
 
 	// Save refresh token to database
-	if err := s.tokenRepo.CreateToken(ctx, refreshToken, user.ID, tokenExpiry); err != nil {
+	if err := s.tokenRepo.CreateToken(ctx, refreshToken, user.ID, familyID, tokenExpiry); err != nil {
 		return nil, fmt.Errorf("token saving error: %w", err)
 	}
 
+	// Record where the session was last used
+	if err := s.tokenRepo.TouchFamily(ctx, familyID, client.UserAgent, client.IPAddress, tokenExpiry); err != nil {
+		s.logger.Warn().Err(err).Int64("familyID", familyID).Msg("Failed to update session")
+	}
+
 	// Create token response
 	tokenResponse := &dto.TokenResponse{
 		AccessToken:           accessToken,
@@ -628,7 +916,7 @@ func GenerateTokenForPasswordReset() (string, error) {
 }
 
 // ForgotPassword initiates the password reset process
-func (s *authServiceImpl) ForgotPassword(ctx context.Context, email string) error {
+func (s *authServiceImpl) ForgotPassword(ctx context.Context, email string, client *dto.ClientInfo) error {
 	// Validate email
 	if err := s.validateEmail(email); err != nil {
 		return err
@@ -637,6 +925,12 @@ func (s *authServiceImpl) ForgotPassword(ctx context.Context, email string) erro
 	// Convert email to lowercase
 	email = strings.ToLower(email)
 
+	// Limit how many emails an address or IP address can trigger. Addresses without an
+	// account are counted too, so the limit does not reveal which have one.
+	if err := s.throttleService.AllowEmailRequest(ctx, models.ThrottleActionForgotPassword, email, client.IPAddress); err != nil {
+		return err
+	}
+
 	// Get user by email
 	user, err := s.userRepo.GetByEmail(ctx, email)
 	if err != nil {
@@ -756,6 +1050,12 @@ func (s *authServiceImpl) ResetPassword(ctx context.Context, token string, newPa
 		// Don't return error since password was updated successfully
 	}
 
+	// Whoever knew the old password may hold tokens, personal access tokens included; sign
+	// out everywhere
+	if err := s.RevokeAllSessions(ctx, userID); err != nil {
+		s.logger.Error().Err(err).Int64("userID", userID).Msg("Failed to revoke tokens after password reset")
+	}
+
 	// Ensure that the user account is active and email is verified
 	if !user.IsActive || !user.EmailVerified {
 		// Do a direct update for activation too
@@ -791,3 +1091,330 @@ func (s *authServiceImpl) ResetPassword(ctx context.Context, token string, newPa
 
 	return nil
 }
+
+// Email change tokens are valid for a day; the link undoing a change for a week, as the old
+// address may not be read every day
+const (
+	emailChangeTokenExpiry     = 24 * time.Hour
+	emailChangeUndoTokenExpiry = 7 * 24 * time.Hour
+)
+
+// ChangePassword changes the password of a logged-in user who knows the current one. Every
+// other session is signed out and personal access tokens are revoked; the current session
+// continues with the token pair returned.
+func (s *authServiceImpl) ChangePassword(ctx context.Context, userID, sessionID int64, req *dto.ChangePasswordRequest, client *dto.ClientInfo) (*dto.ChangePasswordResponse, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCurrentPassword(ctx, user, req.CurrentPassword, client); err != nil {
+		return nil, err
+	}
+	if err := s.checkNewPassword(req.CurrentPassword, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing password: %w", err)
+	}
+	if err := s.userRepo.UpdatePassword(ctx, userID, string(hashedPassword)); err != nil {
+		return nil, fmt.Errorf("error updating user password: %w", err)
+	}
+
+	// Whoever knew the old password may hold tokens, or have created personal access tokens;
+	// only this session stays signed in
+	token, err := s.signOutOtherSessions(ctx, user, sessionID, client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send password changed notification email
+	if err := s.emailService.SendPasswordChangedEmail(user.Email, user.FirstName); err != nil {
+		s.logger.Warn().Err(err).Int64("userID", userID).Msg("Failed to send password changed notification")
+		// Don't return error since password was changed successfully
+	}
+
+	s.logger.Info().Int64("userID", userID).Msg("User changed their password")
+	return &dto.ChangePasswordResponse{
+		Message: "Password changed. Your other sessions have been signed out and your personal access tokens revoked.",
+		Token:   token,
+	}, nil
+}
+
+// RequestEmailChange sends a link confirming a new email address to that address. The
+// account keeps its current address until the link is followed.
+func (s *authServiceImpl) RequestEmailChange(ctx context.Context, userID int64, req *dto.ChangeEmailRequest, client *dto.ClientInfo) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	newEmail := strings.ToLower(strings.TrimSpace(req.NewEmail))
+	if err := s.checkNewEmail(user, newEmail); err != nil {
+		return err
+	}
+
+	if err := s.checkCurrentPassword(ctx, user, req.CurrentPassword, client); err != nil {
+		return err
+	}
+
+	// Limit how many confirmation emails an address or IP address can be sent
+	if err := s.throttleService.AllowEmailRequest(ctx, models.ThrottleActionChangeEmail, newEmail, client.IPAddress); err != nil {
+		return err
+	}
+
+	exists, err := s.userRepo.EmailExists(ctx, newEmail)
+	if err != nil {
+		return fmt.Errorf("error checking if email exists: %w", err)
+	}
+	if exists {
+		return apperrors.ErrEmailAlreadyExists
+	}
+
+	// Only the latest request can be confirmed
+	if err := s.verificationTokenRepo.DeleteEmailChangeTokens(ctx, userID, models.VerificationTokenEmailChange); err != nil {
+		s.logger.Warn().Err(err).Int64("userID", userID).Msg("Failed to delete existing email change tokens")
+		// Continue anyway
+	}
+
+	token, err := GenerateTokenForVerification()
+	if err != nil {
+		return fmt.Errorf("error generating email change token: %w", err)
+	}
+	err = s.verificationTokenRepo.CreateEmailChangeToken(ctx, &models.EmailChangeToken{
+		Token:      token,
+		UserID:     userID,
+		Purpose:    models.VerificationTokenEmailChange,
+		Email:      newEmail,
+		ExpiryDate: time.Now().Add(emailChangeTokenExpiry),
+	})
+	if err != nil {
+		return fmt.Errorf("error storing email change token: %w", err)
+	}
+
+	if err := s.emailService.SendEmailChangeConfirmationEmail(newEmail, user.FirstName, token); err != nil {
+		s.logger.Error().Err(err).Int64("userID", userID).Msg("Failed to send email change confirmation")
+		return fmt.Errorf("error sending email change confirmation: %w", err)
+	}
+
+	s.logger.Info().Int64("userID", userID).Msg("Email change requested")
+	return nil
+}
+
+// ConfirmEmailChange changes a user's email address to the one a confirmation link was sent
+// to. The old address gets a link undoing the change, and every session is signed out so
+// they log in again with the new address.
+func (s *authServiceImpl) ConfirmEmailChange(ctx context.Context, token string) error {
+	changeToken, err := s.consumeEmailChangeToken(ctx, token, models.VerificationTokenEmailChange)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, changeToken.UserID)
+	if err != nil {
+		return err
+	}
+	oldEmail := user.Email
+	if strings.EqualFold(oldEmail, changeToken.Email) {
+		return nil
+	}
+
+	// The address may have been taken since the change was requested
+	if err := s.setEmail(ctx, user, changeToken.Email); err != nil {
+		return err
+	}
+
+	undoToken, err := GenerateTokenForVerification()
+	if err != nil {
+		return fmt.Errorf("error generating email change undo token: %w", err)
+	}
+	err = s.verificationTokenRepo.CreateEmailChangeToken(ctx, &models.EmailChangeToken{
+		Token:      undoToken,
+		UserID:     user.ID,
+		Purpose:    models.VerificationTokenEmailChangeUndo,
+		Email:      oldEmail,
+		ExpiryDate: time.Now().Add(emailChangeUndoTokenExpiry),
+	})
+	if err != nil {
+		return fmt.Errorf("error storing email change undo token: %w", err)
+	}
+
+	if err := s.signOutAllSessions(ctx, user.ID); err != nil {
+		s.logger.Error().Err(err).Int64("userID", user.ID).Msg("Failed to revoke tokens after email change")
+	}
+
+	if err := s.emailService.SendEmailChangedEmail(oldEmail, user.FirstName, changeToken.Email, undoToken); err != nil {
+		s.logger.Error().Err(err).Int64("userID", user.ID).Msg("Failed to send email changed notification to the old address")
+		// Don't return error since the email was changed successfully
+	}
+
+	s.logger.Info().Int64("userID", user.ID).Msg("User changed their email address")
+	return nil
+}
+
+// UndoEmailChange restores the email address an email change replaced, from the link sent
+// to that address. Every session is signed out, as whoever made the change may hold one.
+func (s *authServiceImpl) UndoEmailChange(ctx context.Context, token string) error {
+	undoToken, err := s.consumeEmailChangeToken(ctx, token, models.VerificationTokenEmailChangeUndo)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, undoToken.UserID)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(user.Email, undoToken.Email) {
+		if err := s.setEmail(ctx, user, undoToken.Email); err != nil {
+			return err
+		}
+	}
+
+	// Whoever made the change must not be able to confirm or undo another one
+	for _, purpose := range []string{models.VerificationTokenEmailChange, models.VerificationTokenEmailChangeUndo} {
+		if err := s.verificationTokenRepo.DeleteEmailChangeTokens(ctx, user.ID, purpose); err != nil {
+			s.logger.Warn().Err(err).Int64("userID", user.ID).Str("purpose", purpose).Msg("Failed to delete email change tokens")
+		}
+	}
+
+	if err := s.signOutAllSessions(ctx, user.ID); err != nil {
+		return err
+	}
+
+	s.logger.Warn().Int64("userID", user.ID).Msg("User undid an email change")
+	return nil
+}
+
+// checkCurrentPassword confirms a change to a user's credentials with their current
+// password. Wrong passwords count as failed logins, so a stolen access token cannot be used
+// to guess it. Directory accounts change their credentials in the directory instead.
+func (s *authServiceImpl) checkCurrentPassword(ctx context.Context, user *models.User, password string, client *dto.ClientInfo) error {
+	linked, err := s.ldapService.IsLinked(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if linked {
+		return apperrors.NewBadRequestError("Your password and email address are managed by the university directory; change them there")
+	}
+
+	throttleEmail := strings.ToLower(user.Email)
+	if err := s.throttleService.CheckLogin(ctx, throttleEmail, client.IPAddress); err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		s.throttleService.RecordLoginFailure(ctx, throttleEmail, client.IPAddress, user)
+		return apperrors.NewBadRequestError("Current password is incorrect")
+	}
+	s.throttleService.RecordLoginSuccess(ctx, throttleEmail)
+	return nil
+}
+
+// checkNewPassword checks that a new password meets the requirements and differs from the current one
+func (s *authServiceImpl) checkNewPassword(currentPassword, newPassword string) error {
+	if err := s.ValidatePassword(newPassword); err != nil {
+		return err
+	}
+	if newPassword == currentPassword {
+		return apperrors.NewBadRequestError("The new password must be different from the current one")
+	}
+	return nil
+}
+
+// checkNewEmail checks that a user may change their email address to a normalized newEmail
+func (s *authServiceImpl) checkNewEmail(user *models.User, newEmail string) error {
+	if err := s.validateEmail(newEmail); err != nil {
+		return err
+	}
+	if strings.EqualFold(user.Email, newEmail) {
+		return apperrors.NewBadRequestError("This is already your email address")
+	}
+	// The address is what made the account a student's, so students keep a student address
+	if user.RoleType == models.RoleStudent && s.roleResolver.ResolveRole(newEmail) != models.RoleStudent {
+		return apperrors.NewBadRequestError("Student accounts have to use a student email address")
+	}
+	return nil
+}
+
+// consumeEmailChangeToken uses up an email change token with a purpose
+func (s *authServiceImpl) consumeEmailChangeToken(ctx context.Context, token, purpose string) (*models.EmailChangeToken, error) {
+	if strings.TrimSpace(token) == "" {
+		return nil, apperrors.ErrInvalidEmailToken
+	}
+
+	changeToken, err := s.verificationTokenRepo.ConsumeEmailChangeToken(ctx, token, purpose)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("purpose", purpose).Msg("Failed to get email change token")
+		return nil, apperrors.ErrInvalidEmailToken
+	}
+	if changeToken.ExpiryDate.Before(time.Now()) {
+		s.logger.Warn().Int64("userID", changeToken.UserID).Str("purpose", purpose).Msg("Email change token expired")
+		return nil, apperrors.ErrInvalidEmailToken
+	}
+	return changeToken, nil
+}
+
+// setEmail changes the email address of a user, unless another account has it
+func (s *authServiceImpl) setEmail(ctx context.Context, user *models.User, email string) error {
+	other, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, apperrors.ErrUserNotFound) {
+		return fmt.Errorf("error checking if email exists: %w", err)
+	}
+	if other != nil && other.ID != user.ID {
+		return apperrors.ErrEmailAlreadyExists
+	}
+
+	user.Email = email
+	return s.userRepo.Update(ctx, user)
+}
+
+// signOutOtherSessions signs a user out of every session but the current one and returns a
+// new token pair for it, as the access tokens it holds are revoked with all others. Without a
+// current session, as for tokens issued before sessions existed, every session is signed out.
+func (s *authServiceImpl) signOutOtherSessions(ctx context.Context, user *models.User, sessionID int64, client *dto.ClientInfo) (*dto.TokenResponse, error) {
+	if sessionID == 0 {
+		return nil, s.signOutAllSessions(ctx, user.ID)
+	}
+
+	revoked, err := s.tokenRepo.RevokeOtherFamilies(ctx, user.ID, sessionID, models.TokenFamilyCredentialsChanged)
+	if err != nil {
+		return nil, err
+	}
+	// The current session's refresh tokens too: it continues with the new pair only
+	if err := s.tokenRepo.RevokeAllUserTokens(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if err := s.revocationService.RevokeUserTokens(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Int64("userID", user.ID).Int64("sessions", revoked).Msg("Other sessions signed out")
+
+	family, err := s.tokenRepo.GetFamily(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("session lookup error: %w", err)
+	}
+	if family.UserID != user.ID || family.RevokedAt != nil {
+		return nil, nil
+	}
+	// Access tokens issued in the second of the cutoff are rejected; issue the new pair after it
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	return s.generateTokenResponse(ctx, user, sessionID, client)
+}
+
+// signOutAllSessions signs a user out of every session after their credentials changed
+func (s *authServiceImpl) signOutAllSessions(ctx context.Context, userID int64) error {
+	revoked, err := s.tokenRepo.RevokeAllFamilies(ctx, userID, models.TokenFamilyCredentialsChanged)
+	if err != nil {
+		return err
+	}
+	// Tokens issued before families existed are not in any
+	if err := s.tokenRepo.RevokeAllUserTokens(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.revocationService.RevokeUserTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	s.logger.Info().Int64("userID", userID).Int64("sessions", revoked).Msg("All sessions signed out")
+	return nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeLDAPService reports every user as linked or not
+type fakeLDAPService struct {
+	LDAPService
+	linked bool
+}
+
+func (f *fakeLDAPService) IsLinked(ctx context.Context, userID int64) (bool, error) {
+	return f.linked, nil
+}
+
+// fakeThrottleService records the logins it is told about and puts them on hold when onHold is set
+type fakeThrottleService struct {
+	ThrottleService
+	onHold    bool
+	failures  int
+	successes int
+}
+
+func (f *fakeThrottleService) CheckLogin(ctx context.Context, email, ip string) error {
+	if f.onHold {
+		return apperrors.ErrTooManyAttempts
+	}
+	return nil
+}
+
+func (f *fakeThrottleService) RecordLoginFailure(ctx context.Context, email, ip string, user *models.User) {
+	f.failures++
+}
+
+func (f *fakeThrottleService) RecordLoginSuccess(ctx context.Context, email string) {
+	f.successes++
+}
+
+func TestCheckCurrentPassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("Current1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+	user := &models.User{ID: 1, Email: "Ada@example.edu", Password: string(hash)}
+
+	tests := []struct {
+		name          string
+		password      string
+		linked        bool
+		onHold        bool
+		wantErr       error
+		wantFailures  int
+		wantSuccesses int
+	}{
+		{"correct", "Current1", false, false, nil, 0, 1},
+		{"wrong", "current1", false, false, apperrors.ErrBadRequest, 1, 0},
+		{"empty", "", false, false, apperrors.ErrBadRequest, 1, 0},
+		{"on hold", "Current1", false, true, apperrors.ErrTooManyAttempts, 0, 0},
+		{"directory account", "Current1", true, false, apperrors.ErrBadRequest, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := &fakeThrottleService{onHold: tt.onHold}
+			s := &authServiceImpl{ldapService: &fakeLDAPService{linked: tt.linked}, throttleService: throttle}
+
+			err := s.checkCurrentPassword(context.Background(), user, tt.password, &dto.ClientInfo{IPAddress: "192.0.2.1"})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("checkCurrentPassword() error = %v, want %v", err, tt.wantErr)
+			}
+			if throttle.failures != tt.wantFailures || throttle.successes != tt.wantSuccesses {
+				t.Errorf("recorded %d failures and %d successes, want %d and %d", throttle.failures, throttle.successes, tt.wantFailures, tt.wantSuccesses)
+			}
+		})
+	}
+}
+
+func TestCheckNewPassword(t *testing.T) {
+	s := &authServiceImpl{}
+
+	tests := []struct {
+		name        string
+		newPassword string
+		wantErr     error
+	}{
+		{"valid", "Changed12", nil},
+		{"same as the current one", "Current12", apperrors.ErrBadRequest},
+		{"too short", "Ch4nged", apperrors.ErrValidationFailed},
+		{"no upper case", "changed12", apperrors.ErrValidationFailed},
+		{"empty", "", apperrors.ErrValidationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.checkNewPassword("Current12", tt.newPassword)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("checkNewPassword() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckNewEmail(t *testing.T) {
+	roleResolver, err := NewRoleResolver(RoleStrategyStudentDomain, []string{"std.example.edu"})
+	if err != nil {
+		t.Fatalf("NewRoleResolver: %v", err)
+	}
+	s := &authServiceImpl{roleResolver: roleResolver}
+	student := &models.User{Email: "ada@std.example.edu", RoleType: models.RoleStudent}
+	instructor := &models.User{Email: "grace@example.edu", RoleType: models.RoleInstructor}
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		newEmail string
+		wantErr  error
+	}{
+		{"student to a student address", student, "lovelace@std.example.edu", nil},
+		{"student to another address", student, "ada@example.com", apperrors.ErrBadRequest},
+		{"instructor to any address", instructor, "grace@example.com", nil},
+		{"instructor to a student address", instructor, "grace@std.example.edu", nil},
+		{"same address", instructor, "grace@example.edu", apperrors.ErrBadRequest},
+		{"same address stored in another case", &models.User{Email: "Grace@Example.edu", RoleType: models.RoleInstructor}, "grace@example.edu", apperrors.ErrBadRequest},
+		{"invalid", instructor, "grace", apperrors.ErrInvalidEmail},
+		{"empty", instructor, "", apperrors.ErrValidationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.checkNewEmail(tt.user, tt.newEmail)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("checkNewEmail() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+)
+
+// maxBundleFiles caps how many files a single ZIP bundle may contain
+const maxBundleFiles = 1000
+
+// maxBundleSegmentLength caps the length of a single folder or file name in a bundle
+const maxBundleSegmentLength = 100
+
+// bundleBuilder collects bundle entries and keeps archive paths unique
+type bundleBuilder struct {
+	entries []dto.FileBundleEntry
+	used    map[string]struct{}
+}
+
+func newBundleBuilder() *bundleBuilder {
+	return &bundleBuilder{used: make(map[string]struct{})}
+}
+
+// folder returns a unique folder path made of the sanitized segments
+func (b *bundleBuilder) folder(segments ...string) string {
+	clean := make([]string, len(segments))
+	for i, segment := range segments {
+		clean[i] = bundleSegment(segment)
+	}
+	return b.unique(path.Join(clean...), "")
+}
+
+// addFiles adds the files under the given folder. Files that are not known to be free
+// of malware are left out.
+func (b *bundleBuilder) addFiles(folder string, files []*models.File) {
+	for _, file := range files {
+		if file.ScanStatus != models.ScanStatusClean {
+			continue
+		}
+		name := bundleSegment(file.FileName)
+		ext := path.Ext(name)
+		archivePath := b.unique(path.Join(folder, strings.TrimSuffix(name, ext)), ext)
+		b.entries = append(b.entries, dto.FileBundleEntry{
+			ArchivePath: archivePath,
+			FilePath:    file.FilePath,
+			ModifiedAt:  file.UpdatedAt,
+		})
+	}
+}
+
+// unique appends " (2)", " (3)", ... to base until base+ext has not been used yet
+func (b *bundleBuilder) unique(base, ext string) string {
+	candidate := base + ext
+	for n := 2; ; n++ {
+		if _, taken := b.used[candidate]; !taken {
+			break
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, n, ext)
+	}
+	b.used[candidate] = struct{}{}
+	return candidate
+}
+
+// bundleSegment makes a title or file name safe to use as a single ZIP path segment
+func bundleSegment(name string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	cleaned = strings.Trim(strings.TrimSpace(cleaned), ".")
+
+	if runes := []rune(cleaned); len(runes) > maxBundleSegmentLength {
+		cleaned = strings.TrimSpace(string(runes[:maxBundleSegmentLength]))
+	}
+	if cleaned == "" {
+		return "untitled"
+	}
+	return cleaned
+}
+
+// bundleFileName builds the download name of a bundle from its parts
+func bundleFileName(parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		part = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '.' {
+				return r
+			}
+			return '_'
+		}, strings.TrimSpace(part))
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	if len(kept) == 0 {
+		return "bundle.zip"
+	}
+	return strings.Join(kept, "_") + ".zip"
+}
@@ -12,12 +12,13 @@ import (
 	"github.com/yigit/unisphere/internal/app/repositories"
 	"github.com/yigit/unisphere/internal/pkg/apperrors"
 	"github.com/yigit/unisphere/internal/pkg/filestorage"
+	"github.com/yigit/unisphere/internal/pkg/helpers"
 	"github.com/yigit/unisphere/internal/pkg/websocket"
 )
 
 // ChatService defines the interface for chat operations
 type ChatService interface {
-	GetChatMessages(ctx context.Context, communityID int64, filter *dto.GetChatMessagesRequest) ([]dto.ChatMessageResponse, error)
+	GetChatMessages(ctx context.Context, communityID int64, filter *dto.GetChatMessagesRequest) ([]dto.ChatMessageResponse, *dto.CursorInfo, error)
 	GetChatMessageByID(ctx context.Context, messageID int64) (*dto.ChatMessageDetailResponse, error)
 	SendTextMessage(ctx context.Context, communityID int64, message *dto.CreateChatMessageRequest) (*dto.ChatMessageResponse, error)
 	SendFileMessage(ctx context.Context, communityID int64, message *dto.CreateChatMessageRequest, file *multipart.FileHeader) (*dto.ChatMessageResponse, error)
@@ -31,7 +32,9 @@ type chatServiceImpl struct {
 	communityParticipantRepo *repositories.CommunityParticipantRepository
 	userRepo                 *repositories.UserRepository
 	fileRepo                 *repositories.FileRepository
-	fileStorage              *filestorage.LocalStorage
+	fileStorage              filestorage.FileStorage
+	quotaService             QuotaService
+	scanService              ScanService
 	wsHub                    *websocket.Hub 
 	logger                   zerolog.Logger
 }
@@ -43,7 +46,9 @@ func NewChatService(
 	communityParticipantRepo *repositories.CommunityParticipantRepository,
 	userRepo *repositories.UserRepository,
 	fileRepo *repositories.FileRepository,
-	fileStorage *filestorage.LocalStorage,
+	fileStorage filestorage.FileStorage,
+	quotaService QuotaService,
+	scanService ScanService,
 	wsHub *websocket.Hub,
 	logger zerolog.Logger,
 ) ChatService {
@@ -54,6 +59,8 @@ func NewChatService(
 		userRepo:                 userRepo,
 		fileRepo:                 fileRepo,
 		fileStorage:              fileStorage,
+		quotaService:             quotaService,
+		scanService:              scanService,
 		wsHub:                    wsHub,
 		logger:                   logger,
 	}
@@ -64,7 +71,7 @@ func (s *chatServiceImpl) GetChatMessages(
 	ctx context.Context,
 	communityID int64,
 	filter *dto.GetChatMessagesRequest,
-) ([]dto.ChatMessageResponse, error) {
+) ([]dto.ChatMessageResponse, *dto.CursorInfo, error) {
 	s.logger.Debug().
 		Int64("communityID", communityID).
 		Interface("filter", filter).
@@ -76,18 +83,18 @@ func (s *chatServiceImpl) GetChatMessages(
 		s.logger.Error().Err(err).
 			Int64("communityID", communityID).
 			Msg("Failed to get community")
-		return nil, apperrors.NewResourceNotFoundError("Community not found")
+		return nil, nil, apperrors.NewResourceNotFoundError("Community not found")
 	}
 	
 	if community == nil {
-		return nil, apperrors.NewResourceNotFoundError("Community not found")
+		return nil, nil, apperrors.NewResourceNotFoundError("Community not found")
 	}
 
 	// Get user ID from context for authorization
 	userID, ok := ctx.Value("userID").(int64)
 	if !ok {
 		s.logger.Error().Msg("User ID not found in context")
-		return nil, fmt.Errorf("user ID not found in context")
+		return nil, nil, fmt.Errorf("user ID not found in context")
 	}
 
 	// Check if user is a participant in the community
@@ -97,27 +104,33 @@ func (s *chatServiceImpl) GetChatMessages(
 			Int64("communityID", communityID).
 			Int64("userID", userID).
 			Msg("Failed to check if user is participant")
-		return nil, fmt.Errorf("error checking participant status: %w", err)
+		return nil, nil, fmt.Errorf("error checking participant status: %w", err)
 	}
 
 	if !isParticipant {
-		return nil, apperrors.NewForbiddenError("User is not a participant in this community")
+		return nil, nil, apperrors.NewForbiddenError("User is not a participant in this community")
+	}
+
+	cursor, err := helpers.ParseCursor(filter.AfterCursor, filter.BeforeCursor)
+	if err != nil {
+		return nil, nil, apperrors.NewBadRequestError(err.Error())
 	}
 
 	// Retrieve messages
-	messages, err := s.chatRepo.GetByCommunityID(
+	messages, hasMore, err := s.chatRepo.GetByCommunityID(
 		ctx,
 		communityID,
 		filter.Before,
 		filter.After,
 		filter.SenderID,
 		filter.Limit,
+		cursor,
 	)
 	if err != nil {
 		s.logger.Error().Err(err).
 			Int64("communityID", communityID).
 			Msg("Failed to retrieve chat messages")
-		return nil, fmt.Errorf("error retrieving chat messages: %w", err)
+		return nil, nil, fmt.Errorf("error retrieving chat messages: %w", err)
 	}
 
 	// Convert to DTOs
@@ -126,7 +139,17 @@ func (s *chatServiceImpl) GetChatMessages(
 		responseMessages = append(responseMessages, dto.ToChatMessageResponse(message))
 	}
 
-	return responseMessages, nil
+	// Chat has no total count; a first page behaves like page 1 of an unknown-length list
+	// and hasMore alone decides whether older messages follow.
+	total := int64(len(messages))
+	if hasMore {
+		total++
+	}
+	cursorInfo := helpers.NewCursorInfo(repositories.ChatMessageSort, len(messages), func(i int) (interface{}, int64) {
+		return messages[i].CreatedAt, messages[i].ID
+	}, cursor, hasMore, 1, len(messages), total)
+
+	return responseMessages, cursorInfo, nil
 }
 
 // GetChatMessageByID retrieves a specific chat message
@@ -341,6 +364,14 @@ func (s *chatServiceImpl) SendFileMessage(
 		return nil, apperrors.NewForbiddenError("User is not a participant in this community")
 	}
 
+	// Chat attachments count against both the sender's and the community's quota
+	if err := s.quotaService.CheckUserQuota(ctx, userID, fileHeader.Size); err != nil {
+		return nil, err
+	}
+	if err := s.quotaService.CheckCommunityQuota(ctx, communityID, fileHeader.Size); err != nil {
+		return nil, err
+	}
+
 	// Upload the file
 	uploadedFile, err := s.uploadFile(ctx, fileHeader, models.FileTypeChatMessage, communityID, userID)
 	if err != nil {
@@ -377,7 +408,7 @@ func (s *chatServiceImpl) SendFileMessage(
 			Msg("Failed to create chat message")
 		
 		// Clean up - delete the file if we couldn't create the message
-		_ = s.fileStorage.DeleteFile(uploadedFile.FilePath)
+		deleteStoredFile(s.fileStorage, uploadedFile)
 		_ = s.fileRepo.Delete(ctx, uploadedFile.ID)
 		
 		return nil, fmt.Errorf("error creating chat message: %w", err)
@@ -393,14 +424,15 @@ func (s *chatServiceImpl) SendFileMessage(
 	if s.wsHub != nil {
 		// Create WebSocket message
 		wsMessage := &websocket.Message{
-			Type:        "file",
-			CommunityID: communityID,
-			SenderID:    userID,
-			Content:     content,
-			FileURL:     uploadedFile.FileURL,
-			FileID:      uploadedFile.ID,
-			Timestamp:   message.CreatedAt,
-			ID:          message.ID,
+			Type:           "file",
+			CommunityID:    communityID,
+			SenderID:       userID,
+			Content:        content,
+			FileURL:        dto.FileAccessURL(uploadedFile),
+			FileID:         uploadedFile.ID,
+			FileThumbnails: dto.FileThumbnails(uploadedFile),
+			Timestamp:      message.CreatedAt,
+			ID:             message.ID,
 		}
 
 		// Broadcast to all connected clients in the community
@@ -467,8 +499,8 @@ func (s *chatServiceImpl) DeleteMessage(
 	if message.FileID != nil {
 		file, err := s.fileRepo.GetByID(ctx, *message.FileID)
 		if err == nil && file != nil {
-			// Delete physical file
-			_ = s.fileStorage.DeleteFile(file.FilePath)
+			// Delete physical file and its thumbnails
+			deleteStoredFile(s.fileStorage, file)
 			// Delete file record
 			_ = s.fileRepo.Delete(ctx, file.ID)
 		}
@@ -504,6 +536,12 @@ func (s *chatServiceImpl) uploadFile(
 	resourceID int64,
 	userID int64,
 ) (*models.File, error) {
+	// Validate against the upload policy and use the detected MIME type
+	contentType, err := checkUpload(resourceType, fileHeader)
+	if err != nil {
+		return nil, err
+	}
+
 	// Open the file
 	src, err := fileHeader.Open()
 	if err != nil {
@@ -514,34 +552,37 @@ func (s *chatServiceImpl) uploadFile(
 	// Generate a storage path based on resource type and ID
 	subPath := fmt.Sprintf("%s_%d", resourceType, resourceID)
 
-	// Upload to storage with the original FileHeader
-	fileURL, err := s.fileStorage.SaveFileWithPath(fileHeader, subPath)
+	// Upload to storage; images are normalized and get thumbnails
+	stored, err := storeUpload(s.fileStorage, resourceType, fileHeader, subPath, contentType)
 	if err != nil {
 		return nil, fmt.Errorf("error uploading file: %w", err)
 	}
 
 	// Extract relative path from URL
-	relativeFilePath := strings.TrimPrefix(fileURL, s.fileStorage.GetBaseURL())
+	relativeFilePath := strings.TrimPrefix(stored.FileURL, s.fileStorage.GetBaseURL())
 	relativeFilePath = strings.TrimPrefix(relativeFilePath, "/uploads/")
 
 	// Create file model
 	file := &models.File{
-		FileName:     fileHeader.Filename,
-		FilePath:     relativeFilePath,
-		FileURL:      fileURL,
-		FileSize:     fileHeader.Size,
-		FileType:     fileHeader.Header.Get("Content-Type"),
-		ResourceType: resourceType,
-		ResourceID:   resourceID,
-		UploadedBy:   userID,
+		FileName:       stored.FileName,
+		FilePath:       relativeFilePath,
+		FileURL:        stored.FileURL,
+		FileSize:       stored.FileSize,
+		FileType:       stored.FileType,
+		ResourceType:   resourceType,
+		ResourceID:     resourceID,
+		UploadedBy:     userID,
+		ThumbnailSizes: stored.ThumbnailSizes,
 	}
 
 	// Save file metadata to database
 	fileID, err := s.fileRepo.Create(ctx, file)
 	if err != nil {
+		deleteStoredFile(s.fileStorage, file)
 		return nil, fmt.Errorf("error saving file metadata: %w", err)
 	}
 	file.ID = fileID
+	s.scanService.Enqueue(ctx, fileID)
 
 	return file, nil
 }
\ No newline at end of file
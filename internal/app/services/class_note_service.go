@@ -14,30 +14,35 @@ import (
 	"github.com/yigit/unisphere/internal/pkg/apperrors"
 	"github.com/yigit/unisphere/internal/pkg/filestorage"
 	"github.com/yigit/unisphere/internal/pkg/helpers"
+	"github.com/yigit/unisphere/internal/pkg/markdown"
 )
 
 // ClassNoteService defines the interface for class note operations
 type ClassNoteService interface {
 	GetAllNotes(ctx context.Context, filter *dto.ClassNoteFilterRequest) (*dto.ClassNoteListResponse, error)
-	GetNoteByID(ctx context.Context, id int64) (*dto.ClassNoteResponse, error)
+	GetNoteByID(ctx context.Context, id int64, includeHTML bool) (*dto.ClassNoteResponse, error)
 	CreateNote(ctx context.Context, req *dto.CreateClassNoteRequest, files []*multipart.FileHeader) (*dto.ClassNoteResponse, error)
 	UpdateNote(ctx context.Context, id int64, req *dto.UpdateClassNoteRequest) (*dto.ClassNoteResponse, error)
 	DeleteNote(ctx context.Context, id int64) error
-	AddFileToNote(ctx context.Context, noteID int64, file *multipart.FileHeader) error
+	AddFileToNote(ctx context.Context, noteID int64, file *multipart.FileHeader) ([]dto.DuplicateWarning, error)
 	AddFilesToNote(ctx context.Context, noteID int64, files []*multipart.FileHeader) (*dto.ClassNoteResponse, error)
 	RemoveFileFromNote(ctx context.Context, noteID int64, fileID int64) error
 	DeleteFileFromNote(ctx context.Context, noteID int64, fileID int64) error
 	GetFileDetails(ctx context.Context, fileID int64) (*dto.ClassNoteFileResponse, error)
+	GetNoteBundle(ctx context.Context, id int64) (*dto.FileBundle, error)
 }
 
 // classNoteServiceImpl implements ClassNoteService
 type classNoteServiceImpl struct {
-	classNoteRepo  *repositories.ClassNoteRepository
-	departmentRepo *repositories.DepartmentRepository
-	fileRepo       *repositories.FileRepository
-	fileStorage    *filestorage.LocalStorage
-	authzService   *auth.AuthorizationService
-	logger         zerolog.Logger
+	classNoteRepo    *repositories.ClassNoteRepository
+	departmentRepo   *repositories.DepartmentRepository
+	fileRepo         *repositories.FileRepository
+	fileStorage      filestorage.FileStorage
+	quotaService     QuotaService
+	scanService      ScanService
+	authzService     *auth.AuthorizationService
+	duplicateService DuplicateService
+	logger           zerolog.Logger
 }
 
 // NewClassNoteService creates a new ClassNoteService
@@ -45,17 +50,23 @@ func NewClassNoteService(
 	classNoteRepo *repositories.ClassNoteRepository,
 	departmentRepo *repositories.DepartmentRepository,
 	fileRepo *repositories.FileRepository,
-	fileStorage *filestorage.LocalStorage,
+	fileStorage filestorage.FileStorage,
+	quotaService QuotaService,
+	scanService ScanService,
 	authzService *auth.AuthorizationService,
+	duplicateService DuplicateService,
 	logger zerolog.Logger,
 ) ClassNoteService {
 	return &classNoteServiceImpl{
-		classNoteRepo:  classNoteRepo,
-		departmentRepo: departmentRepo,
-		fileRepo:       fileRepo,
-		fileStorage:    fileStorage,
-		authzService:   authzService,
-		logger:         logger,
+		classNoteRepo:    classNoteRepo,
+		departmentRepo:   departmentRepo,
+		fileRepo:         fileRepo,
+		fileStorage:      fileStorage,
+		quotaService:     quotaService,
+		scanService:      scanService,
+		authzService:     authzService,
+		duplicateService: duplicateService,
+		logger:           logger,
 	}
 }
 
@@ -65,9 +76,15 @@ func (s *classNoteServiceImpl) GetAllNotes(ctx context.Context, filter *dto.Clas
 		Interface("filter", filter).
 		Msg("Getting all class notes")
 
-	// Get notes from repository with sorting parameters
-	notes, total, err := s.classNoteRepo.GetAll(ctx, filter.DepartmentID, filter.CourseCode, filter.InstructorID, 
-		filter.Page, filter.PageSize, filter.SortBy, filter.SortOrder)
+	cursor, err := helpers.ParseCursor(filter.After, filter.Before)
+	if err != nil {
+		return nil, apperrors.NewBadRequestError(err.Error())
+	}
+
+	// Get notes from repository with sorting parameters; files are only loaded when expanded
+	withFiles := filter.Projection.ExpandsOr("files", true)
+	notes, total, hasMore, err := s.classNoteRepo.GetAll(ctx, filter.DepartmentID, filter.CourseCode, filter.InstructorID, 
+		filter.Page, filter.PageSize, filter.SortBy, filter.SortOrder, cursor, withFiles)
 	if err != nil {
 		s.logger.Error().Err(err).
 			Interface("filter", filter).
@@ -82,52 +99,77 @@ func (s *classNoteServiceImpl) GetAllNotes(ctx context.Context, filter *dto.Clas
 		Str("sortOrder", filter.SortOrder).
 		Msg("Retrieved class notes successfully")
 
+	var departments *departmentExpander
+	if filter.Projection.Expands("department") {
+		departments = newDepartmentExpander(s.departmentRepo)
+	}
+
 	// Convert to response DTOs
 	var noteResponses []dto.ClassNoteResponse
 	for _, note := range notes {
-		// Dosyaları getir
-		files, err := s.classNoteRepo.GetClassNoteFiles(ctx, note.ID)
-		if err != nil {
-			s.logger.Error().Err(err).
-				Int64("noteID", note.ID).
-				Msg("Failed to get files for class note")
-			// Hata durumunda boş dosya listesi ile devam edelim
-			files = []*models.File{}
-		}
-
 		// Sadece dosya ID'lerini içeren yanıtlar oluştur
 		var fileResponses []dto.SimpleClassNoteFileResponse
-		for _, file := range files {
+		for _, file := range note.Files {
 			fileResponses = append(fileResponses, dto.SimpleClassNoteFileResponse{
 				ID: file.ID,
 			})
 		}
 
+		var department *dto.DepartmentResponse
+		if departments != nil {
+			department, err = departments.get(ctx, note.DepartmentID)
+			if err != nil {
+				return nil, fmt.Errorf("error getting department for class note %d: %w", note.ID, err)
+			}
+		}
+
 		noteResponses = append(noteResponses, dto.ClassNoteResponse{
 			ID:           note.ID,
 			CourseCode:   note.CourseCode,
 			Title:        note.Title,
 			Description:  note.Description,
-			Content:      note.Content,
+			Content:      contentSource(note.Content, note.ContentHTMLVersion),
+			ContentHTML:  contentHTML(filter.IncludeHTML, note.Content, note.ContentHTML, note.ContentHTMLVersion),
 			DepartmentID: note.DepartmentID,
 			UserID:       note.UserID,
 			CreatedAt:    note.CreatedAt,
 			UpdatedAt:    note.UpdatedAt,
 			Files:        fileResponses,
+			Department:   department,
 		})
 	}
 
 	// Create response with pagination using the helper function
 	paginationInfo := helpers.NewPaginationInfo(total, filter.Page, filter.PageSize)
 
+	sortBy, _, sortKey := repositories.ClassNoteSort(filter.SortBy, filter.SortOrder)
+	cursorInfo := helpers.NewCursorInfo(sortKey, len(notes), func(i int) (interface{}, int64) {
+		return classNoteSortValue(&notes[i], sortBy), notes[i].ID
+	}, cursor, hasMore, filter.Page, filter.PageSize, total)
+
 	return &dto.ClassNoteListResponse{
 		ClassNotes:     noteResponses,
 		PaginationInfo: paginationInfo,
+		Cursor:         cursorInfo,
 	}, nil
 }
 
-// GetNoteByID retrieves a class note by ID
-func (s *classNoteServiceImpl) GetNoteByID(ctx context.Context, id int64) (*dto.ClassNoteResponse, error) {
+// classNoteSortValue returns the value of the column a class note list is sorted by
+func classNoteSortValue(note *models.ClassNote, sortBy string) interface{} {
+	switch sortBy {
+	case "updated_at":
+		return note.UpdatedAt
+	case "title":
+		return note.Title
+	case "course_code":
+		return note.CourseCode
+	default:
+		return note.CreatedAt
+	}
+}
+
+// GetNoteByID retrieves a class note by ID; its content HTML only when includeHTML is set
+func (s *classNoteServiceImpl) GetNoteByID(ctx context.Context, id int64, includeHTML bool) (*dto.ClassNoteResponse, error) {
 	// Get note from repository
 	note, err := s.classNoteRepo.GetByID(ctx, id)
 	if err != nil {
@@ -151,7 +193,8 @@ func (s *classNoteServiceImpl) GetNoteByID(ctx context.Context, id int64) (*dto.
 		CourseCode:   note.CourseCode,
 		Title:        note.Title,
 		Description:  note.Description,
-		Content:      note.Content,
+		Content:      contentSource(note.Content, note.ContentHTMLVersion),
+		ContentHTML:  contentHTML(includeHTML, note.Content, note.ContentHTML, note.ContentHTMLVersion),
 		DepartmentID: note.DepartmentID,
 		UserID:       note.UserID,
 		CreatedAt:    note.CreatedAt,
@@ -179,7 +222,7 @@ func (s *classNoteServiceImpl) GetFileDetails(ctx context.Context, fileID int64)
 	return &dto.ClassNoteFileResponse{
 		ID:        file.ID,
 		FileName:  file.FileName,
-		FileURL:   file.FileURL,
+		FileURL:   dto.FileAccessURL(file),
 		FileSize:  file.FileSize,
 		FileType:  file.FileType,
 		CreatedAt: file.CreatedAt,
@@ -200,16 +243,31 @@ func (s *classNoteServiceImpl) CreateNote(ctx context.Context, req *dto.CreateCl
 		return nil, fmt.Errorf("user ID not found in context")
 	}
 
+	// Reject the request before anything is stored if a file breaks the upload policy
+	if _, err := checkUploads(ctx, s.fileRepo, models.FileTypeClassNote, 0, files); err != nil {
+		return nil, err
+	}
+	if err := s.quotaService.CheckUserQuota(ctx, userID, uploadSize(files)); err != nil {
+		return nil, err
+	}
+
 	// Create note model
 	note := &models.ClassNote{
 		CourseCode:   req.CourseCode,
 		Title:        req.Title,
 		Description:  req.Description,
-		Content:      req.Content,
+		Content:      markdown.StripHTML(req.Content),
 		DepartmentID: req.DepartmentID,
 		UserID:       userID,
 	}
 
+	// Render Markdown content to sanitized HTML
+	renderedHTML, renderedVersion, err := renderContentHTML(note.Content)
+	if err != nil {
+		return nil, err
+	}
+	note.ContentHTML, note.ContentHTMLVersion = renderedHTML, renderedVersion
+
 	// Save note to database
 	noteID, err := s.classNoteRepo.Create(ctx, note)
 	if err != nil {
@@ -227,10 +285,19 @@ func (s *classNoteServiceImpl) CreateNote(ctx context.Context, req *dto.CreateCl
 
 	// Sadece dosya ID'lerini içeren yanıtlar oluştur
 	var fileResponses []dto.SimpleClassNoteFileResponse
+	var duplicateWarnings []dto.DuplicateWarning
 
 	// Process files if any
 	if len(files) > 0 {
 		for _, file := range files {
+			contentType, err := checkUpload(models.FileTypeClassNote, file)
+			if err != nil {
+				s.logger.Error().Err(err).
+					Str("fileName", file.Filename).
+					Msg("File rejected by upload policy")
+				continue
+			}
+
 			// Dosyayı kaydet
 			fileURL, err := s.fileStorage.SaveFileWithPath(file, "class_notes")
 			if err != nil {
@@ -254,7 +321,7 @@ func (s *classNoteServiceImpl) CreateNote(ctx context.Context, req *dto.CreateCl
 				FilePath:     relativeFilePath,
 				FileURL:      fileURL,
 				FileSize:     file.Size,
-				FileType:     file.Header.Get("Content-Type"),
+				FileType:     contentType,
 				ResourceType: "CLASS_NOTE",
 				ResourceID:   noteID,
 				UploadedBy:   userID,
@@ -272,6 +339,7 @@ func (s *classNoteServiceImpl) CreateNote(ctx context.Context, req *dto.CreateCl
 			}
 
 			fileRecord.ID = fileID
+			s.scanService.Enqueue(ctx, fileID)
 
 			// Dosya ve not arasında ilişki kur
 			err = s.classNoteRepo.AddFileToClassNote(ctx, noteID, fileID)
@@ -287,21 +355,23 @@ func (s *classNoteServiceImpl) CreateNote(ctx context.Context, req *dto.CreateCl
 			fileResponses = append(fileResponses, dto.SimpleClassNoteFileResponse{
 				ID: fileID,
 			})
+			duplicateWarnings = append(duplicateWarnings, s.duplicateService.CheckFile(ctx, fileRecord, file)...)
 		}
 	}
 
 	// Return response
 	return &dto.ClassNoteResponse{
-		ID:           noteID,
-		CourseCode:   note.CourseCode,
-		Title:        note.Title,
-		Description:  note.Description,
-		Content:      note.Content,
-		DepartmentID: note.DepartmentID,
-		UserID:       note.UserID,
-		CreatedAt:    note.CreatedAt,
-		UpdatedAt:    note.UpdatedAt,
-		Files:        fileResponses,
+		ID:                noteID,
+		CourseCode:        note.CourseCode,
+		Title:             note.Title,
+		Description:       note.Description,
+		Content:           note.Content,
+		DepartmentID:      note.DepartmentID,
+		UserID:            note.UserID,
+		CreatedAt:         note.CreatedAt,
+		UpdatedAt:         note.UpdatedAt,
+		Files:             fileResponses,
+		DuplicateWarnings: duplicateWarnings,
 	}, nil
 }
 
@@ -349,7 +419,7 @@ func (s *classNoteServiceImpl) UpdateNote(ctx context.Context, id int64, req *dt
 		CourseCode:   req.CourseCode,
 		Title:        req.Title,
 		Description:  req.Description,
-		Content:      req.Content,
+		Content:      markdown.StripHTML(req.Content),
 		DepartmentID: existingNote.DepartmentID, // Keep the same department
 		UserID:       existingNote.UserID,       // Keep the same user
 	}
@@ -358,6 +428,11 @@ func (s *classNoteServiceImpl) UpdateNote(ctx context.Context, id int64, req *dt
 		Interface("noteToUpdate", note).
 		Msg("Calling repository Update method")
 
+	// Render Markdown content to sanitized HTML
+	if note.ContentHTML, note.ContentHTMLVersion, err = renderContentHTML(note.Content); err != nil {
+		return nil, err
+	}
+
 	// Update note in database
 	if err := s.classNoteRepo.Update(ctx, note); err != nil {
 		s.logger.Error().Err(err).
@@ -400,7 +475,7 @@ func (s *classNoteServiceImpl) UpdateNote(ctx context.Context, id int64, req *dt
 		CourseCode:   updatedNote.CourseCode,
 		Title:        updatedNote.Title,
 		Description:  updatedNote.Description,
-		Content:      updatedNote.Content,
+		Content:      contentSource(updatedNote.Content, updatedNote.ContentHTMLVersion),
 		DepartmentID: updatedNote.DepartmentID,
 		UserID:       updatedNote.UserID,
 		CreatedAt:    updatedNote.CreatedAt,
@@ -463,32 +538,40 @@ func (s *classNoteServiceImpl) DeleteNote(ctx context.Context, id int64) error {
 }
 
 // AddFileToNote adds a file to an existing class note
-func (s *classNoteServiceImpl) AddFileToNote(ctx context.Context, noteID int64, file *multipart.FileHeader) error {
+func (s *classNoteServiceImpl) AddFileToNote(ctx context.Context, noteID int64, file *multipart.FileHeader) ([]dto.DuplicateWarning, error) {
 	// Get existing note
 	existingNote, err := s.classNoteRepo.GetByID(ctx, noteID)
 	if err != nil {
-		return fmt.Errorf("error getting class note: %w", err)
+		return nil, fmt.Errorf("error getting class note: %w", err)
 	}
 	if existingNote == nil {
-		return apperrors.ErrClassNoteNotFound
+		return nil, apperrors.ErrClassNoteNotFound
 	}
 
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(int64)
 	if !ok {
-		return fmt.Errorf("user ID not found in context")
+		return nil, fmt.Errorf("user ID not found in context")
 	}
 
 	// Check if user has permission to update
 	// Only the creator can update the note
 	if existingNote.UserID != userID {
-		return fmt.Errorf("unauthorized: only the creator can update this note")
+		return nil, fmt.Errorf("unauthorized: only the creator can update this note")
+	}
+
+	contentTypes, err := checkUploads(ctx, s.fileRepo, models.FileTypeClassNote, noteID, []*multipart.FileHeader{file})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.quotaService.CheckUserQuota(ctx, userID, file.Size); err != nil {
+		return nil, err
 	}
 
 	// Save file
 	fileURL, err := s.fileStorage.SaveFileWithPath(file, "class_notes")
 	if err != nil {
-		return fmt.Errorf("failed to save file: %w", err)
+		return nil, fmt.Errorf("failed to save file: %w", err)
 	}
 
 	// Extract relative path from URL
@@ -501,7 +584,7 @@ func (s *classNoteServiceImpl) AddFileToNote(ctx context.Context, noteID int64,
 		FilePath:     relativeFilePath,
 		FileURL:      fileURL,
 		FileSize:     file.Size,
-		FileType:     file.Header.Get("Content-Type"),
+		FileType:     contentTypes[0],
 		ResourceType: "CLASS_NOTE",
 		ResourceID:   noteID,
 		UploadedBy:   userID,
@@ -512,7 +595,7 @@ func (s *classNoteServiceImpl) AddFileToNote(ctx context.Context, noteID int64,
 	if err != nil {
 		// If DB save fails, delete the physical file
 		_ = s.fileStorage.DeleteFile(fileRecord.FilePath)
-		return fmt.Errorf("failed to save file record: %w", err)
+		return nil, fmt.Errorf("failed to save file record: %w", err)
 	}
 
 	// Add file to class note
@@ -521,10 +604,12 @@ func (s *classNoteServiceImpl) AddFileToNote(ctx context.Context, noteID int64,
 		// If relation fails, delete file and file record
 		_ = s.fileStorage.DeleteFile(fileRecord.FilePath)
 		_ = s.fileRepo.Delete(ctx, fileID)
-		return fmt.Errorf("failed to add file to class note: %w", err)
+		return nil, fmt.Errorf("failed to add file to class note: %w", err)
 	}
+	fileRecord.ID = fileID
+	s.scanService.Enqueue(ctx, fileID)
 
-	return nil
+	return s.duplicateService.CheckFile(ctx, fileRecord, file), nil
 }
 
 // AddFilesToNote adds multiple files to an existing class note
@@ -566,10 +651,20 @@ func (s *classNoteServiceImpl) AddFilesToNote(ctx context.Context, noteID int64,
 		return nil, err
 	}
 
+	// Reject the whole batch if any file breaks the upload policy
+	if _, err := checkUploads(ctx, s.fileRepo, models.FileTypeClassNote, noteID, files); err != nil {
+		return nil, err
+	}
+	if err := s.quotaService.CheckUserQuota(ctx, userID, uploadSize(files)); err != nil {
+		return nil, err
+	}
+
 	// Upload files one by one
 	fileUploadErrors := []error{}
+	var duplicateWarnings []dto.DuplicateWarning
 	for _, file := range files {
-		err := s.AddFileToNote(ctx, noteID, file)
+		warnings, err := s.AddFileToNote(ctx, noteID, file)
+		duplicateWarnings = append(duplicateWarnings, warnings...)
 		if err != nil {
 			s.logger.Error().Err(err).
 				Str("fileName", file.Filename).
@@ -608,16 +703,17 @@ func (s *classNoteServiceImpl) AddFilesToNote(ctx context.Context, noteID int64,
 
 	// Return the updated note
 	return &dto.ClassNoteResponse{
-		ID:           updatedNote.ID,
-		CourseCode:   updatedNote.CourseCode,
-		Title:        updatedNote.Title,
-		Description:  updatedNote.Description,
-		Content:      updatedNote.Content,
-		DepartmentID: updatedNote.DepartmentID,
-		UserID:       updatedNote.UserID,
-		CreatedAt:    updatedNote.CreatedAt,
-		UpdatedAt:    updatedNote.UpdatedAt,
-		Files:        fileResponses,
+		ID:                updatedNote.ID,
+		CourseCode:        updatedNote.CourseCode,
+		Title:             updatedNote.Title,
+		Description:       updatedNote.Description,
+		Content:           contentSource(updatedNote.Content, updatedNote.ContentHTMLVersion),
+		DepartmentID:      updatedNote.DepartmentID,
+		UserID:            updatedNote.UserID,
+		CreatedAt:         updatedNote.CreatedAt,
+		UpdatedAt:         updatedNote.UpdatedAt,
+		Files:             fileResponses,
+		DuplicateWarnings: duplicateWarnings,
 	}, nil
 }
 
@@ -694,3 +790,25 @@ func (s *classNoteServiceImpl) DeleteFileFromNote(ctx context.Context, noteID in
 	// This method is an alias for RemoveFileFromNote to maintain backward compatibility
 	return s.RemoveFileFromNote(ctx, noteID, fileID)
 }
+
+// GetNoteBundle lists the files of a class note for download as a single ZIP archive
+func (s *classNoteServiceImpl) GetNoteBundle(ctx context.Context, id int64) (*dto.FileBundle, error) {
+	note, err := s.classNoteRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting class note: %w", err)
+	}
+	if note == nil {
+		return nil, apperrors.ErrClassNoteNotFound
+	}
+	if len(note.Files) == 0 {
+		return nil, apperrors.NewResourceNotFoundError("Class note has no files")
+	}
+
+	builder := newBundleBuilder()
+	builder.addFiles(builder.folder(note.CourseCode, note.Title), note.Files)
+
+	return &dto.FileBundle{
+		FileName: bundleFileName(note.CourseCode, note.Title),
+		Entries:  builder.entries,
+	}, nil
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"mime/multipart"
 	"strings"
@@ -13,6 +14,7 @@ import (
 	"github.com/yigit/unisphere/internal/app/repositories"
 	"github.com/yigit/unisphere/internal/pkg/apperrors"
 	"github.com/yigit/unisphere/internal/pkg/filestorage"
+	"github.com/yigit/unisphere/internal/pkg/helpers"
 )
 
 // CommunityService defines the interface for community operations
@@ -39,7 +41,9 @@ type communityServiceImpl struct {
 	communityParticipantRepo *repositories.CommunityParticipantRepository
 	userRepo                 *repositories.UserRepository
 	fileRepo                 *repositories.FileRepository
-	fileStorage              *filestorage.LocalStorage
+	fileStorage              filestorage.FileStorage
+	quotaService             QuotaService
+	scanService              ScanService
 	authzService             *auth.AuthorizationService
 	logger                   zerolog.Logger
 }
@@ -50,7 +54,9 @@ func NewCommunityService(
 	communityParticipantRepo *repositories.CommunityParticipantRepository,
 	userRepo *repositories.UserRepository,
 	fileRepo *repositories.FileRepository,
-	fileStorage *filestorage.LocalStorage,
+	fileStorage filestorage.FileStorage,
+	quotaService QuotaService,
+	scanService ScanService,
 	authzService *auth.AuthorizationService,
 	logger zerolog.Logger,
 ) CommunityService {
@@ -60,6 +66,8 @@ func NewCommunityService(
 		userRepo:                 userRepo,
 		fileRepo:                 fileRepo,
 		fileStorage:              fileStorage,
+		quotaService:             quotaService,
+		scanService:              scanService,
 		authzService:             authzService,
 		logger:                   logger,
 	}
@@ -71,8 +79,16 @@ func (s *communityServiceImpl) GetAllCommunities(ctx context.Context, filter *dt
 		Interface("filter", filter).
 		Msg("Getting all communities")
 
+	cursor, err := helpers.ParseCursor(filter.After, filter.Before)
+	if err != nil {
+		return nil, apperrors.NewBadRequestError(err.Error())
+	}
+
 	// Try to get communities from repository
-	communities, total, err := s.communityRepo.GetAll(ctx, filter.LeadID, filter.Search, filter.Page, filter.PageSize)
+	communities, total, hasMore, err := s.communityRepo.GetAll(ctx, filter.LeadID, filter.Search, filter.Page, filter.PageSize, cursor)
+	if errors.Is(err, apperrors.ErrBadRequest) {
+		return nil, err
+	}
 	if err != nil {
 		// Log the error but return an empty list instead of failing
 		s.logger.Error().Err(err).
@@ -107,15 +123,16 @@ func (s *communityServiceImpl) GetAllCommunities(ctx context.Context, filter *dt
 		}
 
 		communityResponses = append(communityResponses, dto.CommunityResponse{
-			ID:                 community.ID,
-			Name:               community.Name,
-			Abbreviation:       community.Abbreviation,
-			LeadID:             community.LeadID,
-			ProfilePhotoFileID: community.ProfilePhotoFileID,
-			ProfilePhotoURL:    profilePhotoURL,
-			ParticipantCount:   participantCount,
-			CreatedAt:          community.CreatedAt,
-			UpdatedAt:          community.UpdatedAt,
+			ID:                     community.ID,
+			Name:                   community.Name,
+			Abbreviation:           community.Abbreviation,
+			LeadID:                 community.LeadID,
+			ProfilePhotoFileID:     community.ProfilePhotoFileID,
+			ProfilePhotoURL:        profilePhotoURL,
+			ProfilePhotoThumbnails: dto.FileThumbnails(community.ProfilePhoto),
+			ParticipantCount:       participantCount,
+			CreatedAt:              community.CreatedAt,
+			UpdatedAt:              community.UpdatedAt,
 		})
 	}
 
@@ -132,9 +149,14 @@ func (s *communityServiceImpl) GetAllCommunities(ctx context.Context, filter *dt
 		TotalPages:  totalPages,
 	}
 
+	cursorInfo := helpers.NewCursorInfo(repositories.CommunitySort, len(communities), func(i int) (interface{}, int64) {
+		return communities[i].ID, communities[i].ID
+	}, cursor, hasMore, filter.Page, filter.PageSize, total)
+
 	return &dto.CommunityListResponse{
 		Communities:    communityResponses,
 		PaginationInfo: paginationInfo,
+		Cursor:         cursorInfo,
 	}, nil
 }
 
@@ -189,15 +211,16 @@ func (s *communityServiceImpl) GetCommunityByID(ctx context.Context, id int64) (
 
 	// Create the base community response
 	communityResponse := dto.CommunityResponse{
-		ID:                 community.ID,
-		Name:               community.Name,
-		Abbreviation:       community.Abbreviation,
-		LeadID:             community.LeadID,
-		ProfilePhotoFileID: community.ProfilePhotoFileID,
-		ProfilePhotoURL:    profilePhotoURL,
-		ParticipantCount:   participantCount,
-		CreatedAt:          community.CreatedAt,
-		UpdatedAt:          community.UpdatedAt,
+		ID:                     community.ID,
+		Name:                   community.Name,
+		Abbreviation:           community.Abbreviation,
+		LeadID:                 community.LeadID,
+		ProfilePhotoFileID:     community.ProfilePhotoFileID,
+		ProfilePhotoURL:        profilePhotoURL,
+		ProfilePhotoThumbnails: dto.FileThumbnails(community.ProfilePhoto),
+		ParticipantCount:       participantCount,
+		CreatedAt:              community.CreatedAt,
+		UpdatedAt:              community.UpdatedAt,
 	}
 
 	// Return detailed response with participants
@@ -236,6 +259,16 @@ func (s *communityServiceImpl) CreateCommunity(ctx context.Context, req *dto.Cre
 		return nil, apperrors.NewResourceNotFoundError("Lead user not found")
 	}
 
+	// Reject an invalid profile photo before the community is created
+	if profilePhoto != nil {
+		if _, err := checkUpload(models.FileTypeCommunityProfilePhoto, profilePhoto); err != nil {
+			return nil, err
+		}
+		if err := s.quotaService.CheckUserQuota(ctx, userID, profilePhoto.Size); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create community model
 	community := &models.Community{
 		Name:               req.Name,
@@ -268,6 +301,7 @@ func (s *communityServiceImpl) CreateCommunity(ctx context.Context, req *dto.Cre
 	// Process profile photo if provided
 	var profilePhotoFileID *int64
 	var profilePhotoURL *string
+	var profilePhotoThumbnails map[string]string
 
 	if profilePhoto != nil {
 		s.logger.Info().
@@ -292,11 +326,12 @@ func (s *communityServiceImpl) CreateCommunity(ctx context.Context, req *dto.Cre
 					Msg("Failed to update community profile photo ID")
 
 				// Clean up - delete the file if we couldn't update the community
-				_ = s.fileStorage.DeleteFile(file.FilePath)
+				deleteStoredFile(s.fileStorage, file)
 				_ = s.fileRepo.Delete(ctx, file.ID)
 			} else {
 				profilePhotoFileID = &file.ID
 				profilePhotoURL = &file.FileURL
+				profilePhotoThumbnails = dto.FileThumbnails(file)
 			}
 		}
 	}
@@ -305,18 +340,55 @@ func (s *communityServiceImpl) CreateCommunity(ctx context.Context, req *dto.Cre
 	participantCount, _ := s.communityParticipantRepo.GetParticipantCountByCommunityID(ctx, communityID)
 
 	return &dto.CommunityResponse{
-		ID:                 community.ID,
-		Name:               community.Name,
-		Abbreviation:       community.Abbreviation,
-		LeadID:             community.LeadID,
-		ProfilePhotoFileID: profilePhotoFileID,
-		ProfilePhotoURL:    profilePhotoURL,
-		ParticipantCount:   participantCount,
-		CreatedAt:          community.CreatedAt,
-		UpdatedAt:          community.UpdatedAt,
+		ID:                     community.ID,
+		Name:                   community.Name,
+		Abbreviation:           community.Abbreviation,
+		LeadID:                 community.LeadID,
+		ProfilePhotoFileID:     profilePhotoFileID,
+		ProfilePhotoURL:        profilePhotoURL,
+		ProfilePhotoThumbnails: profilePhotoThumbnails,
+		ParticipantCount:       participantCount,
+		CreatedAt:              community.CreatedAt,
+		UpdatedAt:              community.UpdatedAt,
 	}, nil
 }
 
+// checkCommunityModeration checks that a user may change a community: its lead, or a user
+// with community:moderate on it
+func (s *communityServiceImpl) checkCommunityModeration(ctx context.Context, community *models.Community, userID int64, message string) error {
+	if community.LeadID == userID {
+		return nil
+	}
+	if err := s.authzService.RequirePermission(ctx, userID, auth.PermissionCommunityModerate,
+		&auth.Scope{Type: models.ScopeCommunity, ID: community.ID}, message); err != nil {
+		s.logger.Error().
+			Int64("userID", userID).
+			Int64("leadID", community.LeadID).
+			Msg("User is neither the lead nor a moderator of the community")
+		return err
+	}
+	return nil
+}
+
+// checkCommunityParticipation checks that a user takes part in a community: its lead or a participant
+func (s *communityServiceImpl) checkCommunityParticipation(ctx context.Context, community *models.Community, userID int64, message string) error {
+	if community.LeadID == userID {
+		return nil
+	}
+	isParticipant, err := s.communityParticipantRepo.IsUserParticipant(ctx, community.ID, userID)
+	if err != nil {
+		s.logger.Error().Err(err).
+			Int64("communityID", community.ID).
+			Int64("userID", userID).
+			Msg("Failed to check if user is a participant")
+		return fmt.Errorf("error checking participant status: %w", err)
+	}
+	if !isParticipant {
+		return apperrors.NewForbiddenError(message)
+	}
+	return nil
+}
+
 // UpdateCommunity updates an existing community
 func (s *communityServiceImpl) UpdateCommunity(ctx context.Context, id int64, req *dto.UpdateCommunityRequest) (*dto.CommunityResponse, error) {
 	s.logger.Debug().
@@ -343,13 +415,9 @@ func (s *communityServiceImpl) UpdateCommunity(ctx context.Context, id int64, re
 		return nil, fmt.Errorf("user ID not found in context")
 	}
 
-	// Check if the user is the lead of the community
-	if existingCommunity.LeadID != userID {
-		s.logger.Error().
-			Int64("userID", userID).
-			Int64("leadID", existingCommunity.LeadID).
-			Msg("User is not the lead of the community")
-		return nil, apperrors.NewForbiddenError("Only the community lead can update the community")
+	// Check if the user is the lead of the community or may moderate it
+	if err := s.checkCommunityModeration(ctx, existingCommunity, userID, "Only the community lead or a moderator can update the community"); err != nil {
+		return nil, err
 	}
 
 	// Check if new lead exists
@@ -397,14 +465,15 @@ func (s *communityServiceImpl) UpdateCommunity(ctx context.Context, id int64, re
 	}
 
 	return &dto.CommunityResponse{
-		ID:                 updatedCommunityFull.ID,
-		Name:               updatedCommunityFull.Name,
-		Abbreviation:       updatedCommunityFull.Abbreviation,
-		LeadID:             updatedCommunityFull.LeadID,
-		ProfilePhotoFileID: updatedCommunityFull.ProfilePhotoFileID,
-		ProfilePhotoURL:    profilePhotoURL,
-		CreatedAt:          updatedCommunityFull.CreatedAt,
-		UpdatedAt:          updatedCommunityFull.UpdatedAt,
+		ID:                     updatedCommunityFull.ID,
+		Name:                   updatedCommunityFull.Name,
+		Abbreviation:           updatedCommunityFull.Abbreviation,
+		LeadID:                 updatedCommunityFull.LeadID,
+		ProfilePhotoFileID:     updatedCommunityFull.ProfilePhotoFileID,
+		ProfilePhotoURL:        profilePhotoURL,
+		ProfilePhotoThumbnails: dto.FileThumbnails(updatedCommunityFull.ProfilePhoto),
+		CreatedAt:              updatedCommunityFull.CreatedAt,
+		UpdatedAt:              updatedCommunityFull.UpdatedAt,
 	}, nil
 }
 
@@ -433,13 +502,9 @@ func (s *communityServiceImpl) DeleteCommunity(ctx context.Context, id int64) er
 		return fmt.Errorf("user ID not found in context")
 	}
 
-	// Check if the user is the lead of the community
-	if existingCommunity.LeadID != userID {
-		s.logger.Error().
-			Int64("userID", userID).
-			Int64("leadID", existingCommunity.LeadID).
-			Msg("User is not the lead of the community")
-		return apperrors.NewForbiddenError("Only the community lead can delete the community")
+	// Check if the user is the lead of the community or may moderate it
+	if err := s.checkCommunityModeration(ctx, existingCommunity, userID, "Only the community lead or a moderator can delete the community"); err != nil {
+		return err
 	}
 
 	// Delete all associated files
@@ -500,7 +565,14 @@ func (s *communityServiceImpl) AddFileToCommunity(ctx context.Context, community
 		return fmt.Errorf("user ID not found in context")
 	}
 
-	// Check permissions if needed
+	// Only participants share files with a community
+	if err := s.checkCommunityParticipation(ctx, existingCommunity, userID, "Only participants can add files to the community"); err != nil {
+		return err
+	}
+
+	if err := s.quotaService.CheckUserQuota(ctx, userID, file.Size); err != nil {
+		return err
+	}
 
 	// Upload file
 	_, err = s.uploadFile(ctx, file, models.FileTypeCommunity, communityID, userID)
@@ -538,15 +610,12 @@ func (s *communityServiceImpl) RemoveFileFromCommunity(ctx context.Context, comm
 	}
 
 	// Get user ID from context
-	_, ok := ctx.Value("userID").(int64)
+	userID, ok := ctx.Value("userID").(int64)
 	if !ok {
 		s.logger.Error().Msg("User ID not found in context")
 		return fmt.Errorf("user ID not found in context")
 	}
 
-	// Check permissions if needed
-	// For now, allowing any authenticated user to remove files
-
 	// Get file
 	file, err := s.fileRepo.GetByID(ctx, fileID)
 	if err != nil {
@@ -555,17 +624,17 @@ func (s *communityServiceImpl) RemoveFileFromCommunity(ctx context.Context, comm
 			Msg("Failed to get file")
 		return fmt.Errorf("error getting file: %w", err)
 	}
-	if file == nil {
+	// Files of other resources, including the community's profile photo, are not removed here
+	if file == nil || file.ResourceType != models.FileTypeCommunity || file.ResourceID != communityID {
 		return apperrors.NewResourceNotFoundError("File not found")
 	}
 
-	// We'll skip the check if the file belongs to the community
-	// as this might be causing issues when the Files array isn't fully populated
-	// This is a temporary fix - in a production app, you'd want to properly validate
-	// the file belongs to the community before deletion
-
-	// We no longer need to remove the file from the community_files table
-	// as we're not using that table anymore. Files are tracked directly in the files table.
+	// Uploaders remove their own files; anyone else has to moderate the community
+	if file.UploadedBy != userID {
+		if err := s.checkCommunityModeration(ctx, existingCommunity, userID, "Only the uploader, the community lead or a moderator can remove the file"); err != nil {
+			return err
+		}
+	}
 
 	// Delete file record
 	err = s.fileRepo.Delete(ctx, fileID)
@@ -614,13 +683,17 @@ func (s *communityServiceImpl) UpdateProfilePhoto(ctx context.Context, community
 		return nil, fmt.Errorf("user ID not found in context")
 	}
 
-	// Check if the user is the lead of the community
-	if existingCommunity.LeadID != userID {
-		s.logger.Error().
-			Int64("userID", userID).
-			Int64("leadID", existingCommunity.LeadID).
-			Msg("User is not the lead of the community")
-		return nil, apperrors.NewForbiddenError("Only the community lead can update the profile photo")
+	// Check if the user is the lead of the community or may moderate it
+	if err := s.checkCommunityModeration(ctx, existingCommunity, userID, "Only the community lead or a moderator can update the profile photo"); err != nil {
+		return nil, err
+	}
+
+	// Keep the old photo if the new one breaks the upload policy
+	if _, err := checkUpload(models.FileTypeCommunityProfilePhoto, fileHeader); err != nil {
+		return nil, err
+	}
+	if err := s.quotaService.CheckUserQuota(ctx, userID, fileHeader.Size); err != nil {
+		return nil, err
 	}
 
 	// Delete old profile photo if exists
@@ -635,6 +708,9 @@ func (s *communityServiceImpl) UpdateProfilePhoto(ctx context.Context, community
 					Str("filePath", existingCommunity.ProfilePhoto.FilePath).
 					Msg("Failed to delete old profile photo file")
 			}
+			for _, size := range existingCommunity.ProfilePhoto.ThumbnailSizes {
+				_ = s.fileStorage.DeleteFile(existingCommunity.ProfilePhoto.ThumbnailPath(size))
+			}
 		}
 
 		// Delete file record
@@ -665,7 +741,7 @@ func (s *communityServiceImpl) UpdateProfilePhoto(ctx context.Context, community
 			Msg("Failed to update community profile photo ID")
 
 		// Clean up - delete the file if we couldn't update the community
-		_ = s.fileStorage.DeleteFile(file.FilePath)
+		deleteStoredFile(s.fileStorage, file)
 		_ = s.fileRepo.Delete(ctx, file.ID)
 
 		return nil, fmt.Errorf("failed to update community profile photo: %w", err)
@@ -693,15 +769,16 @@ func (s *communityServiceImpl) UpdateProfilePhoto(ctx context.Context, community
 	}
 
 	return &dto.CommunityResponse{
-		ID:                 updatedCommunity.ID,
-		Name:               updatedCommunity.Name,
-		Abbreviation:       updatedCommunity.Abbreviation,
-		LeadID:             updatedCommunity.LeadID,
-		ProfilePhotoFileID: updatedCommunity.ProfilePhotoFileID,
-		ProfilePhotoURL:    profilePhotoURL,
-		ParticipantCount:   participantCount,
-		CreatedAt:          updatedCommunity.CreatedAt,
-		UpdatedAt:          updatedCommunity.UpdatedAt,
+		ID:                     updatedCommunity.ID,
+		Name:                   updatedCommunity.Name,
+		Abbreviation:           updatedCommunity.Abbreviation,
+		LeadID:                 updatedCommunity.LeadID,
+		ProfilePhotoFileID:     updatedCommunity.ProfilePhotoFileID,
+		ProfilePhotoURL:        profilePhotoURL,
+		ProfilePhotoThumbnails: dto.FileThumbnails(updatedCommunity.ProfilePhoto),
+		ParticipantCount:       participantCount,
+		CreatedAt:              updatedCommunity.CreatedAt,
+		UpdatedAt:              updatedCommunity.UpdatedAt,
 	}, nil
 }
 
@@ -735,13 +812,9 @@ func (s *communityServiceImpl) DeleteProfilePhoto(ctx context.Context, community
 		return fmt.Errorf("user ID not found in context")
 	}
 
-	// Check if the user is the lead of the community
-	if existingCommunity.LeadID != userID {
-		s.logger.Error().
-			Int64("userID", userID).
-			Int64("leadID", existingCommunity.LeadID).
-			Msg("User is not the lead of the community")
-		return apperrors.NewForbiddenError("Only the community lead can delete the profile photo")
+	// Check if the user is the lead of the community or may moderate it
+	if err := s.checkCommunityModeration(ctx, existingCommunity, userID, "Only the community lead or a moderator can delete the profile photo"); err != nil {
+		return err
 	}
 
 	// Delete physical file if exists
@@ -753,6 +826,9 @@ func (s *communityServiceImpl) DeleteProfilePhoto(ctx context.Context, community
 				Str("filePath", existingCommunity.ProfilePhoto.FilePath).
 				Msg("Failed to delete profile photo file")
 		}
+		for _, size := range existingCommunity.ProfilePhoto.ThumbnailSizes {
+			_ = s.fileStorage.DeleteFile(existingCommunity.ProfilePhoto.ThumbnailPath(size))
+		}
 	}
 
 	// Delete file record
@@ -1005,15 +1081,16 @@ func (s *communityServiceImpl) GetMyCommunities(ctx context.Context, userID int6
 		}
 
 		communityResponses[i] = dto.CommunityResponse{
-			ID:                 community.ID,
-			Name:               community.Name,
-			Abbreviation:       community.Abbreviation,
-			LeadID:             community.LeadID,
-			ProfilePhotoFileID: community.ProfilePhotoFileID,
-			ProfilePhotoURL:    profilePhotoURL,
-			ParticipantCount:   count,
-			CreatedAt:          community.CreatedAt,
-			UpdatedAt:          community.UpdatedAt,
+			ID:                     community.ID,
+			Name:                   community.Name,
+			Abbreviation:           community.Abbreviation,
+			LeadID:                 community.LeadID,
+			ProfilePhotoFileID:     community.ProfilePhotoFileID,
+			ProfilePhotoURL:        profilePhotoURL,
+			ProfilePhotoThumbnails: dto.FileThumbnails(community.ProfilePhoto),
+			ParticipantCount:       count,
+			CreatedAt:              community.CreatedAt,
+			UpdatedAt:              community.UpdatedAt,
 		}
 	}
 
@@ -1022,6 +1099,12 @@ func (s *communityServiceImpl) GetMyCommunities(ctx context.Context, userID int6
 
 // Helper method to upload a file
 func (s *communityServiceImpl) uploadFile(ctx context.Context, fileHeader *multipart.FileHeader, resourceType models.FileType, resourceID int64, userID int64) (*models.File, error) {
+	// Validate against the upload policy and use the detected MIME type
+	contentType, err := checkUpload(resourceType, fileHeader)
+	if err != nil {
+		return nil, err
+	}
+
 	// Open the file
 	src, err := fileHeader.Open()
 	if err != nil {
@@ -1032,34 +1115,37 @@ func (s *communityServiceImpl) uploadFile(ctx context.Context, fileHeader *multi
 	// Generate a storage path based on resource type and ID
 	subPath := fmt.Sprintf("%s_%d", resourceType, resourceID)
 
-	// Upload to storage with the original FileHeader
-	fileURL, err := s.fileStorage.SaveFileWithPath(fileHeader, subPath)
+	// Upload to storage; images are normalized and get thumbnails
+	stored, err := storeUpload(s.fileStorage, resourceType, fileHeader, subPath, contentType)
 	if err != nil {
 		return nil, fmt.Errorf("error uploading file: %w", err)
 	}
 
 	// Extract relative path from URL
-	relativeFilePath := strings.TrimPrefix(fileURL, s.fileStorage.GetBaseURL())
+	relativeFilePath := strings.TrimPrefix(stored.FileURL, s.fileStorage.GetBaseURL())
 	relativeFilePath = strings.TrimPrefix(relativeFilePath, "/uploads/")
 
 	// Create file model
 	file := &models.File{
-		FileName:     fileHeader.Filename,
-		FilePath:     relativeFilePath,
-		FileURL:      fileURL,
-		FileSize:     fileHeader.Size,
-		FileType:     fileHeader.Header.Get("Content-Type"),
-		ResourceType: resourceType,
-		ResourceID:   resourceID,
-		UploadedBy:   userID,
+		FileName:       stored.FileName,
+		FilePath:       relativeFilePath,
+		FileURL:        stored.FileURL,
+		FileSize:       stored.FileSize,
+		FileType:       stored.FileType,
+		ResourceType:   resourceType,
+		ResourceID:     resourceID,
+		UploadedBy:     userID,
+		ThumbnailSizes: stored.ThumbnailSizes,
 	}
 
 	// Save file metadata to database
 	fileID, err := s.fileRepo.Create(ctx, file)
 	if err != nil {
+		deleteStoredFile(s.fileStorage, file)
 		return nil, fmt.Errorf("error saving file metadata: %w", err)
 	}
 	file.ID = fileID
+	s.scanService.Enqueue(ctx, fileID)
 
 	// We're no longer tracking community files in a separate table.
 	// Files are now stored directly in the files table with resource_type='COMMUNITY'
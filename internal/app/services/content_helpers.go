@@ -0,0 +1,41 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/markdown"
+)
+
+// renderContentHTML renders Markdown content to sanitized HTML for storage alongside the source,
+// together with the policy version it was rendered with
+func renderContentHTML(content string) (*string, *int, error) {
+	html, err := markdown.Render(content)
+	if err != nil {
+		return nil, nil, apperrors.NewBadRequestError(fmt.Sprintf("Invalid Markdown content: %v", err))
+	}
+	version := markdown.PolicyVersion
+	return &html, &version, nil
+}
+
+// contentHTML returns the HTML of content when include is set. The stored HTML is used if it was
+// rendered with the current policy; content written before Markdown support or rendered with an
+// older policy is rendered on the fly.
+func contentHTML(include bool, content string, stored *string, version *int) string {
+	if !include {
+		return ""
+	}
+	if stored != nil && version != nil && *version == markdown.PolicyVersion {
+		return *stored
+	}
+	return markdown.RenderOrEmpty(content)
+}
+
+// contentSource returns the Markdown source of content for responses. Content is stripped of raw
+// HTML on write; rows written under an older policy may still hold it, so they are stripped on read.
+func contentSource(content string, version *int) string {
+	if version != nil && *version == markdown.PolicyVersion {
+		return content
+	}
+	return markdown.StripHTML(content)
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/fingerprint"
+	"github.com/yigit/unisphere/internal/pkg/helpers"
+)
+
+const (
+	// nearDuplicateThreshold is the minimum estimated Jaccard similarity for a near-duplicate
+	nearDuplicateThreshold = 0.8
+	// maxTextExtractBytes caps how much of a file is buffered for text extraction
+	maxTextExtractBytes = 20 << 20
+	// maxLSHBucketSize is the most files sharing a band hash that the report pairs up. Larger
+	// buckets come from boilerplate text; their files are still paired through other bands.
+	maxLSHBucketSize = 50
+)
+
+// duplicateCheckedTypes are the resource types whose files are checked for duplicates
+var duplicateCheckedTypes = []models.FileType{models.FileTypePastExam, models.FileTypeClassNote}
+
+// DuplicateService detects exact and near-duplicate uploads of notes and exam files
+type DuplicateService interface {
+	CheckFile(ctx context.Context, file *models.File, fileHeader *multipart.FileHeader) []dto.DuplicateWarning
+	GetDuplicateReport(ctx context.Context, req *dto.DuplicateReportRequest) (*dto.DuplicateReportResponse, error)
+}
+
+// duplicateServiceImpl implements DuplicateService
+type duplicateServiceImpl struct {
+	fileRepo        *repositories.FileRepository
+	fingerprintRepo *repositories.FileFingerprintRepository
+	logger          zerolog.Logger
+}
+
+// NewDuplicateService creates a new DuplicateService
+func NewDuplicateService(
+	fileRepo *repositories.FileRepository,
+	fingerprintRepo *repositories.FileFingerprintRepository,
+	logger zerolog.Logger,
+) DuplicateService {
+	return &duplicateServiceImpl{
+		fileRepo:        fileRepo,
+		fingerprintRepo: fingerprintRepo,
+		logger:          logger,
+	}
+}
+
+// CheckFile fingerprints a freshly stored file and returns warnings for any existing duplicates.
+// Failures are logged and never block the upload.
+func (s *duplicateServiceImpl) CheckFile(ctx context.Context, file *models.File, fileHeader *multipart.FileHeader) []dto.DuplicateWarning {
+	if file == nil || fileHeader == nil {
+		return nil
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("fileID", file.ID).Msg("Failed to open file for duplicate check")
+		return nil
+	}
+	defer src.Close()
+
+	// Hash the whole stream while keeping the head of it for text extraction
+	hasher := sha256.New()
+	var head bytes.Buffer
+	if _, err := io.Copy(io.MultiWriter(hasher, &limitedWriter{w: &head, n: maxTextExtractBytes}), src); err != nil {
+		s.logger.Warn().Err(err).Int64("fileID", file.ID).Msg("Failed to read file for duplicate check")
+		return nil
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	if err := s.fileRepo.SetContentHash(ctx, file.ID, hash); err != nil {
+		s.logger.Warn().Err(err).Int64("fileID", file.ID).Msg("Failed to store file content hash")
+	}
+	file.ContentHash = &hash
+
+	var warnings []dto.DuplicateWarning
+	seen := map[int64]bool{}
+
+	// Exact duplicates
+	exact, err := s.fileRepo.FindByContentHash(ctx, hash, duplicateCheckedTypes, file.ID)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("fileID", file.ID).Msg("Failed to look up exact duplicates")
+	}
+	for _, existing := range exact {
+		seen[existing.ID] = true
+		warnings = append(warnings, newDuplicateWarning(file, existing, dto.DuplicateMatchExact, 1))
+	}
+
+	// Near duplicates (only when the whole file was available for text extraction)
+	if int64(head.Len()) < fileHeader.Size {
+		return warnings
+	}
+	signature := fingerprint.Compute(fingerprint.ExtractText(head.Bytes(), file.FileType))
+	if signature == nil {
+		return warnings
+	}
+	bands := signature.BandHashes()
+
+	candidates, err := s.fingerprintRepo.FindCandidates(ctx, bands, file.ID)
+	if err != nil {
+		s.logger.Warn().Err(err).Int64("fileID", file.ID).Msg("Failed to look up near-duplicate candidates")
+	}
+
+	var nearIDs []int64
+	similarities := map[int64]float64{}
+	for _, c := range candidates {
+		if seen[c.FileID] {
+			continue
+		}
+		if sim := fingerprint.Similarity(signature, fingerprint.FromInt64s(c.Signature)); sim >= nearDuplicateThreshold {
+			nearIDs = append(nearIDs, c.FileID)
+			similarities[c.FileID] = sim
+		}
+	}
+
+	if len(nearIDs) > 0 {
+		nearFiles, err := s.fileRepo.GetByIDs(ctx, nearIDs)
+		if err != nil {
+			s.logger.Warn().Err(err).Int64("fileID", file.ID).Msg("Failed to load near-duplicate files")
+		}
+		for _, existing := range nearFiles {
+			if !isDuplicateCheckedType(existing.ResourceType) {
+				continue
+			}
+			warnings = append(warnings, newDuplicateWarning(file, existing, dto.DuplicateMatchNear, similarities[existing.ID]))
+		}
+	}
+
+	if err := s.fingerprintRepo.Save(ctx, file.ID, signature.ToInt64s(), bands); err != nil {
+		s.logger.Warn().Err(err).Int64("fileID", file.ID).Msg("Failed to store file fingerprint")
+	}
+
+	if len(warnings) > 0 {
+		s.logger.Info().
+			Int64("fileID", file.ID).
+			Int("duplicates", len(warnings)).
+			Msg("Uploaded file duplicates existing content")
+	}
+
+	return warnings
+}
+
+// GetDuplicateReport groups all known duplicates into clusters for admin review and returns the
+// requested page of them
+func (s *duplicateServiceImpl) GetDuplicateReport(ctx context.Context, req *dto.DuplicateReportRequest) (*dto.DuplicateReportResponse, error) {
+	report := &dto.DuplicateReportResponse{
+		ExactClusters: []dto.DuplicateCluster{},
+		NearClusters:  []dto.DuplicateCluster{},
+		GeneratedAt:   time.Now(),
+	}
+	offset := (req.Page - 1) * req.PageSize
+
+	// Exact clusters come straight from the content hash groups; the files of the whole page
+	// are loaded at once
+	groups, total, err := s.fileRepo.GetContentHashGroups(ctx, duplicateCheckedTypes, req.PageSize, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error getting exact duplicate groups: %w", err)
+	}
+	var groupFileIDs []int64
+	for _, group := range groups {
+		groupFileIDs = append(groupFileIDs, group.FileIDs...)
+	}
+	groupFiles, err := s.fileRepo.GetByIDs(ctx, groupFileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error loading duplicate files: %w", err)
+	}
+	groupFilesByID := make(map[int64]*models.File, len(groupFiles))
+	for _, f := range groupFiles {
+		groupFilesByID[f.ID] = f
+	}
+	for _, group := range groups {
+		var files []*models.File
+		for _, id := range group.FileIDs {
+			if f := groupFilesByID[id]; f != nil {
+				files = append(files, f)
+			}
+		}
+		if len(files) < 2 {
+			continue
+		}
+		report.ExactClusters = append(report.ExactClusters, dto.DuplicateCluster{
+			MatchType:     dto.DuplicateMatchExact,
+			ContentHash:   group.ContentHash,
+			MinSimilarity: 1,
+			Files:         toDuplicateClusterFiles(files),
+		})
+	}
+	report.ExactPagination = helpers.NewPaginationInfo(total, req.Page, req.PageSize)
+
+	// Near clusters: verify LSH candidate pairs, then union connected files
+	pairs, err := s.fingerprintRepo.GetCandidatePairs(ctx, maxLSHBucketSize)
+	if err != nil {
+		return nil, fmt.Errorf("error getting near duplicate candidates: %w", err)
+	}
+
+	var pairIDs []int64
+	for _, p := range pairs {
+		pairIDs = append(pairIDs, p.FileID, p.OtherFileID)
+	}
+	signatures, err := s.fingerprintRepo.GetSignatures(ctx, pairIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error getting fingerprints: %w", err)
+	}
+	candidateFiles, err := s.fileRepo.GetByIDs(ctx, uniqueIDs(pairIDs))
+	if err != nil {
+		return nil, fmt.Errorf("error loading candidate files: %w", err)
+	}
+	filesByID := make(map[int64]*models.File, len(candidateFiles))
+	for _, f := range candidateFiles {
+		filesByID[f.ID] = f
+	}
+	report.NearClusters = nearClusters(pairs, signatures, filesByID)
+
+	// Near clusters are only known once every pair is verified, so they are paged in memory
+	sortClusters(report.NearClusters)
+	report.NearPagination = helpers.NewPaginationInfo(int64(len(report.NearClusters)), req.Page, req.PageSize)
+	switch {
+	case offset >= len(report.NearClusters):
+		report.NearClusters = []dto.DuplicateCluster{}
+	case offset+req.PageSize < len(report.NearClusters):
+		report.NearClusters = report.NearClusters[offset : offset+req.PageSize]
+	default:
+		report.NearClusters = report.NearClusters[offset:]
+	}
+
+	return report, nil
+}
+
+// nearClusters verifies LSH candidate pairs against their signatures and unions the files of the
+// pairs at or above the near-duplicate threshold into clusters
+func nearClusters(pairs []repositories.FingerprintPair, signatures map[int64][]int64, filesByID map[int64]*models.File) []dto.DuplicateCluster {
+	parent := map[int64]int64{}
+	var find func(int64) int64
+	find = func(x int64) int64 {
+		if p, ok := parent[x]; ok && p != x {
+			parent[x] = find(p)
+			return parent[x]
+		}
+		parent[x] = x
+		return x
+	}
+	clusterMin := map[int64]float64{}
+
+	for _, p := range pairs {
+		a, b := filesByID[p.FileID], filesByID[p.OtherFileID]
+		if a == nil || b == nil || !isDuplicateCheckedType(a.ResourceType) || !isDuplicateCheckedType(b.ResourceType) {
+			continue
+		}
+		// Byte-identical files are already reported as exact duplicates
+		if a.ContentHash != nil && b.ContentHash != nil && *a.ContentHash == *b.ContentHash {
+			continue
+		}
+		sim := fingerprint.Similarity(fingerprint.FromInt64s(signatures[a.ID]), fingerprint.FromInt64s(signatures[b.ID]))
+		if sim < nearDuplicateThreshold {
+			continue
+		}
+
+		ra, rb := find(a.ID), find(b.ID)
+		minSim := sim
+		for _, r := range []int64{ra, rb} {
+			if m, ok := clusterMin[r]; ok && m < minSim {
+				minSim = m
+			}
+		}
+		delete(clusterMin, ra)
+		delete(clusterMin, rb)
+		parent[ra] = rb
+		clusterMin[rb] = minSim
+	}
+
+	var clusters []dto.DuplicateCluster
+	members := map[int64][]*models.File{}
+	for id := range parent {
+		root := find(id)
+		members[root] = append(members[root], filesByID[id])
+	}
+	for root, files := range members {
+		if len(files) < 2 {
+			continue
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+		clusters = append(clusters, dto.DuplicateCluster{
+			MatchType:     dto.DuplicateMatchNear,
+			MinSimilarity: clusterMin[root],
+			Files:         toDuplicateClusterFiles(files),
+		})
+	}
+	return clusters
+}
+
+// newDuplicateWarning builds a warning pointing the uploader at the existing resource
+func newDuplicateWarning(file, existing *models.File, matchType string, similarity float64) dto.DuplicateWarning {
+	return dto.DuplicateWarning{
+		FileID:           file.ID,
+		FileName:         file.FileName,
+		MatchType:        matchType,
+		Similarity:       similarity,
+		ExistingFileID:   existing.ID,
+		ExistingFileName: existing.FileName,
+		ResourceType:     string(existing.ResourceType),
+		ResourceID:       existing.ResourceID,
+		ResourceURL:      resourceURL(existing.ResourceType, existing.ResourceID),
+	}
+}
+
+// resourceURL returns the API path of the resource a file is attached to
+func resourceURL(resourceType models.FileType, resourceID int64) string {
+	switch resourceType {
+	case models.FileTypePastExam:
+		return fmt.Sprintf("/api/v1/past-exams/%d", resourceID)
+	case models.FileTypeClassNote:
+		return fmt.Sprintf("/api/v1/class-notes/%d", resourceID)
+	case models.FileTypeCommunity, models.FileTypeCommunityProfilePhoto:
+		return fmt.Sprintf("/api/v1/communities/%d", resourceID)
+	case models.FileTypeProfilePhoto:
+		return fmt.Sprintf("/api/v1/users/%d", resourceID)
+	default:
+		return ""
+	}
+}
+
+func isDuplicateCheckedType(t models.FileType) bool {
+	for _, checked := range duplicateCheckedTypes {
+		if t == checked {
+			return true
+		}
+	}
+	return false
+}
+
+func toDuplicateClusterFiles(files []*models.File) []dto.DuplicateClusterFile {
+	out := make([]dto.DuplicateClusterFile, 0, len(files))
+	for _, f := range files {
+		out = append(out, dto.DuplicateClusterFile{
+			FileID:       f.ID,
+			FileName:     f.FileName,
+			FileSize:     f.FileSize,
+			ResourceType: string(f.ResourceType),
+			ResourceID:   f.ResourceID,
+			ResourceURL:  resourceURL(f.ResourceType, f.ResourceID),
+			UploadedBy:   f.UploadedBy,
+			CreatedAt:    f.CreatedAt,
+		})
+	}
+	return out
+}
+
+// sortClusters orders clusters by their oldest file so the report is stable
+func sortClusters(clusters []dto.DuplicateCluster) {
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Files[0].FileID < clusters[j].Files[0].FileID
+	})
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	var out []int64
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// limitedWriter keeps at most n bytes and silently discards the rest
+type limitedWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n > 0 {
+		chunk := p
+		if int64(len(chunk)) > l.n {
+			chunk = chunk[:l.n]
+		}
+		written, err := l.w.Write(chunk)
+		l.n -= int64(written)
+		if err != nil {
+			return written, err
+		}
+	}
+	return len(p), nil
+}
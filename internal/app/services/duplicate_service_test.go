@@ -0,0 +1,170 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/fingerprint"
+)
+
+// duplicateText returns a 200-word text, with every step-th word changed when step > 0
+func duplicateText(prefix string, step int) string {
+	words := make([]string, 200)
+	for i := range words {
+		words[i] = fmt.Sprintf("%s%d", prefix, i)
+		if step > 0 && i%step == step/2 {
+			words[i] = "changed" + words[i]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// bucketPairs pairs up the files sharing a band hash, as GetCandidatePairs does
+func bucketPairs(signatures map[int64][]int64, maxBucketSize int) []repositories.FingerprintPair {
+	type bucket struct {
+		band int
+		hash int64
+	}
+	buckets := map[bucket][]int64{}
+	for id, signature := range signatures {
+		for band, hash := range fingerprint.FromInt64s(signature).BandHashes() {
+			buckets[bucket{band, hash}] = append(buckets[bucket{band, hash}], id)
+		}
+	}
+
+	seen := map[repositories.FingerprintPair]bool{}
+	var pairs []repositories.FingerprintPair
+	for _, ids := range buckets {
+		if len(ids) < 2 || len(ids) > maxBucketSize {
+			continue
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for i := range ids {
+			for _, other := range ids[i+1:] {
+				pair := repositories.FingerprintPair{FileID: ids[i], OtherFileID: other}
+				if !seen[pair] {
+					seen[pair] = true
+					pairs = append(pairs, pair)
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+func TestNearClusters(t *testing.T) {
+	hash := "same"
+	type file struct {
+		id           int64
+		text         string
+		resourceType models.FileType
+		contentHash  *string
+	}
+
+	tests := []struct {
+		name  string
+		files []file
+		want  [][]int64
+	}{
+		{
+			name: "near duplicates cluster",
+			files: []file{
+				{1, duplicateText("word", 0), models.FileTypeClassNote, nil},
+				{2, duplicateText("word", 100), models.FileTypePastExam, nil},
+				{3, duplicateText("other", 0), models.FileTypeClassNote, nil},
+			},
+			want: [][]int64{{1, 2}},
+		},
+		{
+			name: "clusters are transitive",
+			files: []file{
+				{1, duplicateText("word", 0), models.FileTypeClassNote, nil},
+				{2, duplicateText("word", 100), models.FileTypeClassNote, nil},
+				{3, duplicateText("word", 60), models.FileTypeClassNote, nil},
+				{4, duplicateText("other", 0), models.FileTypeClassNote, nil},
+				{5, duplicateText("other", 100), models.FileTypeClassNote, nil},
+			},
+			want: [][]int64{{1, 2, 3}, {4, 5}},
+		},
+		{
+			name: "byte-identical files are left to the exact report",
+			files: []file{
+				{1, duplicateText("word", 0), models.FileTypeClassNote, &hash},
+				{2, duplicateText("word", 0), models.FileTypeClassNote, &hash},
+			},
+			want: nil,
+		},
+		{
+			name: "unchecked resource types are skipped",
+			files: []file{
+				{1, duplicateText("word", 0), models.FileTypeClassNote, nil},
+				{2, duplicateText("word", 100), models.FileTypeCommunity, nil},
+			},
+			want: nil,
+		},
+		{
+			name: "unrelated files",
+			files: []file{
+				{1, duplicateText("word", 0), models.FileTypeClassNote, nil},
+				{2, duplicateText("other", 0), models.FileTypeClassNote, nil},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signatures := map[int64][]int64{}
+			filesByID := map[int64]*models.File{}
+			for _, f := range tt.files {
+				signatures[f.id] = fingerprint.Compute(f.text).ToInt64s()
+				filesByID[f.id] = &models.File{ID: f.id, ResourceType: f.resourceType, ContentHash: f.contentHash}
+			}
+
+			clusters := nearClusters(bucketPairs(signatures, maxLSHBucketSize), signatures, filesByID)
+			sortClusters(clusters)
+			var got [][]int64
+			for _, cluster := range clusters {
+				var ids []int64
+				for _, f := range cluster.Files {
+					ids = append(ids, f.FileID)
+				}
+				got = append(got, ids)
+				if cluster.MinSimilarity < nearDuplicateThreshold || cluster.MinSimilarity > 1 {
+					t.Errorf("cluster %v has minimum similarity %v", ids, cluster.MinSimilarity)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("clusters = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNearClustersVerifiesCandidates(t *testing.T) {
+	// LSH candidates are only a hint; pairs below the threshold are dropped
+	signatures := map[int64][]int64{
+		1: fingerprint.Compute(duplicateText("word", 0)).ToInt64s(),
+		2: fingerprint.Compute(duplicateText("other", 0)).ToInt64s(),
+		3: fingerprint.Compute(duplicateText("word", 100)).ToInt64s(),
+	}
+	filesByID := map[int64]*models.File{
+		1: {ID: 1, ResourceType: models.FileTypeClassNote},
+		2: {ID: 2, ResourceType: models.FileTypeClassNote},
+		3: {ID: 3, ResourceType: models.FileTypeClassNote},
+	}
+	pairs := []repositories.FingerprintPair{{FileID: 1, OtherFileID: 2}, {FileID: 2, OtherFileID: 3}, {FileID: 1, OtherFileID: 3}}
+
+	clusters := nearClusters(pairs, signatures, filesByID)
+	if len(clusters) != 1 || len(clusters[0].Files) != 2 || clusters[0].Files[0].FileID != 1 || clusters[0].Files[1].FileID != 3 {
+		t.Fatalf("clusters = %+v, want one of files 1 and 3", clusters)
+	}
+	want := fingerprint.Similarity(fingerprint.FromInt64s(signatures[1]), fingerprint.FromInt64s(signatures[3]))
+	if clusters[0].MinSimilarity != want {
+		t.Errorf("MinSimilarity = %v, want %v", clusters[0].MinSimilarity, want)
+	}
+}
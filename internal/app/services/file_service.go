@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/auth"
+)
+
+// FileService authorizes access to stored files and issues signed download URLs
+type FileService interface {
+	// GetFileForUser returns the file if the user may read it
+	GetFileForUser(ctx context.Context, fileID, userID int64) (*models.File, error)
+	// CreateSignedURL issues an expiring download URL for a file the user may read
+	CreateSignedURL(ctx context.Context, fileID, userID int64, ttl time.Duration) (*dto.SignedFileURLResponse, error)
+	// GetSignedFile returns the file named by a valid, unexpired signed URL
+	GetSignedFile(ctx context.Context, fileID, expires int64, signature string) (*models.File, error)
+	// GetPublicFileByPath returns the file stored at filePath if it may be served without authorization
+	GetPublicFileByPath(ctx context.Context, filePath string) (*models.File, error)
+}
+
+// fileServiceImpl implements FileService
+type fileServiceImpl struct {
+	fileRepo                 *repositories.FileRepository
+	userRepo                 *repositories.UserRepository
+	pastExamRepo             *repositories.PastExamRepository
+	classNoteRepo            *repositories.ClassNoteRepository
+	communityRepo            *repositories.CommunityRepository
+	communityParticipantRepo *repositories.CommunityParticipantRepository
+	urlSigner                *auth.URLSigner
+	maxSignedURLTTL          time.Duration
+	logger                   zerolog.Logger
+}
+
+// NewFileService creates a new FileService. Signed URLs live at most maxSignedURLTTL.
+func NewFileService(
+	fileRepo *repositories.FileRepository,
+	userRepo *repositories.UserRepository,
+	pastExamRepo *repositories.PastExamRepository,
+	classNoteRepo *repositories.ClassNoteRepository,
+	communityRepo *repositories.CommunityRepository,
+	communityParticipantRepo *repositories.CommunityParticipantRepository,
+	urlSigner *auth.URLSigner,
+	maxSignedURLTTL time.Duration,
+	logger zerolog.Logger,
+) FileService {
+	return &fileServiceImpl{
+		fileRepo:                 fileRepo,
+		userRepo:                 userRepo,
+		pastExamRepo:             pastExamRepo,
+		classNoteRepo:            classNoteRepo,
+		communityRepo:            communityRepo,
+		communityParticipantRepo: communityParticipantRepo,
+		urlSigner:                urlSigner,
+		maxSignedURLTTL:          maxSignedURLTTL,
+		logger:                   logger,
+	}
+}
+
+// GetFileForUser returns the file if the user may read it
+func (s *fileServiceImpl) GetFileForUser(ctx context.Context, fileID, userID int64) (*models.File, error) {
+	file, err := s.getFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkAccess(ctx, file, userID); err != nil {
+		s.logger.Debug().Err(err).Int64("fileID", fileID).Int64("userID", userID).Msg("File access denied")
+		return nil, err
+	}
+	if err := checkScanned(file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// CreateSignedURL issues an expiring download URL for a file the user may read
+func (s *fileServiceImpl) CreateSignedURL(ctx context.Context, fileID, userID int64, ttl time.Duration) (*dto.SignedFileURLResponse, error) {
+	if _, err := s.GetFileForUser(ctx, fileID, userID); err != nil {
+		return nil, err
+	}
+
+	if ttl <= 0 || ttl > s.maxSignedURLTTL {
+		ttl = s.maxSignedURLTTL
+	}
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	signature := s.urlSigner.Sign(fileID, expiresAt)
+
+	return &dto.SignedFileURLResponse{
+		URL:       fmt.Sprintf("/api/v1/files/%d/content?expires=%d&signature=%s", fileID, expiresAt.Unix(), signature),
+		ExpiresAt: expiresAt.UTC(),
+	}, nil
+}
+
+// GetSignedFile returns the file named by a valid, unexpired signed URL
+func (s *fileServiceImpl) GetSignedFile(ctx context.Context, fileID, expires int64, signature string) (*models.File, error) {
+	if err := s.urlSigner.Verify(fileID, expires, signature); err != nil {
+		return nil, err
+	}
+
+	file, err := s.getFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScanned(file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// GetPublicFileByPath returns the file stored at filePath if it may be served without authorization
+func (s *fileServiceImpl) GetPublicFileByPath(ctx context.Context, filePath string) (*models.File, error) {
+	file, err := s.fileRepo.GetByFilePath(ctx, filePath)
+	if errors.Is(err, apperrors.ErrResourceNotFound) {
+		// Thumbnails have no rows of their own; they are served on behalf of their file
+		if parentPath, size, ok := models.ParseThumbnailPath(filePath); ok {
+			if parent, parentErr := s.fileRepo.GetByFilePath(ctx, parentPath); parentErr == nil {
+				if thumbnail := parent.Thumbnail(size); thumbnail != nil {
+					file, err = thumbnail, nil
+				}
+			}
+		}
+	}
+	if err != nil {
+		if errors.Is(err, apperrors.ErrResourceNotFound) {
+			return nil, apperrors.NewResourceNotFoundError("File not found")
+		}
+		return nil, fmt.Errorf("error getting file: %w", err)
+	}
+
+	if !file.ResourceType.IsPublic() {
+		return nil, apperrors.NewForbiddenError(fmt.Sprintf("This file requires authorization; download it from %s or through a signed URL", dto.FileDownloadPath(file.ID)))
+	}
+	if err := checkScanned(file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (s *fileServiceImpl) getFile(ctx context.Context, fileID int64) (*models.File, error) {
+	file, err := s.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrResourceNotFound) {
+			return nil, apperrors.NewResourceNotFoundError("File not found")
+		}
+		return nil, fmt.Errorf("error getting file: %w", err)
+	}
+	return file, nil
+}
+
+// checkAccess applies the read permissions of the resource that owns the file
+func (s *fileServiceImpl) checkAccess(ctx context.Context, file *models.File, userID int64) error {
+	if file.ResourceType.IsPublic() || file.UploadedBy == userID {
+		return nil
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error getting user: %w", err)
+	}
+	if user.RoleType == models.RoleAdmin {
+		return nil
+	}
+
+	switch file.ResourceType {
+	case models.FileTypePastExam:
+		// Past exams are readable by every authenticated user, as on GET /past-exams/:id
+		exam, err := s.pastExamRepo.GetByID(ctx, file.ResourceID)
+		if err != nil {
+			return fmt.Errorf("error getting past exam: %w", err)
+		}
+		if exam == nil {
+			return apperrors.NewResourceNotFoundError("File not found")
+		}
+		return nil
+
+	case models.FileTypeClassNote:
+		// Class notes are readable by every authenticated user, as on GET /class-notes/:id
+		note, err := s.classNoteRepo.GetByID(ctx, file.ResourceID)
+		if err != nil {
+			return fmt.Errorf("error getting class note: %w", err)
+		}
+		if note == nil {
+			return apperrors.NewResourceNotFoundError("File not found")
+		}
+		return nil
+
+	case models.FileTypeCommunity, models.FileTypeChatMessage:
+		// Community files and chat attachments are limited to the community's members
+		community, err := s.communityRepo.GetByID(ctx, file.ResourceID)
+		if err != nil {
+			return apperrors.NewResourceNotFoundError("File not found")
+		}
+		if community.LeadID == userID {
+			return nil
+		}
+		isParticipant, err := s.communityParticipantRepo.IsUserParticipant(ctx, community.ID, userID)
+		if err != nil {
+			return fmt.Errorf("error checking participant status: %w", err)
+		}
+		if !isParticipant {
+			return apperrors.NewForbiddenError("Only community participants can access this file")
+		}
+		return nil
+	}
+
+	// Files without a known owner are private to their uploader
+	return apperrors.NewForbiddenError("You do not have access to this file")
+}
+
+// checkScanned lets only files that were scanned and found clean be downloaded
+func checkScanned(file *models.File) error {
+	switch file.ScanStatus {
+	case models.ScanStatusClean:
+		return nil
+	case models.ScanStatusInfected:
+		return apperrors.NewForbiddenError("This file was quarantined because malware was found in it")
+	default:
+		return apperrors.NewConflictError("This file is still being scanned for malware; try again shortly")
+	}
+}
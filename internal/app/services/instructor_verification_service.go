@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/email"
+)
+
+// InstructorVerificationService is the admin review queue of accounts that registered as
+// PENDING_INSTRUCTOR. Approving an account makes it an instructor; rejecting it makes it a
+// student. Either way the user is emailed and their access tokens are revoked, so the new
+// role applies as soon as they refresh.
+type InstructorVerificationService interface {
+	// QueueNewAccount puts a new account in the queue if it registered as PENDING_INSTRUCTOR
+	QueueNewAccount(ctx context.Context, user *models.User) error
+	ListVerifications(ctx context.Context, status string) ([]dto.InstructorVerificationResponse, error)
+	Approve(ctx context.Context, adminID, userID int64) error
+	Reject(ctx context.Context, adminID, userID int64, reason string) error
+}
+
+// instructorVerificationServiceImpl implements InstructorVerificationService
+type instructorVerificationServiceImpl struct {
+	verificationRepo  *repositories.InstructorVerificationRepository
+	userRepo          *repositories.UserRepository
+	revocationService TokenRevocationService
+	emailService      email.EmailService
+	logger            zerolog.Logger
+}
+
+// NewInstructorVerificationService creates a new InstructorVerificationService
+func NewInstructorVerificationService(
+	verificationRepo *repositories.InstructorVerificationRepository,
+	userRepo *repositories.UserRepository,
+	revocationService TokenRevocationService,
+	emailService email.EmailService,
+	logger zerolog.Logger,
+) InstructorVerificationService {
+	return &instructorVerificationServiceImpl{
+		verificationRepo:  verificationRepo,
+		userRepo:          userRepo,
+		revocationService: revocationService,
+		emailService:      emailService,
+		logger:            logger,
+	}
+}
+
+// QueueNewAccount puts a new would-be instructor in the review queue; other accounts are left alone
+func (s *instructorVerificationServiceImpl) QueueNewAccount(ctx context.Context, user *models.User) error {
+	if user.RoleType != models.RolePendingInstructor {
+		return nil
+	}
+	return s.verificationRepo.Request(ctx, user.ID)
+}
+
+// ListVerifications returns the verifications with a status, PENDING if it is empty
+func (s *instructorVerificationServiceImpl) ListVerifications(ctx context.Context, status string) ([]dto.InstructorVerificationResponse, error) {
+	switch status {
+	case "":
+		status = models.InstructorVerificationPending
+	case models.InstructorVerificationPending, models.InstructorVerificationApproved, models.InstructorVerificationRejected:
+	default:
+		return nil, apperrors.NewBadRequestError("Status must be PENDING, APPROVED or REJECTED")
+	}
+
+	verifications, err := s.verificationRepo.List(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.InstructorVerificationResponse, 0, len(verifications))
+	for _, verification := range verifications {
+		responses = append(responses, dto.InstructorVerificationResponse{
+			UserID:          verification.UserID,
+			Email:           verification.User.Email,
+			FirstName:       verification.User.FirstName,
+			LastName:        verification.User.LastName,
+			Role:            string(verification.User.RoleType),
+			EmailVerified:   verification.User.EmailVerified,
+			DepartmentID:    verification.User.DepartmentID,
+			Status:          verification.Status,
+			RequestedAt:     verification.RequestedAt,
+			ReviewedBy:      verification.ReviewedBy,
+			ReviewedAt:      verification.ReviewedAt,
+			RejectionReason: verification.RejectionReason,
+		})
+	}
+	return responses, nil
+}
+
+// Approve confirms a pending account as an instructor
+func (s *instructorVerificationServiceImpl) Approve(ctx context.Context, adminID, userID int64) error {
+	user, err := s.review(ctx, adminID, userID, models.InstructorVerificationApproved, models.RoleInstructor, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := s.emailService.SendInstructorApprovedEmail(user.Email, user.FirstName); err != nil {
+		s.logger.Error().Err(err).Int64("userID", userID).Msg("Failed to send instructor approval email")
+	}
+	return nil
+}
+
+// Reject turns a pending account into a student account, telling the user why
+func (s *instructorVerificationServiceImpl) Reject(ctx context.Context, adminID, userID int64, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return apperrors.NewBadRequestError("A reason is required to reject an instructor")
+	}
+
+	user, err := s.review(ctx, adminID, userID, models.InstructorVerificationRejected, models.RoleStudent, &reason)
+	if err != nil {
+		return err
+	}
+
+	if err := s.emailService.SendInstructorRejectedEmail(user.Email, user.FirstName, reason); err != nil {
+		s.logger.Error().Err(err).Int64("userID", userID).Msg("Failed to send instructor rejection email")
+	}
+	return nil
+}
+
+// review records a decision, gives the user their new role and revokes their access tokens,
+// which still carry the pending role
+func (s *instructorVerificationServiceImpl) review(ctx context.Context, adminID, userID int64, status string, role models.RoleType, reason *string) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verificationRepo.Review(ctx, userID, status, role, adminID, reason); err != nil {
+		if errors.Is(err, apperrors.ErrResourceNotFound) {
+			return nil, apperrors.NewResourceNotFoundError("No pending instructor verification for this user")
+		}
+		return nil, err
+	}
+
+	if err := s.revocationService.RevokeUserTokens(ctx, userID); err != nil {
+		s.logger.Error().Err(err).Int64("userID", userID).Msg("Failed to revoke access tokens after instructor review")
+	}
+
+	s.logger.Info().Int64("userID", userID).Int64("adminID", adminID).Str("status", status).Msg("Instructor verification reviewed")
+	return user, nil
+}
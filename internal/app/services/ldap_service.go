@@ -0,0 +1,557 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/ldap"
+	"github.com/yigit/unisphere/internal/pkg/oidc"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ldapSyncPageSize is the page size of the sync's directory search
+const ldapSyncPageSize = 500
+
+// LDAPAttributes names the directory attributes user fields are read from
+type LDAPAttributes struct {
+	Email      string
+	FirstName  string
+	LastName   string
+	Department string // Department code; empty leaves departments alone
+	Role       string // Matched against StudentRoles and InstructorRoles; empty uses the role resolver
+}
+
+// LDAPConfig configures directory logins. An empty URL disables them.
+type LDAPConfig struct {
+	URL             string
+	Dial            ldap.DialConfig
+	BindDN          string // Service account that searches for users; empty searches anonymously
+	BindPassword    string
+	BaseDN          string
+	UserFilter      string // %s is replaced by the escaped login email
+	IDAttribute     string // Stable entry ID accounts are linked by; empty uses the DN
+	StudentRoles    []string
+	InstructorRoles []string
+	SyncInterval    time.Duration // 0 disables the periodic sync
+	Attributes      LDAPAttributes
+}
+
+// LDAPService authenticates users against an LDAP directory and keeps the accounts linked to
+// directory entries in sync with them. Accounts are linked through user identities, with the
+// directory as issuer, and provisioned on their first login.
+type LDAPService interface {
+	// Authenticate checks a login against the directory. It returns nil without an error when
+	// the email is not a directory account, so the local password applies.
+	Authenticate(ctx context.Context, email, password string) (*models.User, error)
+	// IsLinked reports whether a user is linked to a directory entry, which owns their
+	// password and email address
+	IsLinked(ctx context.Context, userID int64) (bool, error)
+	// Sync updates linked users from their entries and deactivates those whose entry is gone
+	Sync(ctx context.Context) (*dto.LDAPSyncResponse, error)
+	// Run syncs every SyncInterval
+	Run()
+}
+
+// ldapServiceImpl implements LDAPService
+type ldapServiceImpl struct {
+	config                        LDAPConfig
+	issuer                        string
+	identityRepo                  *repositories.UserIdentityRepository
+	userRepo                      *repositories.UserRepository
+	departmentRepo                *repositories.DepartmentRepository
+	tokenRepo                     *repositories.TokenRepository
+	instructorVerificationService InstructorVerificationService
+	revocationService             TokenRevocationService
+	roleResolver                  RoleResolver
+	logger                        zerolog.Logger
+}
+
+// NewLDAPService creates a new LDAPService
+func NewLDAPService(
+	config LDAPConfig,
+	identityRepo *repositories.UserIdentityRepository,
+	userRepo *repositories.UserRepository,
+	departmentRepo *repositories.DepartmentRepository,
+	tokenRepo *repositories.TokenRepository,
+	instructorVerificationService InstructorVerificationService,
+	revocationService TokenRevocationService,
+	roleResolver RoleResolver,
+	logger zerolog.Logger,
+) LDAPService {
+	return &ldapServiceImpl{
+		config: config,
+		// An LDAP URL of the search base (RFC 4516) identifies the directory in linked accounts
+		issuer:                        strings.TrimSuffix(config.URL, "/") + "/" + config.BaseDN,
+		identityRepo:                  identityRepo,
+		userRepo:                      userRepo,
+		departmentRepo:                departmentRepo,
+		tokenRepo:                     tokenRepo,
+		instructorVerificationService: instructorVerificationService,
+		revocationService:             revocationService,
+		roleResolver:                  roleResolver,
+		logger:                        logger,
+	}
+}
+
+// errLDAPDisabled is returned by Sync while no directory is configured
+var errLDAPDisabled = apperrors.NewResourceNotFoundError("LDAP directory authentication is not enabled")
+
+// Authenticate checks a login against the directory: it finds the entry with the service
+// account, then binds as the entry with the password. Users with an entry are linked or
+// provisioned and updated from it. When the directory cannot be reached, users that are not
+// linked to it fall back to their local password.
+func (s *ldapServiceImpl) Authenticate(ctx context.Context, email, password string) (*models.User, error) {
+	if s.config.URL == "" {
+		return nil, nil
+	}
+
+	entry, err := s.authenticateEntry(ctx, email, password)
+	if errors.Is(err, ldap.ErrInvalidCredentials) {
+		return nil, apperrors.ErrInvalidCredentials
+	}
+	if err != nil || entry == nil {
+		// Linked accounts have no usable local password, so they do not fall back to it
+		linked, linkErr := s.isLinked(ctx, email)
+		if linkErr != nil {
+			return nil, linkErr
+		}
+		switch {
+		case linked && err != nil:
+			return nil, fmt.Errorf("LDAP directory is unavailable: %w", err)
+		case linked:
+			return nil, apperrors.ErrInvalidCredentials // The entry is gone
+		case err != nil:
+			s.logger.Warn().Err(err).Msg("LDAP directory is unavailable; falling back to local passwords")
+		}
+		return nil, nil
+	}
+
+	return s.resolveUser(ctx, entry, email)
+}
+
+// authenticateEntry returns the entry of an email after binding as it with the password, or
+// nil if the directory has no such entry. A wrong password gives ldap.ErrInvalidCredentials.
+func (s *ldapServiceImpl) authenticateEntry(ctx context.Context, email, password string) (*ldap.Entry, error) {
+	conn, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entries, err := conn.Search(ldap.SearchRequest{
+		BaseDN:     s.config.BaseDN,
+		Scope:      ldap.ScopeWholeSubtree,
+		Filter:     strings.Replace(s.config.UserFilter, "%s", ldap.EscapeFilter(email), 1),
+		Attributes: s.searchAttributes(),
+		SizeLimit:  2,
+	})
+	if ldap.IsSizeLimitExceeded(err) {
+		err = nil // More than one match, refused below
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LDAP user search failed: %w", err)
+	}
+	switch len(entries) {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, fmt.Errorf("LDAP user filter matches more than one entry for %s", email)
+	}
+
+	if err := conn.Bind(entries[0].DN, password); err != nil {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+// connect dials the directory and binds as the service account
+func (s *ldapServiceImpl) connect(ctx context.Context) (*ldap.Conn, error) {
+	conn, err := ldap.Dial(ctx, s.config.URL, s.config.Dial)
+	if err != nil {
+		return nil, err
+	}
+	if s.config.BindDN != "" {
+		if err := conn.Bind(s.config.BindDN, s.config.BindPassword); err != nil {
+			conn.Close()
+			// Not wrapped: a rejected service account must not read as a wrong user password
+			return nil, fmt.Errorf("LDAP service account bind failed: %v", err)
+		}
+	}
+	return conn, nil
+}
+
+// searchAttributes returns the attributes to fetch for users
+func (s *ldapServiceImpl) searchAttributes() []string {
+	attributes := []string{}
+	for _, attribute := range []string{s.config.IDAttribute, s.config.Attributes.Email, s.config.Attributes.FirstName,
+		s.config.Attributes.LastName, s.config.Attributes.Department, s.config.Attributes.Role} {
+		if attribute != "" {
+			attributes = append(attributes, attribute)
+		}
+	}
+	return attributes
+}
+
+// entryID returns the stable ID of an entry. Binary IDs, such as objectGUID, are hex-encoded.
+func (s *ldapServiceImpl) entryID(entry *ldap.Entry) string {
+	if s.config.IDAttribute == "" {
+		return strings.ToLower(entry.DN)
+	}
+	id := entry.Value(s.config.IDAttribute)
+	if !utf8.ValidString(id) {
+		return hex.EncodeToString([]byte(id))
+	}
+	return id
+}
+
+// IsLinked reports whether a user is linked to an entry of the directory
+func (s *ldapServiceImpl) IsLinked(ctx context.Context, userID int64) (bool, error) {
+	if s.config.URL == "" {
+		return false, nil
+	}
+	subject, err := s.identityRepo.GetSubject(ctx, userID, s.issuer)
+	if err != nil {
+		return false, err
+	}
+	return subject != "", nil
+}
+
+// isLinked reports whether the user with an email is linked to the directory
+func (s *ldapServiceImpl) isLinked(ctx context.Context, email string) (bool, error) {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrUserNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return s.IsLinked(ctx, user.ID)
+}
+
+// resolveUser returns the user an authenticated entry logs in to: the linked user, the user
+// with the same email, which is linked now, or a new user. Users are updated from the entry.
+func (s *ldapServiceImpl) resolveUser(ctx context.Context, entry *ldap.Entry, loginEmail string) (*models.User, error) {
+	subject := s.entryID(entry)
+	if subject == "" {
+		return nil, fmt.Errorf("LDAP entry %s has no %s attribute", entry.DN, s.config.IDAttribute)
+	}
+
+	identity, err := s.identityRepo.Get(ctx, s.issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		user, err := s.userRepo.GetUserByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.identityRepo.TouchLogin(ctx, s.issuer, subject); err != nil {
+			s.logger.Warn().Err(err).Int64("userID", user.ID).Msg("Failed to record LDAP login")
+		}
+		if _, err := s.applyEntry(ctx, user, identity, entry); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	email := entry.Value(s.config.Attributes.Email)
+	if email == "" {
+		email = loginEmail
+	}
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, apperrors.ErrUserNotFound) {
+		return nil, err
+	}
+	if user != nil {
+		// An account linked to another entry is not relinked, as the email may have moved
+		linkedSubject, err := s.identityRepo.GetSubject(ctx, user.ID, s.issuer)
+		if err != nil {
+			return nil, err
+		}
+		if linkedSubject != "" {
+			return nil, apperrors.NewConflictError("Your account is linked to another directory entry")
+		}
+	} else if user, err = s.provisionUser(ctx, email, entry); err != nil {
+		return nil, err
+	}
+
+	identity = &models.UserIdentity{UserID: user.ID, Issuer: s.issuer, Subject: subject}
+	if err := s.identityRepo.Create(ctx, identity); err != nil {
+		if errors.Is(err, apperrors.ErrResourceAlreadyExists) {
+			return nil, apperrors.NewConflictError("This directory account was linked by another login; try again")
+		}
+		return nil, err
+	}
+	if _, err := s.applyEntry(ctx, user, identity, entry); err != nil {
+		return nil, err
+	}
+	s.logger.Info().Int64("userID", user.ID).Str("dn", entry.DN).Msg("Linked LDAP directory entry")
+	return user, nil
+}
+
+// provisionUser creates the account of a directory user logging in for the first time. It
+// has an unusable random password; the directory password is used instead.
+func (s *ldapServiceImpl) provisionUser(ctx context.Context, email string, entry *ldap.Entry) (*models.User, error) {
+	password, err := oidc.RandomToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing password: %w", err)
+	}
+
+	roleType := s.entryRole(entry)
+	if roleType == "" {
+		roleType = s.roleResolver.ResolveRole(email)
+	}
+	user := &models.User{
+		Email:         email,
+		Password:      string(hashedPassword),
+		FirstName:     entry.Value(s.config.Attributes.FirstName),
+		LastName:      entry.Value(s.config.Attributes.LastName),
+		RoleType:      roleType,
+		IsActive:      true,
+		EmailVerified: true, // Vouched for by the directory
+	}
+	if user.DepartmentID, err = s.entryDepartment(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	userID, err := s.userRepo.CreateUser(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("error creating user: %w", err)
+	}
+	user.ID = userID
+
+	if err := s.instructorVerificationService.QueueNewAccount(ctx, user); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().Int64("userID", userID).Str("role", string(roleType)).Msg("Provisioned user from LDAP directory")
+	return user, nil
+}
+
+// applyEntry updates a linked user's names, department and role from their entry, and
+// reactivates them if the sync deactivated them. Admins keep their role. It reports whether
+// the user changed.
+func (s *ldapServiceImpl) applyEntry(ctx context.Context, user *models.User, identity *models.UserIdentity, entry *ldap.Entry) (bool, error) {
+	changed, roleChanged := false, false
+	if firstName := entry.Value(s.config.Attributes.FirstName); firstName != "" && firstName != user.FirstName {
+		user.FirstName, changed = firstName, true
+	}
+	if lastName := entry.Value(s.config.Attributes.LastName); lastName != "" && lastName != user.LastName {
+		user.LastName, changed = lastName, true
+	}
+	departmentID, err := s.entryDepartment(ctx, entry)
+	if err != nil {
+		return false, err
+	}
+	if departmentID != nil && (user.DepartmentID == nil || *user.DepartmentID != *departmentID) {
+		user.DepartmentID, changed = departmentID, true
+	}
+	if role := s.entryRole(entry); role != "" && role != user.RoleType && user.RoleType != models.RoleAdmin {
+		user.RoleType, changed, roleChanged = role, true, true
+	}
+	if identity.DeactivatedAt != nil {
+		user.IsActive, changed = true, true
+	}
+
+	if !changed {
+		return false, nil
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return false, err
+	}
+	if identity.DeactivatedAt != nil {
+		if err := s.identityRepo.SetDeactivated(ctx, identity.ID, false); err != nil {
+			return false, err
+		}
+		identity.DeactivatedAt = nil
+		s.logger.Info().Int64("userID", user.ID).Msg("Reactivated user back in the LDAP directory")
+	}
+	// Access tokens carry the role
+	if roleChanged {
+		if err := s.revocationService.RevokeUserTokens(ctx, user.ID); err != nil {
+			s.logger.Error().Err(err).Int64("userID", user.ID).Msg("Failed to revoke access tokens after LDAP role change")
+		}
+	}
+	return true, nil
+}
+
+// entryRole returns the role the role attribute of an entry maps to, or "" if it maps to none.
+// Instructor values win over student values.
+func (s *ldapServiceImpl) entryRole(entry *ldap.Entry) models.RoleType {
+	if s.config.Attributes.Role == "" {
+		return ""
+	}
+	values := entry.Values(s.config.Attributes.Role)
+	if containsFold(values, s.config.InstructorRoles) {
+		return models.RoleInstructor
+	}
+	if containsFold(values, s.config.StudentRoles) {
+		return models.RoleStudent
+	}
+	return ""
+}
+
+// entryDepartment returns the department whose code the department attribute holds, or nil
+func (s *ldapServiceImpl) entryDepartment(ctx context.Context, entry *ldap.Entry) (*int64, error) {
+	code := entry.Value(s.config.Attributes.Department)
+	if s.config.Attributes.Department == "" || code == "" {
+		return nil, nil
+	}
+	department, err := s.departmentRepo.GetByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrDepartmentNotFound) {
+			s.logger.Warn().Str("departmentCode", code).Str("dn", entry.DN).Msg("LDAP department code matches no department")
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &department.ID, nil
+}
+
+// containsFold reports whether any of values is one of candidates, ignoring case
+func containsFold(values, candidates []string) bool {
+	for _, value := range values {
+		for _, candidate := range candidates {
+			if strings.EqualFold(strings.TrimSpace(value), strings.TrimSpace(candidate)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Sync updates every linked user from their entry and deactivates, and signs out, those whose
+// entry is gone. Users it deactivated are reactivated when their entry comes back. Entries
+// without a linked user are left for their first login.
+func (s *ldapServiceImpl) Sync(ctx context.Context) (*dto.LDAPSyncResponse, error) {
+	if s.config.URL == "" {
+		return nil, errLDAPDisabled
+	}
+
+	conn, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := conn.Search(ldap.SearchRequest{
+		BaseDN:     s.config.BaseDN,
+		Scope:      ldap.ScopeWholeSubtree,
+		Filter:     strings.Replace(s.config.UserFilter, "%s", "*", 1),
+		Attributes: s.searchAttributes(),
+		PageSize:   ldapSyncPageSize,
+	})
+	conn.Close()
+	if err != nil {
+		// A partial listing would deactivate everyone left out of it
+		return nil, fmt.Errorf("LDAP sync search failed: %w", err)
+	}
+
+	identities, err := s.identityRepo.ListByIssuer(ctx, s.issuer)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 && len(identities) > 0 {
+		return nil, fmt.Errorf("LDAP sync search found no users; refusing to deactivate every linked user")
+	}
+	byID := make(map[string]*ldap.Entry, len(entries))
+	for _, entry := range entries {
+		byID[s.entryID(entry)] = entry
+	}
+
+	report := &dto.LDAPSyncResponse{Checked: len(identities)}
+	for _, identity := range identities {
+		user, err := s.userRepo.GetUserByID(ctx, identity.UserID)
+		if err != nil {
+			s.logger.Error().Err(err).Int64("userID", identity.UserID).Msg("Failed to load user for LDAP sync")
+			continue
+		}
+
+		entry := byID[identity.Subject]
+		if entry == nil {
+			// Users deactivated for other reasons are left alone, so they stay inactive if the entry returns
+			if identity.DeactivatedAt != nil || !user.IsActive {
+				continue
+			}
+			if err := s.deactivate(ctx, user, identity); err != nil {
+				s.logger.Error().Err(err).Int64("userID", user.ID).Msg("Failed to deactivate user gone from the LDAP directory")
+				continue
+			}
+			report.Deactivated++
+			continue
+		}
+
+		reactivating := identity.DeactivatedAt != nil
+		changed, err := s.applyEntry(ctx, user, identity, entry)
+		if err != nil {
+			s.logger.Error().Err(err).Int64("userID", user.ID).Msg("Failed to update user from the LDAP directory")
+			continue
+		}
+		switch {
+		case reactivating:
+			report.Reactivated++
+		case changed:
+			report.Updated++
+		}
+	}
+
+	s.logger.Info().
+		Int("checked", report.Checked).
+		Int("updated", report.Updated).
+		Int("deactivated", report.Deactivated).
+		Int("reactivated", report.Reactivated).
+		Msg("LDAP directory sync finished")
+	return report, nil
+}
+
+// deactivate deactivates a user whose entry is gone and ends their sessions
+func (s *ldapServiceImpl) deactivate(ctx context.Context, user *models.User, identity *models.UserIdentity) error {
+	user.IsActive = false
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	if err := s.identityRepo.SetDeactivated(ctx, identity.ID, true); err != nil {
+		return err
+	}
+
+	if _, err := s.tokenRepo.RevokeAllFamilies(ctx, user.ID, models.TokenFamilySignedOut); err != nil {
+		s.logger.Error().Err(err).Int64("userID", user.ID).Msg("Failed to revoke sessions of deactivated LDAP user")
+	}
+	// Tokens issued before families existed are not in any
+	if err := s.tokenRepo.RevokeAllUserTokens(ctx, user.ID); err != nil {
+		s.logger.Error().Err(err).Int64("userID", user.ID).Msg("Failed to revoke refresh tokens of deactivated LDAP user")
+	}
+	if err := s.revocationService.RevokeUserTokens(ctx, user.ID); err != nil {
+		s.logger.Error().Err(err).Int64("userID", user.ID).Msg("Failed to revoke access tokens of deactivated LDAP user")
+	}
+	s.logger.Info().Int64("userID", user.ID).Str("subject", identity.Subject).Msg("Deactivated user gone from the LDAP directory")
+	return nil
+}
+
+// Run syncs every SyncInterval. It returns at once when directory logins or the sync are disabled.
+func (s *ldapServiceImpl) Run() {
+	if s.config.URL == "" || s.config.SyncInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.config.SyncInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := s.Sync(context.Background()); err != nil {
+			s.logger.Error().Err(err).Msg("LDAP directory sync failed")
+		}
+	}
+}
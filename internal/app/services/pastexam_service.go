@@ -14,27 +14,33 @@ import (
 	"github.com/yigit/unisphere/internal/pkg/apperrors"
 	"github.com/yigit/unisphere/internal/pkg/filestorage"
 	"github.com/yigit/unisphere/internal/pkg/helpers"
+	"github.com/yigit/unisphere/internal/pkg/markdown"
 )
 
 // PastExamService defines the interface for past exam operations
 type PastExamService interface {
 	GetAllExams(ctx context.Context, filter *dto.PastExamFilterRequest) (*dto.PastExamListResponse, error)
-	GetExamByID(ctx context.Context, id int64) (*dto.PastExamResponse, error)
+	GetExamByID(ctx context.Context, id int64, includeHTML bool) (*dto.PastExamResponse, error)
 	CreateExam(ctx context.Context, req *dto.CreatePastExamRequest, files []*multipart.FileHeader) (*dto.PastExamResponse, error)
 	UpdateExam(ctx context.Context, id int64, req *dto.UpdatePastExamRequest) (*dto.PastExamResponse, error)
 	DeleteExam(ctx context.Context, id int64) error
-	AddFileToPastExam(ctx context.Context, examID int64, file *multipart.FileHeader) error
+	AddFileToPastExam(ctx context.Context, examID int64, file *multipart.FileHeader) ([]dto.DuplicateWarning, error)
 	RemoveFileFromPastExam(ctx context.Context, examID int64, fileID int64) error
+	GetExamBundle(ctx context.Context, id int64) (*dto.FileBundle, error)
+	GetCourseBundle(ctx context.Context, req *dto.CourseBundleRequest) (*dto.FileBundle, error)
 }
 
 // pastExamServiceImpl implements PastExamService
 type pastExamServiceImpl struct {
-	pastExamRepo   *repositories.PastExamRepository
-	departmentRepo *repositories.DepartmentRepository
-	fileRepo       *repositories.FileRepository
-	fileStorage    *filestorage.LocalStorage
-	authzService   *auth.AuthorizationService
-	logger         zerolog.Logger
+	pastExamRepo     *repositories.PastExamRepository
+	departmentRepo   *repositories.DepartmentRepository
+	fileRepo         *repositories.FileRepository
+	fileStorage      filestorage.FileStorage
+	quotaService     QuotaService
+	scanService      ScanService
+	authzService     *auth.AuthorizationService
+	duplicateService DuplicateService
+	logger           zerolog.Logger
 }
 
 // NewPastExamService creates a new PastExamService
@@ -42,28 +48,45 @@ func NewPastExamService(
 	pastExamRepo *repositories.PastExamRepository,
 	departmentRepo *repositories.DepartmentRepository,
 	fileRepo *repositories.FileRepository,
-	fileStorage *filestorage.LocalStorage,
+	fileStorage filestorage.FileStorage,
+	quotaService QuotaService,
+	scanService ScanService,
 	authzService *auth.AuthorizationService,
+	duplicateService DuplicateService,
 	logger zerolog.Logger,
 ) PastExamService {
 	return &pastExamServiceImpl{
-		pastExamRepo:   pastExamRepo,
-		departmentRepo: departmentRepo,
-		fileRepo:       fileRepo,
-		fileStorage:    fileStorage,
-		authzService:   authzService,
-		logger:         logger,
+		pastExamRepo:     pastExamRepo,
+		departmentRepo:   departmentRepo,
+		fileRepo:         fileRepo,
+		fileStorage:      fileStorage,
+		quotaService:     quotaService,
+		scanService:      scanService,
+		authzService:     authzService,
+		duplicateService: duplicateService,
+		logger:           logger,
 	}
 }
 
 // GetAllExams retrieves all past exams with filtering and pagination
 func (s *pastExamServiceImpl) GetAllExams(ctx context.Context, filter *dto.PastExamFilterRequest) (*dto.PastExamListResponse, error) {
-	// Get exams from repository
-	exams, total, err := s.pastExamRepo.GetAll(ctx, filter.FacultyID, filter.DepartmentID, filter.CourseCode, filter.Year, filter.Term, filter.Page, filter.PageSize)
+	cursor, err := helpers.ParseCursor(filter.After, filter.Before)
+	if err != nil {
+		return nil, apperrors.NewBadRequestError(err.Error())
+	}
+
+	// Get exams from repository; files are skipped unless they are expanded
+	withFiles := filter.Projection.ExpandsOr("files", true)
+	exams, total, hasMore, err := s.pastExamRepo.GetAll(ctx, filter.FacultyID, filter.DepartmentID, filter.CourseCode, filter.Year, filter.Term, filter.Page, filter.PageSize, cursor, withFiles)
 	if err != nil {
 		return nil, fmt.Errorf("error getting past exams: %w", err)
 	}
 
+	var departments *departmentExpander
+	if filter.Projection.Expands("department") {
+		departments = newDepartmentExpander(s.departmentRepo)
+	}
+
 	// Convert to response DTOs
 	var examResponses []dto.PastExamResponse
 	for _, exam := range exams {
@@ -79,26 +102,40 @@ func (s *pastExamServiceImpl) GetAllExams(ctx context.Context, filter *dto.PastE
 			Year:         exam.Year,
 			Term:         string(exam.Term),
 			Title:        exam.Title,
-			Content:      exam.Content,
+			Content:      contentSource(exam.Content, exam.ContentHTMLVersion),
+			ContentHTML:  contentHTML(filter.IncludeHTML, exam.Content, exam.ContentHTML, exam.ContentHTMLVersion),
 			DepartmentID: exam.DepartmentID,
 			InstructorID: exam.InstructorID,
 			FileIDs:      fileIDs,
 			CreatedAt:    exam.CreatedAt,
 			UpdatedAt:    exam.UpdatedAt,
 		})
+
+		if departments != nil {
+			department, err := departments.get(ctx, exam.DepartmentID)
+			if err != nil {
+				return nil, fmt.Errorf("error getting department for exam %d: %w", exam.ID, err)
+			}
+			examResponses[len(examResponses)-1].Department = department
+		}
 	}
 
 	// Create response with pagination using the helper function
 	paginationInfo := helpers.NewPaginationInfo(total, filter.Page, filter.PageSize)
 
+	cursorInfo := helpers.NewCursorInfo(repositories.PastExamSort, len(exams), func(i int) (interface{}, int64) {
+		return exams[i].CreatedAt, exams[i].ID
+	}, cursor, hasMore, filter.Page, filter.PageSize, total)
+
 	return &dto.PastExamListResponse{
 		PastExams:      examResponses,
 		PaginationInfo: paginationInfo,
+		Cursor:         cursorInfo,
 	}, nil
 }
 
-// GetExamByID retrieves a past exam by ID
-func (s *pastExamServiceImpl) GetExamByID(ctx context.Context, id int64) (*dto.PastExamResponse, error) {
+// GetExamByID retrieves a past exam by ID; its content HTML only when includeHTML is set
+func (s *pastExamServiceImpl) GetExamByID(ctx context.Context, id int64, includeHTML bool) (*dto.PastExamResponse, error) {
 	// Get exam from repository
 	exam, err := s.pastExamRepo.GetByID(ctx, id)
 	if err != nil {
@@ -121,7 +158,8 @@ func (s *pastExamServiceImpl) GetExamByID(ctx context.Context, id int64) (*dto.P
 		Year:         exam.Year,
 		Term:         string(exam.Term),
 		Title:        exam.Title,
-		Content:      exam.Content,
+		Content:      contentSource(exam.Content, exam.ContentHTMLVersion),
+		ContentHTML:  contentHTML(includeHTML, exam.Content, exam.ContentHTML, exam.ContentHTMLVersion),
 		DepartmentID: exam.DepartmentID,
 		InstructorID: exam.InstructorID,
 		FileIDs:      fileIDs,
@@ -144,17 +182,39 @@ func (s *pastExamServiceImpl) CreateExam(ctx context.Context, req *dto.CreatePas
 		return nil, fmt.Errorf("user ID not found in context")
 	}
 
+	// The route only checks pastexam:write somewhere; the exam goes to the requested department
+	departmentScope := &auth.Scope{Type: models.ScopeDepartment, ID: req.DepartmentID}
+	if err := s.authzService.RequirePermission(ctx, userID, auth.PermissionPastExamWrite, departmentScope,
+		"You don't have permission to publish past exams in this department"); err != nil {
+		return nil, err
+	}
+
+	// Reject the request before anything is stored if a file breaks the upload policy
+	if _, err := checkUploads(ctx, s.fileRepo, models.FileTypePastExam, 0, files); err != nil {
+		return nil, err
+	}
+	if err := s.quotaService.CheckUserQuota(ctx, userID, uploadSize(files)); err != nil {
+		return nil, err
+	}
+
 	// Create exam model
 	exam := &models.PastExam{
 		CourseCode:   req.CourseCode,
 		Year:         req.Year,
 		Term:         models.Term(req.Term),
 		Title:        req.Title,
-		Content:      req.Content,
+		Content:      markdown.StripHTML(req.Content),
 		DepartmentID: req.DepartmentID,
 		InstructorID: userID, // Use current user as instructor
 	}
 
+	// Render Markdown content to sanitized HTML
+	renderedHTML, renderedVersion, err := renderContentHTML(exam.Content)
+	if err != nil {
+		return nil, err
+	}
+	exam.ContentHTML, exam.ContentHTMLVersion = renderedHTML, renderedVersion
+
 	// Save exam to database
 	examID, err := s.pastExamRepo.Create(ctx, exam)
 	if err != nil {
@@ -168,6 +228,7 @@ func (s *pastExamServiceImpl) CreateExam(ctx context.Context, req *dto.CreatePas
 
 	// Process uploaded files
 	var savedFiles []*models.File
+	var duplicateWarnings []dto.DuplicateWarning
 	
 	// Only process files if any were provided
 	if len(files) > 0 {
@@ -195,6 +256,7 @@ func (s *pastExamServiceImpl) CreateExam(ctx context.Context, req *dto.CreatePas
 			}
 
 			savedFiles = append(savedFiles, file)
+			duplicateWarnings = append(duplicateWarnings, s.duplicateService.CheckFile(ctx, file, fileHeader)...)
 		}
 	} else {
 		s.logger.Debug().Msg("No files provided for past exam")
@@ -210,22 +272,29 @@ func (s *pastExamServiceImpl) CreateExam(ctx context.Context, req *dto.CreatePas
 	}
 
 	return &dto.PastExamResponse{
-		ID:           exam.ID,
-		CourseCode:   exam.CourseCode,
-		Year:         exam.Year,
-		Term:         string(exam.Term),
-		Title:        exam.Title,
-		Content:      exam.Content,
-		DepartmentID: exam.DepartmentID,
-		InstructorID: exam.InstructorID,
-		FileIDs:      fileIDs,
-		CreatedAt:    exam.CreatedAt,
-		UpdatedAt:    exam.UpdatedAt,
+		ID:                exam.ID,
+		CourseCode:        exam.CourseCode,
+		Year:              exam.Year,
+		Term:              string(exam.Term),
+		Title:             exam.Title,
+		Content:           exam.Content,
+		DepartmentID:      exam.DepartmentID,
+		InstructorID:      exam.InstructorID,
+		FileIDs:           fileIDs,
+		CreatedAt:         exam.CreatedAt,
+		UpdatedAt:         exam.UpdatedAt,
+		DuplicateWarnings: duplicateWarnings,
 	}, nil
 }
 
 // uploadFile uploads a file to storage and saves its metadata to the database
 func (s *pastExamServiceImpl) uploadFile(ctx context.Context, fileHeader *multipart.FileHeader, resourceType models.FileType, resourceID int64, userID int64) (*models.File, error) {
+	// Validate against the upload policy and use the detected MIME type
+	contentType, err := checkUpload(resourceType, fileHeader)
+	if err != nil {
+		return nil, err
+	}
+
 	// Open the file
 	src, err := fileHeader.Open()
 	if err != nil {
@@ -255,7 +324,7 @@ func (s *pastExamServiceImpl) uploadFile(ctx context.Context, fileHeader *multip
 		FilePath:     relativeFilePath,    // Store unique path for retrieval
 		FileURL:      fileURL,
 		FileSize:     fileHeader.Size,
-		FileType:     fileHeader.Header.Get("Content-Type"),
+		FileType:     contentType,
 		ResourceType: resourceType,
 		ResourceID:   resourceID,
 		UploadedBy:   userID,
@@ -267,10 +336,21 @@ func (s *pastExamServiceImpl) uploadFile(ctx context.Context, fileHeader *multip
 		return nil, fmt.Errorf("error saving file metadata: %w", err)
 	}
 	file.ID = fileID
+	s.scanService.Enqueue(ctx, fileID)
 
 	return file, nil
 }
 
+// checkExamManagement checks that a user may change an exam: its creator, or a user with
+// pastexam:manage on its department
+func (s *pastExamServiceImpl) checkExamManagement(ctx context.Context, exam *models.PastExam, userID int64, message string) error {
+	if exam.InstructorID == userID {
+		return nil
+	}
+	departmentScope := &auth.Scope{Type: models.ScopeDepartment, ID: exam.DepartmentID}
+	return s.authzService.RequirePermission(ctx, userID, auth.PermissionPastExamManage, departmentScope, message)
+}
+
 // UpdateExam updates an existing past exam
 func (s *pastExamServiceImpl) UpdateExam(ctx context.Context, id int64, req *dto.UpdatePastExamRequest) (*dto.PastExamResponse, error) {
 	// Get existing exam
@@ -290,9 +370,8 @@ func (s *pastExamServiceImpl) UpdateExam(ctx context.Context, id int64, req *dto
 	}
 
 	// Check if user has permission to update
-	// Only the instructor who created the exam can update it
-	if existingExam.InstructorID != userID {
-		return nil, fmt.Errorf("unauthorized: only the creator can update this exam")
+	if err := s.checkExamManagement(ctx, existingExam, userID, "Only the creator or a past exam manager can update this exam"); err != nil {
+		return nil, err
 	}
 
 	// Update exam model with new values
@@ -302,11 +381,17 @@ func (s *pastExamServiceImpl) UpdateExam(ctx context.Context, id int64, req *dto
 		Year:         req.Year,
 		Term:         models.Term(req.Term),
 		Title:        req.Title,
-		Content:      req.Content,
+		Content:      markdown.StripHTML(req.Content),
 		DepartmentID: existingExam.DepartmentID, // Keep original department
 		InstructorID: existingExam.InstructorID, // Keep original instructor
 	}
 
+	// Render Markdown content to sanitized HTML
+	updatedExam.ContentHTML, updatedExam.ContentHTMLVersion, err = renderContentHTML(updatedExam.Content)
+	if err != nil {
+		return nil, err
+	}
+
 	// Update exam in database
 	err = s.pastExamRepo.Update(ctx, updatedExam)
 	if err != nil {
@@ -334,7 +419,7 @@ func (s *pastExamServiceImpl) UpdateExam(ctx context.Context, id int64, req *dto
 		Year:         updatedExamFull.Year,
 		Term:         string(updatedExamFull.Term),
 		Title:        updatedExamFull.Title,
-		Content:      updatedExamFull.Content,
+		Content:      contentSource(updatedExamFull.Content, updatedExamFull.ContentHTMLVersion),
 		DepartmentID: updatedExamFull.DepartmentID,
 		InstructorID: updatedExamFull.InstructorID,
 		FileIDs:      fileIDs,
@@ -362,9 +447,8 @@ func (s *pastExamServiceImpl) DeleteExam(ctx context.Context, id int64) error {
 	}
 
 	// Check if user has permission to delete
-	// Only the instructor who created the exam can delete it
-	if existingExam.InstructorID != userID {
-		return fmt.Errorf("unauthorized: only the creator can delete this exam")
+	if err := s.checkExamManagement(ctx, existingExam, userID, "Only the creator or a past exam manager can delete this exam"); err != nil {
+		return err
 	}
 
 	// Delete all associated files
@@ -399,27 +483,33 @@ func (s *pastExamServiceImpl) DeleteExam(ctx context.Context, id int64) error {
 }
 
 // AddFileToPastExam adds a file to an existing past exam
-func (s *pastExamServiceImpl) AddFileToPastExam(ctx context.Context, examID int64, file *multipart.FileHeader) error {
+func (s *pastExamServiceImpl) AddFileToPastExam(ctx context.Context, examID int64, file *multipart.FileHeader) ([]dto.DuplicateWarning, error) {
 	// Get existing exam
 	existingExam, err := s.pastExamRepo.GetByID(ctx, examID)
 	if err != nil {
-		return fmt.Errorf("error getting past exam: %w", err)
+		return nil, fmt.Errorf("error getting past exam: %w", err)
 	}
 	if existingExam == nil {
-		return apperrors.ErrPastExamNotFound
+		return nil, apperrors.ErrPastExamNotFound
 	}
 
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(int64)
 	if !ok {
 		s.logger.Error().Msg("User ID not found in context")
-		return fmt.Errorf("user ID not found in context")
+		return nil, fmt.Errorf("user ID not found in context")
 	}
 
 	// Check if user has permission to update
-	// Only the instructor who created the exam can update it
-	if existingExam.InstructorID != userID {
-		return fmt.Errorf("unauthorized: only the creator can update this exam")
+	if err := s.checkExamManagement(ctx, existingExam, userID, "Only the creator or a past exam manager can update this exam"); err != nil {
+		return nil, err
+	}
+
+	if _, err := checkUploads(ctx, s.fileRepo, models.FileTypePastExam, examID, []*multipart.FileHeader{file}); err != nil {
+		return nil, err
+	}
+	if err := s.quotaService.CheckUserQuota(ctx, userID, file.Size); err != nil {
+		return nil, err
 	}
 
 	// Upload file
@@ -429,7 +519,7 @@ func (s *pastExamServiceImpl) AddFileToPastExam(ctx context.Context, examID int6
 			Str("filename", file.Filename).
 			Int64("examID", examID).
 			Msg("Failed to upload file for past exam")
-		return fmt.Errorf("failed to upload file: %w", err)
+		return nil, fmt.Errorf("failed to upload file: %w", err)
 	}
 
 	// Link file to past exam
@@ -444,10 +534,10 @@ func (s *pastExamServiceImpl) AddFileToPastExam(ctx context.Context, examID int6
 		_ = s.fileStorage.DeleteFile(uploadedFile.FilePath)
 		_ = s.fileRepo.Delete(ctx, uploadedFile.ID)
 
-		return fmt.Errorf("failed to link file to past exam: %w", err)
+		return nil, fmt.Errorf("failed to link file to past exam: %w", err)
 	}
 
-	return nil
+	return s.duplicateService.CheckFile(ctx, uploadedFile, file), nil
 }
 
 // RemoveFileFromPastExam removes a file from a past exam
@@ -469,9 +559,8 @@ func (s *pastExamServiceImpl) RemoveFileFromPastExam(ctx context.Context, examID
 	}
 
 	// Check if user has permission to update
-	// Only the instructor who created the exam can update it
-	if existingExam.InstructorID != userID {
-		return fmt.Errorf("unauthorized: only the creator can update this exam")
+	if err := s.checkExamManagement(ctx, existingExam, userID, "Only the creator or a past exam manager can update this exam"); err != nil {
+		return err
 	}
 
 	// Get file
@@ -518,3 +607,72 @@ func (s *pastExamServiceImpl) RemoveFileFromPastExam(ctx context.Context, examID
 
 	return nil
 }
+
+// GetExamBundle lists the files of a past exam for download as a single ZIP archive
+func (s *pastExamServiceImpl) GetExamBundle(ctx context.Context, id int64) (*dto.FileBundle, error) {
+	exam, err := s.pastExamRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting past exam: %w", err)
+	}
+	if exam == nil {
+		return nil, apperrors.ErrPastExamNotFound
+	}
+	if len(exam.Files) == 0 {
+		return nil, apperrors.NewResourceNotFoundError("Past exam has no files")
+	}
+
+	builder := newBundleBuilder()
+	builder.addFiles(builder.folder(fmt.Sprint(exam.Year), string(exam.Term), exam.Title), exam.Files)
+
+	return &dto.FileBundle{
+		FileName: bundleFileName(exam.CourseCode, fmt.Sprint(exam.Year), string(exam.Term), exam.Title),
+		Entries:  builder.entries,
+	}, nil
+}
+
+// GetCourseBundle lists the files of every past exam of a course within the year range
+// for download as a single ZIP archive organised as year/term/title
+func (s *pastExamServiceImpl) GetCourseBundle(ctx context.Context, req *dto.CourseBundleRequest) (*dto.FileBundle, error) {
+	courseCode := strings.TrimSpace(req.CourseCode)
+	if courseCode == "" {
+		return nil, apperrors.NewBadRequestError("Course code is required")
+	}
+	if req.FromYear != nil && req.ToYear != nil && *req.FromYear > *req.ToYear {
+		return nil, apperrors.NewBadRequestError("fromYear must not be after toYear")
+	}
+
+	exams, err := s.pastExamRepo.GetByCourseCode(ctx, courseCode, req.FromYear, req.ToYear)
+	if err != nil {
+		return nil, fmt.Errorf("error getting past exams for course: %w", err)
+	}
+
+	builder := newBundleBuilder()
+	for _, exam := range exams {
+		if len(exam.Files) == 0 {
+			continue
+		}
+		if len(builder.entries)+len(exam.Files) > maxBundleFiles {
+			return nil, apperrors.NewBadRequestError(
+				fmt.Sprintf("Too many files to bundle (limit %d); narrow the year range", maxBundleFiles))
+		}
+		builder.addFiles(builder.folder(fmt.Sprint(exam.Year), string(exam.Term), exam.Title), exam.Files)
+	}
+	if len(builder.entries) == 0 {
+		return nil, apperrors.NewResourceNotFoundError("No past exam files found for this course")
+	}
+
+	yearRange := ""
+	switch {
+	case req.FromYear != nil && req.ToYear != nil:
+		yearRange = fmt.Sprintf("%d-%d", *req.FromYear, *req.ToYear)
+	case req.FromYear != nil:
+		yearRange = fmt.Sprintf("%d-", *req.FromYear)
+	case req.ToYear != nil:
+		yearRange = fmt.Sprintf("-%d", *req.ToYear)
+	}
+
+	return &dto.FileBundle{
+		FileName: bundleFileName(courseCode, yearRange, "past_exams"),
+		Entries:  builder.entries,
+	}, nil
+}
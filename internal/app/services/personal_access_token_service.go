@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	appAuth "github.com/yigit/unisphere/internal/app/auth"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/auth"
+)
+
+// maxActivePersonalAccessTokens is how many unrevoked, unexpired tokens a user can have
+const maxActivePersonalAccessTokens = 50
+
+// PersonalAccessTokenService manages the personal access tokens users create for scripts and
+// bots, and authenticates requests made with them
+type PersonalAccessTokenService interface {
+	ListTokens(ctx context.Context, userID int64) ([]dto.PersonalAccessTokenResponse, error)
+	CreateToken(ctx context.Context, userID int64, req *dto.CreatePersonalAccessTokenRequest) (*dto.CreatedPersonalAccessTokenResponse, error)
+	RevokeToken(ctx context.Context, userID, id int64) error
+	// Authenticate returns a usable token and its owner, and records that it was used. Tokens
+	// created before the owner's tokens were revoked, by signing out everywhere, a password
+	// change or a deactivation, no longer work.
+	Authenticate(ctx context.Context, token string) (*models.PersonalAccessToken, *models.User, error)
+}
+
+// personalAccessTokenServiceImpl implements PersonalAccessTokenService
+type personalAccessTokenServiceImpl struct {
+	tokenRepo        *repositories.PersonalAccessTokenRepository
+	userRepo         *repositories.UserRepository
+	revokedTokenRepo *repositories.RevokedTokenRepository
+	logger           zerolog.Logger
+}
+
+// NewPersonalAccessTokenService creates a new PersonalAccessTokenService
+func NewPersonalAccessTokenService(
+	tokenRepo *repositories.PersonalAccessTokenRepository,
+	userRepo *repositories.UserRepository,
+	revokedTokenRepo *repositories.RevokedTokenRepository,
+	logger zerolog.Logger,
+) PersonalAccessTokenService {
+	return &personalAccessTokenServiceImpl{
+		tokenRepo:        tokenRepo,
+		userRepo:         userRepo,
+		revokedTokenRepo: revokedTokenRepo,
+		logger:           logger,
+	}
+}
+
+// ListTokens returns the tokens of a user, including revoked and expired ones, newest first
+func (s *personalAccessTokenServiceImpl) ListTokens(ctx context.Context, userID int64) ([]dto.PersonalAccessTokenResponse, error) {
+	tokens, err := s.tokenRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff, err := s.revokedTokenRepo.GetTokensInvalidBefore(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	responses := make([]dto.PersonalAccessTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		response := toPersonalAccessTokenResponse(token, now)
+		if revokedByCutoff(token, cutoff) {
+			response.Active = false
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// CreateToken creates a token for a user. The response holds the token, which is stored only
+// as a hash and cannot be shown again.
+func (s *personalAccessTokenServiceImpl) CreateToken(ctx context.Context, userID int64, req *dto.CreatePersonalAccessTokenRequest) (*dto.CreatedPersonalAccessTokenResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, apperrors.NewBadRequestError("Token name cannot be empty")
+	}
+
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[string]bool, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !appAuth.IsTokenScope(scope) {
+			return nil, apperrors.NewBadRequestError(fmt.Sprintf("Unknown token scope %s", scope))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	active, err := s.tokenRepo.CountActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if active >= maxActivePersonalAccessTokens {
+		return nil, apperrors.NewConflictError(fmt.Sprintf("You already have %d active tokens; revoke one first", active))
+	}
+
+	token, hash, hint, err := auth.GeneratePersonalAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	pat := &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hash,
+		TokenHint: hint,
+		Scopes:    scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		pat.ExpiresAt = &expiresAt
+	}
+	if err := s.tokenRepo.Create(ctx, pat); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().Int64("userID", userID).Int64("tokenID", pat.ID).Strs("scopes", scopes).Msg("Personal access token created")
+	return &dto.CreatedPersonalAccessTokenResponse{
+		PersonalAccessTokenResponse: toPersonalAccessTokenResponse(pat, time.Now()),
+		Token:                       token,
+	}, nil
+}
+
+// RevokeToken revokes a token of a user. It stops working at once.
+func (s *personalAccessTokenServiceImpl) RevokeToken(ctx context.Context, userID, id int64) error {
+	if err := s.tokenRepo.Revoke(ctx, userID, id); err != nil {
+		if errors.Is(err, apperrors.ErrResourceNotFound) {
+			return apperrors.NewResourceNotFoundError("Personal access token not found or already revoked")
+		}
+		return err
+	}
+
+	s.logger.Info().Int64("userID", userID).Int64("tokenID", id).Msg("Personal access token revoked")
+	return nil
+}
+
+// Authenticate looks a token up by its hash and checks that it is usable and that its owner
+// is active. Roles are read with the user, so they apply without new tokens.
+func (s *personalAccessTokenServiceImpl) Authenticate(ctx context.Context, token string) (*models.PersonalAccessToken, *models.User, error) {
+	pat, err := s.tokenRepo.GetByHash(ctx, auth.HashPersonalAccessToken(token))
+	if err != nil {
+		return nil, nil, err
+	}
+	if pat.RevokedAt != nil {
+		return nil, nil, apperrors.ErrTokenRevoked
+	}
+	if !pat.Active(time.Now()) {
+		return nil, nil, apperrors.ErrTokenExpired
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, pat.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, apperrors.ErrAccountDisabled
+	}
+
+	cutoff, err := s.revokedTokenRepo.GetTokensInvalidBefore(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revokedByCutoff(pat, cutoff) {
+		return nil, nil, apperrors.ErrTokenRevoked
+	}
+
+	if err := s.tokenRepo.TouchLastUsed(ctx, pat.ID); err != nil {
+		s.logger.Warn().Err(err).Int64("tokenID", pat.ID).Msg("Failed to record personal access token use")
+	}
+	return pat, user, nil
+}
+
+// revokedByCutoff reports whether a token was created before its owner's tokens were revoked
+func revokedByCutoff(token *models.PersonalAccessToken, cutoff *time.Time) bool {
+	return cutoff != nil && !token.CreatedAt.After(*cutoff)
+}
+
+// toPersonalAccessTokenResponse converts a token to its response
+func toPersonalAccessTokenResponse(token *models.PersonalAccessToken, now time.Time) dto.PersonalAccessTokenResponse {
+	return dto.PersonalAccessTokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		TokenHint:  token.TokenHint,
+		Scopes:     token.Scopes,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		RevokedAt:  token.RevokedAt,
+		CreatedAt:  token.CreatedAt,
+		Active:     token.Active(now),
+	}
+}
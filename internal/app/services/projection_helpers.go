@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+)
+
+// departmentExpander resolves expand=department for a list response.
+// Each department is fetched once per request, however many items share it.
+type departmentExpander struct {
+	repo  *repositories.DepartmentRepository
+	cache map[int64]*dto.DepartmentResponse
+}
+
+func newDepartmentExpander(repo *repositories.DepartmentRepository) *departmentExpander {
+	return &departmentExpander{repo: repo, cache: make(map[int64]*dto.DepartmentResponse)}
+}
+
+// get returns the department response, or nil when the department no longer exists
+func (e *departmentExpander) get(ctx context.Context, id int64) (*dto.DepartmentResponse, error) {
+	if department, ok := e.cache[id]; ok {
+		return department, nil
+	}
+
+	department, err := e.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrDepartmentNotFound) {
+			e.cache[id] = nil
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	response := &dto.DepartmentResponse{
+		ID:        department.ID,
+		Name:      department.Name,
+		Code:      department.Code,
+		FacultyID: department.FacultyID,
+	}
+	e.cache[id] = response
+	return response, nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"mime/multipart"
+
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+)
+
+// StorageQuotas are storage limits in bytes; 0 means unlimited
+type StorageQuotas struct {
+	PerRole      map[models.RoleType]int64 // Files uploaded by one user, by the user's role
+	PerCommunity int64                     // Chat attachments of one community
+}
+
+// QuotaService computes storage usage from file sizes and enforces storage quotas
+type QuotaService interface {
+	CheckUserQuota(ctx context.Context, userID, size int64) error
+	CheckCommunityQuota(ctx context.Context, communityID, size int64) error
+	GetUserStorage(ctx context.Context, userID int64) (*dto.StorageUsageResponse, error)
+}
+
+// quotaServiceImpl implements QuotaService
+type quotaServiceImpl struct {
+	fileRepo *repositories.FileRepository
+	userRepo *repositories.UserRepository
+	quotas   StorageQuotas
+}
+
+// NewQuotaService creates a new QuotaService
+func NewQuotaService(
+	fileRepo *repositories.FileRepository,
+	userRepo *repositories.UserRepository,
+	quotas StorageQuotas,
+) QuotaService {
+	return &quotaServiceImpl{
+		fileRepo: fileRepo,
+		userRepo: userRepo,
+		quotas:   quotas,
+	}
+}
+
+// CheckUserQuota rejects size more bytes if they would take the user over their quota
+func (s *quotaServiceImpl) CheckUserQuota(ctx context.Context, userID, size int64) error {
+	quota, err := s.userQuota(ctx, userID)
+	if err != nil || quota == 0 {
+		return err
+	}
+
+	used, err := s.fileRepo.SumSizeByUploader(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return checkQuota("user", quota, used, size)
+}
+
+// CheckCommunityQuota rejects size more bytes of chat attachments if they would take the
+// community over its quota
+func (s *quotaServiceImpl) CheckCommunityQuota(ctx context.Context, communityID, size int64) error {
+	if s.quotas.PerCommunity == 0 {
+		return nil
+	}
+
+	used, err := s.fileRepo.SumSizeByResource(ctx, models.FileTypeChatMessage, communityID)
+	if err != nil {
+		return err
+	}
+	return checkQuota("community", s.quotas.PerCommunity, used, size)
+}
+
+// GetUserStorage returns the storage a user uses, by resource type, and their quota
+func (s *quotaServiceImpl) GetUserStorage(ctx context.Context, userID int64) (*dto.StorageUsageResponse, error) {
+	quota, err := s.userQuota(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := s.fileRepo.GetUsageByUploader(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.StorageUsageResponse{ByResourceType: []dto.ResourceStorageUsage{}}
+	for _, u := range usage {
+		response.UsedBytes += u.TotalSize
+		response.ByResourceType = append(response.ByResourceType, dto.ResourceStorageUsage{
+			ResourceType: string(u.ResourceType),
+			FileCount:    u.FileCount,
+			UsedBytes:    u.TotalSize,
+		})
+	}
+
+	if quota > 0 {
+		remaining := max(0, quota-response.UsedBytes)
+		response.QuotaBytes = &quota
+		response.RemainingBytes = &remaining
+	}
+	return response, nil
+}
+
+// userQuota returns the quota of the user's role
+func (s *quotaServiceImpl) userQuota(ctx context.Context, userID int64) (int64, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("error getting user: %w", err)
+	}
+	if user == nil {
+		return 0, apperrors.ErrUserNotFound
+	}
+	return s.quotas.PerRole[user.RoleType], nil
+}
+
+// checkQuota returns a validation error if used plus size bytes exceed quota
+func checkQuota(scope string, quota, used, size int64) error {
+	if used+size <= quota {
+		return nil
+	}
+
+	return apperrors.NewValidationError(string(dto.ErrorCodeStorageQuotaExceeded),
+		fmt.Sprintf("This upload would exceed the %s storage quota of %d MB (%d MB used)", scope, quota/megabyte, used/megabyte),
+		map[string]interface{}{
+			"scope":          scope,
+			"quotaBytes":     quota,
+			"usedBytes":      used,
+			"requestedBytes": size,
+		})
+}
+
+// uploadSize returns the total size of files
+func uploadSize(files []*multipart.FileHeader) int64 {
+	var size int64
+	for _, file := range files {
+		size += file.Size
+	}
+	return size
+}
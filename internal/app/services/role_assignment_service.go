@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/auth"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+)
+
+// RoleAssignmentService manages the roles granted to users within a scope. Permissions are
+// read from the database on every check, so assignments apply without new tokens.
+type RoleAssignmentService interface {
+	ListRoles() []dto.RoleResponse
+	ListAssignments(ctx context.Context, userID *int64) ([]dto.RoleAssignmentResponse, error)
+	CreateAssignment(ctx context.Context, adminID int64, req *dto.CreateRoleAssignmentRequest) (*dto.RoleAssignmentResponse, error)
+	DeleteAssignment(ctx context.Context, adminID, id int64) error
+}
+
+// roleAssignmentServiceImpl implements RoleAssignmentService
+type roleAssignmentServiceImpl struct {
+	roleAssignmentRepo *repositories.RoleAssignmentRepository
+	userRepo           *repositories.UserRepository
+	facultyRepo        *repositories.FacultyRepository
+	departmentRepo     *repositories.DepartmentRepository
+	communityRepo      *repositories.CommunityRepository
+	logger             zerolog.Logger
+}
+
+// NewRoleAssignmentService creates a new RoleAssignmentService
+func NewRoleAssignmentService(
+	roleAssignmentRepo *repositories.RoleAssignmentRepository,
+	userRepo *repositories.UserRepository,
+	facultyRepo *repositories.FacultyRepository,
+	departmentRepo *repositories.DepartmentRepository,
+	communityRepo *repositories.CommunityRepository,
+	logger zerolog.Logger,
+) RoleAssignmentService {
+	return &roleAssignmentServiceImpl{
+		roleAssignmentRepo: roleAssignmentRepo,
+		userRepo:           userRepo,
+		facultyRepo:        facultyRepo,
+		departmentRepo:     departmentRepo,
+		communityRepo:      communityRepo,
+		logger:             logger,
+	}
+}
+
+// ListRoles returns every role with its permissions and the scopes it can be assigned in
+func (s *roleAssignmentServiceImpl) ListRoles() []dto.RoleResponse {
+	definitions := auth.RoleDefinitions()
+	roles := make([]dto.RoleResponse, 0, len(definitions))
+	for _, definition := range definitions {
+		permissions := make([]string, 0, len(definition.Permissions))
+		for _, permission := range definition.Permissions {
+			permissions = append(permissions, string(permission))
+		}
+		roles = append(roles, dto.RoleResponse{
+			Role:             string(definition.Role),
+			Permissions:      permissions,
+			AssignableScopes: append([]string{}, definition.Scopes...),
+		})
+	}
+	return roles
+}
+
+// ListAssignments returns the role assignments of a user, or of every user if userID is nil
+func (s *roleAssignmentServiceImpl) ListAssignments(ctx context.Context, userID *int64) ([]dto.RoleAssignmentResponse, error) {
+	assignments, err := s.roleAssignmentRepo.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.RoleAssignmentResponse, 0, len(assignments))
+	for _, assignment := range assignments {
+		responses = append(responses, toRoleAssignmentResponse(assignment))
+	}
+	return responses, nil
+}
+
+// CreateAssignment grants a user a role within a scope, after checking that the role can be
+// assigned there and that the user and scope exist
+func (s *roleAssignmentServiceImpl) CreateAssignment(ctx context.Context, adminID int64, req *dto.CreateRoleAssignmentRequest) (*dto.RoleAssignmentResponse, error) {
+	role := models.RoleType(req.Role)
+	definition, ok := auth.LookupRole(role)
+	if !ok {
+		return nil, apperrors.NewBadRequestError(fmt.Sprintf("Unknown role %s", req.Role))
+	}
+	if len(definition.Scopes) == 0 {
+		return nil, apperrors.NewBadRequestError(fmt.Sprintf("Role %s cannot be assigned", req.Role))
+	}
+	if !definition.AssignableIn(req.ScopeType) {
+		return nil, apperrors.NewBadRequestError(fmt.Sprintf("Role %s cannot be assigned in a %s scope", req.Role, req.ScopeType))
+	}
+
+	if err := s.checkScope(ctx, req.ScopeType, req.ScopeID); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.userRepo.GetUserByID(ctx, req.UserID); err != nil {
+		return nil, err
+	}
+
+	assignment := &models.RoleAssignment{
+		UserID:    req.UserID,
+		Role:      role,
+		ScopeType: req.ScopeType,
+		ScopeID:   req.ScopeID,
+		GrantedBy: &adminID,
+	}
+	if err := s.roleAssignmentRepo.Create(ctx, assignment); err != nil {
+		if errors.Is(err, apperrors.ErrResourceAlreadyExists) {
+			return nil, apperrors.NewConflictError("The user already has this role in this scope")
+		}
+		return nil, err
+	}
+
+	s.logger.Info().
+		Int64("userID", req.UserID).
+		Int64("adminID", adminID).
+		Str("role", req.Role).
+		Str("scopeType", req.ScopeType).
+		Msg("Role assigned")
+
+	response := toRoleAssignmentResponse(assignment)
+	return &response, nil
+}
+
+// DeleteAssignment removes a role assignment
+func (s *roleAssignmentServiceImpl) DeleteAssignment(ctx context.Context, adminID, id int64) error {
+	assignment, err := s.roleAssignmentRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrResourceNotFound) {
+			return apperrors.NewResourceNotFoundError("Role assignment not found")
+		}
+		return err
+	}
+
+	if err := s.roleAssignmentRepo.Delete(ctx, id); err != nil {
+		if errors.Is(err, apperrors.ErrResourceNotFound) {
+			return apperrors.NewResourceNotFoundError("Role assignment not found")
+		}
+		return err
+	}
+
+	s.logger.Info().
+		Int64("userID", assignment.UserID).
+		Int64("adminID", adminID).
+		Str("role", string(assignment.Role)).
+		Str("scopeType", assignment.ScopeType).
+		Msg("Role assignment removed")
+	return nil
+}
+
+// checkScope checks that a scope ID is given exactly when the scope is not global, and that
+// the faculty, department or community it names exists
+func (s *roleAssignmentServiceImpl) checkScope(ctx context.Context, scopeType string, scopeID *int64) error {
+	if scopeType == models.ScopeGlobal {
+		if scopeID != nil {
+			return apperrors.NewBadRequestError("A global role assignment cannot have a scope ID")
+		}
+		return nil
+	}
+	if scopeID == nil {
+		return apperrors.NewBadRequestError(fmt.Sprintf("A %s role assignment needs a scope ID", scopeType))
+	}
+
+	switch scopeType {
+	case models.ScopeFaculty:
+		if _, err := s.facultyRepo.GetFacultyByID(ctx, *scopeID); err != nil {
+			return err
+		}
+	case models.ScopeDepartment:
+		if _, err := s.departmentRepo.GetByID(ctx, *scopeID); err != nil {
+			return err
+		}
+	case models.ScopeCommunity:
+		community, err := s.communityRepo.GetByID(ctx, *scopeID)
+		if err != nil || community == nil {
+			return apperrors.NewResourceNotFoundError("Community not found")
+		}
+	}
+	return nil
+}
+
+// toRoleAssignmentResponse converts a role assignment to its response
+func toRoleAssignmentResponse(assignment *models.RoleAssignment) dto.RoleAssignmentResponse {
+	return dto.RoleAssignmentResponse{
+		ID:        assignment.ID,
+		UserID:    assignment.UserID,
+		Role:      string(assignment.Role),
+		ScopeType: assignment.ScopeType,
+		ScopeID:   assignment.ScopeID,
+		GrantedBy: assignment.GrantedBy,
+		CreatedAt: assignment.CreatedAt,
+	}
+}
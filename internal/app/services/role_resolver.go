@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/yigit/unisphere/internal/app/models"
+)
+
+// Role resolution strategies, selected per deployment with registration.role_strategy
+const (
+	RoleStrategyStudentNumber = "student_number"
+	RoleStrategyStudentDomain = "student_domain"
+)
+
+// RoleResolver picks the role a new account registers with. It only ever picks STUDENT or
+// PENDING_INSTRUCTOR: instructors are confirmed by an admin, never by their email address.
+type RoleResolver interface {
+	ResolveRole(email string) models.RoleType
+}
+
+// NewRoleResolver creates the RoleResolver for a strategy. studentDomains is only used by
+// RoleStrategyStudentDomain.
+func NewRoleResolver(strategy string, studentDomains []string) (RoleResolver, error) {
+	switch strategy {
+	case RoleStrategyStudentNumber:
+		return studentNumberRoleResolver{}, nil
+	case RoleStrategyStudentDomain:
+		domains := make(map[string]bool, len(studentDomains))
+		for _, domain := range studentDomains {
+			if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+				domains[domain] = true
+			}
+		}
+		if len(domains) == 0 {
+			return nil, fmt.Errorf("role strategy %s needs at least one student domain", strategy)
+		}
+		return studentDomainRoleResolver{domains: domains}, nil
+	default:
+		return nil, fmt.Errorf("unknown role strategy: %s", strategy)
+	}
+}
+
+// studentNumberRoleResolver treats addresses whose local part is an 's' followed by digits,
+// the student number (e.g. s200201027@school.edu.tr), as students
+type studentNumberRoleResolver struct{}
+
+// ResolveRole implements RoleResolver
+func (studentNumberRoleResolver) ResolveRole(email string) models.RoleType {
+	localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+	if len(localPart) < 2 || localPart[0] != 's' {
+		return models.RolePendingInstructor
+	}
+	for _, char := range localPart[1:] {
+		if !unicode.IsDigit(char) {
+			return models.RolePendingInstructor
+		}
+	}
+	return models.RoleStudent
+}
+
+// studentDomainRoleResolver treats addresses at the configured domains as students, for
+// universities that give students their own mail domain (e.g. ogr.school.edu.tr)
+type studentDomainRoleResolver struct {
+	domains map[string]bool
+}
+
+// ResolveRole implements RoleResolver
+func (r studentDomainRoleResolver) ResolveRole(email string) models.RoleType {
+	at := strings.LastIndex(email, "@")
+	if at >= 0 && r.domains[strings.ToLower(email[at+1:])] {
+		return models.RoleStudent
+	}
+	return models.RolePendingInstructor
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/email"
+	"github.com/yigit/unisphere/internal/pkg/filestorage"
+	"github.com/yigit/unisphere/internal/pkg/scanner"
+)
+
+const (
+	// quarantineDir holds infected files, away from the paths their URLs pointed at
+	quarantineDir = "quarantine"
+	// scanSweepInterval is how often pending files that missed the queue are picked up,
+	// e.g. after a restart or while clamd was unreachable
+	scanSweepInterval = time.Minute
+	scanBatchSize     = 100
+	// maxQuarantineList caps how many quarantined files the admin list returns
+	maxQuarantineList = 1000
+)
+
+// ScanService scans stored files for malware. New files are PENDING and cannot be
+// downloaded until a scan finds them CLEAN; INFECTED files are quarantined and reported to admins.
+type ScanService interface {
+	// Enqueue schedules the scan of a file that was just stored
+	Enqueue(ctx context.Context, fileID int64)
+	// Run scans queued and pending files; it runs until the process exits
+	Run()
+	GetQuarantinedFiles(ctx context.Context) ([]dto.QuarantinedFileResponse, error)
+	DeleteQuarantinedFile(ctx context.Context, fileID int64) error
+}
+
+// scanServiceImpl implements ScanService
+type scanServiceImpl struct {
+	fileRepo     *repositories.FileRepository
+	userRepo     *repositories.UserRepository
+	fileStorage  filestorage.FileStorage
+	scanner      scanner.Scanner // nil when scanning is disabled; files are marked clean unscanned
+	emailService email.EmailService
+	queue        chan int64
+	logger       zerolog.Logger
+}
+
+// NewScanService creates a new ScanService. A nil fileScanner disables scanning.
+func NewScanService(
+	fileRepo *repositories.FileRepository,
+	userRepo *repositories.UserRepository,
+	fileStorage filestorage.FileStorage,
+	fileScanner scanner.Scanner,
+	emailService email.EmailService,
+	logger zerolog.Logger,
+) ScanService {
+	return &scanServiceImpl{
+		fileRepo:     fileRepo,
+		userRepo:     userRepo,
+		fileStorage:  fileStorage,
+		scanner:      fileScanner,
+		emailService: emailService,
+		queue:        make(chan int64, scanBatchSize),
+		logger:       logger,
+	}
+}
+
+// Enqueue schedules the scan of a file that was just stored. Without a scanner the file
+// is marked clean right away, so it can be downloaded as soon as the upload returns.
+func (s *scanServiceImpl) Enqueue(ctx context.Context, fileID int64) {
+	if s.scanner == nil {
+		if err := s.fileRepo.SetScanStatus(ctx, fileID, models.ScanStatusClean, nil); err != nil {
+			s.logger.Error().Err(err).Int64("fileID", fileID).Msg("Failed to mark file clean")
+		}
+		return
+	}
+
+	select {
+	case s.queue <- fileID:
+	default:
+		// The queue is full; the next sweep picks the file up
+	}
+}
+
+// Run scans queued files as they arrive and sweeps up pending ones every scanSweepInterval
+func (s *scanServiceImpl) Run() {
+	ticker := time.NewTicker(scanSweepInterval)
+	defer ticker.Stop()
+
+	s.sweep()
+	for {
+		select {
+		case fileID := <-s.queue:
+			file, err := s.fileRepo.GetByID(context.Background(), fileID)
+			if err != nil {
+				s.logger.Error().Err(err).Int64("fileID", fileID).Msg("Failed to load file for scanning")
+				continue
+			}
+			if err := s.scanFile(context.Background(), file); err != nil {
+				s.logger.Error().Err(err).Int64("fileID", fileID).Msg("Malware scan failed; will retry")
+			}
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
+
+// sweep scans every pending file. It stops at the first failing scan, since that usually
+// means the scanner is unreachable; files that cannot be read are skipped.
+func (s *scanServiceImpl) sweep() {
+	ctx := context.Background()
+
+	var lastID int64
+	for {
+		files, err := s.fileRepo.ListByScanStatus(ctx, models.ScanStatusPending, lastID, scanBatchSize)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("Failed to list files pending a malware scan")
+			return
+		}
+
+		for _, file := range files {
+			lastID = file.ID
+			if err := s.scanFile(ctx, file); err != nil {
+				s.logger.Error().Err(err).Int64("fileID", file.ID).Msg("Malware scan failed; will retry")
+				if !errors.Is(err, errUnreadableFile) {
+					return
+				}
+			}
+		}
+		if len(files) < scanBatchSize {
+			return
+		}
+	}
+}
+
+// errUnreadableFile marks scan failures caused by the stored file rather than the scanner
+var errUnreadableFile = errors.New("stored file cannot be read")
+
+// scanFile scans a pending file and records the verdict
+func (s *scanServiceImpl) scanFile(ctx context.Context, file *models.File) error {
+	if file.ScanStatus != models.ScanStatusPending {
+		return nil
+	}
+	if s.scanner == nil {
+		return s.fileRepo.SetScanStatus(ctx, file.ID, models.ScanStatusClean, nil)
+	}
+
+	src, err := s.fileStorage.OpenFile(file.FilePath)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUnreadableFile, err)
+	}
+	result, err := s.scanner.Scan(ctx, src)
+	src.Close()
+	if err != nil {
+		return err
+	}
+
+	if !result.Infected {
+		s.logger.Debug().Int64("fileID", file.ID).Msg("File is clean")
+		return s.fileRepo.SetScanStatus(ctx, file.ID, models.ScanStatusClean, nil)
+	}
+
+	// Block downloads first, then move the content out of reach
+	if err := s.fileRepo.SetScanStatus(ctx, file.ID, models.ScanStatusInfected, &result.Signature); err != nil {
+		return err
+	}
+	s.logger.Warn().
+		Int64("fileID", file.ID).
+		Str("signature", result.Signature).
+		Str("resourceType", string(file.ResourceType)).
+		Int64("resourceID", file.ResourceID).
+		Int64("uploadedBy", file.UploadedBy).
+		Msg("Malware found in upload; quarantining file")
+
+	if err := s.quarantine(ctx, file); err != nil {
+		// The file stays where it was, but it cannot be downloaded
+		s.logger.Error().Err(err).Int64("fileID", file.ID).Msg("Failed to move infected file to quarantine")
+	}
+	s.notifyAdmins(ctx, file, result.Signature)
+	return nil
+}
+
+// quarantine moves an infected file to the quarantine directory and deletes its thumbnails
+func (s *scanServiceImpl) quarantine(ctx context.Context, file *models.File) error {
+	src, err := s.fileStorage.OpenFile(file.FilePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	quarantinePath := path.Join(quarantineDir, fmt.Sprintf("%d_%s", file.ID, path.Base(file.FilePath)))
+	fileURL, err := s.fileStorage.SaveObject(src, file.FileSize, quarantinePath, "application/octet-stream")
+	if err != nil {
+		return err
+	}
+
+	relativeFilePath := strings.TrimPrefix(fileURL, s.fileStorage.GetBaseURL())
+	if err := s.fileRepo.UpdateLocation(ctx, file.ID, relativeFilePath, fileURL); err != nil {
+		_ = s.fileStorage.DeleteFile(relativeFilePath)
+		return err
+	}
+
+	if err := s.fileStorage.DeleteFile(file.FilePath); err != nil {
+		s.logger.Warn().Err(err).Int64("fileID", file.ID).Msg("Failed to delete infected file after quarantining it")
+	}
+	for _, size := range file.ThumbnailSizes {
+		_ = s.fileStorage.DeleteFile(file.ThumbnailPath(size))
+	}
+	if len(file.ThumbnailSizes) > 0 {
+		return s.fileRepo.SetThumbnailSizes(ctx, file.ID, nil)
+	}
+	return nil
+}
+
+// notifyAdmins emails every active admin about a quarantined file
+func (s *scanServiceImpl) notifyAdmins(ctx context.Context, file *models.File, signature string) {
+	admins, err := s.userRepo.FindActiveByRole(ctx, models.RoleAdmin)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to load admins for malware alert")
+		return
+	}
+
+	alert := email.MalwareAlert{
+		FileID:       file.ID,
+		FileName:     file.FileName,
+		Signature:    signature,
+		ResourceType: string(file.ResourceType),
+		ResourceID:   file.ResourceID,
+		UploadedBy:   file.UploadedBy,
+	}
+	for _, admin := range admins {
+		name := admin.FirstName + " " + admin.LastName
+		if err := s.emailService.SendMalwareAlertEmail(admin.Email, name, alert); err != nil {
+			s.logger.Error().Err(err).Int64("adminID", admin.ID).Msg("Failed to send malware alert")
+		}
+	}
+}
+
+// GetQuarantinedFiles lists infected files, oldest first
+func (s *scanServiceImpl) GetQuarantinedFiles(ctx context.Context) ([]dto.QuarantinedFileResponse, error) {
+	files, err := s.fileRepo.ListByScanStatus(ctx, models.ScanStatusInfected, 0, maxQuarantineList)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.QuarantinedFileResponse, len(files))
+	for i, file := range files {
+		response[i] = dto.NewQuarantinedFileResponse(file)
+	}
+	return response, nil
+}
+
+// DeleteQuarantinedFile deletes an infected file from storage and the database
+func (s *scanServiceImpl) DeleteQuarantinedFile(ctx context.Context, fileID int64) error {
+	file, err := s.fileRepo.GetByID(ctx, fileID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrResourceNotFound) {
+			return apperrors.NewResourceNotFoundError("File not found")
+		}
+		return err
+	}
+	if file.ScanStatus != models.ScanStatusInfected {
+		return apperrors.NewResourceNotFoundError("File is not quarantined")
+	}
+
+	if err := s.fileRepo.Delete(ctx, fileID); err != nil {
+		return err
+	}
+	if err := s.fileStorage.DeleteFile(file.FilePath); err != nil {
+		s.logger.Warn().Err(err).Int64("fileID", fileID).Msg("Failed to delete quarantined file from storage")
+	}
+
+	s.logger.Info().Int64("fileID", fileID).Msg("Quarantined file deleted")
+	return nil
+}
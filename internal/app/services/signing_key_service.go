@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/auth"
+)
+
+const (
+	// signingKeyRefreshInterval is how often keys are reloaded and rotation is checked
+	signingKeyRefreshInterval = 5 * time.Minute
+	// signingKeyPublishLead is how long a new key is published before tokens are signed with
+	// it, so every instance and JWKS consumer knows it by the time it shows up in a token
+	signingKeyPublishLead = 3 * signingKeyRefreshInterval
+)
+
+// SigningKeyService rotates the key pairs access tokens are signed with when the JWT
+// algorithm is RS256 or EdDSA. Keys are stored encrypted in Postgres and shared by all instances.
+type SigningKeyService interface {
+	// Load rotates the key if it is due and fills the key set; call it before serving requests
+	Load(ctx context.Context) error
+	// Run repeats Load every signingKeyRefreshInterval; it runs until the process exits
+	Run()
+}
+
+// signingKeyServiceImpl implements SigningKeyService
+type signingKeyServiceImpl struct {
+	signingKeyRepo   *repositories.SigningKeyRepository
+	keys             *auth.KeySet
+	cipher           *auth.SecretCipher
+	algorithm        string
+	rotationInterval time.Duration
+	gracePeriod      time.Duration
+	logger           zerolog.Logger
+}
+
+// NewSigningKeyService creates a new SigningKeyService. A new key is created every
+// rotationInterval; a replaced key keeps verifying tokens for gracePeriod, which has to be
+// at least the access token lifetime.
+func NewSigningKeyService(
+	signingKeyRepo *repositories.SigningKeyRepository,
+	keys *auth.KeySet,
+	cipher *auth.SecretCipher,
+	algorithm string,
+	rotationInterval time.Duration,
+	gracePeriod time.Duration,
+	logger zerolog.Logger,
+) SigningKeyService {
+	return &signingKeyServiceImpl{
+		signingKeyRepo:   signingKeyRepo,
+		keys:             keys,
+		cipher:           cipher,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		gracePeriod:      gracePeriod,
+		logger:           logger,
+	}
+}
+
+// Load rotates the key if it is due and fills the key set
+func (s *signingKeyServiceImpl) Load(ctx context.Context) error {
+	stored, err := s.signingKeyRepo.List(ctx)
+	if err != nil {
+		return err
+	}
+	if s.rotationDue(stored) {
+		created, err := s.rotate(ctx)
+		if err != nil {
+			return err
+		}
+		if created {
+			if stored, err = s.signingKeyRepo.List(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	keys := make([]*auth.SigningKey, 0, len(stored))
+	for _, row := range stored {
+		key, err := s.decodeKey(row)
+		if err != nil {
+			s.logger.Error().Err(err).Str("kid", row.KID).Msg("Skipping unreadable signing key")
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	signing, verification, expired := selectSigningKeys(keys, time.Now(), s.gracePeriod)
+	if signing == nil {
+		s.logger.Error().Msg("No usable JWT signing key; access tokens cannot be issued")
+	}
+	s.keys.Set(signing, verification)
+
+	if err := s.signingKeyRepo.Delete(ctx, expired); err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to delete expired signing keys")
+	} else if len(expired) > 0 {
+		s.logger.Info().Strs("kids", expired).Msg("Expired signing keys deleted")
+	}
+	return nil
+}
+
+// selectSigningKeys picks, from keys oldest first, the key to sign with, the keys tokens are
+// still accepted from and the kids of the expired keys. A key is replaced once its successor
+// has been published for signingKeyPublishLead, and is dropped gracePeriod after that.
+func selectSigningKeys(keys []*auth.SigningKey, now time.Time, gracePeriod time.Duration) (signing *auth.SigningKey, verification []*auth.SigningKey, expired []string) {
+	for i, key := range keys {
+		if i+1 < len(keys) && now.After(keys[i+1].CreatedAt.Add(signingKeyPublishLead+gracePeriod)) {
+			expired = append(expired, key.ID)
+			continue
+		}
+		verification = append(verification, key)
+		if signing == nil || now.Sub(key.CreatedAt) >= signingKeyPublishLead {
+			signing = key
+		}
+	}
+	return signing, verification, expired
+}
+
+// Run repeats Load every signingKeyRefreshInterval
+func (s *signingKeyServiceImpl) Run() {
+	ticker := time.NewTicker(signingKeyRefreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.Load(context.Background()); err != nil {
+			s.logger.Error().Err(err).Msg("Failed to refresh JWT signing keys")
+		}
+	}
+}
+
+// rotationDue reports whether no key of the configured algorithm was created within the
+// rotation interval. Changing the algorithm therefore rotates right away.
+func (s *signingKeyServiceImpl) rotationDue(stored []models.JWTSigningKey) bool {
+	dueBefore := time.Now().Add(-s.rotationInterval)
+	for _, key := range stored {
+		if key.Algorithm == s.algorithm && key.CreatedAt.After(dueBefore) {
+			return false
+		}
+	}
+	return true
+}
+
+// rotate creates a key for the configured algorithm. It reports false when another instance
+// created one first.
+func (s *signingKeyServiceImpl) rotate(ctx context.Context) (bool, error) {
+	key, err := auth.GenerateSigningKey(s.algorithm)
+	if err != nil {
+		return false, err
+	}
+	privateKey, err := key.EncodePrivateKey()
+	if err != nil {
+		return false, err
+	}
+	encrypted, err := s.cipher.Encrypt(privateKey)
+	if err != nil {
+		return false, err
+	}
+	publicKey, err := key.EncodePublicKey()
+	if err != nil {
+		return false, err
+	}
+
+	created, err := s.signingKeyRepo.CreateIfDue(ctx, &models.JWTSigningKey{
+		KID:        key.ID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: encrypted,
+		PublicKey:  publicKey,
+		CreatedAt:  key.CreatedAt,
+	}, key.CreatedAt.Add(-s.rotationInterval))
+	if err != nil {
+		return false, err
+	}
+	if created {
+		s.logger.Info().Str("kid", key.ID).Str("algorithm", key.Algorithm).Msg("New JWT signing key created")
+	}
+	return created, nil
+}
+
+// decodeKey decrypts and parses a stored key
+func (s *signingKeyServiceImpl) decodeKey(row models.JWTSigningKey) (*auth.SigningKey, error) {
+	privateKey, err := s.cipher.Decrypt(row.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return auth.ParseSigningKey(row.Algorithm, privateKey, row.CreatedAt)
+}
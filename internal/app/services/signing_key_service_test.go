@@ -0,0 +1,93 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/pkg/auth"
+)
+
+func TestSelectSigningKeys(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	gracePeriod := time.Hour
+
+	// key returns a key created age ago
+	key := func(id string, age time.Duration) *auth.SigningKey {
+		return &auth.SigningKey{ID: id, Algorithm: auth.AlgorithmEdDSA, CreatedAt: now.Add(-age)}
+	}
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name             string
+		keys             []*auth.SigningKey
+		wantSigning      string
+		wantVerification string
+		wantExpired      string
+	}{
+		{"none", nil, "", "", ""},
+		{"first key signs right away", []*auth.SigningKey{key("a", time.Minute)}, "a", "a", ""},
+		{"new key is published first", []*auth.SigningKey{key("a", 30*day), key("b", time.Minute)}, "a", "a,b", ""},
+		{"new key signs after the lead", []*auth.SigningKey{key("a", 30*day), key("b", signingKeyPublishLead)}, "b", "a,b", ""},
+		{"replaced key verifies during the grace period", []*auth.SigningKey{key("a", 30*day), key("b", signingKeyPublishLead+gracePeriod-time.Minute)}, "b", "a,b", ""},
+		{"replaced key expires after the grace period", []*auth.SigningKey{key("a", 30*day), key("b", signingKeyPublishLead+gracePeriod+time.Minute)}, "b", "b", "a"},
+		{
+			"rotation in progress",
+			[]*auth.SigningKey{key("a", 60*day), key("b", 30*day), key("c", time.Minute)},
+			"b", "b,c", "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signing, verification, expired := selectSigningKeys(tt.keys, now, gracePeriod)
+
+			gotSigning := ""
+			if signing != nil {
+				gotSigning = signing.ID
+			}
+			var verificationIDs []string
+			for _, k := range verification {
+				verificationIDs = append(verificationIDs, k.ID)
+			}
+
+			if gotSigning != tt.wantSigning {
+				t.Errorf("signing key = %q, want %q", gotSigning, tt.wantSigning)
+			}
+			if got := strings.Join(verificationIDs, ","); got != tt.wantVerification {
+				t.Errorf("verification keys = %q, want %q", got, tt.wantVerification)
+			}
+			if got := strings.Join(expired, ","); got != tt.wantExpired {
+				t.Errorf("expired keys = %q, want %q", got, tt.wantExpired)
+			}
+		})
+	}
+}
+
+func TestRotationDue(t *testing.T) {
+	s := &signingKeyServiceImpl{algorithm: auth.AlgorithmEdDSA, rotationInterval: 30 * 24 * time.Hour}
+	stored := func(algorithm string, age time.Duration) models.JWTSigningKey {
+		return models.JWTSigningKey{Algorithm: algorithm, CreatedAt: time.Now().Add(-age)}
+	}
+
+	tests := []struct {
+		name   string
+		stored []models.JWTSigningKey
+		want   bool
+	}{
+		{"no keys", nil, true},
+		{"recent key", []models.JWTSigningKey{stored(auth.AlgorithmEdDSA, time.Hour)}, false},
+		{"old key", []models.JWTSigningKey{stored(auth.AlgorithmEdDSA, 31*24*time.Hour)}, true},
+		{"recent key of another algorithm", []models.JWTSigningKey{stored(auth.AlgorithmRS256, time.Hour)}, true},
+		{"old and recent keys", []models.JWTSigningKey{stored(auth.AlgorithmEdDSA, 31*24*time.Hour), stored(auth.AlgorithmEdDSA, time.Hour)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.rotationDue(tt.stored); got != tt.want {
+				t.Errorf("rotationDue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/oidc"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SSOClaims names the claims user fields are read from. Dotted names reach into nested claims.
+type SSOClaims struct {
+	Email         string
+	FirstName     string
+	LastName      string
+	Department    string // Department code; empty leaves the department of new users unset
+	StudentNumber string // Users with a student number become students; empty uses the role resolver
+}
+
+// SSOConfig configures single sign-on
+type SSOConfig struct {
+	StateTTL             time.Duration // Time to finish a login at the provider
+	AllowUnverifiedEmail bool          // Link and provision accounts whose email the provider does not mark verified
+	Claims               SSOClaims
+}
+
+// SSOService logs users in through an OpenID Connect provider with the authorization code
+// flow and PKCE. Users are found by their linked identity, linked by email on their first
+// login, or provisioned.
+type SSOService interface {
+	AuthorizationURL(ctx context.Context) (*dto.SSOAuthorizationResponse, error)
+	Login(ctx context.Context, req *dto.SSOCallbackRequest, client *dto.ClientInfo) (*dto.LoginResponse, error)
+}
+
+// ssoServiceImpl implements SSOService
+type ssoServiceImpl struct {
+	provider                      *oidc.Provider
+	config                        SSOConfig
+	loginStateRepo                *repositories.SSOLoginStateRepository
+	identityRepo                  *repositories.UserIdentityRepository
+	userRepo                      *repositories.UserRepository
+	departmentRepo                *repositories.DepartmentRepository
+	instructorVerificationService InstructorVerificationService
+	roleResolver                  RoleResolver
+	authService                   AuthService
+	logger                        zerolog.Logger
+}
+
+// NewSSOService creates a new SSOService. provider is nil when single sign-on is disabled.
+func NewSSOService(
+	provider *oidc.Provider,
+	config SSOConfig,
+	loginStateRepo *repositories.SSOLoginStateRepository,
+	identityRepo *repositories.UserIdentityRepository,
+	userRepo *repositories.UserRepository,
+	departmentRepo *repositories.DepartmentRepository,
+	instructorVerificationService InstructorVerificationService,
+	roleResolver RoleResolver,
+	authService AuthService,
+	logger zerolog.Logger,
+) SSOService {
+	return &ssoServiceImpl{
+		provider:                      provider,
+		config:                        config,
+		loginStateRepo:                loginStateRepo,
+		identityRepo:                  identityRepo,
+		userRepo:                      userRepo,
+		departmentRepo:                departmentRepo,
+		instructorVerificationService: instructorVerificationService,
+		roleResolver:                  roleResolver,
+		authService:                   authService,
+		logger:                        logger,
+	}
+}
+
+// errSSODisabled is returned while no provider is configured
+var errSSODisabled = apperrors.NewResourceNotFoundError("Single sign-on is not enabled")
+
+// AuthorizationURL starts a login: it stores a new state with its nonce and PKCE code verifier
+// and returns the provider URL to send the user to
+func (s *ssoServiceImpl) AuthorizationURL(ctx context.Context) (*dto.SSOAuthorizationResponse, error) {
+	if s.provider == nil {
+		return nil, errSSODisabled
+	}
+
+	loginState := &models.SSOLoginState{ExpiresAt: time.Now().Add(s.config.StateTTL)}
+	for _, token := range []*string{&loginState.State, &loginState.Nonce, &loginState.CodeVerifier} {
+		value, err := oidc.RandomToken()
+		if err != nil {
+			return nil, err
+		}
+		*token = value
+	}
+
+	authorizationURL, err := s.provider.AuthCodeURL(ctx, loginState.State, loginState.Nonce, oidc.CodeChallenge(loginState.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("error building single sign-on URL: %w", err)
+	}
+	if err := s.loginStateRepo.Create(ctx, loginState); err != nil {
+		return nil, err
+	}
+
+	return &dto.SSOAuthorizationResponse{
+		AuthorizationURL: authorizationURL,
+		State:            loginState.State,
+		ExpiresIn:        int64(s.config.StateTTL.Seconds()),
+	}, nil
+}
+
+// Login completes a login with the code the provider redirected back with. Users with
+// two-factor authentication still get an MFA challenge.
+func (s *ssoServiceImpl) Login(ctx context.Context, req *dto.SSOCallbackRequest, client *dto.ClientInfo) (*dto.LoginResponse, error) {
+	if s.provider == nil {
+		return nil, errSSODisabled
+	}
+
+	// The state is single use, so a code can be redeemed only by the login that asked for it
+	loginState, err := s.loginStateRepo.Consume(ctx, req.State)
+	if err != nil {
+		return nil, err
+	}
+	if loginState == nil {
+		return nil, apperrors.NewBadRequestError("Single sign-on state is invalid or has expired; start the login again")
+	}
+
+	tokens, err := s.provider.Exchange(ctx, req.Code, loginState.CodeVerifier)
+	if err != nil {
+		if errors.Is(err, oidc.ErrCodeRejected) {
+			return nil, apperrors.ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("error exchanging single sign-on code: %w", err)
+	}
+	claims, err := s.provider.VerifyIDToken(ctx, tokens.IDToken, loginState.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrInvalidIDToken) {
+			s.logger.Warn().Err(err).Msg("Rejected ID token from single sign-on provider")
+			return nil, apperrors.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	s.mergeUserInfo(ctx, claims, tokens.AccessToken)
+
+	user, err := s.resolveUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	client.DeviceLabel = req.DeviceLabel
+	return s.authService.StartSession(ctx, user, client)
+}
+
+// mergeUserInfo fills in claims the ID token left out from the userinfo endpoint. Providers
+// that put only the subject in ID tokens keep the profile there.
+func (s *ssoServiceImpl) mergeUserInfo(ctx context.Context, claims oidc.Claims, accessToken string) {
+	if accessToken == "" {
+		return
+	}
+	userInfo, err := s.provider.UserInfo(ctx, accessToken)
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to fetch single sign-on userinfo; using ID token claims only")
+		return
+	}
+	// Userinfo for another subject must not be mixed in
+	if userInfo == nil || userInfo.String("sub") != claims.String("sub") {
+		return
+	}
+	for name, value := range userInfo {
+		if _, ok := claims[name]; !ok {
+			claims[name] = value
+		}
+	}
+}
+
+// resolveUser returns the user an identity logs in to: the linked user, the user with the same
+// email, which is linked now, or a new user
+func (s *ssoServiceImpl) resolveUser(ctx context.Context, claims oidc.Claims) (*models.User, error) {
+	issuer, subject := s.provider.Issuer(), claims.String("sub")
+
+	userID, err := s.identityRepo.GetUserID(ctx, issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+	if userID != 0 {
+		if err := s.identityRepo.TouchLogin(ctx, issuer, subject); err != nil {
+			s.logger.Warn().Err(err).Int64("userID", userID).Msg("Failed to record single sign-on login")
+		}
+		return s.userRepo.GetUserByID(ctx, userID)
+	}
+
+	// Accounts are matched by email, so only emails the provider vouches for are used
+	email := claims.String(s.config.Claims.Email)
+	if email == "" {
+		return nil, apperrors.NewBadRequestError("Single sign-on provider did not return an email address")
+	}
+	verified, _ := claims.Bool("email_verified")
+	if !verified && !s.config.AllowUnverifiedEmail {
+		return nil, apperrors.NewForbiddenError("Single sign-on provider has not verified your email address")
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, apperrors.ErrUserNotFound) {
+		return nil, err
+	}
+	if user != nil {
+		if err := s.linkUser(ctx, user, issuer, verified); err != nil {
+			return nil, err
+		}
+	} else {
+		if user, err = s.provisionUser(ctx, email, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	identity := &models.UserIdentity{UserID: user.ID, Issuer: issuer, Subject: subject}
+	if err := s.identityRepo.Create(ctx, identity); err != nil {
+		if errors.Is(err, apperrors.ErrResourceAlreadyExists) {
+			return nil, apperrors.NewConflictError("This single sign-on account was linked by another login; try again")
+		}
+		return nil, err
+	}
+	s.logger.Info().Int64("userID", user.ID).Str("issuer", issuer).Msg("Linked single sign-on identity")
+	return user, nil
+}
+
+// linkUser prepares an existing account for linking. An account already linked to another
+// subject at the provider is not relinked, as the email may have been given to someone else.
+func (s *ssoServiceImpl) linkUser(ctx context.Context, user *models.User, issuer string, emailVerified bool) error {
+	linkedSubject, err := s.identityRepo.GetSubject(ctx, user.ID, issuer)
+	if err != nil {
+		return err
+	}
+	if linkedSubject != "" {
+		return apperrors.NewConflictError("Your account is linked to another single sign-on account")
+	}
+
+	// When the provider has verified the email, so has the account's owner, and the account is
+	// activated as email verification would. Accounts deactivated after verifying stay inactive.
+	if emailVerified && !user.EmailVerified {
+		if err := s.userRepo.SetEmailVerified(ctx, user.ID, true); err != nil {
+			return err
+		}
+		user.EmailVerified = true
+		if !user.IsActive {
+			user.IsActive = true
+			if err := s.userRepo.Update(ctx, user); err != nil {
+				return fmt.Errorf("error activating user account: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// provisionUser creates the account of a user logging in for the first time. It has an
+// unusable random password until the user sets one through a password reset.
+func (s *ssoServiceImpl) provisionUser(ctx context.Context, email string, claims oidc.Claims) (*models.User, error) {
+	studentNumber := claims.String(s.config.Claims.StudentNumber)
+	if studentNumber != "" {
+		exists, err := s.userRepo.IdentifierExists(ctx, studentNumber)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, apperrors.ErrIdentifierExists
+		}
+	}
+
+	password, err := oidc.RandomToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing password: %w", err)
+	}
+
+	firstName, lastName := claims.String(s.config.Claims.FirstName), claims.String(s.config.Claims.LastName)
+	if firstName == "" && lastName == "" {
+		firstName, lastName = splitName(claims.String("name"))
+	}
+
+	roleType := models.RoleStudent
+	if studentNumber == "" {
+		roleType = s.roleResolver.ResolveRole(email)
+	}
+
+	user := &models.User{
+		Email:         email,
+		Password:      string(hashedPassword),
+		FirstName:     firstName,
+		LastName:      lastName,
+		RoleType:      roleType,
+		IsActive:      true,
+		EmailVerified: true, // Verified by the provider
+	}
+	if code := claims.String(s.config.Claims.Department); code != "" {
+		department, err := s.departmentRepo.GetByCode(ctx, code)
+		if err == nil {
+			user.DepartmentID = &department.ID
+		} else if errors.Is(err, apperrors.ErrDepartmentNotFound) {
+			s.logger.Warn().Str("departmentCode", code).Str("email", email).Msg("Single sign-on department code matches no department")
+		} else {
+			return nil, err
+		}
+	}
+
+	userID, err := s.userRepo.CreateUser(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("error creating user: %w", err)
+	}
+	user.ID = userID
+
+	if studentNumber != "" {
+		if err := s.userRepo.CreateStudent(ctx, &models.Student{UserID: userID, Identifier: studentNumber}); err != nil {
+			// Do not leave a student without a student number behind
+			if deleteErr := s.userRepo.Delete(ctx, userID); deleteErr != nil {
+				s.logger.Error().Err(deleteErr).Int64("userID", userID).Msg("Failed to delete user after student creation failed")
+			}
+			return nil, err
+		}
+	}
+
+	if err := s.instructorVerificationService.QueueNewAccount(ctx, user); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().Int64("userID", userID).Str("role", string(roleType)).Msg("Provisioned user from single sign-on")
+	return user, nil
+}
+
+// splitName splits a full name into a first name and the last word as the last name
+func splitName(name string) (string, string) {
+	fields := strings.Fields(name)
+	switch len(fields) {
+	case 0:
+		return "", ""
+	case 1:
+		return fields[0], ""
+	default:
+		return strings.Join(fields[:len(fields)-1], " "), fields[len(fields)-1]
+	}
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/filestorage"
+)
+
+// storageCheckGracePeriod keeps the check away from uploads in progress: files are stored
+// before their row is written, and rows are written before their resource refers to them
+const storageCheckGracePeriod = time.Hour
+
+// StorageCheckOptions choose what a storage check fixes; with neither set it only reports
+type StorageCheckOptions struct {
+	DeleteOrphans bool // Delete stored files that no row or upload refers to
+	Repair        bool // Delete rows whose file or resource is gone, and forget missing thumbnails
+}
+
+// StorageCheckReport lists the inconsistencies between storage and the files table
+type StorageCheckReport struct {
+	OrphanedFiles     []filestorage.StoredObject // Stored files that no row or upload refers to
+	MissingFiles      []*models.File             // Rows whose stored file is gone
+	MissingThumbnails map[int64][]int            // Thumbnail sizes a row lists but storage lacks, by file ID
+	DetachedFiles     []*models.File             // Rows whose resource no longer exists
+	Deleted           int                        // Stored files and rows deleted
+	Repaired          int                        // Rows whose thumbnail list was corrected
+	Failed            int                        // Deletions and repairs that failed
+}
+
+// Problems returns the number of inconsistencies found
+func (r *StorageCheckReport) Problems() int {
+	return len(r.OrphanedFiles) + len(r.MissingFiles) + len(r.MissingThumbnails) + len(r.DetachedFiles)
+}
+
+// StorageCheckService finds stored files without a row and rows whose file or resource is gone
+type StorageCheckService interface {
+	Check(ctx context.Context, opts StorageCheckOptions) (*StorageCheckReport, error)
+	RunScheduled(interval time.Duration, opts StorageCheckOptions)
+}
+
+// storageCheckServiceImpl implements StorageCheckService
+type storageCheckServiceImpl struct {
+	fileRepo    *repositories.FileRepository
+	uploadRepo  *repositories.UploadSessionRepository
+	blobRepo    *repositories.BlobRepository
+	fileStorage filestorage.FileStorage
+	logger      zerolog.Logger
+}
+
+// NewStorageCheckService creates a new StorageCheckService
+func NewStorageCheckService(
+	fileRepo *repositories.FileRepository,
+	uploadRepo *repositories.UploadSessionRepository,
+	blobRepo *repositories.BlobRepository,
+	fileStorage filestorage.FileStorage,
+	logger zerolog.Logger,
+) StorageCheckService {
+	return &storageCheckServiceImpl{
+		fileRepo:    fileRepo,
+		uploadRepo:  uploadRepo,
+		blobRepo:    blobRepo,
+		fileStorage: fileStorage,
+		logger:      logger,
+	}
+}
+
+// storageRef is what refers to a stored path: a file row, one of its thumbnails, a blob or an upload chunk
+type storageRef struct {
+	file          *models.File // nil for blobs and upload chunks
+	thumbnailSize int          // 0 for the file itself
+}
+
+// Check compares storage with the files table and fixes what opts allow.
+// Files and rows younger than storageCheckGracePeriod are left alone.
+func (s *storageCheckServiceImpl) Check(ctx context.Context, opts StorageCheckOptions) (*StorageCheckReport, error) {
+	cutoff := time.Now().Add(-storageCheckGracePeriod)
+	report := &StorageCheckReport{MissingThumbnails: make(map[int64][]int)}
+
+	refs, err := s.collectRefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stored files nothing refers to
+	found := make(map[string]bool, len(refs))
+	err = s.fileStorage.WalkFiles(ctx, func(object filestorage.StoredObject) error {
+		if _, ok := refs[object.Path]; ok {
+			found[object.Path] = true
+		} else if object.ModTime.Before(cutoff) {
+			report.OrphanedFiles = append(report.OrphanedFiles, object)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking storage: %w", err)
+	}
+
+	// Rows and thumbnails whose stored file is gone
+	for storedPath, pathRefs := range refs {
+		if found[storedPath] {
+			continue
+		}
+		for _, ref := range pathRefs {
+			if ref.file == nil || ref.file.CreatedAt.After(cutoff) {
+				continue
+			}
+			if ref.thumbnailSize == 0 {
+				report.MissingFiles = append(report.MissingFiles, ref.file)
+			} else {
+				report.MissingThumbnails[ref.file.ID] = append(report.MissingThumbnails[ref.file.ID], ref.thumbnailSize)
+			}
+		}
+	}
+	sort.Slice(report.OrphanedFiles, func(i, j int) bool { return report.OrphanedFiles[i].Path < report.OrphanedFiles[j].Path })
+	sort.Slice(report.MissingFiles, func(i, j int) bool { return report.MissingFiles[i].ID < report.MissingFiles[j].ID })
+	for _, sizes := range report.MissingThumbnails {
+		sort.Ints(sizes)
+	}
+
+	// Rows whose resource was deleted without them
+	report.DetachedFiles, err = s.fileRepo.ListDetached(ctx, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DeleteOrphans {
+		s.deleteOrphans(report)
+	}
+	if opts.Repair {
+		s.repair(ctx, report)
+	}
+	return report, nil
+}
+
+// RunScheduled runs a check every interval and logs what it found; it runs until the process exits
+func (s *storageCheckServiceImpl) RunScheduled(interval time.Duration, opts StorageCheckOptions) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		report, err := s.Check(context.Background(), opts)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("Storage consistency check failed")
+			continue
+		}
+
+		event := s.logger.Info()
+		if report.Problems() > 0 {
+			event = s.logger.Warn()
+		}
+		event.
+			Int("orphanedFiles", len(report.OrphanedFiles)).
+			Int("missingFiles", len(report.MissingFiles)).
+			Int("missingThumbnails", len(report.MissingThumbnails)).
+			Int("detachedFiles", len(report.DetachedFiles)).
+			Int("deleted", report.Deleted).
+			Int("repaired", report.Repaired).
+			Int("failed", report.Failed).
+			Msg("Storage consistency check finished")
+	}
+}
+
+// collectRefs maps every stored path to the file rows, blob or upload referring to it.
+// Rows with the same content share the path of their blob.
+func (s *storageCheckServiceImpl) collectRefs(ctx context.Context) (map[string][]storageRef, error) {
+	refs := make(map[string][]storageRef)
+
+	var lastID int64
+	for {
+		files, err := s.fileRepo.ListAfter(ctx, lastID, 500)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		for _, file := range files {
+			lastID = file.ID
+			stored := &models.File{FilePath: s.storedPath(file)}
+			refs[stored.FilePath] = append(refs[stored.FilePath], storageRef{file: file})
+			for _, size := range file.ThumbnailSizes {
+				refs[stored.ThumbnailPath(size)] = append(refs[stored.ThumbnailPath(size)], storageRef{file: file, thumbnailSize: size})
+			}
+		}
+	}
+
+	// Blobs no row refers to any more are left to the blob garbage collection
+	blobPaths, err := s.blobRepo.ListPaths(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for blobPath, thumbnailSizes := range blobPaths {
+		blob := &models.File{FilePath: filestorage.StoredPath(s.fileStorage.GetBaseURL(), blobPath)}
+		refs[blob.FilePath] = append(refs[blob.FilePath], storageRef{})
+		for _, size := range thumbnailSizes {
+			refs[blob.ThumbnailPath(size)] = append(refs[blob.ThumbnailPath(size)], storageRef{})
+		}
+	}
+
+	chunkPaths, err := s.uploadRepo.ListChunkPaths(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunkPath := range chunkPaths {
+		chunkPath = filestorage.StoredPath(s.fileStorage.GetBaseURL(), chunkPath)
+		refs[chunkPath] = append(refs[chunkPath], storageRef{})
+	}
+
+	return refs, nil
+}
+
+// deleteOrphans deletes stored files that nothing refers to
+func (s *storageCheckServiceImpl) deleteOrphans(report *StorageCheckReport) {
+	// An orphaned blob has no record to consult, so it is deleted from the backend directly
+	storage := s.fileStorage
+	if blobs, ok := storage.(*filestorage.BlobStorage); ok {
+		storage = blobs.FileStorage
+	}
+
+	for _, object := range report.OrphanedFiles {
+		if err := storage.DeleteFile(object.Path); err != nil {
+			s.logger.Error().Err(err).Str("path", object.Path).Msg("Failed to delete orphaned file")
+			report.Failed++
+			continue
+		}
+		s.logger.Info().Str("path", object.Path).Int64("size", object.Size).Msg("Deleted orphaned file")
+		report.Deleted++
+	}
+}
+
+// repair deletes rows whose file or resource is gone and drops missing thumbnails from the rows
+// that list them. Rows go first, so a failure leaves at worst an orphaned file for the next run.
+// Blobs are only deleted once no other row shares them.
+func (s *storageCheckServiceImpl) repair(ctx context.Context, report *StorageCheckReport) {
+	deleted := make(map[int64]bool)
+	deleteRow := func(file *models.File, reason string) {
+		if deleted[file.ID] {
+			return
+		}
+		if err := s.fileRepo.Delete(ctx, file.ID); err != nil && !errors.Is(err, apperrors.ErrResourceNotFound) {
+			s.logger.Error().Err(err).Int64("fileID", file.ID).Msg("Failed to delete file row")
+			report.Failed++
+			return
+		}
+		deleted[file.ID] = true
+		report.Deleted++
+		s.logger.Info().Int64("fileID", file.ID).Str("resourceType", string(file.ResourceType)).
+			Int64("resourceID", file.ResourceID).Str("reason", reason).Msg("Deleted file row")
+
+		stored := &models.File{FilePath: s.storedPath(file)}
+		_ = s.fileStorage.DeleteFile(stored.FilePath)
+		for _, size := range file.ThumbnailSizes {
+			_ = s.fileStorage.DeleteFile(stored.ThumbnailPath(size))
+		}
+	}
+
+	for _, file := range report.MissingFiles {
+		deleteRow(file, "stored file missing")
+	}
+	for _, file := range report.DetachedFiles {
+		deleteRow(file, "resource no longer exists")
+	}
+
+	for fileID, missing := range report.MissingThumbnails {
+		if deleted[fileID] {
+			continue // Gone with the row
+		}
+		file, err := s.fileRepo.GetByID(ctx, fileID)
+		if err != nil || file == nil {
+			continue
+		}
+
+		var sizes []int
+		for _, size := range file.ThumbnailSizes {
+			if !slices.Contains(missing, size) {
+				sizes = append(sizes, size)
+			}
+		}
+		if err := s.fileRepo.SetThumbnailSizes(ctx, fileID, sizes); err != nil {
+			s.logger.Error().Err(err).Int64("fileID", fileID).Msg("Failed to repair thumbnail list")
+			report.Failed++
+			continue
+		}
+		s.logger.Info().Int64("fileID", fileID).Ints("missingSizes", missing).Msg("Removed missing thumbnails from file row")
+		report.Repaired++
+	}
+}
+
+// storedPath returns where a row's file is stored
+func (s *storageCheckServiceImpl) storedPath(file *models.File) string {
+	p := file.FilePath
+	if p == "" {
+		p = file.FileURL
+	}
+	return filestorage.StoredPath(s.fileStorage.GetBaseURL(), p)
+}
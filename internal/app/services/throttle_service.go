@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/email"
+)
+
+const (
+	// loginBackoffBase is the wait after the first failed login past the free allowance; it
+	// doubles with every further failure up to loginBackoffMax
+	loginBackoffBase = time.Second
+	loginBackoffMax  = 15 * time.Minute
+)
+
+// ThrottleConfig configures brute-force protection
+type ThrottleConfig struct {
+	LoginFreeAttempts int           // Failed logins per account before backoff starts
+	IPFreeAttempts    int           // Failed logins, or email requests, per IP address before backoff starts
+	LockoutThreshold  int           // Failed logins that lock an account; 0 disables lockout
+	LockoutDuration   time.Duration // How long a lockout lasts unless an admin lifts it
+	Window            time.Duration // Counters start over after this long without attempts
+	EmailsPerWindow   int           // forgot-password and resend-verification requests per account
+}
+
+// ThrottleService slows down password guessing and inbox spam. Failed logins are counted
+// per account and per IP address and back off exponentially; enough failures lock the
+// account. Counters are stored in Postgres, so they hold across instances.
+type ThrottleService interface {
+	// CheckLogin returns an error if logins for the account or from the IP address are on hold
+	CheckLogin(ctx context.Context, email, ip string) error
+	// RecordLoginFailure counts a failed login; user is nil when no account has the email
+	RecordLoginFailure(ctx context.Context, email, ip string, user *models.User)
+	// RecordLoginSuccess clears the account's failed logins
+	RecordLoginSuccess(ctx context.Context, email string)
+	// AllowEmailRequest counts a request that sends email, returning an error if it is one too many
+	AllowEmailRequest(ctx context.Context, action, email, ip string) error
+
+	// Admin
+	ListLockedAccounts(ctx context.Context) ([]dto.LockedAccountResponse, error)
+	UnlockAccount(ctx context.Context, userID int64) error
+
+	// RunCleanup deletes stale counters every interval; it runs until the process exits
+	RunCleanup(interval time.Duration)
+}
+
+// throttleServiceImpl implements ThrottleService
+type throttleServiceImpl struct {
+	throttleRepo *repositories.AuthThrottleRepository
+	userRepo     *repositories.UserRepository
+	emailService email.EmailService
+	config       ThrottleConfig
+	logger       zerolog.Logger
+}
+
+// NewThrottleService creates a new ThrottleService
+func NewThrottleService(
+	throttleRepo *repositories.AuthThrottleRepository,
+	userRepo *repositories.UserRepository,
+	emailService email.EmailService,
+	config ThrottleConfig,
+	logger zerolog.Logger,
+) ThrottleService {
+	return &throttleServiceImpl{
+		throttleRepo: throttleRepo,
+		userRepo:     userRepo,
+		emailService: emailService,
+		config:       config,
+		logger:       logger,
+	}
+}
+
+// CheckLogin returns an error if logins for the account or from the IP address are on hold
+func (s *throttleServiceImpl) CheckLogin(ctx context.Context, email, ip string) error {
+	now := time.Now()
+
+	account, err := s.throttleRepo.Get(ctx, models.ThrottleActionLogin, models.ThrottleAccountSubject(email))
+	if err != nil {
+		return err
+	}
+	if account != nil {
+		if account.LockedUntil != nil && account.LockedUntil.After(now) {
+			return apperrors.NewAccountLockedError(*account.LockedUntil)
+		}
+		if account.BlockedUntil != nil && account.BlockedUntil.After(now) {
+			return apperrors.NewTooManyAttemptsError(account.BlockedUntil.Sub(now))
+		}
+	}
+
+	if ip == "" {
+		return nil
+	}
+	address, err := s.throttleRepo.Get(ctx, models.ThrottleActionLogin, models.ThrottleIPSubject(ip))
+	if err != nil {
+		return err
+	}
+	if address != nil && address.BlockedUntil != nil && address.BlockedUntil.After(now) {
+		return apperrors.NewTooManyAttemptsError(address.BlockedUntil.Sub(now))
+	}
+	return nil
+}
+
+// RecordLoginFailure counts a failed login for the account and the IP address. Errors are
+// logged rather than returned, so the caller can still report the failed login.
+func (s *throttleServiceImpl) RecordLoginFailure(ctx context.Context, email, ip string, user *models.User) {
+	subject := models.ThrottleAccountSubject(email)
+	account, err := s.throttleRepo.RecordAttempt(ctx, models.ThrottleActionLogin, subject, s.config.Window)
+	if err != nil {
+		s.logger.Error().Err(err).Str("email", email).Msg("Failed to record failed login")
+	} else if s.config.LockoutThreshold > 0 && account.Attempts >= s.config.LockoutThreshold {
+		s.lockAccount(ctx, email, user)
+	} else if delay := backoffDelay(account.Attempts, s.config.LoginFreeAttempts); delay > 0 {
+		if err := s.throttleRepo.Block(ctx, models.ThrottleActionLogin, subject, time.Now().Add(delay), nil); err != nil {
+			s.logger.Error().Err(err).Str("email", email).Msg("Failed to delay logins for account")
+		}
+	}
+
+	if ip == "" {
+		return
+	}
+	subject = models.ThrottleIPSubject(ip)
+	address, err := s.throttleRepo.RecordAttempt(ctx, models.ThrottleActionLogin, subject, s.config.Window)
+	if err != nil {
+		s.logger.Error().Err(err).Str("ip", ip).Msg("Failed to record failed login")
+		return
+	}
+	if delay := backoffDelay(address.Attempts, s.config.IPFreeAttempts); delay > 0 {
+		if err := s.throttleRepo.Block(ctx, models.ThrottleActionLogin, subject, time.Now().Add(delay), nil); err != nil {
+			s.logger.Error().Err(err).Str("ip", ip).Msg("Failed to delay logins from IP address")
+		}
+		s.logger.Warn().Str("ip", ip).Int("failures", address.Attempts).Dur("delay", delay).Msg("Failed logins from IP address are backing off")
+	}
+}
+
+// lockAccount locks an account for the lockout duration and tells its owner. The failure
+// count starts over, so the account gets its free attempts back once the lock ends.
+func (s *throttleServiceImpl) lockAccount(ctx context.Context, email string, user *models.User) {
+	lockedUntil := time.Now().Add(s.config.LockoutDuration)
+	if err := s.throttleRepo.Block(ctx, models.ThrottleActionLogin, models.ThrottleAccountSubject(email), lockedUntil, &lockedUntil); err != nil {
+		s.logger.Error().Err(err).Str("email", email).Msg("Failed to lock account")
+		return
+	}
+
+	// Accounts that do not exist are locked all the same, so lockouts do not reveal which do
+	if user == nil {
+		return
+	}
+	s.logger.Warn().Int64("userID", user.ID).Time("lockedUntil", lockedUntil).Msg("Account locked after too many failed logins")
+	if err := s.emailService.SendAccountLockedEmail(user.Email, user.FirstName, lockedUntil); err != nil {
+		s.logger.Error().Err(err).Int64("userID", user.ID).Msg("Failed to send account locked email")
+	}
+}
+
+// RecordLoginSuccess clears the account's failed logins. The IP address keeps its count, so
+// logging in to one account does not buy more guesses at others.
+func (s *throttleServiceImpl) RecordLoginSuccess(ctx context.Context, email string) {
+	if _, err := s.throttleRepo.Reset(ctx, models.ThrottleActionLogin, models.ThrottleAccountSubject(email)); err != nil {
+		s.logger.Error().Err(err).Str("email", email).Msg("Failed to clear failed logins")
+	}
+}
+
+// AllowEmailRequest counts a request that sends email for the account and the IP address.
+// Past the allowance requests are refused until the window has passed without any.
+func (s *throttleServiceImpl) AllowEmailRequest(ctx context.Context, action, email, ip string) error {
+	account, err := s.throttleRepo.RecordAttempt(ctx, action, models.ThrottleAccountSubject(email), s.config.Window)
+	if err != nil {
+		return err
+	}
+	if account.Attempts > s.config.EmailsPerWindow {
+		s.logger.Warn().Str("action", action).Str("email", email).Int("requests", account.Attempts).Msg("Email requests for account throttled")
+		return apperrors.NewTooManyAttemptsError(s.config.Window)
+	}
+
+	if ip == "" {
+		return nil
+	}
+	address, err := s.throttleRepo.RecordAttempt(ctx, action, models.ThrottleIPSubject(ip), s.config.Window)
+	if err != nil {
+		return err
+	}
+	if address.Attempts > s.config.IPFreeAttempts {
+		s.logger.Warn().Str("action", action).Str("ip", ip).Int("requests", address.Attempts).Msg("Email requests from IP address throttled")
+		return apperrors.NewTooManyAttemptsError(s.config.Window)
+	}
+	return nil
+}
+
+// ListLockedAccounts returns the accounts that are locked now
+func (s *throttleServiceImpl) ListLockedAccounts(ctx context.Context) ([]dto.LockedAccountResponse, error) {
+	throttles, err := s.throttleRepo.ListLocked(ctx, models.ThrottleActionLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]dto.LockedAccountResponse, 0, len(throttles))
+	for _, throttle := range throttles {
+		email, ok := accountEmail(throttle.Subject)
+		if !ok {
+			continue
+		}
+		user, err := s.userRepo.GetUserByEmail(ctx, email)
+		if err != nil {
+			if errors.Is(err, apperrors.ErrUserNotFound) {
+				continue // Guesses at an address without an account
+			}
+			return nil, err
+		}
+		accounts = append(accounts, dto.LockedAccountResponse{
+			UserID:      user.ID,
+			Email:       user.Email,
+			FirstName:   user.FirstName,
+			LastName:    user.LastName,
+			LockedUntil: *throttle.LockedUntil,
+		})
+	}
+	return accounts, nil
+}
+
+// UnlockAccount lifts the lockout and backoff of an account
+func (s *throttleServiceImpl) UnlockAccount(ctx context.Context, userID int64) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	unlocked, err := s.throttleRepo.Reset(ctx, models.ThrottleActionLogin, models.ThrottleAccountSubject(user.Email))
+	if err != nil {
+		return err
+	}
+	if unlocked {
+		s.logger.Info().Int64("userID", userID).Msg("Account unlocked by admin")
+	}
+	return nil
+}
+
+// RunCleanup deletes counters that have been quiet for a window every interval
+func (s *throttleServiceImpl) RunCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := s.throttleRepo.DeleteStale(context.Background(), time.Now().Add(-s.config.Window))
+		if err != nil {
+			s.logger.Error().Err(err).Msg("Failed to delete stale auth throttles")
+			continue
+		}
+		if deleted > 0 {
+			s.logger.Debug().Int64("deleted", deleted).Msg("Stale auth throttles deleted")
+		}
+	}
+}
+
+// backoffDelay returns how long to wait after the given number of failures: nothing within
+// the free allowance, then loginBackoffBase doubling with each failure up to loginBackoffMax
+func backoffDelay(failures, free int) time.Duration {
+	if failures <= free {
+		return 0
+	}
+	delay := loginBackoffBase
+	for i := free + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= loginBackoffMax {
+			return loginBackoffMax
+		}
+	}
+	return delay
+}
+
+// accountEmail returns the email address of an account subject
+func accountEmail(subject string) (string, bool) {
+	const prefix = "account:"
+	if len(subject) <= len(prefix) || subject[:len(prefix)] != prefix {
+		return "", false
+	}
+	return subject[len(prefix):], true
+}
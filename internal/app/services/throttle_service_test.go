@@ -0,0 +1,70 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		free     int
+		want     time.Duration
+	}{
+		{"no failures", 0, 3, 0},
+		{"within allowance", 3, 3, 0},
+		{"first over allowance", 4, 3, loginBackoffBase},
+		{"doubles", 5, 3, 2 * loginBackoffBase},
+		{"doubles again", 6, 3, 4 * loginBackoffBase},
+		{"no allowance", 1, 0, loginBackoffBase},
+		{"last before cap", 10, 0, 512 * loginBackoffBase},
+		{"capped", 11, 0, loginBackoffMax},
+		{"stays capped", 1000, 0, loginBackoffMax},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backoffDelay(tt.failures, tt.free); got != tt.want {
+				t.Errorf("backoffDelay(%d, %d) = %v, want %v", tt.failures, tt.free, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffDelayIsMonotonic(t *testing.T) {
+	previous := time.Duration(0)
+	for failures := 0; failures <= 100; failures++ {
+		delay := backoffDelay(failures, 5)
+		if delay < previous {
+			t.Fatalf("backoffDelay(%d, 5) = %v, less than %v for one failure fewer", failures, delay, previous)
+		}
+		if delay > loginBackoffMax {
+			t.Fatalf("backoffDelay(%d, 5) = %v, over the maximum %v", failures, delay, loginBackoffMax)
+		}
+		previous = delay
+	}
+}
+
+func TestAccountEmail(t *testing.T) {
+	tests := []struct {
+		subject   string
+		wantEmail string
+		wantOK    bool
+	}{
+		{"account:ada@example.edu", "ada@example.edu", true},
+		{"account:", "", false},
+		{"ip:192.0.2.1", "", false},
+		{"ada@example.edu", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			email, ok := accountEmail(tt.subject)
+			if email != tt.wantEmail || ok != tt.wantOK {
+				t.Errorf("accountEmail(%q) = (%q, %v), want (%q, %v)", tt.subject, email, ok, tt.wantEmail, tt.wantOK)
+			}
+		})
+	}
+}
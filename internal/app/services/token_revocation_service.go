@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/auth"
+)
+
+// revocationRefreshInterval is how often the cache picks up revocations made by other instances.
+// It bounds how long a token revoked on one instance is still accepted by the others.
+const revocationRefreshInterval = 30 * time.Second
+
+// TokenRevocationService revokes access tokens before they expire. Revocations are stored in
+// Postgres and cached in memory, so checking a token on every request needs no query. They
+// apply at once on the instance that made them and within revocationRefreshInterval on the others.
+type TokenRevocationService interface {
+	// Load fills the cache from the database; call it before serving requests
+	Load(ctx context.Context) error
+	// Run refreshes the cache every revocationRefreshInterval; it runs until the process exits
+	Run()
+	// IsRevoked reports whether an access token was revoked by its jti, its session or its
+	// user's cutoff
+	IsRevoked(claims *auth.Claims) bool
+	// RevokeAccessToken denylists a single access token until it expires
+	RevokeAccessToken(ctx context.Context, jti string, userID int64, expiresAt time.Time) error
+	// RevokeUserTokens invalidates every access token issued to a user so far
+	RevokeUserTokens(ctx context.Context, userID int64) error
+	// RevokeSession invalidates the access tokens of a session whose token family was just
+	// revoked. The family's revocation is the record; this makes it effective on this
+	// instance at once.
+	RevokeSession(sessionID int64)
+}
+
+// tokenRevocationServiceImpl implements TokenRevocationService
+type tokenRevocationServiceImpl struct {
+	revokedTokenRepo *repositories.RevokedTokenRepository
+	accessTokenExp   time.Duration
+	logger           zerolog.Logger
+
+	mu              sync.RWMutex
+	revokedJTIs     map[string]time.Time // jti -> token expiry
+	invalidBefore   map[int64]time.Time  // user ID -> cutoff
+	revokedSessions map[int64]time.Time  // token family ID -> revocation time
+}
+
+// NewTokenRevocationService creates a new TokenRevocationService. accessTokenExp bounds how
+// long a revocation has to be kept: after it, every token it concerns has expired anyway.
+func NewTokenRevocationService(
+	revokedTokenRepo *repositories.RevokedTokenRepository,
+	accessTokenExp time.Duration,
+	logger zerolog.Logger,
+) TokenRevocationService {
+	return &tokenRevocationServiceImpl{
+		revokedTokenRepo: revokedTokenRepo,
+		accessTokenExp:   accessTokenExp,
+		logger:           logger,
+		revokedJTIs:      make(map[string]time.Time),
+		invalidBefore:    make(map[int64]time.Time),
+		revokedSessions:  make(map[int64]time.Time),
+	}
+}
+
+// Load fills the cache from the database
+func (s *tokenRevocationServiceImpl) Load(ctx context.Context) error {
+	tokens, err := s.revokedTokenRepo.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	cutoffs, err := s.revokedTokenRepo.ListTokensInvalidBefore(ctx, time.Now().Add(-s.accessTokenExp))
+	if err != nil {
+		return err
+	}
+	// Access tokens of a session revoked longer ago than their lifetime have expired
+	sessions, err := s.revokedTokenRepo.ListRevokedSessions(ctx, time.Now().Add(-s.accessTokenExp))
+	if err != nil {
+		return err
+	}
+
+	revokedJTIs := make(map[string]time.Time, len(tokens))
+	for _, token := range tokens {
+		revokedJTIs[token.JTI] = token.ExpiresAt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokedJTIs = revokedJTIs
+	s.invalidBefore = cutoffs
+	s.revokedSessions = sessions
+	return nil
+}
+
+// Run refreshes the cache and deletes expired revocations every revocationRefreshInterval
+func (s *tokenRevocationServiceImpl) Run() {
+	ticker := time.NewTicker(revocationRefreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx := context.Background()
+		if _, err := s.revokedTokenRepo.DeleteExpired(ctx); err != nil {
+			s.logger.Error().Err(err).Msg("Failed to delete expired revoked access tokens")
+		}
+		if _, err := s.revokedTokenRepo.DeleteExpiredCutoffs(ctx, time.Now().Add(-s.accessTokenExp)); err != nil {
+			s.logger.Error().Err(err).Msg("Failed to delete expired access token cutoffs")
+		}
+		if err := s.Load(ctx); err != nil {
+			s.logger.Error().Err(err).Msg("Failed to refresh access token revocations")
+		}
+	}
+}
+
+// IsRevoked reports whether an access token was revoked
+func (s *tokenRevocationServiceImpl) IsRevoked(claims *auth.Claims) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.revokedJTIs[claims.ID]; ok {
+		return true
+	}
+	// A revoked family issues no more tokens, so every token of the session predates it
+	if _, ok := s.revokedSessions[claims.SessionID]; ok && claims.SessionID != 0 {
+		return true
+	}
+	if cutoff, ok := s.invalidBefore[claims.UserID]; ok {
+		// Tokens carry their issue time in whole seconds: one issued in the second of the
+		// cutoff may predate it, so it is rejected as well
+		return claims.IssuedAt == nil || !claims.IssuedAt.Time.After(cutoff.Truncate(time.Second))
+	}
+	return false
+}
+
+// RevokeAccessToken denylists a single access token until it expires
+func (s *tokenRevocationServiceImpl) RevokeAccessToken(ctx context.Context, jti string, userID int64, expiresAt time.Time) error {
+	if jti == "" || !expiresAt.After(time.Now()) {
+		return nil
+	}
+
+	if err := s.revokedTokenRepo.Revoke(ctx, jti, userID, expiresAt); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.revokedJTIs[jti] = expiresAt
+	s.mu.Unlock()
+
+	s.logger.Info().Int64("userID", userID).Str("jti", jti).Msg("Access token revoked")
+	return nil
+}
+
+// RevokeUserTokens invalidates every access token issued to a user so far. Access tokens
+// issued in the rest of the current second are rejected too, see IsRevoked; personal access
+// tokens record their creation time exactly and are not.
+func (s *tokenRevocationServiceImpl) RevokeUserTokens(ctx context.Context, userID int64) error {
+	cutoff := time.Now()
+
+	if err := s.revokedTokenRepo.SetTokensInvalidBefore(ctx, userID, cutoff); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if current, ok := s.invalidBefore[userID]; !ok || cutoff.After(current) {
+		s.invalidBefore[userID] = cutoff
+	}
+	s.mu.Unlock()
+
+	s.logger.Info().Int64("userID", userID).Time("cutoff", cutoff).Msg("Access tokens of user revoked")
+	return nil
+}
+
+// RevokeSession invalidates the access tokens of a session whose token family was just revoked
+func (s *tokenRevocationServiceImpl) RevokeSession(sessionID int64) {
+	if sessionID == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	s.revokedSessions[sessionID] = time.Now()
+	s.mu.Unlock()
+}
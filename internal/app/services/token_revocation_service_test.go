@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yigit/unisphere/internal/pkg/auth"
+)
+
+func TestIsRevoked(t *testing.T) {
+	cutoff := time.Date(2026, 3, 1, 12, 0, 0, 500*int(time.Millisecond), time.UTC)
+	s := &tokenRevocationServiceImpl{
+		revokedJTIs:     map[string]time.Time{"revoked-jti": cutoff.Add(time.Hour)},
+		invalidBefore:   map[int64]time.Time{1: cutoff},
+		revokedSessions: map[int64]time.Time{7: cutoff},
+	}
+
+	// claims returns the claims of a token issued to a user in a session at a time
+	claims := func(jti string, userID, sessionID int64, issuedAt time.Time) *auth.Claims {
+		c := &auth.Claims{UserID: userID, SessionID: sessionID}
+		c.ID = jti
+		if !issuedAt.IsZero() {
+			// Tokens carry whole seconds
+			c.IssuedAt = jwt.NewNumericDate(issuedAt.Truncate(time.Second))
+		}
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		claims *auth.Claims
+		want   bool
+	}{
+		{"valid", claims("jti", 2, 3, cutoff), false},
+		{"denylisted jti", claims("revoked-jti", 2, 3, cutoff), true},
+		{"revoked session", claims("jti", 2, 7, cutoff.Add(-time.Minute)), true},
+		{"no session", claims("jti", 2, 0, cutoff), false},
+		{"issued before the cutoff", claims("jti", 1, 3, cutoff.Add(-time.Minute)), true},
+		{"issued earlier in the cutoff's second", claims("jti", 1, 3, cutoff.Add(-400*time.Millisecond)), true},
+		{"issued later in the cutoff's second", claims("jti", 1, 3, cutoff.Add(400*time.Millisecond)), true},
+		{"issued the second after the cutoff", claims("jti", 1, 3, cutoff.Add(600*time.Millisecond)), false},
+		{"no issue time", claims("jti", 1, 3, time.Time{}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.IsRevoked(tt.claims); got != tt.want {
+				t.Errorf("IsRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes are generated at a time
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet is the base32 alphabet; codes look like "k7qm-3xta"
+	recoveryCodeAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+	// maxMFAAttempts is how many wrong codes are accepted within mfaAttemptWindow. Only a right
+	// code clears the count, so logging in again with the password gives no more guesses.
+	maxMFAAttempts = 5
+	// mfaAttemptWindow is how long it takes for wrong codes to be forgotten, so the owner is not
+	// kept out for good once someone used up the attempts
+	mfaAttemptWindow = 15 * time.Minute
+)
+
+// TwoFactorService manages TOTP two-factor authentication: enrollment, the codes that
+// complete a login, recovery codes and turning it off
+type TwoFactorService interface {
+	GetStatus(ctx context.Context, userID int64) (*dto.TwoFactorStatusResponse, error)
+	Setup(ctx context.Context, userID int64) (*dto.TOTPSetupResponse, error)
+	Enable(ctx context.Context, userID int64, code string) (*dto.RecoveryCodesResponse, error)
+	Disable(ctx context.Context, userID int64, req *dto.DisableTOTPRequest) error
+	RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) (*dto.RecoveryCodesResponse, error)
+
+	// ChallengeRequired reports whether a user whose password was just checked has to give
+	// a second factor
+	ChallengeRequired(ctx context.Context, userID int64) (bool, error)
+	// VerifyLoginCode checks the TOTP or recovery code that completes a login
+	VerifyLoginCode(ctx context.Context, userID int64, code string) error
+}
+
+// twoFactorServiceImpl implements TwoFactorService
+type twoFactorServiceImpl struct {
+	totpRepo     *repositories.TOTPRepository
+	userRepo     *repositories.UserRepository
+	secretCipher *auth.SecretCipher
+	issuer       string
+	logger       zerolog.Logger
+}
+
+// NewTwoFactorService creates a new TwoFactorService. issuer names the service in authenticator apps.
+func NewTwoFactorService(
+	totpRepo *repositories.TOTPRepository,
+	userRepo *repositories.UserRepository,
+	secretCipher *auth.SecretCipher,
+	issuer string,
+	logger zerolog.Logger,
+) TwoFactorService {
+	return &twoFactorServiceImpl{
+		totpRepo:     totpRepo,
+		userRepo:     userRepo,
+		secretCipher: secretCipher,
+		issuer:       issuer,
+		logger:       logger,
+	}
+}
+
+// GetStatus describes a user's two-factor authentication
+func (s *twoFactorServiceImpl) GetStatus(ctx context.Context, userID int64) (*dto.TwoFactorStatusResponse, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, apperrors.ErrUserNotFound
+	}
+	totp, err := s.totpRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &dto.TwoFactorStatusResponse{
+		Required: models.RoleRequiresMFA(user.RoleType),
+	}
+	if totp != nil && totp.Enabled {
+		status.Enabled = true
+		status.EnabledAt = totp.EnabledAt
+		if status.RecoveryCodesRemaining, err = s.totpRepo.CountUnusedRecoveryCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// Setup starts a TOTP enrollment with a new secret. Two-factor authentication is turned on
+// by Enable once the authenticator shows a valid code; calling Setup again replaces the secret.
+func (s *twoFactorServiceImpl) Setup(ctx context.Context, userID int64) (*dto.TOTPSetupResponse, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, apperrors.ErrUserNotFound
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.secretCipher.Encrypt(secret)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting TOTP secret: %w", err)
+	}
+
+	saved, err := s.totpRepo.SavePending(ctx, userID, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, apperrors.NewConflictError("Two-factor authentication is already enabled")
+	}
+
+	s.logger.Info().Int64("userID", userID).Msg("TOTP enrollment started")
+	return &dto.TOTPSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// Enable confirms a TOTP enrollment with a code from the authenticator, turns two-factor
+// authentication on and returns the first recovery codes
+func (s *twoFactorServiceImpl) Enable(ctx context.Context, userID int64, code string) (*dto.RecoveryCodesResponse, error) {
+	totp, err := s.totpRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if totp == nil {
+		return nil, apperrors.NewBadRequestError("Start the two-factor authentication setup first")
+	}
+	if totp.Enabled {
+		return nil, apperrors.NewConflictError("Two-factor authentication is already enabled")
+	}
+
+	secret, err := s.secretCipher.Decrypt(totp.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := auth.ValidateTOTP(secret, code, time.Now(), 0)
+	if !ok {
+		return nil, apperrors.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.totpRepo.Enable(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().Int64("userID", userID).Msg("Two-factor authentication enabled")
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Disable turns two-factor authentication off after checking the password and a current code
+func (s *twoFactorServiceImpl) Disable(ctx context.Context, userID int64, req *dto.DisableTOTPRequest) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return apperrors.ErrUserNotFound
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return apperrors.ErrInvalidCredentials
+	}
+
+	totp, err := s.enabledTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyCode(ctx, totp, req.Code); err != nil {
+		return err
+	}
+
+	if err := s.totpRepo.Delete(ctx, userID); err != nil {
+		return err
+	}
+	s.logger.Info().Int64("userID", userID).Msg("Two-factor authentication disabled")
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes after checking a current TOTP code
+func (s *twoFactorServiceImpl) RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) (*dto.RecoveryCodesResponse, error) {
+	totp, err := s.enabledTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// A recovery code cannot be traded for new ones; that needs the authenticator
+	if err := s.verifyTOTPCode(ctx, totp, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.totpRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().Int64("userID", userID).Msg("Recovery codes regenerated")
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// ChallengeRequired reports whether the user has two-factor authentication enabled. It leaves
+// the count of wrong codes alone: a new challenge does not give more guesses.
+func (s *twoFactorServiceImpl) ChallengeRequired(ctx context.Context, userID int64) (bool, error) {
+	return s.totpRepo.IsEnabled(ctx, userID)
+}
+
+// VerifyLoginCode checks the code that completes a login. Attempts are counted before the
+// code is checked, so concurrent guesses cannot exceed maxMFAAttempts; a right code clears them.
+func (s *twoFactorServiceImpl) VerifyLoginCode(ctx context.Context, userID int64, code string) error {
+	totp, err := s.totpRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if totp == nil || !totp.Enabled {
+		// Turned off since the challenge was issued
+		return apperrors.ErrTokenInvalid
+	}
+
+	attempts, err := s.totpRepo.AddFailedAttempt(ctx, userID, mfaAttemptWindow)
+	if err != nil {
+		return err
+	}
+	if attempts > maxMFAAttempts {
+		s.logger.Warn().Int64("userID", userID).Msg("Too many wrong two-factor codes; codes are refused for a while")
+		return apperrors.NewTooManyAttemptsError(mfaAttemptWindow)
+	}
+
+	if err := s.verifyCode(ctx, totp, code); err != nil {
+		return err
+	}
+	return s.totpRepo.ResetFailedAttempts(ctx, userID)
+}
+
+// enabledTOTP returns the user's authenticator, failing if two-factor authentication is off
+func (s *twoFactorServiceImpl) enabledTOTP(ctx context.Context, userID int64) (*models.UserTOTP, error) {
+	totp, err := s.totpRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if totp == nil || !totp.Enabled {
+		return nil, apperrors.NewBadRequestError("Two-factor authentication is not enabled")
+	}
+	return totp, nil
+}
+
+// verifyCode accepts a TOTP code or an unused recovery code, which is used up
+func (s *twoFactorServiceImpl) verifyCode(ctx context.Context, totp *models.UserTOTP, code string) error {
+	if isTOTPCode(code) {
+		return s.verifyTOTPCode(ctx, totp, code)
+	}
+
+	used, err := s.totpRepo.UseRecoveryCode(ctx, totp.UserID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return apperrors.ErrInvalidMFACode
+	}
+
+	remaining, err := s.totpRepo.CountUnusedRecoveryCodes(ctx, totp.UserID)
+	if err == nil {
+		s.logger.Info().Int64("userID", totp.UserID).Int("remaining", remaining).Msg("Recovery code used")
+	}
+	return nil
+}
+
+// verifyTOTPCode accepts a code from the authenticator that was not used before
+func (s *twoFactorServiceImpl) verifyTOTPCode(ctx context.Context, totp *models.UserTOTP, code string) error {
+	secret, err := s.secretCipher.Decrypt(totp.Secret)
+	if err != nil {
+		return err
+	}
+	step, ok := auth.ValidateTOTP(secret, code, time.Now(), totp.LastUsedStep)
+	if !ok {
+		return apperrors.ErrInvalidMFACode
+	}
+
+	// Guards against the same code being used concurrently
+	used, err := s.totpRepo.UseStep(ctx, totp.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return apperrors.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// isTOTPCode reports whether code has the form of a TOTP code rather than a recovery code
+func isTOTPCode(code string) bool {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != auth.TOTPDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// generateRecoveryCodes returns new recovery codes and the hashes to store for them
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	random := make([]byte, recoveryCodeCount*8)
+	if _, err := rand.Read(random); err != nil {
+		return nil, nil, fmt.Errorf("error generating recovery codes: %w", err)
+	}
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		var code strings.Builder
+		for j, b := range random[i*8 : (i+1)*8] {
+			if j == 4 {
+				code.WriteByte('-')
+			}
+			code.WriteByte(recoveryCodeAlphabet[b&31])
+		}
+		codes = append(codes, code.String())
+		hashes = append(hashes, hashRecoveryCode(code.String()))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code ignoring case, spaces and dashes. Codes are random
+// enough that a fast hash suffices.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/filestorage"
+	"github.com/yigit/unisphere/internal/pkg/imaging"
+)
+
+// storedUpload describes an upload after it was written to storage
+type storedUpload struct {
+	FileURL        string
+	FileName       string
+	FileType       string
+	FileSize       int64
+	ThumbnailSizes []int
+}
+
+// storeUpload writes an upload that passed checkUpload to storage under subPath.
+// When the policy of resourceType normalizes images, supported images are stored
+// re-encoded without EXIF data, with thumbnails next to them; other files are stored as sent.
+func storeUpload(storage filestorage.FileStorage, resourceType models.FileType, fileHeader *multipart.FileHeader, subPath, contentType string) (*storedUpload, error) {
+	if !UploadPolicies[resourceType].NormalizeImages || !imaging.Supported(contentType) {
+		fileURL, err := storage.SaveFileWithPath(fileHeader, subPath)
+		if err != nil {
+			return nil, err
+		}
+		return &storedUpload{
+			FileURL:  fileURL,
+			FileName: fileHeader.Filename,
+			FileType: contentType,
+			FileSize: fileHeader.Size,
+		}, nil
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer src.Close()
+
+	result, err := imaging.Process(src)
+	if err != nil {
+		if errors.Is(err, imaging.ErrTooManyPixels) {
+			return nil, apperrors.NewValidationError(string(dto.ErrorCodeFileTooLarge),
+				fmt.Sprintf("%s has too many pixels", fileHeader.Filename),
+				map[string]interface{}{"fileName": fileHeader.Filename})
+		}
+		return nil, apperrors.NewValidationError(string(dto.ErrorCodeFileTypeNotAllowed),
+			fmt.Sprintf("%s could not be read as an image", fileHeader.Filename),
+			map[string]interface{}{"fileName": fileHeader.Filename, "detectedType": contentType})
+	}
+
+	storedName := strings.TrimSuffix(fileHeader.Filename, path.Ext(fileHeader.Filename)) + imaging.Extension
+
+	// Identical images are stored once, with the thumbnails rendered the first time
+	if blobs, ok := storage.(*filestorage.BlobStorage); ok {
+		fileURL, thumbnailSizes, err := blobs.SaveContent(result.Image, imaging.Extension, imaging.ContentType, result.Thumbnails)
+		if err != nil {
+			return nil, err
+		}
+		return &storedUpload{
+			FileURL:        fileURL,
+			FileName:       storedName,
+			FileType:       imaging.ContentType,
+			FileSize:       int64(len(result.Image)),
+			ThumbnailSizes: thumbnailSizes,
+		}, nil
+	}
+
+	// Thumbnails are named after the stored file, so the file path is chosen up front
+	file := &models.File{FilePath: path.Join(strings.Trim(subPath, "/"), uuid.New().String()+imaging.Extension)}
+
+	fileURL, err := storage.SaveObject(bytes.NewReader(result.Image), int64(len(result.Image)), file.FilePath, imaging.ContentType)
+	if err != nil {
+		return nil, err
+	}
+	for _, size := range imaging.ThumbnailSizes {
+		thumbnail := result.Thumbnails[size]
+		if _, err := storage.SaveObject(bytes.NewReader(thumbnail), int64(len(thumbnail)), file.ThumbnailPath(size), imaging.ContentType); err != nil {
+			deleteStoredFile(storage, file)
+			return nil, err
+		}
+		file.ThumbnailSizes = append(file.ThumbnailSizes, size)
+	}
+
+	return &storedUpload{
+		FileURL:        fileURL,
+		FileName:       storedName,
+		FileType:       imaging.ContentType,
+		FileSize:       int64(len(result.Image)),
+		ThumbnailSizes: file.ThumbnailSizes,
+	}, nil
+}
+
+// deleteStoredFile removes a file and its thumbnails from storage. Failures are logged by
+// the storage backend and otherwise ignored, like the other cleanup paths.
+func deleteStoredFile(storage filestorage.FileStorage, file *models.File) {
+	_ = storage.DeleteFile(file.FilePath)
+	for _, size := range file.ThumbnailSizes {
+		_ = storage.DeleteFile(file.ThumbnailPath(size))
+	}
+}
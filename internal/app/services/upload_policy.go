@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"mime/multipart"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+)
+
+const megabyte = 1 << 20
+
+// UploadPolicy restricts the files that may be attached to one resource type
+type UploadPolicy struct {
+	AllowedTypes []string // MIME types detected from the file's magic bytes
+	MaxSize      int64    // Maximum size of a single file in bytes
+	MaxFiles     int      // Maximum files per resource; 0 means no limit
+
+	// NormalizeImages re-encodes JPEG, PNG and WebP uploads without EXIF data and stores thumbnails
+	NormalizeImages bool
+}
+
+var (
+	imageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+	documentTypes = append([]string{
+		"application/pdf",
+		"text/plain",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	}, imageTypes...)
+
+	attachmentTypes = append([]string{"application/zip"}, documentTypes...)
+)
+
+// UploadPolicies holds the upload policy of every resource type that accepts files.
+// Profile photos are replaced rather than accumulated, so they have no file limit.
+var UploadPolicies = map[models.FileType]UploadPolicy{
+	models.FileTypeProfilePhoto:          {AllowedTypes: imageTypes, MaxSize: 2 * megabyte, NormalizeImages: true},
+	models.FileTypeCommunityProfilePhoto: {AllowedTypes: imageTypes, MaxSize: 2 * megabyte, NormalizeImages: true},
+	models.FileTypePastExam:              {AllowedTypes: append([]string{"application/pdf"}, imageTypes...), MaxSize: 20 * megabyte, MaxFiles: 10},
+	models.FileTypeClassNote:             {AllowedTypes: documentTypes, MaxSize: 20 * megabyte, MaxFiles: 10},
+	models.FileTypeCommunity:             {AllowedTypes: attachmentTypes, MaxSize: 25 * megabyte},
+	models.FileTypeChatMessage:           {AllowedTypes: attachmentTypes, MaxSize: 10 * megabyte, NormalizeImages: true},
+}
+
+// checkUpload validates a single file against the policy of its resource type and
+// returns the MIME type detected from its content, which replaces the client's claim.
+func checkUpload(resourceType models.FileType, fileHeader *multipart.FileHeader) (string, error) {
+	policy, ok := UploadPolicies[resourceType]
+	if !ok {
+		return "", fmt.Errorf("no upload policy for resource type %s", resourceType)
+	}
+
+	if fileHeader.Size > policy.MaxSize {
+		return "", apperrors.NewValidationError(string(dto.ErrorCodeFileTooLarge),
+			fmt.Sprintf("%s is larger than the %d MB limit", fileHeader.Filename, policy.MaxSize/megabyte),
+			map[string]interface{}{
+				"fileName": fileHeader.Filename,
+				"size":     fileHeader.Size,
+				"maxSize":  policy.MaxSize,
+			})
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		return "", fmt.Errorf("error opening file: %w", err)
+	}
+	defer src.Close()
+
+	detected, err := mimetype.DetectReader(src)
+	if err != nil {
+		return "", fmt.Errorf("error detecting file type: %w", err)
+	}
+
+	for _, allowed := range policy.AllowedTypes {
+		if detected.Is(allowed) {
+			return detected.String(), nil
+		}
+	}
+
+	return "", apperrors.NewValidationError(string(dto.ErrorCodeFileTypeNotAllowed),
+		fmt.Sprintf("%s has a file type that is not allowed here", fileHeader.Filename),
+		map[string]interface{}{
+			"fileName":     fileHeader.Filename,
+			"detectedType": detected.String(),
+			"allowedTypes": policy.AllowedTypes,
+		})
+}
+
+// checkUploads validates files that are about to be attached to a resource, including
+// the per-resource file limit, and returns their detected MIME types. Call it before any
+// file is stored so a batch is accepted or rejected as a whole. resourceID 0 means the
+// resource is not created yet.
+func checkUploads(ctx context.Context, fileRepo *repositories.FileRepository, resourceType models.FileType, resourceID int64, files []*multipart.FileHeader) ([]string, error) {
+	policy, ok := UploadPolicies[resourceType]
+	if !ok {
+		return nil, fmt.Errorf("no upload policy for resource type %s", resourceType)
+	}
+
+	if policy.MaxFiles > 0 {
+		existing := 0
+		if resourceID != 0 {
+			count, err := fileRepo.CountByResource(ctx, resourceType, resourceID)
+			if err != nil {
+				return nil, err
+			}
+			existing = count
+		}
+
+		if existing+len(files) > policy.MaxFiles {
+			return nil, apperrors.NewValidationError(string(dto.ErrorCodeTooManyFiles),
+				fmt.Sprintf("At most %d files can be attached", policy.MaxFiles),
+				map[string]interface{}{
+					"existingFiles": existing,
+					"newFiles":      len(files),
+					"maxFiles":      policy.MaxFiles,
+				})
+		}
+	}
+
+	contentTypes := make([]string, len(files))
+	for i, fileHeader := range files {
+		contentType, err := checkUpload(resourceType, fileHeader)
+		if err != nil {
+			return nil, err
+		}
+		contentTypes[i] = contentType
+	}
+	return contentTypes, nil
+}
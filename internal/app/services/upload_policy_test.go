@@ -0,0 +1,140 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"testing"
+
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/models/dto"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+)
+
+// Magic bytes of the file types the policies tell apart
+const (
+	pngContent  = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	jpegContent = "\xff\xd8\xff\xe0\x00\x10JFIF\x00"
+	pdfContent  = "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"
+	zipContent  = "PK\x03\x04\x14\x00\x00\x00\x00\x00"
+	htmlContent = "<!DOCTYPE html><html><script>alert(1)</script></html>"
+	textContent = "Lecture notes, week one"
+)
+
+// uploadHeader returns the header of a file uploaded in a multipart form, as handlers receive it
+func uploadHeader(t *testing.T, filename, content string) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("files", filename)
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	part.Write([]byte(content))
+	writer.Close()
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("ReadForm: %v", err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["files"][0]
+}
+
+// errorCode returns the code of a validation error, or "" for other errors
+func errorCode(err error) string {
+	var customErr *apperrors.CustomError
+	if errors.As(err, &customErr) {
+		return customErr.Code
+	}
+	return ""
+}
+
+func TestCheckUpload(t *testing.T) {
+	tests := []struct {
+		name         string
+		resourceType models.FileType
+		filename     string
+		content      string
+		want         string
+		wantCode     dto.ErrorCode
+	}{
+		{"png profile photo", models.FileTypeProfilePhoto, "me.png", pngContent, "image/png", ""},
+		{"jpeg past exam", models.FileTypePastExam, "exam.jpg", jpegContent, "image/jpeg", ""},
+		{"pdf past exam", models.FileTypePastExam, "exam.pdf", pdfContent, "application/pdf", ""},
+		{"text class note", models.FileTypeClassNote, "notes.txt", textContent, "text/plain; charset=utf-8", ""},
+		{"zip community file", models.FileTypeCommunity, "slides.zip", zipContent, "application/zip", ""},
+		{"pdf profile photo", models.FileTypeProfilePhoto, "me.png", pdfContent, "", dto.ErrorCodeFileTypeNotAllowed},
+		{"zip class note", models.FileTypeClassNote, "notes.zip", zipContent, "", dto.ErrorCodeFileTypeNotAllowed},
+		{"html named as pdf", models.FileTypePastExam, "exam.pdf", htmlContent, "", dto.ErrorCodeFileTypeNotAllowed},
+		{"html in a chat", models.FileTypeChatMessage, "page.txt", htmlContent, "", dto.ErrorCodeFileTypeNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkUpload(tt.resourceType, uploadHeader(t, tt.filename, tt.content))
+			if tt.wantCode != "" {
+				if code := errorCode(err); code != string(tt.wantCode) {
+					t.Fatalf("checkUpload() error = %v, want code %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkUpload() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("checkUpload() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckUploadSize(t *testing.T) {
+	for resourceType, policy := range UploadPolicies {
+		t.Run(string(resourceType), func(t *testing.T) {
+			header := uploadHeader(t, "image.png", pngContent)
+			header.Size = policy.MaxSize
+			if _, err := checkUpload(resourceType, header); err != nil {
+				t.Errorf("checkUpload() of a file at the limit: %v", err)
+			}
+			header.Size = policy.MaxSize + 1
+			if _, err := checkUpload(resourceType, header); errorCode(err) != string(dto.ErrorCodeFileTooLarge) {
+				t.Errorf("checkUpload() of a file over the limit error = %v, want code %s", err, dto.ErrorCodeFileTooLarge)
+			}
+		})
+	}
+
+	if _, err := checkUpload(models.FileType("unknown"), uploadHeader(t, "image.png", pngContent)); err == nil {
+		t.Error("checkUpload() for a resource type without a policy succeeded, want an error")
+	}
+}
+
+func TestCheckUploadsFileLimit(t *testing.T) {
+	// New resources have no files yet, so the repository is not consulted
+	maxFiles := UploadPolicies[models.FileTypePastExam].MaxFiles
+	files := make([]*multipart.FileHeader, maxFiles+1)
+	for i := range files {
+		files[i] = uploadHeader(t, "exam.pdf", pdfContent)
+	}
+
+	contentTypes, err := checkUploads(context.Background(), nil, models.FileTypePastExam, 0, files[:maxFiles])
+	if err != nil {
+		t.Fatalf("checkUploads() of %d files: %v", maxFiles, err)
+	}
+	for i, contentType := range contentTypes {
+		if contentType != "application/pdf" {
+			t.Errorf("content type %d = %q, want application/pdf", i, contentType)
+		}
+	}
+
+	if _, err := checkUploads(context.Background(), nil, models.FileTypePastExam, 0, files); errorCode(err) != string(dto.ErrorCodeTooManyFiles) {
+		t.Errorf("checkUploads() of %d files error = %v, want code %s", len(files), err, dto.ErrorCodeTooManyFiles)
+	}
+
+	// One disallowed file rejects the whole batch
+	batch := []*multipart.FileHeader{uploadHeader(t, "exam.pdf", pdfContent), uploadHeader(t, "exam.zip", zipContent)}
+	if contentTypes, err := checkUploads(context.Background(), nil, models.FileTypePastExam, 0, batch); errorCode(err) != string(dto.ErrorCodeFileTypeNotAllowed) || contentTypes != nil {
+		t.Errorf("checkUploads() of a mixed batch = %v, %v; want code %s", contentTypes, err, dto.ErrorCodeFileTypeNotAllowed)
+	}
+}
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+	"github.com/yigit/unisphere/internal/pkg/filestorage"
+)
+
+const (
+	// uploadChunkDir is the storage directory of received chunks, one subdirectory per upload
+	uploadChunkDir = "resumable_uploads"
+
+	// uploadFormMemory is how much of a finished upload is kept in memory when it is
+	// turned into a multipart file; the rest is spooled to a temporary file
+	uploadFormMemory = 8 * megabyte
+
+	// expiredUploadBatch bounds the sessions removed per cleanup query
+	expiredUploadBatch = 100
+
+	// maxOpenUploads is how many unfinished uploads a user may have at once
+	maxOpenUploads = 10
+)
+
+// resumableResourceTypes are the resource types a finished resumable upload can be attached to
+var resumableResourceTypes = []models.FileType{
+	models.FileTypePastExam,
+	models.FileTypeClassNote,
+	models.FileTypeChatMessage,
+}
+
+// UploadService manages resumable (tus) uploads
+type UploadService interface {
+	CreateUpload(ctx context.Context, userID, length int64, metadata string) (*models.UploadSession, error)
+	GetUpload(ctx context.Context, userID int64, id string) (*models.UploadSession, error)
+	WriteChunk(ctx context.Context, userID int64, id string, offset int64, r io.Reader) (*models.UploadSession, error)
+	TerminateUpload(ctx context.Context, userID int64, id string) error
+	OpenCompletedUpload(ctx context.Context, userID int64, id string) (*CompletedUpload, error)
+	FinishUpload(ctx context.Context, upload *CompletedUpload)
+	DeleteExpiredUploads(ctx context.Context) (int, error)
+	RunExpiryCleanup(interval time.Duration)
+	MaxSize() int64
+}
+
+// CompletedUpload is a finished resumable upload opened as a multipart file, so it can be
+// passed to the services that attach files. Close removes its temporary file.
+type CompletedUpload struct {
+	ID   string
+	File *multipart.FileHeader
+	form *multipart.Form
+}
+
+// Close releases the temporary file backing the upload
+func (u *CompletedUpload) Close() error {
+	return u.form.RemoveAll()
+}
+
+// uploadServiceImpl implements UploadService
+type uploadServiceImpl struct {
+	uploadRepo   *repositories.UploadSessionRepository
+	fileStorage  filestorage.FileStorage
+	quotaService QuotaService
+	ttl          time.Duration
+	logger       zerolog.Logger
+}
+
+// NewUploadService creates a new UploadService. Sessions expire ttl after they last received data.
+func NewUploadService(
+	uploadRepo *repositories.UploadSessionRepository,
+	fileStorage filestorage.FileStorage,
+	quotaService QuotaService,
+	ttl time.Duration,
+	logger zerolog.Logger,
+) UploadService {
+	return &uploadServiceImpl{
+		uploadRepo:   uploadRepo,
+		fileStorage:  fileStorage,
+		quotaService: quotaService,
+		ttl:          ttl,
+		logger:       logger,
+	}
+}
+
+// MaxSize returns the largest upload any attachable resource type accepts
+func (s *uploadServiceImpl) MaxSize() int64 {
+	var maxSize int64
+	for _, resourceType := range resumableResourceTypes {
+		maxSize = max(maxSize, UploadPolicies[resourceType].MaxSize)
+	}
+	return maxSize
+}
+
+// CreateUpload starts a resumable upload of length bytes. metadata is the tus
+// Upload-Metadata header; its filename (or name) entry names the file. The bytes of the user's
+// other open uploads count toward their quota, and at most maxOpenUploads can be open.
+func (s *uploadServiceImpl) CreateUpload(ctx context.Context, userID, length int64, metadata string) (*models.UploadSession, error) {
+	if length > s.MaxSize() {
+		return nil, apperrors.NewBadRequestError(fmt.Sprintf("Uploads are limited to %d MB", s.MaxSize()/megabyte))
+	}
+
+	// Open uploads hold on to their full length, so a user cannot start more uploads than their
+	// quota has room for. The quota is checked again when the upload is attached.
+	openCount, openLength, err := s.uploadRepo.GetOpenByUser(ctx, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if openCount >= maxOpenUploads {
+		return nil, apperrors.NewConflictError(fmt.Sprintf("At most %d uploads can be in progress; finish or terminate one first", maxOpenUploads))
+	}
+	if err := s.quotaService.CheckUserQuota(ctx, userID, openLength+length); err != nil {
+		return nil, err
+	}
+
+	values, err := parseUploadMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := values["filename"]
+	if fileName == "" {
+		fileName = values["name"]
+	}
+	fileName = path.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	if fileName == "." || fileName == "/" {
+		fileName = "upload"
+	}
+	if len(fileName) > 255 {
+		fileName = fileName[len(fileName)-255:]
+	}
+
+	session := &models.UploadSession{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		FileName:  fileName,
+		Length:    length,
+		Metadata:  metadata,
+		ExpiresAt: time.Now().UTC().Add(s.ttl), // TIMESTAMP columns store the UTC wall clock
+	}
+	if err := s.uploadRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	s.logger.Debug().Str("uploadID", session.ID).Int64("userID", userID).Int64("length", length).Msg("Resumable upload created")
+	return session, nil
+}
+
+// GetUpload returns an upload of the user. Uploads of other users and expired uploads are not found.
+func (s *uploadServiceImpl) GetUpload(ctx context.Context, userID int64, id string) (*models.UploadSession, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, apperrors.NewResourceNotFoundError("Upload not found")
+	}
+
+	session, err := s.uploadRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrResourceNotFound) {
+			return nil, apperrors.NewResourceNotFoundError("Upload not found")
+		}
+		return nil, err
+	}
+	if session.UserID != userID || time.Now().After(session.ExpiresAt) {
+		return nil, apperrors.NewResourceNotFoundError("Upload not found")
+	}
+	return session, nil
+}
+
+// WriteChunk appends the bytes of r to an upload that is currently at offset. The body is
+// spooled to a temporary file first, so if the client disconnects halfway, the bytes that
+// did arrive are still stored and the client can resume after them.
+func (s *uploadServiceImpl) WriteChunk(ctx context.Context, userID int64, id string, offset int64, r io.Reader) (*models.UploadSession, error) {
+	session, err := s.GetUpload(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != session.Offset {
+		return nil, apperrors.NewConflictError(fmt.Sprintf("Upload-Offset %d does not match the current offset %d", offset, session.Offset))
+	}
+
+	remaining := session.Length - session.Offset
+	tmp, err := os.CreateTemp("", "unisphere-chunk-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary chunk file: %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	size, readErr := io.Copy(tmp, io.LimitReader(r, remaining+1))
+	if size > remaining {
+		return nil, apperrors.NewBadRequestError("Request body exceeds the remaining Upload-Length")
+	}
+	if size == 0 {
+		if readErr != nil {
+			return nil, fmt.Errorf("error reading chunk: %w", readErr)
+		}
+		return session, nil
+	}
+	if readErr != nil {
+		// The client is gone; keep what arrived even though the request context is canceled
+		s.logger.Warn().Err(readErr).Str("uploadID", id).Int64("received", size).Msg("Chunk interrupted, storing received bytes")
+		ctx = context.WithoutCancel(ctx)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error rewinding chunk file: %w", err)
+	}
+	chunkPath := path.Join(uploadChunkDir, id, uuid.New().String())
+	if _, err := s.fileStorage.SaveObject(tmp, size, chunkPath, "application/octet-stream"); err != nil {
+		return nil, fmt.Errorf("error storing chunk: %w", err)
+	}
+
+	updated, err := s.uploadRepo.AppendChunk(ctx, id, offset, chunkPath, size, time.Now().UTC().Add(s.ttl))
+	if err != nil {
+		_ = s.fileStorage.DeleteFile(chunkPath)
+		if errors.Is(err, apperrors.ErrConflict) {
+			return nil, apperrors.NewConflictError("Upload offset changed while the chunk was received")
+		}
+		return nil, err
+	}
+	return updated, nil
+}
+
+// TerminateUpload deletes an upload and the chunks received so far
+func (s *uploadServiceImpl) TerminateUpload(ctx context.Context, userID int64, id string) error {
+	session, err := s.GetUpload(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	return s.deleteSession(ctx, session)
+}
+
+// OpenCompletedUpload joins the chunks of a finished upload into a multipart file
+func (s *uploadServiceImpl) OpenCompletedUpload(ctx context.Context, userID int64, id string) (*CompletedUpload, error) {
+	session, err := s.GetUpload(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !session.IsComplete() {
+		return nil, apperrors.NewConflictError(fmt.Sprintf("Upload %s is not complete: %d of %d bytes received", id, session.Offset, session.Length))
+	}
+
+	form, err := s.readUploadForm(session)
+	if err != nil {
+		return nil, fmt.Errorf("error reading upload %s: %w", id, err)
+	}
+	files := form.File["file"]
+	if len(files) != 1 || files[0].Size != session.Length {
+		_ = form.RemoveAll()
+		return nil, fmt.Errorf("upload %s has missing chunks", id)
+	}
+
+	return &CompletedUpload{ID: id, File: files[0], form: form}, nil
+}
+
+// readUploadForm streams the chunks of an upload through a multipart writer into a
+// multipart reader, which spools them to a temporary file
+func (s *uploadServiceImpl) readUploadForm(session *models.UploadSession) (*multipart.Form, error) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(s.writeUploadForm(mw, session))
+	}()
+
+	return multipart.NewReader(pr, mw.Boundary()).ReadForm(uploadFormMemory)
+}
+
+// writeUploadForm writes the chunks of an upload as the single file part of a multipart form
+func (s *uploadServiceImpl) writeUploadForm(mw *multipart.Writer, session *models.UploadSession) error {
+	part, err := mw.CreateFormFile("file", session.FileName)
+	if err != nil {
+		return err
+	}
+	for _, chunkPath := range session.ChunkPaths {
+		chunk, err := s.fileStorage.OpenFile(chunkPath)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, chunk)
+		chunk.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// FinishUpload deletes an upload whose file was attached to a resource
+func (s *uploadServiceImpl) FinishUpload(ctx context.Context, upload *CompletedUpload) {
+	session, err := s.uploadRepo.GetByID(ctx, upload.ID)
+	if err == nil {
+		err = s.deleteSession(ctx, session)
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Str("uploadID", upload.ID).Msg("Failed to delete finished upload; it will expire")
+	}
+}
+
+// DeleteExpiredUploads removes expired sessions with their chunks and returns how many were
+// removed. A session that cannot be removed is logged and left for the next run, so one bad
+// session does not keep the others around; only failing to list sessions is an error.
+func (s *uploadServiceImpl) DeleteExpiredUploads(ctx context.Context) (int, error) {
+	deleted := 0
+	failed := make(map[string]bool) // Still expired, so listed again by later batches
+	for {
+		sessions, err := s.uploadRepo.GetExpired(ctx, time.Now().UTC(), expiredUploadBatch)
+		if err != nil {
+			return deleted, err
+		}
+		progress := false
+		for _, session := range sessions {
+			if failed[session.ID] {
+				continue
+			}
+			if err := s.deleteSession(ctx, session); err != nil {
+				s.logger.Error().Err(err).Str("uploadID", session.ID).Msg("Failed to delete expired upload; it will be retried")
+				failed[session.ID] = true
+				continue
+			}
+			deleted++
+			progress = true
+		}
+		if len(sessions) < expiredUploadBatch || !progress {
+			return deleted, nil
+		}
+	}
+}
+
+// RunExpiryCleanup deletes expired uploads every interval; it runs until the process exits
+func (s *uploadServiceImpl) RunExpiryCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := s.DeleteExpiredUploads(context.Background())
+		if err != nil {
+			s.logger.Error().Err(err).Msg("Failed to delete expired uploads")
+		}
+		if deleted > 0 {
+			s.logger.Info().Int("count", deleted).Msg("Deleted expired uploads")
+		}
+	}
+}
+
+// deleteSession removes the chunks of an upload, then the session itself. Chunks that are
+// already gone count as deleted.
+func (s *uploadServiceImpl) deleteSession(ctx context.Context, session *models.UploadSession) error {
+	for _, chunkPath := range session.ChunkPaths {
+		if err := s.fileStorage.DeleteFile(chunkPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error deleting upload chunk: %w", err)
+		}
+	}
+	return s.uploadRepo.Delete(ctx, session.ID)
+}
+
+// parseUploadMetadata decodes a tus Upload-Metadata header: comma separated pairs of a key
+// and an optional base64 encoded value
+func parseUploadMetadata(header string) (map[string]string, error) {
+	values := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return values, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, apperrors.NewBadRequestError("Invalid Upload-Metadata header")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, apperrors.NewBadRequestError(fmt.Sprintf("Invalid Upload-Metadata value for %s", key))
+		}
+		values[key] = string(value)
+	}
+	return values, nil
+}
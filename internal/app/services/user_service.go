@@ -13,8 +13,8 @@ import (
 	"github.com/yigit/unisphere/internal/app/models/dto"
 	"github.com/yigit/unisphere/internal/app/repositories"
 	"github.com/yigit/unisphere/internal/pkg/apperrors"
-	"github.com/yigit/unisphere/internal/pkg/auth"
 	"github.com/yigit/unisphere/internal/pkg/filestorage"
+	"github.com/yigit/unisphere/internal/pkg/helpers"
 )
 
 // UserService defines the interface for user operations
@@ -25,19 +25,23 @@ type UserService interface {
 	UpdateUserProfile(ctx context.Context, userID int64, req *dto.UpdateUserRequest) (*models.User, error)
 	UpdateProfilePhoto(ctx context.Context, userID int64, file *multipart.FileHeader) (*models.File, error)
 	DeleteProfilePhoto(ctx context.Context, userID int64) error
-	GetUsersByFilter(ctx context.Context, filter *dto.UserFilterRequest) ([]*models.User, int64, error)
+	GetUsersByFilter(ctx context.Context, filter *dto.UserFilterRequest) ([]*models.User, int64, *dto.CursorInfo, error)
 	GetFileByID(ctx context.Context, fileID int64) (*models.File, error)
+	GetStorageUsage(ctx context.Context, userID int64) (*dto.StorageUsageResponse, error)
 	DeleteUser(ctx context.Context, userID int64) error
 }
 
 // userServiceImpl implements UserService
 type userServiceImpl struct {
-	userRepo       *repositories.UserRepository
-	departmentRepo *repositories.DepartmentRepository
-	fileRepo       *repositories.FileRepository
-	fileStorage    *filestorage.LocalStorage
-	authService    AuthService
-	logger         zerolog.Logger
+	userRepo          *repositories.UserRepository
+	departmentRepo    *repositories.DepartmentRepository
+	fileRepo          *repositories.FileRepository
+	fileStorage       filestorage.FileStorage
+	quotaService      QuotaService
+	scanService       ScanService
+	authService       AuthService
+	revocationService TokenRevocationService
+	logger            zerolog.Logger
 }
 
 // NewUserService creates a new UserService
@@ -45,17 +49,23 @@ func NewUserService(
 	userRepo *repositories.UserRepository,
 	departmentRepo *repositories.DepartmentRepository,
 	fileRepo *repositories.FileRepository,
-	fileStorage *filestorage.LocalStorage,
+	fileStorage filestorage.FileStorage,
+	quotaService QuotaService,
+	scanService ScanService,
 	authService AuthService,
+	revocationService TokenRevocationService,
 	logger zerolog.Logger,
 ) UserService {
 	return &userServiceImpl{
-		userRepo:       userRepo,
-		departmentRepo: departmentRepo,
-		fileRepo:       fileRepo,
-		fileStorage:    fileStorage,
-		authService:    authService,
-		logger:         logger,
+		userRepo:          userRepo,
+		departmentRepo:    departmentRepo,
+		fileRepo:          fileRepo,
+		fileStorage:       fileStorage,
+		quotaService:      quotaService,
+		scanService:       scanService,
+		authService:       authService,
+		revocationService: revocationService,
+		logger:            logger,
 	}
 }
 
@@ -131,6 +141,11 @@ func (s *userServiceImpl) GetUserProfile(ctx context.Context, userID int64) (*mo
 
 // UpdateUserProfile updates a user's profile information
 func (s *userServiceImpl) UpdateUserProfile(ctx context.Context, userID int64, req *dto.UpdateUserRequest) (*models.User, error) {
+	// Passwords are changed with the current one, which signs out the other sessions
+	if req.Password != nil && *req.Password != "" {
+		return nil, apperrors.NewBadRequestError("Change your password with PUT /users/profile/password")
+	}
+
 	// Get current user information
 	currentUser, err := s.userRepo.FindByID(ctx, userID)
 	if err != nil {
@@ -157,31 +172,15 @@ func (s *userServiceImpl) UpdateUserProfile(ctx context.Context, userID int64, r
 	currentUser.LastName = req.LastName
 	// Email değiştirilmeyecek, mevcut email korunacak
 
-	// If password is provided, update it
-	if req.Password != nil && *req.Password != "" {
-		// Validate password using the same validation as in auth service
-		if err := s.authService.ValidatePassword(*req.Password); err != nil {
-			return nil, err
-		}
-
-		// Hash the new password
-		hashedPassword, err := auth.HashPassword(*req.Password)
-		if err != nil {
-			s.logger.Error().Err(err).Msg("Error hashing password")
-			return nil, fmt.Errorf("error processing password: %w", err)
-		}
-
-		// Update the password
-		err = s.userRepo.UpdatePassword(ctx, userID, hashedPassword)
-		if err != nil {
-			return nil, fmt.Errorf("error updating user password: %w", err)
-		}
-	}
-
 	// Return updated user
 	return currentUser, nil
 }
 
+// GetStorageUsage returns the storage a user's uploads take up and their quota
+func (s *userServiceImpl) GetStorageUsage(ctx context.Context, userID int64) (*dto.StorageUsageResponse, error) {
+	return s.quotaService.GetUserStorage(ctx, userID)
+}
+
 // UpdateProfilePhoto updates a user's profile photo
 func (s *userServiceImpl) UpdateProfilePhoto(ctx context.Context, userID int64, fileHeader *multipart.FileHeader) (*models.File, error) {
 	// Get user information
@@ -193,76 +192,77 @@ func (s *userServiceImpl) UpdateProfilePhoto(ctx context.Context, userID int64,
 		return nil, apperrors.ErrUserNotFound
 	}
 
-	// Get the content type
-	contentType := fileHeader.Header.Get("Content-Type")
-	if !strings.HasPrefix(contentType, "image/") {
-		return nil, fmt.Errorf("file is not an image: %s", contentType)
+	// Validate against the upload policy and use the detected MIME type
+	contentType, err := checkUpload(models.FileTypeProfilePhoto, fileHeader)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.quotaService.CheckUserQuota(ctx, userID, fileHeader.Size); err != nil {
+		return nil, err
 	}
 
 	// Generate a storage path based on user ID
 	subPath := fmt.Sprintf("profile_photos/user_%d", userID)
 
-	// Upload to storage - LocalStorage will generate a unique filename using UUID
-	fileURL, err := s.fileStorage.SaveFileWithPath(fileHeader, subPath)
+	// Upload to storage without EXIF data, with thumbnails - a unique filename is generated using UUID
+	stored, err := storeUpload(s.fileStorage, models.FileTypeProfilePhoto, fileHeader, subPath, contentType)
 	if err != nil {
 		return nil, fmt.Errorf("error uploading file: %w", err)
 	}
 
 	// Get just the filename from the URL
-	filename := filepath.Base(fileURL)
+	filename := filepath.Base(stored.FileURL)
 
 	// Extract relative path from URL
-	relativeFilePath := strings.TrimPrefix(fileURL, s.fileStorage.GetBaseURL())
+	relativeFilePath := strings.TrimPrefix(stored.FileURL, s.fileStorage.GetBaseURL())
 	relativeFilePath = strings.TrimPrefix(relativeFilePath, "/uploads/")
 
 	// Create file record
 	file := &models.File{
-		FileName:     filename,
-		FilePath:     relativeFilePath,
-		FileURL:      fileURL,
-		FileSize:     fileHeader.Size,
-		FileType:     contentType,
-		ResourceType: models.FileTypeProfilePhoto,
-		ResourceID:   userID,
-		UploadedBy:   userID,
+		FileName:       filename,
+		FilePath:       relativeFilePath,
+		FileURL:        stored.FileURL,
+		FileSize:       stored.FileSize,
+		FileType:       stored.FileType,
+		ResourceType:   models.FileTypeProfilePhoto,
+		ResourceID:     userID,
+		UploadedBy:     userID,
+		ThumbnailSizes: stored.ThumbnailSizes,
 	}
 
 	// Save file metadata first
 	fileID, err := s.fileRepo.Create(ctx, file)
 	if err != nil {
 		// Clean up the uploaded file if metadata save fails
-		_ = s.fileStorage.DeleteFile(relativeFilePath)
+		deleteStoredFile(s.fileStorage, file)
 		return nil, fmt.Errorf("error saving file metadata: %w", err)
 	}
 	file.ID = fileID
+	s.scanService.Enqueue(ctx, fileID)
 
 	// Delete old profile photo if exists
 	if user.ProfilePhotoFileID != nil && *user.ProfilePhotoFileID != fileID {
 		oldFile, err := s.fileRepo.GetByID(ctx, *user.ProfilePhotoFileID)
 		if err == nil && oldFile != nil {
-			// Store the old file path for deletion after user update succeeds
-			oldFilePath := oldFile.FilePath
-			oldFileID := oldFile.ID
-
 			// Update user's profile photo ID first
 			err = s.userRepo.UpdateProfilePhotoFileID(ctx, userID, &fileID)
 			if err != nil {
 				// If user update fails, clean up the new file
-				_ = s.fileStorage.DeleteFile(relativeFilePath)
+				deleteStoredFile(s.fileStorage, file)
 				_ = s.fileRepo.Delete(ctx, fileID)
 				return nil, fmt.Errorf("error updating user's profile photo ID: %w", err)
 			}
 
-			// Now that user update succeeded, delete old file
-			_ = s.fileStorage.DeleteFile(oldFilePath)
-			_ = s.fileRepo.Delete(ctx, oldFileID)
+			// Now that user update succeeded, delete old file and its thumbnails
+			deleteStoredFile(s.fileStorage, oldFile)
+			_ = s.fileRepo.Delete(ctx, oldFile.ID)
 		}
 	} else {
 		// No old photo or same photo ID (which shouldn't happen), just update user
 		err = s.userRepo.UpdateProfilePhotoFileID(ctx, userID, &fileID)
 		if err != nil {
 			// If user update fails, clean up the new file
-			_ = s.fileStorage.DeleteFile(relativeFilePath)
+			deleteStoredFile(s.fileStorage, file)
 			_ = s.fileRepo.Delete(ctx, fileID)
 			return nil, fmt.Errorf("error updating user's profile photo ID: %w", err)
 		}
@@ -310,6 +310,9 @@ func (s *userServiceImpl) DeleteProfilePhoto(ctx context.Context, userID int64)
 			s.logger.Warn().Err(delErr).Str("filePath", oldFile.FilePath).
 				Msg("Failed to delete profile photo file from storage")
 		}
+		for _, size := range oldFile.ThumbnailSizes {
+			_ = s.fileStorage.DeleteFile(oldFile.ThumbnailPath(size))
+		}
 
 		// Delete the file record
 		if delErr := s.fileRepo.Delete(ctx, *user.ProfilePhotoFileID); delErr != nil {
@@ -330,8 +333,14 @@ func (s *userServiceImpl) GetFileByID(ctx context.Context, fileID int64) (*model
 	return file, nil
 }
 
-// GetUsersByFilter retrieves users based on filter criteria with pagination
-func (s *userServiceImpl) GetUsersByFilter(ctx context.Context, filter *dto.UserFilterRequest) ([]*models.User, int64, error) {
+// GetUsersByFilter retrieves users based on filter criteria with pagination.
+// The returned cursor block allows keyset paging with the after/before tokens.
+func (s *userServiceImpl) GetUsersByFilter(ctx context.Context, filter *dto.UserFilterRequest) ([]*models.User, int64, *dto.CursorInfo, error) {
+	cursor, err := helpers.ParseCursor(filter.After, filter.Before)
+	if err != nil {
+		return nil, 0, nil, apperrors.NewBadRequestError(err.Error())
+	}
+
 	// Convert role string to RoleType if provided
 	var roleType *models.RoleType
 	if filter.Role != nil {
@@ -340,29 +349,43 @@ func (s *userServiceImpl) GetUsersByFilter(ctx context.Context, filter *dto.User
 	}
 
 	// Get users by filter
-	users, total, err := s.userRepo.FindByFilter(ctx,
+	users, total, hasMore, err := s.userRepo.FindByFilter(ctx,
 		filter.DepartmentID,
 		roleType,
 		filter.Email,
 		filter.Name,
 		filter.Page,
-		filter.PageSize)
+		filter.PageSize,
+		cursor)
 
 	if err != nil {
-		return nil, 0, fmt.Errorf("error finding users by filter: %w", err)
+		return nil, 0, nil, fmt.Errorf("error finding users by filter: %w", err)
 	}
 
-	// Load departments for users
-	for _, user := range users {
-		if user.DepartmentID != nil {
-			department, err := s.departmentRepo.GetByID(ctx, *user.DepartmentID)
-			if err == nil && department != nil {
-				user.Department = department
+	// Load departments for users only when they are expanded, once per department
+	if filter.Projection.Expands("department") {
+		departments := make(map[int64]*models.Department)
+		for _, user := range users {
+			if user.DepartmentID == nil {
+				continue
 			}
+			department, ok := departments[*user.DepartmentID]
+			if !ok {
+				department, err = s.departmentRepo.GetByID(ctx, *user.DepartmentID)
+				if err != nil {
+					department = nil
+				}
+				departments[*user.DepartmentID] = department
+			}
+			user.Department = department
 		}
 	}
 
-	return users, total, nil
+	cursorInfo := helpers.NewCursorInfo(repositories.UserSort, len(users), func(i int) (interface{}, int64) {
+		return users[i].ID, users[i].ID
+	}, cursor, hasMore, filter.Page, filter.PageSize, total)
+
+	return users, total, cursorInfo, nil
 }
 
 // DeleteUser deletes a user by ID
@@ -389,6 +412,11 @@ func (s *userServiceImpl) DeleteUser(ctx context.Context, userID int64) error {
 		}
 	}
 
+	// Access tokens of the user must stop working along with the account
+	if err := s.revocationService.RevokeUserTokens(ctx, userID); err != nil {
+		return fmt.Errorf("error revoking user tokens: %w", err)
+	}
+
 	// Delete user
 	if err := s.userRepo.Delete(ctx, userID); err != nil {
 		return fmt.Errorf("error deleting user: %w", err)
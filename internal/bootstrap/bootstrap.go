@@ -2,6 +2,7 @@ package bootstrap
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -19,6 +20,7 @@ import (
 	appAuth "github.com/yigit/unisphere/internal/app/auth"
 	appControllers "github.com/yigit/unisphere/internal/app/controllers"
 	appMigrations "github.com/yigit/unisphere/internal/app/migrations"
+	"github.com/yigit/unisphere/internal/app/models"
 	appRepos "github.com/yigit/unisphere/internal/app/repositories"
 	appRoutes "github.com/yigit/unisphere/internal/app/routes"
 	appServices "github.com/yigit/unisphere/internal/app/services"
@@ -31,38 +33,70 @@ import (
 	// Import the new helpers package
 	"github.com/yigit/unisphere/internal/pkg/email" // Import email package
 	"github.com/yigit/unisphere/internal/pkg/helpers"
+	"github.com/yigit/unisphere/internal/pkg/ldap"
 	"github.com/yigit/unisphere/internal/pkg/logger"
+	"github.com/yigit/unisphere/internal/pkg/oidc"
+	"github.com/yigit/unisphere/internal/pkg/scanner"
 	"github.com/yigit/unisphere/internal/pkg/websocket" // Import WebSocket package
 	"github.com/yigit/unisphere/internal/seed" // Import the new seed package
 )
 
 // Dependencies holds all the application dependencies
+
 type Dependencies struct {
-	AuthService          appServices.AuthService       // Interface type
-	UserService          appServices.UserService       // Interface type
-	FacultyService       appServices.FacultyService    // Interface type
-	DepartmentService    appServices.DepartmentService // Interface type
-	PastExamService      appServices.PastExamService   // Interface type
-	ClassNoteService     appServices.ClassNoteService  // Interface type
-	CommunityService     appServices.CommunityService  // Interface type
-	ChatService          appServices.ChatService       // Interface type
-	AuthController       *appControllers.AuthController
-	FacultyController    *appControllers.FacultyController
-	DepartmentController *appControllers.DepartmentController
-	UserController       *appControllers.UserController // User Controller
-	PastExamController   *appControllers.PastExamController
-	ClassNoteController  *appControllers.ClassNoteController
-	CommunityController  *appControllers.CommunityController
-	ChatController       *appControllers.ChatController
-	AuthMiddleware       *appMiddleware.AuthMiddleware // Pointer to middleware struct
-	Repos                *appRepos.Repositories        // Include the main repo container
-	JWTService           *pkgAuth.JWTService
-	AuthzService         *appAuth.AuthorizationService
-	EmailService         email.EmailService
-	Logger               zerolog.Logger
-	FileStorage          *filestorage.LocalStorage // Add FileStorage
-	WSHub                *websocket.Hub            // WebSocket hub for real-time communication
-	WSHandler            *websocket.Handler        // WebSocket connection handler
+	AuthService                      appServices.AuthService         // Interface type
+	UserService                      appServices.UserService         // Interface type
+	FacultyService                   appServices.FacultyService      // Interface type
+	DepartmentService                appServices.DepartmentService   // Interface type
+	PastExamService                  appServices.PastExamService     // Interface type
+	ClassNoteService                 appServices.ClassNoteService    // Interface type
+	CommunityService                 appServices.CommunityService    // Interface type
+	ChatService                      appServices.ChatService         // Interface type
+	DuplicateService                 appServices.DuplicateService    // Interface type
+	FileService                      appServices.FileService         // Interface type
+	UploadService                    appServices.UploadService       // Interface type
+	QuotaService                     appServices.QuotaService        // Interface type
+	StorageCheckService              appServices.StorageCheckService // Interface type
+	ScanService                      appServices.ScanService         // Interface type
+	TwoFactorService                 appServices.TwoFactorService    // Interface type
+	RevocationService                appServices.TokenRevocationService
+	SigningKeyService                appServices.SigningKeyService // Nil with HS256
+	ThrottleService                  appServices.ThrottleService
+	InstructorVerificationService    appServices.InstructorVerificationService
+	RoleAssignmentService            appServices.RoleAssignmentService
+	SSOService                       appServices.SSOService
+	LDAPService                      appServices.LDAPService
+	PersonalAccessTokenService       appServices.PersonalAccessTokenService
+	AuthController                   *appControllers.AuthController
+	FacultyController                *appControllers.FacultyController
+	DepartmentController             *appControllers.DepartmentController
+	UserController                   *appControllers.UserController // User Controller
+	PastExamController               *appControllers.PastExamController
+	ClassNoteController              *appControllers.ClassNoteController
+	CommunityController              *appControllers.CommunityController
+	ChatController                   *appControllers.ChatController
+	DuplicateController              *appControllers.DuplicateController
+	FileController                   *appControllers.FileController
+	UploadController                 *appControllers.UploadController
+	QuarantineController             *appControllers.QuarantineController
+	TwoFactorController              *appControllers.TwoFactorController
+	SessionController                *appControllers.SessionController
+	LockoutController                *appControllers.LockoutController
+	InstructorVerificationController *appControllers.InstructorVerificationController
+	RoleAssignmentController         *appControllers.RoleAssignmentController
+	SSOController                    *appControllers.SSOController
+	LDAPController                   *appControllers.LDAPController
+	PersonalAccessTokenController    *appControllers.PersonalAccessTokenController
+	AccountController                *appControllers.AccountController
+	AuthMiddleware                   *appMiddleware.AuthMiddleware // Pointer to middleware struct
+	Repos                            *appRepos.Repositories        // Include the main repo container
+	JWTService                       *pkgAuth.JWTService
+	AuthzService                     *appAuth.AuthorizationService
+	EmailService                     email.EmailService
+	Logger                           zerolog.Logger
+	FileStorage                      filestorage.FileStorage // Local disk or S3, selected by storage.driver, storing content-addressed blobs
+	WSHub                            *websocket.Hub          // WebSocket hub for real-time communication
+	WSHandler                        *websocket.Handler      // WebSocket connection handler
 }
 
 // LoadConfigAndSetupLogger loads configuration and initializes the logger.
@@ -134,38 +168,106 @@ func SetupDatabase(cfg *config.Config, lgr zerolog.Logger) (*pgxpool.Pool, error
 	return dbPool, nil
 }
 
+// NewFileStorage creates the file storage backend selected by storage.driver. Uploads are
+// stored once per distinct content, as blobs recorded in blobs.
+func NewFileStorage(cfg *config.Config, blobs filestorage.BlobIndex) (*filestorage.BlobStorage, error) {
+	var storage filestorage.FileStorage
+	var err error
+	if cfg.Storage.Driver == config.StorageDriverS3 {
+		storage, err = NewS3Storage(cfg)
+	} else {
+		storage, err = NewLocalStorage(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return filestorage.NewBlobStorage(storage, blobs), nil
+}
+
+// NewLocalStorage creates the local disk storage served under /uploads
+func NewLocalStorage(cfg *config.Config) (*filestorage.LocalStorage, error) {
+	// Configure baseURL to match the static file serving endpoint
+	fileStorageBaseURL := serverBaseURL(cfg) + "/uploads" // This must match the static file serving URL path
+	return filestorage.NewLocalStorage(cfg.Server.StoragePath, fileStorageBaseURL)
+}
+
+// serverBaseURL is the absolute URL of this server, including host and port
+func serverBaseURL(cfg *config.Config) string {
+	return "http://localhost:" + cfg.Server.Port
+}
+
+// NewS3Storage creates the S3-compatible bucket storage from storage.s3
+func NewS3Storage(cfg *config.Config) (*filestorage.S3Storage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	s3 := cfg.Storage.S3
+	return filestorage.NewS3Storage(ctx, filestorage.S3Config{
+		Endpoint:  s3.Endpoint,
+		Region:    s3.Region,
+		Bucket:    s3.Bucket,
+		AccessKey: s3.AccessKey,
+		SecretKey: s3.SecretKey,
+		UseSSL:    s3.UseSSL,
+		PublicURL: s3.PublicURL,
+	})
+}
+
 // BuildDependencies initializes application repositories, services, and controllers.
 func BuildDependencies(cfg *config.Config, dbPool *pgxpool.Pool, lgr zerolog.Logger) (*Dependencies, error) {
 	deps := &Dependencies{Logger: lgr}
 
 	deps.Repos = appRepos.NewRepositories(dbPool)
 
+	baseUrl := serverBaseURL(cfg)
+
 	// Initialize File Storage
-	// Configure baseURL to match the static file serving endpoint
-	var err error
-	// Change the relative path to an absolute URL including host and port
-	baseUrl := "http://localhost:" + cfg.Server.Port
-	fileStorageBaseURL := baseUrl + "/uploads" // This must match the static file serving URL path
-	deps.FileStorage, err = filestorage.NewLocalStorage(cfg.Server.StoragePath, fileStorageBaseURL)
+	blobStorage, err := NewFileStorage(cfg, deps.Repos.BlobRepository)
 	if err != nil {
 		lgr.Error().Err(err).Msg("Failed to initialize file storage")
 		return nil, fmt.Errorf("failed to initialize file storage: %w", err)
 	}
+	deps.FileStorage = blobStorage
+	// Blobs no file refers to any more are deleted in the background
+	go blobStorage.RunGarbageCollection(filestorage.BlobGracePeriod)
 
 	// Initialize services
 	deps.AuthzService = appAuth.NewAuthorizationService(
 		deps.Repos.UserRepository,
 		deps.Repos.ClassNoteRepository,
 		deps.Repos.PastExamRepository,
+		deps.Repos.DepartmentRepository,
+		deps.Repos.RoleAssignmentRepository,
 	)
 
+	signingKeys := pkgAuth.NewKeySet()
 	deps.JWTService = pkgAuth.NewJWTService(pkgAuth.JWTConfig{
 		SecretKey:       cfg.JWT.Secret,
+		Algorithm:       cfg.JWT.Algorithm,
+		Keys:            signingKeys,
 		AccessTokenExp:  helpers.ParseDuration(cfg.JWT.AccessTokenExpiration, 1*time.Hour),
 		RefreshTokenExp: helpers.ParseDuration(cfg.JWT.RefreshTokenExpiration, 720*time.Hour),
+		MFAChallengeExp: helpers.ParseDuration(cfg.MFA.ChallengeTTL, 5*time.Minute),
 		TokenIssuer:     cfg.JWT.Issuer,
 	})
 
+	// RS256/EdDSA key pairs, rotated on a schedule and shared by all instances through the database
+	if pkgAuth.IsAsymmetricAlgorithm(cfg.JWT.Algorithm) {
+		deps.SigningKeyService = appServices.NewSigningKeyService(
+			deps.Repos.SigningKeyRepository,
+			signingKeys,
+			pkgAuth.NewSecretCipher(cfg.JWT.Secret),
+			cfg.JWT.Algorithm,
+			helpers.ParseDuration(cfg.JWT.KeyRotationInterval, 720*time.Hour),
+			helpers.ParseDuration(cfg.JWT.KeyGracePeriod, 24*time.Hour),
+			deps.Logger,
+		)
+		if err := deps.SigningKeyService.Load(context.Background()); err != nil {
+			return nil, fmt.Errorf("failed to load JWT signing keys: %w", err)
+		}
+		go deps.SigningKeyService.Run()
+	}
+
 	// Initialize Email Service
 	deps.EmailService = email.NewEmailService(email.SMTPConfig{
 		Host:      cfg.SMTP.Host,
@@ -178,6 +280,143 @@ func BuildDependencies(cfg *config.Config, dbPool *pgxpool.Pool, lgr zerolog.Log
 		BaseURL:   baseUrl, // Use the same base URL as file storage
 	}, lgr)
 
+	// Storage quotas are configured in MB; 0 means unlimited
+	deps.QuotaService = appServices.NewQuotaService(
+		deps.Repos.FileRepository,
+		deps.Repos.UserRepository,
+		appServices.StorageQuotas{
+			PerRole: map[models.RoleType]int64{
+				models.RoleStudent:    cfg.Storage.Quotas.StudentMB << 20,
+				models.RoleInstructor: cfg.Storage.Quotas.InstructorMB << 20,
+				models.RoleAdmin:      cfg.Storage.Quotas.AdminMB << 20,
+				// Until approved, would-be instructors get what students get
+				models.RolePendingInstructor: cfg.Storage.Quotas.StudentMB << 20,
+			},
+			PerCommunity: cfg.Storage.Quotas.CommunityMB << 20,
+		},
+	)
+
+	// Malware scanning of uploads; without a clamd address files are marked clean unscanned
+	var fileScanner scanner.Scanner
+	if cfg.Storage.Scan.ClamdAddress != "" {
+		fileScanner = scanner.NewClamdScanner(cfg.Storage.Scan.ClamdAddress, helpers.ParseDuration(cfg.Storage.Scan.Timeout, 2*time.Minute))
+	} else {
+		lgr.Warn().Msg("CLAMD_ADDRESS is not set; uploads will not be scanned for malware")
+	}
+	deps.ScanService = appServices.NewScanService(
+		deps.Repos.FileRepository,
+		deps.Repos.UserRepository,
+		deps.FileStorage,
+		fileScanner,
+		deps.EmailService,
+		deps.Logger,
+	)
+	go deps.ScanService.Run()
+
+	// Access token revocation, checked by JWTAuth on every request from an in-memory cache
+	deps.RevocationService = appServices.NewTokenRevocationService(
+		deps.Repos.RevokedTokenRepository,
+		helpers.ParseDuration(cfg.JWT.AccessTokenExpiration, 1*time.Hour),
+		deps.Logger,
+	)
+	if err := deps.RevocationService.Load(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to load access token revocations: %w", err)
+	}
+	go deps.RevocationService.Run()
+
+	// Brute-force protection: login backoff and lockout, and limits on emails sent on request
+	deps.ThrottleService = appServices.NewThrottleService(
+		deps.Repos.AuthThrottleRepository,
+		deps.Repos.UserRepository,
+		deps.EmailService,
+		appServices.ThrottleConfig{
+			LoginFreeAttempts: cfg.Throttle.LoginFreeAttempts,
+			IPFreeAttempts:    cfg.Throttle.IPFreeAttempts,
+			LockoutThreshold:  cfg.Throttle.LockoutThreshold,
+			LockoutDuration:   helpers.ParseDuration(cfg.Throttle.LockoutDuration, 30*time.Minute),
+			Window:            helpers.ParseDuration(cfg.Throttle.Window, time.Hour),
+			EmailsPerWindow:   cfg.Throttle.EmailsPerWindow,
+		},
+		deps.Logger,
+	)
+	go deps.ThrottleService.RunCleanup(time.Hour)
+
+	// TOTP two-factor authentication; secrets are stored encrypted
+	mfaEncryptionKey := cfg.MFA.EncryptionKey
+	if mfaEncryptionKey == "" {
+		mfaEncryptionKey = cfg.JWT.Secret
+	}
+	deps.TwoFactorService = appServices.NewTwoFactorService(
+		deps.Repos.TOTPRepository,
+		deps.Repos.UserRepository,
+		pkgAuth.NewSecretCipher(mfaEncryptionKey),
+		cfg.MFA.Issuer,
+		deps.Logger,
+	)
+
+	// Role of new accounts; those that are not students wait in the admin review queue
+	roleResolver, err := appServices.NewRoleResolver(cfg.Registration.RoleStrategy, strings.Split(cfg.Registration.StudentDomains, ","))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up registration role strategy: %w", err)
+	}
+	deps.InstructorVerificationService = appServices.NewInstructorVerificationService(
+		deps.Repos.InstructorVerificationRepository,
+		deps.Repos.UserRepository,
+		deps.RevocationService,
+		deps.EmailService,
+		deps.Logger,
+	)
+	deps.RoleAssignmentService = appServices.NewRoleAssignmentService(
+		deps.Repos.RoleAssignmentRepository,
+		deps.Repos.UserRepository,
+		deps.Repos.FacultyRepository,
+		deps.Repos.DepartmentRepository,
+		deps.Repos.CommunityRepository,
+		deps.Logger,
+	)
+	deps.PersonalAccessTokenService = appServices.NewPersonalAccessTokenService(
+		deps.Repos.PersonalAccessTokenRepository,
+		deps.Repos.UserRepository,
+		deps.Repos.RevokedTokenRepository,
+		deps.Logger,
+	)
+
+	// LDAP directory logins; without a URL every login uses the local password
+	deps.LDAPService = appServices.NewLDAPService(
+		appServices.LDAPConfig{
+			URL: cfg.LDAP.URL,
+			Dial: ldap.DialConfig{
+				StartTLS:  cfg.LDAP.StartTLS,
+				TLSConfig: &tls.Config{InsecureSkipVerify: cfg.LDAP.InsecureSkipVerify},
+				Timeout:   helpers.ParseDuration(cfg.LDAP.Timeout, 10*time.Second),
+			},
+			BindDN:          cfg.LDAP.BindDN,
+			BindPassword:    cfg.LDAP.BindPassword,
+			BaseDN:          cfg.LDAP.BaseDN,
+			UserFilter:      cfg.LDAP.UserFilter,
+			IDAttribute:     cfg.LDAP.IDAttribute,
+			StudentRoles:    strings.Split(cfg.LDAP.StudentRoles, ","),
+			InstructorRoles: strings.Split(cfg.LDAP.InstructorRoles, ","),
+			SyncInterval:    helpers.ParseDuration(cfg.LDAP.SyncInterval, time.Hour),
+			Attributes: appServices.LDAPAttributes{
+				Email:      cfg.LDAP.Attributes.Email,
+				FirstName:  cfg.LDAP.Attributes.FirstName,
+				LastName:   cfg.LDAP.Attributes.LastName,
+				Department: cfg.LDAP.Attributes.Department,
+				Role:       cfg.LDAP.Attributes.Role,
+			},
+		},
+		deps.Repos.UserIdentityRepository,
+		deps.Repos.UserRepository,
+		deps.Repos.DepartmentRepository,
+		deps.Repos.TokenRepository,
+		deps.InstructorVerificationService,
+		deps.RevocationService,
+		roleResolver,
+		deps.Logger,
+	)
+	go deps.LDAPService.Run()
+
 	deps.AuthService = appServices.NewAuthService(
 		deps.Repos.UserRepository,
 		deps.Repos.TokenRepository,
@@ -185,6 +424,14 @@ func BuildDependencies(cfg *config.Config, dbPool *pgxpool.Pool, lgr zerolog.Log
 		deps.Repos.FacultyRepository,
 		deps.Repos.FileRepository,
 		deps.FileStorage,
+		deps.QuotaService,
+		deps.ScanService,
+		deps.TwoFactorService,
+		deps.RevocationService,
+		deps.ThrottleService,
+		deps.LDAPService,
+		roleResolver,
+		deps.InstructorVerificationService,
 		deps.Repos.VerificationTokenRepository,
 		deps.Repos.PasswordResetTokenRepository,
 		deps.EmailService,
@@ -192,6 +439,40 @@ func BuildDependencies(cfg *config.Config, dbPool *pgxpool.Pool, lgr zerolog.Log
 		lgr,
 	)
 
+	// Single sign-on; without an issuer URL the SSO routes answer that it is disabled
+	var ssoProvider *oidc.Provider
+	if cfg.OIDC.IssuerURL != "" {
+		ssoProvider = oidc.NewProvider(oidc.Config{
+			IssuerURL:    cfg.OIDC.IssuerURL,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       strings.Split(cfg.OIDC.Scopes, ","),
+		})
+	}
+	deps.SSOService = appServices.NewSSOService(
+		ssoProvider,
+		appServices.SSOConfig{
+			StateTTL:             helpers.ParseDuration(cfg.OIDC.StateTTL, 10*time.Minute),
+			AllowUnverifiedEmail: cfg.OIDC.AllowUnverifiedEmail,
+			Claims: appServices.SSOClaims{
+				Email:         cfg.OIDC.Claims.Email,
+				FirstName:     cfg.OIDC.Claims.FirstName,
+				LastName:      cfg.OIDC.Claims.LastName,
+				Department:    cfg.OIDC.Claims.Department,
+				StudentNumber: cfg.OIDC.Claims.StudentNumber,
+			},
+		},
+		deps.Repos.SSOLoginStateRepository,
+		deps.Repos.UserIdentityRepository,
+		deps.Repos.UserRepository,
+		deps.Repos.DepartmentRepository,
+		deps.InstructorVerificationService,
+		roleResolver,
+		deps.AuthService,
+		deps.Logger,
+	)
+
 	deps.FacultyService = appServices.NewFacultyService(deps.Repos.FacultyRepository)
 	deps.DepartmentService = appServices.NewDepartmentService(deps.Repos.DepartmentRepository, deps.Repos.FacultyRepository)
 
@@ -201,7 +482,17 @@ func BuildDependencies(cfg *config.Config, dbPool *pgxpool.Pool, lgr zerolog.Log
 		deps.Repos.DepartmentRepository,
 		deps.Repos.FileRepository,
 		deps.FileStorage,
+		deps.QuotaService,
+		deps.ScanService,
 		deps.AuthService,
+		deps.RevocationService,
+		deps.Logger,
+	)
+
+	// Duplicate detection for uploaded notes and exam files
+	deps.DuplicateService = appServices.NewDuplicateService(
+		deps.Repos.FileRepository,
+		deps.Repos.FileFingerprintRepository,
 		deps.Logger,
 	)
 
@@ -210,7 +501,10 @@ func BuildDependencies(cfg *config.Config, dbPool *pgxpool.Pool, lgr zerolog.Log
 		deps.Repos.DepartmentRepository,
 		deps.Repos.FileRepository,
 		deps.FileStorage,
+		deps.QuotaService,
+		deps.ScanService,
 		deps.AuthzService,
+		deps.DuplicateService,
 		deps.Logger,
 	)
 	deps.ClassNoteService = appServices.NewClassNoteService(
@@ -218,7 +512,10 @@ func BuildDependencies(cfg *config.Config, dbPool *pgxpool.Pool, lgr zerolog.Log
 		deps.Repos.DepartmentRepository,
 		deps.Repos.FileRepository,
 		deps.FileStorage,
+		deps.QuotaService,
+		deps.ScanService,
 		deps.AuthzService,
+		deps.DuplicateService,
 		deps.Logger,
 	)
 
@@ -228,6 +525,8 @@ func BuildDependencies(cfg *config.Config, dbPool *pgxpool.Pool, lgr zerolog.Log
 		deps.Repos.UserRepository,
 		deps.Repos.FileRepository,
 		deps.FileStorage,
+		deps.QuotaService,
+		deps.ScanService,
 		deps.AuthzService,
 		deps.Logger,
 	)
@@ -267,11 +566,55 @@ func BuildDependencies(cfg *config.Config, dbPool *pgxpool.Pool, lgr zerolog.Log
 		deps.Repos.UserRepository,
 		deps.Repos.FileRepository,
 		deps.FileStorage,
+		deps.QuotaService,
+		deps.ScanService,
 		deps.WSHub,
 		deps.Logger,
 	)
 
-	deps.AuthMiddleware = appMiddleware.NewAuthMiddleware(deps.JWTService, deps.Repos.UserRepository)
+	// Authorized and signed file downloads
+	signingKey := cfg.Storage.SigningKey
+	if signingKey == "" {
+		signingKey = cfg.JWT.Secret
+	}
+	deps.FileService = appServices.NewFileService(
+		deps.Repos.FileRepository,
+		deps.Repos.UserRepository,
+		deps.Repos.PastExamRepository,
+		deps.Repos.ClassNoteRepository,
+		deps.Repos.CommunityRepository,
+		deps.Repos.CommunityParticipantRepository,
+		pkgAuth.NewURLSigner(signingKey),
+		helpers.ParseDuration(cfg.Storage.SignedURLTTL, 15*time.Minute),
+		deps.Logger,
+	)
+
+	// Resumable uploads; expired sessions are removed in the background
+	deps.UploadService = appServices.NewUploadService(
+		deps.Repos.UploadSessionRepository,
+		deps.FileStorage,
+		deps.QuotaService,
+		helpers.ParseDuration(cfg.Storage.UploadTTL, 24*time.Hour),
+		deps.Logger,
+	)
+	go deps.UploadService.RunExpiryCleanup(time.Hour)
+
+	// Storage consistency check; cmd/check-storage runs the same check by hand
+	deps.StorageCheckService = appServices.NewStorageCheckService(
+		deps.Repos.FileRepository,
+		deps.Repos.UploadSessionRepository,
+		deps.Repos.BlobRepository,
+		deps.FileStorage,
+		deps.Logger,
+	)
+	if interval := helpers.ParseDuration(cfg.Storage.Check.Interval, 24*time.Hour); interval > 0 {
+		go deps.StorageCheckService.RunScheduled(interval, appServices.StorageCheckOptions{
+			DeleteOrphans: cfg.Storage.Check.Repair,
+			Repair:        cfg.Storage.Check.Repair,
+		})
+	}
+
+	deps.AuthMiddleware = appMiddleware.NewAuthMiddleware(deps.JWTService, deps.Repos.UserRepository, deps.Repos.TOTPRepository, deps.RevocationService, deps.PersonalAccessTokenService, deps.AuthzService, cfg.MFA.Enforce)
 
 	deps.AuthController = appControllers.NewAuthController(
 		deps.AuthService,
@@ -282,10 +625,23 @@ func BuildDependencies(cfg *config.Config, dbPool *pgxpool.Pool, lgr zerolog.Log
 	deps.FacultyController = appControllers.NewFacultyController(deps.FacultyService)
 	deps.DepartmentController = appControllers.NewDepartmentController(deps.DepartmentService)
 	deps.UserController = appControllers.NewUserController(deps.UserService, deps.FileStorage)
-	deps.PastExamController = appControllers.NewPastExamController(deps.PastExamService, deps.FileStorage)
-	deps.ClassNoteController = appControllers.NewClassNoteController(deps.ClassNoteService, deps.FileStorage)
+	deps.PastExamController = appControllers.NewPastExamController(deps.PastExamService, deps.UploadService, deps.FileStorage)
+	deps.ClassNoteController = appControllers.NewClassNoteController(deps.ClassNoteService, deps.UploadService, deps.FileStorage)
 	deps.CommunityController = appControllers.NewCommunityController(deps.CommunityService, deps.FileStorage)
-	deps.ChatController = appControllers.NewChatController(deps.ChatService)
+	deps.ChatController = appControllers.NewChatController(deps.ChatService, deps.UploadService)
+	deps.DuplicateController = appControllers.NewDuplicateController(deps.DuplicateService)
+	deps.FileController = appControllers.NewFileController(deps.FileService, deps.FileStorage)
+	deps.UploadController = appControllers.NewUploadController(deps.UploadService)
+	deps.QuarantineController = appControllers.NewQuarantineController(deps.ScanService)
+	deps.TwoFactorController = appControllers.NewTwoFactorController(deps.TwoFactorService)
+	deps.SessionController = appControllers.NewSessionController(deps.AuthService)
+	deps.LockoutController = appControllers.NewLockoutController(deps.ThrottleService)
+	deps.InstructorVerificationController = appControllers.NewInstructorVerificationController(deps.InstructorVerificationService)
+	deps.RoleAssignmentController = appControllers.NewRoleAssignmentController(deps.RoleAssignmentService)
+	deps.SSOController = appControllers.NewSSOController(deps.SSOService, deps.Logger)
+	deps.LDAPController = appControllers.NewLDAPController(deps.LDAPService, deps.Logger)
+	deps.PersonalAccessTokenController = appControllers.NewPersonalAccessTokenController(deps.PersonalAccessTokenService)
+	deps.AccountController = appControllers.NewAccountController(deps.AuthService)
 
 	return deps, nil
 }
@@ -312,8 +668,11 @@ func SetupRouter(cfg *config.Config, deps *Dependencies, lgr zerolog.Logger) *gi
 	// Setup static files for frontend
 	router.Static("/public", "./public")
 
-	// Serve uploaded files
-	router.Static("/uploads", cfg.Server.StoragePath)
+	// Serve legacy upload URLs; only public files (profile photos) are served this way.
+	// S3 objects are served by the bucket itself.
+	if cfg.Storage.Driver == config.StorageDriverLocal {
+		router.GET("/uploads/*filepath", deps.FileController.ServeUpload)
+	}
 
 	// Setup all API routes
 	appRoutes.SetupRouter(router,
@@ -325,6 +684,19 @@ func SetupRouter(cfg *config.Config, deps *Dependencies, lgr zerolog.Logger) *gi
 		deps.CommunityController,
 		deps.UserController,
 		deps.ChatController,
+		deps.DuplicateController,
+		deps.FileController,
+		deps.UploadController,
+		deps.QuarantineController,
+		deps.TwoFactorController,
+		deps.SessionController,
+		deps.LockoutController,
+		deps.InstructorVerificationController,
+		deps.RoleAssignmentController,
+		deps.SSOController,
+		deps.LDAPController,
+		deps.PersonalAccessTokenController,
+		deps.AccountController,
 		deps.WSHandler,
 		deps.AuthMiddleware,
 	)
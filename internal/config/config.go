@@ -18,6 +18,36 @@ type Config struct {
 		StoragePath string `yaml:"storage_path" env:"STORAGE_PATH"`
 	} `yaml:"server"`
 
+	Storage struct {
+		Driver       string `yaml:"driver" env:"STORAGE_DRIVER"`              // local or s3
+		SigningKey   string `yaml:"signing_key" env:"FILE_URL_SIGNING_KEY"`   // HMAC key for signed download URLs; defaults to the JWT secret
+		SignedURLTTL string `yaml:"signed_url_ttl" env:"FILE_SIGNED_URL_TTL"` // Maximum lifetime of a signed download URL
+		UploadTTL    string `yaml:"upload_ttl" env:"UPLOAD_SESSION_TTL"`      // Idle lifetime of a resumable upload session
+		S3           struct {
+			Endpoint  string `yaml:"endpoint" env:"S3_ENDPOINT"`
+			Region    string `yaml:"region" env:"S3_REGION"`
+			Bucket    string `yaml:"bucket" env:"S3_BUCKET"`
+			AccessKey string `yaml:"access_key" env:"S3_ACCESS_KEY"`
+			SecretKey string `yaml:"secret_key" env:"S3_SECRET_KEY"`
+			UseSSL    bool   `yaml:"use_ssl" env:"S3_USE_SSL"`
+			PublicURL string `yaml:"public_url" env:"S3_PUBLIC_URL"` // Defaults to the path-style bucket URL
+		} `yaml:"s3"`
+		Quotas struct { // Storage limits in megabytes; 0 means unlimited
+			StudentMB    int64 `yaml:"student_mb" env:"QUOTA_STUDENT_MB"`
+			InstructorMB int64 `yaml:"instructor_mb" env:"QUOTA_INSTRUCTOR_MB"`
+			AdminMB      int64 `yaml:"admin_mb" env:"QUOTA_ADMIN_MB"`
+			CommunityMB  int64 `yaml:"community_mb" env:"QUOTA_COMMUNITY_MB"` // Chat attachments per community
+		} `yaml:"quotas"`
+		Check struct { // Scheduled storage consistency check
+			Interval string `yaml:"interval" env:"STORAGE_CHECK_INTERVAL"` // 0 disables the scheduled check
+			Repair   bool   `yaml:"repair" env:"STORAGE_CHECK_REPAIR"`     // Delete and repair what the check finds instead of only reporting it
+		} `yaml:"check"`
+		Scan struct { // Malware scanning of uploads with ClamAV
+			ClamdAddress string `yaml:"clamd_address" env:"CLAMD_ADDRESS"` // host:port of clamd; empty disables scanning
+			Timeout      string `yaml:"timeout" env:"CLAMD_TIMEOUT"`       // Maximum duration of a single scan
+		} `yaml:"scan"`
+	} `yaml:"storage"`
+
 	Database struct {
 		Driver          string `yaml:"driver" env:"DB_DRIVER"`
 		Host            string `yaml:"host" env:"DB_HOST"`
@@ -36,8 +66,75 @@ type Config struct {
 		AccessTokenExpiration  string `yaml:"access_token_expiration" env:"JWT_ACCESS_TOKEN_EXPIRATION"`
 		RefreshTokenExpiration string `yaml:"refresh_token_expiration" env:"JWT_REFRESH_TOKEN_EXPIRATION"`
 		Issuer                 string `yaml:"issuer" env:"JWT_ISSUER"`
+		Algorithm              string `yaml:"algorithm" env:"JWT_ALGORITHM"`                         // HS256, RS256 or EdDSA
+		KeyRotationInterval    string `yaml:"key_rotation_interval" env:"JWT_KEY_ROTATION_INTERVAL"` // How often RS256/EdDSA keys are replaced
+		KeyGracePeriod         string `yaml:"key_grace_period" env:"JWT_KEY_GRACE_PERIOD"`           // How long a replaced key still verifies tokens
 	} `yaml:"jwt"`
 
+	MFA struct { // TOTP two-factor authentication
+		Issuer        string `yaml:"issuer" env:"MFA_ISSUER"`                 // Account issuer shown in authenticator apps
+		EncryptionKey string `yaml:"encryption_key" env:"MFA_ENCRYPTION_KEY"` // Key TOTP secrets are encrypted with; defaults to the JWT secret
+		ChallengeTTL  string `yaml:"challenge_ttl" env:"MFA_CHALLENGE_TTL"`   // Lifetime of the token between the password and the code
+		Enforce       bool   `yaml:"enforce" env:"MFA_ENFORCE"`               // Keep instructors and admins without 2FA out of their role's routes
+	} `yaml:"mfa"`
+
+	Registration struct { // How new accounts get their role
+		RoleStrategy   string `yaml:"role_strategy" env:"REGISTRATION_ROLE_STRATEGY"`     // student_number or student_domain; other accounts wait for admin approval as instructors
+		StudentDomains string `yaml:"student_domains" env:"REGISTRATION_STUDENT_DOMAINS"` // Comma-separated email domains of students, for student_domain
+	} `yaml:"registration"`
+
+	OIDC struct { // Single sign-on with an OpenID Connect provider
+		IssuerURL            string `yaml:"issuer_url" env:"OIDC_ISSUER_URL"`                         // Provider issuer; empty disables single sign-on
+		ClientID             string `yaml:"client_id" env:"OIDC_CLIENT_ID"`                           // Client registered at the provider
+		ClientSecret         string `yaml:"client_secret" env:"OIDC_CLIENT_SECRET"`                   // Empty for public clients, which rely on PKCE alone
+		RedirectURL          string `yaml:"redirect_url" env:"OIDC_REDIRECT_URL"`                     // Frontend page the provider sends the code back to
+		Scopes               string `yaml:"scopes" env:"OIDC_SCOPES"`                                 // Comma-separated scopes to request
+		StateTTL             string `yaml:"state_ttl" env:"OIDC_STATE_TTL"`                           // Time to finish a login at the provider
+		AllowUnverifiedEmail bool   `yaml:"allow_unverified_email" env:"OIDC_ALLOW_UNVERIFIED_EMAIL"` // Trust emails the provider does not mark email_verified
+
+		// Claim names mapped to user fields; dotted names reach into nested claims
+		Claims struct {
+			Email         string `yaml:"email" env:"OIDC_CLAIM_EMAIL"`
+			FirstName     string `yaml:"first_name" env:"OIDC_CLAIM_FIRST_NAME"`
+			LastName      string `yaml:"last_name" env:"OIDC_CLAIM_LAST_NAME"`
+			Department    string `yaml:"department" env:"OIDC_CLAIM_DEPARTMENT"`         // Department code; empty leaves the department unset
+			StudentNumber string `yaml:"student_number" env:"OIDC_CLAIM_STUDENT_NUMBER"` // Present for students; empty falls back to the registration role strategy
+		} `yaml:"claims"`
+	} `yaml:"oidc"`
+
+	LDAP struct { // Login against an LDAP directory, tried before the local password
+		URL                string `yaml:"url" env:"LDAP_URL"`                                   // ldap:// or ldaps:// URL of the directory; empty disables directory logins
+		StartTLS           bool   `yaml:"start_tls" env:"LDAP_START_TLS"`                       // Upgrade ldap:// connections with StartTLS
+		InsecureSkipVerify bool   `yaml:"insecure_skip_verify" env:"LDAP_INSECURE_SKIP_VERIFY"` // Accept any server certificate; for testing only
+		BindDN             string `yaml:"bind_dn" env:"LDAP_BIND_DN"`                           // Service account that searches for users; empty searches anonymously
+		BindPassword       string `yaml:"bind_password" env:"LDAP_BIND_PASSWORD"`               // Should be set via environment variable
+		BaseDN             string `yaml:"base_dn" env:"LDAP_BASE_DN"`                           // Subtree users are searched in
+		UserFilter         string `yaml:"user_filter" env:"LDAP_USER_FILTER"`                   // %s is replaced by the escaped login email; the sync uses it with %s as *
+		IDAttribute        string `yaml:"id_attribute" env:"LDAP_ID_ATTRIBUTE"`                 // Stable entry ID accounts are linked by; empty uses the DN
+		StudentRoles       string `yaml:"student_roles" env:"LDAP_STUDENT_ROLES"`               // Comma-separated role attribute values of students
+		InstructorRoles    string `yaml:"instructor_roles" env:"LDAP_INSTRUCTOR_ROLES"`         // Comma-separated role attribute values of instructors
+		Timeout            string `yaml:"timeout" env:"LDAP_TIMEOUT"`                           // Per directory operation
+		SyncInterval       string `yaml:"sync_interval" env:"LDAP_SYNC_INTERVAL"`               // How often linked users are synced with the directory; 0 disables
+
+		// Directory attributes mapped to user fields
+		Attributes struct {
+			Email      string `yaml:"email" env:"LDAP_ATTRIBUTE_EMAIL"`
+			FirstName  string `yaml:"first_name" env:"LDAP_ATTRIBUTE_FIRST_NAME"`
+			LastName   string `yaml:"last_name" env:"LDAP_ATTRIBUTE_LAST_NAME"`
+			Department string `yaml:"department" env:"LDAP_ATTRIBUTE_DEPARTMENT"` // Department code; empty leaves departments alone
+			Role       string `yaml:"role" env:"LDAP_ATTRIBUTE_ROLE"`             // Matched against the student and instructor roles; empty uses the registration role strategy
+		} `yaml:"attributes"`
+	} `yaml:"ldap"`
+
+	Throttle struct { // Brute-force protection for login, forgot-password and resend-verification
+		LoginFreeAttempts int    `yaml:"login_free_attempts" env:"THROTTLE_LOGIN_FREE_ATTEMPTS"` // Failed logins per account before backoff starts
+		IPFreeAttempts    int    `yaml:"ip_free_attempts" env:"THROTTLE_IP_FREE_ATTEMPTS"`       // Failed logins or email requests per IP before backoff starts
+		LockoutThreshold  int    `yaml:"lockout_threshold" env:"THROTTLE_LOCKOUT_THRESHOLD"`     // Failed logins that lock an account; 0 disables lockout
+		LockoutDuration   string `yaml:"lockout_duration" env:"THROTTLE_LOCKOUT_DURATION"`       // How long a lockout lasts unless an admin lifts it
+		Window            string `yaml:"window" env:"THROTTLE_WINDOW"`                           // Counters start over after this long without attempts
+		EmailsPerWindow   int    `yaml:"emails_per_window" env:"THROTTLE_EMAILS_PER_WINDOW"`     // Password reset and verification emails per account
+	} `yaml:"throttle"`
+
 	Logging struct {
 		Level  string `yaml:"level" env:"LOG_LEVEL"`
 		Format string `yaml:"format" env:"LOG_FORMAT"`
@@ -54,6 +151,12 @@ type Config struct {
 	} `yaml:"smtp"`
 }
 
+// Storage drivers
+const (
+	StorageDriverLocal = "local"
+	StorageDriverS3    = "s3"
+)
+
 // LoadConfig loads configuration from a file and environment variables
 func LoadConfig(configPath string) (*Config, error) {
 	config := &Config{}
@@ -88,6 +191,16 @@ func setDefaults(config *Config) {
 	config.Server.Mode = "development"
 	config.Server.StoragePath = "./uploads"
 
+	config.Storage.Driver = StorageDriverLocal
+	config.Storage.SignedURLTTL = "15m"
+	config.Storage.UploadTTL = "24h"
+	config.Storage.S3.Region = "us-east-1"
+	config.Storage.Quotas.StudentMB = 1024
+	config.Storage.Quotas.InstructorMB = 10240
+	config.Storage.Quotas.CommunityMB = 5120
+	config.Storage.Check.Interval = "24h"
+	config.Storage.Scan.Timeout = "2m"
+
 	config.Database.Driver = "postgres"
 	config.Database.Host = "localhost"
 	config.Database.Port = "5432"
@@ -101,6 +214,38 @@ func setDefaults(config *Config) {
 	config.JWT.AccessTokenExpiration = "1h"
 	config.JWT.RefreshTokenExpiration = "720h"
 	config.JWT.Issuer = "unisphere.app"
+	config.JWT.Algorithm = "HS256"
+	config.JWT.KeyRotationInterval = "720h"
+	config.JWT.KeyGracePeriod = "24h"
+
+	config.MFA.Issuer = "UniSphere"
+	config.MFA.ChallengeTTL = "5m"
+
+	config.Registration.RoleStrategy = "student_number"
+
+	config.OIDC.Scopes = "openid,email,profile"
+	config.OIDC.StateTTL = "10m"
+	config.OIDC.Claims.Email = "email"
+	config.OIDC.Claims.FirstName = "given_name"
+	config.OIDC.Claims.LastName = "family_name"
+
+	config.LDAP.UserFilter = "(mail=%s)"
+	config.LDAP.IDAttribute = "entryUUID"
+	config.LDAP.StudentRoles = "student"
+	config.LDAP.InstructorRoles = "faculty"
+	config.LDAP.Timeout = "10s"
+	config.LDAP.SyncInterval = "1h"
+	config.LDAP.Attributes.Email = "mail"
+	config.LDAP.Attributes.FirstName = "givenName"
+	config.LDAP.Attributes.LastName = "sn"
+	config.LDAP.Attributes.Role = "eduPersonAffiliation"
+
+	config.Throttle.LoginFreeAttempts = 5
+	config.Throttle.IPFreeAttempts = 50
+	config.Throttle.LockoutThreshold = 10
+	config.Throttle.LockoutDuration = "30m"
+	config.Throttle.Window = "1h"
+	config.Throttle.EmailsPerWindow = 3
 
 	config.Logging.Level = "info"
 	config.Logging.Format = "text"
@@ -156,6 +301,66 @@ func validateConfig(config *Config) error {
 	if _, err := time.ParseDuration(config.JWT.RefreshTokenExpiration); err != nil {
 		return fmt.Errorf("invalid JWT refresh token expiration format (JWT_REFRESH_TOKEN_EXPIRATION): %w", err)
 	}
+	if _, err := time.ParseDuration(config.JWT.KeyRotationInterval); err != nil {
+		return fmt.Errorf("invalid JWT key rotation interval format (JWT_KEY_ROTATION_INTERVAL): %w", err)
+	}
+	keyGracePeriod, err := time.ParseDuration(config.JWT.KeyGracePeriod)
+	if err != nil {
+		return fmt.Errorf("invalid JWT key grace period format (JWT_KEY_GRACE_PERIOD): %w", err)
+	}
+	if accessTokenExpiration, _ := time.ParseDuration(config.JWT.AccessTokenExpiration); keyGracePeriod < accessTokenExpiration {
+		return fmt.Errorf("JWT key grace period (JWT_KEY_GRACE_PERIOD) must be at least the access token expiration")
+	}
+	if _, err := time.ParseDuration(config.MFA.ChallengeTTL); err != nil {
+		return fmt.Errorf("invalid MFA challenge lifetime format (MFA_CHALLENGE_TTL): %w", err)
+	}
+	if _, err := time.ParseDuration(config.OIDC.StateTTL); err != nil {
+		return fmt.Errorf("invalid single sign-on state lifetime format (OIDC_STATE_TTL): %w", err)
+	}
+	if config.OIDC.IssuerURL != "" && (config.OIDC.ClientID == "" || config.OIDC.RedirectURL == "") {
+		return fmt.Errorf("OIDC client ID (OIDC_CLIENT_ID) and redirect URL (OIDC_REDIRECT_URL) are required when OIDC_ISSUER_URL is set")
+	}
+	if config.OIDC.IssuerURL != "" && config.OIDC.Claims.Email == "" {
+		return fmt.Errorf("OIDC email claim (OIDC_CLAIM_EMAIL) is required when OIDC_ISSUER_URL is set")
+	}
+	if _, err := time.ParseDuration(config.LDAP.Timeout); err != nil {
+		return fmt.Errorf("invalid LDAP timeout format (LDAP_TIMEOUT): %w", err)
+	}
+	if _, err := time.ParseDuration(config.LDAP.SyncInterval); err != nil {
+		return fmt.Errorf("invalid LDAP sync interval format (LDAP_SYNC_INTERVAL): %w", err)
+	}
+	if config.LDAP.URL != "" {
+		if !strings.HasPrefix(config.LDAP.URL, "ldap://") && !strings.HasPrefix(config.LDAP.URL, "ldaps://") {
+			return fmt.Errorf("invalid LDAP URL '%s' (LDAP_URL): must start with ldap:// or ldaps://", config.LDAP.URL)
+		}
+		if config.LDAP.BaseDN == "" {
+			return fmt.Errorf("LDAP base DN (LDAP_BASE_DN) is required when LDAP_URL is set")
+		}
+		if strings.Count(config.LDAP.UserFilter, "%s") != 1 {
+			return fmt.Errorf("LDAP user filter (LDAP_USER_FILTER) must contain %%s exactly once")
+		}
+	}
+	if _, err := time.ParseDuration(config.Throttle.LockoutDuration); err != nil {
+		return fmt.Errorf("invalid lockout duration format (THROTTLE_LOCKOUT_DURATION): %w", err)
+	}
+	if _, err := time.ParseDuration(config.Throttle.Window); err != nil {
+		return fmt.Errorf("invalid throttle window format (THROTTLE_WINDOW): %w", err)
+	}
+	if config.Throttle.LoginFreeAttempts < 0 || config.Throttle.IPFreeAttempts < 0 || config.Throttle.LockoutThreshold < 0 || config.Throttle.EmailsPerWindow < 0 {
+		return fmt.Errorf("throttle attempt limits (THROTTLE_*) must not be negative")
+	}
+	if _, err := time.ParseDuration(config.Storage.SignedURLTTL); err != nil {
+		return fmt.Errorf("invalid signed URL lifetime format (FILE_SIGNED_URL_TTL): %w", err)
+	}
+	if _, err := time.ParseDuration(config.Storage.UploadTTL); err != nil {
+		return fmt.Errorf("invalid upload session lifetime format (UPLOAD_SESSION_TTL): %w", err)
+	}
+	if _, err := time.ParseDuration(config.Storage.Check.Interval); err != nil {
+		return fmt.Errorf("invalid storage check interval format (STORAGE_CHECK_INTERVAL): %w", err)
+	}
+	if _, err := time.ParseDuration(config.Storage.Scan.Timeout); err != nil {
+		return fmt.Errorf("invalid malware scan timeout format (CLAMD_TIMEOUT): %w", err)
+	}
 	if _, err := time.ParseDuration(config.Database.ConnMaxLifetime); err != nil {
 		return fmt.Errorf("invalid database connection max lifetime format (DB_CONN_MAX_LIFETIME): %w", err)
 	}
@@ -166,6 +371,49 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("invalid server mode '%s' (SERVER_MODE): must be 'development' or 'production'", config.Server.Mode)
 	}
 
+	// Validate registration role strategy
+	switch config.Registration.RoleStrategy {
+	case "student_number":
+	case "student_domain":
+		if strings.TrimSpace(config.Registration.StudentDomains) == "" {
+			return fmt.Errorf("registration role strategy 'student_domain' needs student domains (REGISTRATION_STUDENT_DOMAINS)")
+		}
+	default:
+		return fmt.Errorf("invalid registration role strategy '%s' (REGISTRATION_ROLE_STRATEGY): must be 'student_number' or 'student_domain'", config.Registration.RoleStrategy)
+	}
+
+	// Validate JWT signing algorithm
+	switch strings.ToUpper(config.JWT.Algorithm) {
+	case "HS256":
+		config.JWT.Algorithm = "HS256"
+	case "RS256":
+		config.JWT.Algorithm = "RS256"
+	case "EDDSA":
+		config.JWT.Algorithm = "EdDSA"
+	default:
+		return fmt.Errorf("invalid JWT algorithm '%s' (JWT_ALGORITHM): must be 'HS256', 'RS256' or 'EdDSA'", config.JWT.Algorithm)
+	}
+
+	// Validate storage backend
+	config.Storage.Driver = strings.ToLower(config.Storage.Driver)
+	switch config.Storage.Driver {
+	case StorageDriverLocal:
+	case StorageDriverS3:
+		if config.Storage.S3.Endpoint == "" || config.Storage.S3.Bucket == "" {
+			return fmt.Errorf("S3 endpoint (S3_ENDPOINT) and bucket (S3_BUCKET) are required when STORAGE_DRIVER is 's3'")
+		}
+		if config.Storage.S3.AccessKey == "" || config.Storage.S3.SecretKey == "" {
+			return fmt.Errorf("S3 credentials (S3_ACCESS_KEY, S3_SECRET_KEY) are required and should be set via environment variables")
+		}
+	default:
+		return fmt.Errorf("invalid storage driver '%s' (STORAGE_DRIVER): must be 'local' or 's3'", config.Storage.Driver)
+	}
+
+	quotas := config.Storage.Quotas
+	if quotas.StudentMB < 0 || quotas.InstructorMB < 0 || quotas.AdminMB < 0 || quotas.CommunityMB < 0 {
+		return fmt.Errorf("storage quotas (QUOTA_*_MB) must not be negative")
+	}
+
 	// Validate log level
 	level := strings.ToLower(config.Logging.Level)
 	if level != "debug" && level != "info" && level != "warn" && level != "error" {
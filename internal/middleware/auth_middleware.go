@@ -3,30 +3,46 @@ package middleware
 import (
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	appAuth "github.com/yigit/unisphere/internal/app/auth"
+	"github.com/yigit/unisphere/internal/app/models"
 	"github.com/yigit/unisphere/internal/app/models/dto"
 	"github.com/yigit/unisphere/internal/app/repositories"
+	"github.com/yigit/unisphere/internal/app/services"
 	"github.com/yigit/unisphere/internal/pkg/apperrors"
 	"github.com/yigit/unisphere/internal/pkg/auth"
 )
 
 // AuthMiddleware for authentication and authorization
 type AuthMiddleware struct {
-	jwtService *auth.JWTService
-	userRepo   *repositories.UserRepository
+	jwtService           *auth.JWTService
+	userRepo             *repositories.UserRepository
+	totpRepo             *repositories.TOTPRepository
+	revocations          services.TokenRevocationService
+	personalAccessTokens services.PersonalAccessTokenService
+	authz                *appAuth.AuthorizationService
+	enforceMFA           bool
 }
 
-// NewAuthMiddleware creates a new AuthMiddleware
-func NewAuthMiddleware(jwtService *auth.JWTService, userRepo *repositories.UserRepository) *AuthMiddleware {
+// NewAuthMiddleware creates a new AuthMiddleware. With enforceMFA, MFARequired keeps
+// instructors and admins without two-factor authentication out.
+func NewAuthMiddleware(jwtService *auth.JWTService, userRepo *repositories.UserRepository, totpRepo *repositories.TOTPRepository, revocations services.TokenRevocationService, personalAccessTokens services.PersonalAccessTokenService, authz *appAuth.AuthorizationService, enforceMFA bool) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtService: jwtService,
-		userRepo:   userRepo,
+		jwtService:           jwtService,
+		userRepo:             userRepo,
+		totpRepo:             totpRepo,
+		revocations:          revocations,
+		personalAccessTokens: personalAccessTokens,
+		authz:                authz,
+		enforceMFA:           enforceMFA,
 	}
 }
 
-// JWTAuth middleware for JWT token validation
+// JWTAuth middleware for JWT token validation. It also accepts personal access tokens, on the
+// routes their scopes allow.
 func (m *AuthMiddleware) JWTAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var tokenString string
@@ -70,7 +86,7 @@ func (m *AuthMiddleware) JWTAuth() gin.HandlerFunc {
 				authHeader = strings.Trim(authHeader, "\"'")
 				if strings.HasPrefix(authHeader, "Bearer ") {
 					tokenString = strings.TrimPrefix(authHeader, "Bearer ")
-				} else if strings.Count(authHeader, ".") == 2 {
+				} else if strings.Count(authHeader, ".") == 2 || auth.IsPersonalAccessToken(authHeader) {
 					// It might still be a raw token
 					tokenString = authHeader
 				} else {
@@ -83,6 +99,11 @@ func (m *AuthMiddleware) JWTAuth() gin.HandlerFunc {
 			}
 		}
 
+		if auth.IsPersonalAccessToken(tokenString) {
+			m.personalAccessTokenAuth(c, tokenString)
+			return
+		}
+
 		// Validate and extract claims using the new method
 		claims, err := m.jwtService.ValidateAndExtractClaims(tokenString)
 		if err != nil {
@@ -107,15 +128,81 @@ func (m *AuthMiddleware) JWTAuth() gin.HandlerFunc {
 			return
 		}
 
+		// Reject tokens revoked before they expired (logout, password reset, deleted user)
+		if m.revocations.IsRevoked(claims) {
+			errorDetail := dto.NewErrorDetail(dto.ErrorCodeInvalidToken, "Authentication failed")
+			errorDetail = errorDetail.WithDetails("Token has been revoked")
+			errorDetail = errorDetail.WithSeverity(dto.ErrorSeverityError)
+
+			c.AbortWithStatusJSON(http.StatusUnauthorized, dto.NewErrorResponse(errorDetail))
+			return
+		}
+
 		// Add user information to context if token is valid
 		c.Set("userID", claims.UserID)
 		c.Set("email", claims.Email)
 		c.Set("roleType", claims.RoleType)
+		c.Set("sessionID", claims.SessionID)
+		c.Set("tokenID", claims.ID)
+		if claims.ExpiresAt != nil {
+			c.Set("tokenExpiresAt", claims.ExpiresAt.Time)
+		}
 
 		c.Next()
 	}
 }
 
+// personalAccessTokenAuth authenticates a request made with a personal access token. The
+// token must be usable and have the scope the route needs; routes without one refuse tokens.
+func (m *AuthMiddleware) personalAccessTokenAuth(c *gin.Context, tokenString string) {
+	token, user, err := m.personalAccessTokens.Authenticate(c.Request.Context(), tokenString)
+	if err != nil {
+		errorCode := dto.ErrorCodeInvalidToken
+		errorDetails := "Invalid personal access token"
+		switch {
+		case errors.Is(err, apperrors.ErrTokenExpired):
+			errorCode = dto.ErrorCodeExpiredToken
+			errorDetails = "Personal access token has expired"
+		case errors.Is(err, apperrors.ErrTokenRevoked):
+			errorDetails = "Personal access token has been revoked"
+		case errors.Is(err, apperrors.ErrAccountDisabled):
+			errorDetails = "Account is disabled"
+		case !errors.Is(err, apperrors.ErrTokenNotFound) && !errors.Is(err, apperrors.ErrUserNotFound):
+			errorDetail := dto.NewErrorDetail(dto.ErrorCodeInternalServer, "Internal server error")
+			errorDetail = errorDetail.WithDetails("Failed to check personal access token")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, dto.NewErrorResponse(errorDetail))
+			return
+		}
+
+		errorDetail := dto.NewErrorDetail(errorCode, "Authentication failed")
+		errorDetail = errorDetail.WithDetails(errorDetails)
+		errorDetail = errorDetail.WithSeverity(dto.ErrorSeverityError)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, dto.NewErrorResponse(errorDetail))
+		return
+	}
+
+	scope, allowed := requiredTokenScope(c.Request.Method, c.FullPath())
+	if !allowed || !hasTokenScope(token.Scopes, scope) {
+		errorDetail := dto.NewErrorDetail(dto.ErrorCodeForbidden, "Access denied")
+		if allowed {
+			errorDetail = errorDetail.WithDetails("This personal access token needs the " + string(scope) + " scope for this operation")
+		} else {
+			errorDetail = errorDetail.WithDetails("Personal access tokens cannot be used for this operation")
+		}
+		errorDetail = errorDetail.WithSeverity(dto.ErrorSeverityError)
+		c.AbortWithStatusJSON(http.StatusForbidden, dto.NewErrorResponse(errorDetail))
+		return
+	}
+
+	// Add user information to context; there is no session or access token ID to revoke
+	c.Set("userID", user.ID)
+	c.Set("email", user.Email)
+	c.Set("roleType", string(user.RoleType))
+	c.Set("personalAccessTokenID", token.ID)
+
+	c.Next()
+}
+
 // EmailVerificationRequired middleware to check if user's email is verified
 func (m *AuthMiddleware) EmailVerificationRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -158,26 +245,126 @@ func (m *AuthMiddleware) EmailVerificationRequired() gin.HandlerFunc {
 	}
 }
 
-// RoleRequired middleware to check if user has required role
-func (m *AuthMiddleware) RoleRequired(requiredRole string) gin.HandlerFunc {
+// ScopeResolver finds the scope a request acts in. A nil scope accepts the permission in
+// any scope, for routes whose service checks the exact scope itself.
+type ScopeResolver func(c *gin.Context) (*appAuth.Scope, error)
+
+// GlobalScope resolves to the global scope
+func GlobalScope() ScopeResolver {
+	return func(c *gin.Context) (*appAuth.Scope, error) {
+		scope := appAuth.GlobalScope
+		return &scope, nil
+	}
+}
+
+// AnyScope resolves to a nil scope, accepting the permission in any scope
+func AnyScope() ScopeResolver {
+	return func(c *gin.Context) (*appAuth.Scope, error) {
+		return nil, nil
+	}
+}
+
+// ParamScope resolves to a scope of scopeType whose ID is the path parameter param
+func ParamScope(scopeType, param string) ScopeResolver {
+	return func(c *gin.Context) (*appAuth.Scope, error) {
+		id, err := strconv.ParseInt(c.Param(param), 10, 64)
+		if err != nil || id <= 0 {
+			return nil, apperrors.NewBadRequestError("Invalid " + param)
+		}
+		return &appAuth.Scope{Type: scopeType, ID: id}, nil
+	}
+}
+
+// PastExamScope resolves to the department of the past exam whose ID is the path parameter
+// param
+func (m *AuthMiddleware) PastExamScope(param string) ScopeResolver {
+	return func(c *gin.Context) (*appAuth.Scope, error) {
+		id, err := strconv.ParseInt(c.Param(param), 10, 64)
+		if err != nil || id <= 0 {
+			return nil, apperrors.NewBadRequestError("Invalid past exam ID")
+		}
+		return m.authz.PastExamScope(c.Request.Context(), id)
+	}
+}
+
+// PermissionRequired middleware to check that the user has a permission in the scope of the
+// request, through the role of their account or a role assignment
+func (m *AuthMiddleware) PermissionRequired(permission appAuth.Permission, resolveScope ScopeResolver) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Ensure JWTAuth middleware has run first
-		role, exists := c.Get("roleType")
-		if !exists {
+		userID, ok := c.Get("userID")
+		userIDInt, isInt := userID.(int64)
+		if !ok || !isInt {
 			errorDetail := dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")
-			errorDetail = errorDetail.WithDetails("User role not found")
-
+			errorDetail = errorDetail.WithDetails("User information not found")
 			c.AbortWithStatusJSON(http.StatusUnauthorized, dto.NewErrorResponse(errorDetail))
 			return
 		}
 
-		// Compare roles
-		roleStr, ok := role.(string)
-		if !ok || roleStr != requiredRole {
-			errorDetail := dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Access denied")
-			errorDetail = errorDetail.WithDetails("You don't have sufficient permissions for this operation")
+		scope, err := resolveScope(c)
+		if err != nil {
+			HandleAPIError(c, err)
+			c.Abort()
+			return
+		}
+
+		allowed, err := m.authz.HasPermission(c.Request.Context(), userIDInt, permission, scope)
+		if err != nil {
+			errorDetail := dto.NewErrorDetail(dto.ErrorCodeInternalServer, "Internal server error")
+			errorDetail = errorDetail.WithDetails("Failed to check permissions")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, dto.NewErrorResponse(errorDetail))
+			return
+		}
+
+		if !allowed {
+			errorDetail := dto.NewErrorDetail(dto.ErrorCodeForbidden, "Access denied")
+			errorDetail = errorDetail.WithDetails("You need the " + string(permission) + " permission for this operation")
 			errorDetail = errorDetail.WithSeverity(dto.ErrorSeverityError)
+			c.AbortWithStatusJSON(http.StatusForbidden, dto.NewErrorResponse(errorDetail))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// MFARequired middleware to check that users whose role requires two-factor authentication
+// have enabled it. It does nothing unless enforcement is configured.
+func (m *AuthMiddleware) MFARequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.enforceMFA {
+			c.Next()
+			return
+		}
+
+		// Ensure JWTAuth middleware has run first
+		role, _ := c.Get("roleType")
+		userID, ok := c.Get("userID")
+		userIDInt, isInt := userID.(int64)
+		if !ok || !isInt {
+			errorDetail := dto.NewErrorDetail(dto.ErrorCodeUnauthorized, "Authentication required")
+			errorDetail = errorDetail.WithDetails("User information not found")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, dto.NewErrorResponse(errorDetail))
+			return
+		}
+
+		roleStr, _ := role.(string)
+		if !models.RoleRequiresMFA(models.RoleType(roleStr)) {
+			c.Next()
+			return
+		}
+
+		enabled, err := m.totpRepo.IsEnabled(c.Request.Context(), userIDInt)
+		if err != nil {
+			errorDetail := dto.NewErrorDetail(dto.ErrorCodeInternalServer, "Internal server error")
+			errorDetail = errorDetail.WithDetails("Failed to check two-factor authentication status")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, dto.NewErrorResponse(errorDetail))
+			return
+		}
 
+		if !enabled {
+			errorDetail := dto.NewErrorDetail(dto.ErrorCodeMFARequired, "Two-factor authentication required")
+			errorDetail = errorDetail.WithDetails("Enable two-factor authentication in your profile to access this resource")
 			c.AbortWithStatusJSON(http.StatusForbidden, dto.NewErrorResponse(errorDetail))
 			return
 		}
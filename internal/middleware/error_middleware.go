@@ -3,6 +3,7 @@ package middleware
 import (
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/yigit/unisphere/internal/app/models/dto"
@@ -70,8 +71,13 @@ func HandleAPIError(c *gin.Context, err error) {
 		
 	// Authorization/Permission errors
 	case errors.Is(err, apperrors.ErrPermissionDenied):
+		message := "Permission denied"
+		var customErr *apperrors.CustomError
+		if errors.As(err, &customErr) && customErr.Message != "" {
+			message = customErr.Message
+		}
 		c.JSON(http.StatusForbidden, dto.NewErrorResponse(
-			dto.NewErrorDetail(dto.ErrorCodeForbidden, "Permission denied")))
+			dto.NewErrorDetail(dto.ErrorCodeForbidden, message)))
 		return
 	
 	// Authentication errors
@@ -99,6 +105,29 @@ func HandleAPIError(c *gin.Context, err error) {
 		c.JSON(http.StatusForbidden, dto.NewErrorResponse(
 			dto.NewErrorDetail(dto.ErrorCodeForbidden, "Account is disabled")))
 		return
+	case errors.Is(err, apperrors.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidMFACode, "Invalid two-factor authentication code")))
+		return
+	case errors.Is(err, apperrors.ErrMFARequired):
+		c.JSON(http.StatusForbidden, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeMFARequired, "Two-factor authentication required")))
+		return
+	case errors.Is(err, apperrors.ErrTooManyAttempts) || errors.Is(err, apperrors.ErrAccountLocked):
+		code := dto.ErrorCodeTooManyAttempts
+		if errors.Is(err, apperrors.ErrAccountLocked) {
+			code = dto.ErrorCodeAccountLocked
+		}
+		errorDetail := dto.NewErrorDetail(code, err.Error())
+		var customErr *apperrors.CustomError
+		if errors.As(err, &customErr) && customErr.Details != nil {
+			if retryAfter, ok := customErr.Details["retryAfterSeconds"].(int64); ok {
+				c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+			}
+			errorDetail = errorDetail.WithDetails(customErr.Details)
+		}
+		c.JSON(http.StatusTooManyRequests, dto.NewErrorResponse(errorDetail))
+		return
 			
 	// Email verification errors
 	case errors.Is(err, apperrors.ErrEmailNotVerified):
@@ -116,10 +145,28 @@ func HandleAPIError(c *gin.Context, err error) {
 	
 	// Validation errors
 	case errors.Is(err, apperrors.ErrValidationFailed) || errors.Is(err, apperrors.ErrInvalidPassword):
+		var customErr *apperrors.CustomError
+		if errors.As(err, &customErr) && customErr.Code != "" {
+			errorDetail := dto.NewErrorDetail(dto.ErrorCode(customErr.Code), customErr.Message)
+			if customErr.Details != nil {
+				errorDetail = errorDetail.WithDetails(customErr.Details)
+			}
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(errorDetail))
+			return
+		}
 		errorDetail := dto.NewErrorDetail(dto.ErrorCodeValidationFailed, "Validation failed")
 		errorDetail = errorDetail.WithDetails(err.Error())
 		c.JSON(http.StatusBadRequest, dto.NewErrorResponse(errorDetail))
 		return
+	case errors.Is(err, apperrors.ErrBadRequest):
+		message := "Bad request"
+		var customErr *apperrors.CustomError
+		if errors.As(err, &customErr) && customErr.Message != "" {
+			message = customErr.Message
+		}
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeInvalidRequest, message)))
+		return
 	case errors.Is(err, apperrors.ErrInvalidEmail):
 		c.JSON(http.StatusBadRequest, dto.NewErrorResponse(
 			dto.NewErrorDetail(dto.ErrorCodeInvalidEmail, "Invalid email format")))
@@ -146,6 +193,15 @@ func HandleAPIError(c *gin.Context, err error) {
 		c.JSON(http.StatusConflict, dto.NewErrorResponse(
 			dto.NewErrorDetail(dto.ErrorCodeResourceAlreadyExists, "Student ID already exists")))
 		return
+	case errors.Is(err, apperrors.ErrConflict):
+		message := "Conflict"
+		var customErr *apperrors.CustomError
+		if errors.As(err, &customErr) && customErr.Message != "" {
+			message = customErr.Message
+		}
+		c.JSON(http.StatusConflict, dto.NewErrorResponse(
+			dto.NewErrorDetail(dto.ErrorCodeResourceInvalid, message)))
+		return
 	case errors.Is(err, apperrors.ErrResourceAlreadyExists):
 		c.JSON(http.StatusConflict, dto.NewErrorResponse(
 			dto.NewErrorDetail(dto.ErrorCodeResourceAlreadyExists, "Resource already exists")))
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	appAuth "github.com/yigit/unisphere/internal/app/auth"
+)
+
+// tokenScopeRule gives the scopes personal access tokens need for a route, or for the routes
+// under it when subtree is set
+type tokenScopeRule struct {
+	path    string
+	subtree bool
+	read    appAuth.TokenScope // For GET and HEAD
+	write   appAuth.TokenScope // For other methods; empty refuses them
+}
+
+// tokenScopeRules are the only routes personal access tokens can reach, most specific first.
+// Account, session, two-factor, token and admin routes are left out on purpose, so a leaked
+// token cannot take over the account.
+var tokenScopeRules = []tokenScopeRule{
+	{path: "/api/v1/communities/:id/chat/ws", read: appAuth.TokenScopeChatSend}, // Sends messages as well
+	{path: "/api/v1/communities/:id/chat", subtree: true, read: appAuth.TokenScopeChatRead, write: appAuth.TokenScopeChatSend},
+	{path: "/api/v1/communities", subtree: true, read: appAuth.TokenScopeContentRead, write: appAuth.TokenScopeContentWrite},
+	{path: "/api/v1/my-communities", read: appAuth.TokenScopeContentRead},
+	{path: "/api/v1/faculties", subtree: true, read: appAuth.TokenScopeContentRead, write: appAuth.TokenScopeContentWrite},
+	{path: "/api/v1/departments", subtree: true, read: appAuth.TokenScopeContentRead, write: appAuth.TokenScopeContentWrite},
+	{path: "/api/v1/past-exams", subtree: true, read: appAuth.TokenScopeContentRead, write: appAuth.TokenScopeContentWrite},
+	{path: "/api/v1/class-notes", subtree: true, read: appAuth.TokenScopeContentRead, write: appAuth.TokenScopeContentWrite},
+	{path: "/api/v1/files/:fileId/signed-url", read: appAuth.TokenScopeContentRead, write: appAuth.TokenScopeContentRead},
+	{path: "/api/v1/files", subtree: true, read: appAuth.TokenScopeContentRead},
+	{path: "/api/v1/uploads", subtree: true, read: appAuth.TokenScopeContentWrite, write: appAuth.TokenScopeContentWrite},
+	{path: "/api/v1/users/profile", read: appAuth.TokenScopeProfileRead},
+	{path: "/api/v1/users/profile/storage", read: appAuth.TokenScopeProfileRead},
+	{path: "/api/v1/users", read: appAuth.TokenScopeProfileRead},
+	{path: "/api/v1/users/:id", read: appAuth.TokenScopeProfileRead},
+	{path: "/api/v1/department-users/:departmentId", read: appAuth.TokenScopeProfileRead},
+}
+
+// requiredTokenScope returns the scope a personal access token needs for a request to a
+// route, given by its pattern. It reports false for routes tokens cannot be used on.
+func requiredTokenScope(method, route string) (appAuth.TokenScope, bool) {
+	for _, rule := range tokenScopeRules {
+		if route != rule.path && !(rule.subtree && strings.HasPrefix(route, rule.path+"/")) {
+			continue
+		}
+		scope := rule.write
+		if method == http.MethodGet || method == http.MethodHead {
+			scope = rule.read
+		}
+		return scope, scope != ""
+	}
+	return "", false
+}
+
+// hasTokenScope reports whether a token's scopes include a scope
+func hasTokenScope(scopes []string, scope appAuth.TokenScope) bool {
+	for _, s := range scopes {
+		if s == string(scope) {
+			return true
+		}
+	}
+	return false
+}
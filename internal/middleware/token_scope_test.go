@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	appAuth "github.com/yigit/unisphere/internal/app/auth"
+)
+
+func TestRequiredTokenScope(t *testing.T) {
+	tests := []struct {
+		method    string
+		route     string
+		wantScope appAuth.TokenScope
+		wantOK    bool
+	}{
+		// Content
+		{http.MethodGet, "/api/v1/past-exams", appAuth.TokenScopeContentRead, true},
+		{http.MethodHead, "/api/v1/past-exams/:id", appAuth.TokenScopeContentRead, true},
+		{http.MethodPost, "/api/v1/past-exams", appAuth.TokenScopeContentWrite, true},
+		{http.MethodDelete, "/api/v1/past-exams/:id/files/:fileId", appAuth.TokenScopeContentWrite, true},
+		{http.MethodPut, "/api/v1/class-notes/:noteId", appAuth.TokenScopeContentWrite, true},
+		{http.MethodGet, "/api/v1/faculties/:id/departments", appAuth.TokenScopeContentRead, true},
+		{http.MethodPost, "/api/v1/communities/:id/join", appAuth.TokenScopeContentWrite, true},
+		{http.MethodGet, "/api/v1/my-communities", appAuth.TokenScopeContentRead, true},
+
+		// Chat routes are matched before the communities subtree
+		{http.MethodGet, "/api/v1/communities/:id/chat", appAuth.TokenScopeChatRead, true},
+		{http.MethodGet, "/api/v1/communities/:id/chat/messages", appAuth.TokenScopeChatRead, true},
+		{http.MethodPost, "/api/v1/communities/:id/chat/messages", appAuth.TokenScopeChatSend, true},
+		{http.MethodGet, "/api/v1/communities/:id/chat/ws", appAuth.TokenScopeChatSend, true},
+
+		// Files can be read but not changed; signing a URL only reads
+		{http.MethodGet, "/api/v1/files/:fileId", appAuth.TokenScopeContentRead, true},
+		{http.MethodDelete, "/api/v1/files/:fileId", "", false},
+		{http.MethodPost, "/api/v1/files/:fileId/signed-url", appAuth.TokenScopeContentRead, true},
+		{http.MethodGet, "/api/v1/uploads/:uploadId", appAuth.TokenScopeContentWrite, true},
+		{http.MethodPatch, "/api/v1/uploads/:uploadId", appAuth.TokenScopeContentWrite, true},
+
+		// Profiles are read-only
+		{http.MethodGet, "/api/v1/users/profile", appAuth.TokenScopeProfileRead, true},
+		{http.MethodPut, "/api/v1/users/profile", "", false},
+		{http.MethodGet, "/api/v1/users/:id", appAuth.TokenScopeProfileRead, true},
+		{http.MethodGet, "/api/v1/department-users/:departmentId", appAuth.TokenScopeProfileRead, true},
+
+		// Account takeover routes are out of reach
+		{http.MethodPut, "/api/v1/users/profile/password", "", false},
+		{http.MethodPost, "/api/v1/users/profile/email", "", false},
+		{http.MethodGet, "/api/v1/users/profile/sessions", "", false},
+		{http.MethodPost, "/api/v1/users/profile/tokens", "", false},
+		{http.MethodPost, "/api/v1/users/profile/2fa/disable", "", false},
+		{http.MethodGet, "/api/v1/admin/duplicates", "", false},
+		{http.MethodPost, "/api/v1/auth/logout", "", false},
+
+		// Prefixes only match whole path segments
+		{http.MethodGet, "/api/v1/past-exams-archive", "", false},
+		{http.MethodGet, "/api/v1/users/:id/roles", "", false},
+		{http.MethodGet, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.route, func(t *testing.T) {
+			scope, ok := requiredTokenScope(tt.method, tt.route)
+			if scope != tt.wantScope || ok != tt.wantOK {
+				t.Errorf("requiredTokenScope(%s, %q) = (%q, %v), want (%q, %v)", tt.method, tt.route, scope, ok, tt.wantScope, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHasTokenScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		scope  appAuth.TokenScope
+		want   bool
+	}{
+		{"granted", []string{"content:read", "chat:read"}, appAuth.TokenScopeChatRead, true},
+		{"not granted", []string{"content:read"}, appAuth.TokenScopeContentWrite, false},
+		{"read does not imply write", []string{"chat:read"}, appAuth.TokenScopeChatSend, false},
+		{"no scopes", nil, appAuth.TokenScopeContentRead, false},
+		{"exact match only", []string{"content:read:extra", "CONTENT:READ"}, appAuth.TokenScopeContentRead, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasTokenScope(tt.scopes, tt.scope); got != tt.want {
+				t.Errorf("hasTokenScope(%v, %q) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package apperrors
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 // Common errors
 var (
@@ -16,6 +19,10 @@ var (
 	ErrTokenNotFound      = errors.New("token not found")
 	ErrTokenRevoked       = errors.New("token revoked")
 	ErrAccountDisabled    = errors.New("account is disabled")
+	ErrInvalidMFACode     = errors.New("invalid two-factor authentication code")
+	ErrMFARequired        = errors.New("two-factor authentication required")
+	ErrTooManyAttempts    = errors.New("too many attempts")
+	ErrAccountLocked      = errors.New("account is temporarily locked")
 
 	// Authorization errors
 	ErrPermissionDenied = errors.New("permission denied")
@@ -109,6 +116,46 @@ func NewBadRequestError(message string) error {
 	}
 }
 
+// NewValidationError creates a new custom error for failed validation with an error code
+// and structured details that are returned to the client
+func NewValidationError(code, message string, details map[string]interface{}) error {
+	return &CustomError{
+		Err:     ErrValidationFailed,
+		Message: message,
+		Code:    code,
+		Details: details,
+	}
+}
+
+// NewTooManyAttemptsError creates a new custom error for throttled requests, telling the
+// client how long to wait
+func NewTooManyAttemptsError(retryAfter time.Duration) error {
+	return &CustomError{
+		Err:     ErrTooManyAttempts,
+		Message: "Too many attempts, try again later",
+		Details: map[string]interface{}{"retryAfterSeconds": retryAfterSeconds(retryAfter)},
+	}
+}
+
+// NewAccountLockedError creates a new custom error for an account locked after too many
+// failed logins
+func NewAccountLockedError(lockedUntil time.Time) error {
+	return &CustomError{
+		Err:     ErrAccountLocked,
+		Message: "Account is temporarily locked after too many failed logins",
+		Details: map[string]interface{}{"retryAfterSeconds": retryAfterSeconds(time.Until(lockedUntil))},
+	}
+}
+
+// retryAfterSeconds rounds a wait up to whole seconds, at least one
+func retryAfterSeconds(d time.Duration) int64 {
+	seconds := int64((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
 // Is returns whether target matches any of the errors in errList
 // Bu yardımcı fonksiyon, errors.Is() fonksiyonunun birden fazla hatayla kullanımını kolaylaştırır
 func Is(err, target error, errList ...error) bool {
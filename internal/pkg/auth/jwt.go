@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"strings"
@@ -15,8 +16,11 @@ import (
 // JWTConfig defines JWT configuration settings
 type JWTConfig struct {
 	SecretKey       string
+	Algorithm       string  // Access token algorithm: HS256 (default), RS256 or EdDSA
+	Keys            *KeySet // Key pairs for RS256 and EdDSA, kept up to date by the key rotation
 	AccessTokenExp  time.Duration
 	RefreshTokenExp time.Duration
+	MFAChallengeExp time.Duration
 	TokenIssuer     string
 }
 
@@ -27,6 +31,12 @@ type JWTService struct {
 
 // NewJWTService creates a new JWT service
 func NewJWTService(config JWTConfig) *JWTService {
+	if config.Algorithm == "" {
+		config.Algorithm = AlgorithmHS256
+	}
+	if config.Keys == nil {
+		config.Keys = NewKeySet()
+	}
 	return &JWTService{
 		config: config,
 	}
@@ -34,22 +44,24 @@ func NewJWTService(config JWTConfig) *JWTService {
 
 // Claims defines JWT token content
 type Claims struct {
-	UserID   int64  `json:"userId"`
-	Email    string `json:"email"`
-	RoleType string `json:"roleType"`
+	UserID    int64  `json:"userId"`
+	Email     string `json:"email"`
+	RoleType  string `json:"roleType"`
+	SessionID int64  `json:"sid,omitempty"` // Refresh token family (session) the token was issued for
 	jwt.RegisteredClaims
 }
 
-// GenerateTokenPair creates access and refresh token pair
-func (s *JWTService) GenerateTokenPair(user *models.User) (accessToken, refreshToken string, expiresIn, refreshExpiresIn int, err error) {
+// GenerateTokenPair creates access and refresh token pair for a session
+func (s *JWTService) GenerateTokenPair(user *models.User, sessionID int64) (accessToken, refreshToken string, expiresIn, refreshExpiresIn int, err error) {
 	// Access token expiry
 	accessTokenExpiry := time.Now().Add(s.config.AccessTokenExp)
 
 	// Create claims
 	claims := &Claims{
-		UserID:   user.ID,
-		Email:    user.Email,
-		RoleType: string(user.RoleType),
+		UserID:    user.ID,
+		Email:     user.Email,
+		RoleType:  string(user.RoleType),
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(accessTokenExpiry),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -61,8 +73,7 @@ func (s *JWTService) GenerateTokenPair(user *models.User) (accessToken, refreshT
 	}
 
 	// Create access token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	accessToken, err = token.SignedString([]byte(s.config.SecretKey))
+	accessToken, err = s.signAccessToken(claims)
 	if err != nil {
 		return "", "", 0, 0, fmt.Errorf("failed to create access token: %w", err)
 	}
@@ -77,16 +88,55 @@ func (s *JWTService) GenerateTokenPair(user *models.User) (accessToken, refreshT
 	return accessToken, refreshToken, expiresIn, refreshExpiresIn, nil
 }
 
-// ValidateToken validates a token
-func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
-	// Parse token
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate algorithm
+// signAccessToken signs access token claims with the configured algorithm. Asymmetric
+// signatures name the key in the kid header.
+func (s *JWTService) signAccessToken(claims *Claims) (string, error) {
+	if !IsAsymmetricAlgorithm(s.config.Algorithm) {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.config.SecretKey))
+	}
+
+	key := s.config.Keys.SigningKey()
+	if key == nil {
+		return "", errors.New("no signing key loaded")
+	}
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// accessTokenKey returns the key to verify an access token with. Only the configured
+// algorithm is accepted, and an asymmetric token has to name a key whose algorithm matches.
+func (s *JWTService) accessTokenKey(token *jwt.Token) (interface{}, error) {
+	if !IsAsymmetricAlgorithm(s.config.Algorithm) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(s.config.SecretKey), nil
-	})
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key := s.config.Keys.VerificationKey(kid)
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	if token.Method.Alg() != key.signingMethod().Alg() {
+		return nil, fmt.Errorf("unexpected signing method for key %q: %v", kid, token.Header["alg"])
+	}
+	return key.PrivateKey.Public(), nil
+}
+
+// JWKS returns the public keys access tokens can be verified with. It is empty with HS256.
+func (s *JWTService) JWKS() JWKS {
+	if !IsAsymmetricAlgorithm(s.config.Algorithm) {
+		return JWKS{Keys: []JWK{}}
+	}
+	return s.config.Keys.JWKS()
+}
+
+// ValidateToken validates a token
+func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
+	// Parse token
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.accessTokenKey)
 
 	if err != nil {
 		// Token expired
@@ -104,6 +154,68 @@ func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 	return nil, apperrors.ErrTokenInvalid
 }
 
+// MFAChallengeClaims defines the content of an MFA challenge token. It proves the password was
+// checked and is exchanged, together with a second factor, for a token pair.
+type MFAChallengeClaims struct {
+	UserID int64 `json:"userId"`
+	jwt.RegisteredClaims
+}
+
+// mfaChallengeAudience marks MFA challenge tokens
+const mfaChallengeAudience = "mfa-challenge"
+
+// GenerateMFAChallenge creates a short-lived MFA challenge token for a user whose password was checked
+func (s *JWTService) GenerateMFAChallenge(userID int64) (token string, expiresIn int, err error) {
+	now := time.Now()
+	claims := &MFAChallengeClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.MFAChallengeExp)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    s.config.TokenIssuer,
+			Subject:   fmt.Sprintf("%d", userID),
+			Audience:  jwt.ClaimStrings{mfaChallengeAudience},
+			ID:        uuid.New().String(),
+		},
+	}
+
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.mfaChallengeKey())
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create MFA challenge token: %w", err)
+	}
+	return token, int(s.config.MFAChallengeExp.Seconds()), nil
+}
+
+// ValidateMFAChallenge validates an MFA challenge token and returns the user it was issued to
+func (s *JWTService) ValidateMFAChallenge(tokenString string) (int64, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &MFAChallengeClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return s.mfaChallengeKey(), nil
+	}, jwt.WithAudience(mfaChallengeAudience))
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return 0, apperrors.ErrTokenExpired
+		}
+		return 0, apperrors.ErrTokenInvalid
+	}
+
+	claims, ok := token.Claims.(*MFAChallengeClaims)
+	if !ok || !token.Valid || claims.UserID <= 0 {
+		return 0, apperrors.ErrTokenInvalid
+	}
+	return claims.UserID, nil
+}
+
+// mfaChallengeKey derives the key challenge tokens are signed with from the JWT secret,
+// so a challenge token can never pass as an access token
+func (s *JWTService) mfaChallengeKey() []byte {
+	key := sha256.Sum256([]byte("unisphere-mfa-challenge:" + s.config.SecretKey))
+	return key[:]
+}
+
 // GetRefreshTokenExpiry returns refresh token expiry time
 func (s *JWTService) GetRefreshTokenExpiry() time.Time {
 	return time.Now().Add(s.config.RefreshTokenExp)
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, telling them apart from JWTs
+// and making leaked tokens easy to search for
+const PersonalAccessTokenPrefix = "upt_"
+
+// personalAccessTokenHintLength is how much of a token is kept in the clear to recognize it
+const personalAccessTokenHintLength = len(PersonalAccessTokenPrefix) + 6
+
+// GeneratePersonalAccessToken creates a personal access token. It returns the token, shown to
+// its owner once, with its hash and hint, which are stored instead.
+func GeneratePersonalAccessToken() (token, hash, hint string, err error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate personal access token: %w", err)
+	}
+	token = PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(random)
+	return token, HashPersonalAccessToken(token), token[:personalAccessTokenHintLength], nil
+}
+
+// HashPersonalAccessToken hashes a personal access token. Tokens are random enough that a
+// fast hash suffices.
+func HashPersonalAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsPersonalAccessToken reports whether a bearer token is a personal access token
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithms access tokens can be signed with. HS256 uses the shared JWT secret; the others
+// use rotating key pairs whose public halves are published as a JWKS.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// rsaKeyBits is the size of generated RSA keys
+const rsaKeyBits = 2048
+
+// IsAsymmetricAlgorithm reports whether algorithm signs with key pairs rather than the shared secret
+func IsAsymmetricAlgorithm(algorithm string) bool {
+	return algorithm == AlgorithmRS256 || algorithm == AlgorithmEdDSA
+}
+
+// SigningKey is a key pair access tokens are signed with, identified by its kid
+type SigningKey struct {
+	ID         string // RFC 7638 thumbprint of the public key
+	Algorithm  string
+	PrivateKey crypto.Signer
+	CreatedAt  time.Time
+}
+
+// GenerateSigningKey creates a new key pair for algorithm
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var privateKey crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgorithmRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", algorithm, err)
+	}
+
+	return newSigningKey(algorithm, privateKey, time.Now())
+}
+
+// ParseSigningKey restores a key stored with EncodePrivateKey
+func ParseSigningKey(algorithm, privateKeyPEM string, createdAt time.Time) (*SigningKey, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, errors.New("malformed private key PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	privateKey, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot sign")
+	}
+	return newSigningKey(algorithm, privateKey, createdAt)
+}
+
+// newSigningKey checks that privateKey suits algorithm and derives the kid
+func newSigningKey(algorithm string, privateKey crypto.Signer, createdAt time.Time) (*SigningKey, error) {
+	key := &SigningKey{Algorithm: algorithm, PrivateKey: privateKey, CreatedAt: createdAt}
+	jwk, err := key.PublicJWK()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = jwk.thumbprint()
+	return key, nil
+}
+
+// EncodePrivateKey returns the private key as PKCS #8 PEM, for storing it
+func (k *SigningKey) EncodePrivateKey() (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode private key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// EncodePublicKey returns the public key as PKIX PEM
+func (k *SigningKey) EncodePublicKey() (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(k.PrivateKey.Public())
+	if err != nil {
+		return "", fmt.Errorf("failed to encode public key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// signingMethod returns the JWT signing method of the key
+func (k *SigningKey) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// JWK is the public half of a signing key in JSON Web Key form (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS is a JSON Web Key Set, served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWK returns the public half of the key as a JWK
+func (k *SigningKey) PublicJWK() (JWK, error) {
+	jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Algorithm}
+	switch publicKey := k.PrivateKey.Public().(type) {
+	case *rsa.PublicKey:
+		if k.Algorithm != AlgorithmRS256 {
+			return JWK{}, fmt.Errorf("RSA key cannot be used with %s", k.Algorithm)
+		}
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		if k.Algorithm != AlgorithmEdDSA {
+			return JWK{}, fmt.Errorf("Ed25519 key cannot be used with %s", k.Algorithm)
+		}
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	return jwk, nil
+}
+
+// thumbprint computes the RFC 7638 thumbprint of the key: the SHA-256 of its required
+// members in lexicographic order
+func (j JWK) thumbprint() string {
+	var canonical string
+	if j.KeyType == "RSA" {
+		canonical = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, j.E, j.N)
+	} else {
+		canonical = fmt.Sprintf(`{"crv":"%s","kty":"OKP","x":"%s"}`, j.Curve, j.X)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// KeySet holds the key new access tokens are signed with and the keys tokens are still
+// accepted from. It is safe for concurrent use; the key rotation replaces its contents.
+type KeySet struct {
+	mu           sync.RWMutex
+	signing      *SigningKey
+	verification map[string]*SigningKey
+	published    []*SigningKey
+}
+
+// NewKeySet creates an empty KeySet
+func NewKeySet() *KeySet {
+	return &KeySet{verification: make(map[string]*SigningKey)}
+}
+
+// Set replaces the keys. signing has to be one of verification.
+func (s *KeySet) Set(signing *SigningKey, verification []*SigningKey) {
+	byID := make(map[string]*SigningKey, len(verification))
+	for _, key := range verification {
+		byID[key.ID] = key
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signing = signing
+	s.verification = byID
+	s.published = verification
+}
+
+// SigningKey returns the key new tokens are signed with, or nil before keys are loaded
+func (s *KeySet) SigningKey() *SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.signing
+}
+
+// VerificationKey returns the key with the given kid, or nil if tokens signed with it are not accepted
+func (s *KeySet) VerificationKey(kid string) *SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.verification[kid]
+}
+
+// JWKS returns the public halves of the verification keys
+func (s *KeySet) JWKS() JWKS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(s.published))}
+	for _, key := range s.published {
+		if jwk, err := key.PublicJWK(); err == nil {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yigit/unisphere/internal/app/models"
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+)
+
+func TestThumbprint(t *testing.T) {
+	// The examples of RFC 7638 3.1 and RFC 8037 A.3
+	tests := []struct {
+		name string
+		jwk  JWK
+		want string
+	}{
+		{
+			"RSA",
+			JWK{
+				KeyType: "RSA",
+				E:       "AQAB",
+				N:       "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+			},
+			"NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			"Ed25519",
+			JWK{KeyType: "OKP", Curve: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+			"kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.jwk.thumbprint(); got != tt.want {
+				t.Errorf("thumbprint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSigningKeyRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			key, err := GenerateSigningKey(algorithm)
+			if err != nil {
+				t.Fatalf("GenerateSigningKey: %v", err)
+			}
+			encoded, err := key.EncodePrivateKey()
+			if err != nil {
+				t.Fatalf("EncodePrivateKey: %v", err)
+			}
+
+			parsed, err := ParseSigningKey(algorithm, encoded, key.CreatedAt)
+			if err != nil {
+				t.Fatalf("ParseSigningKey: %v", err)
+			}
+			if parsed.ID != key.ID || parsed.Algorithm != algorithm {
+				t.Errorf("parsed key %s/%s, want %s/%s", parsed.ID, parsed.Algorithm, key.ID, algorithm)
+			}
+
+			other := AlgorithmRS256
+			if algorithm == AlgorithmRS256 {
+				other = AlgorithmEdDSA
+			}
+			if _, err := ParseSigningKey(other, encoded, key.CreatedAt); err == nil {
+				t.Errorf("ParseSigningKey of a %s key as %s succeeded, want an error", algorithm, other)
+			}
+		})
+	}
+
+	if _, err := GenerateSigningKey(AlgorithmHS256); err == nil {
+		t.Error("GenerateSigningKey(HS256) succeeded, want an error")
+	}
+	if _, err := ParseSigningKey(AlgorithmEdDSA, "not a key", time.Now()); err == nil {
+		t.Error("ParseSigningKey of garbage succeeded, want an error")
+	}
+}
+
+func TestKeySetJWKS(t *testing.T) {
+	rsaKey, err := GenerateSigningKey(AlgorithmRS256)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey: %v", err)
+	}
+	edKey, err := GenerateSigningKey(AlgorithmEdDSA)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey: %v", err)
+	}
+
+	keys := NewKeySet()
+	keys.Set(edKey, []*SigningKey{rsaKey, edKey})
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].KeyID != rsaKey.ID || jwks.Keys[1].KeyID != edKey.ID {
+		t.Fatalf("JWKS = %+v, want the RSA and Ed25519 keys", jwks)
+	}
+	if k := jwks.Keys[0]; k.KeyType != "RSA" || k.Algorithm != AlgorithmRS256 || k.E != "AQAB" || k.N == "" || k.Use != "sig" {
+		t.Errorf("RSA JWK = %+v", k)
+	}
+	if k := jwks.Keys[1]; k.KeyType != "OKP" || k.Curve != "Ed25519" || k.Algorithm != AlgorithmEdDSA || k.X == "" {
+		t.Errorf("Ed25519 JWK = %+v", k)
+	}
+
+	// Only public members are published
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for _, private := range []string{`"d"`, `"p"`, `"q"`} {
+		if strings.Contains(string(data), private) {
+			t.Errorf("JWKS %s contains the private member %s", data, private)
+		}
+	}
+}
+
+func TestAsymmetricTokens(t *testing.T) {
+	user := &models.User{ID: 7, Email: "ada@example.edu", RoleType: "STUDENT"}
+
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			oldKey, err := GenerateSigningKey(algorithm)
+			if err != nil {
+				t.Fatalf("GenerateSigningKey: %v", err)
+			}
+			newKey, err := GenerateSigningKey(algorithm)
+			if err != nil {
+				t.Fatalf("GenerateSigningKey: %v", err)
+			}
+
+			keys := NewKeySet()
+			service := NewJWTService(JWTConfig{SecretKey: "secret", Algorithm: algorithm, Keys: keys, AccessTokenExp: time.Minute})
+			if _, _, _, _, err := service.GenerateTokenPair(user, 1); err == nil {
+				t.Fatal("GenerateTokenPair without keys succeeded, want an error")
+			}
+
+			keys.Set(oldKey, []*SigningKey{oldKey, newKey})
+			oldToken, _, _, _, err := service.GenerateTokenPair(user, 1)
+			if err != nil {
+				t.Fatalf("GenerateTokenPair: %v", err)
+			}
+			parsed, _, err := jwt.NewParser().ParseUnverified(oldToken, &Claims{})
+			if err != nil {
+				t.Fatalf("ParseUnverified: %v", err)
+			}
+			if parsed.Header["kid"] != oldKey.ID || parsed.Header["alg"] != oldKey.signingMethod().Alg() {
+				t.Errorf("token header = %v, want kid %s", parsed.Header, oldKey.ID)
+			}
+
+			// After the rotation tokens of the old key stay valid while it is published
+			keys.Set(newKey, []*SigningKey{oldKey, newKey})
+			newToken, _, _, _, err := service.GenerateTokenPair(user, 1)
+			if err != nil {
+				t.Fatalf("GenerateTokenPair: %v", err)
+			}
+			for _, token := range []string{oldToken, newToken} {
+				if claims, err := service.ValidateToken(token); err != nil || claims.UserID != user.ID {
+					t.Errorf("ValidateToken() = %v, %v; want the user's claims", claims, err)
+				}
+			}
+
+			// Once the old key is dropped, its tokens are rejected
+			keys.Set(newKey, []*SigningKey{newKey})
+			if _, err := service.ValidateToken(oldToken); err == nil {
+				t.Error("ValidateToken() of a token signed with a dropped key succeeded")
+			}
+			if _, err := service.ValidateToken(newToken); err != nil {
+				t.Errorf("ValidateToken() of a token signed with the current key: %v", err)
+			}
+		})
+	}
+}
+
+func TestAsymmetricTokensRejectForgeries(t *testing.T) {
+	key, err := GenerateSigningKey(AlgorithmEdDSA)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey: %v", err)
+	}
+	keys := NewKeySet()
+	keys.Set(key, []*SigningKey{key})
+	service := NewJWTService(JWTConfig{SecretKey: "secret", Algorithm: AlgorithmEdDSA, Keys: keys, AccessTokenExp: time.Minute})
+
+	claims := &Claims{UserID: 1, RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}}
+	otherKey, err := GenerateSigningKey(AlgorithmEdDSA)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey: %v", err)
+	}
+
+	// sign signs claims with method and key, naming kid in the header
+	sign := func(method jwt.SigningMethod, kid string, signingKey interface{}) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(signingKey)
+		if err != nil {
+			t.Fatalf("SignedString: %v", err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"shared secret", sign(jwt.SigningMethodHS256, "", []byte("secret"))},
+		{"shared secret naming the key", sign(jwt.SigningMethodHS256, key.ID, []byte("secret"))},
+		{"no kid", sign(jwt.SigningMethodEdDSA, "", key.PrivateKey)},
+		{"unknown key", sign(jwt.SigningMethodEdDSA, otherKey.ID, otherKey.PrivateKey)},
+		{"other key naming the known kid", sign(jwt.SigningMethodEdDSA, key.ID, otherKey.PrivateKey)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.ValidateToken(tt.token); err == nil || errors.Is(err, apperrors.ErrTokenExpired) {
+				t.Errorf("ValidateToken() error = %v, want the token rejected", err)
+			}
+		})
+	}
+
+	if jwks := NewJWTService(JWTConfig{SecretKey: "secret", Keys: keys}).JWKS(); len(jwks.Keys) != 0 {
+		t.Errorf("JWKS with HS256 = %+v, want no keys", jwks)
+	}
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890", in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatalf("decoding secret: %v", err)
+	}
+
+	// RFC 6238 appendix B, cut to the last six of the eight digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(time.Unix(tt.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			if got := totpCode(key, TOTPStep(time.Unix(tt.unix, 0))); got != tt.want {
+				t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TOTPStep(now)
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatalf("decoding secret: %v", err)
+	}
+	codeAt := func(offset int64) string { return totpCode(key, step+offset) }
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfc6238Secret, codeAt(0), 0, step, true},
+		{"previous step within skew", rfc6238Secret, codeAt(-1), 0, step - 1, true},
+		{"next step within skew", rfc6238Secret, codeAt(1), 0, step + 1, true},
+		{"two steps behind", rfc6238Secret, codeAt(-2), 0, 0, false},
+		{"two steps ahead", rfc6238Secret, codeAt(2), 0, 0, false},
+		{"spaces are ignored", rfc6238Secret, codeAt(0)[:3] + " " + codeAt(0)[3:], 0, step, true},
+		{"lower-case secret", strings.ToLower(rfc6238Secret), codeAt(0), 0, step, true},
+		{"replayed code", rfc6238Secret, codeAt(0), step, 0, false},
+		{"newer code after use", rfc6238Secret, codeAt(1), step, step + 1, true},
+		{"wrong code", rfc6238Secret, "000000", 0, 0, false},
+		{"too short", rfc6238Secret, codeAt(0)[:5], 0, 0, false},
+		{"too long", rfc6238Secret, codeAt(0) + "0", 0, 0, false},
+		{"empty", rfc6238Secret, "", 0, 0, false},
+		{"invalid secret", "not base32!", codeAt(0), 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, gotOK := ValidateTOTP(tt.secret, tt.code, now, tt.lastStep)
+			if gotOK != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("ValidateTOTP(%q) = (%d, %v), want (%d, %v)", tt.code, gotStep, gotOK, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not unpadded base32: %v", secret, err)
+	}
+	if len(key) != totpSecretSize {
+		t.Errorf("secret has %d bytes, want %d", len(key), totpSecretSize)
+	}
+
+	// A freshly generated secret validates its own codes
+	now := time.Now()
+	if _, ok := ValidateTOTP(secret, totpCode(key, TOTPStep(now)), now, 0); !ok {
+		t.Error("code of a generated secret was rejected")
+	}
+}
+
+func TestSecretCipher(t *testing.T) {
+	cipher := NewSecretCipher("jwt-secret")
+
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{"totp secret", rfc6238Secret},
+		{"empty", ""},
+		{"unicode", "şifre-ğüç"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := cipher.Encrypt(tt.plaintext)
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+			decrypted, err := cipher.Decrypt(encrypted)
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if decrypted != tt.plaintext {
+				t.Errorf("Decrypt(Encrypt(%q)) = %q", tt.plaintext, decrypted)
+			}
+			if _, err := NewSecretCipher("other-secret").Decrypt(encrypted); err == nil {
+				t.Error("Decrypt with another key succeeded")
+			}
+		})
+	}
+
+	for _, malformed := range []string{"", "not base64!", "c2hvcnQ="} {
+		if _, err := cipher.Decrypt(malformed); err == nil {
+			t.Errorf("Decrypt(%q) succeeded, want an error", malformed)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yigit/unisphere/internal/pkg/apperrors"
+)
+
+func TestURLSignerVerify(t *testing.T) {
+	signer := NewURLSigner("jwt-secret")
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	signature := signer.Sign(42, expires)
+	expired := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		signer    *URLSigner
+		fileID    int64
+		expires   int64
+		signature string
+		wantErr   error
+	}{
+		{"valid", signer, 42, expires.Unix(), signature, nil},
+		{"other file", signer, 43, expires.Unix(), signature, apperrors.ErrTokenInvalid},
+		{"extended expiry", signer, 42, expires.Add(time.Hour).Unix(), signature, apperrors.ErrTokenInvalid},
+		{"other secret", NewURLSigner("other-secret"), 42, expires.Unix(), signature, apperrors.ErrTokenInvalid},
+		{"tampered signature", signer, 42, expires.Unix(), "A" + signature[1:], apperrors.ErrTokenInvalid},
+		{"not base64", signer, 42, expires.Unix(), "not base64!", apperrors.ErrTokenInvalid},
+		{"empty signature", signer, 42, expires.Unix(), "", apperrors.ErrTokenInvalid},
+		{"expired", signer, 42, expired.Unix(), signer.Sign(42, expired), apperrors.ErrTokenExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.signer.Verify(tt.fileID, tt.expires, tt.signature)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestURLSignerSign(t *testing.T) {
+	signer := NewURLSigner("jwt-secret")
+	expires := time.Unix(1700000000, 0)
+
+	if a, b := signer.Sign(1, expires), signer.Sign(1, expires); a != b {
+		t.Errorf("Sign is not deterministic: %q != %q", a, b)
+	}
+	if a, b := signer.Sign(1, expires), signer.Sign(2, expires); a == b {
+		t.Error("different files got the same signature")
+	}
+	if a, b := signer.Sign(1, expires), signer.Sign(1, expires.Add(time.Second)); a == b {
+		t.Error("different expiries got the same signature")
+	}
+	// The key is derived, so the signature differs from a plain HMAC with the JWT secret
+	if a, b := signer.Sign(1, expires), NewURLSigner("unisphere-file-url:jwt-secret").Sign(1, expires); a == b {
+		t.Error("signatures of different secrets match")
+	}
+}
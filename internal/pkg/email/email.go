@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"fmt"
+	"html"
 	"math/big"
 	"net/smtp"
 	"strconv"
@@ -18,6 +19,22 @@ type EmailService interface {
 	SendWelcomeEmail(toEmail, toName string) error
 	SendPasswordResetEmail(toEmail, toName, token string) error
 	SendPasswordChangedEmail(toEmail, toName string) error
+	SendEmailChangeConfirmationEmail(toEmail, toName, token string) error
+	SendEmailChangedEmail(toEmail, toName, newEmail, undoToken string) error
+	SendMalwareAlertEmail(toEmail, toName string, alert MalwareAlert) error
+	SendAccountLockedEmail(toEmail, toName string, lockedUntil time.Time) error
+	SendInstructorApprovedEmail(toEmail, toName string) error
+	SendInstructorRejectedEmail(toEmail, toName, reason string) error
+}
+
+// MalwareAlert describes an infected upload that was quarantined
+type MalwareAlert struct {
+	FileID       int64
+	FileName     string
+	Signature    string // Name of the malware found
+	ResourceType string
+	ResourceID   int64
+	UploadedBy   int64
 }
 
 // SMTPConfig holds configuration for SMTP server
@@ -200,6 +217,235 @@ func (s *EmailServiceImpl) SendPasswordChangedEmail(toEmail, toName string) erro
 	return s.sendHTMLEmail(toEmail, subject, body)
 }
 
+// SendEmailChangeConfirmationEmail sends a link confirming a new email address to that address
+func (s *EmailServiceImpl) SendEmailChangeConfirmationEmail(toEmail, toName, token string) error {
+	confirmURL := fmt.Sprintf("%s/api/v1/auth/confirm-email-change?token=%s", s.config.BaseURL, token)
+
+	// If username or password is empty, log the email (for development only)
+	if s.config.Username == "" || s.config.Password == "" {
+		s.logger.Warn().
+			Str("toEmail", toEmail).
+			Str("token", token).
+			Str("confirmURL", confirmURL).
+			Msg("SMTP credentials not configured - email change confirmation not sent. Use the token/URL above for testing.")
+
+		// Return success for development purposes
+		return nil
+	}
+
+	subject := "Confirm Your New Email Address - UniSphere"
+
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+				<h2 style="color: #333;">Confirm Your New Email Address</h2>
+				<p>Hello %s,</p>
+				<p>You asked to use this address for your UniSphere account. Please confirm it by clicking the button below:</p>
+
+				<div style="text-align: center; margin: 30px 0;">
+					<a href="%s" style="background-color: #4a86e8; color: white; padding: 12px 24px; text-decoration: none; border-radius: 4px; font-weight: bold;">Confirm Email Address</a>
+				</div>
+
+				<p>This link will expire in 24 hours. Until then, your account keeps using its current address.</p>
+
+				<p>If you did not ask for this change, please ignore this email.</p>
+
+				<p>Best regards,<br>The UniSphere Team</p>
+			</div>
+		</body>
+		</html>
+	`, html.EscapeString(toName), confirmURL)
+
+	return s.sendHTMLEmail(toEmail, subject, body)
+}
+
+// SendEmailChangedEmail tells the old address of an account that its email address was
+// changed, with a link undoing the change
+func (s *EmailServiceImpl) SendEmailChangedEmail(toEmail, toName, newEmail, undoToken string) error {
+	undoURL := fmt.Sprintf("%s/api/v1/auth/undo-email-change?token=%s", s.config.BaseURL, undoToken)
+
+	// If username or password is empty, log the email (for development only)
+	if s.config.Username == "" || s.config.Password == "" {
+		s.logger.Warn().
+			Str("toEmail", toEmail).
+			Str("newEmail", newEmail).
+			Str("undoURL", undoURL).
+			Msg("SMTP credentials not configured - logging email changed notification instead")
+
+		// Return success for development purposes
+		return nil
+	}
+
+	subject := "UniSphere Email Address Changed"
+
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+				<h2 style="color: #333;">Your Email Address Has Been Changed</h2>
+				<p>Hello %s,</p>
+				<p>The email address of your UniSphere account has been changed to <strong>%s</strong>. You will receive our emails there from now on.</p>
+
+				<p>If you did not make this change, someone else may have access to your account. Undo the change with the button below; it also signs out every session:</p>
+
+				<div style="text-align: center; margin: 30px 0;">
+					<a href="%s" style="background-color: #c0392b; color: white; padding: 12px 24px; text-decoration: none; border-radius: 4px; font-weight: bold;">Undo Email Change</a>
+				</div>
+
+				<p>This link will expire in 7 days. After undoing the change, reset your password.</p>
+
+				<p>Best regards,<br>The UniSphere Team</p>
+			</div>
+		</body>
+		</html>
+	`, html.EscapeString(toName), html.EscapeString(newEmail), undoURL)
+
+	return s.sendHTMLEmail(toEmail, subject, body)
+}
+
+// SendMalwareAlertEmail tells an administrator that an upload was quarantined
+func (s *EmailServiceImpl) SendMalwareAlertEmail(toEmail, toName string, alert MalwareAlert) error {
+	// If username or password is empty, log the email (for development only)
+	if s.config.Username == "" || s.config.Password == "" {
+		s.logger.Warn().
+			Str("toEmail", toEmail).
+			Int64("fileID", alert.FileID).
+			Str("signature", alert.Signature).
+			Msg("SMTP credentials not configured - logging malware alert instead")
+
+		// Return success for development purposes
+		return nil
+	}
+
+	subject := "UniSphere Malware Alert: Upload Quarantined"
+
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+				<h2 style="color: #c0392b;">Malware Found in an Upload</h2>
+				<p>Hello %s,</p>
+				<p>The virus scanner found <strong>%s</strong> in an uploaded file. The file was moved to quarantine and can no longer be downloaded.</p>
+				<ul>
+					<li>File: %s (ID %d)</li>
+					<li>Attached to: %s %d</li>
+					<li>Uploaded by user ID: %d</li>
+				</ul>
+				<p>Review the quarantined files at %s/api/v1/admin/files/quarantine.</p>
+
+				<p>Best regards,<br>The UniSphere Team</p>
+			</div>
+		</body>
+		</html>
+	`, html.EscapeString(toName), html.EscapeString(alert.Signature), html.EscapeString(alert.FileName), alert.FileID,
+		alert.ResourceType, alert.ResourceID, alert.UploadedBy, s.config.BaseURL)
+
+	return s.sendHTMLEmail(toEmail, subject, body)
+}
+
+// SendAccountLockedEmail tells a user their account was locked after too many failed logins
+func (s *EmailServiceImpl) SendAccountLockedEmail(toEmail, toName string, lockedUntil time.Time) error {
+	// If username or password is empty, log the email (for development only)
+	if s.config.Username == "" || s.config.Password == "" {
+		s.logger.Warn().
+			Str("toEmail", toEmail).
+			Time("lockedUntil", lockedUntil).
+			Msg("SMTP credentials not configured - logging account locked notification instead")
+
+		// Return success for development purposes
+		return nil
+	}
+
+	subject := "UniSphere Account Locked"
+
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+				<h2 style="color: #c0392b;">Your Account Has Been Locked</h2>
+				<p>Hello %s,</p>
+				<p>There were too many failed attempts to log in to your UniSphere account, so it has been locked until %s.</p>
+
+				<p>If these attempts were not yours, someone may be trying to guess your password. Once the lock ends, consider resetting your password and enabling two-factor authentication.</p>
+
+				<p>Best regards,<br>The UniSphere Team</p>
+			</div>
+		</body>
+		</html>
+	`, html.EscapeString(toName), lockedUntil.UTC().Format("2006-01-02 15:04 UTC"))
+
+	return s.sendHTMLEmail(toEmail, subject, body)
+}
+
+// SendInstructorApprovedEmail tells a user an admin confirmed them as an instructor
+func (s *EmailServiceImpl) SendInstructorApprovedEmail(toEmail, toName string) error {
+	// If username or password is empty, log the email (for development only)
+	if s.config.Username == "" || s.config.Password == "" {
+		s.logger.Warn().
+			Str("toEmail", toEmail).
+			Msg("SMTP credentials not configured - logging instructor approval notification instead")
+
+		// Return success for development purposes
+		return nil
+	}
+
+	subject := "Your UniSphere Instructor Account Is Approved"
+
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+				<h2 style="color: #333;">Instructor Account Approved</h2>
+				<p>Hello %s,</p>
+				<p>An administrator has confirmed your UniSphere account as an instructor account. You can now manage faculties, departments and past exams.</p>
+
+				<p>Instructor accounts have to be protected with two-factor authentication; you will be asked to set it up the next time you log in.</p>
+
+				<p>Best regards,<br>The UniSphere Team</p>
+			</div>
+		</body>
+		</html>
+	`, html.EscapeString(toName))
+
+	return s.sendHTMLEmail(toEmail, subject, body)
+}
+
+// SendInstructorRejectedEmail tells a user an admin did not confirm them as an instructor
+func (s *EmailServiceImpl) SendInstructorRejectedEmail(toEmail, toName, reason string) error {
+	// If username or password is empty, log the email (for development only)
+	if s.config.Username == "" || s.config.Password == "" {
+		s.logger.Warn().
+			Str("toEmail", toEmail).
+			Str("reason", reason).
+			Msg("SMTP credentials not configured - logging instructor rejection notification instead")
+
+		// Return success for development purposes
+		return nil
+	}
+
+	subject := "Your UniSphere Instructor Request"
+
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+				<h2 style="color: #333;">Instructor Request Not Approved</h2>
+				<p>Hello %s,</p>
+				<p>An administrator reviewed your UniSphere account and did not confirm it as an instructor account, for the following reason:</p>
+				<blockquote style="border-left: 3px solid #ccc; margin: 10px 0; padding-left: 10px;">%s</blockquote>
+
+				<p>You can keep using UniSphere with a regular account. If you think this is a mistake, please contact your department.</p>
+
+				<p>Best regards,<br>The UniSphere Team</p>
+			</div>
+		</body>
+		</html>
+	`, html.EscapeString(toName), html.EscapeString(reason))
+
+	return s.sendHTMLEmail(toEmail, subject, body)
+}
+
 // sendHTMLEmail sends an HTML email
 func (s *EmailServiceImpl) sendHTMLEmail(toEmail, subject, htmlBody string) error {
 	// Set up authentication information
@@ -0,0 +1,319 @@
+package filestorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yigit/unisphere/internal/pkg/logger"
+)
+
+const (
+	// blobDir holds content-addressed files, "blobs/ab/abcd….pdf"
+	blobDir = "blobs"
+	// BlobGracePeriod keeps an unreferenced blob around after an upload last resolved to it,
+	// since the upload's file row is written only after the content was stored
+	BlobGracePeriod = 10 * time.Minute
+	// blobCollectBatch is how many unreferenced blobs a collection deletes per query
+	blobCollectBatch = 100
+)
+
+// Blob is content stored once under its SHA-256 hash and shared by every file row with that content
+type Blob struct {
+	Hash           string // Hex encoded SHA-256 of the content
+	Path           string // Stored path in the "/blobs/ab/<hash>.ext" form
+	Size           int64
+	ThumbnailSizes []int // Thumbnails stored next to the blob
+}
+
+// BlobIndex records the stored blobs and how many file rows refer to each of them
+type BlobIndex interface {
+	// Use marks the blob with the given hash as just used and returns it, or nil if it is not stored
+	Use(ctx context.Context, hash string) (*Blob, error)
+
+	// Add records a blob that was just stored
+	Add(ctx context.Context, blob *Blob) error
+
+	// DeleteUnused deletes the record of a blob no file row refers to and that was last used
+	// before the given time. remove is called while the record is locked, so the blob cannot be
+	// used meanwhile; if it fails the record is kept. It reports whether the blob was deleted.
+	DeleteUnused(ctx context.Context, hash string, before time.Time, remove func(*Blob) error) (bool, error)
+
+	// ListUnused returns the hashes of up to limit blobs no file row refers to that were last used before the given time
+	ListUnused(ctx context.Context, before time.Time, limit int) ([]string, error)
+}
+
+// BlobStorage stores uploads once per distinct content, under the SHA-256 of the content,
+// on top of any FileStorage. The rows of the files table refer to the shared blob, and the
+// index counts them: deleting a file only deletes its blob once no other row uses it.
+// Objects outside the blob directory, such as upload chunks, are passed through untouched.
+type BlobStorage struct {
+	FileStorage
+	index BlobIndex
+}
+
+// NewBlobStorage stores content-addressed blobs in storage and records them in index
+func NewBlobStorage(storage FileStorage, index BlobIndex) *BlobStorage {
+	return &BlobStorage{
+		FileStorage: storage,
+		index:       index,
+	}
+}
+
+// SaveFile stores an uploaded file as a blob
+func (s *BlobStorage) SaveFile(fileHeader *multipart.FileHeader) (string, error) {
+	return s.SaveFileWithPath(fileHeader, "")
+}
+
+// SaveFileWithPath stores an uploaded file as a blob and returns its URL. Blobs are shared
+// between resources, so subPath is ignored; content that is already stored is not stored again.
+func (s *BlobStorage) SaveFileWithPath(fileHeader *multipart.FileHeader, subPath string) (string, error) {
+	if fileHeader == nil {
+		return "", nil // No file uploaded
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		logger.Error().Err(err).Str("filename", fileHeader.Filename).Msg("Failed to open uploaded file")
+		return "", fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer src.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, src); err != nil {
+		return "", fmt.Errorf("failed to hash uploaded file: %w", err)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind uploaded file: %w", err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	contentType := fileHeader.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = mime.TypeByExtension(ext)
+	}
+
+	blob, err := s.save(hex.EncodeToString(hash.Sum(nil)), ext, fileHeader.Size, func(blob *Blob) error {
+		_, err := s.FileStorage.SaveObject(src, fileHeader.Size, blob.Path, contentType)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.blobURL(blob), nil
+}
+
+// SaveContent stores data as a blob with thumbnails next to it, keyed by size, and returns
+// the blob's URL and the sizes of its thumbnails. When the content is already stored, the
+// thumbnails stored with it are kept.
+func (s *BlobStorage) SaveContent(data []byte, ext, contentType string, thumbnails map[int][]byte) (string, []int, error) {
+	sum := sha256.Sum256(data)
+	blob, err := s.save(hex.EncodeToString(sum[:]), ext, int64(len(data)), func(blob *Blob) error {
+		if _, err := s.FileStorage.SaveObject(bytes.NewReader(data), int64(len(data)), blob.Path, contentType); err != nil {
+			return err
+		}
+
+		sizes := make([]int, 0, len(thumbnails))
+		for size := range thumbnails {
+			sizes = append(sizes, size)
+		}
+		sort.Ints(sizes)
+		for _, size := range sizes {
+			thumbnail := thumbnails[size]
+			if _, err := s.FileStorage.SaveObject(bytes.NewReader(thumbnail), int64(len(thumbnail)), blobThumbnailPath(blob.Path, size), contentType); err != nil {
+				s.removeBlob(blob)
+				return err
+			}
+			blob.ThumbnailSizes = append(blob.ThumbnailSizes, size)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return s.blobURL(blob), blob.ThumbnailSizes, nil
+}
+
+// save returns the blob of size bytes stored under hash, calling store to write it first if it is new
+func (s *BlobStorage) save(hash, ext string, size int64, store func(*Blob) error) (*Blob, error) {
+	ctx := context.Background()
+
+	blob, err := s.index.Use(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up blob: %w", err)
+	}
+	if blob != nil {
+		logger.Debug().Str("hash", hash).Str("path", blob.Path).Msg("Content already stored; reusing blob")
+		return blob, nil
+	}
+
+	blob = &Blob{
+		Hash: hash,
+		Path: "/" + path.Join(blobDir, hash[:2], hash+ext),
+		Size: size,
+	}
+	if err := store(blob); err != nil {
+		return nil, err
+	}
+	if err := s.index.Add(ctx, blob); err != nil {
+		s.removeBlob(blob)
+		return nil, fmt.Errorf("failed to record blob: %w", err)
+	}
+	logger.Info().Str("hash", hash).Str("path", blob.Path).Msg("Blob stored")
+	return blob, nil
+}
+
+// DeleteFile deletes a stored file. A blob is only deleted when no file row refers to it any
+// more; its thumbnails go with it, so deleting a blob's thumbnail on its own does nothing.
+// Blobs an upload resolved to less than BlobGracePeriod ago are left for CollectGarbage.
+func (s *BlobStorage) DeleteFile(filePath string) error {
+	storedPath := StoredPath(s.GetBaseURL(), filePath)
+	hash, thumbnail, ok := parseBlobPath(storedPath)
+	if !ok {
+		return s.FileStorage.DeleteFile(filePath)
+	}
+	if thumbnail {
+		return nil
+	}
+
+	_, err := s.deleteUnused(context.Background(), hash, time.Now().Add(-BlobGracePeriod))
+	return err
+}
+
+// CollectGarbage deletes the blobs no file row has referred to for BlobGracePeriod
+// and returns how many it deleted
+func (s *BlobStorage) CollectGarbage(ctx context.Context) (int, error) {
+	before := time.Now().Add(-BlobGracePeriod)
+
+	deleted := 0
+	for {
+		hashes, err := s.index.ListUnused(ctx, before, blobCollectBatch)
+		if err != nil {
+			return deleted, err
+		}
+
+		progress := false
+		for _, hash := range hashes {
+			ok, err := s.deleteUnused(ctx, hash, before)
+			if err != nil {
+				logger.Error().Err(err).Str("hash", hash).Msg("Failed to delete unused blob")
+				continue
+			}
+			if ok {
+				deleted++
+				progress = true
+			}
+		}
+		if len(hashes) < blobCollectBatch || !progress {
+			return deleted, nil
+		}
+	}
+}
+
+// RunGarbageCollection runs CollectGarbage every interval; it runs until the process exits
+func (s *BlobStorage) RunGarbageCollection(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		deleted, err := s.CollectGarbage(context.Background())
+		if err != nil {
+			logger.Error().Err(err).Msg("Blob garbage collection failed")
+			continue
+		}
+		if deleted > 0 {
+			logger.Info().Int("deleted", deleted).Msg("Deleted unused blobs")
+		}
+	}
+}
+
+// deleteUnused deletes a blob and its thumbnails if no file row refers to it
+func (s *BlobStorage) deleteUnused(ctx context.Context, hash string, before time.Time) (bool, error) {
+	deleted, err := s.index.DeleteUnused(ctx, hash, before, func(blob *Blob) error {
+		if err := s.FileStorage.DeleteFile(blob.Path); err != nil {
+			return err
+		}
+		for _, size := range blob.ThumbnailSizes {
+			_ = s.FileStorage.DeleteFile(blobThumbnailPath(blob.Path, size))
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete blob: %w", err)
+	}
+	if deleted {
+		logger.Info().Str("hash", hash).Msg("Blob deleted; no file refers to it any more")
+	}
+	return deleted, nil
+}
+
+// removeBlob deletes the objects of a blob that could not be recorded
+func (s *BlobStorage) removeBlob(blob *Blob) {
+	_ = s.FileStorage.DeleteFile(blob.Path)
+	for _, size := range blob.ThumbnailSizes {
+		_ = s.FileStorage.DeleteFile(blobThumbnailPath(blob.Path, size))
+	}
+}
+
+// blobURL returns the URL of a blob in the form SaveFileWithPath of the underlying storage returns
+func (s *BlobStorage) blobURL(blob *Blob) string {
+	if s.GetBaseURL() == "" {
+		return path.Join("uploads", blob.Path)
+	}
+	return strings.TrimRight(s.GetBaseURL(), "/") + blob.Path
+}
+
+// BlobHash returns the content hash of the blob stored at filePath, a stored path or URL.
+// ok is false when filePath is not a blob, for instance a file stored before deduplication.
+func BlobHash(baseURL, filePath string) (hash string, ok bool) {
+	hash, thumbnail, ok := parseBlobPath(StoredPath(baseURL, filePath))
+	if !ok || thumbnail {
+		return "", false
+	}
+	return hash, true
+}
+
+// parseBlobPath splits a stored path below the blob directory into the blob's hash and
+// whether the path names one of its thumbnails
+func parseBlobPath(storedPath string) (hash string, thumbnail bool, ok bool) {
+	dir, name := path.Split(storedPath)
+	if path.Dir(path.Clean(dir)) != "/"+blobDir {
+		return "", false, false
+	}
+
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	if len(stem) < sha256.Size*2 {
+		return "", false, false
+	}
+	hash, rest := stem[:sha256.Size*2], stem[sha256.Size*2:]
+	if _, err := hex.DecodeString(hash); err != nil || path.Base(path.Clean(dir)) != hash[:2] {
+		return "", false, false
+	}
+
+	switch {
+	case rest == "":
+		return hash, false, true
+	case strings.HasPrefix(rest, "_"):
+		if size, err := strconv.Atoi(rest[1:]); err == nil && size > 0 {
+			return hash, true, true
+		}
+	}
+	return "", false, false
+}
+
+// blobThumbnailPath names a thumbnail the way models.File.ThumbnailPath does: "<hash>_256.jpg"
+func blobThumbnailPath(blobPath string, size int) string {
+	ext := path.Ext(blobPath)
+	return strings.TrimSuffix(blobPath, ext) + "_" + strconv.Itoa(size) + ext
+}
@@ -0,0 +1,326 @@
+package filestorage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testBaseURL is the URL local storage is served under, as in bootstrap
+const testBaseURL = "http://localhost:8080/uploads"
+
+// memoryBlobIndex is a BlobIndex in memory. refs stands in for the file rows referring to
+// each blob, which the database counts with a trigger.
+type memoryBlobIndex struct {
+	mu       sync.Mutex
+	blobs    map[string]*Blob
+	lastUsed map[string]time.Time
+	refs     map[string]int
+}
+
+func newMemoryBlobIndex() *memoryBlobIndex {
+	return &memoryBlobIndex{
+		blobs:    map[string]*Blob{},
+		lastUsed: map[string]time.Time{},
+		refs:     map[string]int{},
+	}
+}
+
+func (m *memoryBlobIndex) Use(ctx context.Context, hash string) (*Blob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	blob, ok := m.blobs[hash]
+	if !ok {
+		return nil, nil
+	}
+	m.lastUsed[hash] = time.Now()
+	copied := *blob
+	return &copied, nil
+}
+
+func (m *memoryBlobIndex) Add(ctx context.Context, blob *Blob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.blobs[blob.Hash]; !ok {
+		copied := *blob
+		m.blobs[blob.Hash] = &copied
+	}
+	m.lastUsed[blob.Hash] = time.Now()
+	return nil
+}
+
+func (m *memoryBlobIndex) DeleteUnused(ctx context.Context, hash string, before time.Time, remove func(*Blob) error) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	blob, ok := m.blobs[hash]
+	if !ok || m.refs[hash] > 0 || !m.lastUsed[hash].Before(before) {
+		return false, nil
+	}
+	if err := remove(blob); err != nil {
+		return false, err
+	}
+	delete(m.blobs, hash)
+	delete(m.lastUsed, hash)
+	return true, nil
+}
+
+func (m *memoryBlobIndex) ListUnused(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var hashes []string
+	for hash := range m.blobs {
+		if m.refs[hash] == 0 && m.lastUsed[hash].Before(before) && len(hashes) < limit {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
+
+// age makes a blob look last used longer ago than the grace period
+func (m *memoryBlobIndex) age(hash string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastUsed[hash] = time.Now().Add(-2 * BlobGracePeriod)
+}
+
+func newTestBlobStorage(t *testing.T) (*BlobStorage, *memoryBlobIndex, string) {
+	t.Helper()
+	dir := t.TempDir()
+	local, err := NewLocalStorage(dir, testBaseURL)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	index := newMemoryBlobIndex()
+	return NewBlobStorage(local, index), index, dir
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// exists reports whether a stored path is on disk
+func exists(dir, storedPath string) bool {
+	_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(storedPath)))
+	return err == nil
+}
+
+func TestBlobStorageDeduplicates(t *testing.T) {
+	storage, index, dir := newTestBlobStorage(t)
+
+	first, err := storage.SaveFileWithPath(multipartFile(t, "notes.PDF", "application/pdf", []byte("content")), "class_notes")
+	if err != nil {
+		t.Fatalf("SaveFileWithPath: %v", err)
+	}
+	hash := contentHash("content")
+	if want := testBaseURL + "/blobs/" + hash[:2] + "/" + hash + ".pdf"; first != want {
+		t.Errorf("URL = %q, want %q", first, want)
+	}
+
+	second, err := storage.SaveFileWithPath(multipartFile(t, "copy.pdf", "application/pdf", []byte("content")), "past_exams")
+	if err != nil {
+		t.Fatalf("SaveFileWithPath: %v", err)
+	}
+	if second != first {
+		t.Errorf("the same content was stored at %q and %q", first, second)
+	}
+
+	other, err := storage.SaveFile(multipartFile(t, "other.pdf", "application/pdf", []byte("other content")))
+	if err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	if other == first {
+		t.Error("different content was stored at the same URL")
+	}
+
+	if len(index.blobs) != 2 {
+		t.Errorf("index holds %d blobs, want 2", len(index.blobs))
+	}
+	if got, ok := BlobHash(testBaseURL, first); !ok || got != hash {
+		t.Errorf("BlobHash(%q) = %q, %v; want %q", first, got, ok, hash)
+	}
+	if !exists(dir, StoredPath(testBaseURL, first)) || !exists(dir, StoredPath(testBaseURL, other)) {
+		t.Error("blobs are missing from the storage")
+	}
+}
+
+func TestBlobStorageSaveContent(t *testing.T) {
+	storage, _, dir := newTestBlobStorage(t)
+
+	url, sizes, err := storage.SaveContent([]byte("image"), ".jpg", "image/jpeg", map[int][]byte{256: []byte("medium"), 64: []byte("small")})
+	if err != nil {
+		t.Fatalf("SaveContent: %v", err)
+	}
+	if len(sizes) != 2 || sizes[0] != 64 || sizes[1] != 256 {
+		t.Errorf("thumbnail sizes = %v, want [64 256]", sizes)
+	}
+	blobPath := StoredPath(testBaseURL, url)
+	for _, thumbnail := range []string{blobThumbnailPath(blobPath, 64), blobThumbnailPath(blobPath, 256)} {
+		if !exists(dir, thumbnail) {
+			t.Errorf("thumbnail %s was not stored", thumbnail)
+		}
+	}
+
+	// The same content keeps the thumbnails it was first stored with
+	again, sizes, err := storage.SaveContent([]byte("image"), ".jpg", "image/jpeg", map[int][]byte{1024: []byte("large")})
+	if err != nil {
+		t.Fatalf("SaveContent: %v", err)
+	}
+	if again != url || len(sizes) != 2 {
+		t.Errorf("SaveContent of stored content = %q, %v; want %q, [64 256]", again, sizes, url)
+	}
+	if exists(dir, blobThumbnailPath(blobPath, 1024)) {
+		t.Error("a thumbnail of already stored content was stored")
+	}
+}
+
+func TestBlobStorageDeleteFile(t *testing.T) {
+	storage, index, dir := newTestBlobStorage(t)
+	url, _, err := storage.SaveContent([]byte("image"), ".jpg", "image/jpeg", map[int][]byte{64: []byte("small")})
+	if err != nil {
+		t.Fatalf("SaveContent: %v", err)
+	}
+	hash, blobPath := contentHash("image"), StoredPath(testBaseURL, url)
+
+	// Of the two file rows sharing the blob, one is deleted; the other keeps the blob
+	index.refs[hash] = 1
+	index.age(hash)
+	if err := storage.DeleteFile(url); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if !exists(dir, blobPath) {
+		t.Fatal("a blob still referred to was deleted")
+	}
+
+	// Thumbnails go with their blob, never on their own
+	index.refs[hash] = 0
+	if err := storage.DeleteFile(blobThumbnailPath(url, 64)); err != nil {
+		t.Fatalf("DeleteFile of a thumbnail: %v", err)
+	}
+	if !exists(dir, blobThumbnailPath(blobPath, 64)) {
+		t.Fatal("a thumbnail was deleted on its own")
+	}
+
+	// An upload that just resolved to the blob protects it for the grace period
+	if _, err := index.Use(context.Background(), hash); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+	if err := storage.DeleteFile(url); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if !exists(dir, blobPath) {
+		t.Fatal("a blob within its grace period was deleted")
+	}
+
+	index.age(hash)
+	if err := storage.DeleteFile(url); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if exists(dir, blobPath) || exists(dir, blobThumbnailPath(blobPath, 64)) {
+		t.Error("an unused blob or its thumbnail was kept")
+	}
+	if _, ok := index.blobs[hash]; ok {
+		t.Error("the record of a deleted blob was kept")
+	}
+
+	// Files stored before deduplication are deleted directly
+	legacy, err := storage.FileStorage.SaveObject(strings.NewReader("legacy"), 6, "/profile_photos/legacy.jpg", "image/jpeg")
+	if err != nil {
+		t.Fatalf("SaveObject: %v", err)
+	}
+	if err := storage.DeleteFile(legacy); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if exists(dir, "/profile_photos/legacy.jpg") {
+		t.Error("a file outside the blob directory was kept")
+	}
+}
+
+// failingDeleteStorage fails to delete the files it names
+type failingDeleteStorage struct {
+	FileStorage
+	fail map[string]bool
+}
+
+func (s *failingDeleteStorage) DeleteFile(filePath string) error {
+	if s.fail[filePath] {
+		return errors.New("storage unavailable")
+	}
+	return s.FileStorage.DeleteFile(filePath)
+}
+
+func TestBlobStorageCollectGarbage(t *testing.T) {
+	dir := t.TempDir()
+	local, err := NewLocalStorage(dir, testBaseURL)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	failing := &failingDeleteStorage{FileStorage: local, fail: map[string]bool{}}
+	index := newMemoryBlobIndex()
+	storage := NewBlobStorage(failing, index)
+
+	urls := map[string]string{}
+	for _, content := range []string{"referenced", "recent", "unused", "undeletable"} {
+		url, err := storage.SaveFile(multipartFile(t, content+".txt", "text/plain", []byte(content)))
+		if err != nil {
+			t.Fatalf("SaveFile: %v", err)
+		}
+		urls[content] = url
+		if content != "recent" {
+			index.age(contentHash(content))
+		}
+	}
+	index.refs[contentHash("referenced")] = 1
+	failing.fail[StoredPath(testBaseURL, urls["undeletable"])] = true
+
+	deleted, err := storage.CollectGarbage(context.Background())
+	if err != nil {
+		t.Fatalf("CollectGarbage: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("CollectGarbage deleted %d blobs, want 1", deleted)
+	}
+	for content, want := range map[string]bool{"referenced": true, "recent": true, "unused": false, "undeletable": true} {
+		if got := exists(dir, StoredPath(testBaseURL, urls[content])); got != want {
+			t.Errorf("blob %q kept = %v, want %v", content, got, want)
+		}
+		if _, got := index.blobs[contentHash(content)]; got != want {
+			t.Errorf("record of %q kept = %v, want %v", content, got, want)
+		}
+	}
+}
+
+func TestParseBlobPath(t *testing.T) {
+	hash := contentHash("content")
+
+	tests := []struct {
+		path          string
+		wantHash      string
+		wantThumbnail bool
+	}{
+		{"/blobs/" + hash[:2] + "/" + hash + ".pdf", hash, false},
+		{"/blobs/" + hash[:2] + "/" + hash, hash, false},
+		{"/blobs/" + hash[:2] + "/" + hash + "_256.jpg", hash, true},
+		{"/blobs/" + hash[:2] + "/" + hash + "_0.jpg", "", false},
+		{"/blobs/" + hash[:2] + "/" + hash + "_large.jpg", "", false},
+		{"/blobs/ff/" + hash + ".pdf", "", false},
+		{"/blobs/" + hash + ".pdf", "", false},
+		{"/past_exams/" + hash[:2] + "/" + hash + ".pdf", "", false},
+		{"/blobs/" + hash[:2] + "/" + strings.Repeat("z", 64) + ".pdf", "", false},
+		{"/blobs/" + hash[:2] + "/short.pdf", "", false},
+	}
+
+	for _, tt := range tests {
+		hash, thumbnail, ok := parseBlobPath(tt.path)
+		if ok != (tt.wantHash != "") || hash != tt.wantHash || thumbnail != tt.wantThumbnail {
+			t.Errorf("parseBlobPath(%q) = %q, %v, %v; want %q, %v", tt.path, hash, thumbnail, ok, tt.wantHash, tt.wantThumbnail)
+		}
+	}
+}
@@ -2,7 +2,11 @@ package filestorage
 
 import (
 	"context"
+	"io"
 	"mime/multipart"
+	"path"
+	"strings"
+	"time"
 )
 
 // FileInfo represents information about a stored file
@@ -14,6 +18,13 @@ type FileInfo struct {
 	MimeType string // MIME type of the file
 }
 
+// StoredObject describes a file found in storage by WalkFiles
+type StoredObject struct {
+	Path    string // Stored path in the "/sub_path/name.ext" form, see StoredPath
+	Size    int64
+	ModTime time.Time
+}
+
 // FileStorage defines the interface for file storage operations
 type FileStorage interface {
 	// SaveFile saves a file and returns information about where it was stored
@@ -22,11 +33,35 @@ type FileStorage interface {
 	// SaveFileWithPath lets you specify a subdirectory for storing the file
 	SaveFileWithPath(fileHeader *multipart.FileHeader, path string) (string, error)
 
+	// SaveObject stores size bytes from r at filePath (relative, e.g. "profile_photos/user_1/photo.jpg")
+	// and returns the file's URL. Use it for content generated on the server, like thumbnails.
+	SaveObject(r io.Reader, size int64, filePath string, contentType string) (string, error)
+
 	// DeleteFile removes a file from storage
 	DeleteFile(filePath string) error
 
 	// GetFullPath returns the full filesystem path for a given file URL
 	GetFullPath(fileURL string) string
+
+	// OpenFile opens a stored file for reading; the caller must close it
+	OpenFile(filePath string) (io.ReadCloser, error)
+
+	// GetBaseURL returns the URL prefix of stored files; trimming it from a file URL gives the stored file path
+	GetBaseURL() string
+
+	// WalkFiles calls fn for every stored file, in no particular order, and stops at the first error fn returns
+	WalkFiles(ctx context.Context, fn func(StoredObject) error) error
+}
+
+// StoredPath normalizes a file path or URL as stored in the database to the "/sub_path/name.ext"
+// form both backends use. Older rows may hold the full local URL or an "uploads/..." relative path.
+func StoredPath(baseURL, filePath string) string {
+	p := strings.TrimPrefix(filePath, baseURL)
+	p = path.Clean("/" + p)
+	if strings.HasPrefix(p, "/uploads/") {
+		p = strings.TrimPrefix(p, "/uploads")
+	}
+	return p
 }
 
 // FileStorageWithDB extends FileStorage with database operations
@@ -1,6 +1,7 @@
 package filestorage
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -106,6 +107,40 @@ func (ls *LocalStorage) SaveFileWithPath(fileHeader *multipart.FileHeader, subPa
 	return accessiblePath, nil
 }
 
+// SaveObject writes r to filePath below the storage directory.
+// size and contentType are not needed on disk.
+func (ls *LocalStorage) SaveObject(r io.Reader, size int64, filePath string, contentType string) (string, error) {
+	// Cleaning against "/" keeps the path inside basePath
+	relativePath := strings.TrimPrefix(filepath.Clean("/"+filePath), "/")
+	if relativePath == "" {
+		return "", fmt.Errorf("empty file path")
+	}
+
+	dstPath := filepath.Join(ls.basePath, relativePath)
+	if err := os.MkdirAll(filepath.Dir(dstPath), os.ModePerm); err != nil {
+		logger.Error().Err(err).Str("path", dstPath).Msg("Failed to create subdirectory")
+		return "", fmt.Errorf("failed to create subdirectory: %w", err)
+	}
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		logger.Error().Err(err).Str("path", dstPath).Msg("Failed to create destination file")
+		return "", fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, r); err != nil {
+		logger.Error().Err(err).Str("path", dstPath).Msg("Failed to write file content")
+		_ = os.Remove(dstPath)
+		return "", fmt.Errorf("failed to save file content: %w", err)
+	}
+
+	if ls.baseURL != "" {
+		return strings.TrimRight(ls.baseURL, "/") + "/" + filepath.ToSlash(relativePath), nil
+	}
+	return filepath.Join("uploads", relativePath), nil
+}
+
 // SaveFile saves an uploaded file using the default path
 func (ls *LocalStorage) SaveFile(fileHeader *multipart.FileHeader) (string, error) {
 	return ls.SaveFileWithPath(fileHeader, "")
@@ -143,6 +178,25 @@ func (ls *LocalStorage) DeleteFile(filePath string) error {
 	return nil
 }
 
+// OpenFile opens a stored file for streaming.
+// It accepts the file path as stored in the database, like DeleteFile does.
+func (ls *LocalStorage) OpenFile(filePath string) (io.ReadCloser, error) {
+	if filePath == "" {
+		return nil, fmt.Errorf("empty file path")
+	}
+
+	relativePath := strings.TrimPrefix(filePath, ls.baseURL)
+	// Cleaning against "/" keeps the path inside basePath
+	relativePath = filepath.Clean("/" + relativePath)
+	physicalPath := filepath.Join(ls.basePath, relativePath)
+
+	file, err := os.Open(physicalPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, nil
+}
+
 // GetFullPath returns the full filesystem path for a given file URL.
 // This is useful for getting the actual path for deletion.
 func (ls *LocalStorage) GetFullPath(fileURL string) string {
@@ -155,6 +209,35 @@ func (ls *LocalStorage) GetFullPath(fileURL string) string {
 	return filepath.Join(ls.basePath, filename)
 }
 
+// WalkFiles calls fn for every file below the storage directory. Hidden files are skipped.
+func (ls *LocalStorage) WalkFiles(ctx context.Context, fn func(StoredObject) error) error {
+	return filepath.WalkDir(ls.basePath, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(ls.basePath, p)
+		if err != nil {
+			return err
+		}
+		return fn(StoredObject{
+			Path:    "/" + filepath.ToSlash(relativePath),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	})
+}
+
 // GetBaseURL returns the base URL for file access
 func (ls *LocalStorage) GetBaseURL() string {
 	return ls.baseURL
@@ -0,0 +1,220 @@
+package filestorage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/yigit/unisphere/internal/pkg/logger"
+)
+
+// S3Config holds the settings of an S3-compatible bucket (AWS S3, MinIO, ...)
+type S3Config struct {
+	Endpoint  string // Host and optional port, e.g. "localhost:9000" or "s3.eu-central-1.amazonaws.com"
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	// PublicURL is the base URL objects are served from. Defaults to the path-style bucket URL.
+	PublicURL string
+}
+
+// S3Storage stores files as objects in an S3-compatible bucket.
+// Object keys mirror the local layout ("past_exams/<uuid>.pdf"), so the file_path
+// stored in the database stays the same whichever backend is used.
+type S3Storage struct {
+	client  *minio.Client
+	bucket  string
+	baseURL string
+}
+
+// NewS3Storage connects to the bucket described by cfg, creating the bucket if it does not exist.
+func NewS3Storage(ctx context.Context, cfg S3Config) (*S3Storage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 endpoint and bucket are required")
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket %s: %w", cfg.Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("failed to create bucket %s: %w", cfg.Bucket, err)
+		}
+		logger.Info().Str("bucket", cfg.Bucket).Msg("S3 bucket created")
+	}
+
+	baseURL := cfg.PublicURL
+	if baseURL == "" {
+		scheme := "http"
+		if cfg.UseSSL {
+			scheme = "https"
+		}
+		baseURL = scheme + "://" + cfg.Endpoint + "/" + cfg.Bucket
+	}
+
+	logger.Info().Str("endpoint", cfg.Endpoint).Str("bucket", cfg.Bucket).Msg("S3 storage ready")
+	return &S3Storage{
+		client:  client,
+		bucket:  cfg.Bucket,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}, nil
+}
+
+// SaveFileWithPath uploads a file under the given key prefix and returns its public URL
+func (s *S3Storage) SaveFileWithPath(fileHeader *multipart.FileHeader, subPath string) (string, error) {
+	if fileHeader == nil {
+		return "", nil // No file uploaded
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		logger.Error().Err(err).Str("filename", fileHeader.Filename).Msg("Failed to open uploaded file")
+		return "", fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer file.Close()
+
+	// Generate a unique object name to prevent collisions
+	ext := filepath.Ext(fileHeader.Filename)
+	key := path.Join(strings.Trim(subPath, "/"), uuid.New().String()+ext)
+
+	contentType := fileHeader.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = mime.TypeByExtension(ext)
+	}
+
+	fileURL, err := s.PutObject(context.Background(), key, file, fileHeader.Size, contentType)
+	if err != nil {
+		return "", err
+	}
+
+	logger.Info().Str("filename", fileHeader.Filename).Str("key", key).Msg("File uploaded to S3 successfully")
+	return fileURL, nil
+}
+
+// SaveFile uploads a file to the root of the bucket
+func (s *S3Storage) SaveFile(fileHeader *multipart.FileHeader) (string, error) {
+	return s.SaveFileWithPath(fileHeader, "")
+}
+
+// SaveObject uploads r under the object key filePath
+func (s *S3Storage) SaveObject(r io.Reader, size int64, filePath string, contentType string) (string, error) {
+	key := strings.TrimPrefix(path.Clean("/"+filePath), "/")
+	if key == "" {
+		return "", fmt.Errorf("empty file path")
+	}
+	return s.PutObject(context.Background(), key, r, size, contentType)
+}
+
+// PutObject uploads size bytes from r under key and returns the object's public URL.
+// A size of -1 streams an object of unknown length.
+func (s *S3Storage) PutObject(ctx context.Context, key string, r io.Reader, size int64, contentType string) (string, error) {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		logger.Error().Err(err).Str("key", key).Msg("Failed to upload object")
+		return "", fmt.Errorf("failed to upload object: %w", err)
+	}
+	return s.baseURL + "/" + key, nil
+}
+
+// DeleteFile removes an object. It accepts the file path as stored in the database
+// (e.g. "/profile_photos/filename.jpg") or the full object URL.
+// Deleting a missing object is not an error.
+func (s *S3Storage) DeleteFile(filePath string) error {
+	if filePath == "" {
+		return nil // Nothing to delete
+	}
+
+	key := s.objectKey(filePath)
+	if err := s.client.RemoveObject(context.Background(), s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		logger.Error().Err(err).Str("key", key).Msg("Failed to delete object")
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	logger.Info().Str("key", key).Msg("Object deleted successfully")
+	return nil
+}
+
+// OpenFile opens an object for streaming. It accepts the same paths as DeleteFile.
+func (s *S3Storage) OpenFile(filePath string) (io.ReadCloser, error) {
+	if filePath == "" {
+		return nil, fmt.Errorf("empty file path")
+	}
+
+	object, err := s.client.GetObject(context.Background(), s.bucket, s.objectKey(filePath), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	// GetObject is lazy; Stat surfaces a missing object before the caller starts streaming
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return object, nil
+}
+
+// GetFullPath returns the public URL of the object behind a stored path or URL
+func (s *S3Storage) GetFullPath(fileURL string) string {
+	key := s.objectKey(fileURL)
+	if key == "" {
+		return ""
+	}
+	return s.baseURL + "/" + key
+}
+
+// WalkFiles calls fn for every object in the bucket
+func (s *S3Storage) WalkFiles(ctx context.Context, fn func(StoredObject) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Stops the listing when fn fails
+
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		err := fn(StoredObject{
+			Path:    "/" + object.Key,
+			Size:    object.Size,
+			ModTime: object.LastModified,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// GetBaseURL returns the base URL objects are served from
+func (s *S3Storage) GetBaseURL() string {
+	return s.baseURL
+}
+
+// objectKey turns a stored file path or URL into a bucket key
+func (s *S3Storage) objectKey(filePath string) string {
+	key := strings.TrimPrefix(filePath, s.baseURL)
+	// Cleaning against "/" drops any ".." segments
+	key = path.Clean("/" + key)
+	return strings.TrimPrefix(key, "/")
+}
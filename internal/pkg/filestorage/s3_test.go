@@ -0,0 +1,307 @@
+package filestorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yigit/unisphere/internal/pkg/filestorage/s3test"
+)
+
+const testBucket = "unisphere-test"
+
+func newTestS3Storage(t *testing.T, server *s3test.Server) *S3Storage {
+	t.Helper()
+	storage, err := NewS3Storage(context.Background(), S3Config{
+		Endpoint:  server.Endpoint(),
+		Region:    "us-east-1",
+		Bucket:    testBucket,
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return storage
+}
+
+// multipartFile builds the file header of an upload the way gin receives it
+func multipartFile(t *testing.T, filename, contentType string, content []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := make(map[string][]string)
+	header["Content-Disposition"] = []string{`form-data; name="file"; filename="` + filename + `"`}
+	if contentType != "" {
+		header["Content-Type"] = []string{contentType}
+	}
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("creating part: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatalf("parsing form: %v", err)
+	}
+	return req.MultipartForm.File["file"][0]
+}
+
+func TestNewS3Storage(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+
+	storage := newTestS3Storage(t, server)
+	if !server.HasBucket(testBucket) {
+		t.Error("bucket was not created")
+	}
+	if want := "http://" + server.Endpoint() + "/" + testBucket; storage.GetBaseURL() != want {
+		t.Errorf("GetBaseURL() = %q, want %q", storage.GetBaseURL(), want)
+	}
+
+	// An existing bucket is reused and a public URL replaces the bucket URL
+	server.PutObject(testBucket, "kept.txt", []byte("kept"), "text/plain")
+	storage, err := NewS3Storage(context.Background(), S3Config{
+		Endpoint: server.Endpoint(), Region: "us-east-1", Bucket: testBucket, PublicURL: "https://cdn.example.edu/files/",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage with an existing bucket: %v", err)
+	}
+	if storage.GetBaseURL() != "https://cdn.example.edu/files" {
+		t.Errorf("GetBaseURL() = %q, want the public URL without its trailing slash", storage.GetBaseURL())
+	}
+	if server.Object(testBucket, "kept.txt") == nil {
+		t.Error("existing object was lost")
+	}
+
+	for _, cfg := range []S3Config{{Bucket: testBucket}, {Endpoint: server.Endpoint()}} {
+		if _, err := NewS3Storage(context.Background(), cfg); err == nil {
+			t.Errorf("NewS3Storage(%+v) succeeded, want an error", cfg)
+		}
+	}
+}
+
+func TestS3StorageSaveAndOpen(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	storage := newTestS3Storage(t, server)
+
+	tests := []struct {
+		name            string
+		filename        string
+		contentType     string
+		subPath         string
+		wantPrefix      string
+		wantContentType string
+	}{
+		{"sub path", "exam.pdf", "application/pdf", "past_exams", "past_exams/", "application/pdf"},
+		{"slashes are trimmed", "notes.txt", "text/plain", "/class_notes/", "class_notes/", "text/plain"},
+		{"type from the extension", "photo.png", "", "profile_photos/user_1", "profile_photos/user_1/", "image/png"},
+		{"bucket root", "readme.txt", "text/plain", "", "", "text/plain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := []byte("content of " + tt.filename)
+			fileURL, err := storage.SaveFileWithPath(multipartFile(t, tt.filename, tt.contentType, content), tt.subPath)
+			if err != nil {
+				t.Fatalf("SaveFileWithPath: %v", err)
+			}
+
+			key := strings.TrimPrefix(fileURL, storage.GetBaseURL()+"/")
+			if !strings.HasPrefix(key, tt.wantPrefix) || strings.Contains(strings.TrimPrefix(key, tt.wantPrefix), "/") {
+				t.Errorf("key %q is not directly under %q", key, tt.wantPrefix)
+			}
+			object := server.Object(testBucket, key)
+			if object == nil {
+				t.Fatalf("object %q was not stored", key)
+			}
+			if !bytes.Equal(object.Data, content) {
+				t.Errorf("stored %q, want %q", object.Data, content)
+			}
+			if !strings.HasPrefix(object.ContentType, tt.wantContentType) {
+				t.Errorf("content type = %q, want %q", object.ContentType, tt.wantContentType)
+			}
+
+			// The object opens by the stored path as well as by its URL
+			for _, p := range []string{"/" + key, fileURL} {
+				reader, err := storage.OpenFile(p)
+				if err != nil {
+					t.Fatalf("OpenFile(%q): %v", p, err)
+				}
+				got, err := io.ReadAll(reader)
+				reader.Close()
+				if err != nil || !bytes.Equal(got, content) {
+					t.Errorf("OpenFile(%q) read %q, %v; want %q", p, got, err, content)
+				}
+			}
+		})
+	}
+
+	if fileURL, err := storage.SaveFile(nil); fileURL != "" || err != nil {
+		t.Errorf("SaveFile(nil) = %q, %v; want no upload", fileURL, err)
+	}
+}
+
+func TestS3StorageSaveObject(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	storage := newTestS3Storage(t, server)
+
+	tests := []struct {
+		name     string
+		filePath string
+		size     int64
+		wantKey  string
+		wantErr  bool
+	}{
+		{"relative path", "thumbnails/a_256.jpg", 5, "thumbnails/a_256.jpg", false},
+		{"leading slash", "/thumbnails/b_256.jpg", 5, "thumbnails/b_256.jpg", false},
+		{"dot segments are cleaned", "thumbnails/../../c.jpg", 5, "c.jpg", false},
+		{"unknown size", "streams/d.bin", -1, "streams/d.bin", false},
+		{"empty path", "", 5, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileURL, err := storage.SaveObject(strings.NewReader("bytes"), tt.size, tt.filePath, "")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("SaveObject(%q) succeeded, want an error", tt.filePath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SaveObject(%q): %v", tt.filePath, err)
+			}
+			if want := storage.GetBaseURL() + "/" + tt.wantKey; fileURL != want {
+				t.Errorf("SaveObject(%q) = %q, want %q", tt.filePath, fileURL, want)
+			}
+			object := server.Object(testBucket, tt.wantKey)
+			if object == nil || string(object.Data) != "bytes" {
+				t.Fatalf("object %q = %+v, want the uploaded bytes", tt.wantKey, object)
+			}
+		})
+	}
+}
+
+func TestS3StorageDeleteFile(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	storage := newTestS3Storage(t, server)
+
+	tests := []struct {
+		name     string
+		filePath func(key string) string
+	}{
+		{"stored path", func(key string) string { return "/" + key }},
+		{"object URL", func(key string) string { return storage.GetBaseURL() + "/" + key }},
+		{"dot segments", func(key string) string { return "/other/../" + key }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := "past_exams/" + strings.ReplaceAll(tt.name, " ", "_") + ".pdf"
+			server.PutObject(testBucket, key, []byte("pdf"), "application/pdf")
+
+			if err := storage.DeleteFile(tt.filePath(key)); err != nil {
+				t.Fatalf("DeleteFile: %v", err)
+			}
+			if server.Object(testBucket, key) != nil {
+				t.Errorf("object %q still exists", key)
+			}
+			if _, err := storage.OpenFile("/" + key); err == nil {
+				t.Errorf("OpenFile of the deleted object succeeded")
+			}
+		})
+	}
+
+	if err := storage.DeleteFile("/past_exams/missing.pdf"); err != nil {
+		t.Errorf("DeleteFile of a missing object = %v, want nil", err)
+	}
+	if err := storage.DeleteFile(""); err != nil {
+		t.Errorf("DeleteFile(\"\") = %v, want nil", err)
+	}
+}
+
+func TestS3StorageGetFullPath(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	storage := newTestS3Storage(t, server)
+	base := storage.GetBaseURL()
+
+	tests := []struct {
+		fileURL string
+		want    string
+	}{
+		{"/past_exams/a.pdf", base + "/past_exams/a.pdf"},
+		{"past_exams/a.pdf", base + "/past_exams/a.pdf"},
+		{base + "/past_exams/a.pdf", base + "/past_exams/a.pdf"},
+		{"/../../etc/passwd", base + "/etc/passwd"},
+		{"", ""},
+		{"/", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fileURL, func(t *testing.T) {
+			if got := storage.GetFullPath(tt.fileURL); got != tt.want {
+				t.Errorf("GetFullPath(%q) = %q, want %q", tt.fileURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestS3StorageWalkFiles(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	storage := newTestS3Storage(t, server)
+
+	objects := map[string]string{
+		"past_exams/a.pdf":        "aaaa",
+		"class_notes/b.txt":       "bb",
+		"profile_photos/u/c.jpeg": "c",
+	}
+	for key, data := range objects {
+		server.PutObject(testBucket, key, []byte(data), "")
+	}
+
+	var paths []string
+	err := storage.WalkFiles(context.Background(), func(object StoredObject) error {
+		paths = append(paths, object.Path)
+		if want := int64(len(objects[strings.TrimPrefix(object.Path, "/")])); object.Size != want {
+			t.Errorf("size of %s = %d, want %d", object.Path, object.Size, want)
+		}
+		if object.ModTime.IsZero() {
+			t.Errorf("%s has no modification time", object.Path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkFiles: %v", err)
+	}
+	sort.Strings(paths)
+	want := []string{"/class_notes/b.txt", "/past_exams/a.pdf", "/profile_photos/u/c.jpeg"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("WalkFiles visited %v, want %v", paths, want)
+	}
+
+	// The first error of fn stops the walk
+	stop := errors.New("stop")
+	visited := 0
+	err = storage.WalkFiles(context.Background(), func(StoredObject) error {
+		visited++
+		return stop
+	})
+	if !errors.Is(err, stop) || visited != 1 {
+		t.Errorf("WalkFiles = %v after %d objects, want %v after 1", err, visited, stop)
+	}
+}
@@ -0,0 +1,319 @@
+// Package s3test provides an in-memory stand-in for an S3-compatible server, so the S3
+// storage backend can be tested without MinIO. It covers the calls S3Storage makes: bucket
+// existence and creation, single and multipart uploads, downloads, deletes and listing.
+// Signatures are not checked.
+package s3test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Object is an object stored by the server
+type Object struct {
+	Data         []byte
+	ContentType  string
+	LastModified time.Time
+}
+
+// Server is a fake S3 server. Requests use path-style addressing: /<bucket>/<key>.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	buckets map[string]map[string]*Object
+	uploads map[string]map[int][]byte // Multipart uploads by ID, their parts by number
+	nextID  int
+}
+
+// NewServer starts a server with no buckets; the caller must Close it
+func NewServer() *Server {
+	s := &Server{
+		buckets: make(map[string]map[string]*Object),
+		uploads: make(map[string]map[int][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Endpoint returns the host and port of the server, as S3Config.Endpoint expects them
+func (s *Server) Endpoint() string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+// CreateBucket creates an empty bucket
+func (s *Server) CreateBucket(bucket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = make(map[string]*Object)
+	}
+}
+
+// HasBucket reports whether a bucket exists
+func (s *Server) HasBucket(bucket string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buckets[bucket] != nil
+}
+
+// Object returns a stored object, or nil
+func (s *Server) Object(bucket, key string) *Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buckets[bucket][key]
+}
+
+// Keys returns the keys in a bucket in order
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.buckets[bucket]))
+	for key := range s.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PutObject stores an object directly
+func (s *Server) PutObject(bucket, key string, data []byte, contentType string) {
+	s.CreateBucket(bucket)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets[bucket][key] = &Object{Data: data, ContentType: contentType, LastModified: time.Now().UTC()}
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objects := s.buckets[bucket]
+	if key == "" {
+		switch r.Method {
+		case http.MethodHead:
+			if objects == nil {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			if objects != nil {
+				writeError(w, http.StatusConflict, "BucketAlreadyOwnedByYou")
+				return
+			}
+			s.buckets[bucket] = make(map[string]*Object)
+		case http.MethodGet:
+			if objects == nil {
+				writeError(w, http.StatusNotFound, "NoSuchBucket")
+				return
+			}
+			if _, ok := query["location"]; ok {
+				writeXML(w, struct {
+					XMLName xml.Name `xml:"LocationConstraint"`
+				}{})
+				return
+			}
+			s.list(w, bucket, query.Get("prefix"))
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+		}
+		return
+	}
+
+	if objects == nil {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		s.nextID++
+		uploadID := strconv.Itoa(s.nextID)
+		s.uploads[uploadID] = make(map[int][]byte)
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucket, Key: key, UploadId: uploadID})
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		data, err := readBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		parts[number] = data
+		w.Header().Set("ETag", etag(data))
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		numbers := make([]int, 0, len(parts))
+		for number := range parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		for _, number := range numbers {
+			data = append(data, parts[number]...)
+		}
+		delete(s.uploads, query.Get("uploadId"))
+		objects[key] = &Object{Data: data, ContentType: "application/octet-stream", LastModified: time.Now().UTC()}
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: etag(data)})
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(s.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut:
+		data, err := readBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		objects[key] = &Object{Data: data, ContentType: r.Header.Get("Content-Type"), LastModified: time.Now().UTC()}
+		w.Header().Set("ETag", etag(data))
+
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object := objects[key]
+		if object == nil {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", object.ContentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.Data)))
+		w.Header().Set("ETag", etag(object.Data))
+		w.Header().Set("Last-Modified", object.LastModified.Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			w.Write(object.Data)
+		}
+
+	case r.Method == http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// list answers a ListObjectsV2 request with every matching key in one page
+func (s *Server) list(w http.ResponseWriter, bucket, prefix string) {
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int
+		StorageClass string
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		MaxKeys     int
+		IsTruncated bool
+		Contents    []content
+	}{Name: bucket, Prefix: prefix, MaxKeys: 1000}
+
+	keys := make([]string, 0, len(s.buckets[bucket]))
+	for key := range s.buckets[bucket] {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		object := s.buckets[bucket][key]
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			LastModified: object.LastModified.Format(time.RFC3339),
+			ETag:         etag(object.Data),
+			Size:         len(object.Data),
+			StorageClass: "STANDARD",
+		})
+	}
+	result.KeyCount = len(result.Contents)
+	writeXML(w, result)
+}
+
+// readBody reads a request body, decoding the aws-chunked encoding clients use for
+// streaming signatures over plain HTTP
+func readBody(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data []byte
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunk size %q", sizeHex)
+		}
+		if size == 0 {
+			return data, nil // Trailing headers, if any, are ignored
+		}
+		chunk := make([]byte, size+2) // The chunk and its CRLF
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write(buf.Bytes())
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s<Error><Code>%s</Code><Message>%s</Message></Error>", xml.Header, code, code)
+}
@@ -0,0 +1,53 @@
+package filestorage
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/yigit/unisphere/internal/pkg/logger"
+)
+
+// ZipEntry is a stored file to be written into a ZIP archive
+type ZipEntry struct {
+	Name     string    // Path of the file inside the archive
+	FilePath string    // Path of the file as stored in the database
+	Modified time.Time // Modification time recorded in the archive
+}
+
+// WriteZip streams the entries from storage into a ZIP archive written to w.
+// Files are copied one at a time, so the archive is never held in memory.
+// Entries that cannot be opened are skipped and logged.
+func WriteZip(w io.Writer, storage FileStorage, entries []ZipEntry) error {
+	zw := zip.NewWriter(w)
+
+	for _, entry := range entries {
+		src, err := storage.OpenFile(entry.FilePath)
+		if err != nil {
+			logger.Warn().Err(err).Str("path", entry.FilePath).Msg("Skipping missing file in ZIP archive")
+			continue
+		}
+
+		dst, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     entry.Name,
+			Method:   zip.Deflate,
+			Modified: entry.Modified,
+		})
+		if err != nil {
+			src.Close()
+			return fmt.Errorf("failed to create ZIP entry %s: %w", entry.Name, err)
+		}
+
+		_, err = io.Copy(dst, src)
+		src.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write ZIP entry %s: %w", entry.Name, err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish ZIP archive: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/fnv"
+	"io"
+	"strings"
+	"unicode"
+)
+
+const (
+	// NumHashes is the length of a MinHash signature
+	NumHashes = 128
+	// Bands is the number of LSH bands a signature is split into
+	Bands = 32
+	// RowsPerBand is the number of signature rows in each LSH band
+	RowsPerBand = NumHashes / Bands
+	// ShingleSize is the number of consecutive words forming a shingle
+	ShingleSize = 5
+	// MinShingles is the minimum number of shingles required to build a signature
+	MinShingles = 10
+)
+
+// Signature is a MinHash signature of a document
+type Signature []uint32
+
+// seeds are the per-row seeds used to derive NumHashes independent hash functions
+var seeds = func() [NumHashes]uint64 {
+	var s [NumHashes]uint64
+	x := uint64(0x9E3779B97F4A7C15)
+	for i := range s {
+		x = splitMix64(x)
+		s[i] = x
+	}
+	return s
+}()
+
+// SHA256Hex returns the hex-encoded SHA-256 digest of everything read from r
+func SHA256Hex(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Shingles splits text into normalized word tokens and returns the hashed k-word shingles
+func Shingles(text string) map[uint32]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	shingles := make(map[uint32]struct{})
+	if len(words) < ShingleSize {
+		return shingles
+	}
+
+	for i := 0; i+ShingleSize <= len(words); i++ {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(strings.Join(words[i:i+ShingleSize], " ")))
+		shingles[h.Sum32()] = struct{}{}
+	}
+	return shingles
+}
+
+// Compute builds a MinHash signature for text.
+// Returns nil if the text is too short to produce a meaningful signature.
+func Compute(text string) Signature {
+	shingles := Shingles(text)
+	if len(shingles) < MinShingles {
+		return nil
+	}
+
+	sig := make(Signature, NumHashes)
+	for i := range sig {
+		sig[i] = ^uint32(0)
+	}
+
+	for shingle := range shingles {
+		for i, seed := range seeds {
+			v := uint32(splitMix64(uint64(shingle) ^ seed))
+			if v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// Similarity estimates the Jaccard similarity of the documents behind two signatures
+func Similarity(a, b Signature) float64 {
+	if len(a) != NumHashes || len(b) != NumHashes {
+		return 0
+	}
+	matches := 0
+	for i := range a {
+		if a[i] == b[i] {
+			matches++
+		}
+	}
+	return float64(matches) / float64(NumHashes)
+}
+
+// BandHashes returns one hash per LSH band; documents sharing any band hash are candidates
+func (s Signature) BandHashes() []int64 {
+	if len(s) != NumHashes {
+		return nil
+	}
+	bands := make([]int64, Bands)
+	buf := make([]byte, 4*RowsPerBand)
+	for b := 0; b < Bands; b++ {
+		for r := 0; r < RowsPerBand; r++ {
+			binary.LittleEndian.PutUint32(buf[r*4:], s[b*RowsPerBand+r])
+		}
+		h := fnv.New64a()
+		_, _ = h.Write(buf)
+		bands[b] = int64(h.Sum64())
+	}
+	return bands
+}
+
+// ToInt64s converts the signature to a form that can be stored in a BIGINT[] column
+func (s Signature) ToInt64s() []int64 {
+	out := make([]int64, len(s))
+	for i, v := range s {
+		out[i] = int64(v)
+	}
+	return out
+}
+
+// FromInt64s converts a stored BIGINT[] column back to a signature
+func FromInt64s(values []int64) Signature {
+	sig := make(Signature, len(values))
+	for i, v := range values {
+		sig[i] = uint32(v)
+	}
+	return sig
+}
+
+// splitMix64 is a fast, well-distributed 64-bit mixing function
+func splitMix64(x uint64) uint64 {
+	x += 0x9E3779B97F4A7C15
+	x = (x ^ (x >> 30)) * 0xBF58476D1CE4E5B9
+	x = (x ^ (x >> 27)) * 0x94D049BB133111EB
+	return x ^ (x >> 31)
+}
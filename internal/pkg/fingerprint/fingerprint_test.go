@@ -0,0 +1,202 @@
+package fingerprint
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// words returns n distinct words starting with prefix
+func words(prefix string, n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return out
+}
+
+// jaccard returns the exact Jaccard similarity of the shingle sets of two texts
+func jaccard(a, b string) float64 {
+	sa, sb := Shingles(a), Shingles(b)
+	intersection := 0
+	for s := range sa {
+		if _, ok := sb[s]; ok {
+			intersection++
+		}
+	}
+	union := len(sa) + len(sb) - intersection
+	if union == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(union)
+}
+
+// sharesBand reports whether two signatures would be LSH candidates
+func sharesBand(a, b Signature) bool {
+	bandsA, bandsB := a.BandHashes(), b.BandHashes()
+	for i := range bandsA {
+		if bandsA[i] == bandsB[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func TestShingles(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"empty", "", 0},
+		{"shorter than a shingle", "one two three four", 0},
+		{"exactly one shingle", "one two three four five", 1},
+		{"sliding window", strings.Join(words("w", 20), " "), 16},
+		{"repeated shingles count once", strings.Repeat("a b c d e ", 10), 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(Shingles(tt.text)); got != tt.want {
+				t.Errorf("len(Shingles(%q)) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeNormalizes(t *testing.T) {
+	base := strings.Join(words("word", 40), " ")
+
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"upper case", strings.ToUpper(base)},
+		{"punctuation", strings.ReplaceAll(base, " ", ", ")},
+		{"whitespace", strings.ReplaceAll(base, " ", "\n\t ")},
+	}
+
+	want := Compute(base)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Similarity(want, Compute(tt.text)); got != 1 {
+				t.Errorf("similarity to the normalized text = %v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestComputeTooShort(t *testing.T) {
+	// MinShingles shingles need MinShingles+ShingleSize-1 words
+	if sig := Compute(strings.Join(words("w", MinShingles+ShingleSize-2), " ")); sig != nil {
+		t.Errorf("Compute of too short a text = %v, want nil", sig)
+	}
+	if sig := Compute(strings.Join(words("w", MinShingles+ShingleSize-1), " ")); len(sig) != NumHashes {
+		t.Errorf("Compute returned %d hashes, want %d", len(sig), NumHashes)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	base := words("word", 200)
+	text := strings.Join(base, " ")
+
+	// edited replaces every step-th word, changing up to ShingleSize shingles per edit
+	edited := func(step int) string {
+		changed := append([]string(nil), base...)
+		for i := step / 2; i < len(changed); i += step {
+			changed[i] = "changed" + changed[i]
+		}
+		return strings.Join(changed, " ")
+	}
+
+	// LSH makes a pair a candidate with probability 1-(1-s^RowsPerBand)^Bands: almost surely
+	// at the near-duplicate threshold of 0.8, almost never for unrelated text. In between it
+	// is left to chance, so only those two ends are checked.
+	tests := []struct {
+		name      string
+		other     string
+		candidate string // "yes", "no" or "" for either
+	}{
+		{"identical", text, "yes"},
+		{"one word changed", edited(1000), "yes"},
+		{"every 50th word changed", edited(50), "yes"},
+		{"every 10th word changed", edited(10), ""},
+		{"every 5th word changed", edited(5), ""},
+		{"unrelated", strings.Join(words("other", 200), " "), "no"},
+	}
+
+	a := Compute(text)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Compute(tt.other)
+			exact := jaccard(text, tt.other)
+			estimate := Similarity(a, b)
+
+			// The standard error of a 128-hash estimate is at most 0.045
+			if math.Abs(estimate-exact) > 0.15 {
+				t.Errorf("Similarity = %.3f, exact Jaccard similarity %.3f", estimate, exact)
+			}
+			if tt.candidate != "" {
+				if got, want := sharesBand(a, b), tt.candidate == "yes"; got != want {
+					t.Errorf("LSH candidate = %v, want %v (similarity %.3f)", got, want, estimate)
+				}
+			}
+		})
+	}
+}
+
+func TestSimilarityInvalidSignatures(t *testing.T) {
+	sig := Compute(strings.Join(words("w", 50), " "))
+
+	tests := []struct {
+		name string
+		a, b Signature
+	}{
+		{"nil", nil, sig},
+		{"both nil", nil, nil},
+		{"short", sig[:NumHashes-1], sig[:NumHashes-1]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Similarity(tt.a, tt.b); got != 0 {
+				t.Errorf("Similarity = %v, want 0", got)
+			}
+		})
+	}
+	if bands := sig[:10].BandHashes(); bands != nil {
+		t.Errorf("BandHashes of a short signature = %v, want nil", bands)
+	}
+}
+
+func TestBandHashes(t *testing.T) {
+	sig := Compute(strings.Join(words("w", 50), " "))
+	bands := sig.BandHashes()
+	if len(bands) != Bands {
+		t.Fatalf("got %d bands, want %d", len(bands), Bands)
+	}
+
+	// Changing a single row changes exactly the band that holds it
+	changed := append(Signature(nil), sig...)
+	changed[RowsPerBand*3+1]++
+	changedBands := changed.BandHashes()
+	for i := range bands {
+		if same := bands[i] == changedBands[i]; same != (i != 3) {
+			t.Errorf("band %d unchanged = %v, want %v", i, same, i != 3)
+		}
+	}
+}
+
+func TestInt64RoundTrip(t *testing.T) {
+	sig := Signature{0, 1, math.MaxInt32, math.MaxInt32 + 1, math.MaxUint32}
+	got := FromInt64s(sig.ToInt64s())
+	if len(got) != len(sig) {
+		t.Fatalf("got %d values, want %d", len(got), len(sig))
+	}
+	for i := range sig {
+		if got[i] != sig[i] {
+			t.Errorf("value %d = %d, want %d", i, got[i], sig[i])
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package fingerprint
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxInflatedStream caps the size of a single decompressed PDF stream
+const maxInflatedStream = 8 << 20
+
+// ExtractText returns the plain text of a document for near-duplicate comparison.
+// Plain text formats are returned as-is; PDFs get a best-effort extraction of
+// their text-showing operators. Other formats (images, archives, ...) yield "".
+func ExtractText(data []byte, mimeType string) string {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+
+	switch {
+	case strings.HasPrefix(mimeType, "text/"),
+		mimeType == "application/json",
+		mimeType == "application/xml",
+		mimeType == "application/x-tex":
+		if utf8.Valid(data) {
+			return string(data)
+		}
+		return ""
+	case mimeType == "application/pdf" || bytes.HasPrefix(data, []byte("%PDF-")):
+		return extractPDFText(data)
+	default:
+		return ""
+	}
+}
+
+// extractPDFText walks every content stream of a PDF (inflating FlateDecode
+// streams) and collects the string operands of Tj/TJ/'/" operators.
+func extractPDFText(data []byte) string {
+	var out strings.Builder
+	rest := data
+
+	for {
+		start := bytes.Index(rest, []byte("stream"))
+		if start < 0 {
+			break
+		}
+		body := rest[start+len("stream"):]
+		body = bytes.TrimLeft(body, "\r\n")
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		raw := body[:end]
+		rest = body[end+len("endstream"):]
+
+		content := raw
+		if r, err := zlib.NewReader(bytes.NewReader(raw)); err == nil {
+			inflated, err := io.ReadAll(io.LimitReader(r, maxInflatedStream))
+			r.Close()
+			if err == nil || len(inflated) > 0 {
+				content = inflated
+			}
+		}
+
+		if bytes.Contains(content, []byte("BT")) {
+			collectPDFStrings(content, &out)
+		}
+	}
+
+	return out.String()
+}
+
+// collectPDFStrings appends the literal strings found between BT/ET blocks
+func collectPDFStrings(content []byte, out *strings.Builder) {
+	inText := false
+	for i := 0; i < len(content); i++ {
+		switch {
+		case !inText && bytes.HasPrefix(content[i:], []byte("BT")):
+			inText = true
+			i++
+		case inText && bytes.HasPrefix(content[i:], []byte("ET")):
+			inText = false
+			out.WriteByte(' ')
+			i++
+		case inText && content[i] == '(':
+			s, n := readPDFLiteral(content[i:])
+			out.WriteString(s)
+			i += n - 1
+		case inText && content[i] == ']':
+			out.WriteByte(' ')
+		}
+	}
+}
+
+// readPDFLiteral decodes a PDF literal string starting at '(' and returns it
+// together with the number of bytes consumed
+func readPDFLiteral(b []byte) (string, int) {
+	var s strings.Builder
+	depth := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch c {
+		case '(':
+			depth++
+			if depth > 1 {
+				s.WriteByte(c)
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return s.String(), i + 1
+			}
+			s.WriteByte(c)
+		case '\\':
+			if i+1 < len(b) {
+				i++
+				switch b[i] {
+				case 'n', 'r', 't':
+					s.WriteByte(' ')
+				default:
+					s.WriteByte(b[i])
+				}
+			}
+		default:
+			s.WriteByte(c)
+		}
+	}
+	return s.String(), len(b)
+}
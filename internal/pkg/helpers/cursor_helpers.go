@@ -0,0 +1,118 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/yigit/unisphere/internal/app/models/dto"
+)
+
+// ErrInvalidCursor is returned when a pagination token cannot be decoded
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// Cursor is the decoded form of an opaque after/before pagination token.
+// It records the boundary row of a page as its sort column value plus id,
+// so the next page can be selected with a keyset condition instead of OFFSET.
+type Cursor struct {
+	Sort   string `json:"s"` // sort the token was produced for, e.g. "created_at:desc"
+	Value  string `json:"v"` // sort column value of the boundary row
+	ID     int64  `json:"i"` // id of the boundary row, the tie-breaker
+	Before bool   `json:"-"` // true when passed as `before`, i.e. paging backwards
+}
+
+// EncodeCursor builds an opaque token for the row with the given sort value and id
+func EncodeCursor(sort string, value interface{}, id int64) string {
+	data, _ := json.Marshal(Cursor{Sort: sort, Value: CursorValue(value), ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token produced by EncodeCursor
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// CursorValue formats a sort column value for storage in a cursor
+func CursorValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ParseCursor decodes the `after` or `before` token of a list request.
+// It returns nil when neither is given, in which case page-based pagination applies.
+func ParseCursor(after, before string) (*Cursor, error) {
+	if after != "" && before != "" {
+		return nil, fmt.Errorf("%w: after and before cannot be combined", ErrInvalidCursor)
+	}
+
+	token := after
+	if before != "" {
+		token = before
+	}
+	if token == "" {
+		return nil, nil
+	}
+
+	cursor, err := DecodeCursor(token)
+	if err != nil {
+		return nil, err
+	}
+	cursor.Before = before != ""
+	return cursor, nil
+}
+
+// NewCursorInfo creates the cursor block of a list response.
+// count is the number of items on the page and key returns the sort value and id of item i.
+// In cursor mode hasMore tells whether rows exist past the page in the paging direction;
+// in page mode the neighbours are derived from page, pageSize and total.
+func NewCursorInfo(sort string, count int, key func(i int) (interface{}, int64), cursor *Cursor, hasMore bool, page, pageSize int, total int64) *dto.CursorInfo {
+	info := &dto.CursorInfo{}
+	switch {
+	case cursor == nil:
+		info.HasPrev = page > 1
+		info.HasNext = int64(page)*int64(pageSize) < total
+	case cursor.Before:
+		info.HasPrev = hasMore
+		info.HasNext = true
+	default:
+		info.HasPrev = true
+		info.HasNext = hasMore
+	}
+
+	if count == 0 {
+		// Without a boundary row there is nothing to anchor a token to
+		info.HasPrev, info.HasNext = false, false
+		return info
+	}
+
+	if info.HasPrev {
+		value, id := key(0)
+		info.PrevCursor = EncodeCursor(sort, value, id)
+	}
+	if info.HasNext {
+		value, id := key(count - 1)
+		info.NextCursor = EncodeCursor(sort, value, id)
+	}
+	return info
+}
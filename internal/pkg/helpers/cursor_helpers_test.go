@@ -0,0 +1,151 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 12, 30, 0, 123456789, time.FixedZone("TRT", 3*60*60))
+
+	tests := []struct {
+		name      string
+		value     interface{}
+		wantValue string
+	}{
+		{"time in UTC with nanoseconds", createdAt, "2026-03-01T09:30:00.123456789Z"},
+		{"int", 42, "42"},
+		{"int64", int64(-7), "-7"},
+		{"text", "Ada, Lovelace", "Ada, Lovelace"},
+		{"other", 1.5, "1.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := DecodeCursor(EncodeCursor("created_at:desc", tt.value, 99))
+			if err != nil {
+				t.Fatalf("DecodeCursor: %v", err)
+			}
+			if cursor.Sort != "created_at:desc" || cursor.Value != tt.wantValue || cursor.ID != 99 || cursor.Before {
+				t.Errorf("cursor = %+v, want sort created_at:desc, value %q and id 99", cursor, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"s":"id:asc","v":"1","i":1}`))},
+		{"not JSON", encode("cursor")},
+		{"wrong types", encode(`{"s":"id:asc","v":1,"i":"1"}`)},
+		{"no sort", encode(`{"v":"1","i":1}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.token); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor(%q) error = %v, want ErrInvalidCursor", tt.token, err)
+			}
+		})
+	}
+}
+
+func TestParseCursor(t *testing.T) {
+	token := EncodeCursor("id:asc", int64(5), 5)
+
+	tests := []struct {
+		name       string
+		after      string
+		before     string
+		wantNil    bool
+		wantBefore bool
+		wantErr    bool
+	}{
+		{"neither", "", "", true, false, false},
+		{"after", token, "", false, false, false},
+		{"before", "", token, false, true, false},
+		{"both", token, token, false, false, true},
+		{"invalid", "!!!", "", false, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := ParseCursor(tt.after, tt.before)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCursor) {
+					t.Errorf("ParseCursor() error = %v, want ErrInvalidCursor", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCursor() error = %v", err)
+			}
+			if (cursor == nil) != tt.wantNil {
+				t.Fatalf("ParseCursor() = %+v, want nil %v", cursor, tt.wantNil)
+			}
+			if cursor != nil && cursor.Before != tt.wantBefore {
+				t.Errorf("Before = %v, want %v", cursor.Before, tt.wantBefore)
+			}
+		})
+	}
+}
+
+func TestNewCursorInfo(t *testing.T) {
+	ids := []int64{10, 11, 12}
+	key := func(i int) (interface{}, int64) { return ids[i], ids[i] }
+
+	tests := []struct {
+		name     string
+		count    int
+		cursor   *Cursor
+		hasMore  bool
+		page     int
+		total    int64
+		wantPrev int64 // 0 for no previous page
+		wantNext int64 // 0 for no next page
+	}{
+		{"first page", 3, nil, false, 1, 9, 0, 12},
+		{"middle page", 3, nil, false, 2, 9, 10, 12},
+		{"last page", 3, nil, false, 3, 9, 10, 0},
+		{"after with more", 3, &Cursor{}, true, 0, 0, 10, 12},
+		{"after at the end", 3, &Cursor{}, false, 0, 0, 10, 0},
+		{"before with more", 3, &Cursor{Before: true}, true, 0, 0, 10, 12},
+		{"before at the start", 3, &Cursor{Before: true}, false, 0, 0, 0, 12},
+		{"empty page", 0, &Cursor{}, true, 0, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := NewCursorInfo("id:asc", tt.count, key, tt.cursor, tt.hasMore, tt.page, 3, tt.total)
+			if info.HasPrev != (tt.wantPrev != 0) || info.HasNext != (tt.wantNext != 0) {
+				t.Fatalf("HasPrev, HasNext = %v, %v; want %v, %v", info.HasPrev, info.HasNext, tt.wantPrev != 0, tt.wantNext != 0)
+			}
+			for _, c := range []struct {
+				token string
+				want  int64
+			}{{info.PrevCursor, tt.wantPrev}, {info.NextCursor, tt.wantNext}} {
+				if c.want == 0 {
+					if c.token != "" {
+						t.Errorf("got token %q, want none", c.token)
+					}
+					continue
+				}
+				cursor, err := DecodeCursor(c.token)
+				if err != nil {
+					t.Fatalf("DecodeCursor: %v", err)
+				}
+				if cursor.Sort != "id:asc" || cursor.ID != c.want {
+					t.Errorf("token points at %+v, want row %d", cursor, c.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Package imaging normalizes uploaded images. Images are decoded, rotated upright
+// according to their EXIF orientation, downsized and re-encoded as JPEG, which drops
+// EXIF metadata such as GPS coordinates. Thumbnails are rendered from the same image.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Registered for DecodeConfig
+	"image/jpeg"
+	_ "image/png" // Registered for Decode
+	"io"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Registered for Decode
+)
+
+const (
+	// ContentType is the MIME type of every processed image and thumbnail
+	ContentType = "image/jpeg"
+	// Extension is the file extension of every processed image and thumbnail
+	Extension = ".jpg"
+
+	// MaxDimension bounds the longest side of the normalized image
+	MaxDimension = 2048
+	// maxPixels rejects images that would need too much memory to decode
+	maxPixels = 40_000_000
+
+	jpegQuality = 85
+)
+
+// ThumbnailSizes are the bounding boxes, in pixels, of the thumbnails rendered for every image
+var ThumbnailSizes = []int{64, 256, 1024}
+
+// ErrTooManyPixels is returned for images whose dimensions exceed the decoding limit
+var ErrTooManyPixels = errors.New("image dimensions are too large")
+
+// Result is a normalized image with its thumbnails, all JPEG encoded
+type Result struct {
+	Image      []byte
+	Width      int
+	Height     int
+	Thumbnails map[int][]byte // Keyed by ThumbnailSizes entry
+}
+
+// Supported reports whether images of the given MIME type are normalized. GIFs are left
+// alone so animations survive; they carry no EXIF data.
+func Supported(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Process normalizes an image and renders its thumbnails
+func Process(r io.Reader) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image header: %w", err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	img := applyOrientation(flatten(src), jpegOrientation(data))
+	img = fit(img, MaxDimension)
+
+	result := &Result{
+		Width:      img.Bounds().Dx(),
+		Height:     img.Bounds().Dy(),
+		Thumbnails: make(map[int][]byte, len(ThumbnailSizes)),
+	}
+	if result.Image, err = encode(img); err != nil {
+		return nil, err
+	}
+	for _, size := range ThumbnailSizes {
+		if result.Thumbnails[size], err = encode(fit(img, size)); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// flatten draws the image onto an opaque white canvas, since JPEG has no alpha channel
+func flatten(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Over)
+	return dst
+}
+
+// fit scales the image down so its longest side is at most size; smaller images are kept
+func fit(src *image.RGBA, size int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w <= size && h <= size {
+		return src
+	}
+
+	if w >= h {
+		h = max(1, h*size/w)
+		w = size
+	} else {
+		w = max(1, w*size/h)
+		h = size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,219 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// newImage returns an opaque image of the given size
+func newImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("jpeg.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withExif inserts an APP1 segment holding an EXIF orientation right after the SOI marker
+func withExif(jpegData []byte, order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1) // One IFD entry
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, jpegData[:2]...)
+	out = append(out, app1...)
+	return append(out, jpegData[2:]...)
+}
+
+func TestSupported(t *testing.T) {
+	tests := map[string]bool{
+		"image/jpeg":      true,
+		"image/png":       true,
+		"image/webp":      true,
+		"image/gif":       false,
+		"application/pdf": false,
+	}
+	for contentType, want := range tests {
+		if got := Supported(contentType); got != want {
+			t.Errorf("Supported(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	plain := encodeJPEG(t, newImage(4, 4))
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"no EXIF", plain, 1},
+		{"little-endian", withExif(plain, binary.LittleEndian, 6), 6},
+		{"big-endian", withExif(plain, binary.BigEndian, 8), 8},
+		{"out of range", withExif(plain, binary.LittleEndian, 9), 1},
+		{"truncated", withExif(plain, binary.BigEndian, 6)[:20], 1},
+		{"not a JPEG", encodePNG(t, newImage(4, 4)), 1},
+		{"empty", nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// A 3x2 image whose pixels are numbered, in the red channel, row by row:
+	// 0 1 2
+	// 3 4 5
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := 0; i < 6; i++ {
+		src.Set(i%3, i/3, color.RGBA{R: uint8(i), A: 0xFF})
+	}
+
+	tests := []struct {
+		orientation int
+		want        [][]uint8 // Rows of the upright image
+	}{
+		{1, [][]uint8{{0, 1, 2}, {3, 4, 5}}},
+		{2, [][]uint8{{2, 1, 0}, {5, 4, 3}}},
+		{3, [][]uint8{{5, 4, 3}, {2, 1, 0}}},
+		{4, [][]uint8{{3, 4, 5}, {0, 1, 2}}},
+		{5, [][]uint8{{0, 3}, {1, 4}, {2, 5}}},
+		{6, [][]uint8{{3, 0}, {4, 1}, {5, 2}}},
+		{7, [][]uint8{{5, 2}, {4, 1}, {3, 0}}},
+		{8, [][]uint8{{2, 5}, {1, 4}, {0, 3}}},
+	}
+
+	for _, tt := range tests {
+		dst := applyOrientation(src, tt.orientation)
+		if dst.Bounds().Dx() != len(tt.want[0]) || dst.Bounds().Dy() != len(tt.want) {
+			t.Errorf("orientation %d: size %v, want %dx%d", tt.orientation, dst.Bounds().Size(), len(tt.want[0]), len(tt.want))
+			continue
+		}
+		for y, row := range tt.want {
+			for x, want := range row {
+				if got := dst.RGBAAt(x, y).R; got != want {
+					t.Errorf("orientation %d: pixel (%d, %d) = %d, want %d", tt.orientation, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	// Half transparent, so flattening onto white shows
+	transparent := image.NewNRGBA(image.Rect(0, 0, 3000, 1500))
+	for x := 0; x < 1500; x++ {
+		for y := 0; y < 1500; y++ {
+			transparent.Set(x, y, color.NRGBA{A: 0xFF})
+		}
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantWidth  int
+		wantHeight int
+	}{
+		{"large PNG is downsized", encodePNG(t, transparent), MaxDimension, MaxDimension / 2},
+		{"small JPEG keeps its size", encodeJPEG(t, newImage(40, 20)), 40, 20},
+		{"rotated JPEG is turned upright", withExif(encodeJPEG(t, newImage(40, 20)), binary.BigEndian, 6), 20, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Process(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if result.Width != tt.wantWidth || result.Height != tt.wantHeight {
+				t.Errorf("size = %dx%d, want %dx%d", result.Width, result.Height, tt.wantWidth, tt.wantHeight)
+			}
+			if bytes.Contains(result.Image, []byte("Exif")) {
+				t.Error("the processed image still carries EXIF data")
+			}
+
+			img, err := jpeg.Decode(bytes.NewReader(result.Image))
+			if err != nil {
+				t.Fatalf("decoding the processed image: %v", err)
+			}
+			if img.Bounds().Dx() != result.Width || img.Bounds().Dy() != result.Height {
+				t.Errorf("encoded size = %v, want %dx%d", img.Bounds().Size(), result.Width, result.Height)
+			}
+			// Transparent pixels are flattened onto white
+			if r, _, _, _ := img.At(img.Bounds().Dx()-1, 0).RGBA(); r>>8 < 0xF0 {
+				t.Errorf("right edge red = %d, want white", r>>8)
+			}
+
+			for _, size := range ThumbnailSizes {
+				thumbnail, err := jpeg.DecodeConfig(bytes.NewReader(result.Thumbnails[size]))
+				if err != nil {
+					t.Fatalf("decoding the %d thumbnail: %v", size, err)
+				}
+				if want := min(size, max(tt.wantWidth, tt.wantHeight)); max(thumbnail.Width, thumbnail.Height) != want {
+					t.Errorf("thumbnail %d is %dx%d, want its longest side %d", size, thumbnail.Width, thumbnail.Height, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	// A PNG header claiming 10000x10000 pixels, with a valid checksum
+	huge := encodePNG(t, newImage(1, 1))
+	binary.BigEndian.PutUint32(huge[16:], 10000)
+	binary.BigEndian.PutUint32(huge[20:], 10000)
+	binary.BigEndian.PutUint32(huge[29:], crc32.ChecksumIEEE(huge[12:29]))
+
+	if _, err := Process(bytes.NewReader(huge)); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("Process() of a huge image error = %v, want ErrTooManyPixels", err)
+	}
+	if _, err := Process(bytes.NewReader([]byte("not an image"))); err == nil {
+		t.Error("Process() of text succeeded, want an error")
+	}
+}
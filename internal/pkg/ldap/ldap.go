@@ -0,0 +1,203 @@
+// Package ldap connects to directory servers for authentication. It wraps go-ldap with what
+// directory logins need: simple binds, paged subtree searches and StartTLS over ldap:// or
+// ldaps://, with entries keyed by case-insensitive attribute names.
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+// Search scopes
+const (
+	ScopeBaseObject   = goldap.ScopeBaseObject
+	ScopeSingleLevel  = goldap.ScopeSingleLevel
+	ScopeWholeSubtree = goldap.ScopeWholeSubtree
+)
+
+// ErrInvalidCredentials is returned by Bind when the DN or password is wrong
+var ErrInvalidCredentials = errors.New("invalid LDAP credentials")
+
+// IsSizeLimitExceeded reports whether a search stopped at its size limit
+func IsSizeLimitExceeded(err error) bool {
+	return goldap.IsErrorWithCode(err, goldap.LDAPResultSizeLimitExceeded)
+}
+
+// EscapeFilter escapes a value for use in a search filter, so user input cannot change the
+// filter's meaning (RFC 4515 3)
+func EscapeFilter(value string) string {
+	return goldap.EscapeFilter(value)
+}
+
+// DialConfig configures a connection
+type DialConfig struct {
+	StartTLS  bool          // Upgrade ldap:// connections to TLS before binding
+	TLSConfig *tls.Config   // For ldaps:// and StartTLS; the server name defaults to the URL host
+	Timeout   time.Duration // Per operation; defaults to 10 seconds
+}
+
+// Conn is a connection to a directory server
+type Conn struct {
+	conn    *goldap.Conn
+	timeout time.Duration
+}
+
+// Dial connects to a directory server at an ldap:// or ldaps:// URL
+func Dial(ctx context.Context, rawURL string, config DialConfig) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP URL: %w", err)
+	}
+	host := u.Host
+	switch u.Scheme {
+	case "ldap":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+	case "ldaps":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		}
+	default:
+		return nil, fmt.Errorf("invalid LDAP URL scheme %q: must be ldap or ldaps", u.Scheme)
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	tlsConfig := config.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	// Dialed here rather than by go-ldap so the context bounds connecting
+	dialer := &net.Dialer{Timeout: timeout}
+	var netConn net.Conn
+	if u.Scheme == "ldaps" {
+		netConn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", host)
+	} else {
+		netConn, err = dialer.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to LDAP server: %w", err)
+	}
+
+	conn := goldap.NewConn(netConn, u.Scheme == "ldaps")
+	conn.SetTimeout(timeout)
+	conn.Start()
+	if u.Scheme == "ldap" && config.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS failed: %w", err)
+		}
+	}
+	return &Conn{conn: conn, timeout: timeout}, nil
+}
+
+// Close unbinds and closes the connection
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Bind authenticates the connection with a simple bind. An empty password is refused rather
+// than sent: servers treat it as an unauthenticated bind, which succeeds for any DN.
+func (c *Conn) Bind(dn, password string) error {
+	if password == "" {
+		return ErrInvalidCredentials
+	}
+	if err := c.conn.Bind(dn, password); err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+	return nil
+}
+
+// SearchRequest describes a search
+type SearchRequest struct {
+	BaseDN     string
+	Scope      int
+	Filter     string
+	Attributes []string // Empty returns all user attributes
+	SizeLimit  int      // 0 for the server's limit
+	PageSize   int      // Fetches results in pages of this size; 0 fetches them at once
+}
+
+// Search runs a search and returns the entries found. Entries received before an error, such
+// as the size limit being exceeded, are returned with it.
+func (c *Conn) Search(req SearchRequest) ([]*Entry, error) {
+	filter := strings.TrimSpace(req.Filter)
+	if filter != "" && filter[0] != '(' {
+		filter = "(" + filter + ")"
+	}
+	request := goldap.NewSearchRequest(
+		req.BaseDN, req.Scope, goldap.NeverDerefAliases, req.SizeLimit, int(c.timeout/time.Second), false,
+		filter, req.Attributes, nil,
+	)
+
+	var result *goldap.SearchResult
+	var err error
+	if req.PageSize > 0 {
+		// Servers that ignore the non-critical control send everything in one go
+		result, err = c.conn.SearchWithPaging(request, uint32(req.PageSize))
+	} else {
+		result, err = c.conn.Search(request)
+	}
+
+	var entries []*Entry
+	if result != nil {
+		for _, found := range result.Entries {
+			entry := &Entry{DN: found.DN, Attributes: make(map[string][]string, len(found.Attributes))}
+			for _, attribute := range found.Attributes {
+				key := strings.ToLower(attribute.Name)
+				entry.Attributes[key] = append(entry.Attributes[key], attribute.Values...)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, err
+}
+
+// Entry is a directory entry returned by a search
+type Entry struct {
+	DN         string
+	Attributes map[string][]string // Keyed by lower-case attribute name
+}
+
+// NewEntry creates an entry with attributes given as name and values
+func NewEntry(dn string, attributes map[string][]string) *Entry {
+	entry := &Entry{DN: dn, Attributes: make(map[string][]string, len(attributes))}
+	for name, values := range attributes {
+		key := strings.ToLower(name)
+		entry.Attributes[key] = append(entry.Attributes[key], values...)
+	}
+	return entry
+}
+
+// Values returns the values of an attribute
+func (e *Entry) Values(name string) []string {
+	return e.Attributes[strings.ToLower(name)]
+}
+
+// Value returns the first value of an attribute, or "" if it has none
+func (e *Entry) Value(name string) string {
+	values := e.Values(name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
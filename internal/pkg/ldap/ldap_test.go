@@ -0,0 +1,156 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yigit/unisphere/internal/pkg/ldap/ldaptest"
+)
+
+const (
+	testBaseDN   = "ou=people,dc=example,dc=edu"
+	testPassword = "password"
+)
+
+// startServer serves a directory with a service account and users, and returns its URL
+func startServer(t *testing.T, users int) string {
+	t.Helper()
+	entries := []*ldaptest.Entry{
+		ldaptest.NewEntry("dc=example,dc=edu", map[string][]string{"dc": {"example"}}),
+		ldaptest.NewEntry("cn=service,dc=example,dc=edu", map[string][]string{"cn": {"service"}, "userPassword": {"secret"}}),
+		ldaptest.NewEntry(testBaseDN, map[string][]string{"ou": {"people"}}),
+	}
+	for i := 0; i < users; i++ {
+		uid := fmt.Sprintf("user%d", i)
+		entries = append(entries, ldaptest.NewEntry("uid="+uid+","+testBaseDN, map[string][]string{
+			"objectClass":  {"inetOrgPerson"},
+			"uid":          {uid},
+			"mail":         {uid + "@example.edu"},
+			"givenName":    {"User"},
+			"userPassword": {testPassword},
+		}))
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go ldaptest.NewServer(entries).Serve(listener)
+	return "ldap://" + listener.Addr().String()
+}
+
+func dial(t *testing.T, url string) *Conn {
+	t.Helper()
+	conn, err := Dial(context.Background(), url, DialConfig{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestDialInvalidURL(t *testing.T) {
+	for _, url := range []string{"http://localhost", "://", "ldap://127.0.0.1:1"} {
+		if _, err := Dial(context.Background(), url, DialConfig{Timeout: time.Second}); err == nil {
+			t.Errorf("Dial(%q) succeeded, want an error", url)
+		}
+	}
+}
+
+func TestBind(t *testing.T) {
+	url := startServer(t, 1)
+
+	tests := []struct {
+		name     string
+		dn       string
+		password string
+		wantErr  error
+	}{
+		{"service account", "cn=service,dc=example,dc=edu", "secret", nil},
+		{"user", "uid=user0," + testBaseDN, testPassword, nil},
+		{"DN is case-insensitive", "UID=user0, OU=people,dc=example,dc=edu", testPassword, nil},
+		{"wrong password", "uid=user0," + testBaseDN, "wrong", ErrInvalidCredentials},
+		{"unknown DN", "uid=nobody," + testBaseDN, testPassword, ErrInvalidCredentials},
+		{"empty password", "uid=user0," + testBaseDN, "", ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dial(t, url).Bind(tt.dn, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Bind() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	url := startServer(t, 5)
+	conn := dial(t, url)
+	if err := conn.Bind("cn=service,dc=example,dc=edu", "secret"); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		req       SearchRequest
+		wantUIDs  []string
+		wantLimit bool
+	}{
+		{"one user", SearchRequest{BaseDN: testBaseDN, Scope: ScopeWholeSubtree, Filter: "(mail=user1@example.edu)"}, []string{"user1"}, false},
+		{"filter without parentheses", SearchRequest{BaseDN: testBaseDN, Scope: ScopeWholeSubtree, Filter: "uid=user2"}, []string{"user2"}, false},
+		{"escaped input", SearchRequest{BaseDN: testBaseDN, Scope: ScopeWholeSubtree, Filter: "(mail=" + EscapeFilter("*") + ")"}, nil, false},
+		{"all users", SearchRequest{BaseDN: testBaseDN, Scope: ScopeSingleLevel, Filter: "(uid=*)"}, []string{"user0", "user1", "user2", "user3", "user4"}, false},
+		{"paged", SearchRequest{BaseDN: testBaseDN, Scope: ScopeWholeSubtree, Filter: "(uid=*)", PageSize: 2}, []string{"user0", "user1", "user2", "user3", "user4"}, false},
+		{"size limit", SearchRequest{BaseDN: testBaseDN, Scope: ScopeWholeSubtree, Filter: "(uid=*)", SizeLimit: 2}, []string{"user0", "user1"}, true},
+		{"base scope", SearchRequest{BaseDN: "uid=user3," + testBaseDN, Scope: ScopeBaseObject, Filter: "(objectClass=*)"}, []string{"user3"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := conn.Search(tt.req)
+			if tt.wantLimit {
+				if !IsSizeLimitExceeded(err) {
+					t.Fatalf("Search() error = %v, want the size limit exceeded", err)
+				}
+			} else if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			var uids []string
+			for _, entry := range entries {
+				uids = append(uids, entry.Value("UID"))
+			}
+			sort.Strings(uids)
+			if strings.Join(uids, ",") != strings.Join(tt.wantUIDs, ",") {
+				t.Errorf("Search() found %v, want %v", uids, tt.wantUIDs)
+			}
+		})
+	}
+
+	// Requested attributes only, and never the password
+	entries, err := conn.Search(SearchRequest{BaseDN: testBaseDN, Scope: ScopeWholeSubtree, Filter: "(uid=user0)", Attributes: []string{"mail", "userPassword"}})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Search() = %v, %v; want one entry", entries, err)
+	}
+	if entries[0].Value("mail") != "user0@example.edu" || entries[0].Value("givenName") != "" || entries[0].Value("userPassword") != "" {
+		t.Errorf("entry attributes = %v, want only mail", entries[0].Attributes)
+	}
+
+	if _, err := conn.Search(SearchRequest{BaseDN: "ou=missing,dc=example,dc=edu", Scope: ScopeWholeSubtree, Filter: "(uid=*)"}); err == nil {
+		t.Error("Search() of a missing base succeeded, want an error")
+	}
+}
+
+func TestSearchNeedsBind(t *testing.T) {
+	url := startServer(t, 1)
+	if _, err := dial(t, url).Search(SearchRequest{BaseDN: testBaseDN, Scope: ScopeWholeSubtree, Filter: "(uid=*)"}); err == nil {
+		t.Error("Search() without a bind succeeded, want an error")
+	}
+}
@@ -0,0 +1,91 @@
+package ldaptest
+
+import (
+	"fmt"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+// matchFilter evaluates a search filter, as go-ldap encodes it, against an entry. Values compare
+// case-insensitively; extensible matches are not supported.
+func matchFilter(entry *Entry, filter *ber.Packet) (bool, error) {
+	if filter.ClassType != ber.ClassContext {
+		return false, fmt.Errorf("invalid filter")
+	}
+
+	switch filter.Tag {
+	case goldap.FilterAnd, goldap.FilterOr:
+		for _, child := range filter.Children {
+			matched, err := matchFilter(entry, child)
+			if err != nil {
+				return false, err
+			}
+			if matched == (filter.Tag == goldap.FilterOr) {
+				return matched, nil
+			}
+		}
+		return filter.Tag == goldap.FilterAnd, nil
+	case goldap.FilterNot:
+		if len(filter.Children) != 1 {
+			return false, fmt.Errorf("invalid not filter")
+		}
+		matched, err := matchFilter(entry, filter.Children[0])
+		return !matched, err
+	case goldap.FilterPresent:
+		return len(entry.Values(filter.Data.String())) > 0, nil
+	case goldap.FilterSubstrings:
+		if len(filter.Children) != 2 {
+			return false, fmt.Errorf("invalid substrings filter")
+		}
+		for _, value := range entry.Values(filter.Children[0].Data.String()) {
+			if matchSubstrings(strings.ToLower(value), filter.Children[1].Children) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case goldap.FilterEqualityMatch, goldap.FilterGreaterOrEqual, goldap.FilterLessOrEqual, goldap.FilterApproxMatch:
+		if len(filter.Children) != 2 {
+			return false, fmt.Errorf("invalid filter item")
+		}
+		assertion := strings.ToLower(filter.Children[1].Data.String())
+		for _, value := range entry.Values(filter.Children[0].Data.String()) {
+			value = strings.ToLower(value)
+			switch {
+			case filter.Tag == goldap.FilterGreaterOrEqual && value >= assertion,
+				filter.Tag == goldap.FilterLessOrEqual && value <= assertion,
+				(filter.Tag == goldap.FilterEqualityMatch || filter.Tag == goldap.FilterApproxMatch) && value == assertion:
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported filter type %d", filter.Tag)
+	}
+}
+
+// matchSubstrings matches a lower-cased value against the parts of a substrings filter
+func matchSubstrings(value string, parts []*ber.Packet) bool {
+	for _, part := range parts {
+		substring := strings.ToLower(part.Data.String())
+		switch part.Tag {
+		case goldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(value, substring) {
+				return false
+			}
+			value = value[len(substring):]
+		case goldap.FilterSubstringsAny:
+			i := strings.Index(value, substring)
+			if i < 0 {
+				return false
+			}
+			value = value[i+len(substring):]
+		case goldap.FilterSubstringsFinal:
+			if !strings.HasSuffix(value, substring) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package ldaptest
+
+import (
+	"testing"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestMatchFilter(t *testing.T) {
+	entry := NewEntry("uid=ada,ou=people,dc=example,dc=edu", map[string][]string{
+		"objectClass":    {"top", "person"},
+		"uid":            {"ada"},
+		"mail":           {"Ada.Lovelace@example.edu"},
+		"cn":             {"Ada Lovelace"},
+		"employeeNumber": {"1815"},
+	})
+
+	tests := []struct {
+		name   string
+		filter string
+		want   bool
+	}{
+		{"equality", "(uid=ada)", true},
+		{"equality is case-insensitive", "(MAIL=ada.lovelace@EXAMPLE.edu)", true},
+		{"equality mismatch", "(uid=grace)", false},
+		{"present", "(mail=*)", true},
+		{"absent", "(telephoneNumber=*)", false},
+		{"initial substring", "(cn=Ada*)", true},
+		{"final substring", "(mail=*@example.edu)", true},
+		{"any substring", "(cn=*love*)", true},
+		{"all substrings", "(cn=A*a L*e)", true},
+		{"substring mismatch", "(cn=*Byron*)", false},
+		{"greater or equal", "(employeeNumber>=1800)", true},
+		{"less or equal", "(employeeNumber<=1800)", false},
+		{"approximate", "(uid~=ada)", true},
+		{"and", "(&(objectClass=person)(uid=ada))", true},
+		{"and with mismatch", "(&(objectClass=person)(uid=grace))", false},
+		{"or", "(|(uid=grace)(uid=ada))", true},
+		{"not", "(!(uid=grace))", true},
+		{"nested", "(&(objectClass=person)(|(mail=*@example.edu)(uid=grace))(!(cn=Grace*)))", true},
+		{"escaped value", `(cn=Ada\20Lovelace)`, true},
+		{"escaped wildcard is literal", "(uid=" + goldap.EscapeFilter("*") + ")", false},
+		{"escaped injection is literal", "(uid=" + goldap.EscapeFilter("*)(uid=*") + ")", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := goldap.CompileFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("CompileFilter(%q): %v", tt.filter, err)
+			}
+			got, err := matchFilter(entry, filter)
+			if err != nil {
+				t.Fatalf("matchFilter(%q): %v", tt.filter, err)
+			}
+			if got != tt.want {
+				t.Errorf("filter %q matched = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+
+	filter, err := goldap.CompileFilter("(uid:dn:=ada)")
+	if err != nil {
+		t.Fatalf("CompileFilter: %v", err)
+	}
+	if _, err := matchFilter(entry, filter); err == nil {
+		t.Error("matchFilter of an extensible match succeeded, want an error")
+	}
+}
@@ -0,0 +1,304 @@
+// Package ldaptest provides an in-memory directory server for tests and for trying directory
+// logins locally. It answers simple binds against the userPassword of its entries and
+// searches, paged or not, with any filter go-ldap can send. Searches need a bind first. It
+// speaks plain LDAP only, without StartTLS.
+package ldaptest
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+// passwordAttribute holds the password of an entry. It is never returned by searches.
+const passwordAttribute = "userpassword"
+
+// Entry is an entry of the directory
+type Entry struct {
+	DN         string
+	Attributes map[string][]string // Keyed by lower-case attribute name
+}
+
+// NewEntry creates an entry with attributes given as name and values
+func NewEntry(dn string, attributes map[string][]string) *Entry {
+	entry := &Entry{DN: dn, Attributes: make(map[string][]string, len(attributes))}
+	for name, values := range attributes {
+		key := strings.ToLower(name)
+		entry.Attributes[key] = append(entry.Attributes[key], values...)
+	}
+	return entry
+}
+
+// Values returns the values of an attribute
+func (e *Entry) Values(name string) []string {
+	return e.Attributes[strings.ToLower(name)]
+}
+
+// Server is an in-memory directory server
+type Server struct {
+	mu      sync.RWMutex
+	entries []*Entry
+}
+
+// NewServer creates a server holding entries
+func NewServer(entries []*Entry) *Server {
+	return &Server{entries: entries}
+}
+
+// SetEntries replaces the entries of the server
+func (s *Server) SetEntries(entries []*Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = entries
+}
+
+// Serve accepts connections on l until it is closed
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn handles the requests of one connection
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	boundDN := ""
+
+	for {
+		request, err := ber.ReadPacket(conn)
+		if err != nil || len(request.Children) < 2 {
+			return
+		}
+		id, ok := request.Children[0].Value.(int64)
+		if !ok {
+			return
+		}
+		op := request.Children[1]
+		var controls []*ber.Packet
+		if len(request.Children) > 2 {
+			controls = request.Children[2].Children
+		}
+
+		var responses []*ber.Packet
+		var doneControls *ber.Packet // Controls of the last response
+		switch op.Tag {
+		case goldap.ApplicationBindRequest:
+			var code uint16
+			code, boundDN = s.bind(op)
+			responses = append(responses, newResult(goldap.ApplicationBindResponse, code, ""))
+		case goldap.ApplicationSearchRequest:
+			if boundDN == "" {
+				responses = append(responses, newResult(goldap.ApplicationSearchResultDone, goldap.LDAPResultInsufficientAccessRights, "bind required"))
+				break
+			}
+			responses, doneControls = s.search(op, controls)
+		case goldap.ApplicationUnbindRequest:
+			return
+		case goldap.ApplicationExtendedRequest:
+			responses = append(responses, newResult(goldap.ApplicationExtendedResponse, goldap.LDAPResultProtocolError, "extended operations are not supported"))
+		default:
+			return
+		}
+
+		for i, response := range responses {
+			envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+			envelope.AppendChild(response)
+			if i == len(responses)-1 && doneControls != nil {
+				envelope.AppendChild(doneControls)
+			}
+			if _, err := conn.Write(envelope.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// bind checks a simple bind and returns its result code and the DN bound to
+func (s *Server) bind(op *ber.Packet) (uint16, string) {
+	if len(op.Children) < 3 || op.Children[2].ClassType != ber.ClassContext || op.Children[2].Tag != 0 {
+		return goldap.LDAPResultUnwillingToPerform, ""
+	}
+	dn, password := op.Children[1].Data.String(), op.Children[2].Data.Bytes()
+	if len(password) == 0 {
+		return goldap.LDAPResultUnwillingToPerform, "" // Unauthenticated binds are not allowed
+	}
+
+	s.mu.RLock()
+	entry := s.find(normalizeDN(dn))
+	s.mu.RUnlock()
+	if entry == nil {
+		return goldap.LDAPResultInvalidCredentials, ""
+	}
+	for _, stored := range entry.Values(passwordAttribute) {
+		if subtle.ConstantTimeCompare([]byte(stored), password) == 1 {
+			return goldap.LDAPResultSuccess, entry.DN
+		}
+	}
+	return goldap.LDAPResultInvalidCredentials, ""
+}
+
+// search answers a search with the matching entries and a SearchResultDone, and returns the
+// controls of the SearchResultDone. With a paged results control, the cookie is the offset of
+// the next page.
+func (s *Server) search(op *ber.Packet, controls []*ber.Packet) ([]*ber.Packet, *ber.Packet) {
+	if len(op.Children) < 8 {
+		return []*ber.Packet{newResult(goldap.ApplicationSearchResultDone, goldap.LDAPResultProtocolError, "malformed search request")}, nil
+	}
+	base := normalizeDN(op.Children[0].Data.String())
+	scope, _ := op.Children[1].Value.(int64)
+	sizeLimit, _ := op.Children[3].Value.(int64)
+	filter := op.Children[6]
+	var attributes []string
+	for _, attribute := range op.Children[7].Children {
+		attributes = append(attributes, strings.ToLower(attribute.Data.String()))
+	}
+	var paging *goldap.ControlPaging
+	for _, packet := range controls {
+		if control, err := goldap.DecodeControl(packet); err == nil {
+			if control, ok := control.(*goldap.ControlPaging); ok {
+				paging = control
+			}
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if base != "" && s.find(base) == nil {
+		return []*ber.Packet{newResult(goldap.ApplicationSearchResultDone, goldap.LDAPResultNoSuchObject, "")}, nil
+	}
+
+	var matches []*Entry
+	for _, entry := range s.entries {
+		if !inScope(normalizeDN(entry.DN), base, int(scope)) {
+			continue
+		}
+		matched, err := matchFilter(entry, filter)
+		if err != nil {
+			return []*ber.Packet{newResult(goldap.ApplicationSearchResultDone, goldap.LDAPResultProtocolError, err.Error())}, nil
+		}
+		if matched {
+			matches = append(matches, entry)
+		}
+	}
+
+	var responses []*ber.Packet
+	for i, entry := range matches {
+		if sizeLimit > 0 && int64(i) >= sizeLimit {
+			return append(responses, newResult(goldap.ApplicationSearchResultDone, goldap.LDAPResultSizeLimitExceeded, "")), nil
+		}
+		responses = append(responses, encodeEntry(entry, attributes))
+	}
+
+	if paging != nil && paging.PagingSize > 0 {
+		offset, _ := strconv.Atoi(string(paging.Cookie))
+		end := offset + int(paging.PagingSize)
+		if offset > len(responses) {
+			offset = len(responses)
+		}
+		next := ""
+		if end < len(responses) {
+			next = strconv.Itoa(end)
+		} else {
+			end = len(responses)
+		}
+		responses = responses[offset:end]
+
+		reply := goldap.NewControlPaging(0)
+		reply.SetCookie([]byte(next))
+		replyControls := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+		replyControls.AppendChild(reply.Encode())
+		return append(responses, newResult(goldap.ApplicationSearchResultDone, goldap.LDAPResultSuccess, "")), replyControls
+	}
+	return append(responses, newResult(goldap.ApplicationSearchResultDone, goldap.LDAPResultSuccess, "")), nil
+}
+
+// find returns the entry with a normalized DN; the caller holds the lock
+func (s *Server) find(normalizedDN string) *Entry {
+	for _, entry := range s.entries {
+		if normalizeDN(entry.DN) == normalizedDN {
+			return entry
+		}
+	}
+	return nil
+}
+
+// newResult builds an LDAPResult response
+func newResult(op ber.Tag, code uint16, message string) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, op, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "Diagnostic Message"))
+	return result
+}
+
+// encodeEntry builds a SearchResultEntry with the requested attributes, or all of them
+func encodeEntry(entry *Entry, attributes []string) *ber.Packet {
+	all := len(attributes) == 0
+	requested := make(map[string]bool, len(attributes))
+	for _, attribute := range attributes {
+		if attribute == "*" {
+			all = true
+		}
+		requested[attribute] = true
+	}
+
+	list := ber.NewSequence("Attributes")
+	for name, values := range entry.Attributes {
+		if name == passwordAttribute || (!all && !requested[name]) {
+			continue
+		}
+		attribute := ber.NewSequence("Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		list.AppendChild(attribute)
+	}
+
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "DN"))
+	result.AppendChild(list)
+	return result
+}
+
+// normalizeDN lower-cases a DN and drops the spaces around its separators
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, part := range parts {
+		parts[i] = strings.ToLower(strings.TrimSpace(part))
+	}
+	return strings.Join(parts, ",")
+}
+
+// inScope reports whether a normalized DN is within a search scope of base
+func inScope(dn, base string, scope int) bool {
+	switch scope {
+	case goldap.ScopeBaseObject:
+		return dn == base
+	case goldap.ScopeSingleLevel:
+		parent := ""
+		if i := strings.IndexByte(dn, ','); i >= 0 {
+			parent = dn[i+1:]
+		}
+		return dn != base && parent == base
+	default:
+		return base == "" || dn == base || strings.HasSuffix(dn, ","+base)
+	}
+}
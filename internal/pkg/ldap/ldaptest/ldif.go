@@ -0,0 +1,70 @@
+package ldaptest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseLDIF reads entries in LDIF (RFC 2849): "dn:" lines starting records separated by blank
+// lines, "name: value" or base64 "name:: value" attribute lines and continuation lines
+// starting with a space. Change records are not supported.
+func ParseLDIF(r io.Reader) ([]*Entry, error) {
+	scanner := bufio.NewScanner(r)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, " ") && len(lines) > 0 && lines[len(lines)-1] != "" {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	var current *Entry
+	for number, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			current = nil
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		colon := strings.IndexByte(line, ':')
+		if colon < 1 {
+			return nil, fmt.Errorf("LDIF line %d: expected \"name: value\"", number+1)
+		}
+		name, value := line[:colon], line[colon+1:]
+		if strings.HasPrefix(value, ":") {
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("LDIF line %d: invalid base64 value: %w", number+1, err)
+			}
+			value = string(decoded)
+		} else {
+			value = strings.TrimSpace(value)
+		}
+
+		switch {
+		case current == nil && strings.EqualFold(name, "version"):
+		case current == nil && strings.EqualFold(name, "dn"):
+			current = NewEntry(value, nil)
+			entries = append(entries, current)
+		case current == nil:
+			return nil, fmt.Errorf("LDIF line %d: record does not start with dn", number+1)
+		case strings.EqualFold(name, "changetype"):
+			return nil, fmt.Errorf("LDIF line %d: change records are not supported", number+1)
+		default:
+			key := strings.ToLower(name)
+			current.Attributes[key] = append(current.Attributes[key], value)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,124 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// md renders CommonMark with GFM tables and LaTeX math.
+// Raw HTML in the source is omitted; the sanitizer is a second line of defense.
+var md = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Table,
+		extension.Strikethrough,
+		&mathExtension{},
+	),
+	goldmark.WithParserOptions(
+		parser.WithAutoHeadingID(),
+	),
+)
+
+// PolicyVersion identifies the renderer options, the sanitizer policy and what StripHTML
+// removes. Bump it whenever one of them changes: content stored with another version is
+// stripped and rendered again when it is read.
+const PolicyVersion = 2
+
+// policy is the XSS sanitizer applied to every rendered document
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^math (inline|display)$`)).OnElements("span", "div")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	p.AllowAttrs("style").Matching(regexp.MustCompile(`^text-align:\s*(left|center|right);?$`)).OnElements("th", "td")
+	return p
+}()
+
+// Render converts Markdown to sanitized HTML that is safe to embed in a page
+func Render(source string) (string, error) {
+	if source == "" {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
+
+// StripHTML removes raw HTML from Markdown source, so clients rendering the source themselves
+// are as safe as those using the rendered HTML. HTML inside code spans and blocks is text and
+// is kept, as are autolinks.
+func StripHTML(source string) string {
+	// Removing a tag can join the text around it into a new one, as in "<<b>script>"
+	for {
+		stripped := stripHTMLOnce([]byte(source))
+		if stripped == source {
+			return stripped
+		}
+		source = stripped
+	}
+}
+
+// stripHTMLOnce removes the HTML blocks and inline HTML the parser finds in source
+func stripHTMLOnce(source []byte) string {
+	type span struct{ start, stop int }
+	var spans []span
+
+	doc := md.Parser().Parse(text.NewReader(source))
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.HTMLBlock:
+			for i := 0; i < node.Lines().Len(); i++ {
+				line := node.Lines().At(i)
+				spans = append(spans, span{line.Start, line.Stop})
+			}
+			if node.HasClosure() {
+				spans = append(spans, span{node.ClosureLine.Start, node.ClosureLine.Stop})
+			}
+		case *ast.RawHTML:
+			for i := 0; i < node.Segments.Len(); i++ {
+				segment := node.Segments.At(i)
+				spans = append(spans, span{segment.Start, segment.Stop})
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if len(spans) == 0 {
+		return string(source)
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var out bytes.Buffer
+	last := 0
+	for _, sp := range spans {
+		if sp.start > last {
+			out.Write(source[last:sp.start])
+		}
+		if sp.stop > last {
+			last = sp.stop
+		}
+	}
+	out.Write(source[last:])
+	return out.String()
+}
+
+// RenderOrEmpty renders Markdown and returns "" if rendering fails
+func RenderOrEmpty(source string) string {
+	out, err := Render(source)
+	if err != nil {
+		return ""
+	}
+	return out
+}
@@ -0,0 +1,157 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// xssPayloads are sources that must not produce active content, whether the rendered HTML
+// or the stripped source is shown
+var xssPayloads = []struct {
+	name   string
+	source string
+}{
+	{"script block", "<script>alert(1)</script>"},
+	{"inline script", "text <script>alert(1)</script> text"},
+	{"img onerror", `<img src=x onerror="alert(1)">`},
+	{"inline img onerror", `see <img src=x onerror=alert(1)> here`},
+	{"svg onload", `<svg onload=alert(1)>`},
+	{"iframe", `<iframe src="https://evil.example"></iframe>`},
+	{"nested tags", "<<script>script>alert(1)<</script>/script>"},
+	{"split tag", "<scr<b>ipt>alert(1)</scr</b>ipt>"},
+	{"html comment", "<!-- <script>alert(1)</script> -->"},
+	{"style block", "<style>body { background: url(javascript:alert(1)) }</style>"},
+	{"a javascript href", `<a href="javascript:alert(1)">x</a>`},
+	{"markdown javascript link", "[click](javascript:alert(1))"},
+	{"markdown javascript image", "![x](javascript:alert(1))"},
+	{"markdown data link", "[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)"},
+	{"in a table", "| a |\n|---|\n| <img src=x onerror=alert(1)> |"},
+	{"in a list", "- item <script>alert(1)</script>\n- <iframe src=x>"},
+	{"in a blockquote", "> <script>\n> alert(1)\n> </script>"},
+	{"heading id", "# <img src=x onerror=alert(1)>"},
+}
+
+// dangerous are fragments that must not appear in output
+var dangerous = []string{"<script", "<img src=x", "<iframe", "<svg", "<style", `href="javascript:`, `src="javascript:`, `href="data:`}
+
+// eventHandler matches an event handler attribute inside a tag
+var eventHandler = regexp.MustCompile(`(?i)<[^>]*\son\w+=`)
+
+func TestRenderXSS(t *testing.T) {
+	for _, tt := range xssPayloads {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Render(tt.source)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			for _, fragment := range dangerous {
+				if strings.Contains(strings.ToLower(out), fragment) {
+					t.Errorf("Render(%q) = %q, contains %q", tt.source, out, fragment)
+				}
+			}
+			if eventHandler.MatchString(out) {
+				t.Errorf("Render(%q) = %q, contains an event handler", tt.source, out)
+			}
+		})
+	}
+}
+
+func TestRenderMathEscapesHTML(t *testing.T) {
+	// Math is kept verbatim in the source, so it must render as text
+	source := "$<img src=x onerror=alert(1)>$ and $$<script>alert(1)</script>$$"
+	if got := StripHTML(source); got != source {
+		t.Errorf("StripHTML(%q) = %q, want math left alone", source, got)
+	}
+	out, err := Render(source)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	for _, fragment := range []string{"<img", "<script"} {
+		if strings.Contains(out, fragment) {
+			t.Errorf("Render(%q) = %q, contains %q", source, out, fragment)
+		}
+	}
+	if !strings.Contains(out, "&lt;script&gt;") {
+		t.Errorf("Render(%q) = %q, want the math escaped", source, out)
+	}
+}
+
+func TestStripHTMLXSS(t *testing.T) {
+	for _, tt := range xssPayloads {
+		t.Run(tt.name, func(t *testing.T) {
+			out := StripHTML(tt.source)
+			// Stripped source holds no tags of its own; what is left renders to inert text
+			for _, fragment := range []string{"<script", "<img", "<iframe", "<svg", "<style", "onerror=", "onload="} {
+				if strings.Contains(strings.ToLower(out), fragment) {
+					t.Errorf("StripHTML(%q) = %q, contains %q", tt.source, out, fragment)
+				}
+			}
+			if StripHTML(out) != out {
+				t.Errorf("StripHTML is not idempotent for %q", tt.source)
+			}
+		})
+	}
+}
+
+func TestStripHTMLKeepsMarkdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"plain markdown", "# Title\n\n**bold** and _italic_\n", "# Title\n\n**bold** and _italic_\n"},
+		{"comparison", "a < b and c > d", "a < b and c > d"},
+		{"code span", "use `<script>` tags", "use `<script>` tags"},
+		{"fenced code", "```html\n<script>alert(1)</script>\n```\n", "```html\n<script>alert(1)</script>\n```\n"},
+		{"indented code", "    <iframe src=x>\n", "    <iframe src=x>\n"},
+		{"autolink", "see <https://example.edu>", "see <https://example.edu>"},
+		{"math", "$a<b$", "$a<b$"},
+		{"inline tags", "a <b>bold</b> word", "a bold word"},
+		{"html block", "before\n\n<div>\nhidden\n</div>\n\nafter\n", "before\n\n\nafter\n"},
+		{"script block", "<script>\nalert(1)\n</script>\nafter\n", "after\n"},
+		{"escaped tag", `\<script>`, `\<script>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripHTML(tt.source); got != tt.want {
+				t.Errorf("StripHTML(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string // Fragments the output must contain
+	}{
+		{"empty", "", nil},
+		{"heading", "# Title", []string{`<h1 id="title">Title</h1>`}},
+		{"link", "[site](https://example.edu)", []string{`<a href="https://example.edu" rel="nofollow">site</a>`}},
+		{"table alignment", "| a |\n|:-:|\n| b |", []string{`<th style="text-align:center">a</th>`}},
+		{"code language", "```go\nx := 1\n```", []string{`<code class="language-go">`}},
+		{"inline math", "$x^2$", []string{`class="math inline"`}},
+		{"display math", "$$\nx^2\n$$", []string{`class="math display"`}},
+		{"strikethrough", "~~old~~", []string{"<del>old</del>"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Render(tt.source)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if tt.source == "" && out != "" {
+				t.Errorf("Render(\"\") = %q, want \"\"", out)
+			}
+			for _, fragment := range tt.want {
+				if !strings.Contains(out, fragment) {
+					t.Errorf("Render(%q) = %q, missing %q", tt.source, out, fragment)
+				}
+			}
+		})
+	}
+}
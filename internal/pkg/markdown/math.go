@@ -0,0 +1,198 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// LaTeX math support: $inline$ and $$display$$ spans plus fenced $$ blocks.
+// Math is not rendered server-side; it is emitted as escaped TeX wrapped in
+// MathJax/KaTeX delimiters so the client can typeset it.
+
+// KindMathInline is the node kind of inline math
+var KindMathInline = ast.NewNodeKind("MathInline")
+
+// KindMathBlock is the node kind of block math
+var KindMathBlock = ast.NewNodeKind("MathBlock")
+
+// MathInline is an inline $...$ (or $$...$$) math span
+type MathInline struct {
+	ast.BaseInline
+	Display bool
+	Value   []byte
+}
+
+// Kind implements ast.Node
+func (n *MathInline) Kind() ast.NodeKind { return KindMathInline }
+
+// Dump implements ast.Node
+func (n *MathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Value": string(n.Value)}, nil)
+}
+
+// MathBlock is a $$ fenced block of display math
+type MathBlock struct {
+	ast.BaseBlock
+}
+
+// Kind implements ast.Node
+func (n *MathBlock) Kind() ast.NodeKind { return KindMathBlock }
+
+// IsRaw implements ast.Node; the block content is not parsed as Markdown
+func (n *MathBlock) IsRaw() bool { return true }
+
+// Dump implements ast.Node
+func (n *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte { return []byte{'$'} }
+
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if len(line) < 2 || line[0] != '$' {
+		return nil
+	}
+
+	display := line[1] == '$'
+	open := 1
+	if display {
+		open = 2
+	}
+
+	end := -1
+	for i := open; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case line[i] == '$':
+			if !display {
+				end = i
+			} else if i+1 < len(line) && line[i+1] == '$' {
+				end = i
+			}
+		}
+		if end >= 0 {
+			break
+		}
+	}
+	if end <= open {
+		return nil
+	}
+
+	value := line[open:end]
+	// "$5 and $6" is not math: inline content may not start or end with a space
+	if !display && (value[0] == ' ' || value[len(value)-1] == ' ') {
+		return nil
+	}
+
+	block.Advance(end + open)
+	return &MathInline{Display: display, Value: append([]byte(nil), value...)}
+}
+
+type mathBlockParser struct{}
+
+func (p *mathBlockParser) Trigger() []byte { return []byte{'$'} }
+
+func (p *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+
+	rest := bytes.TrimSpace(line[pos+2:])
+	node := &MathBlock{}
+
+	if len(rest) == 0 {
+		reader.AdvanceToEOL()
+		return node, parser.NoChildren
+	}
+
+	// Single-line block: $$ x^2 $$
+	if bytes.HasSuffix(rest, []byte("$$")) && len(rest) > 2 {
+		start := segment.Start + pos + 2
+		stop := segment.Start + bytes.LastIndex(line, []byte("$$"))
+		node.Lines().Append(text.NewSegment(start, stop))
+		reader.AdvanceToEOL()
+		return node, parser.Close
+	}
+
+	return nil, parser.NoChildren
+}
+
+func (p *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	if bytes.Equal(bytes.TrimSpace(line), []byte("$$")) {
+		reader.AdvanceToEOL()
+		return parser.Close
+	}
+	node.Lines().Append(segment)
+	reader.AdvanceToEOL()
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *mathBlockParser) CanInterruptParagraph() bool { return true }
+
+func (p *mathBlockParser) CanAcceptIndentedLine() bool { return false }
+
+type mathRenderer struct{}
+
+func (r *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMathInline, r.renderInline)
+	reg.Register(KindMathBlock, r.renderBlock)
+}
+
+func (r *mathRenderer) renderInline(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	m := n.(*MathInline)
+	if m.Display {
+		_, _ = w.WriteString(`<span class="math display">\[`)
+		_, _ = w.Write(util.EscapeHTML(m.Value))
+		_, _ = w.WriteString(`\]</span>`)
+	} else {
+		_, _ = w.WriteString(`<span class="math inline">\(`)
+		_, _ = w.Write(util.EscapeHTML(m.Value))
+		_, _ = w.WriteString(`\)</span>`)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *mathRenderer) renderBlock(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	_, _ = w.WriteString(`<div class="math display">\[`)
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		_, _ = w.Write(util.EscapeHTML(seg.Value(source)))
+	}
+	_, _ = w.WriteString("\\]</div>\n")
+	return ast.WalkSkipChildren, nil
+}
+
+// mathExtension registers the math parsers and renderer with goldmark
+type mathExtension struct{}
+
+func (e *mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&mathBlockParser{}, 700)),
+		parser.WithInlineParsers(util.Prioritized(&mathInlineParser{}, 500)),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&mathRenderer{}, 500)),
+	)
+}
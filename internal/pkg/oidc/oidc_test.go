@@ -0,0 +1,223 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "unisphere"
+	testNonce    = "nonce-123"
+)
+
+// testProvider is an identity provider serving discovery and a JWKS with an ES256 key "ec",
+// an Ed25519 key "ed" and an encryption key "enc" that must not verify signatures
+type testProvider struct {
+	server     *httptest.Server
+	ecKey      *ecdsa.PrivateKey
+	edKey      ed25519.PrivateKey
+	encKey     *ecdsa.PrivateKey
+	jwksServed int
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	tp := &testProvider{}
+	var err error
+	if tp.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatalf("generating EC key: %v", err)
+	}
+	if _, tp.edKey, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		t.Fatalf("generating Ed25519 key: %v", err)
+	}
+	if tp.encKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatalf("generating EC key: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(metadata{
+			Issuer:                tp.server.URL,
+			AuthorizationEndpoint: tp.server.URL + "/authorize",
+			TokenEndpoint:         tp.server.URL + "/token",
+			JWKSURI:               tp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		tp.jwksServed++
+		ecJWK := func(kid, use string, key *ecdsa.PrivateKey) jsonWebKey {
+			return jsonWebKey{
+				KeyType: "EC", KeyID: kid, Use: use, Curve: "P-256",
+				X: base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+				Y: base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+			}
+		}
+		json.NewEncoder(w).Encode(map[string][]jsonWebKey{"keys": {
+			ecJWK("ec", "sig", tp.ecKey),
+			{KeyType: "OKP", KeyID: "ed", Curve: "Ed25519", X: base64.RawURLEncoding.EncodeToString(tp.edKey.Public().(ed25519.PublicKey))},
+			ecJWK("enc", "enc", tp.encKey),
+		}})
+	})
+	tp.server = httptest.NewServer(mux)
+	t.Cleanup(tp.server.Close)
+	return tp
+}
+
+// claims returns the claims of a valid ID token
+func (tp *testProvider) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   tp.server.URL,
+		"aud":   testClientID,
+		"sub":   "user-1",
+		"nonce": testNonce,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+}
+
+// sign signs claims with a method and key, setting the kid header unless it is empty
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return raw
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	tp := newTestProvider(t)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating EC key: %v", err)
+	}
+
+	// with returns valid claims changed by set, deleting claims set to nil
+	with := func(set map[string]interface{}) jwt.MapClaims {
+		claims := tp.claims()
+		for name, value := range set {
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+		}
+		return claims
+	}
+	es256 := func(claims jwt.MapClaims) string { return sign(t, jwt.SigningMethodES256, "ec", tp.ecKey, claims) }
+	hour := time.Hour
+
+	tests := []struct {
+		name    string
+		token   string
+		nonce   string
+		wantErr bool
+	}{
+		{"valid ES256", es256(tp.claims()), testNonce, false},
+		{"valid EdDSA", sign(t, jwt.SigningMethodEdDSA, "ed", tp.edKey, tp.claims()), testNonce, false},
+		{"audience list", es256(with(map[string]interface{}{"aud": []string{testClientID}})), testNonce, false},
+		{"several audiences with azp", es256(with(map[string]interface{}{"aud": []string{testClientID, "other"}, "azp": testClientID})), testNonce, false},
+		{"expired within leeway", es256(with(map[string]interface{}{"exp": time.Now().Add(-30 * time.Second).Unix()})), testNonce, false},
+
+		{"wrong issuer", es256(with(map[string]interface{}{"iss": "https://evil.example"})), testNonce, true},
+		{"missing issuer", es256(with(map[string]interface{}{"iss": nil})), testNonce, true},
+		{"wrong audience", es256(with(map[string]interface{}{"aud": "other"})), testNonce, true},
+		{"several audiences without azp", es256(with(map[string]interface{}{"aud": []string{testClientID, "other"}})), testNonce, true},
+		{"several audiences for another client", es256(with(map[string]interface{}{"aud": []string{testClientID, "other"}, "azp": "other"})), testNonce, true},
+		{"expired", es256(with(map[string]interface{}{"exp": time.Now().Add(-hour).Unix()})), testNonce, true},
+		{"no expiry", es256(with(map[string]interface{}{"exp": nil})), testNonce, true},
+		{"issued in the future", es256(with(map[string]interface{}{"iat": time.Now().Add(hour).Unix()})), testNonce, true},
+		{"nonce mismatch", es256(tp.claims()), "other-nonce", true},
+		{"missing nonce", es256(with(map[string]interface{}{"nonce": nil})), testNonce, true},
+		{"missing subject", es256(with(map[string]interface{}{"sub": nil})), testNonce, true},
+		{"signed by another key", sign(t, jwt.SigningMethodES256, "ec", otherKey, tp.claims()), testNonce, true},
+		{"signed by the encryption key", sign(t, jwt.SigningMethodES256, "enc", tp.encKey, tp.claims()), testNonce, true},
+		{"unknown kid", sign(t, jwt.SigningMethodES256, "unknown", tp.ecKey, tp.claims()), testNonce, true},
+		{"no kid with several keys", sign(t, jwt.SigningMethodES256, "", tp.ecKey, tp.claims()), testNonce, true},
+		{"HS256", sign(t, jwt.SigningMethodHS256, "ec", []byte("secret"), tp.claims()), testNonce, true},
+		{"alg none", sign(t, jwt.SigningMethodNone, "ec", jwt.UnsafeAllowNoneSignatureType, tp.claims()), testNonce, true},
+		{"malformed", "not.a.token", testNonce, true},
+	}
+
+	provider := NewProvider(Config{IssuerURL: tp.server.URL, ClientID: testClientID})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := provider.VerifyIDToken(context.Background(), tt.token, tt.nonce)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Errorf("VerifyIDToken() error = %v, want %v", err, ErrInvalidIDToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyIDToken() error = %v", err)
+			}
+			if sub := claims.String("sub"); sub != "user-1" {
+				t.Errorf("sub = %q, want %q", sub, "user-1")
+			}
+		})
+	}
+
+	// Unknown kids do not make every token fetch the JWKS again
+	if tp.jwksServed != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", tp.jwksServed)
+	}
+}
+
+func TestVerifyIDTokenIssuerMismatch(t *testing.T) {
+	tp := newTestProvider(t)
+	provider := NewProvider(Config{IssuerURL: tp.server.URL + "/", ClientID: testClientID})
+
+	_, err := provider.VerifyIDToken(context.Background(), sign(t, jwt.SigningMethodES256, "ec", tp.ecKey, tp.claims()), testNonce)
+	if err == nil || errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("VerifyIDToken() error = %v, want a discovery error", err)
+	}
+}
+
+func TestClaimsString(t *testing.T) {
+	claims := Claims{
+		"email":      " ada@example.edu ",
+		"number":     float64(20190001),
+		"verified":   true,
+		"attributes": map[string]interface{}{"studentNumber": "S123"},
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"email", "ada@example.edu"},
+		{"number", "20190001"},
+		{"verified", ""},
+		{"attributes.studentNumber", "S123"},
+		{"attributes.missing", ""},
+		{"email.nested", ""},
+		{"missing", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := claims.String(tt.name); got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Package scanner checks uploaded files for malware. ClamdScanner streams files to a
+// ClamAV daemon over TCP; any other engine can be plugged in through the Scanner interface.
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Result is the verdict of a scan
+type Result struct {
+	Infected  bool
+	Signature string // Name of the malware found, e.g. "Eicar-Test-Signature"
+}
+
+// Scanner scans file content for malware
+type Scanner interface {
+	// Scan reads r to the end and reports whether it contains malware.
+	// An error means no verdict was reached and the scan should be retried.
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+}
+
+// chunkSize is the size of the INSTREAM chunks; clamd's StreamMaxLength limits the total, not the chunk
+const chunkSize = 64 << 10
+
+// ClamdScanner scans files with a ClamAV daemon reachable over TCP, using the INSTREAM command
+type ClamdScanner struct {
+	address string
+	timeout time.Duration
+}
+
+// NewClamdScanner creates a scanner for the clamd listening at address ("host:port").
+// timeout bounds a whole scan, including the upload of the content.
+func NewClamdScanner(address string, timeout time.Duration) *ClamdScanner {
+	return &ClamdScanner{
+		address: address,
+		timeout: timeout,
+	}
+}
+
+// Scan streams r to clamd and parses its verdict
+func (s *ClamdScanner) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	// Commands prefixed with "z" are terminated by a NUL byte, and so is the reply
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("failed to send command to clamd: %w", err)
+	}
+
+	// Each chunk is prefixed with its length; a zero length ends the stream
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				// clamd closes the connection once the stream exceeds StreamMaxLength; its reply says so
+				if reply, replyErr := readReply(conn); replyErr == nil {
+					return parseReply(reply)
+				}
+				return nil, fmt.Errorf("failed to stream content to clamd: %w", err)
+			}
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read content: %w", readErr)
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("failed to end stream: %w", err)
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return nil, err
+	}
+	return parseReply(reply)
+}
+
+// readReply reads one NUL-terminated clamd reply
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(errors.Is(err, io.EOF) && reply != "") {
+		return "", fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return strings.TrimRight(reply, "\x00\n"), nil
+}
+
+// parseReply turns "stream: OK" or "stream: <signature> FOUND" into a result
+func parseReply(reply string) (*Result, error) {
+	status := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case status == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(status, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(status, " FOUND")}, nil
+	default:
+		// e.g. "INSTREAM size limit exceeded. ERROR"
+		return nil, fmt.Errorf("clamd error: %s", status)
+	}
+}
@@ -0,0 +1,193 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd answers INSTREAM scans the way clamd does: it reports the EICAR test file as
+// found and stops reading once a stream is longer than maxLength. It returns the address
+// it listens at and a channel receiving the content of every finished stream.
+func fakeClamd(t *testing.T, maxLength int) (string, <-chan []byte) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	streams := make(chan []byte, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if command, err := r.ReadString(0); err != nil || command != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+
+				var content []byte
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					chunk := make([]byte, size)
+					if _, err := io.ReadFull(r, chunk); err != nil {
+						return
+					}
+					content = append(content, chunk...)
+					if len(content) > maxLength {
+						conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+						return
+					}
+				}
+				streams <- content
+
+				if bytes.Contains(content, []byte(eicar)) {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), streams
+}
+
+func TestScan(t *testing.T) {
+	address, streams := fakeClamd(t, 1<<20)
+	scanner := NewClamdScanner(address, 5*time.Second)
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    Result
+	}{
+		{"empty", nil, Result{}},
+		{"clean", []byte("lecture notes"), Result{}},
+		{"infected", []byte(eicar), Result{Infected: true, Signature: "Eicar-Test-Signature"}},
+		{"infected past the first chunk", append(bytes.Repeat([]byte("a"), chunkSize+10), eicar...), Result{Infected: true, Signature: "Eicar-Test-Signature"}},
+		{"several chunks", bytes.Repeat([]byte("0123456789"), chunkSize/4), Result{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scanner.Scan(context.Background(), bytes.NewReader(tt.content))
+			if err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("Scan() = %+v, want %+v", *got, tt.want)
+			}
+			if streamed := <-streams; !bytes.Equal(streamed, tt.content) {
+				t.Errorf("clamd received %d bytes, want the %d bytes of content", len(streamed), len(tt.content))
+			}
+		})
+	}
+}
+
+func TestScanSizeLimit(t *testing.T) {
+	address, _ := fakeClamd(t, chunkSize)
+	scanner := NewClamdScanner(address, 5*time.Second)
+
+	// Large enough that writing blocks once clamd stops reading
+	_, err := scanner.Scan(context.Background(), bytes.NewReader(make([]byte, 64<<20)))
+	if err == nil || !strings.Contains(err.Error(), "size limit exceeded") {
+		t.Errorf("Scan() error = %v, want the size limit exceeded", err)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	t.Run("connection refused", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listening: %v", err)
+		}
+		address := listener.Addr().String()
+		listener.Close()
+
+		if _, err := NewClamdScanner(address, time.Second).Scan(context.Background(), strings.NewReader("x")); err == nil {
+			t.Error("Scan() succeeded, want an error")
+		}
+	})
+
+	t.Run("no reply", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listening: %v", err)
+		}
+		defer listener.Close()
+		go func() {
+			conn, err := listener.Accept()
+			if err == nil {
+				io.Copy(io.Discard, conn)
+				conn.Close()
+			}
+		}()
+
+		start := time.Now()
+		if _, err := NewClamdScanner(listener.Addr().String(), 200*time.Millisecond).Scan(context.Background(), strings.NewReader("x")); err == nil {
+			t.Error("Scan() succeeded, want a timeout")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Scan() took %v, want it bounded by the timeout", elapsed)
+		}
+	})
+
+	t.Run("unreadable content", func(t *testing.T) {
+		address, _ := fakeClamd(t, 1<<20)
+		readErr := errors.New("disk failure")
+		_, err := NewClamdScanner(address, time.Second).Scan(context.Background(), io.MultiReader(strings.NewReader("x"), &failingReader{readErr}))
+		if !errors.Is(err, readErr) {
+			t.Errorf("Scan() error = %v, want %v", err, readErr)
+		}
+	})
+}
+
+// failingReader fails every read with err
+type failingReader struct{ err error }
+
+func (r *failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		reply   string
+		want    Result
+		wantErr bool
+	}{
+		{"stream: OK", Result{}, false},
+		{"stream: Win.Test.EICAR_HDB-1 FOUND", Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}, false},
+		{"INSTREAM size limit exceeded. ERROR", Result{}, true},
+		{"", Result{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseReply(tt.reply)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseReply(%q) error = %v, wantErr %v", tt.reply, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && *got != tt.want {
+			t.Errorf("parseReply(%q) = %+v, want %+v", tt.reply, *got, tt.want)
+		}
+	}
+}
@@ -52,6 +52,9 @@ type Message struct {
 	// Link to file if this is a file message
 	FileURL string `json:"fileUrl,omitempty"`
 
+	// Thumbnail URLs keyed by size if the file is an image
+	FileThumbnails map[string]string `json:"fileThumbnails,omitempty"`
+
 	// File ID if this is a file message
 	FileID int64 `json:"fileId,omitempty"`
 
@@ -0,0 +1,33 @@
+-- Add content fingerprints for duplicate detection of uploaded files
+
+-- SHA-256 of the file content for exact duplicate detection
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'files' AND column_name = 'content_hash') THEN
+        ALTER TABLE files ADD COLUMN content_hash VARCHAR(64);
+    END IF;
+END$$;
+
+CREATE INDEX IF NOT EXISTS idx_files_content_hash ON files(content_hash);
+
+-- MinHash signature of the extracted text for near-duplicate detection
+CREATE TABLE IF NOT EXISTS file_fingerprints (
+    file_id BIGINT PRIMARY KEY,
+    minhash BIGINT[] NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_file_fingerprints_file
+        FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE
+);
+
+-- LSH band hashes of the signature; files sharing a band are near-duplicate candidates
+CREATE TABLE IF NOT EXISTS file_fingerprint_bands (
+    file_id BIGINT NOT NULL,
+    band_index SMALLINT NOT NULL,
+    band_hash BIGINT NOT NULL,
+    PRIMARY KEY (file_id, band_index),
+    CONSTRAINT fk_file_fingerprint_bands_file
+        FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_fingerprint_bands_lookup ON file_fingerprint_bands(band_index, band_hash);
@@ -0,0 +1,16 @@
+-- Markdown is the official content format for class notes and past exams.
+-- content keeps the Markdown source; content_html caches the sanitized HTML rendered on write.
+-- Rows written before this migration have NULL content_html and are rendered on read.
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'class_notes' AND column_name = 'content_html') THEN
+        ALTER TABLE class_notes ADD COLUMN content_html TEXT;
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'past_exams' AND column_name = 'content_html') THEN
+        ALTER TABLE past_exams ADD COLUMN content_html TEXT;
+    END IF;
+END$$;
@@ -0,0 +1,10 @@
+-- Track the thumbnails rendered for uploaded images
+
+-- Sizes (in pixels) of the thumbnails stored next to the file, e.g. {64,256,1024}
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'files' AND column_name = 'thumbnail_sizes') THEN
+        ALTER TABLE files ADD COLUMN thumbnail_sizes INTEGER[] NOT NULL DEFAULT '{}';
+    END IF;
+END$$;
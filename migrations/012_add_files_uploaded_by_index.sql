@@ -0,0 +1,2 @@
+-- Storage quotas sum file sizes per uploader
+CREATE INDEX IF NOT EXISTS idx_files_uploaded_by ON files(uploaded_by);
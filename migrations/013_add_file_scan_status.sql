@@ -0,0 +1,20 @@
+-- Malware scanning of uploaded files
+
+-- Existing files count as clean; files uploaded from now on start out pending until scanned
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'files' AND column_name = 'scan_status') THEN
+        ALTER TABLE files ADD COLUMN scan_status VARCHAR(20) NOT NULL DEFAULT 'CLEAN'
+            CHECK (scan_status IN ('PENDING', 'CLEAN', 'INFECTED'));
+        ALTER TABLE files ALTER COLUMN scan_status SET DEFAULT 'PENDING';
+        ALTER TABLE files ADD COLUMN scan_signature TEXT NULL;
+        ALTER TABLE files ADD COLUMN scanned_at TIMESTAMP WITH TIME ZONE NULL;
+    END IF;
+END$$;
+
+COMMENT ON COLUMN files.scan_status IS 'PENDING until scanned for malware, then CLEAN or INFECTED; only CLEAN files can be downloaded';
+COMMENT ON COLUMN files.scan_signature IS 'Name of the malware found in an INFECTED file';
+
+-- The scan worker picks up pending files and admins list infected ones
+CREATE INDEX IF NOT EXISTS idx_files_scan_status ON files(scan_status) WHERE scan_status <> 'CLEAN';
@@ -0,0 +1,51 @@
+-- Content-addressed storage: identical uploads are stored once and shared by their file rows
+
+CREATE TABLE IF NOT EXISTS blobs (
+    hash CHAR(64) PRIMARY KEY,                   -- SHA-256 of the content, hex encoded
+    file_path TEXT NOT NULL UNIQUE,              -- Stored path, "/blobs/<first two hash characters>/<hash><ext>"
+    file_size BIGINT NOT NULL,
+    thumbnail_sizes INTEGER[] NOT NULL DEFAULT '{}',
+    ref_count INTEGER NOT NULL DEFAULT 0 CHECK (ref_count >= 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON COLUMN blobs.ref_count IS 'Number of files rows referring to the blob, kept by the files_blob_ref_count trigger';
+COMMENT ON COLUMN blobs.last_used_at IS 'When an upload last resolved to the blob; unreferenced blobs are only deleted some time after it';
+
+-- Rows stored before this migration keep their own file and have no blob
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'files' AND column_name = 'blob_hash') THEN
+        ALTER TABLE files ADD COLUMN blob_hash CHAR(64) NULL REFERENCES blobs(hash);
+    END IF;
+END$$;
+
+CREATE INDEX IF NOT EXISTS idx_files_blob_hash ON files(blob_hash);
+
+-- The collector looks for blobs nothing refers to any more
+CREATE INDEX IF NOT EXISTS idx_blobs_unused ON blobs(last_used_at) WHERE ref_count = 0;
+
+-- Count the file rows referring to each blob as they are added, removed or moved
+CREATE OR REPLACE FUNCTION update_blob_ref_count()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.blob_hash IS NOT DISTINCT FROM NEW.blob_hash THEN
+        RETURN NULL;
+    END IF;
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.blob_hash IS NOT NULL THEN
+        UPDATE blobs SET ref_count = ref_count - 1 WHERE hash = OLD.blob_hash;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.blob_hash IS NOT NULL THEN
+        UPDATE blobs SET ref_count = ref_count + 1 WHERE hash = NEW.blob_hash;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS files_blob_ref_count ON files;
+CREATE TRIGGER files_blob_ref_count
+    AFTER INSERT OR DELETE OR UPDATE OF blob_hash ON files
+    FOR EACH ROW
+    EXECUTE FUNCTION update_blob_ref_count();
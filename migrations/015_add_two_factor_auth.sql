@@ -0,0 +1,32 @@
+-- TOTP two-factor authentication: one authenticator per user, plus single-use recovery codes
+
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,                         -- Encrypted base32 secret
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,       -- False while enrollment awaits its first code
+    last_used_step BIGINT NOT NULL DEFAULT 0,     -- Time step of the last accepted code, so codes cannot be replayed
+    failed_attempts INTEGER NOT NULL DEFAULT 0,   -- Wrong codes since the last password check
+    enabled_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+DROP TRIGGER IF EXISTS update_user_totp_updated_at ON user_totp;
+CREATE TRIGGER update_user_totp_updated_at
+    BEFORE UPDATE ON user_totp
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL,                  -- SHA-256 of the normalized code, hex encoded
+    used_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, code_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user_id ON user_recovery_codes(user_id);
+
+COMMENT ON TABLE user_totp IS 'TOTP authenticators; a row that is not enabled is an enrollment in progress';
+COMMENT ON TABLE user_recovery_codes IS 'Single-use codes that replace a TOTP code when the authenticator is lost';
@@ -0,0 +1,30 @@
+-- Refresh token families: every login starts a family (a device session), and each refresh
+-- rotates the family's token. Presenting a token that was already rotated revokes the family.
+
+CREATE TABLE IF NOT EXISTS token_families (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_label VARCHAR(100) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',   -- Address of the last login or refresh
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL, -- Expiry of the family's current token
+    revoked_at TIMESTAMP WITH TIME ZONE NULL,
+    revoked_reason VARCHAR(50) NULL               -- SIGNED_OUT, REUSE_DETECTED
+);
+
+CREATE INDEX IF NOT EXISTS idx_token_families_user_id ON token_families(user_id) WHERE revoked_at IS NULL;
+
+-- Tokens issued before this migration have no family; one is started when they are refreshed
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'refresh_tokens' AND column_name = 'family_id') THEN
+        ALTER TABLE refresh_tokens ADD COLUMN family_id BIGINT NULL REFERENCES token_families(id) ON DELETE CASCADE;
+    END IF;
+END$$;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+COMMENT ON TABLE token_families IS 'Refresh token families, one per login, listed to users as their sessions';
@@ -0,0 +1,23 @@
+-- Access token revocation: single tokens are denylisted by their jti until they expire, and all
+-- tokens of a user issued before users.tokens_invalid_before are rejected
+
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,  -- Expiry of the token; the row can go after it
+    revoked_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_access_tokens_expires_at ON revoked_access_tokens(expires_at);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'users' AND column_name = 'tokens_invalid_before') THEN
+        ALTER TABLE users ADD COLUMN tokens_invalid_before TIMESTAMP WITH TIME ZONE NULL;
+    END IF;
+END$$;
+
+CREATE INDEX IF NOT EXISTS idx_users_tokens_invalid_before ON users(tokens_invalid_before) WHERE tokens_invalid_before IS NOT NULL;
+
+COMMENT ON TABLE revoked_access_tokens IS 'Denylist of access tokens revoked before their expiry, by jti';
@@ -0,0 +1,14 @@
+-- Key pairs access tokens are signed with when JWT_ALGORITHM is RS256 or EdDSA. A new key is
+-- created every rotation interval; older keys keep verifying tokens for a grace period.
+
+CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+    kid VARCHAR(64) PRIMARY KEY,             -- RFC 7638 thumbprint of the public key
+    algorithm VARCHAR(10) NOT NULL,          -- RS256, EdDSA
+    private_key TEXT NOT NULL,               -- PKCS #8 PEM, encrypted with a key derived from the JWT secret
+    public_key TEXT NOT NULL,                -- PKIX PEM
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_jwt_signing_keys_created_at ON jwt_signing_keys(created_at);
+
+COMMENT ON TABLE jwt_signing_keys IS 'Rotating key pairs for signing access tokens, published at /.well-known/jwks.json';
@@ -0,0 +1,18 @@
+-- Brute-force protection: failed logins and email-sending requests are counted per account and
+-- per IP address. Counters back off exponentially past a free allowance and lock accounts.
+
+CREATE TABLE IF NOT EXISTS auth_throttles (
+    action VARCHAR(30) NOT NULL,                -- login, forgot_password, resend_verification
+    subject VARCHAR(320) NOT NULL,              -- account:<email> or ip:<address>
+    attempts INT NOT NULL DEFAULT 0,            -- Failures (login) or requests (email actions) in the window
+    window_started_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    blocked_until TIMESTAMP WITH TIME ZONE NULL, -- Backoff: no attempts before this
+    locked_until TIMESTAMP WITH TIME ZONE NULL,  -- Account lockout, lifted early by an admin
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (action, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_throttles_locked_until ON auth_throttles(locked_until) WHERE locked_until IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_auth_throttles_updated_at ON auth_throttles(updated_at);
+
+COMMENT ON TABLE auth_throttles IS 'Failed login and email request counters per account and IP, for backoff and lockout';
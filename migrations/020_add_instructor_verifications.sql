@@ -0,0 +1,19 @@
+-- Instructor verification: accounts that are not recognised as students register as
+-- PENDING_INSTRUCTOR and only become INSTRUCTOR once an admin approves them
+
+-- Runs inside the migration transaction, so nothing below may use the new value
+ALTER TYPE role_type ADD VALUE IF NOT EXISTS 'PENDING_INSTRUCTOR';
+
+CREATE TABLE IF NOT EXISTS instructor_verifications (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING'
+        CHECK (status IN ('PENDING', 'APPROVED', 'REJECTED')),
+    requested_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    reviewed_by BIGINT NULL REFERENCES users(id) ON DELETE SET NULL, -- Admin who decided
+    reviewed_at TIMESTAMP WITH TIME ZONE NULL,
+    rejection_reason TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_instructor_verifications_status ON instructor_verifications(status, requested_at);
+
+COMMENT ON TABLE instructor_verifications IS 'Admin review of accounts asking to be instructors; the role changes when a request is approved or rejected';
@@ -0,0 +1,21 @@
+-- Scoped role assignments: on top of the role of their account, users can hold roles limited to
+-- a faculty, department or community (e.g. INSTRUCTOR of one department, MODERATOR of a
+-- community). Permissions of each role are defined in code.
+
+CREATE TABLE IF NOT EXISTS role_assignments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL,
+    scope_type VARCHAR(20) NOT NULL
+        CHECK (scope_type IN ('global', 'faculty', 'department', 'community')),
+    scope_id BIGINT NULL, -- NULL only for global assignments
+    granted_by BIGINT NULL REFERENCES users(id) ON DELETE SET NULL, -- Admin who assigned the role
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((scope_type = 'global') = (scope_id IS NULL))
+);
+
+-- scope_id is NULL for global assignments, which a plain UNIQUE constraint would not compare
+CREATE UNIQUE INDEX IF NOT EXISTS idx_role_assignments_unique
+    ON role_assignments(user_id, role, scope_type, COALESCE(scope_id, 0));
+
+COMMENT ON TABLE role_assignments IS 'Roles granted to users within a scope, in addition to the role of their account';
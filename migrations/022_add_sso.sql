@@ -0,0 +1,36 @@
+-- Single sign-on with OpenID Connect: accounts linked to identities at a provider, and the
+-- logins started at the provider that have not come back yet
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer VARCHAR(255) NOT NULL,  -- Issuer URL of the provider
+    subject VARCHAR(255) NOT NULL, -- sub claim, stable per user at the provider
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMP WITH TIME ZONE NULL,
+    UNIQUE (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+CREATE TABLE IF NOT EXISTS sso_login_states (
+    state VARCHAR(64) PRIMARY KEY,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL, -- PKCE verifier; the provider only saw its challenge
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sso_login_states_expires_at ON sso_login_states(expires_at);
+
+-- Student numbers of provisioned students. The repository has always written here, but no
+-- migration created the table.
+CREATE TABLE IF NOT EXISTS students (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    identifier VARCHAR(50) NOT NULL UNIQUE, -- Student number
+    graduation_year INT NOT NULL DEFAULT 0  -- 0 when unknown
+);
+
+COMMENT ON TABLE user_identities IS 'Accounts at OpenID Connect providers that log in to a user';
+COMMENT ON TABLE sso_login_states IS 'Single sign-on logins waiting for the provider to redirect back';
@@ -0,0 +1,10 @@
+-- LDAP directory accounts are linked to users through user_identities, with the directory
+-- as issuer and the entry's ID attribute as subject. The sync job deactivates users whose
+-- entry disappeared and records it here, so it only reactivates the users it deactivated.
+
+ALTER TABLE user_identities
+    ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMP WITH TIME ZONE NULL; -- Set while the user is deactivated because the entry is gone
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_issuer ON user_identities(issuer);
+
+COMMENT ON COLUMN user_identities.deactivated_at IS 'When the directory sync deactivated the user because their entry disappeared';
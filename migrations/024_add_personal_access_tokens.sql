@@ -0,0 +1,19 @@
+-- Personal access tokens let scripts and bots call the API as a user without their password.
+-- Only a hash of each token is stored; the token itself is shown once when it is created.
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE, -- SHA-256 of the token, hex-encoded
+    token_hint VARCHAR(20) NOT NULL, -- Start of the token, to recognize it in lists
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NULL, -- NULL for tokens that do not expire
+    last_used_at TIMESTAMP WITH TIME ZONE NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user_id ON personal_access_tokens(user_id);
+
+COMMENT ON TABLE personal_access_tokens IS 'Named, scoped API tokens users create for integrations';
@@ -0,0 +1,18 @@
+-- Email changes reuse the email verification tokens: one confirms the new address, the other,
+-- sent to the old address, undoes the change. The purpose keeps them from verifying accounts.
+
+ALTER TABLE email_verification_tokens
+    ADD COLUMN IF NOT EXISTS purpose VARCHAR(20) NOT NULL DEFAULT 'verify',
+    ADD COLUMN IF NOT EXISTS email VARCHAR(255) NULL; -- New address to confirm, or old address to restore
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'email_verification_tokens_purpose_check') THEN
+        ALTER TABLE email_verification_tokens
+            ADD CONSTRAINT email_verification_tokens_purpose_check
+            CHECK (purpose IN ('verify', 'email_change', 'email_change_undo'));
+    END IF;
+END$$;
+
+COMMENT ON COLUMN email_verification_tokens.purpose IS 'verify, email_change or email_change_undo';
+COMMENT ON COLUMN email_verification_tokens.email IS 'Address an email change token sets';
@@ -0,0 +1,8 @@
+-- Wrong two-factor codes are no longer forgotten when the password is entered again: only a
+-- right code clears them, or the time since the last wrong one.
+
+ALTER TABLE user_totp
+    ADD COLUMN IF NOT EXISTS last_failed_at TIMESTAMP WITH TIME ZONE NULL;
+
+COMMENT ON COLUMN user_totp.failed_attempts IS 'Wrong codes since the last right one, within the attempt window';
+COMMENT ON COLUMN user_totp.last_failed_at IS 'When the last wrong code was given';
@@ -0,0 +1,28 @@
+-- Access token revocations have to outlive the user: the cutoff moves from users to a table of its
+-- own, and neither it nor the jti denylist is deleted along with the user. Rows are pruned once
+-- every token they concern has expired.
+
+CREATE TABLE IF NOT EXISTS user_token_cutoffs (
+    user_id BIGINT PRIMARY KEY, -- No foreign key: the cutoff has to survive the user's deletion
+    invalid_before TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_token_cutoffs_invalid_before ON user_token_cutoffs(invalid_before);
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'users' AND column_name = 'tokens_invalid_before') THEN
+        INSERT INTO user_token_cutoffs (user_id, invalid_before)
+        SELECT id, tokens_invalid_before FROM users WHERE tokens_invalid_before IS NOT NULL
+        ON CONFLICT (user_id) DO UPDATE
+            SET invalid_before = GREATEST(user_token_cutoffs.invalid_before, EXCLUDED.invalid_before);
+
+        DROP INDEX IF EXISTS idx_users_tokens_invalid_before;
+        ALTER TABLE users DROP COLUMN tokens_invalid_before;
+    END IF;
+END$$;
+
+ALTER TABLE revoked_access_tokens DROP CONSTRAINT IF EXISTS revoked_access_tokens_user_id_fkey;
+
+COMMENT ON TABLE user_token_cutoffs IS 'Access tokens of a user issued before invalid_before are rejected';
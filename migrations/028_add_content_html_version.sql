@@ -0,0 +1,9 @@
+-- content_html is only valid for the renderer and sanitizer policy it was rendered with.
+-- content_html_version records that policy version; HTML with another version, including rows
+-- written before this migration (NULL), is rendered again on read.
+
+ALTER TABLE class_notes ADD COLUMN IF NOT EXISTS content_html_version INTEGER NULL;
+ALTER TABLE past_exams ADD COLUMN IF NOT EXISTS content_html_version INTEGER NULL;
+
+COMMENT ON COLUMN class_notes.content_html_version IS 'Sanitizer policy version content_html was rendered with';
+COMMENT ON COLUMN past_exams.content_html_version IS 'Sanitizer policy version content_html was rendered with';
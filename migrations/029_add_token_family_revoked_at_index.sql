@@ -0,0 +1,5 @@
+-- Access tokens carry the token family (session) they were issued for. The revocation cache
+-- loads the families revoked within the access token lifetime, to reject the access tokens of a
+-- session as soon as it is signed out.
+
+CREATE INDEX IF NOT EXISTS idx_token_families_revoked_at ON token_families(revoked_at) WHERE revoked_at IS NOT NULL;